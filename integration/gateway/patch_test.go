@@ -364,5 +364,5 @@ func TestLayerCountAfterPatch(t *testing.T) {
 
 // initBuildkitConfig is a helper that creates a buildkit config for an image.
 func initBuildkitConfig(ctx context.Context, c gwclient.Client, imageName string, platform *specs.Platform) (*buildkit.Config, error) {
-	return buildkit.InitializeBuildkitConfig(ctx, c, imageName, platform)
+	return buildkit.InitializeBuildkitConfig(ctx, c, imageName, platform, false, buildkit.PullMissing.ResolveMode())
 }
@@ -7,7 +7,9 @@ import (
 
 	"github.com/project-copacetic/copacetic/pkg/cmd"
 	"github.com/project-copacetic/copacetic/pkg/generate"
+	"github.com/project-copacetic/copacetic/pkg/normalize"
 	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/validatereport"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -41,6 +43,8 @@ func newRootCmd() *cobra.Command {
 
 	rootCmd.AddCommand(cmd.NewPatchCmd())
 	rootCmd.AddCommand(generate.NewGenerateCmd())
+	rootCmd.AddCommand(normalize.NewNormalizeCmd())
+	rootCmd.AddCommand(validatereport.NewValidateReportCmd())
 	return rootCmd
 }
 
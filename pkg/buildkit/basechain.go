@@ -0,0 +1,59 @@
+package buildkit
+
+import (
+	"context"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxBaseImageChain bounds how many BaseImage labels are followed, in case labels
+// written by other tools point back at each other.
+const maxBaseImageChain = 8
+
+// followBaseImages resolves the images named by the BaseImage label of image's base,
+// and the label of each of those in turn. label is the BaseImage label found on image.
+// The walk stops at an image labelled as its own base, at one it has already seen, or
+// at one that can't be resolved, which is still listed, without a digest.
+func followBaseImages(ctx context.Context, c gwclient.Client, image, label string, resolveMode llb.ResolveMode) []types.BaseImageLink {
+	var chain []types.BaseImageLink
+	seen := map[string]bool{image: true}
+	for label != "" && !seen[label] && len(chain) < maxBaseImageChain {
+		seen[label] = true
+		_, dgst, configData, err := c.ResolveImageConfig(ctx, label, sourceresolver.Opt{
+			ImageOpt: &sourceresolver.ResolveImageOpt{
+				ResolveMode: resolveMode.String(),
+			},
+		})
+		if err != nil {
+			log.Debugf("Failed to resolve BaseImage %s while following the base image chain: %v", label, err)
+			return append(chain, types.BaseImageLink{Ref: label})
+		}
+		chain = append(chain, types.BaseImageLink{Ref: label, Digest: dgst.String()})
+
+		next, _, err := setupLabels(label, configData)
+		if err != nil {
+			log.Debugf("Failed to read the BaseImage label of %s: %v", label, err)
+			break
+		}
+		label = next
+	}
+	return chain
+}
+
+// FormatBaseImageChain renders chain as "image@digest -> base@digest -> ...".
+func FormatBaseImageChain(chain []types.BaseImageLink) string {
+	links := make([]string, 0, len(chain))
+	for _, link := range chain {
+		if link.Digest == "" {
+			links = append(links, link.Ref+" (unresolved)")
+			continue
+		}
+		links = append(links, link.Ref+"@"+link.Digest)
+	}
+	return strings.Join(links, " -> ")
+}
@@ -0,0 +1,73 @@
+package buildkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/opencontainers/go-digest"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/project-copacetic/copacetic/mocks"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeBuildkitConfigBaseChain(t *testing.T) {
+	ctx := context.Background()
+	platform := &ispec.Platform{OS: "linux", Architecture: "amd64"}
+
+	mockClient := &mocks.MockGWClient{}
+	mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:patched-2", mock.Anything).
+		Return("", digest.Digest("sha256:p2"), []byte(`{"config":{"labels":{"BaseImage":"example.com/app:patched-1"}}}`), nil).Once()
+	mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:patched-1", mock.Anything).
+		Return("", digest.Digest("sha256:p1"), []byte(`{"config":{"labels":{"BaseImage":"example.com/app:1.0"}}}`), nil).Once()
+	mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:1.0", mock.Anything).
+		Return("", digest.Digest("sha256:orig"), []byte(`{"config":{}}`), nil).Once()
+
+	config, err := InitializeBuildkitConfig(ctx, mockClient, "example.com/app:patched-2", platform, false, llb.ResolveModePreferLocal)
+	require.NoError(t, err)
+	assert.Equal(t, []types.BaseImageLink{
+		{Ref: "example.com/app:patched-2", Digest: "sha256:p2"},
+		{Ref: "example.com/app:patched-1", Digest: "sha256:p1"},
+		{Ref: "example.com/app:1.0", Digest: "sha256:orig"},
+	}, config.BaseChain)
+	mockClient.AssertExpectations(t)
+}
+
+func TestFollowBaseImages(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stops at a cycle", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig", mock.Anything, "b", mock.Anything).
+			Return("", digest.Digest("sha256:b"), []byte(`{"config":{"labels":{"BaseImage":"a"}}}`), nil).Once()
+
+		chain := followBaseImages(ctx, mockClient, "a", "b", llb.ResolveModePreferLocal)
+		assert.Equal(t, []types.BaseImageLink{{Ref: "b", Digest: "sha256:b"}}, chain)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("keeps an unresolved base", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig", mock.Anything, "b", mock.Anything).
+			Return("", digest.Digest(""), []byte(nil), errors.New("not found")).Once()
+
+		chain := followBaseImages(ctx, mockClient, "a", "b", llb.ResolveModePreferLocal)
+		assert.Equal(t, []types.BaseImageLink{{Ref: "b"}}, chain)
+	})
+
+	t.Run("no label", func(t *testing.T) {
+		assert.Empty(t, followBaseImages(ctx, &mocks.MockGWClient{}, "a", "", llb.ResolveModePreferLocal))
+	})
+}
+
+func TestFormatBaseImageChain(t *testing.T) {
+	chain := []types.BaseImageLink{
+		{Ref: "example.com/app:patched", Digest: "sha256:p"},
+		{Ref: "example.com/app:1.0"},
+	}
+	assert.Equal(t, "example.com/app:patched@sha256:p -> example.com/app:1.0 (unresolved)", FormatBaseImageChain(chain))
+}
@@ -47,6 +47,33 @@ type Config struct {
 	PatchedImageState llb.State
 	// ImageLabels contains OCI labels from the image config (e.g. org.opencontainers.image.*).
 	ImageLabels map[string]string
+	// MaxConcurrentDownloads caps parallel package downloads in the apt, dnf, yum
+	// and npm commands. Zero leaves the package manager defaults in place.
+	MaxConcurrentDownloads int
+	// RepoSnapshots maps a package type (deb, apk) to the pinned repository URLs
+	// package managers use instead of the image's configured repositories.
+	RepoSnapshots map[string]string
+	// RepoMirrors maps a package type (deb, apk, rpm) to a mirror URL that replaces the
+	// host of the image's configured repositories while patching.
+	RepoMirrors map[string]string
+	// APTSecurityOnly pins apt to the security suite of the image's release and
+	// upgrades reported packages to the versions there instead of those reported.
+	APTSecurityOnly bool
+	// PkgMgrPaths maps a package manager command (apk, apt-get, npm) to the absolute
+	// path it is invoked by inside the image, for images where it is not on PATH.
+	PkgMgrPaths map[string]string
+	// BaseChain is the target image followed by the images its BaseImage label leads
+	// to, each with the digest it resolved to.
+	BaseChain []types.BaseImageLink
+}
+
+// PkgMgrBinary returns the command that invokes the package manager name inside the
+// image: its configured path if one is set, otherwise name itself.
+func (c *Config) PkgMgrBinary(name string) string {
+	if path := c.PkgMgrPaths[name]; path != "" {
+		return path
+	}
+	return name
 }
 
 type Opts struct {
@@ -66,6 +93,8 @@ var (
 	readDir  = os.ReadDir
 	readFile = os.ReadFile
 	lookPath = exec.LookPath
+
+	localManifests localManifestSource = daemonManifestSource{}
 )
 
 func InitializeBuildkitConfig(
@@ -73,6 +102,8 @@ func InitializeBuildkitConfig(
 	c gwclient.Client,
 	userImage string,
 	platform *specs.Platform,
+	noRebase bool,
+	resolveMode llb.ResolveMode,
 ) (*Config, error) {
 	// Initialize buildkit config for the target image
 	config := Config{
@@ -83,27 +114,29 @@ func InitializeBuildkitConfig(
 	// Resolve and pull the config for the target image
 	resolveOpt := sourceresolver.Opt{
 		ImageOpt: &sourceresolver.ResolveImageOpt{
-			ResolveMode: llb.ResolveModePreferLocal.String(),
+			ResolveMode: resolveMode.String(),
 		},
 	}
 	if platform != nil {
 		resolveOpt.ImageOpt.Platform = platform
 	}
-	_, _, configData, err := c.ResolveImageConfig(ctx, userImage, resolveOpt)
+	_, dgst, configData, err := c.ResolveImageConfig(ctx, userImage, resolveOpt)
 	if err != nil {
 		return nil, err
 	}
 
 	var baseImage string
-	config.ConfigData, config.PatchedConfigData, baseImage, err = updateImageConfigData(ctx, c, configData, userImage)
+	var baseChain []types.BaseImageLink
+	config.ConfigData, config.PatchedConfigData, baseImage, baseChain, err = updateImageConfigData(ctx, c, configData, userImage, noRebase, resolveMode)
 	if err != nil {
 		return nil, err
 	}
+	config.BaseChain = append([]types.BaseImageLink{{Ref: userImage, Digest: dgst.String()}}, baseChain...)
 
 	// Load the target image state with the resolved image config in case environment variable settings
 	// are necessary for running apps in the target image for updates
 	imageOpts := []llb.ImageOption{
-		llb.ResolveModePreferLocal,
+		resolveMode,
 		llb.WithMetaResolver(c),
 	}
 	if platform != nil {
@@ -119,7 +152,7 @@ func InitializeBuildkitConfig(
 	// BaseImage or specs.AnnotationBaseImageName
 	if config.PatchedConfigData != nil {
 		patchedImageOpts := []llb.ImageOption{
-			llb.ResolveModePreferLocal,
+			resolveMode,
 			llb.WithMetaResolver(c),
 		}
 		if platform != nil {
@@ -153,8 +186,37 @@ func extractLabelsFromConfig(configData []byte) map[string]string {
 	return parsed.Config.Labels
 }
 
-func DiscoverPlatformsFromReport(reportDir, scanner string) ([]types.PatchPlatform, error) {
+// ReportDiscoveryOptions configure how DiscoverPlatformsFromReport reads a report
+// directory and DiscoverPlatforms matches its reports to platforms. The zero value
+// reads .json reports of supported OS types, fails on the first report that cannot
+// be parsed and matches on the full platform key.
+type ReportDiscoveryOptions struct {
+	// Extensions of the files read as reports (empty = report.DefaultFileExtension)
+	Extensions []string
+
+	// AcceptAnyOSType keeps reports whose OS type Copa has no package manager for,
+	// as the package manager is chosen by --force-pkg-manager.
+	AcceptAnyOSType bool
+
+	// KeyFormat is how reports are matched to platforms (empty = ReportPlatformKeyFull)
+	KeyFormat ReportPlatformKeyFormat
+
+	// SkipMalformed keeps going past reports that cannot be parsed, so the other
+	// platforms can still be patched. A report whose file name carries its platform,
+	// e.g. linux-arm64.json, is still returned for that platform, so patching it fails
+	// with types.ErrReportParse; other malformed reports are left out.
+	SkipMalformed bool
+}
+
+// DiscoverPlatformsFromReport returns a platform for each report in reportDir. Only
+// files with one of opts.Extensions are read as reports. A nil opts is the zero
+// ReportDiscoveryOptions.
+func DiscoverPlatformsFromReport(reportDir, scanner string, opts *ReportDiscoveryOptions) ([]types.PatchPlatform, error) {
+	if opts == nil {
+		opts = &ReportDiscoveryOptions{}
+	}
 	var platforms []types.PatchPlatform
+	exts := report.FileExtensionsOrDefault(opts.Extensions)
 
 	reportNames, err := os.ReadDir(reportDir)
 	if err != nil {
@@ -166,13 +228,38 @@ func DiscoverPlatformsFromReport(reportDir, scanner string) ([]types.PatchPlatfo
 		if file.IsDir() {
 			continue
 		}
+		if !report.HasFileExtension(file.Name(), exts) {
+			log.Debugf("Skipping %s in report directory, report files end in %s", file.Name(), strings.Join(exts, ", "))
+			continue
+		}
+		// Copa's own per-platform VEX output may share the directory with the reports
+		if utils.IsPlatformArtifact(utils.VEXArtifactPrefix, file.Name()) {
+			log.Debugf("Skipping VEX document %s in report directory", file.Name())
+			continue
+		}
+		reportName := report.TrimFileExtension(file.Name(), exts)
 		report, err := report.TryParseScanReport(filePath, scanner, utils.PkgTypeOS, utils.PatchTypePatch)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing report %w", err)
+			if !opts.SkipMalformed {
+				return nil, fmt.Errorf("error parsing report %w", err)
+			}
+			if p, ok := utils.PlatformFromReportName(reportName); ok {
+				// Patching the platform reports the parse error for it
+				log.Warnf("Report %s for platform %s could not be parsed: %v", filePath, PlatformKey(p), err)
+				if p.Architecture == arm64 && p.Variant == "v8" {
+					p.Variant = ""
+				}
+				p.Variant = NormalizeArmVariant(p.Architecture, p.Variant)
+				platforms = append(platforms, types.PatchPlatform{Platform: p, ReportFile: filePath})
+				continue
+			}
+			// The platform of the report is unknown, so it is preserved as one without a report
+			log.Warnf("Skipping report %s, it could not be parsed: %v", filePath, err)
+			continue
 		}
 
 		// use this to confirm that os type (ex/Debian) is linux based and supported since report.Metadata.OS.Type gives specific like "debian" rather than "linux"
-		if !isSupportedOsType(report.Metadata.OS.Type) {
+		if !opts.AcceptAnyOSType && !isSupportedOsType(report.Metadata.OS.Type) {
 			continue
 		}
 
@@ -191,6 +278,7 @@ func DiscoverPlatformsFromReport(reportDir, scanner string) ([]types.PatchPlatfo
 			// the same for the platforms discovered from reports
 			platform.Variant = ""
 		}
+		platform.Variant = NormalizeArmVariant(platform.Architecture, platform.Variant)
 		platforms = append(platforms, platform)
 	}
 
@@ -213,39 +301,52 @@ func isSupportedOsType(osType string) bool {
 		utils.OSTypeAlmaLinux,
 		utils.OSTypeSLES,
 		utils.OSTypeOpenSUSELeap,
-		utils.OSTypeOpenSUSETW:
+		utils.OSTypeOpenSUSETW,
+		utils.OSTypeWolfi,
+		utils.OSTypeChainguard:
 		return true
 	default:
 		return false
 	}
 }
 
-// TryGetManifestFromLocal attempts to get manifest data from the local Docker daemon.
-// It returns a remote.Descriptor if successful, or an error if the manifest cannot be retrieved locally.
-// This is exported to support patching images that exist locally but not in a remote registry.
-func TryGetManifestFromLocal(ref name.Reference) (*remote.Descriptor, error) {
-	imageName := ref.String()
-	log.Debugf("Attempting to get manifest from local daemon for %s", imageName)
+// localManifestSource reads the raw manifest of an image from the local image store.
+type localManifestSource interface {
+	RawManifest(ctx context.Context, ref name.Reference) ([]byte, error)
+}
 
-	// Try to get the image from the local daemon using go-containerregistry
-	// First, try to get it as an image index (multi-platform)
-	ctx := context.Background()
+// daemonManifestSource reads manifests from the local Docker daemon.
+type daemonManifestSource struct{}
 
-	// Attempt to read raw manifest from daemon
-	// The daemon package doesn't directly expose manifest inspection, so we use a workaround:
-	// Try to get the image and then extract its raw manifest
+func (daemonManifestSource) RawManifest(ctx context.Context, ref name.Reference) ([]byte, error) {
 	img, err := daemon.Image(ref, daemon.WithContext(ctx))
 	if err != nil {
-		log.Debugf("Failed to get image from daemon for %s: %v", imageName, err)
 		return nil, fmt.Errorf("failed to get image from local daemon: %v", err)
 	}
-
-	// Get the raw manifest
 	rawManifest, err := img.RawManifest()
 	if err != nil {
-		log.Debugf("Failed to get raw manifest for %s: %v", imageName, err)
 		return nil, fmt.Errorf("failed to get raw manifest: %v", err)
 	}
+	return rawManifest, nil
+}
+
+// TryGetManifestFromLocal attempts to get manifest data from the local Docker daemon.
+// It returns a remote.Descriptor if successful, or an error if the manifest cannot be retrieved locally.
+// This is exported to support patching images that exist locally but not in a remote registry.
+func TryGetManifestFromLocal(ctx context.Context, ref name.Reference) (*remote.Descriptor, error) {
+	imageName := ref.String()
+	if pullPolicyFromContext(ctx) == PullAlways {
+		return nil, errLocalImagesDisabled
+	}
+	log.Debugf("Attempting to get manifest from local daemon for %s", imageName)
+
+	// The daemon package doesn't directly expose manifest inspection, so the local
+	// source gets the image and then extracts its raw manifest
+	rawManifest, err := localManifests.RawManifest(ctx, ref)
+	if err != nil {
+		log.Debugf("Failed to get manifest from local daemon for %s: %v", imageName, err)
+		return nil, err
+	}
 
 	// Parse the manifest to determine if it's a manifest list
 	var manifestData map[string]interface{}
@@ -310,24 +411,26 @@ func TryGetManifestFromLocal(ref name.Reference) (*remote.Descriptor, error) {
 	return nil, fmt.Errorf("single-platform image")
 }
 
+// ErrUnknownImagePlatform is returned when a single-platform image's config does not
+// declare an OS and architecture, so its platform cannot be determined.
+var ErrUnknownImagePlatform = errors.New("image config does not specify os/architecture")
+
 // DiscoverPlatformsFromReference discovers platforms from both local and remote manifests.
 // It first attempts to inspect the manifest locally using Docker API
 // to get raw manifest data and determine if it's multi-platform.
 // If local inspection fails, it falls back to remote registry inspection.
 // This allows Copa to patch multi-platform manifests that exist locally but not in the registry.
-func DiscoverPlatformsFromReference(manifestRef string) ([]types.PatchPlatform, error) {
-	var platforms []types.PatchPlatform
-
+func DiscoverPlatformsFromReference(ctx context.Context, manifestRef string) ([]types.PatchPlatform, error) {
 	ref, err := name.ParseReference(manifestRef)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing reference %q: %w", manifestRef, err)
 	}
 
 	// Try local daemon first, then fall back to remote
-	desc, err := TryGetManifestFromLocal(ref)
+	desc, err := TryGetManifestFromLocal(ctx, ref)
 	if err != nil {
 		log.Debugf("Failed to get descriptor from local daemon: %v, trying remote registry", err)
-		desc, err = remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		desc, err = utils.RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 		if err != nil {
 			return nil, fmt.Errorf("error fetching descriptor for %q from both local daemon and remote registry: %w", manifestRef, err)
 		}
@@ -336,6 +439,24 @@ func DiscoverPlatformsFromReference(manifestRef string) ([]types.PatchPlatform,
 		log.Debugf("Successfully fetched descriptor from local daemon for %s", manifestRef)
 	}
 
+	return discoverPlatformsFromDescriptor(desc, manifestRef)
+}
+
+// DiscoverPlatformsFromDescriptor discovers platforms from an already-fetched descriptor
+// without any network or daemon access. An index yields one platform per manifest with a
+// known platform; a single image yields the platform declared in its config.
+func DiscoverPlatformsFromDescriptor(desc *remote.Descriptor) ([]types.PatchPlatform, error) {
+	if desc == nil {
+		return nil, errors.New("descriptor is nil")
+	}
+	return discoverPlatformsFromDescriptor(desc, desc.Digest.String())
+}
+
+// discoverPlatformsFromDescriptor implements DiscoverPlatformsFromDescriptor, naming the
+// image as source in errors.
+func discoverPlatformsFromDescriptor(desc *remote.Descriptor, source string) ([]types.PatchPlatform, error) {
+	var platforms []types.PatchPlatform
+
 	if desc.MediaType.IsIndex() {
 		index, err := desc.ImageIndex()
 		if err != nil {
@@ -372,6 +493,8 @@ func DiscoverPlatformsFromReference(manifestRef string) ([]types.PatchPlatform,
 				// need to remove it here to maintain consistency
 				patchPlatform.Variant = ""
 			}
+			// arm/v6 and arm/v7 stay distinct platforms
+			patchPlatform.Variant = NormalizeArmVariant(patchPlatform.Architecture, patchPlatform.Variant)
 			platforms = append(platforms, patchPlatform)
 		}
 		return platforms, nil
@@ -402,11 +525,11 @@ func DiscoverPlatformsFromReference(manifestRef string) ([]types.PatchPlatform,
 		if platform.Architecture == arm64 && platform.Variant == "v8" {
 			platform.Variant = ""
 		}
+		platform.Variant = NormalizeArmVariant(platform.Architecture, platform.Variant)
 		return []types.PatchPlatform{platform}, nil
 	}
 
-	// return nil if platform information is not available
-	return nil, nil
+	return nil, fmt.Errorf("%w: %s has os=%q architecture=%q", ErrUnknownImagePlatform, source, config.OS, config.Architecture)
 }
 
 //nolint:gocritic
@@ -423,10 +546,28 @@ func PlatformKey(pl specs.Platform) string {
 	return key
 }
 
-func DiscoverPlatforms(manifestRef, reportDir, scanner string) ([]types.PatchPlatform, error) {
-	var platforms []types.PatchPlatform
+// ErrMalformedPlatform is returned when a platform lacks the OS or architecture
+// needed to build a platform key.
+var ErrMalformedPlatform = errors.New("malformed platform")
+
+// PlatformKeyChecked is like PlatformKey but returns an error instead of a
+// partial key (e.g. "/amd64") when the OS or architecture is missing.
+func PlatformKeyChecked(pl specs.Platform) (string, error) {
+	var missing []string
+	if pl.OS == "" {
+		missing = append(missing, "os")
+	}
+	if pl.Architecture == "" {
+		missing = append(missing, "architecture")
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: missing %s in platform %q", ErrMalformedPlatform, strings.Join(missing, " and "), PlatformKey(pl))
+	}
+	return PlatformKey(pl), nil
+}
 
-	p, err := DiscoverPlatformsFromReference(manifestRef)
+func DiscoverPlatforms(ctx context.Context, manifestRef, reportDir, scanner string, opts *ReportDiscoveryOptions) ([]types.PatchPlatform, error) {
+	p, err := DiscoverPlatformsFromReference(ctx, manifestRef)
 	if err != nil {
 		return nil, err
 	}
@@ -436,53 +577,80 @@ func DiscoverPlatforms(manifestRef, reportDir, scanner string) ([]types.PatchPla
 	log.WithField("platforms", p).Debug("Discovered platforms from manifest")
 
 	if reportDir != "" {
-		p2, err := DiscoverPlatformsFromReport(reportDir, scanner)
+		p2, err := DiscoverPlatformsFromReport(reportDir, scanner, opts)
 		if err != nil {
 			return nil, err
 		}
 		log.WithField("platforms", p2).Debug("Discovered platforms from report")
 
-		// include all platforms from original manifest, patching only those with reports
-		reportSet := make(map[string]string, len(p2))
-		for _, pl := range p2 {
-			reportSet[PlatformKey(pl.Platform)] = pl.ReportFile
-		}
-
-		for _, pl := range p {
-			if rp, ok := reportSet[PlatformKey(pl.Platform)]; ok {
-				// Platform has a report - will be patched
-				pl.ReportFile = rp
-				pl.ShouldPreserve = false
-				platforms = append(platforms, pl)
-			} else {
-				// Platform has no report - preserve original without patching
-				log.Debugf("No report found for platform %s, preserving original", PlatformKey(pl.Platform))
-				pl.ReportFile = ""
-				pl.ShouldPreserve = true
-				platforms = append(platforms, pl)
-			}
+		format := ReportPlatformKeyFull
+		if opts != nil && opts.KeyFormat != "" {
+			format = opts.KeyFormat
 		}
-
-		return platforms, nil
+		return matchReportsToPlatforms(manifestRef, p, p2, format)
 	}
 
 	return p, nil
 }
 
-// GetPlatformImageReference resolves a platform-specific image reference from a local manifest.
-// For multi-platform images that exist locally but not in the registry, this function extracts
-// the platform-specific digest and constructs a reference that BuildKit can resolve.
-func GetPlatformImageReference(manifestRef string, targetPlatform *specs.Platform) (string, error) {
+// matchReportsToPlatforms returns every platform of the image manifest, with those
+// that have a report under format set to be patched and the rest to be preserved.
+func matchReportsToPlatforms(manifestRef string, manifestPlatforms, reportPlatforms []types.PatchPlatform, format ReportPlatformKeyFormat) ([]types.PatchPlatform, error) {
+	reportSet := make(map[string]string, len(reportPlatforms))
+	for _, pl := range reportPlatforms {
+		key, err := reportPlatformKey(pl.Platform, format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid platform in report %s: %w", pl.ReportFile, err)
+		}
+		if other, ok := reportSet[key]; ok && format == ReportPlatformKeyOSArch {
+			return nil, fmt.Errorf("reports %s and %s both match platform %s with --report-platform-key-format=%s", other, pl.ReportFile, key, format)
+		}
+		reportSet[key] = pl.ReportFile
+	}
+
+	platforms := make([]types.PatchPlatform, 0, len(manifestPlatforms))
+	for _, pl := range manifestPlatforms {
+		key, err := reportPlatformKey(pl.Platform, format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid platform in manifest %s: %w", manifestRef, err)
+		}
+		if rp, ok := reportSet[key]; ok {
+			// Platform has a report - will be patched
+			pl.ReportFile = rp
+			pl.ShouldPreserve = false
+		} else {
+			// Platform has no report - preserve original without patching
+			log.Debugf("No report found for platform %s, preserving original", key)
+			pl.ReportFile = ""
+			pl.ShouldPreserve = true
+		}
+		platforms = append(platforms, pl)
+	}
+	return platforms, nil
+}
+
+// GetPlatformImageReference resolves a platform-specific image reference from a manifest list.
+// The local daemon is consulted first so images that exist locally but not in the registry work;
+// otherwise the index is fetched from the registry. Either way the platform-specific digest is
+// used to construct a repo@digest reference that BuildKit can resolve.
+func GetPlatformImageReference(ctx context.Context, manifestRef string, targetPlatform *specs.Platform) (string, error) {
 	ref, err := name.ParseReference(manifestRef)
 	if err != nil {
 		return "", fmt.Errorf("error parsing reference %q: %w", manifestRef, err)
 	}
 
-	// Try to get the local manifest first
-	desc, err := TryGetManifestFromLocal(ref)
+	// Try to get the local manifest first, then fall back to the registry
+	source := "local"
+	desc, err := TryGetManifestFromLocal(ctx, ref)
 	if err != nil {
-		// Not a local manifest, return original reference
-		return manifestRef, nil
+		log.Debugf("Failed to get local manifest for %s: %v, trying remote registry", manifestRef, err)
+		source = "remote"
+		desc, err = utils.RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		if err != nil {
+			// Unresolvable here; let BuildKit resolve the original reference
+			log.Debugf("Failed to get remote manifest for %s: %v, using original reference", manifestRef, err)
+			return manifestRef, nil
+		}
 	}
 
 	if !desc.MediaType.IsIndex() {
@@ -514,7 +682,8 @@ func GetPlatformImageReference(manifestRef string, targetPlatform *specs.Platfor
 		if manifestPlatform.Architecture == arm64 && manifestPlatform.Variant == "v8" {
 			manifestPlatform.Variant = ""
 		}
-		targetVariant := targetPlatform.Variant
+		manifestPlatform.Variant = NormalizeArmVariant(manifestPlatform.Architecture, manifestPlatform.Variant)
+		targetVariant := NormalizeArmVariant(targetPlatform.Architecture, targetPlatform.Variant)
 		if targetPlatform.Architecture == arm64 && targetVariant == "v8" {
 			targetVariant = ""
 		}
@@ -523,35 +692,45 @@ func GetPlatformImageReference(manifestRef string, targetPlatform *specs.Platfor
 		if manifestPlatform.OS == targetPlatform.OS &&
 			manifestPlatform.Architecture == targetPlatform.Architecture &&
 			manifestPlatform.Variant == targetVariant {
-			// For local manifests, we need to construct a reference to the platform-specific image
+			// Construct a reference to the platform-specific image
 			// Extract the base repository name (without tag/digest)
 			baseRepo := ref.Context().Name()
 
 			// Construct platform-specific image reference with digest
 			platformImageRef := baseRepo + "@" + manifest.Digest
 
-			log.Debugf("Found platform %s/%s in local manifest, using image reference: %s",
-				manifestPlatform.OS, manifestPlatform.Architecture, platformImageRef)
+			log.Debugf("Found platform %s/%s in %s manifest, using image reference: %s",
+				manifestPlatform.OS, manifestPlatform.Architecture, source, platformImageRef)
 			return platformImageRef, nil
 		}
 	}
 
-	return "", fmt.Errorf("platform %s/%s not found in manifest", targetPlatform.OS, targetPlatform.Architecture)
+	return "", fmt.Errorf("platform %s not found in manifest", PlatformKey(*targetPlatform))
 }
 
-func updateImageConfigData(ctx context.Context, c gwclient.Client, configData []byte, image string) ([]byte, []byte, string, error) {
+// updateImageConfigData labels the config of image with its base image. An image that
+// already carries a BaseImage label is treated as previously patched: the returned
+// config is that of the base image, and the image's own config is returned as the
+// patched config so the new patch can be rebased onto the base, along with the base and
+// the images its own BaseImage label leads to. With noRebase the label is left as is
+// and the image is patched as a fresh image.
+func updateImageConfigData(ctx context.Context, c gwclient.Client, configData []byte, image string, noRebase bool, resolveMode llb.ResolveMode) ([]byte, []byte, string, []types.BaseImageLink, error) {
 	baseImage, userImageConfig, err := setupLabels(image, configData)
 	if err != nil {
-		return nil, nil, "", err
+		return nil, nil, "", nil, err
+	}
+	if noRebase && baseImage != "" {
+		log.Infof("Ignoring BaseImage label %s of %s: rebase disabled", baseImage, image)
+		baseImage = ""
 	}
 
 	if baseImage == "" {
 		configData = userImageConfig
 	} else {
 		patchedImageConfig := userImageConfig
-		_, _, baseImageConfig, err := c.ResolveImageConfig(ctx, baseImage, sourceresolver.Opt{
+		_, baseDigest, baseImageConfig, err := c.ResolveImageConfig(ctx, baseImage, sourceresolver.Opt{
 			ImageOpt: &sourceresolver.ResolveImageOpt{
-				ResolveMode: llb.ResolveModePreferLocal.String(),
+				ResolveMode: resolveMode.String(),
 			},
 		})
 		if err != nil {
@@ -560,12 +739,12 @@ func updateImageConfigData(ctx context.Context, c gwclient.Client, configData []
 			imageConfig := make(map[string]interface{})
 			if err := json.Unmarshal(configData, &imageConfig); err != nil {
 				log.Warnf("Failed to unmarshal image config: %v", err)
-				return configData, nil, image, nil
+				return configData, nil, image, nil, nil
 			}
 			configMap, ok := imageConfig["config"].(map[string]interface{})
 			if !ok {
 				log.Warnf("Invalid config structure in image config")
-				return configData, nil, image, nil
+				return configData, nil, image, nil, nil
 			}
 			if configMap["labels"] == nil {
 				configMap["labels"] = make(map[string]interface{})
@@ -573,24 +752,34 @@ func updateImageConfigData(ctx context.Context, c gwclient.Client, configData []
 			labelsMap, ok := configMap["labels"].(map[string]interface{})
 			if !ok {
 				log.Warnf("Invalid labels structure in image config")
-				return configData, nil, image, nil
+				return configData, nil, image, nil, nil
 			}
-			labelsMap["BaseImage"] = image
+			setBaseImageLabel(labelsMap, image)
 			updatedConfigData, err := json.Marshal(imageConfig)
 			if err != nil {
 				log.Warnf("Failed to marshal updated image config: %v", err)
-				return configData, nil, image, nil
+				return configData, nil, image, nil, nil
 			}
-			return updatedConfigData, nil, image, nil
+			if err := VerifyRuntimeConfig(configData, updatedConfigData); err != nil {
+				return nil, nil, "", nil, err
+			}
+			return updatedConfigData, nil, image, nil, nil
 		}
 
-		_, baseImageWithLabels, _ := setupLabels(baseImage, baseImageConfig)
-		configData = baseImageWithLabels
+		baseLabel, baseImageWithLabels, _ := setupLabels(baseImage, baseImageConfig)
+		// The base image config replaces the user's, so carry over how the user's
+		// image runs; the base may have a different entrypoint or run as root.
+		configData, err = PreserveRuntimeConfig(userImageConfig, baseImageWithLabels)
+		if err != nil {
+			return nil, nil, "", nil, fmt.Errorf("failed to preserve runtime config of %s: %w", image, err)
+		}
 
-		return configData, patchedImageConfig, baseImage, nil
+		chain := []types.BaseImageLink{{Ref: baseImage, Digest: baseDigest.String()}}
+		chain = append(chain, followBaseImages(ctx, c, baseImage, baseLabel, resolveMode)...)
+		return configData, patchedImageConfig, baseImage, chain, nil
 	}
 
-	return configData, nil, image, nil
+	return configData, nil, image, nil, nil
 }
 
 func setupLabels(image string, configData []byte) (string, []byte, error) {
@@ -616,21 +805,63 @@ func setupLabels(image string, configData []byte) (string, []byte, error) {
 		err := fmt.Errorf("type assertion to map[string]interface{} failed")
 		return "", nil, err
 	}
-	if baseImageValue := labelsMap["BaseImage"]; baseImageValue != nil {
-		baseImage, ok = baseImageValue.(string)
-		if !ok {
-			err := fmt.Errorf("type assertion to string failed")
-			return "", nil, err
-		}
-	} else {
-		labelsMap["BaseImage"] = image
+	baseImage, err = baseImageFromLabels(labelsMap)
+	if err != nil {
+		return "", nil, err
+	}
+	if baseImage == "" {
+		labelsMap[baseImageLabel] = image
 	}
 
-	imageWithLabels, _ := json.Marshal(imageConfig)
+	imageWithLabels, err := json.Marshal(imageConfig)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Only the labels were meant to change; catch anything lost in the map round-trip
+	if err := VerifyRuntimeConfig(configData, imageWithLabels); err != nil {
+		return "", nil, err
+	}
 
 	return baseImage, imageWithLabels, nil
 }
 
+// baseImageLabel is the label Copa records the unpatched image of a patched image in.
+const baseImageLabel = "BaseImage"
+
+// baseImageFromLabels returns the base image recorded in labels, or "" for an image
+// that was never patched. The OCI org.opencontainers.image.base.name label is preferred
+// over the BaseImage label, and an image with either of them counts as patched.
+func baseImageFromLabels(labels map[string]interface{}) (string, error) {
+	var baseImage string
+	for _, key := range []string{specs.AnnotationBaseImageName, baseImageLabel} {
+		value, ok := labels[key]
+		if !ok || value == nil {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("type assertion to string failed for label %s", key)
+		}
+		if baseImage == "" {
+			baseImage = s
+		} else if s != baseImage {
+			log.Debugf("Ignoring %s label %s, using %s label %s", key, s, specs.AnnotationBaseImageName, baseImage)
+		}
+	}
+	return baseImage, nil
+}
+
+// setBaseImageLabel records image as the base image in labels, in the OCI base name
+// label when the image already has one so the two labels don't disagree.
+func setBaseImageLabel(labels map[string]interface{}, image string) {
+	if _, ok := labels[specs.AnnotationBaseImageName]; ok {
+		labels[specs.AnnotationBaseImageName] = image
+		return
+	}
+	labels[baseImageLabel] = image
+}
+
 // Extracts the bytes of the file denoted by `path` from the state `st`.
 func ExtractFileFromState(ctx context.Context, c gwclient.Client, st *llb.State, path string) ([]byte, error) {
 	// since platform is obtained from host, override it in the case of Darwin
@@ -729,6 +960,45 @@ func TryExtractFileFromState(ctx context.Context, c gwclient.Client, st *llb.Sta
 	return data, nil
 }
 
+// ExistingFilesInState returns which of paths exist in the state st, solving it once.
+func ExistingFilesInState(ctx context.Context, c gwclient.Client, st *llb.State, paths []string) (map[string]bool, error) {
+	platform := platforms.Normalize(platforms.DefaultSpec())
+	if platform.OS != linux {
+		platform.OS = linux
+	}
+
+	def, err := st.Marshal(ctx, llb.Platform(platform))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Solve(ctx, gwclient.SolveRequest{
+		Evaluate:   true,
+		Definition: def.ToPB(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := resp.SingleRef()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if _, err := ref.StatFile(ctx, gwclient.StatRequest{Path: path}); err != nil {
+			msg := err.Error()
+			if strings.Contains(msg, "no such file or directory") || strings.Contains(msg, "not a directory") {
+				continue
+			}
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		existing[path] = true
+	}
+	return existing, nil
+}
+
 func Sh(cmd string) llb.RunOption {
 	return llb.Args([]string{"/bin/sh", "-c", cmd})
 }
@@ -772,7 +1042,7 @@ func QemuAvailable(p *types.PatchPlatform) bool {
 		return true
 	}
 
-	archKey := mapGoArch(p.Architecture, p.Variant)
+	archKey := mapGoArch(p.Architecture, NormalizeArmVariant(p.Architecture, p.Variant))
 
 	// walk binfmt_misc entries
 	entries, err := readDir("/proc/sys/fs/binfmt_misc")
@@ -785,8 +1055,7 @@ func QemuAvailable(p *types.PatchPlatform) bool {
 			continue
 		}
 		data, _ := readFile("/proc/sys/fs/binfmt_misc/" + e.Name())
-		if bytes.Contains(data, []byte("interpreter")) &&
-			bytes.Contains(data, []byte("qemu-"+archKey)) {
+		if binfmtInterpreter(data) == "qemu-"+archKey {
 			return true
 		}
 	}
@@ -797,6 +1066,33 @@ func QemuAvailable(p *types.PatchPlatform) bool {
 	return false
 }
 
+// binfmtInterpreter returns the QEMU binary a binfmt_misc entry runs, without its
+// directory or -static suffix, so that qemu-arm is not mistaken for qemu-armeb.
+func binfmtInterpreter(entry []byte) string {
+	for _, line := range bytes.Split(entry, []byte("\n")) {
+		if interpreter, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("interpreter ")); ok {
+			return strings.TrimSuffix(filepath.Base(string(bytes.TrimSpace(interpreter))), "-static")
+		}
+	}
+	return ""
+}
+
+// NormalizeArmVariant returns the OCI variant (v5, v6 or v7) for a 32-bit arm
+// variant given as a GOARM value such as "6" or "7,softfloat", so that the same
+// variant always yields the same platform key. Other variants and architectures
+// are returned unchanged; an arm platform without a variant keeps none.
+func NormalizeArmVariant(arch, variant string) string {
+	if arch != "arm" {
+		return variant
+	}
+	v, _, _ := strings.Cut(strings.ToLower(variant), ",")
+	switch strings.TrimPrefix(v, "v") {
+	case "5", "6", "7":
+		return "v" + strings.TrimPrefix(v, "v")
+	}
+	return variant
+}
+
 func mapGoArch(arch, variant string) string {
 	switch arch {
 	case "amd64", "amd64p32":
@@ -809,7 +1105,7 @@ func mapGoArch(arch, variant string) string {
 		return "aarch64"
 
 	case "arm":
-		// GOARM=5/6/7 -> qemu-arm
+		// GOARM=5/6/7 -> qemu-arm, which emulates every variant
 		// big-endian -> qemu-armeb
 		if strings.HasSuffix(variant, "eb") || strings.HasSuffix(arch, "be") {
 			return "armeb"
@@ -869,35 +1165,79 @@ func mapGoArch(arch, variant string) string {
 	return arch
 }
 
-// CreateOCILayoutFromResults creates an OCI layout directory from patch results using BuildKit's OCI exporter.
-func CreateOCILayoutFromResults(outputDir string, results []types.PatchResult, platforms []types.PatchPlatform) error {
-	log.Infof("Creating multi-platform OCI layout in directory: %s with %d platforms", outputDir, len(platforms))
+// OCIPartialMode controls how an OCI layout export handles platforms that failed to
+// patch or could not be exported.
+type OCIPartialMode string
 
-	// Create output directory
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+const (
+	// OCIPartialStrict fails the whole export if any platform failed.
+	OCIPartialStrict OCIPartialMode = "strict"
+	// OCIPartialPreserve includes the original, unpatched image for failed platforms.
+	OCIPartialPreserve OCIPartialMode = "preserve"
+	// OCIPartialOmit leaves failed platforms out of the layout's index.
+	OCIPartialOmit OCIPartialMode = "omit"
+)
+
+// ParseOCIPartialMode validates an --oci-partial value.
+func ParseOCIPartialMode(s string) (OCIPartialMode, error) {
+	switch mode := OCIPartialMode(s); mode {
+	case OCIPartialStrict, OCIPartialPreserve, OCIPartialOmit:
+		return mode, nil
 	}
+	return "", fmt.Errorf("unsupported --oci-partial %q, supported: %s, %s, %s", s, OCIPartialStrict, OCIPartialPreserve, OCIPartialOmit)
+}
 
-	// Check if we have BuildKit states available
-	hasStates := false
+// handleFailedPlatforms applies partial to the patched platforms that have no image to
+// export, returning the platforms to preserve as their original image.
+func handleFailedPlatforms(failed, preserved []types.PatchPlatform, partial OCIPartialMode) ([]types.PatchPlatform, error) {
+	if len(failed) == 0 {
+		return preserved, nil
+	}
+	keys := make([]string, 0, len(failed))
+	for _, p := range failed {
+		keys = append(keys, PlatformKey(p.Platform))
+	}
 
-	for _, result := range results {
-		if result.PatchedState != nil {
-			hasStates = true
-			break
+	switch partial {
+	case OCIPartialPreserve:
+		log.Warnf("Using the original image in the OCI layout for failed platform(s): %s", strings.Join(keys, ", "))
+		for _, p := range failed {
+			p.ShouldPreserve = true
+			preserved = append(preserved, p)
 		}
+		return preserved, nil
+	case OCIPartialOmit:
+		log.Warnf("Leaving failed platform(s) out of the OCI layout: %s", strings.Join(keys, ", "))
+		return preserved, nil
+	default:
+		return nil, fmt.Errorf("no patched image for platform(s) %s; use --oci-partial=omit or --oci-partial=preserve to export the others",
+			strings.Join(keys, ", "))
 	}
+}
 
-	if hasStates {
-		log.Info("Using BuildKit states directly for OCI export")
-		return createOCILayoutFromStates(outputDir, results, platforms)
+// CreateOCILayoutFromResults creates an OCI layout directory from patch results using BuildKit's OCI exporter.
+// cache may be nil; when set, its imports and exports are applied to every platform solve.
+// export configures the images BuildKit exports and may be nil. Platforms that failed are handled according to partial.
+func CreateOCILayoutFromResults(ctx context.Context, outputDir string, results []types.PatchResult, platforms []types.PatchPlatform, cache *CacheOptions, export *OCIExportOptions, partial OCIPartialMode) error {
+	log.Infof("Creating multi-platform OCI layout in directory: %s with %d platforms", outputDir, len(platforms))
+
+	// Malformed platforms would silently fail to match their patch results
+	for _, platform := range platforms {
+		if _, err := PlatformKeyChecked(platform.Platform); err != nil {
+			return fmt.Errorf("cannot create OCI layout: %w", err)
+		}
 	}
 
-	return fmt.Errorf("no BuildKit states available for OCI export, cannot proceed")
+	// Create output directory
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	return createOCILayoutFromStates(ctx, outputDir, results, platforms, cache, export, partial)
 }
 
 // createOCILayoutFromStates creates OCI layout directly from BuildKit states.
-func createOCILayoutFromStates(outputDir string, results []types.PatchResult, platforms []types.PatchPlatform) error {
+func createOCILayoutFromStates(ctx context.Context, outputDir string, results []types.PatchResult, platforms []types.PatchPlatform, cache *CacheOptions, export *OCIExportOptions, partial OCIPartialMode) error {
 	log.Info("Creating OCI layout from preserved BuildKit states and preserved platforms")
 
 	// Separate patched and preserved platforms
@@ -918,32 +1258,33 @@ func createOCILayoutFromStates(outputDir string, results []types.PatchResult, pl
 	var platformStates []llb.State
 	var platformSpecs []specs.Platform
 
-	// Map results by platform for easy lookup
-	resultMap := make(map[string]*types.PatchResult)
-	for i, result := range results {
-		// Find the platform for this result
-		for _, platform := range patchedPlatforms {
-			platformKey := PlatformKey(platform.Platform)
-			// Match by patched reference suffix or exact match
-			if result.PatchedState != nil {
-				expectedSuffix := getPlatformSuffix(&platform.Platform)
-				if strings.HasSuffix(result.PatchedRef.String(), expectedSuffix) {
-					resultMap[platformKey] = &results[i]
-					break
-				}
-			}
-		}
-	}
+	resultMap := mapResultsByPlatform(results)
+
+	unchanged := unchangedPlatformKeys(results)
 
 	// Create states for each patched platform
+	var failedPlatforms []types.PatchPlatform
 	for _, platform := range patchedPlatforms {
-		platformKey := PlatformKey(platform.Platform)
-		if result, exists := resultMap[platformKey]; exists && result.PatchedState != nil {
+		key := PlatformKey(platform.Platform)
+		if result, exists := resultMap[key]; exists {
 			platformStates = append(platformStates, *result.PatchedState)
 			platformSpecs = append(platformSpecs, platform.Platform)
+		} else if unchanged[key] {
+			// Nothing was patched, so the original image is copied as is rather than
+			// solved again, keeping its digest and anything signed against it.
+			log.Infof("Platform %s is up to date, copying its original image to the OCI layout", key)
+			platform.ShouldPreserve = true
+			preservedPlatforms = append(preservedPlatforms, platform)
+		} else {
+			failedPlatforms = append(failedPlatforms, platform)
 		}
 	}
 
+	preservedPlatforms, err := handleFailedPlatforms(failedPlatforms, preservedPlatforms, partial)
+	if err != nil {
+		return fmt.Errorf("cannot create OCI layout: %w", err)
+	}
+
 	if len(platformStates) == 0 && len(preservedPlatforms) == 0 {
 		return fmt.Errorf("no BuildKit states or preserved platforms found")
 	}
@@ -955,19 +1296,22 @@ func createOCILayoutFromStates(outputDir string, results []types.PatchResult, pl
 	switch {
 	case hasPreservedPlatforms && hasPatchedPlatforms:
 		log.Infof("Creating mixed OCI layout with %d patched and %d preserved platforms", len(platformStates), len(preservedPlatforms))
-		return createMixedOCILayout(outputDir, results, platformStates, platformSpecs, preservedPlatforms)
+		return createMixedOCILayout(ctx, outputDir, results, platformStates, platformSpecs, preservedPlatforms, cache, export, partial)
+	case hasPatchedPlatforms && partial != OCIPartialStrict:
+		// The mixed layout exports platforms one at a time, so a platform that fails
+		// to solve can be left out or preserved without losing the others.
+		log.Infof("Creating OCI layout from %d patched platforms, tolerating per-platform failures", len(platformStates))
+		return createMixedOCILayout(ctx, outputDir, results, platformStates, platformSpecs, nil, cache, export, partial)
 	case hasPatchedPlatforms:
 		log.Infof("Creating OCI layout from %d patched platforms only", len(platformStates))
 	case hasPreservedPlatforms:
 		log.Infof("Creating OCI layout from %d preserved platforms only", len(preservedPlatforms))
-		return createPreservedOnlyOCILayout(outputDir, results, preservedPlatforms)
+		return createPreservedOnlyOCILayout(ctx, outputDir, results, preservedPlatforms)
 	}
 
 	log.Infof("Creating OCI layout from %d BuildKit states", len(platformStates))
 
 	// Use BuildKit Go client to create OCI layout
-	ctx := context.Background()
-
 	// Try buildx driver first
 	h, err := connhelpers.Buildx(&url.URL{})
 	if err != nil {
@@ -980,7 +1324,7 @@ func createOCILayoutFromStates(outputDir string, results []types.PatchResult, pl
 				log.Debug("Using buildx driver for OCI layout export")
 				defer c.Close()
 
-				return solveMultiPlatformOCI(ctx, c, outputDir, platformStates, platformSpecs)
+				return solveMultiPlatformOCI(ctx, c, outputDir, platformStates, platformSpecs, cache, export)
 			}
 			c.Close()
 		}
@@ -990,17 +1334,18 @@ func createOCILayoutFromStates(outputDir string, results []types.PatchResult, pl
 	// Fall back to auto-detection
 	log.Debug("Falling back to auto-detection for BuildKit client")
 	bkOpts := Opts{}
-	c, err := NewClient(ctx, bkOpts)
+	c, err := newOCIExportClient(ctx, bkOpts)
 	if err != nil {
-		return fmt.Errorf("failed to create BuildKit client: %w", err)
+		log.Warnf("Failed to create BuildKit client for OCI export, assembling the layout from the platform images: %v", err)
+		return createOCILayoutFromImages(ctx, outputDir, results, platforms, partial)
 	}
 	defer c.Close()
 
-	return solveMultiPlatformOCI(ctx, c, outputDir, platformStates, platformSpecs)
+	return solveMultiPlatformOCI(ctx, c, outputDir, platformStates, platformSpecs, cache, export)
 }
 
 // solveMultiPlatformOCI uses BuildKit client to solve multi-platform states and export to OCI layout.
-func solveMultiPlatformOCI(ctx context.Context, c *client.Client, outputDir string, platformStates []llb.State, platformSpecs []specs.Platform) error {
+func solveMultiPlatformOCI(ctx context.Context, c *client.Client, outputDir string, platformStates []llb.State, platformSpecs []specs.Platform, cache *CacheOptions, export *OCIExportOptions) error {
 	if len(platformStates) == 0 {
 		return fmt.Errorf("no platform states provided")
 	}
@@ -1017,194 +1362,54 @@ func solveMultiPlatformOCI(ctx context.Context, c *client.Client, outputDir stri
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	if len(platformStates) == 1 {
-		// Single platform case - use output function to avoid diffcopy issues
-		return solveSinglePlatformOCI(ctx, c, outputDir, &platformStates[0], &platformSpecs[0])
-	}
-
-	// Multi-platform case - solve each platform and combine
-	return solveAndCombineAllPlatforms(ctx, c, outputDir, platformStates, platformSpecs)
+	return solveAndCombineAllPlatforms(ctx, c, outputDir, platformStates, platformSpecs, cache, export)
 }
 
-// solveSinglePlatformOCI handles single platform OCI export using output function.
-func solveSinglePlatformOCI(ctx context.Context, c *client.Client, outputDir string, state *llb.State, platformSpec *specs.Platform) error {
-	// Create solve options with output function to avoid diffcopy issues
-	solveOpt := client.SolveOpt{
-		Exports: []client.ExportEntry{{
-			Type: client.ExporterOCI,
-			Attrs: map[string]string{
-				"oci-mediatypes": "true",
-				"buildinfo":      "false",
-			},
-			Output: func(_ map[string]string) (io.WriteCloser, error) {
-				tarPath := filepath.Join(outputDir, "image.tar")
-				return os.Create(tarPath)
-			},
-		}},
-	}
-
-	// Marshal the state with platform constraint
-	def, err := state.Marshal(ctx, llb.Platform(*platformSpec))
-	if err != nil {
-		return fmt.Errorf("failed to marshal LLB state: %w", err)
-	}
-
-	// Solve to tar
-	_, err = c.Solve(ctx, def, solveOpt, nil)
-	if err != nil {
-		return fmt.Errorf("BuildKit solve failed: %w", err)
-	}
-
-	// Extract tar to OCI layout
-	tarPath := filepath.Join(outputDir, "image.tar")
-	if err := extractTarToDirectory(tarPath, outputDir); err != nil {
-		return fmt.Errorf("failed to extract OCI layout: %w", err)
-	}
-
-	// Clean up tar file
-	os.Remove(tarPath)
-
-	// Fix platform information in the extracted OCI layout
-	if err := fixSinglePlatformInfo(outputDir, platformSpec); err != nil {
-		return fmt.Errorf("failed to fix platform information: %w", err)
-	}
-
-	return nil
+// OCIExportOptions configure the images BuildKit exports to an OCI layout.
+type OCIExportOptions struct {
+	// Compression of the layers of each platform, by PlatformKey: gzip, zstd, estargz
+	// or uncompressed. Platforms without one keep BuildKit's default.
+	Compression map[string]string
+	// ForceCompression recompresses the original image's layers too, not only the
+	// patch layers.
+	ForceCompression bool
+	// Annotations added to the manifest of each platform
+	Annotations map[string]string
 }
 
-// fixSinglePlatformInfo corrects the platform information in a single-platform OCI layout.
-func fixSinglePlatformInfo(outputDir string, platformSpec *specs.Platform) error {
-	indexPath := filepath.Join(outputDir, "index.json")
-	indexData, err := os.ReadFile(indexPath)
-	if err != nil {
-		return fmt.Errorf("failed to read index.json: %w", err)
-	}
-
-	var index map[string]interface{}
-	if err := json.Unmarshal(indexData, &index); err != nil {
-		return fmt.Errorf("failed to parse index.json: %w", err)
-	}
-
-	// Create correct platform info
-	targetPlatform := map[string]interface{}{
-		"os":           platformSpec.OS,
-		"architecture": platformSpec.Architecture,
+// ociExportAttrs returns the attributes of the OCI exporter used to export platform
+// to an OCI layout. export may be nil.
+func ociExportAttrs(export *OCIExportOptions, platform specs.Platform) map[string]string {
+	attrs := map[string]string{
+		"oci-mediatypes": "true",
+		"buildinfo":      "false",
 	}
-
-	if platformSpec.Variant != "" {
-		targetPlatform["variant"] = platformSpec.Variant
+	if export == nil {
+		export = &OCIExportOptions{}
 	}
-
-	// Update platform information in all manifests
-	if manifests, ok := index["manifests"].([]interface{}); ok {
-		for _, manifest := range manifests {
-			if manifestMap, ok := manifest.(map[string]interface{}); ok {
-				manifestMap["platform"] = targetPlatform
-			}
+	if compression := export.Compression[PlatformKey(platform)]; compression != "" {
+		attrs["compression"] = compression
+		if export.ForceCompression {
+			attrs["force-compression"] = "true"
 		}
 	}
-
-	// Write back the corrected index
-	indexJSON, err := json.MarshalIndent(index, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal corrected index: %w", err)
-	}
-
-	if err := os.WriteFile(indexPath, indexJSON, 0o600); err != nil {
-		return fmt.Errorf("failed to write corrected index.json: %w", err)
+	for k, v := range export.Annotations {
+		attrs["annotation."+k] = v
 	}
-
-	return nil
+	return attrs
 }
 
 // solveAndCombineAllPlatforms solves each platform and combines them into one OCI layout.
-func solveAndCombineAllPlatforms(ctx context.Context, c *client.Client, outputDir string, platformStates []llb.State, platformSpecs []specs.Platform) error {
-	// Create temporary directory for platform tars
-	tempDir, err := os.MkdirTemp("", "copa-platforms-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	var platformTars []string
-
-	// Solve each platform to its own tar file using output function
-	for i := range platformSpecs {
-		platformTarPath := filepath.Join(tempDir, fmt.Sprintf("platform-%d.tar", i))
-		platformTars = append(platformTars, platformTarPath)
-
-		// Create solve options with output function
-		platformSolveOpt := client.SolveOpt{
-			Exports: []client.ExportEntry{{
-				Type: client.ExporterOCI,
-				Attrs: map[string]string{
-					"oci-mediatypes": "true",
-					"buildinfo":      "false",
-				},
-				Output: func(_ map[string]string) (io.WriteCloser, error) {
-					return os.Create(platformTarPath)
-				},
-			}},
-		}
-
-		// Marshal and solve this platform's definition
-		def, err := platformStates[i].Marshal(ctx, llb.Platform(platformSpecs[i]))
-		if err != nil {
-			return fmt.Errorf("failed to marshal platform: %w", err)
-		}
-
-		_, err = c.Solve(ctx, def, platformSolveOpt, nil)
-		if err != nil {
-			return fmt.Errorf("failed to solve platform: %w", err)
-		}
-	}
-
-	// Extract and combine all platform tars into multi-platform OCI layout
-	return extractAndCombinePlatformTars(outputDir, platformTars, platformSpecs)
-}
-
-// extractAndCombinePlatformTars extracts platform tars and combines them into multi-platform OCI layout.
-func extractAndCombinePlatformTars(outputDir string, platformTars []string, platformSpecs []specs.Platform) error {
-	// Create output directory structure
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	blobsDir := filepath.Join(outputDir, "blobs")
-	if err := os.MkdirAll(blobsDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create blobs directory: %w", err)
-	}
-
-	// Create oci-layout file
-	ociLayoutContent := `{"imageLayoutVersion":"1.0.0"}`
-	if err := os.WriteFile(filepath.Join(outputDir, "oci-layout"), []byte(ociLayoutContent), 0o600); err != nil {
-		return fmt.Errorf("failed to write oci-layout: %w", err)
-	}
-
-	// Collect all platform manifests and copy blobs
+// The exports are streamed into outputDir, which holds each blob once.
+func solveAndCombineAllPlatforms(ctx context.Context, c *client.Client, outputDir string, platformStates []llb.State, platformSpecs []specs.Platform, cache *CacheOptions, export *OCIExportOptions) error {
 	var platformManifests []map[string]interface{}
-	blobsSet := make(map[string]bool) // Track blobs to avoid duplicates
+	blobs := make(map[string]bool)
 
-	for i, platformTar := range platformTars {
+	for i := range platformSpecs {
 		platformSpec := platformSpecs[i]
-
-		// Extract platform tar to temporary directory
-		platformTempDir, err := os.MkdirTemp("", "copa-platform-extract-*")
-		if err != nil {
-			return fmt.Errorf("failed to create temp directory for platform: %w", err)
-		}
-		defer os.RemoveAll(platformTempDir)
-
-		// Extract tar file
-		if err := extractTarToDirectory(platformTar, platformTempDir); err != nil {
-			return fmt.Errorf("failed to extract tar for platform: %w", err)
-		}
-
-		// Read the platform's index.json
-		indexPath := filepath.Join(platformTempDir, "index.json")
-		indexData, err := os.ReadFile(indexPath)
+		indexData, err := solvePlatformOCI(ctx, c, outputDir, &platformStates[i], &platformSpec, cache, export, blobs)
 		if err != nil {
-			return fmt.Errorf("failed to read index.json from platform: %w", err)
+			return fmt.Errorf("failed to solve platform: %w", err)
 		}
 
 		var index map[string]interface{}
@@ -1233,12 +1438,12 @@ func extractAndCombinePlatformTars(outputDir string, platformTars []string, plat
 				}
 			}
 		}
+	}
 
-		// Copy blobs from this platform to the combined layout
-		platformBlobsDir := filepath.Join(platformTempDir, "blobs")
-		if err := copyBlobs(platformBlobsDir, blobsDir, blobsSet); err != nil {
-			return fmt.Errorf("failed to copy blobs from platform: %w", err)
-		}
+	// Create oci-layout file
+	ociLayoutContent := `{"imageLayoutVersion":"1.0.0"}`
+	if err := os.WriteFile(filepath.Join(outputDir, "oci-layout"), []byte(ociLayoutContent), 0o600); err != nil {
+		return fmt.Errorf("failed to write oci-layout: %w", err)
 	}
 
 	// Create the combined index.json with all platform manifests
@@ -1260,153 +1465,71 @@ func extractAndCombinePlatformTars(outputDir string, platformTars []string, plat
 	return nil
 }
 
-// copyBlobs copies blob files from source to destination, avoiding duplicates.
-func copyBlobs(srcBlobsDir, dstBlobsDir string, blobsSet map[string]bool) error {
-	// Check if source blobs directory exists
-	if _, err := os.Stat(srcBlobsDir); os.IsNotExist(err) {
-		log.Debugf("Source blobs directory does not exist: %s", srcBlobsDir)
-		return nil // Not an error, platform might not have blobs
-	}
-
-	// Walk through the blobs directory structure (sha256/*)
-	return filepath.Walk(srcBlobsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			return nil
-		}
-
-		// Get relative path from blobs directory
-		relPath, err := filepath.Rel(srcBlobsDir, path)
-		if err != nil {
-			return err
-		}
-
-		// Skip if blob already exists
-		if blobsSet[relPath] {
-			log.Debugf("Skipping duplicate blob: %s", relPath)
-			return nil
-		}
-
-		// Create destination directory structure
-		dstPath := filepath.Join(dstBlobsDir, relPath)
-		dstDir := filepath.Dir(dstPath)
-		if err := os.MkdirAll(dstDir, 0o755); err != nil {
-			return fmt.Errorf("failed to create destination directory %s: %w", dstDir, err)
-		}
-
-		// Copy the blob file
-		srcFile, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("failed to open source blob %s: %w", path, err)
-		}
-		defer srcFile.Close()
-
-		dstFile, err := os.Create(dstPath)
-		if err != nil {
-			return fmt.Errorf("failed to create destination blob %s: %w", dstPath, err)
+// mapResultsByPlatform indexes the results carrying a BuildKit state by the platform
+// they were patched for. Preserved results have no state and are handled separately.
+func mapResultsByPlatform(results []types.PatchResult) map[string]*types.PatchResult {
+	resultMap := make(map[string]*types.PatchResult)
+	for i := range results {
+		if results[i].PatchedState == nil || results[i].Preserved {
+			continue
 		}
-		defer dstFile.Close()
-
-		if _, err := io.Copy(dstFile, srcFile); err != nil {
-			return fmt.Errorf("failed to copy blob %s: %w", relPath, err)
+		key := PlatformKey(results[i].Platform)
+		if _, exists := resultMap[key]; exists {
+			log.Warnf("Multiple patch results for platform %s, using the first", key)
+			continue
 		}
-
-		// Mark blob as copied
-		blobsSet[relPath] = true
-		log.Debugf("Copied blob: %s", relPath)
-
-		return nil
-	})
-}
-
-// extractTarToDirectory extracts a tar file to a directory.
-func extractTarToDirectory(tarPath, destDir string) error {
-	// Validate and clean paths to prevent path traversal attacks
-	cleanTarPath := filepath.Clean(tarPath)
-	cleanDestDir := filepath.Clean(destDir)
-
-	// Ensure destination directory doesn't contain path traversal sequences
-	if strings.Contains(cleanDestDir, "..") {
-		return fmt.Errorf("destination directory contains invalid path traversal sequence: %s", destDir)
-	}
-
-	// Verify tar file exists and is a regular file
-	tarInfo, err := os.Stat(cleanTarPath)
-	if err != nil {
-		return fmt.Errorf("failed to stat tar file %s: %w", cleanTarPath, err)
+		resultMap[key] = &results[i]
 	}
-	if !tarInfo.Mode().IsRegular() {
-		return fmt.Errorf("tar path %s is not a regular file", cleanTarPath)
-	}
-
-	// Ensure destination directory exists
-	if err := os.MkdirAll(cleanDestDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create destination directory %s: %w", cleanDestDir, err)
-	}
-
-	// Extract tar file using tar command with validated paths
-	cmd := exec.Command("tar", "-xf", cleanTarPath, "-C", cleanDestDir)
-
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to extract tar %s: %v, output: %s", cleanTarPath, err, string(output))
-	}
-
-	log.Debugf("Successfully extracted tar %s to directory %s", cleanTarPath, cleanDestDir)
-	return nil
+	return resultMap
 }
 
-// getPlatformSuffix returns the expected image tag suffix for a platform.
-func getPlatformSuffix(platform *specs.Platform) string {
-	suffix := "-" + platform.Architecture
-	if platform.Variant != "" {
-		suffix += "-" + platform.Variant
+// unchangedPlatformKeys returns the platform keys of the results that have no patched
+// state because the platform was preserved or already up to date.
+func unchangedPlatformKeys(results []types.PatchResult) map[string]bool {
+	keys := make(map[string]bool)
+	for i := range results {
+		if results[i].PatchedState == nil {
+			keys[PlatformKey(results[i].Platform)] = true
+		}
 	}
-	return suffix
+	return keys
 }
 
 // createMixedOCILayout creates an OCI layout combining patched and preserved platforms.
 func createMixedOCILayout(
+	ctx context.Context,
 	outputDir string,
 	results []types.PatchResult,
 	platformStates []llb.State,
 	platformSpecs []specs.Platform,
 	preservedPlatforms []types.PatchPlatform,
+	cache *CacheOptions,
+	export *OCIExportOptions,
+	partial OCIPartialMode,
 ) error {
 	log.Infof("Creating mixed OCI layout with %d patched platforms and %d preserved platforms", len(platformStates), len(preservedPlatforms))
 
-	ctx := context.Background()
-
 	// Step 1: Create OCI layouts for patched platforms
 	var patchedManifests []map[string]interface{}
 	allBlobs := make(map[string]bool) // Track all blobs to avoid duplicates
 
 	if len(platformStates) > 0 {
-		// Create temporary directory for patched platforms
-		patchedTempDir, err := os.MkdirTemp("", "copa-patched-platforms-*")
-		if err != nil {
-			return fmt.Errorf("failed to create temp directory for patched platforms: %w", err)
-		}
-		defer os.RemoveAll(patchedTempDir)
-
 		// Export patched platforms using BuildKit
 		bkOpts := Opts{}
-		c, err := NewClient(ctx, bkOpts)
+		c, err := newOCIExportClient(ctx, bkOpts)
 		if err != nil {
-			return fmt.Errorf("failed to create BuildKit client for mixed layout: %w", err)
+			log.Warnf("Failed to create BuildKit client for OCI export, assembling the layout from the platform images: %v", err)
+			return createOCILayoutFromImages(ctx, outputDir, results, append(platformsOf(platformSpecs), preservedPlatforms...), partial)
 		}
 		defer c.Close()
 
-		patchedManifests, err = exportPatchedPlatformsToTemp(ctx, c, patchedTempDir, platformStates, platformSpecs)
+		var failed []types.PatchPlatform
+		patchedManifests, failed, err = exportPatchedPlatformsToOutput(ctx, c, outputDir, platformStates, platformSpecs, cache, export, partial, allBlobs)
 		if err != nil {
 			return fmt.Errorf("failed to export patched platforms: %w", err)
 		}
-
-		// Copy patched platform blobs to final output directory
-		if err := copyBlobsToOutput(outputDir, patchedTempDir, allBlobs); err != nil {
-			return fmt.Errorf("failed to copy patched platform blobs: %w", err)
+		if preservedPlatforms, err = handleFailedPlatforms(failed, preservedPlatforms, partial); err != nil {
+			return err
 		}
 	}
 
@@ -1426,7 +1549,7 @@ func createMixedOCILayout(
 			log.Warn("Could not determine original image reference for preserved platforms, skipping preserved platforms export")
 		} else {
 			var err error
-			preservedManifests, err = exportPreservedPlatformsToOutput(outputDir, originalRef, preservedPlatforms, allBlobs)
+			preservedManifests, err = exportPreservedPlatformsToOutput(ctx, outputDir, originalRef, preservedPlatforms, allBlobs)
 			if err != nil {
 				return fmt.Errorf("failed to export preserved platforms: %w", err)
 			}
@@ -1443,87 +1566,47 @@ func createMixedOCILayout(
 	return createFinalOCILayout(outputDir, patchedManifests)
 }
 
-// exportPatchedPlatformsToTemp exports patched platforms using BuildKit to a temporary directory.
-func exportPatchedPlatformsToTemp(ctx context.Context, c *client.Client, tempDir string, platformStates []llb.State, platformSpecs []specs.Platform) ([]map[string]interface{}, error) {
-	var manifests []map[string]interface{}
-
-	// Export each platform to its own tar file
-	for i, platformState := range platformStates {
+// exportPatchedPlatformsToOutput exports patched platforms using BuildKit, streaming their
+// blobs into outputDir and recording them in blobsSet. Unless partial is strict, a platform
+// that fails to solve is skipped and returned in failed.
+func exportPatchedPlatformsToOutput(
+	ctx context.Context,
+	c *client.Client,
+	outputDir string,
+	platformStates []llb.State,
+	platformSpecs []specs.Platform,
+	cache *CacheOptions,
+	export *OCIExportOptions,
+	partial OCIPartialMode,
+	blobsSet map[string]bool,
+) (manifests []map[string]interface{}, failed []types.PatchPlatform, err error) {
+	for i := range platformStates {
 		platformSpec := platformSpecs[i]
-		platformTarPath := filepath.Join(tempDir, fmt.Sprintf("platform-%d.tar", i))
-
-		// Create solve options with output function
-		solveOpt := client.SolveOpt{
-			Exports: []client.ExportEntry{{
-				Type: client.ExporterOCI,
-				Attrs: map[string]string{
-					"oci-mediatypes": "true",
-					"buildinfo":      "false",
-				},
-				Output: func(_ map[string]string) (io.WriteCloser, error) {
-					return os.Create(platformTarPath)
-				},
-			}},
-		}
 
-		// Marshal and solve this platform's definition
-		def, err := platformState.Marshal(ctx, llb.Platform(platformSpec))
+		indexData, err := solvePlatformOCI(ctx, c, outputDir, &platformStates[i], &platformSpec, cache, export, blobsSet)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal platform: %w", err)
-		}
-
-		_, err = c.Solve(ctx, def, solveOpt, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to solve platform: %w", err)
-		}
-
-		// Extract tar and read manifest
-		platformExtractDir := filepath.Join(tempDir, fmt.Sprintf("extract-%d", i))
-		if err := os.MkdirAll(platformExtractDir, 0o755); err != nil {
-			return nil, fmt.Errorf("failed to create extraction directory: %w", err)
-		}
-
-		if err := extractTarToDirectory(platformTarPath, platformExtractDir); err != nil {
-			return nil, fmt.Errorf("failed to extract platform tar: %w", err)
+			if partial == OCIPartialStrict {
+				return nil, nil, fmt.Errorf("failed to solve platform %s: %w", PlatformKey(platformSpec), err)
+			}
+			log.Warnf("Failed to export platform %s to the OCI layout: %v", PlatformKey(platformSpec), err)
+			failed = append(failed, types.PatchPlatform{Platform: platformSpec})
+			continue
 		}
 
 		// Read the platform's index.json and extract manifest
-		manifest, err := extractManifestFromOCI(platformExtractDir, &platformSpec)
+		manifest, err := manifestFromOCIIndex(indexData, &platformSpec)
 		if err != nil {
-			return nil, fmt.Errorf("failed to extract manifest: %w", err)
+			return nil, nil, fmt.Errorf("failed to extract manifest: %w", err)
 		}
 
 		manifests = append(manifests, manifest)
 	}
 
-	return manifests, nil
-}
-
-// copyBlobsToOutput copies all blobs from temporary directory to output directory.
-func copyBlobsToOutput(outputDir, tempDir string, blobsSet map[string]bool) error {
-	// Create output blobs directory
-	outputBlobsDir := filepath.Join(outputDir, "blobs")
-	if err := os.MkdirAll(outputBlobsDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create output blobs directory: %w", err)
-	}
-
-	// Walk through temp directory to find all blobs directories
-	return filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Look for blobs directories
-		if info.IsDir() && info.Name() == "blobs" {
-			return copyBlobs(path, outputBlobsDir, blobsSet)
-		}
-
-		return nil
-	})
+	return manifests, failed, nil
 }
 
 // exportPreservedPlatformsToOutput exports preserved platforms from original image to output directory.
-func exportPreservedPlatformsToOutput(outputDir string, originalRef reference.Named, preservedPlatforms []types.PatchPlatform, blobsSet map[string]bool) ([]map[string]interface{}, error) {
+func exportPreservedPlatformsToOutput(ctx context.Context, outputDir string, originalRef reference.Named, preservedPlatforms []types.PatchPlatform, blobsSet map[string]bool) ([]map[string]interface{}, error) {
 	// Convert reference.Named to name.Reference for go-containerregistry
 	ref, err := name.ParseReference(originalRef.String())
 	if err != nil {
@@ -1531,11 +1614,11 @@ func exportPreservedPlatformsToOutput(outputDir string, originalRef reference.Na
 	}
 
 	// Try local daemon first, then fall back to remote
-	desc, err := TryGetManifestFromLocal(ref)
+	desc, err := TryGetManifestFromLocal(ctx, ref)
 	isLocal := (err == nil)
 	if err != nil {
 		log.Debugf("Failed to get descriptor from local daemon: %v, trying remote registry", err)
-		desc, err = remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		desc, err = utils.RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get remote descriptor: %w", err)
 		}
@@ -1623,9 +1706,7 @@ func exportPreservedPlatformsToOutput(outputDir string, originalRef reference.Na
 		for _, platformSpec := range preservedPlatforms {
 			for i := range manifest.Manifests {
 				mdesc := &manifest.Manifests[i]
-				if mdesc.Platform != nil &&
-					mdesc.Platform.OS == platformSpec.OS &&
-					mdesc.Platform.Architecture == platformSpec.Architecture {
+				if descriptorMatchesPlatform(mdesc, platformSpec.Platform) {
 					var img v1.Image
 
 					// For local images, we need to fetch by digest using the daemon
@@ -1639,10 +1720,14 @@ func exportPreservedPlatformsToOutput(outputDir string, originalRef reference.Na
 						}
 
 						// Try to get from local daemon first
-						platformDesc, err := TryGetManifestFromLocal(platformRef)
+						platformDesc, err := TryGetManifestFromLocal(ctx, platformRef)
 						if err != nil {
 							// Fall back to remote if local fails
-							img, err = remote.Image(platformRef, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+							var remoteDesc *remote.Descriptor
+							remoteDesc, err = utils.RemoteGet(ctx, platformRef, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+							if err == nil {
+								img, err = remoteDesc.Image()
+							}
 							if err != nil {
 								return nil, fmt.Errorf("failed to get image for preserved platform %s/%s: %w", platformSpec.OS, platformSpec.Architecture, err)
 							}
@@ -1693,20 +1778,10 @@ func exportPreservedPlatformsToOutput(outputDir string, originalRef reference.Na
 						}
 					}
 
-					// Create manifest entry for this preserved platform (index level descriptor)
-					manifestEntry := map[string]interface{}{
-						"mediaType": string(mdesc.MediaType),
-						"digest":    mdesc.Digest.String(),
-						"size":      mdesc.Size,
-						"platform": map[string]interface{}{
-							"os":           mdesc.Platform.OS,
-							"architecture": mdesc.Platform.Architecture,
-						},
-					}
-					if mdesc.Platform.Variant != "" {
-						if platform, ok := manifestEntry["platform"].(map[string]interface{}); ok {
-							platform["variant"] = mdesc.Platform.Variant
-						}
+					// The original index entry is copied as is, annotations included
+					manifestEntry, err := descriptorEntry(mdesc)
+					if err != nil {
+						return nil, err
 					}
 					manifests = append(manifests, manifestEntry)
 					break
@@ -1779,14 +1854,38 @@ func exportPreservedPlatformsToOutput(outputDir string, originalRef reference.Na
 	return manifests, nil
 }
 
-// extractManifestFromOCI extracts manifest information from an OCI layout directory.
-func extractManifestFromOCI(ociDir string, platformSpec *specs.Platform) (map[string]interface{}, error) {
-	indexPath := filepath.Join(ociDir, "index.json")
-	indexData, err := os.ReadFile(indexPath)
+// descriptorMatchesPlatform reports whether the index entry desc is for platform,
+// treating arm64 and arm64/v8 as the same and defaulting arm variants.
+func descriptorMatchesPlatform(desc *v1.Descriptor, platform specs.Platform) bool {
+	if desc.Platform == nil || desc.Platform.OS != platform.OS || desc.Platform.Architecture != platform.Architecture {
+		return false
+	}
+	variant := func(arch, v string) string {
+		v = NormalizeArmVariant(arch, v)
+		if arch == arm64 && v == "v8" {
+			return ""
+		}
+		return v
+	}
+	return variant(desc.Platform.Architecture, desc.Platform.Variant) == variant(platform.Architecture, platform.Variant)
+}
+
+// descriptorEntry returns desc as an index.json manifest entry.
+func descriptorEntry(desc *v1.Descriptor) (map[string]interface{}, error) {
+	data, err := json.Marshal(desc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read index.json: %w", err)
+		return nil, fmt.Errorf("failed to marshal descriptor %s: %w", desc.Digest, err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor %s: %w", desc.Digest, err)
 	}
+	return entry, nil
+}
 
+// manifestFromOCIIndex returns the first manifest of an OCI index.json, with its platform
+// set to platformSpec.
+func manifestFromOCIIndex(indexData []byte, platformSpec *specs.Platform) (map[string]interface{}, error) {
 	var index map[string]interface{}
 	if err := json.Unmarshal(indexData, &index); err != nil {
 		return nil, fmt.Errorf("failed to parse index.json: %w", err)
@@ -1847,7 +1946,7 @@ func createFinalOCILayout(outputDir string, allManifests []map[string]interface{
 }
 
 // createPreservedOnlyOCILayout creates an OCI layout from preserved platforms only.
-func createPreservedOnlyOCILayout(outputDir string, results []types.PatchResult, preservedPlatforms []types.PatchPlatform) error {
+func createPreservedOnlyOCILayout(ctx context.Context, outputDir string, results []types.PatchResult, preservedPlatforms []types.PatchPlatform) error {
 	log.Infof("Creating OCI layout from %d preserved platforms only", len(preservedPlatforms))
 
 	// Find the original image reference from results
@@ -1863,116 +1962,13 @@ func createPreservedOnlyOCILayout(outputDir string, results []types.PatchResult,
 		return fmt.Errorf("no original reference found for preserved-only layout")
 	}
 
-	// Use go-containerregistry to get the original manifest and export only needed platforms
-	return exportOriginalImagePlatformsAsOCI(outputDir, originalRef, preservedPlatforms)
-}
-
-// exportOriginalImagePlatformsAsOCI uses go-containerregistry to export specific platforms.
-func exportOriginalImagePlatformsAsOCI(outputDir string, originalRef reference.Named, platforms []types.PatchPlatform) error {
-	log.Infof("Exporting %d platforms from original image %s using go-containerregistry", len(platforms), originalRef.String())
-
-	// Convert reference.Named to name.Reference for go-containerregistry
-	ref, err := name.ParseReference(originalRef.String())
-	if err != nil {
-		return fmt.Errorf("failed to parse reference: %w", err)
-	}
-
-	// Get the remote descriptor
-	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
-	if err != nil {
-		return fmt.Errorf("failed to get remote descriptor: %w", err)
-	}
-
-	// Check if it's a manifest list (multi-platform)
-	if desc.MediaType == v1types.OCIImageIndex || desc.MediaType == v1types.DockerManifestList {
-		// Parse the index
-		idx, err := desc.ImageIndex()
-		if err != nil {
-			return fmt.Errorf("failed to parse image index: %w", err)
-		}
-
-		// Get the index manifest
-		manifest, err := idx.IndexManifest()
-		if err != nil {
-			return fmt.Errorf("failed to get index manifest: %w", err)
-		}
-
-		// Create OCI layout structure
-		if err := os.MkdirAll(filepath.Join(outputDir, "blobs", "sha256"), 0o755); err != nil {
-			return fmt.Errorf("failed to create blobs directory: %w", err)
-		}
-
-		// Create oci-layout file
-		ociLayoutContent := `{"imageLayoutVersion": "1.0.0"}`
-		if err := os.WriteFile(filepath.Join(outputDir, "oci-layout"), []byte(ociLayoutContent), 0o600); err != nil {
-			return fmt.Errorf("failed to write oci-layout file: %w", err)
-		}
-
-		// Filter manifests for the preserved platforms we want
-		var preservedManifests []v1.Descriptor
-		for _, platformSpec := range platforms {
-			for i := range manifest.Manifests {
-				desc := &manifest.Manifests[i]
-				if desc.Platform != nil &&
-					desc.Platform.OS == platformSpec.OS &&
-					desc.Platform.Architecture == platformSpec.Architecture {
-					preservedManifests = append(preservedManifests, *desc)
-					log.Debugf("Including preserved platform %s/%s", desc.Platform.OS, desc.Platform.Architecture)
-					break
-				}
-			}
-		}
-
-		// Create new index with only preserved platforms
-		newIndex := &v1.IndexManifest{
-			SchemaVersion: 2,
-			Manifests:     preservedManifests,
-		}
-
-		// Write the index
-		indexBytes, err := json.MarshalIndent(newIndex, "", "  ")
-		if err != nil {
-			return fmt.Errorf("failed to marshal index: %w", err)
-		}
-
-		if err := os.WriteFile(filepath.Join(outputDir, "index.json"), indexBytes, 0o600); err != nil {
-			return fmt.Errorf("failed to write index.json: %w", err)
-		}
-
-		log.Infof("Successfully created OCI layout with %d preserved platforms", len(preservedManifests))
-		return nil
-	}
-
-	// Single platform image - just create a simple index
-	log.Info("Single platform image - creating simple index")
-	singleIndex := &v1.IndexManifest{
-		SchemaVersion: 2,
-		Manifests: []v1.Descriptor{{
-			MediaType: desc.MediaType,
-			Digest:    desc.Digest,
-			Size:      desc.Size,
-		}},
-	}
-
-	// Create directory structure
-	if err := os.MkdirAll(filepath.Join(outputDir, "blobs", "sha256"), 0o755); err != nil {
-		return fmt.Errorf("failed to create blobs directory: %w", err)
-	}
-
-	// Write files
-	ociLayoutContent := `{"imageLayoutVersion": "1.0.0"}`
-	if err := os.WriteFile(filepath.Join(outputDir, "oci-layout"), []byte(ociLayoutContent), 0o600); err != nil {
-		return fmt.Errorf("failed to write oci-layout file: %w", err)
-	}
-
-	indexBytes, err := json.MarshalIndent(singleIndex, "", "  ")
+	// The original manifests and blobs are copied, so nothing is solved
+	manifests, err := exportPreservedPlatformsToOutput(ctx, outputDir, originalRef, preservedPlatforms, make(map[string]bool))
 	if err != nil {
-		return fmt.Errorf("failed to marshal single index: %w", err)
+		return fmt.Errorf("failed to export preserved platforms: %w", err)
 	}
-
-	if err := os.WriteFile(filepath.Join(outputDir, "index.json"), indexBytes, 0o600); err != nil {
-		return fmt.Errorf("failed to write index.json: %w", err)
+	if len(manifests) == 0 {
+		return fmt.Errorf("none of the preserved platforms were found in %s", originalRef)
 	}
-
-	return nil
+	return createFinalOCILayout(outputDir, manifests)
 }
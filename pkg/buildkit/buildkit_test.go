@@ -1,12 +1,15 @@
 package buildkit
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
 	"net"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -14,6 +17,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/distribution/reference"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	v1types "github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/opencontainers/go-digest"
 	ispec "github.com/opencontainers/image-spec/specs-go/v1"
 
@@ -25,10 +37,12 @@ import (
 
 	controlapi "github.com/moby/buildkit/api/services/control"
 	bk_types "github.com/moby/buildkit/api/types"
+	"github.com/moby/buildkit/client/llb"
 	gateway "github.com/moby/buildkit/frontend/gateway/pb"
 	"github.com/moby/buildkit/util/apicaps"
 	caps "github.com/moby/buildkit/util/apicaps/pb"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 )
 
@@ -377,7 +391,7 @@ func TestUpdateImageConfigData(t *testing.T) {
 		expectedData := []byte(`{"config": {"labels": {"com.example.label": "value"}, {"BaseImage": "myimage:latest"}}}`)
 		image := "myimage:latest"
 
-		resultConfig, resultPatched, resultImage, err := updateImageConfigData(ctx, mockClient, configData, image)
+		resultConfig, resultPatched, resultImage, _, err := updateImageConfigData(ctx, mockClient, configData, image, false, llb.ResolveModePreferLocal)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -404,7 +418,7 @@ func TestUpdateImageConfigData(t *testing.T) {
 		configData := []byte(`{"config": {"labels": {"BaseImage": "rockylinux:latest"}}}`)
 		image := "rockylinux:latest"
 
-		resultConfig, _, resultImage, err := updateImageConfigData(ctx, mockClient, configData, image)
+		resultConfig, _, resultImage, _, err := updateImageConfigData(ctx, mockClient, configData, image, false, llb.ResolveModePreferLocal)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -420,6 +434,91 @@ func TestUpdateImageConfigData(t *testing.T) {
 
 		mockClient.AssertExpectations(t)
 	})
+
+	t.Run("Rebase keeps user runtime config", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig",
+			mock.Anything, "debian:12", mock.Anything).
+			Return("debian:12", digest.Digest("sha256:base"), []byte(`{"config": {"Cmd": ["bash"], "Env": ["PATH=/usr/bin:/bin"]}}`), nil)
+
+		configData := []byte(`{"config": {
+			"User": "1001",
+			"Entrypoint": ["/app/server"],
+			"Cmd": ["--port", "8080"],
+			"WorkingDir": "/app",
+			"Env": ["PATH=/usr/bin:/bin", "APP_ENV=prod"],
+			"ExposedPorts": {"8080/tcp": {}},
+			"labels": {"BaseImage": "debian:12"}
+		}}`)
+
+		resultConfig, resultPatched, resultImage, _, err := updateImageConfigData(ctx, mockClient, configData, "myapp:1", false, llb.ResolveModePreferLocal)
+		require.NoError(t, err)
+		assert.Equal(t, "debian:12", resultImage)
+
+		for name, data := range map[string][]byte{"ConfigData": resultConfig, "PatchedConfigData": resultPatched} {
+			var img ispec.Image
+			require.NoError(t, json.Unmarshal(data, &img), name)
+			assert.Equal(t, "1001", img.Config.User, name)
+			assert.Equal(t, []string{"/app/server"}, img.Config.Entrypoint, name)
+			assert.Equal(t, []string{"--port", "8080"}, img.Config.Cmd, name)
+			assert.Equal(t, "/app", img.Config.WorkingDir, name)
+			assert.Equal(t, []string{"PATH=/usr/bin:/bin", "APP_ENV=prod"}, img.Config.Env, name)
+			assert.Equal(t, map[string]struct{}{"8080/tcp": {}}, img.Config.ExposedPorts, name)
+		}
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestPreserveRuntimeConfig(t *testing.T) {
+	user := []byte(`{"config": {"User": "app", "Entrypoint": ["/entrypoint.sh"]}}`)
+	base := []byte(`{"architecture": "amd64", "config": {"user": "root", "Cmd": ["sh"], "Labels": {"a": "b"}}, "x-custom": 1}`)
+
+	preserved, err := PreserveRuntimeConfig(user, base)
+	require.NoError(t, err)
+	require.NoError(t, VerifyRuntimeConfig(user, preserved))
+
+	var img ispec.Image
+	require.NoError(t, json.Unmarshal(preserved, &img))
+	assert.Equal(t, "app", img.Config.User)
+	assert.Equal(t, []string{"/entrypoint.sh"}, img.Config.Entrypoint)
+	assert.Empty(t, img.Config.Cmd)
+	assert.Equal(t, map[string]string{"a": "b"}, img.Config.Labels)
+	assert.Equal(t, "amd64", img.Architecture)
+	assert.Contains(t, string(preserved), `"x-custom":1`)
+
+	_, err = PreserveRuntimeConfig([]byte(`not json`), base)
+	assert.Error(t, err)
+}
+
+func TestInitializeBuildkitConfigNoRebase(t *testing.T) {
+	ctx := context.Background()
+	configData := []byte(`{"config":{"labels":{"BaseImage":"example.com/app:base"}}}`)
+	platform := &ispec.Platform{OS: "linux", Architecture: "amd64"}
+
+	t.Run("rebase", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:patched", mock.Anything).
+			Return("", digest.Digest(""), configData, nil).Once()
+		mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:base", mock.Anything).
+			Return("", digest.Digest(""), []byte(`{"config":{}}`), nil).Once()
+
+		config, err := InitializeBuildkitConfig(ctx, mockClient, "example.com/app:patched", platform, false, llb.ResolveModePreferLocal)
+		require.NoError(t, err)
+		assert.NotNil(t, config.PatchedConfigData)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("no rebase", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:patched", mock.Anything).
+			Return("", digest.Digest(""), configData, nil).Once()
+
+		config, err := InitializeBuildkitConfig(ctx, mockClient, "example.com/app:patched", platform, true, llb.ResolveModePreferLocal)
+		require.NoError(t, err)
+		assert.Nil(t, config.PatchedConfigData)
+		assert.JSONEq(t, string(configData), string(config.ConfigData), "BaseImage label should be kept")
+		mockClient.AssertExpectations(t)
+	})
 }
 
 func TestMapGoArch(t *testing.T) {
@@ -428,7 +527,12 @@ func TestMapGoArch(t *testing.T) {
 	}{
 		{"amd64", "", "x86_64"},
 		{"386", "", "i386"},
+		{"arm", "v5", "arm"},
+		{"arm", "v6", "arm"},
 		{"arm", "v7", "arm"},
+		{"arm", "6", "arm"},
+		{"arm", "7,softfloat", "arm"},
+		{"arm", "", "arm"},
 		{"arm", "v5eb", "armeb"},
 		{"mips64", "n32", "mipsn32"},
 		{"mips64", "", "mips64"},
@@ -445,6 +549,46 @@ func TestMapGoArch(t *testing.T) {
 	}
 }
 
+func TestNormalizeArmVariant(t *testing.T) {
+	cases := []struct {
+		arch, variant, want string
+	}{
+		{"arm", "v5", "v5"},
+		{"arm", "v6", "v6"},
+		{"arm", "v7", "v7"},
+		{"arm", "5", "v5"},
+		{"arm", "6", "v6"},
+		{"arm", "7", "v7"},
+		{"arm", "6,hardfloat", "v6"},
+		{"arm", "7,softfloat", "v7"},
+		{"arm", "V7", "v7"},
+		{"arm", "", ""},
+		{"arm", "v5eb", "v5eb"},
+		{"arm64", "v8", "v8"},
+		{"amd64", "7", "7"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, NormalizeArmVariant(c.arch, c.variant), "NormalizeArmVariant(%q, %q)", c.arch, c.variant)
+	}
+
+	// Each GOARM spelling of a variant yields that variant's platform key, and the
+	// variants' keys differ even though they share qemu-arm
+	keys := map[string]bool{}
+	for _, v := range []string{"5", "6", "7"} {
+		key := PlatformKey(ispec.Platform{OS: "linux", Architecture: "arm", Variant: NormalizeArmVariant("arm", v)})
+		assert.Equal(t, PlatformKey(ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v" + v}), key)
+		keys[key] = true
+	}
+	assert.Len(t, keys, 3)
+}
+
+func TestBinfmtInterpreter(t *testing.T) {
+	entry := []byte("enabled\ninterpreter /usr/bin/qemu-arm-static\nflags: OCF\noffset 0\nmagic 7f454c46\n")
+	assert.Equal(t, "qemu-arm", binfmtInterpreter(entry))
+	assert.Equal(t, "qemu-armeb", binfmtInterpreter([]byte("enabled\ninterpreter /usr/bin/qemu-armeb\n")))
+	assert.Empty(t, binfmtInterpreter([]byte("enabled\nflags: F\n")))
+}
+
 func TestIsSupportedOsType(t *testing.T) {
 	supported := []string{
 		utils.OSTypeAlpine,
@@ -461,6 +605,8 @@ func TestIsSupportedOsType(t *testing.T) {
 		utils.OSTypeSLES,
 		utils.OSTypeOpenSUSELeap,
 		utils.OSTypeOpenSUSETW,
+		utils.OSTypeWolfi,
+		utils.OSTypeChainguard,
 	}
 	for _, os := range supported {
 		if !isSupportedOsType(os) {
@@ -505,6 +651,8 @@ func (fakeEntry) Info() (fs.FileInfo, error) { return nil, nil }
 func TestQemuAvailable_Mocked(t *testing.T) {
 	platArm := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: "arm64"}}
 	platAmd := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: "amd64"}}
+	platArmV6 := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}}
+	platArmV7 := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}}
 
 	tests := []struct {
 		name     string
@@ -525,6 +673,27 @@ func TestQemuAvailable_Mocked(t *testing.T) {
 			stubPath: func(string) (string, error) { return "", os.ErrNotExist },
 			want:     true,
 		},
+		{
+			name: "arm/v6 uses qemu-arm", plat: platArmV6,
+			stubDir:  func(string) ([]os.DirEntry, error) { return []os.DirEntry{fakeEntry("qemu-arm")}, nil },
+			stubRead: func(string) ([]byte, error) { return []byte("enabled\ninterpreter /usr/bin/qemu-arm\n"), nil },
+			stubPath: func(string) (string, error) { return "", os.ErrNotExist },
+			want:     true,
+		},
+		{
+			name: "arm/v7 uses qemu-arm", plat: platArmV7,
+			stubDir:  func(string) ([]os.DirEntry, error) { return []os.DirEntry{fakeEntry("qemu-arm")}, nil },
+			stubRead: func(string) ([]byte, error) { return []byte("enabled\ninterpreter /usr/bin/qemu-arm-static\n"), nil },
+			stubPath: func(string) (string, error) { return "", os.ErrNotExist },
+			want:     true,
+		},
+		{
+			name: "qemu-armeb does not emulate arm", plat: platArmV7,
+			stubDir:  func(string) ([]os.DirEntry, error) { return []os.DirEntry{fakeEntry("qemu-armeb")}, nil },
+			stubRead: func(string) ([]byte, error) { return []byte("enabled\ninterpreter /usr/bin/qemu-armeb\n"), nil },
+			stubPath: func(string) (string, error) { return "", os.ErrNotExist },
+			want:     runtime.GOOS == goosDarwin || runtime.GOOS == goosWindows,
+		},
 		{
 			name: "lookPath fallback", plat: platArm,
 			stubDir:  func(string) ([]os.DirEntry, error) { return []os.DirEntry{}, nil },
@@ -571,3 +740,698 @@ func TestQemuAvailable_Mocked(t *testing.T) {
 		})
 	}
 }
+
+func TestDiscoverPlatformsFromReference_UnknownPlatform(t *testing.T) {
+	// Serve an image whose config has empty OS/Architecture from an in-memory registry.
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	assert.NoError(t, err)
+
+	imageRef := u.Host + "/test/noplatform:latest"
+	ref, err := name.ParseReference(imageRef)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.Write(ref, empty.Image))
+
+	platforms, err := DiscoverPlatformsFromReference(context.Background(), imageRef)
+	assert.Nil(t, platforms)
+	assert.ErrorIs(t, err, ErrUnknownImagePlatform)
+	assert.Contains(t, err.Error(), imageRef)
+
+	// DiscoverPlatforms should surface the same error rather than a multi-platform mismatch.
+	_, err = DiscoverPlatforms(context.Background(), imageRef, "", "trivy", nil)
+	assert.ErrorIs(t, err, ErrUnknownImagePlatform)
+	assert.NotContains(t, err.Error(), "not multi platform")
+}
+
+// fakeManifestSource stands in for the local Docker daemon.
+type fakeManifestSource struct {
+	raw []byte
+	err error
+}
+
+func (f fakeManifestSource) RawManifest(context.Context, name.Reference) ([]byte, error) {
+	return f.raw, f.err
+}
+
+func TestTryGetManifestFromLocal(t *testing.T) {
+	origLocal := localManifests
+	defer func() { localManifests = origLocal }()
+
+	ref, err := name.ParseReference("localhost/copa/local-only:latest")
+	require.NoError(t, err)
+
+	index := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:` + fmt.Sprintf("%064x", 1) + `","size":10,"platform":{"architecture":"amd64","os":"linux"}},` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:` + fmt.Sprintf("%064x", 2) + `","size":10,"platform":{"architecture":"arm64","os":"linux","variant":"v8"}}]}`)
+	indexDigest, _, err := v1.SHA256(bytes.NewReader(index))
+	require.NoError(t, err)
+
+	t.Run("manifest list", func(t *testing.T) {
+		localManifests = fakeManifestSource{raw: index}
+		desc, err := TryGetManifestFromLocal(context.Background(), ref)
+		require.NoError(t, err)
+		assert.Equal(t, v1types.OCIImageIndex, desc.MediaType)
+		assert.Equal(t, indexDigest, desc.Digest)
+		assert.Equal(t, int64(len(index)), desc.Size)
+		assert.Equal(t, index, desc.Manifest)
+	})
+
+	t.Run("manifest list without media type", func(t *testing.T) {
+		raw := bytes.Replace(index, []byte(`"mediaType":"application/vnd.oci.image.index.v1+json",`), nil, 1)
+		localManifests = fakeManifestSource{raw: raw}
+		desc, err := TryGetManifestFromLocal(context.Background(), ref)
+		require.NoError(t, err)
+		assert.Equal(t, v1types.DockerManifestList, desc.MediaType)
+	})
+
+	t.Run("single-platform image", func(t *testing.T) {
+		localManifests = fakeManifestSource{raw: []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`)}
+		_, err := TryGetManifestFromLocal(context.Background(), ref)
+		assert.ErrorContains(t, err, "single-platform image")
+	})
+
+	t.Run("empty manifest list", func(t *testing.T) {
+		localManifests = fakeManifestSource{raw: []byte(`{"schemaVersion":2,"manifests":[]}`)}
+		_, err := TryGetManifestFromLocal(context.Background(), ref)
+		assert.ErrorContains(t, err, "single-platform image")
+	})
+
+	t.Run("daemon error", func(t *testing.T) {
+		daemonErr := errors.New("failed to get image from local daemon: no such image")
+		localManifests = fakeManifestSource{err: daemonErr}
+		_, err := TryGetManifestFromLocal(context.Background(), ref)
+		assert.ErrorIs(t, err, daemonErr)
+	})
+
+	t.Run("invalid manifest", func(t *testing.T) {
+		localManifests = fakeManifestSource{raw: []byte("not json")}
+		_, err := TryGetManifestFromLocal(context.Background(), ref)
+		assert.ErrorContains(t, err, "failed to parse manifest JSON")
+	})
+
+	t.Run("platforms discovered without a registry", func(t *testing.T) {
+		localManifests = fakeManifestSource{raw: index}
+		platforms, err := DiscoverPlatformsFromReference(context.Background(), ref.String())
+		require.NoError(t, err)
+		var keys []string
+		for _, p := range platforms {
+			keys = append(keys, PlatformKey(p.Platform))
+		}
+		assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, keys)
+	})
+}
+
+func TestDiscoverPlatformsFromDescriptor(t *testing.T) {
+	index := v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     v1types.OCIImageIndex,
+		Manifests: []v1.Descriptor{
+			{MediaType: v1types.OCIManifestSchema1, Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+			{MediaType: v1types.OCIManifestSchema1, Platform: &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+			{MediaType: v1types.OCIManifestSchema1, Platform: &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+			{MediaType: v1types.OCIManifestSchema1, Platform: &v1.Platform{OS: "linux", Architecture: "arm", Variant: "6"}},
+			// attestation manifests are skipped
+			{MediaType: v1types.OCIManifestSchema1, Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}},
+		},
+	}
+	for i := range index.Manifests {
+		index.Manifests[i].Digest = v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%064x", i+1)}
+	}
+	raw, err := json.Marshal(index)
+	require.NoError(t, err)
+	digest, _, err := v1.SHA256(bytes.NewReader(raw))
+	require.NoError(t, err)
+
+	desc := &remote.Descriptor{
+		Descriptor: v1.Descriptor{MediaType: v1types.OCIImageIndex, Size: int64(len(raw)), Digest: digest},
+		Manifest:   raw,
+	}
+
+	platforms, err := DiscoverPlatformsFromDescriptor(desc)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, p := range platforms {
+		keys = append(keys, PlatformKey(p.Platform))
+		assert.Empty(t, p.ReportFile)
+		assert.False(t, p.ShouldPreserve)
+	}
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64", "linux/arm/v7", "linux/arm/v6"}, keys)
+
+	_, err = DiscoverPlatformsFromDescriptor(nil)
+	assert.Error(t, err)
+}
+
+func TestGetPlatformImageReference_RemoteFallback(t *testing.T) {
+	// The index only exists in the registry, so local daemon lookup fails.
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	amd64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	arm64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}}},
+	)
+
+	imageRef := u.Host + "/test/remoteonly:latest"
+	ref, err := name.ParseReference(imageRef)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, idx))
+
+	arm64Digest, err := arm64Img.Digest()
+	require.NoError(t, err)
+
+	got, err := GetPlatformImageReference(context.Background(), imageRef, &ispec.Platform{OS: "linux", Architecture: "arm64"})
+	require.NoError(t, err)
+	assert.Equal(t, ref.Context().Name()+"@"+arm64Digest.String(), got)
+
+	_, err = GetPlatformImageReference(context.Background(), imageRef, &ispec.Platform{OS: "linux", Architecture: "s390x"})
+	assert.ErrorContains(t, err, "platform linux/s390x not found in manifest")
+
+	// Images that cannot be found anywhere are left for BuildKit to resolve.
+	missing := u.Host + "/test/missing:latest"
+	got, err = GetPlatformImageReference(context.Background(), missing, &ispec.Platform{OS: "linux", Architecture: "amd64"})
+	require.NoError(t, err)
+	assert.Equal(t, missing, got)
+}
+
+func TestPlatformKeyChecked(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform ispec.Platform
+		want     string
+		wantErr  string
+	}{
+		{name: "complete", platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, want: "linux/arm/v7"},
+		{name: "os version", platform: ispec.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348"}, want: "windows/amd64@10.0.20348"},
+		{name: "empty", platform: ispec.Platform{}, wantErr: `missing os and architecture in platform "/"`},
+		{name: "missing os", platform: ispec.Platform{Architecture: "amd64"}, wantErr: `missing os in platform "/amd64"`},
+		{name: "missing architecture", platform: ispec.Platform{OS: "linux", Variant: "v8"}, wantErr: `missing architecture in platform "linux//v8"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlatformKeyChecked(tt.platform)
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrMalformedPlatform)
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Empty(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateOCILayoutFromResults_MalformedPlatform(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "layout")
+	err := CreateOCILayoutFromResults(context.Background(), outputDir, nil, []types.PatchPlatform{
+		{Platform: ispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{Platform: ispec.Platform{Architecture: "arm64"}},
+	}, nil, nil, OCIPartialStrict)
+	assert.ErrorIs(t, err, ErrMalformedPlatform)
+
+	// Nothing is written for a rejected layout.
+	_, statErr := os.Stat(outputDir)
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestCanceledContextStopsDiscovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	imageRef := u.Host + "/test/canceled:latest"
+	ref, err := name.ParseReference(imageRef)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, empty.Image))
+
+	_, err = DiscoverPlatformsFromReference(ctx, imageRef)
+	assert.ErrorIs(t, err, context.Canceled)
+
+}
+
+func TestParseOCIPartialMode(t *testing.T) {
+	for _, s := range []string{"strict", "preserve", "omit"} {
+		mode, err := ParseOCIPartialMode(s)
+		require.NoError(t, err)
+		assert.Equal(t, OCIPartialMode(s), mode)
+	}
+	_, err := ParseOCIPartialMode("best-effort")
+	assert.Error(t, err)
+}
+
+func TestHandleFailedPlatforms(t *testing.T) {
+	preserved := []types.PatchPlatform{
+		{Platform: ispec.Platform{OS: "linux", Architecture: "s390x"}, ShouldPreserve: true},
+	}
+	failed := []types.PatchPlatform{
+		{Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	}
+
+	t.Run("no failures", func(t *testing.T) {
+		got, err := handleFailedPlatforms(nil, preserved, OCIPartialStrict)
+		require.NoError(t, err)
+		assert.Equal(t, preserved, got)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := handleFailedPlatforms(failed, preserved, OCIPartialStrict)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "linux/arm/v7")
+	})
+
+	t.Run("omit", func(t *testing.T) {
+		got, err := handleFailedPlatforms(failed, preserved, OCIPartialOmit)
+		require.NoError(t, err)
+		assert.Equal(t, preserved, got)
+	})
+
+	t.Run("preserve", func(t *testing.T) {
+		got, err := handleFailedPlatforms(failed, preserved, OCIPartialPreserve)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, "arm", got[1].Architecture)
+		assert.True(t, got[1].ShouldPreserve)
+		// The caller's failed platforms are not modified.
+		assert.False(t, failed[0].ShouldPreserve)
+	})
+}
+
+func TestUnchangedPlatformKeys(t *testing.T) {
+	state := llb.Scratch()
+	results := []types.PatchResult{
+		{Platform: ispec.Platform{OS: "linux", Architecture: "amd64"}, PatchedState: &state},
+		// Already up to date: no state, but not a failure either
+		{Platform: ispec.Platform{OS: "linux", Architecture: "arm64"}},
+		{Platform: ispec.Platform{OS: "linux", Architecture: "s390x"}, Preserved: true},
+	}
+	assert.Equal(t, map[string]bool{"linux/arm64": true, "linux/s390x": true}, unchangedPlatformKeys(results))
+}
+
+func TestSetupLabelsPreservesRuntimeConfig(t *testing.T) {
+	configData := []byte(`{
+		"architecture": "amd64",
+		"os": "linux",
+		"config": {
+			"User": "1000:1000",
+			"ExposedPorts": {"80/tcp": {}, "443/tcp": {}},
+			"Env": ["PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", "GREETING=a&b<c>"],
+			"Entrypoint": ["/docker-entrypoint.sh"],
+			"Cmd": ["nginx", "-g", "daemon off;"],
+			"WorkingDir": "/usr/share/nginx/html",
+			"Labels": {"maintainer": "NGINX Docker Maintainers"},
+			"StopSignal": "SIGQUIT"
+		},
+		"rootfs": {"type": "layers", "diff_ids": []}
+	}`)
+
+	_, patched, err := setupLabels("nginx:1.27", configData)
+	require.NoError(t, err)
+	require.NoError(t, VerifyRuntimeConfig(configData, patched))
+
+	var img ispec.Image
+	require.NoError(t, json.Unmarshal(patched, &img))
+	assert.Equal(t, []string{"/docker-entrypoint.sh"}, img.Config.Entrypoint)
+	assert.Equal(t, []string{"nginx", "-g", "daemon off;"}, img.Config.Cmd)
+	assert.Equal(t, []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", "GREETING=a&b<c>"}, img.Config.Env)
+	assert.Equal(t, "1000:1000", img.Config.User)
+	assert.Equal(t, "/usr/share/nginx/html", img.Config.WorkingDir)
+	assert.Equal(t, map[string]struct{}{"80/tcp": {}, "443/tcp": {}}, img.Config.ExposedPorts)
+	assert.Equal(t, "nginx:1.27", img.Config.Labels["BaseImage"])
+}
+
+func TestUpdateImageConfigDataBaseImageLabels(t *testing.T) {
+	ctx := context.Background()
+	labelsOf := func(t *testing.T, configData []byte) map[string]string {
+		var img ispec.Image
+		require.NoError(t, json.Unmarshal(configData, &img))
+		return img.Config.Labels
+	}
+
+	t.Run("only OCI base name label is a patched image", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:1", mock.Anything).
+			Return("", digest.Digest("sha256:base"), []byte(`{"config":{}}`), nil).Once()
+
+		configData := []byte(`{"config":{"labels":{"org.opencontainers.image.base.name":"example.com/app:1"}}}`)
+		_, patched, baseImage, _, err := updateImageConfigData(ctx, mockClient, configData, "example.com/app:1-patched", false, llb.ResolveModePreferLocal)
+		require.NoError(t, err)
+		assert.Equal(t, "example.com/app:1", baseImage)
+		require.NotNil(t, patched)
+		assert.NotContains(t, labelsOf(t, patched), "BaseImage", "no conflicting BaseImage label should be added")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("OCI base name label is preferred over BaseImage", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:2", mock.Anything).
+			Return("", digest.Digest("sha256:base"), []byte(`{"config":{}}`), nil).Once()
+
+		configData := []byte(`{"config":{"labels":{"BaseImage":"example.com/app:1","org.opencontainers.image.base.name":"example.com/app:2"}}}`)
+		_, _, baseImage, _, err := updateImageConfigData(ctx, mockClient, configData, "example.com/app:2-patched", false, llb.ResolveModePreferLocal)
+		require.NoError(t, err)
+		assert.Equal(t, "example.com/app:2", baseImage)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("no base labels is a fresh image", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		configData := []byte(`{"config":{"labels":{"maintainer":"me"}}}`)
+		updated, patched, baseImage, _, err := updateImageConfigData(ctx, mockClient, configData, "example.com/app:1", false, llb.ResolveModePreferLocal)
+		require.NoError(t, err)
+		assert.Equal(t, "example.com/app:1", baseImage)
+		assert.Nil(t, patched)
+		assert.Equal(t, "example.com/app:1", labelsOf(t, updated)["BaseImage"])
+		mockClient.AssertNotCalled(t, "ResolveImageConfig")
+	})
+
+	t.Run("unresolvable OCI base name label is replaced", func(t *testing.T) {
+		mockClient := &mocks.MockGWClient{}
+		mockClient.On("ResolveImageConfig", mock.Anything, "example.com/gone:1", mock.Anything).
+			Return("", digest.Digest(""), nil, errors.New("not found")).Once()
+
+		configData := []byte(`{"config":{"labels":{"org.opencontainers.image.base.name":"example.com/gone:1"}}}`)
+		updated, patched, _, _, err := updateImageConfigData(ctx, mockClient, configData, "example.com/app:1", false, llb.ResolveModePreferLocal)
+		require.NoError(t, err)
+		assert.Nil(t, patched)
+		labels := labelsOf(t, updated)
+		assert.Equal(t, "example.com/app:1", labels["org.opencontainers.image.base.name"])
+		assert.NotContains(t, labels, "BaseImage")
+	})
+}
+
+func TestVerifyRuntimeConfig(t *testing.T) {
+	original := []byte(`{"config": {"Entrypoint": ["/entrypoint.sh"], "Cmd": ["serve"], "User": "app", "ExposedPorts": {"8080/tcp": {}}}}`)
+
+	tests := []struct {
+		name    string
+		patched string
+		wantErr string
+	}{
+		{"unchanged with new label", `{"config": {"Entrypoint": ["/entrypoint.sh"], "Cmd": ["serve"], "User": "app", "ExposedPorts": {"8080/tcp": {}}, "Labels": {"BaseImage": "app:1"}}}`, ""},
+		{"dropped entrypoint", `{"config": {"Cmd": ["serve"], "User": "app", "ExposedPorts": {"8080/tcp": {}}}}`, "Entrypoint"},
+		{"changed user", `{"config": {"Entrypoint": ["/entrypoint.sh"], "Cmd": ["serve"], "User": "root", "ExposedPorts": {"8080/tcp": {}}}}`, "User"},
+		{"lost ports", `{"config": {"Entrypoint": ["/entrypoint.sh"], "Cmd": ["serve"], "User": "app"}}`, "ExposedPorts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyRuntimeConfig(original, []byte(tt.patched))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrRuntimeConfigChanged)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSetConfigUser(t *testing.T) {
+	original := []byte(`{"architecture": "amd64", "config": {"Entrypoint": ["/entrypoint.sh"], "user": "root", "Labels": {"a": "b"}}, "x-custom": 1}`)
+
+	patched, err := SetConfigUser(original, "65532:65532")
+	require.NoError(t, err)
+
+	var img ispec.Image
+	require.NoError(t, json.Unmarshal(patched, &img))
+	assert.Equal(t, "65532:65532", img.Config.User)
+	assert.Equal(t, []string{"/entrypoint.sh"}, img.Config.Entrypoint)
+	assert.Equal(t, map[string]string{"a": "b"}, img.Config.Labels)
+	assert.NotContains(t, string(patched), `"user"`)
+	assert.Contains(t, string(patched), `"x-custom":1`)
+
+	// Only the user differs from the original.
+	err = VerifyRuntimeConfig(original, patched)
+	assert.ErrorIs(t, err, ErrRuntimeConfigChanged)
+	assert.ErrorContains(t, err, `User "root" -> "65532:65532"`)
+
+	for _, user := range []string{"", "root;rm", "app:", ":1000", "a b"} {
+		_, err := SetConfigUser(original, user)
+		assert.Error(t, err, user)
+	}
+}
+
+func TestSetConfigLabels(t *testing.T) {
+	original := []byte(`{"architecture": "amd64", "config": {"User": "app", "labels": {"a": "b", "build": "1"}}, "x-custom": 1}`)
+
+	patched, err := SetConfigLabels(original, map[string]string{"build": "42", "ticket": "SEC-7"})
+	require.NoError(t, err)
+
+	var img ispec.Image
+	require.NoError(t, json.Unmarshal(patched, &img))
+	assert.Equal(t, map[string]string{"a": "b", "build": "42", "ticket": "SEC-7"}, img.Config.Labels)
+	assert.NotContains(t, string(patched), `"Labels"`, "labels are merged into the existing spelling")
+	assert.Contains(t, string(patched), `"x-custom":1`)
+	assert.NoError(t, VerifyRuntimeConfig(original, patched))
+
+	patched, err = SetConfigLabels([]byte(`{"config": {}}`), map[string]string{"build": "42"})
+	require.NoError(t, err)
+	var fresh ispec.Image
+	require.NoError(t, json.Unmarshal(patched, &fresh))
+	assert.Equal(t, map[string]string{"build": "42"}, fresh.Config.Labels)
+}
+
+func TestMapResultsByPlatform(t *testing.T) {
+	// Tags carry no platform suffix, so results must be matched on the structured platform.
+	ref, err := reference.ParseNormalizedNamed("docker.io/library/nginx:1.25-patched")
+	require.NoError(t, err)
+
+	amd64 := ispec.Platform{OS: "linux", Architecture: "amd64"}
+	armv7 := ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	armv6 := ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}
+	amd64State := llb.Image("docker.io/library/nginx:1.25")
+	armv7State := llb.Image("docker.io/library/nginx:1.25")
+
+	results := []types.PatchResult{
+		{PatchedRef: ref, Platform: armv7, PatchedState: &armv7State},
+		{PatchedRef: ref, Platform: armv6, Preserved: true},
+		{PatchedRef: ref, Platform: amd64, PatchedState: &amd64State},
+	}
+
+	resultMap := mapResultsByPlatform(results)
+	require.Len(t, resultMap, 2)
+	assert.Same(t, &results[0], resultMap[PlatformKey(armv7)])
+	assert.Same(t, &results[2], resultMap[PlatformKey(amd64)])
+	assert.NotContains(t, resultMap, PlatformKey(armv6))
+}
+
+func TestMapResultsByPlatformSharedSuffix(t *testing.T) {
+	// Both Windows results end in "-amd64", so suffix matching assigned them to the
+	// same platform and dropped the other; the OS version keeps them apart.
+	ltsc2019 := ispec.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5830"}
+	ltsc2022 := ispec.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.2461"}
+	// A tag that happens to end like another platform's suffix was claimed by that platform.
+	armv7 := ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	amd64 := ispec.Platform{OS: "linux", Architecture: "amd64"}
+
+	refs := make(map[string]reference.Named)
+	for _, tag := range []string{"servercore:ltsc2019-patched-amd64", "servercore:ltsc2022-patched-amd64", "app:1.0-arm-v7", "app:1.0-patched"} {
+		ref, err := reference.ParseNormalizedNamed("example.com/" + tag)
+		require.NoError(t, err)
+		refs[tag] = ref
+	}
+	state := llb.Image("example.com/servercore:ltsc2022")
+
+	results := []types.PatchResult{
+		{PatchedRef: refs["servercore:ltsc2022-patched-amd64"], Platform: ltsc2022, PatchedState: &state},
+		{PatchedRef: refs["servercore:ltsc2019-patched-amd64"], Platform: ltsc2019, PatchedState: &state},
+		{PatchedRef: refs["app:1.0-arm-v7"], Platform: amd64, PatchedState: &state},
+		{PatchedRef: refs["app:1.0-patched"], Platform: armv7, PatchedState: &state},
+	}
+
+	resultMap := mapResultsByPlatform(results)
+	require.Len(t, resultMap, 4)
+	assert.Same(t, &results[0], resultMap[PlatformKey(ltsc2022)])
+	assert.Same(t, &results[1], resultMap[PlatformKey(ltsc2019)])
+	assert.Same(t, &results[2], resultMap[PlatformKey(amd64)])
+	assert.Same(t, &results[3], resultMap[PlatformKey(armv7)])
+}
+
+func TestOCIExportAttrs(t *testing.T) {
+	amd64 := ispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := ispec.Platform{OS: "linux", Architecture: "arm64"}
+
+	attrs := ociExportAttrs(nil, amd64)
+	assert.Equal(t, map[string]string{"oci-mediatypes": "true", "buildinfo": "false"}, attrs)
+
+	export := &OCIExportOptions{Compression: map[string]string{PlatformKey(amd64): "zstd"}, ForceCompression: true}
+	attrs = ociExportAttrs(export, amd64)
+	assert.Equal(t, "zstd", attrs["compression"])
+	assert.Equal(t, "true", attrs["force-compression"])
+	assert.NotContains(t, ociExportAttrs(export, arm64), "compression")
+
+	// Matching the source only compresses the patch layers
+	export.ForceCompression = false
+	assert.NotContains(t, ociExportAttrs(export, amd64), "force-compression")
+
+	attrs = ociExportAttrs(&OCIExportOptions{Annotations: map[string]string{"com.example.build": "42"}}, amd64)
+	assert.Equal(t, "42", attrs["annotation.com.example.build"])
+}
+
+func TestDiscoverPlatformsFromReportExtensions(t *testing.T) {
+	const trivyReport = `{"SchemaVersion": 2, "Metadata": {"OS": {"Family": "alpine", "Name": "3.19.1"}, ` +
+		`"ImageConfig": {"architecture": "amd64"}}, "Results": []}`
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux-amd64.json"), []byte(trivyReport), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux-arm64.sarif"), []byte(`{"runs": []}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# reports"), 0o600))
+
+	t.Run("trivy reads the JSON reports", func(t *testing.T) {
+		platforms, err := DiscoverPlatformsFromReport(dir, "trivy", nil)
+		require.NoError(t, err)
+		require.Len(t, platforms, 1)
+		assert.Equal(t, "amd64", platforms[0].Architecture)
+		assert.Equal(t, filepath.Join(dir, "linux-amd64.json"), platforms[0].ReportFile)
+	})
+
+	t.Run("plugin reads the SARIF reports", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("fake scanner plugin requires a POSIX shell")
+		}
+		pluginDir := t.TempDir()
+		plugin := "#!/bin/sh\n" +
+			`echo '{"apiVersion": "v1alpha1", "metadata": {"os": {"type": "alpine", "version": "3.19.1"}, "config": {"arch": "arm64"}}, "updates": []}'` + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "copa-sarif"), []byte(plugin), 0o755))
+		t.Setenv("PATH", pluginDir)
+
+		platforms, err := DiscoverPlatformsFromReport(dir, "sarif", &ReportDiscoveryOptions{Extensions: []string{".sarif"}})
+		require.NoError(t, err)
+		require.Len(t, platforms, 1)
+		assert.Equal(t, "arm64", platforms[0].Architecture)
+		assert.Equal(t, filepath.Join(dir, "linux-arm64.sarif"), platforms[0].ReportFile)
+	})
+}
+
+func TestDiscoverPlatformsFromReportMalformed(t *testing.T) {
+	const trivyReport = `{"SchemaVersion": 2, "Metadata": {"OS": {"Family": "alpine", "Name": "3.19.1"}, ` +
+		`"ImageConfig": {"architecture": "amd64"}}, "Results": []}`
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux-amd64.json"), []byte(trivyReport), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "report-linux-arm64.json"), []byte(`{"SchemaVersion": 2, "Results": [`), 0o600))
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := DiscoverPlatformsFromReport(dir, "trivy", nil)
+		require.Error(t, err)
+	})
+
+	manifest := []types.PatchPlatform{
+		{Platform: ispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{Platform: ispec.Platform{OS: "linux", Architecture: "arm64"}},
+		{Platform: ispec.Platform{OS: "linux", Architecture: "s390x"}},
+	}
+
+	t.Run("skip malformed keeps the platform of the file name", func(t *testing.T) {
+		reports, err := DiscoverPlatformsFromReport(dir, "trivy", &ReportDiscoveryOptions{SkipMalformed: true})
+		require.NoError(t, err)
+		require.Len(t, reports, 2)
+
+		platforms, err := matchReportsToPlatforms("example.com/app:1.0", manifest, reports, ReportPlatformKeyFull)
+		require.NoError(t, err)
+		require.Len(t, platforms, 3)
+		assert.False(t, platforms[0].ShouldPreserve)
+		assert.Equal(t, filepath.Join(dir, "linux-amd64.json"), platforms[0].ReportFile)
+		// The malformed report stays with its platform, so patching it fails with a parse error
+		assert.False(t, platforms[1].ShouldPreserve)
+		assert.Equal(t, filepath.Join(dir, "report-linux-arm64.json"), platforms[1].ReportFile)
+		assert.True(t, platforms[2].ShouldPreserve)
+	})
+
+	t.Run("skip malformed without a platform in the file name", func(t *testing.T) {
+		require.NoError(t, os.Rename(filepath.Join(dir, "report-linux-arm64.json"), filepath.Join(dir, "arm-results.json")))
+
+		reports, err := DiscoverPlatformsFromReport(dir, "trivy", &ReportDiscoveryOptions{SkipMalformed: true})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+
+		platforms, err := matchReportsToPlatforms("example.com/app:1.0", manifest, reports, ReportPlatformKeyFull)
+		require.NoError(t, err)
+		assert.True(t, platforms[1].ShouldPreserve)
+	})
+}
+
+func TestCreateOCILayoutFromResultsCopiesUnchangedPlatforms(t *testing.T) {
+	origLocal := localManifests
+	defer func() { localManifests = origLocal }()
+	localManifests = fakeManifestSource{err: errors.New("no daemon")}
+
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	images := make([]v1.Image, 3)
+	for i := range images {
+		images[i], err = random.Image(64, 2)
+		require.NoError(t, err)
+	}
+	annotations := map[string]string{"org.opencontainers.image.revision": "abc123"}
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: images[0], Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}, Annotations: annotations}},
+		mutate.IndexAddendum{Add: images[1], Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}}},
+		mutate.IndexAddendum{Add: images[2], Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, Annotations: annotations}},
+	)
+	imageRef := u.Host + "/test/app:1.0"
+	ref, err := name.ParseReference(imageRef)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, idx))
+	original, err := idx.IndexManifest()
+	require.NoError(t, err)
+
+	originalRef, err := reference.ParseNormalizedNamed(imageRef)
+	require.NoError(t, err)
+	amd64 := ispec.Platform{OS: "linux", Architecture: "amd64"}
+	armv7 := ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	// amd64 was already up to date and arm/v7 was preserved; neither has a state to solve
+	results := []types.PatchResult{
+		{OriginalRef: originalRef, PatchedRef: originalRef, Platform: amd64},
+		{OriginalRef: originalRef, PatchedRef: originalRef, Platform: armv7, Preserved: true},
+	}
+	platforms := []types.PatchPlatform{
+		{Platform: amd64},
+		{Platform: armv7, ShouldPreserve: true},
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "oci")
+	require.NoError(t, CreateOCILayoutFromResults(context.Background(), outputDir, results, platforms, nil, nil, OCIPartialStrict))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "index.json"))
+	require.NoError(t, err)
+	var layoutIndex v1.IndexManifest
+	require.NoError(t, json.Unmarshal(data, &layoutIndex))
+	// The index entries are the original ones, annotations included, and arm/v6 is
+	// not mistaken for arm/v7
+	assert.ElementsMatch(t, []v1.Descriptor{original.Manifests[0], original.Manifests[2]}, layoutIndex.Manifests)
+
+	for _, img := range []v1.Image{images[0], images[2]} {
+		rawManifest, err := img.RawManifest()
+		require.NoError(t, err)
+		dgst, err := img.Digest()
+		require.NoError(t, err)
+		blob, err := os.ReadFile(filepath.Join(outputDir, "blobs", "sha256", dgst.Hex))
+		require.NoError(t, err)
+		assert.Equal(t, rawManifest, blob)
+
+		layers, err := img.Layers()
+		require.NoError(t, err)
+		for _, layer := range layers {
+			ld, err := layer.Digest()
+			require.NoError(t, err)
+			assert.FileExists(t, filepath.Join(outputDir, "blobs", "sha256", ld.Hex))
+		}
+	}
+}
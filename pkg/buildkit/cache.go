@@ -0,0 +1,93 @@
+package buildkit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+)
+
+const (
+	cacheTypeRegistry = "registry"
+	cacheTypeLocal    = "local"
+)
+
+// CacheOptions holds the BuildKit cache imports and exports applied to patch solves.
+type CacheOptions struct {
+	Imports []client.CacheOptionsEntry
+	Exports []client.CacheOptionsEntry
+}
+
+// ParseCacheOptions parses --cache-from and --cache-to specs such as
+// "type=registry,ref=example.com/cache:latest" or "type=local,dest=/tmp/cache".
+// It returns nil when no cache is configured.
+func ParseCacheOptions(cacheFrom, cacheTo []string) (*CacheOptions, error) {
+	if len(cacheFrom) == 0 && len(cacheTo) == 0 {
+		return nil, nil
+	}
+
+	opts := &CacheOptions{}
+	for _, spec := range cacheFrom {
+		entry, err := parseCacheSpec(spec, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cache-from %q: %w", spec, err)
+		}
+		opts.Imports = append(opts.Imports, entry)
+	}
+	for _, spec := range cacheTo {
+		entry, err := parseCacheSpec(spec, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cache-to %q: %w", spec, err)
+		}
+		opts.Exports = append(opts.Exports, entry)
+	}
+	return opts, nil
+}
+
+// Apply sets the cache imports and exports on a solve. A nil receiver is a no-op.
+func (o *CacheOptions) Apply(solveOpt *client.SolveOpt) {
+	if o == nil {
+		return
+	}
+	solveOpt.CacheImports = append(solveOpt.CacheImports, o.Imports...)
+	solveOpt.CacheExports = append(solveOpt.CacheExports, o.Exports...)
+}
+
+func parseCacheSpec(spec string, export bool) (client.CacheOptionsEntry, error) {
+	attrs := make(map[string]string)
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || key == "" || value == "" {
+			return client.CacheOptionsEntry{}, fmt.Errorf("expected comma-separated key=value pairs, got %q", field)
+		}
+		attrs[key] = value
+	}
+
+	cacheType := attrs["type"]
+	delete(attrs, "type")
+
+	switch cacheType {
+	case cacheTypeRegistry:
+		if attrs["ref"] == "" {
+			return client.CacheOptionsEntry{}, fmt.Errorf("type=%s requires ref", cacheType)
+		}
+	case cacheTypeLocal:
+		dirKey := "src"
+		if export {
+			dirKey = "dest"
+		}
+		if attrs[dirKey] == "" {
+			return client.CacheOptionsEntry{}, fmt.Errorf("type=%s requires %s", cacheType, dirKey)
+		}
+	case "":
+		return client.CacheOptionsEntry{}, fmt.Errorf("missing type")
+	default:
+		return client.CacheOptionsEntry{}, fmt.Errorf("unsupported cache type %q, supported: %s, %s", cacheType, cacheTypeRegistry, cacheTypeLocal)
+	}
+
+	if mode, ok := attrs["mode"]; ok && mode != "min" && mode != "max" {
+		return client.CacheOptionsEntry{}, fmt.Errorf("mode must be min or max, got %q", mode)
+	}
+
+	return client.CacheOptionsEntry{Type: cacheType, Attrs: attrs}, nil
+}
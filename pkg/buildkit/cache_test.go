@@ -0,0 +1,92 @@
+package buildkit
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCacheOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        []string
+		to          []string
+		wantImports []client.CacheOptionsEntry
+		wantExports []client.CacheOptionsEntry
+		wantErr     string
+	}{
+		{
+			name: "no cache configured",
+		},
+		{
+			name: "registry import and export",
+			from: []string{"type=registry,ref=example.com/cache:patch"},
+			to:   []string{"type=registry,ref=example.com/cache:patch,mode=max"},
+			wantImports: []client.CacheOptionsEntry{
+				{Type: "registry", Attrs: map[string]string{"ref": "example.com/cache:patch"}},
+			},
+			wantExports: []client.CacheOptionsEntry{
+				{Type: "registry", Attrs: map[string]string{"ref": "example.com/cache:patch", "mode": "max"}},
+			},
+		},
+		{
+			name: "local import and export",
+			from: []string{"type=local,src=/tmp/cache"},
+			to:   []string{"type=local,dest=/tmp/cache"},
+			wantImports: []client.CacheOptionsEntry{
+				{Type: "local", Attrs: map[string]string{"src": "/tmp/cache"}},
+			},
+			wantExports: []client.CacheOptionsEntry{
+				{Type: "local", Attrs: map[string]string{"dest": "/tmp/cache"}},
+			},
+		},
+		{
+			name:    "missing type",
+			from:    []string{"ref=example.com/cache"},
+			wantErr: "missing type",
+		},
+		{
+			name:    "unsupported type",
+			to:      []string{"type=gha"},
+			wantErr: `unsupported cache type "gha"`,
+		},
+		{
+			name:    "registry without ref",
+			from:    []string{"type=registry"},
+			wantErr: "requires ref",
+		},
+		{
+			name:    "local export without dest",
+			to:      []string{"type=local,src=/tmp/cache"},
+			wantErr: "requires dest",
+		},
+		{
+			name:    "malformed pair",
+			from:    []string{"type=registry,example.com/cache"},
+			wantErr: "key=value",
+		},
+		{
+			name:    "invalid mode",
+			to:      []string{"type=registry,ref=example.com/cache,mode=all"},
+			wantErr: "mode must be min or max",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ParseCacheOptions(tt.from, tt.to)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var solveOpt client.SolveOpt
+			opts.Apply(&solveOpt)
+			assert.Equal(t, tt.wantImports, solveOpt.CacheImports)
+			assert.Equal(t, tt.wantExports, solveOpt.CacheExports)
+		})
+	}
+}
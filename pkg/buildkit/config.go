@@ -0,0 +1,174 @@
+package buildkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ErrRuntimeConfigChanged is returned when rewriting an image config altered how the image runs.
+var ErrRuntimeConfigChanged = errors.New("image runtime configuration was altered")
+
+// VerifyRuntimeConfig checks that the fields controlling how a container starts are
+// identical in the original and patched image configs. Patching only touches labels,
+// history and platform fields, so any difference here is a bug that would silently
+// break the patched image.
+func VerifyRuntimeConfig(original, patched []byte) error {
+	var orig, next specs.Image
+	if err := json.Unmarshal(original, &orig); err != nil {
+		return fmt.Errorf("failed to parse original image config: %w", err)
+	}
+	if err := json.Unmarshal(patched, &next); err != nil {
+		return fmt.Errorf("failed to parse patched image config: %w", err)
+	}
+
+	a, b := orig.Config, next.Config
+	var changed []string
+	if !slices.Equal(a.Entrypoint, b.Entrypoint) {
+		changed = append(changed, fmt.Sprintf("Entrypoint %q -> %q", a.Entrypoint, b.Entrypoint))
+	}
+	if !slices.Equal(a.Cmd, b.Cmd) {
+		changed = append(changed, fmt.Sprintf("Cmd %q -> %q", a.Cmd, b.Cmd))
+	}
+	if !slices.Equal(a.Env, b.Env) {
+		changed = append(changed, fmt.Sprintf("Env %q -> %q", a.Env, b.Env))
+	}
+	if a.User != b.User {
+		changed = append(changed, fmt.Sprintf("User %q -> %q", a.User, b.User))
+	}
+	if a.WorkingDir != b.WorkingDir {
+		changed = append(changed, fmt.Sprintf("WorkingDir %q -> %q", a.WorkingDir, b.WorkingDir))
+	}
+	if !maps.Equal(a.ExposedPorts, b.ExposedPorts) {
+		changed = append(changed, fmt.Sprintf("ExposedPorts %v -> %v", slices.Sorted(maps.Keys(a.ExposedPorts)), slices.Sorted(maps.Keys(b.ExposedPorts))))
+	}
+
+	if len(changed) > 0 {
+		return fmt.Errorf("%w: %s", ErrRuntimeConfigChanged, strings.Join(changed, "; "))
+	}
+	return nil
+}
+
+// validUserPattern matches a Dockerfile USER value: a user name or UID, optionally
+// followed by a group name or GID.
+var validUserPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]*)?$`)
+
+// ValidateUser checks that user is a valid image config user, e.g. "nonroot",
+// "1000" or "1000:1000".
+func ValidateUser(user string) error {
+	if !validUserPattern.MatchString(user) {
+		return fmt.Errorf("invalid user %q: expected <user>[:<group>] as a name or numeric ID", user)
+	}
+	return nil
+}
+
+// SetConfigUser returns configData with its config User set to user. All other
+// fields, including unknown ones, are kept as is.
+func SetConfigUser(configData []byte, user string) ([]byte, error) {
+	if err := ValidateUser(user); err != nil {
+		return nil, err
+	}
+
+	imageConfig := make(map[string]interface{})
+	if err := json.Unmarshal(configData, &imageConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	configMap, ok := imageConfig["config"].(map[string]interface{})
+	if !ok {
+		configMap = make(map[string]interface{})
+		imageConfig["config"] = configMap
+	}
+	// Keys are matched case-insensitively on decode, so drop any other spelling.
+	for key := range configMap {
+		if strings.EqualFold(key, "User") {
+			delete(configMap, key)
+		}
+	}
+	configMap["User"] = user
+
+	return json.Marshal(imageConfig)
+}
+
+// SetConfigLabels returns configData with labels added to its config labels,
+// replacing labels of the same name. All other fields are kept as is.
+func SetConfigLabels(configData []byte, labels map[string]string) ([]byte, error) {
+	imageConfig := make(map[string]interface{})
+	if err := json.Unmarshal(configData, &imageConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	configMap, ok := imageConfig["config"].(map[string]interface{})
+	if !ok {
+		configMap = make(map[string]interface{})
+		imageConfig["config"] = configMap
+	}
+	// Keys are matched case-insensitively on decode, so merge into the spelling in use
+	labelsKey := "Labels"
+	for key := range configMap {
+		if strings.EqualFold(key, "Labels") {
+			labelsKey = key
+		}
+	}
+	existing, ok := configMap[labelsKey].(map[string]interface{})
+	if !ok {
+		existing = make(map[string]interface{}, len(labels))
+		configMap[labelsKey] = existing
+	}
+	for k, v := range labels {
+		existing[k] = v
+	}
+
+	return json.Marshal(imageConfig)
+}
+
+// runtimeConfigKeys are the image config fields that control how a container starts,
+// as checked by VerifyRuntimeConfig.
+var runtimeConfigKeys = []string{"Entrypoint", "Cmd", "User", "WorkingDir", "Env", "ExposedPorts"}
+
+// PreserveRuntimeConfig returns configData with its Entrypoint, Cmd, User, WorkingDir,
+// Env and ExposedPorts replaced by those of from, dropping any that from does not set.
+// All other fields of configData, including unknown ones, are kept as is.
+func PreserveRuntimeConfig(from, configData []byte) ([]byte, error) {
+	source := make(map[string]interface{})
+	if err := json.Unmarshal(from, &source); err != nil {
+		return nil, fmt.Errorf("failed to parse original image config: %w", err)
+	}
+	imageConfig := make(map[string]interface{})
+	if err := json.Unmarshal(configData, &imageConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %w", err)
+	}
+
+	sourceMap, _ := source["config"].(map[string]interface{})
+	configMap, ok := imageConfig["config"].(map[string]interface{})
+	if !ok {
+		configMap = make(map[string]interface{})
+		imageConfig["config"] = configMap
+	}
+	for _, field := range runtimeConfigKeys {
+		// Keys are matched case-insensitively on decode, so drop any other spelling.
+		for key := range configMap {
+			if strings.EqualFold(key, field) {
+				delete(configMap, key)
+			}
+		}
+		for key, value := range sourceMap {
+			if strings.EqualFold(key, field) {
+				configMap[field] = value
+			}
+		}
+	}
+
+	preserved, err := json.Marshal(imageConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyRuntimeConfig(from, preserved); err != nil {
+		return nil, err
+	}
+	return preserved, nil
+}
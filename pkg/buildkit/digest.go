@@ -0,0 +1,185 @@
+package buildkit
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	dockerClient "github.com/moby/moby/client"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// shortDigestPattern matches a reference pinned to a truncated sha256 digest, such as
+// repo@sha256:abc123. The full form has 64 hex characters.
+var shortDigestPattern = regexp.MustCompile(`^(.+)@sha256:([0-9a-f]{1,63})$`)
+
+// for testing.
+var listLocalRepoDigests = dockerRepoDigests
+
+// maxProbedTags bounds how many tags of a repository are fetched while resolving a short
+// digest against its registry, so a repository with thousands of tags does not turn one
+// lookup into thousands of rate-limited requests.
+var maxProbedTags = 100
+
+// dockerRepoDigests returns the repo@digest references of every image in the local
+// Docker daemon.
+func dockerRepoDigests(ctx context.Context) ([]string, error) {
+	cli, err := dockerClient.New(dockerClient.FromEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+	defer cli.Close()
+
+	images, err := cli.ImageList(ctx, dockerClient.ImageListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local images: %w", err)
+	}
+	var digests []string
+	for _, img := range images.Items {
+		digests = append(digests, img.RepoDigests...)
+	}
+	return digests, nil
+}
+
+// ResolveShortDigest expands an image reference pinned to a truncated digest, as copied
+// from docker images output, to the full digest. The local daemon's images are searched
+// first, then the tags of the repository in its registry. References with a full digest
+// or none at all are returned unchanged, and a prefix matching several digests is an
+// error.
+func ResolveShortDigest(ctx context.Context, image string) (string, error) {
+	m := shortDigestPattern.FindStringSubmatch(image)
+	if m == nil {
+		return image, nil
+	}
+	repoRef, prefix := m[1], "sha256:"+m[2]
+
+	base, err := name.ParseReference(repoRef)
+	if err != nil {
+		return "", fmt.Errorf("error parsing reference %q: %w", image, err)
+	}
+	repo := base.Context()
+
+	matches, err := localDigestMatches(ctx, repo, prefix)
+	if err != nil {
+		log.Debugf("Could not resolve %s against local images: %v", image, err)
+	}
+	if len(matches) == 0 {
+		matches, err = registryDigestMatches(ctx, repo, prefix)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve short digest in %q: %w", image, err)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no image in %s has a digest starting with %s", repoRef, prefix)
+	case 1:
+		resolved := repoRef + "@" + matches[0]
+		log.Infof("Resolved %s to %s", image, resolved)
+		return resolved, nil
+	default:
+		return "", fmt.Errorf("digest %s is ambiguous in %s, it matches %s; use more of the digest",
+			prefix, repoRef, strings.Join(matches, ", "))
+	}
+}
+
+// localDigestMatches returns the digests of local images in repo that start with prefix.
+func localDigestMatches(ctx context.Context, repo name.Repository, prefix string) ([]string, error) {
+	repoDigests, err := listLocalRepoDigests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	for _, rd := range repoDigests {
+		d, err := name.NewDigest(rd)
+		if err != nil || d.Context().Name() != repo.Name() {
+			continue
+		}
+		if strings.HasPrefix(d.DigestStr(), prefix) {
+			found[d.DigestStr()] = true
+		}
+	}
+	return sortedKeys(found), nil
+}
+
+// registryDigestMatches returns the digests of the tagged manifests in repo that start
+// with prefix. At most maxProbedTags tags are fetched, and the first failed fetch is
+// returned as an error rather than read as a missing match.
+func registryDigestMatches(ctx context.Context, repo name.Repository, prefix string) ([]string, error) {
+	auth := remote.WithAuthFromKeychain(authn.DefaultKeychain)
+	release, err := utils.AcquireRegistrySlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := remote.List(repo, auth, remote.WithContext(ctx))
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", repo.Name(), err)
+	}
+
+	probed := tags
+	if len(probed) > maxProbedTags {
+		probed = probed[:maxProbedTags]
+	}
+	found := make(map[string]bool)
+	for _, tag := range probed {
+		desc, err := utils.RemoteGet(ctx, repo.Tag(tag), auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the digest of %s:%s: %w; use the full digest", repo.Name(), tag, err)
+		}
+		if strings.HasPrefix(desc.Digest.String(), prefix) {
+			found[desc.Digest.String()] = true
+		}
+	}
+	if len(found) == 0 && len(probed) < len(tags) {
+		return nil, fmt.Errorf("no digest starting with %s among the first %d of %d tags of %s; use the full digest",
+			prefix, len(probed), len(tags), repo.Name())
+	}
+	return sortedKeys(found), nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PinDigest resolves the tag of image, latest if it has none, to the digest it points
+// to in the registry and returns image pinned to that digest as repo:tag@digest. The
+// tag is kept so the patched image is still named after it. Images that already have
+// a digest are returned unchanged.
+func PinDigest(ctx context.Context, image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("error parsing reference %q: %w", image, err)
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return image, nil
+	}
+	if reference.IsNameOnly(named) {
+		image += ":latest"
+	}
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("error parsing reference %q: %w", image, err)
+	}
+	desc, err := utils.RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the digest of %s: %w", image, err)
+	}
+	pinned := image + "@" + desc.Digest.String()
+	log.Infof("Pinned %s to %s", image, pinned)
+	return pinned, nil
+}
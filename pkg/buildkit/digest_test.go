@@ -0,0 +1,145 @@
+package buildkit
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveShortDigest(t *testing.T) {
+	origList := listLocalRepoDigests
+	defer func() { listLocalRepoDigests = origList }()
+
+	appDigest := "sha256:abc123" + strings.Repeat("0", 58)
+	otherDigest := "sha256:abd456" + strings.Repeat("1", 58)
+	siblingDigest := "sha256:abd789" + strings.Repeat("2", 58)
+	listLocalRepoDigests = func(context.Context) ([]string, error) {
+		return []string{
+			"localhost:5000/app@" + appDigest,
+			"localhost:5000/app@" + otherDigest,
+			"localhost:5000/app@" + siblingDigest,
+			// the same digest in another repository is not a match
+			"localhost:5000/other@sha256:abc999" + strings.Repeat("3", 58),
+		}, nil
+	}
+
+	t.Run("unique prefix", func(t *testing.T) {
+		got, err := ResolveShortDigest(context.Background(), "localhost:5000/app@sha256:abc1")
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:5000/app@"+appDigest, got)
+	})
+
+	t.Run("tag is kept", func(t *testing.T) {
+		got, err := ResolveShortDigest(context.Background(), "localhost:5000/app:1.0@sha256:abc")
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:5000/app:1.0@"+appDigest, got)
+	})
+
+	t.Run("ambiguous prefix", func(t *testing.T) {
+		_, err := ResolveShortDigest(context.Background(), "localhost:5000/app@sha256:abd")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ambiguous")
+		assert.Contains(t, err.Error(), otherDigest)
+		assert.Contains(t, err.Error(), siblingDigest)
+	})
+
+	t.Run("full references are unchanged", func(t *testing.T) {
+		for _, image := range []string{"alpine:3.20", "localhost:5000/app@" + appDigest} {
+			got, err := ResolveShortDigest(context.Background(), image)
+			require.NoError(t, err)
+			assert.Equal(t, image, got)
+		}
+	})
+}
+
+func TestResolveShortDigestRegistryFallback(t *testing.T) {
+	origList := listLocalRepoDigests
+	defer func() { listLocalRepoDigests = origList }()
+	listLocalRepoDigests = func(context.Context) ([]string, error) {
+		return nil, errors.New("docker daemon not running")
+	}
+
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	repo := u.Host + "/test/app"
+	ref, err := name.ParseReference(repo + ":1.0")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	got, err := ResolveShortDigest(context.Background(), repo+"@"+digest.String()[:19])
+	require.NoError(t, err)
+	assert.Equal(t, repo+"@"+digest.String(), got)
+
+	other := "0"
+	if strings.HasPrefix(digest.Hex, "0") {
+		other = "1"
+	}
+	_, err = ResolveShortDigest(context.Background(), repo+"@sha256:"+other)
+	assert.ErrorContains(t, err, "no image in")
+
+	t.Run("tags beyond the probe limit", func(t *testing.T) {
+		origMax := maxProbedTags
+		defer func() { maxProbedTags = origMax }()
+		maxProbedTags = 1
+
+		ref, err := name.ParseReference(repo + ":2.0")
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+
+		_, err = ResolveShortDigest(context.Background(), repo+"@sha256:"+other)
+		assert.ErrorContains(t, err, "among the first 1 of 2 tags")
+		assert.ErrorContains(t, err, "use the full digest")
+	})
+}
+
+func TestPinDigest(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	repo := u.Host + "/test/app"
+	for _, tag := range []string{"1.0", "latest"} {
+		ref, err := name.ParseReference(repo + ":" + tag)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+	}
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	got, err := PinDigest(context.Background(), repo+":1.0")
+	require.NoError(t, err)
+	assert.Equal(t, repo+":1.0@"+digest.String(), got)
+
+	got, err = PinDigest(context.Background(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, repo+":latest@"+digest.String(), got)
+
+	pinned := repo + "@sha256:" + strings.Repeat("0", 64)
+	got, err = PinDigest(context.Background(), pinned)
+	require.NoError(t, err)
+	assert.Equal(t, pinned, got, "references with a digest are not resolved again")
+
+	_, err = PinDigest(context.Background(), repo+":missing")
+	assert.ErrorContains(t, err, "failed to resolve the digest")
+}
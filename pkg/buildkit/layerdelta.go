@@ -0,0 +1,167 @@
+package buildkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// ComputeLayerDelta compares the layers of the original and patched manifests. Layers
+// are matched by digest, so a patch that rebuilt the image from scratch counts all of
+// its layers as added.
+func ComputeLayerDelta(original, patched *specs.Manifest) types.LayerDelta {
+	var delta types.LayerDelta
+	originalLayers := make(map[digest.Digest]bool, len(original.Layers))
+	for _, layer := range original.Layers {
+		originalLayers[layer.Digest] = true
+		delta.SizeDelta -= layer.Size
+	}
+	for _, layer := range patched.Layers {
+		if !originalLayers[layer.Digest] {
+			delta.LayersAdded++
+		}
+		delta.SizeDelta += layer.Size
+	}
+	return delta
+}
+
+// FormatLayerDelta renders delta as e.g. "1 layer, +3.2 MiB".
+func FormatLayerDelta(delta types.LayerDelta) string {
+	noun := "layers"
+	if delta.LayersAdded == 1 {
+		noun = "layer"
+	}
+	return fmt.Sprintf("%d %s, %s", delta.LayersAdded, noun, formatSizeDelta(delta.SizeDelta))
+}
+
+// formatSizeDelta renders a signed byte count in binary units.
+func formatSizeDelta(size int64) string {
+	sign := "+"
+	if size < 0 {
+		sign, size = "-", -size
+	}
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%s%d B", sign, size)
+	}
+	return fmt.Sprintf("%s%.1f %s", sign, value, units[unit])
+}
+
+// ReadLayoutManifest returns the image manifest for platform from the OCI layout in
+// layoutDir, following nested indexes. A layout holding a single manifest without a
+// platform returns that manifest.
+func ReadLayoutManifest(layoutDir string, platform *specs.Platform) (*specs.Manifest, error) {
+	var index specs.Index
+	if err := readLayoutJSON(filepath.Join(layoutDir, "index.json"), &index); err != nil {
+		return nil, err
+	}
+	return findLayoutManifest(layoutDir, &index, platform)
+}
+
+func findLayoutManifest(layoutDir string, index *specs.Index, platform *specs.Platform) (*specs.Manifest, error) {
+	want := PlatformKey(*platform)
+	for _, desc := range index.Manifests {
+		matches := desc.Platform != nil && PlatformKey(*desc.Platform) == want
+		if !matches && (desc.Platform != nil || len(index.Manifests) != 1) {
+			continue
+		}
+
+		path := filepath.Join(layoutDir, "blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded())
+		switch desc.MediaType {
+		case specs.MediaTypeImageIndex, "application/vnd.docker.distribution.manifest.list.v2+json":
+			var nested specs.Index
+			if err := readLayoutJSON(path, &nested); err != nil {
+				return nil, err
+			}
+			return findLayoutManifest(layoutDir, &nested, platform)
+		default:
+			var manifest specs.Manifest
+			if err := readLayoutJSON(path, &manifest); err != nil {
+				return nil, err
+			}
+			return &manifest, nil
+		}
+	}
+	return nil, fmt.Errorf("no manifest for platform %s in OCI layout %s", want, layoutDir)
+}
+
+func readLayoutJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// fetchOriginalManifest returns the registry manifest of image for platform.
+var fetchOriginalManifest = func(ctx context.Context, image string, platform *specs.Platform) (*specs.Manifest, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := utils.RemoteGet(ctx, ref,
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithPlatform(v1.Platform{OS: platform.OS, Architecture: platform.Architecture, Variant: platform.Variant}))
+	if err != nil {
+		return nil, err
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	var manifest specs.Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// SetLayerDeltas compares every patched platform in the OCI layout at layoutDir with
+// the original image and records the result on its patch result. Platforms that can't
+// be compared, e.g. because the original image is not in a registry, are left unset.
+func SetLayerDeltas(ctx context.Context, layoutDir, originalImage string, results []types.PatchResult) {
+	for i := range results {
+		result := &results[i]
+		if result.Preserved || result.PatchedState == nil {
+			continue
+		}
+		patched, err := ReadLayoutManifest(layoutDir, &result.Platform)
+		if err != nil {
+			log.Debugf("Skipping layer delta for %s: %v", PlatformKey(result.Platform), err)
+			continue
+		}
+		original, err := fetchOriginalManifest(ctx, originalImage, &result.Platform)
+		if err != nil {
+			log.Debugf("Skipping layer delta for %s: could not fetch original manifest: %v", PlatformKey(result.Platform), err)
+			continue
+		}
+		delta := ComputeLayerDelta(original, patched)
+		result.LayerDelta = &delta
+		log.Infof("Patch added %s for %s", FormatLayerDelta(delta), PlatformKey(result.Platform))
+	}
+}
@@ -0,0 +1,125 @@
+package buildkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/client/llb"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+// writeLayoutBlob stores v as JSON in the layout's blob store and returns its descriptor.
+func writeLayoutBlob(t *testing.T, dir, mediaType string, v interface{}) specs.Descriptor {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	dgst := digest.FromBytes(data)
+	blobDir := filepath.Join(dir, "blobs", "sha256")
+	require.NoError(t, os.MkdirAll(blobDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(blobDir, dgst.Encoded()), data, 0o600))
+	return specs.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}
+}
+
+// writeTestLayout writes a multi-platform OCI layout with one manifest per platform,
+// each with layers of the given sizes. Layer digests are derived from the size and
+// position, so equal prefixes share layers across layouts.
+func writeTestLayout(t *testing.T, layers map[string][]int64) string {
+	t.Helper()
+	dir := t.TempDir()
+	var manifests []specs.Descriptor
+	for key, sizes := range layers {
+		p, err := platforms.Parse(key)
+		require.NoError(t, err)
+		manifest := specs.Manifest{MediaType: specs.MediaTypeImageManifest}
+		for i, size := range sizes {
+			manifest.Layers = append(manifest.Layers, specs.Descriptor{
+				MediaType: specs.MediaTypeImageLayerGzip,
+				Digest:    digest.FromString(fmt.Sprintf("%s/%d/%d", key, i, size)),
+				Size:      size,
+			})
+		}
+		desc := writeLayoutBlob(t, dir, specs.MediaTypeImageManifest, manifest)
+		desc.Platform = &p
+		manifests = append(manifests, desc)
+	}
+	index := specs.Index{MediaType: specs.MediaTypeImageIndex, Manifests: manifests}
+	indexDesc := writeLayoutBlob(t, dir, specs.MediaTypeImageIndex, index)
+
+	// BuildKit's exporter nests the image index under the layout's index.json.
+	top, err := json.Marshal(specs.Index{Manifests: []specs.Descriptor{indexDesc}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), top, 0o600))
+	return dir
+}
+
+func TestLayerDeltaFromLayouts(t *testing.T) {
+	const mib = 1 << 20
+	before := writeTestLayout(t, map[string][]int64{
+		"linux/amd64": {30 * mib, 5 * mib},
+		"linux/arm64": {28 * mib, 5 * mib},
+	})
+	after := writeTestLayout(t, map[string][]int64{
+		"linux/amd64": {30 * mib, 5 * mib, 3*mib + mib/5},
+		"linux/arm64": {28 * mib, 5 * mib, 2 * mib, 512},
+	})
+
+	amd64 := specs.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := specs.Platform{OS: "linux", Architecture: "arm64"}
+
+	original, err := ReadLayoutManifest(before, &amd64)
+	require.NoError(t, err)
+	patched, err := ReadLayoutManifest(after, &amd64)
+	require.NoError(t, err)
+	delta := ComputeLayerDelta(original, patched)
+	assert.Equal(t, types.LayerDelta{LayersAdded: 1, SizeDelta: 3*mib + mib/5}, delta)
+	assert.Equal(t, "1 layer, +3.2 MiB", FormatLayerDelta(delta))
+
+	_, err = ReadLayoutManifest(before, &specs.Platform{OS: "linux", Architecture: "s390x"})
+	assert.ErrorContains(t, err, "no manifest for platform linux/s390x")
+
+	// Per-platform deltas are recorded on the patch results.
+	origFetch := fetchOriginalManifest
+	defer func() { fetchOriginalManifest = origFetch }()
+	fetchOriginalManifest = func(_ context.Context, image string, platform *specs.Platform) (*specs.Manifest, error) {
+		assert.Equal(t, "docker.io/library/nginx:1.25", image)
+		return ReadLayoutManifest(before, platform)
+	}
+
+	state := llb.Scratch()
+	results := []types.PatchResult{
+		{Platform: amd64, PatchedState: &state},
+		{Platform: arm64, PatchedState: &state},
+		{Platform: specs.Platform{OS: "linux", Architecture: "s390x"}, Preserved: true},
+	}
+	SetLayerDeltas(context.Background(), after, "docker.io/library/nginx:1.25", results)
+	require.NotNil(t, results[0].LayerDelta)
+	assert.Equal(t, 1, results[0].LayerDelta.LayersAdded)
+	require.NotNil(t, results[1].LayerDelta)
+	assert.Equal(t, types.LayerDelta{LayersAdded: 2, SizeDelta: 2*mib + 512}, *results[1].LayerDelta)
+	assert.Nil(t, results[2].LayerDelta)
+}
+
+func TestFormatLayerDelta(t *testing.T) {
+	tests := []struct {
+		delta types.LayerDelta
+		want  string
+	}{
+		{types.LayerDelta{LayersAdded: 0, SizeDelta: 0}, "0 layers, +0 B"},
+		{types.LayerDelta{LayersAdded: 2, SizeDelta: 1536}, "2 layers, +1.5 KiB"},
+		{types.LayerDelta{LayersAdded: 1, SizeDelta: -(5 << 20)}, "1 layer, -5.0 MiB"},
+		{types.LayerDelta{LayersAdded: 3, SizeDelta: 3 << 30}, "3 layers, +3.0 GiB"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatLayerDelta(tt.delta))
+	}
+}
@@ -0,0 +1,142 @@
+package buildkit
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OCILayoutFormat is how --oci-dir writes the OCI layout.
+type OCILayoutFormat string
+
+const (
+	// OCILayoutDir writes the layout as a directory.
+	OCILayoutDir OCILayoutFormat = "dir"
+	// OCILayoutTar writes the layout as a tar archive, which `docker load` and
+	// `skopeo copy oci-archive:` read.
+	OCILayoutTar OCILayoutFormat = "tar"
+	// OCILayoutTarGz writes the layout as a gzip-compressed tar archive.
+	OCILayoutTarGz OCILayoutFormat = "tar.gz"
+)
+
+// ParseOCILayoutFormat validates an --oci-output-format value and checks that path,
+// the --oci-dir value, has an extension that matches it.
+func ParseOCILayoutFormat(s, path string) (OCILayoutFormat, error) {
+	lower := strings.ToLower(path)
+	isTar := strings.HasSuffix(lower, ".tar")
+	isTarGz := strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+
+	switch format := OCILayoutFormat(s); format {
+	case OCILayoutDir:
+		if isTar || isTarGz {
+			return "", fmt.Errorf("--oci-dir %s looks like an archive; use --oci-output-format=tar or tar.gz to write one", path)
+		}
+		return format, nil
+	case OCILayoutTar:
+		if !isTar {
+			return "", fmt.Errorf("--oci-output-format=tar needs an --oci-dir path ending in .tar, got %s", path)
+		}
+		return format, nil
+	case OCILayoutTarGz:
+		if !isTarGz {
+			return "", fmt.Errorf("--oci-output-format=tar.gz needs an --oci-dir path ending in .tar.gz or .tgz, got %s", path)
+		}
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported --oci-output-format %q, supported: %s, %s, %s", s, OCILayoutDir, OCILayoutTar, OCILayoutTarGz)
+}
+
+// MoveOCILayoutToArchive writes the OCI layout in layoutDir to archivePath as a tar,
+// gzip-compressed for OCILayoutTarGz. The layout files are at the root of the archive
+// in a stable order. archivePath is only replaced once the archive is complete.
+//
+// Each file is removed from layoutDir once it is in the archive, so a multi-GB layout
+// and its archive never take up disk space in full at the same time. layoutDir is
+// left incomplete if archiving fails.
+func MoveOCILayoutToArchive(layoutDir, archivePath string, format OCILayoutFormat) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create OCI layout archive: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	var w io.Writer = tmp
+	var gz *gzip.Writer
+	if format == OCILayoutTarGz {
+		gz = gzip.NewWriter(tmp)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+
+	// WalkDir visits entries in lexical order, so the archive is reproducible
+	err = filepath.WalkDir(layoutDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(layoutDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return fmt.Errorf("unexpected file %s in OCI layout", rel)
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive OCI layout: %w", err)
+	}
+
+	if err = tw.Close(); err != nil {
+		return fmt.Errorf("failed to archive OCI layout: %w", err)
+	}
+	if gz != nil {
+		if err = gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress OCI layout archive: %w", err)
+		}
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write OCI layout archive: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), archivePath); err != nil {
+		return fmt.Errorf("failed to write OCI layout archive: %w", err)
+	}
+	return nil
+}
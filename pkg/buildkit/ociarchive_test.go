@@ -0,0 +1,130 @@
+package buildkit
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOCILayoutFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		path    string
+		want    OCILayoutFormat
+		wantErr string
+	}{
+		{format: "dir", path: "./out", want: OCILayoutDir},
+		{format: "tar", path: "out/app.tar", want: OCILayoutTar},
+		{format: "tar.gz", path: "app.tar.gz", want: OCILayoutTarGz},
+		{format: "tar.gz", path: "APP.TGZ", want: OCILayoutTarGz},
+		{format: "dir", path: "app.tar", wantErr: "looks like an archive"},
+		{format: "tar", path: "app.tar.gz", wantErr: "ending in .tar"},
+		{format: "tar.gz", path: "app.tar", wantErr: "ending in .tar.gz or .tgz"},
+		{format: "zip", path: "app.zip", wantErr: "unsupported --oci-output-format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.path, func(t *testing.T) {
+			got, err := ParseOCILayoutFormat(tt.format, tt.path)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoveOCILayoutToArchive(t *testing.T) {
+	writeLayout := func(t *testing.T) string {
+		t.Helper()
+		layoutDir := filepath.Join(t.TempDir(), "layout")
+		p, err := layout.Write(layoutDir, empty.Index)
+		require.NoError(t, err)
+		img, err := random.Image(256, 2)
+		require.NoError(t, err)
+		require.NoError(t, p.AppendImage(img))
+		return layoutDir
+	}
+
+	t.Run("dir", func(t *testing.T) {
+		idx, err := layout.ImageIndexFromPath(writeLayout(t))
+		require.NoError(t, err)
+		assert.NoError(t, validate.Index(idx))
+	})
+
+	for _, format := range []OCILayoutFormat{OCILayoutTar, OCILayoutTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			layoutDir := writeLayout(t)
+			archive := filepath.Join(t.TempDir(), "app."+string(format))
+			require.NoError(t, MoveOCILayoutToArchive(layoutDir, archive, format))
+
+			// The layout's files were moved into the archive
+			err := filepath.WalkDir(layoutDir, func(p string, d fs.DirEntry, err error) error {
+				require.NoError(t, err)
+				assert.True(t, d.IsDir(), "%s left in the layout", p)
+				return nil
+			})
+			require.NoError(t, err)
+
+			extracted := t.TempDir()
+			extractArchive(t, archive, format == OCILayoutTarGz, extracted)
+			idx, err := layout.ImageIndexFromPath(extracted)
+			require.NoError(t, err)
+			assert.NoError(t, validate.Index(idx))
+
+			// Only the archive is left behind
+			entries, err := os.ReadDir(filepath.Dir(archive))
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
+
+// extractArchive extracts the tar at path, gzip-compressed if compressed, to dir.
+func extractArchive(t *testing.T, path string, compressed bool, dir string) {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		require.NoError(t, err)
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		require.True(t, filepath.IsLocal(hdr.Name), hdr.Name)
+		if hdr.Typeflag == tar.TypeDir {
+			require.NoError(t, os.MkdirAll(target, 0o755))
+			continue
+		}
+		require.Equal(t, byte(tar.TypeReg), hdr.Typeflag, hdr.Name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(target), 0o755))
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(target, data, 0o600))
+	}
+}
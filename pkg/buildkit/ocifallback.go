@@ -0,0 +1,135 @@
+package buildkit
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// for testing.
+var (
+	newOCIExportClient = NewClient
+	platformImage      = fetchPlatformImage
+)
+
+// createOCILayoutFromImages assembles the OCI layout without BuildKit, from the
+// per-platform images that patching already loaded or pushed and, for preserved
+// platforms, from the original image. It is used when no BuildKit client can be
+// created for the export.
+func createOCILayoutFromImages(ctx context.Context, outputDir string, results []types.PatchResult, platforms []types.PatchPlatform, partial OCIPartialMode) error {
+	log.Infof("Assembling OCI layout in %s from the platform images", outputDir)
+
+	var originalRef string
+	for _, result := range results {
+		if result.OriginalRef != nil {
+			originalRef = result.OriginalRef.String()
+			break
+		}
+	}
+
+	resultMap := mapResultsByPlatform(results)
+	var patched, preserved, failed []types.PatchPlatform
+	for _, platform := range platforms {
+		switch result, ok := resultMap[PlatformKey(platform.Platform)]; {
+		case platform.ShouldPreserve:
+			preserved = append(preserved, platform)
+		case ok && result.PatchedRef != nil:
+			patched = append(patched, platform)
+		default:
+			failed = append(failed, platform)
+		}
+	}
+	preserved, err := handleFailedPlatforms(failed, preserved, partial)
+	if err != nil {
+		return fmt.Errorf("cannot create OCI layout: %w", err)
+	}
+	if len(preserved) > 0 && originalRef == "" {
+		return fmt.Errorf("no original image reference to preserve platforms from")
+	}
+
+	os.RemoveAll(outputDir)
+	p, err := layout.Write(outputDir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI layout: %w", err)
+	}
+
+	appendImage := func(ref string, platform specs.Platform) error {
+		img, err := platformImage(ctx, ref, platform)
+		if err != nil {
+			return fmt.Errorf("failed to get image %s for platform %s: %w", ref, PlatformKey(platform), err)
+		}
+		if err := p.AppendImage(img, layout.WithPlatform(v1.Platform{
+			OS:           platform.OS,
+			Architecture: platform.Architecture,
+			Variant:      platform.Variant,
+		})); err != nil {
+			return fmt.Errorf("failed to write image %s to OCI layout: %w", ref, err)
+		}
+		return nil
+	}
+
+	for _, platform := range patched {
+		if err := appendImage(resultMap[PlatformKey(platform.Platform)].PatchedRef.String(), platform.Platform); err != nil {
+			return err
+		}
+	}
+	for _, platform := range preserved {
+		if err := appendImage(originalRef, platform.Platform); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Created OCI layout with %d patched and %d preserved platforms", len(patched), len(preserved))
+	return nil
+}
+
+// fetchPlatformImage returns the platform's image of ref, preferring the local Docker
+// daemon, where patched platform images are loaded, over the registry.
+func fetchPlatformImage(ctx context.Context, ref string, platform specs.Platform) (v1.Image, error) {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if img, err := daemon.Image(parsed, daemon.WithContext(ctx)); err == nil {
+		if cfg, err := img.ConfigFile(); err == nil && cfg.OS == platform.OS && cfg.Architecture == platform.Architecture {
+			return img, nil
+		}
+		log.Debugf("Local image %s is not %s, fetching it from the registry", ref, PlatformKey(platform))
+	}
+
+	release, err := utils.AcquireRegistrySlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return remote.Image(parsed,
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithPlatform(v1.Platform{
+			OS:           platform.OS,
+			Architecture: platform.Architecture,
+			Variant:      platform.Variant,
+		}))
+}
+
+// platformsOf wraps platformSpecs as platforms to patch.
+func platformsOf(platformSpecs []specs.Platform) []types.PatchPlatform {
+	platforms := make([]types.PatchPlatform, 0, len(platformSpecs))
+	for _, spec := range platformSpecs {
+		platforms = append(platforms, types.PatchPlatform{Platform: spec})
+	}
+	return platforms
+}
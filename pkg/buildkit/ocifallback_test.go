@@ -0,0 +1,93 @@
+package buildkit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/distribution/reference"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateOCILayoutWithoutBuildKitClient(t *testing.T) {
+	amd64 := ispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := ispec.Platform{OS: "linux", Architecture: "arm64"}
+	s390x := ispec.Platform{OS: "linux", Architecture: "s390x"}
+
+	images := map[string]v1.Image{}
+	for _, ref := range []string{
+		"docker.io/library/app:1.0-patched-amd64",
+		"docker.io/library/app:1.0-patched-arm64",
+		"docker.io/library/app:1.0",
+	} {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		images[ref] = img
+	}
+
+	origClient, origImage := newOCIExportClient, platformImage
+	t.Cleanup(func() { newOCIExportClient, platformImage = origClient, origImage })
+	newOCIExportClient = func(context.Context, Opts) (*client.Client, error) {
+		return nil, errors.New("buildkit unreachable")
+	}
+	platformImage = func(_ context.Context, ref string, _ ispec.Platform) (v1.Image, error) {
+		img, ok := images[ref]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return img, nil
+	}
+
+	state := llb.Scratch()
+	result := func(tag string, p ispec.Platform) types.PatchResult {
+		original, err := reference.ParseNormalizedNamed("app:1.0")
+		require.NoError(t, err)
+		patched, err := reference.ParseNormalizedNamed("app:" + tag)
+		require.NoError(t, err)
+		return types.PatchResult{OriginalRef: original, PatchedRef: patched, PatchedState: &state, Platform: p}
+	}
+	results := []types.PatchResult{
+		result("1.0-patched-amd64", amd64),
+		result("1.0-patched-arm64", arm64),
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "layout")
+	err := CreateOCILayoutFromResults(context.Background(), outputDir, results, []types.PatchPlatform{
+		{Platform: amd64},
+		{Platform: arm64},
+		{Platform: s390x, ShouldPreserve: true},
+	}, nil, nil, OCIPartialOmit)
+	require.NoError(t, err)
+
+	idx, err := layout.ImageIndexFromPath(outputDir)
+	require.NoError(t, err)
+	manifest, err := idx.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 3)
+
+	want := map[string]string{
+		"linux/amd64": "docker.io/library/app:1.0-patched-amd64",
+		"linux/arm64": "docker.io/library/app:1.0-patched-arm64",
+		"linux/s390x": "docker.io/library/app:1.0",
+	}
+	for _, desc := range manifest.Manifests {
+		require.NotNil(t, desc.Platform)
+		key := desc.Platform.OS + "/" + desc.Platform.Architecture
+		wantDigest, err := images[want[key]].Digest()
+		require.NoError(t, err)
+		assert.Equal(t, wantDigest, desc.Digest, key)
+
+		// The layout holds the blobs, not just the index.
+		_, err = idx.Image(desc.Digest)
+		require.NoError(t, err)
+	}
+}
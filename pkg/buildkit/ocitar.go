@@ -0,0 +1,208 @@
+package buildkit
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/session"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// Limits on what extractOCITar accepts from an export. index.json is read into
+// memory, so it gets a much lower limit than the blobs streamed to disk. They are
+// variables so tests can lower them.
+var (
+	maxOCIIndexSize  int64 = 16 << 20  // 16 MiB
+	maxOCIBlobSize   int64 = 64 << 30  // 64 GiB
+	maxOCIExportSize int64 = 256 << 30 // 256 GiB
+)
+
+// ociTarStream receives the tar that BuildKit's OCI exporter writes and extracts its
+// blobs into a layout directory as they arrive, so a multi-GB image is not stored
+// once as a tar and again extracted. The export's index.json is kept in memory for
+// the caller to fix the platform of and combine with other exports.
+type ociTarStream struct {
+	destDir string
+	blobs   map[string]bool // blobs in destDir, by path relative to blobs/
+
+	reader *io.PipeReader
+	done   chan struct{}
+	index  []byte
+	err    error
+}
+
+func newOCITarStream(destDir string, blobs map[string]bool) *ociTarStream {
+	return &ociTarStream{destDir: destDir, blobs: blobs}
+}
+
+// output is the Output of the BuildKit export entry.
+func (s *ociTarStream) output(_ map[string]string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	s.reader = pr
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.index, s.err = extractOCITar(pr, s.destDir, s.blobs)
+		if s.err != nil {
+			// Fail BuildKit's writes instead of leaving them blocked
+			pr.CloseWithError(s.err)
+			return
+		}
+		// Drain the padding after the end of the archive
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	return pw, nil
+}
+
+// wait returns the index.json of the export once it has been extracted.
+func (s *ociTarStream) wait() ([]byte, error) {
+	if s.done == nil {
+		return nil, errors.New("BuildKit did not export an OCI layout")
+	}
+	<-s.done
+	return s.index, s.err
+}
+
+// close stops an extraction that is still running, e.g. after a failed solve.
+func (s *ociTarStream) close() {
+	if s.reader != nil {
+		s.reader.CloseWithError(io.ErrClosedPipe)
+	}
+}
+
+// extractOCITar writes the blobs of the OCI layout tar read from r to destDir/blobs and
+// returns the layout's index.json. Blobs already in blobs are skipped, and the blobs
+// written are added to it. The remaining layout files are not written; the caller
+// creates the layout's oci-layout and index.json.
+//
+// The tar comes from BuildKit, but is still checked as untrusted input: entries that
+// would land outside the layout, links and other special files, and files over the
+// size limits fail the extraction.
+func extractOCITar(r io.Reader, destDir string, blobs map[string]bool) ([]byte, error) {
+	var index []byte
+	var total int64
+	dirs := make(map[string]bool) // blob directories already created
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OCI export: %w", err)
+		}
+
+		name := path.Clean(hdr.Name)
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("OCI export entry %q is outside the layout", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		default:
+			// BuildKit only writes directories and files; a link could point anywhere
+			return nil, fmt.Errorf("OCI export entry %q has unsupported type %q", hdr.Name, hdr.Typeflag)
+		}
+
+		if name == "index.json" {
+			if hdr.Size > maxOCIIndexSize {
+				return nil, fmt.Errorf("index.json of OCI export is %d bytes, more than the limit of %d", hdr.Size, maxOCIIndexSize)
+			}
+			if index, err = io.ReadAll(tr); err != nil {
+				return nil, fmt.Errorf("failed to read index.json from OCI export: %w", err)
+			}
+			continue
+		}
+		rel, ok := strings.CutPrefix(name, "blobs/")
+		if !ok {
+			continue
+		}
+		rel = filepath.FromSlash(rel)
+		if blobs[rel] {
+			continue
+		}
+
+		if hdr.Size > maxOCIBlobSize {
+			return nil, fmt.Errorf("OCI export blob %s is %d bytes, more than the limit of %d", rel, hdr.Size, maxOCIBlobSize)
+		}
+		if total += hdr.Size; total > maxOCIExportSize {
+			return nil, fmt.Errorf("OCI export is more than the limit of %d bytes", maxOCIExportSize)
+		}
+		blobPath := filepath.Join(destDir, "blobs", rel)
+		if dir := filepath.Dir(blobPath); !dirs[dir] {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create blob directory: %w", err)
+			}
+			dirs[dir] = true
+		}
+		if err := writeBlob(blobPath, tr); err != nil {
+			return nil, err
+		}
+		if blobs != nil {
+			blobs[rel] = true
+		}
+	}
+	if index == nil {
+		return nil, errors.New("OCI export has no index.json")
+	}
+	return index, nil
+}
+
+// writeBlob writes the content of r to the blob file p, whose directory exists.
+func writeBlob(p string, r io.Reader) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("failed to create blob %s: %w", p, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write blob %s: %w", p, err)
+	}
+	return f.Close()
+}
+
+// solvePlatformOCI solves state for platformSpec with the OCI exporter, streaming its
+// blobs into destDir, and returns the index.json of the export.
+func solvePlatformOCI(ctx context.Context, c *client.Client, destDir string, state *llb.State, platformSpec *specs.Platform, cache *CacheOptions, export *OCIExportOptions, blobs map[string]bool) ([]byte, error) {
+	def, err := state.Marshal(ctx, llb.Platform(*platformSpec))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal LLB state: %w", err)
+	}
+
+	stream := newOCITarStream(destDir, blobs)
+	defer stream.close()
+	solveOpt := client.SolveOpt{
+		// The patch steps are normally cached from the patch build; when they are
+		// not, they run again and need the proxy secrets.
+		Session: []session.Attachable{utils.ProxySecrets(ctx)},
+		Exports: []client.ExportEntry{{
+			Type:   client.ExporterOCI,
+			Attrs:  ociExportAttrs(export, *platformSpec),
+			Output: stream.output,
+		}},
+	}
+	cache.Apply(&solveOpt)
+	if _, err := c.Solve(ctx, def, solveOpt, nil); err != nil {
+		return nil, err
+	}
+
+	index, err := stream.wait()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract OCI export: %w", err)
+	}
+	log.Debugf("Exported %s to OCI layout %s", PlatformKey(*platformSpec), destDir)
+	return index, nil
+}
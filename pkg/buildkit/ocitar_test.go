@@ -0,0 +1,152 @@
+package buildkit
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ociTar builds a tar with the given regular files, in order.
+func ociTar(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "blobs/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	for _, f := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f[0], Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(f[1]))}))
+		_, err := tw.Write([]byte(f[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestOCITarStream(t *testing.T) {
+	dir := t.TempDir()
+	blobs := map[string]bool{}
+	index := `{"schemaVersion":2,"manifests":[]}`
+
+	stream := newOCITarStream(dir, blobs)
+	defer stream.close()
+	w, err := stream.output(nil)
+	require.NoError(t, err)
+	_, err = w.Write(ociTar(t,
+		[2]string{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+		[2]string{"blobs/sha256/aaaa", "layer"},
+		[2]string{"index.json", index},
+	))
+	require.NoError(t, err)
+	// Padding after the end of the archive is accepted
+	_, err = w.Write(make([]byte, 1024))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	got, err := stream.wait()
+	require.NoError(t, err)
+	assert.JSONEq(t, index, string(got))
+
+	data, err := os.ReadFile(filepath.Join(dir, "blobs", "sha256", "aaaa"))
+	require.NoError(t, err)
+	assert.Equal(t, "layer", string(data))
+	assert.Equal(t, map[string]bool{filepath.Join("sha256", "aaaa"): true}, blobs)
+
+	// Only blobs are written; the caller writes the layout's own files
+	_, err = os.Stat(filepath.Join(dir, "index.json"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "oci-layout"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExtractOCITar(t *testing.T) {
+	t.Run("blobs already written are skipped", func(t *testing.T) {
+		dir := t.TempDir()
+		blobs := map[string]bool{filepath.Join("sha256", "aaaa"): true}
+		_, err := extractOCITar(bytes.NewReader(ociTar(t,
+			[2]string{"blobs/sha256/aaaa", "layer"},
+			[2]string{"blobs/sha256/bbbb", "config"},
+			[2]string{"index.json", "{}"},
+		)), dir, blobs)
+		require.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(dir, "blobs", "sha256", "aaaa"))
+		assert.True(t, os.IsNotExist(err))
+		assert.FileExists(t, filepath.Join(dir, "blobs", "sha256", "bbbb"))
+		assert.Len(t, blobs, 2)
+	})
+
+	t.Run("entries outside the layout are rejected", func(t *testing.T) {
+		for _, name := range []string{"../evil", "blobs/../../evil", "/etc/evil"} {
+			dir := t.TempDir()
+			_, err := extractOCITar(bytes.NewReader(ociTar(t,
+				[2]string{name, "x"},
+				[2]string{"index.json", "{}"},
+			)), filepath.Join(dir, "layout"), nil)
+			assert.ErrorContains(t, err, "is outside the layout", name)
+			_, err = os.Stat(filepath.Join(dir, "evil"))
+			assert.True(t, os.IsNotExist(err))
+		}
+	})
+
+	t.Run("links are rejected", func(t *testing.T) {
+		for _, typeflag := range []byte{tar.TypeSymlink, tar.TypeLink} {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "blobs/sha256/aaaa", Typeflag: typeflag, Linkname: "/etc/passwd"}))
+			require.NoError(t, tw.Close())
+
+			dir := t.TempDir()
+			_, err := extractOCITar(&buf, dir, nil)
+			assert.ErrorContains(t, err, "unsupported type")
+			_, err = os.Lstat(filepath.Join(dir, "blobs", "sha256", "aaaa"))
+			assert.True(t, os.IsNotExist(err))
+		}
+	})
+
+	t.Run("size limits", func(t *testing.T) {
+		origIndex, origBlob, origExport := maxOCIIndexSize, maxOCIBlobSize, maxOCIExportSize
+		defer func() { maxOCIIndexSize, maxOCIBlobSize, maxOCIExportSize = origIndex, origBlob, origExport }()
+		maxOCIIndexSize, maxOCIBlobSize, maxOCIExportSize = 8, 8, 12
+
+		_, err := extractOCITar(bytes.NewReader(ociTar(t,
+			[2]string{"index.json", `{"manifests":[]}`},
+		)), t.TempDir(), nil)
+		assert.ErrorContains(t, err, "index.json of OCI export is 16 bytes")
+
+		_, err = extractOCITar(bytes.NewReader(ociTar(t,
+			[2]string{"blobs/sha256/aaaa", "a large layer"},
+		)), t.TempDir(), nil)
+		assert.ErrorContains(t, err, "is 13 bytes, more than the limit of 8")
+
+		_, err = extractOCITar(bytes.NewReader(ociTar(t,
+			[2]string{"blobs/sha256/aaaa", "layer"},
+			[2]string{"blobs/sha256/bbbb", "layer"},
+			[2]string{"blobs/sha256/cccc", "layer"},
+		)), t.TempDir(), nil)
+		assert.ErrorContains(t, err, "more than the limit of 12 bytes")
+	})
+
+	t.Run("missing index", func(t *testing.T) {
+		_, err := extractOCITar(bytes.NewReader(ociTar(t,
+			[2]string{"blobs/sha256/aaaa", "layer"},
+		)), t.TempDir(), nil)
+		assert.ErrorContains(t, err, "no index.json")
+	})
+
+	t.Run("truncated export", func(t *testing.T) {
+		data := ociTar(t, [2]string{"blobs/sha256/aaaa", "layer"})
+		_, err := extractOCITar(bytes.NewReader(data[:600]), t.TempDir(), nil)
+		assert.ErrorContains(t, err, "failed to")
+	})
+}
+
+func TestOCITarStreamWithoutExport(t *testing.T) {
+	stream := newOCITarStream(t.TempDir(), nil)
+	stream.close()
+	_, err := stream.wait()
+	assert.ErrorContains(t, err, "did not export")
+}
@@ -0,0 +1,39 @@
+package buildkit
+
+import (
+	"fmt"
+
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ReportPlatformKeyFormat controls which parts of a platform have to agree for a
+// per-platform report to be matched to a platform of a multi-platform image.
+type ReportPlatformKeyFormat string
+
+const (
+	// ReportPlatformKeyFull matches on the PlatformKey: os/arch, the variant and the
+	// OS version, so linux/arm/v6 and linux/arm/v7 each need their own report.
+	ReportPlatformKeyFull ReportPlatformKeyFormat = "full"
+	// ReportPlatformKeyOSArch matches on os/arch only, for reports that don't record
+	// a variant. A report then applies to every variant of its architecture.
+	ReportPlatformKeyOSArch ReportPlatformKeyFormat = "os-arch"
+)
+
+// ParseReportPlatformKeyFormat validates a --report-platform-key-format value.
+func ParseReportPlatformKeyFormat(s string) (ReportPlatformKeyFormat, error) {
+	switch format := ReportPlatformKeyFormat(s); format {
+	case ReportPlatformKeyFull, ReportPlatformKeyOSArch:
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported --report-platform-key-format %q, supported: %s, %s", s, ReportPlatformKeyFull, ReportPlatformKeyOSArch)
+}
+
+// reportPlatformKey returns the key of pl under format. It fails like
+// PlatformKeyChecked when the OS or architecture is missing.
+func reportPlatformKey(pl specs.Platform, format ReportPlatformKeyFormat) (string, error) {
+	key, err := PlatformKeyChecked(pl)
+	if err != nil || format != ReportPlatformKeyOSArch {
+		return key, err
+	}
+	return pl.OS + "/" + pl.Architecture, nil
+}
@@ -0,0 +1,108 @@
+package buildkit
+
+import (
+	"testing"
+
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+func TestParseReportPlatformKeyFormat(t *testing.T) {
+	for _, s := range []string{"full", "os-arch"} {
+		format, err := ParseReportPlatformKeyFormat(s)
+		require.NoError(t, err)
+		assert.Equal(t, ReportPlatformKeyFormat(s), format)
+	}
+	_, err := ParseReportPlatformKeyFormat("os/arch")
+	assert.ErrorContains(t, err, "unsupported --report-platform-key-format")
+}
+
+func TestReportPlatformKey(t *testing.T) {
+	windows := specs.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.2227"}
+	armv7 := specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+
+	key, err := reportPlatformKey(windows, ReportPlatformKeyFull)
+	require.NoError(t, err)
+	assert.Equal(t, "windows/amd64@10.0.20348.2227", key)
+	key, err = reportPlatformKey(windows, ReportPlatformKeyOSArch)
+	require.NoError(t, err)
+	assert.Equal(t, "windows/amd64", key)
+
+	key, err = reportPlatformKey(armv7, ReportPlatformKeyFull)
+	require.NoError(t, err)
+	assert.Equal(t, "linux/arm/v7", key)
+	key, err = reportPlatformKey(armv7, ReportPlatformKeyOSArch)
+	require.NoError(t, err)
+	assert.Equal(t, "linux/arm", key)
+
+	_, err = reportPlatformKey(specs.Platform{Architecture: "amd64"}, ReportPlatformKeyOSArch)
+	assert.ErrorIs(t, err, ErrMalformedPlatform)
+}
+
+func TestMatchReportsToPlatforms(t *testing.T) {
+	manifest := []types.PatchPlatform{
+		{Platform: specs.Platform{OS: "linux", Architecture: "amd64"}},
+		{Platform: specs.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{Platform: specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	}
+	reportFiles := func(platforms []types.PatchPlatform) map[string]string {
+		files := make(map[string]string, len(platforms))
+		for _, p := range platforms {
+			files[PlatformKey(p.Platform)] = p.ReportFile
+			assert.Equal(t, p.ReportFile == "", p.ShouldPreserve)
+		}
+		return files
+	}
+
+	t.Run("full format needs the variant", func(t *testing.T) {
+		reports := []types.PatchPlatform{
+			{Platform: specs.Platform{OS: "linux", Architecture: "amd64"}, ReportFile: "amd64.json"},
+			{Platform: specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, ReportFile: "arm-v7.json"},
+		}
+		platforms, err := matchReportsToPlatforms("example.com/app:1", manifest, reports, ReportPlatformKeyFull)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"linux/amd64":  "amd64.json",
+			"linux/arm/v6": "",
+			"linux/arm/v7": "arm-v7.json",
+		}, reportFiles(platforms))
+	})
+
+	t.Run("full format does not match a report without variant", func(t *testing.T) {
+		reports := []types.PatchPlatform{
+			{Platform: specs.Platform{OS: "linux", Architecture: "arm"}, ReportFile: "arm.json"},
+		}
+		platforms, err := matchReportsToPlatforms("example.com/app:1", manifest, reports, ReportPlatformKeyFull)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"linux/amd64":  "",
+			"linux/arm/v6": "",
+			"linux/arm/v7": "",
+		}, reportFiles(platforms))
+	})
+
+	t.Run("os-arch format applies a report to every variant", func(t *testing.T) {
+		reports := []types.PatchPlatform{
+			{Platform: specs.Platform{OS: "linux", Architecture: "arm"}, ReportFile: "arm.json"},
+		}
+		platforms, err := matchReportsToPlatforms("example.com/app:1", manifest, reports, ReportPlatformKeyOSArch)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"linux/amd64":  "",
+			"linux/arm/v6": "arm.json",
+			"linux/arm/v7": "arm.json",
+		}, reportFiles(platforms))
+	})
+
+	t.Run("os-arch format rejects reports for two variants", func(t *testing.T) {
+		reports := []types.PatchPlatform{
+			{Platform: specs.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, ReportFile: "arm-v6.json"},
+			{Platform: specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, ReportFile: "arm-v7.json"},
+		}
+		_, err := matchReportsToPlatforms("example.com/app:1", manifest, reports, ReportPlatformKeyOSArch)
+		assert.ErrorContains(t, err, "reports arm-v6.json and arm-v7.json both match platform linux/arm")
+	})
+}
@@ -0,0 +1,88 @@
+package buildkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containerd/platforms"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+// ParsePlatformReportMap parses --platform-report-map entries of the form
+// platform=path, e.g. linux/arm64=scan-arm.json, into report platforms. Each
+// report file has to exist and each platform can only be mapped once.
+func ParsePlatformReportMap(entries []string) ([]types.PatchPlatform, error) {
+	reports := make([]types.PatchPlatform, 0, len(entries))
+	seen := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		platformStr, path, ok := strings.Cut(entry, "=")
+		platformStr, path = strings.TrimSpace(platformStr), strings.TrimSpace(path)
+		if !ok || platformStr == "" || path == "" {
+			return nil, fmt.Errorf("invalid --platform-report-map entry %q, expected platform=path", entry)
+		}
+
+		pl, err := platforms.Parse(platformStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid platform in --platform-report-map entry %q: %w", entry, err)
+		}
+		// Like the platforms discovered from the manifest, linux/arm64/v8 is linux/arm64
+		pl = platforms.Normalize(pl)
+		pl.Variant = NormalizeArmVariant(pl.Architecture, pl.Variant)
+
+		key := PlatformKey(pl)
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("platform %s is mapped to both %s and %s in --platform-report-map", key, other, path)
+		}
+		seen[key] = path
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("report %s for platform %s: %w", path, key, err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("report %s for platform %s is a directory", path, key)
+		}
+
+		reports = append(reports, types.PatchPlatform{Platform: pl, ReportFile: path})
+	}
+	return reports, nil
+}
+
+// DiscoverPlatformsFromReportMap is like DiscoverPlatforms, but takes the report of
+// each platform from reports, as parsed by ParsePlatformReportMap, instead of
+// reading the platform from the reports in a directory.
+func DiscoverPlatformsFromReportMap(ctx context.Context, manifestRef string, reports []types.PatchPlatform) ([]types.PatchPlatform, error) {
+	p, err := DiscoverPlatformsFromReference(ctx, manifestRef)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.New("image is not multi platform")
+	}
+	log.WithField("platforms", p).Debug("Discovered platforms from manifest")
+
+	return applyPlatformReportMap(manifestRef, p, reports)
+}
+
+// applyPlatformReportMap matches reports to manifestPlatforms on the full platform
+// key, failing when a report is mapped to a platform the image doesn't have.
+func applyPlatformReportMap(manifestRef string, manifestPlatforms, reports []types.PatchPlatform) ([]types.PatchPlatform, error) {
+	inManifest := make(map[string]bool, len(manifestPlatforms))
+	available := make([]string, 0, len(manifestPlatforms))
+	for _, pl := range manifestPlatforms {
+		key := PlatformKey(pl.Platform)
+		inManifest[key] = true
+		available = append(available, key)
+	}
+	for _, r := range reports {
+		if key := PlatformKey(r.Platform); !inManifest[key] {
+			return nil, fmt.Errorf("platform %s in --platform-report-map is not in image %s (available: %s)", key, manifestRef, strings.Join(available, ", "))
+		}
+	}
+	return matchReportsToPlatforms(manifestRef, manifestPlatforms, reports, ReportPlatformKeyFull)
+}
@@ -0,0 +1,96 @@
+package buildkit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+func TestParsePlatformReportMap(t *testing.T) {
+	dir := t.TempDir()
+	amd64Report := filepath.Join(dir, "scan-x64.json")
+	armReport := filepath.Join(dir, "scan-arm.json")
+	for _, f := range []string{amd64Report, armReport} {
+		require.NoError(t, os.WriteFile(f, []byte(`{}`), 0o600))
+	}
+
+	reports, err := ParsePlatformReportMap([]string{
+		"linux/amd64=" + amd64Report,
+		"linux/arm64/v8=" + armReport,
+		"linux/arm=" + armReport,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []types.PatchPlatform{
+		{Platform: specs.Platform{OS: "linux", Architecture: "amd64"}, ReportFile: amd64Report},
+		{Platform: specs.Platform{OS: "linux", Architecture: "arm64"}, ReportFile: armReport},
+		{Platform: specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, ReportFile: armReport},
+	}, reports)
+
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr string
+	}{
+		{name: "missing path", entries: []string{"linux/amd64"}, wantErr: "expected platform=path"},
+		{name: "empty platform", entries: []string{"=" + amd64Report}, wantErr: "expected platform=path"},
+		{name: "invalid platform", entries: []string{"linux/amd64/v2/extra=" + amd64Report}, wantErr: "invalid platform"},
+		{name: "missing file", entries: []string{"linux/amd64=" + filepath.Join(dir, "nope.json")}, wantErr: "no such file"},
+		{name: "directory", entries: []string{"linux/amd64=" + dir}, wantErr: "is a directory"},
+		{
+			name:    "platform mapped twice",
+			entries: []string{"linux/arm64=" + armReport, "linux/arm64/v8=" + amd64Report},
+			wantErr: "platform linux/arm64 is mapped to both",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePlatformReportMap(tt.entries)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestApplyPlatformReportMap(t *testing.T) {
+	manifest := []types.PatchPlatform{
+		{Platform: specs.Platform{OS: "linux", Architecture: "amd64"}},
+		{Platform: specs.Platform{OS: "linux", Architecture: "arm64"}},
+		{Platform: specs.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	}
+
+	t.Run("explicit map overrides filename-based matching", func(t *testing.T) {
+		// The file name says arm64, but the map assigns it to amd64
+		dir := t.TempDir()
+		report := filepath.Join(dir, "report-linux-arm64.json")
+		require.NoError(t, os.WriteFile(report, []byte(`{}`), 0o600))
+		reports, err := ParsePlatformReportMap([]string{"linux/amd64=" + report})
+		require.NoError(t, err)
+
+		platforms, err := applyPlatformReportMap("example.com/app:1", manifest, reports)
+		require.NoError(t, err)
+		require.Len(t, platforms, 3)
+		files := make(map[string]string, len(platforms))
+		for _, p := range platforms {
+			files[PlatformKey(p.Platform)] = p.ReportFile
+			assert.Equal(t, p.ReportFile == "", p.ShouldPreserve)
+		}
+		assert.Equal(t, map[string]string{
+			"linux/amd64":  report,
+			"linux/arm64":  "",
+			"linux/arm/v7": "",
+		}, files)
+	})
+
+	t.Run("platform not in manifest", func(t *testing.T) {
+		reports := []types.PatchPlatform{
+			{Platform: specs.Platform{OS: "linux", Architecture: "s390x"}, ReportFile: "s390x.json"},
+		}
+		_, err := applyPlatformReportMap("example.com/app:1", manifest, reports)
+		assert.ErrorContains(t, err, "platform linux/s390x in --platform-report-map is not in image example.com/app:1")
+	})
+}
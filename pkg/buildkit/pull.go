@@ -0,0 +1,59 @@
+package buildkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// PullPolicy controls where BuildKit resolves the image being patched from.
+type PullPolicy string
+
+const (
+	// PullMissing uses the image from BuildKit's local store when it has one and
+	// only pulls it from the registry otherwise.
+	PullMissing PullPolicy = "missing"
+	// PullAlways resolves the image from the registry every time, so a stale local
+	// image with the same tag can't stand in for the image that was scanned.
+	PullAlways PullPolicy = "always"
+)
+
+// ParsePullPolicy validates a --pull value.
+func ParsePullPolicy(s string) (PullPolicy, error) {
+	switch policy := PullPolicy(s); policy {
+	case PullMissing, PullAlways:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unsupported --pull %q, supported: %s, %s", s, PullMissing, PullAlways)
+}
+
+// errLocalImagesDisabled is returned by TryGetManifestFromLocal with --pull=always,
+// so callers fall back to the registry.
+var errLocalImagesDisabled = errors.New("local images are not used with --pull=always")
+
+// ResolveMode returns how BuildKit resolves the image to patch and its base images
+// under p. An empty policy is PullMissing.
+func (p PullPolicy) ResolveMode() llb.ResolveMode {
+	if p == PullAlways {
+		return llb.ResolveModeForcePull
+	}
+	return llb.ResolveModePreferLocal
+}
+
+type pullPolicyKey struct{}
+
+// WithPullPolicy returns a copy of ctx that makes TryGetManifestFromLocal follow
+// policy for the images of one patch run.
+func WithPullPolicy(ctx context.Context, policy PullPolicy) context.Context {
+	return context.WithValue(ctx, pullPolicyKey{}, policy)
+}
+
+// pullPolicyFromContext returns the policy set by WithPullPolicy, PullMissing if none.
+func pullPolicyFromContext(ctx context.Context) PullPolicy {
+	if policy, ok := ctx.Value(pullPolicyKey{}).(PullPolicy); ok {
+		return policy
+	}
+	return PullMissing
+}
@@ -0,0 +1,74 @@
+package buildkit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/opencontainers/go-digest"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/mocks"
+)
+
+func TestParsePullPolicy(t *testing.T) {
+	for _, s := range []string{"missing", "always"} {
+		policy, err := ParsePullPolicy(s)
+		require.NoError(t, err)
+		assert.Equal(t, PullPolicy(s), policy)
+	}
+	_, err := ParsePullPolicy("never")
+	assert.ErrorContains(t, err, "unsupported --pull")
+}
+
+func TestInitializeBuildkitConfigPullPolicy(t *testing.T) {
+	ctx := context.Background()
+	platform := &ispec.Platform{OS: "linux", Architecture: "amd64"}
+
+	tests := []struct {
+		policy PullPolicy
+		want   llb.ResolveMode
+	}{
+		{policy: "", want: llb.ResolveModePreferLocal},
+		{policy: PullMissing, want: llb.ResolveModePreferLocal},
+		{policy: PullAlways, want: llb.ResolveModeForcePull},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			withMode := mock.MatchedBy(func(opt sourceresolver.Opt) bool {
+				return opt.ImageOpt != nil && opt.ImageOpt.ResolveMode == tt.want.String()
+			})
+
+			mockClient := &mocks.MockGWClient{}
+			mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:1", withMode).
+				Return("", digest.Digest(""), []byte(`{"config":{"labels":{"BaseImage":"example.com/app:base"}}}`), nil).Once()
+			mockClient.On("ResolveImageConfig", mock.Anything, "example.com/app:base", withMode).
+				Return("", digest.Digest(""), []byte(`{"config":{}}`), nil)
+
+			_, err := InitializeBuildkitConfig(ctx, mockClient, "example.com/app:1", platform, false, tt.policy.ResolveMode())
+			require.NoError(t, err)
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTryGetManifestFromLocalPullAlways(t *testing.T) {
+	origLocal := localManifests
+	defer func() { localManifests = origLocal }()
+
+	localManifests = fakeManifestSource{raw: []byte(`{"schemaVersion":2}`)}
+	ref, err := name.ParseReference("example.com/app:1")
+	require.NoError(t, err)
+
+	_, err = TryGetManifestFromLocal(WithPullPolicy(context.Background(), PullAlways), ref)
+	assert.ErrorIs(t, err, errLocalImagesDisabled)
+
+	// The policy of one run does not affect another
+	_, err = TryGetManifestFromLocal(context.Background(), ref)
+	assert.NotErrorIs(t, err, errLocalImagesDisabled)
+}
@@ -14,7 +14,6 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/hashicorp/go-multierror"
-	"github.com/project-copacetic/copacetic/pkg/patch"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -133,6 +132,12 @@ func PatchFromConfig(ctx context.Context, configPath string, opts *types.Options
 		reports = buildReportIndex(opts.Report)
 	}
 
+	ctx, closeClient, err := withSharedClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
 	numWorkers := runtime.NumCPU()
 
 	// Initialize a worker pool with a number of workers equal to the number of CPUs.
@@ -231,7 +236,7 @@ func PatchFromConfig(ctx context.Context, configPath string, opts *types.Options
 				jobOpts.Suffix = ""
 
 				// Execute the patch operation.
-				err = patch.Patch(ctx, &jobOpts)
+				err = patchImage(ctx, &jobOpts)
 				mu.Lock()
 				jobResult := patchJobStatus{
 					Name:   spec.Name,
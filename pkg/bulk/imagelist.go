@@ -0,0 +1,179 @@
+package bulk
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/patch"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// patchImage and bkNewClient are function variables that can be overridden for testing purposes.
+var (
+	patchImage  = patch.Patch
+	bkNewClient = buildkit.NewClient
+)
+
+// withSharedClient opens the BuildKit connection that every patch run of a batch
+// reuses. The returned func closes it once the batch is done.
+func withSharedClient(ctx context.Context, opts *types.Options) (context.Context, func(), error) {
+	bkClient, err := bkNewClient(ctx, buildkit.Opts{
+		Addr:       opts.BkAddr,
+		CACertPath: opts.BkCACertPath,
+		CertPath:   opts.BkCertPath,
+		KeyPath:    opts.BkKeyPath,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to BuildKit: %w", err)
+	}
+	return patch.WithSharedClient(ctx, bkClient), func() { bkClient.Close() }, nil
+}
+
+// imageListEntry is one line of an --image-list file: image[,report][,tag].
+type imageListEntry struct {
+	Line   int
+	Image  string
+	Report string
+	Tag    string
+}
+
+// parseImageList reads an --image-list file. Blank lines and lines starting with # are
+// skipped. Relative report paths are resolved against the directory of the list.
+func parseImageList(listPath string) ([]imageListEntry, error) {
+	f, err := os.Open(listPath) // #nosec G304 - listPath is provided by user via CLI flag
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image list %s: %w", listPath, err)
+	}
+	defer f.Close()
+
+	var entries []imageListEntry
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: expected image[,report][,tag], got %q", listPath, lineNo, line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		fields = append(fields, "", "")
+
+		entry := imageListEntry{Line: lineNo, Image: fields[0], Report: fields[1], Tag: fields[2]}
+		if entry.Image == "" {
+			return nil, fmt.Errorf("%s:%d: missing image", listPath, lineNo)
+		}
+		if entry.Report != "" && !filepath.IsAbs(entry.Report) {
+			entry.Report = filepath.Join(filepath.Dir(listPath), entry.Report)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read image list %s: %w", listPath, err)
+	}
+	return entries, nil
+}
+
+// PatchFromImageList patches every image listed in an --image-list file with opts,
+// using the report and patched tag given on each line. A failed image does not stop the
+// others unless opts.FailFast is set; the failures are returned together after the
+// summary, or dropped with opts.IgnoreError.
+func PatchFromImageList(ctx context.Context, listPath string, opts *types.Options) error {
+	entries, err := parseImageList(listPath)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		log.Warnf("No images to patch in %s.", listPath)
+		return nil
+	}
+
+	ctx, closeClient, err := withSharedClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	numWorkers := min(runtime.NumCPU(), len(entries))
+	log.Infof("Starting bulk patch for %d image(s) listed in %s with %d concurrent workers...", len(entries), listPath, numWorkers)
+
+	results := make([]patchJobStatus, len(entries))
+	jobs := make(chan int, len(entries))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var multiErr *multierror.Error
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entry := entries[i]
+				results[i] = patchJobStatus{
+					Name:   fmt.Sprintf("line %d", entry.Line),
+					Source: entry.Image,
+					Target: entry.Tag,
+				}
+				if ctx.Err() != nil {
+					results[i].Status = "Skipped"
+					results[i].Details = "not started after an earlier image failed"
+					continue
+				}
+
+				jobOpts := *opts // Shallow copy of the global options
+				jobOpts.Image = entry.Image
+				jobOpts.Report = entry.Report
+				jobOpts.PatchedTag = entry.Tag
+
+				err := patchImage(ctx, &jobOpts)
+				if err != nil {
+					log.Errorf("Failed to patch %s: %v", entry.Image, err)
+					results[i].Status = "Failed"
+					results[i].Error = err
+					mu.Lock()
+					multiErr = multierror.Append(multiErr, fmt.Errorf("%s: %w", entry.Image, err))
+					mu.Unlock()
+					if opts.FailFast {
+						cancel()
+					}
+					continue
+				}
+				results[i].Status = "Patched"
+			}
+		}()
+	}
+
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	printSummary(results)
+	counts := make(map[string]int)
+	for _, res := range results {
+		counts[res.Status]++
+	}
+	log.Infof("Patched %d of %d image(s): %d failed, %d skipped",
+		counts["Patched"], len(entries), counts["Failed"], counts["Skipped"])
+
+	if opts.IgnoreError {
+		return nil
+	}
+	return multiErr.ErrorOrNil()
+}
@@ -0,0 +1,131 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	buildkitclient "github.com/moby/buildkit/client"
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	bkNewClient = func(ctx context.Context, _ buildkit.Opts) (*buildkitclient.Client, error) {
+		// a path that certainly does not have a BuildKit daemon listening.
+		return buildkitclient.New(ctx, "unix:///tmp/nowhere.sock")
+	}
+}
+
+func writeImageList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "images.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestParseImageList(t *testing.T) {
+	path := writeImageList(t, `# images patched nightly
+docker.io/library/nginx:1.25.3, reports/nginx.json, 1.25.3-patched
+
+alpine:3.18
+python:3.11-slim,,3.11-slim-fixed
+redis:7,/abs/redis.json
+`)
+	entries, err := parseImageList(path)
+	require.NoError(t, err)
+
+	dir := filepath.Dir(path)
+	assert.Equal(t, []imageListEntry{
+		{Line: 2, Image: "docker.io/library/nginx:1.25.3", Report: filepath.Join(dir, "reports/nginx.json"), Tag: "1.25.3-patched"},
+		{Line: 4, Image: "alpine:3.18"},
+		{Line: 5, Image: "python:3.11-slim", Tag: "3.11-slim-fixed"},
+		{Line: 6, Image: "redis:7", Report: "/abs/redis.json"},
+	}, entries)
+
+	_, err = parseImageList(writeImageList(t, "nginx:1.25,report.json,tag,extra\n"))
+	assert.ErrorContains(t, err, ":1: expected image[,report][,tag]")
+
+	_, err = parseImageList(writeImageList(t, "alpine:3.18\n,report.json\n"))
+	assert.ErrorContains(t, err, ":2: missing image")
+
+	_, err = parseImageList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestPatchFromImageList(t *testing.T) {
+	origPatch, origNewClient := patchImage, bkNewClient
+	defer func() { patchImage, bkNewClient = origPatch, origNewClient }()
+
+	var opened int
+	bkNewClient = func(ctx context.Context, bkOpts buildkit.Opts) (*buildkitclient.Client, error) {
+		opened++
+		return origNewClient(ctx, bkOpts)
+	}
+
+	var mu sync.Mutex
+	patched := make(map[string]types.Options)
+	patchImage = func(_ context.Context, opts *types.Options) error {
+		mu.Lock()
+		patched[opts.Image] = *opts
+		mu.Unlock()
+		if opts.Image == "broken:1" {
+			return errors.New("unsupported os")
+		}
+		return nil
+	}
+
+	path := writeImageList(t, "alpine:3.18,alpine.json,3.18-patched\nbroken:1\nnginx:1.25\n")
+	err := PatchFromImageList(context.Background(), path, &types.Options{Push: true})
+
+	// The failure is reported but does not stop the other images.
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken:1: unsupported os")
+	require.Len(t, patched, 3)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "alpine.json"), patched["alpine:3.18"].Report)
+	assert.Equal(t, "3.18-patched", patched["alpine:3.18"].PatchedTag)
+	assert.True(t, patched["nginx:1.25"].Push)
+	assert.Equal(t, 1, opened, "the images should share one BuildKit connection")
+
+	err = PatchFromImageList(context.Background(), path, &types.Options{IgnoreError: true})
+	assert.NoError(t, err)
+}
+
+func TestPatchFromImageListFailFast(t *testing.T) {
+	origPatch := patchImage
+	defer func() { patchImage = origPatch }()
+
+	var mu sync.Mutex
+	var completed []string
+	patchImage = func(ctx context.Context, opts *types.Options) error {
+		if opts.Image == "broken:1" {
+			return errors.New("unsupported os")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+		mu.Lock()
+		completed = append(completed, opts.Image)
+		mu.Unlock()
+		return nil
+	}
+
+	path := writeImageList(t, "broken:1\nalpine:3.18\nnginx:1.25\npython:3.11\n")
+	err := PatchFromImageList(context.Background(), path, &types.Options{FailFast: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported os")
+	assert.Empty(t, completed, "no image should finish patching after the first failure")
+}
+
+func TestPatchFromImageListEmpty(t *testing.T) {
+	path := writeImageList(t, "# nothing to patch\n\n")
+	assert.NoError(t, PatchFromImageList(context.Background(), path, &types.Options{}))
+}
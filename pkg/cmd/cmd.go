@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path"
 	"strings"
 	"syscall"
 	"time"
@@ -13,6 +14,8 @@ import (
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/bulk"
 	"github.com/project-copacetic/copacetic/pkg/patch"
+	"github.com/project-copacetic/copacetic/pkg/pkgmgr"
+	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/utils"
 	log "github.com/sirupsen/logrus"
@@ -36,20 +39,74 @@ type patchArgs struct {
 	timeout             time.Duration
 	scanner             string
 	ignoreError         bool
+	strictReportPlat    bool
+	reportPlatKey       string
+	platformReportMap   []string
+	verifyEmulation     bool
 	format              string
 	output              string
 	bkOpts              buildkit.Opts
 	push                bool
+	load                bool
+	pushTo              []string
+	parallelPush        bool
+	attachAttestations  bool
 	platform            []string
 	loader              string
 	pkgTypes            string
 	libraryPatchLevel   string
 	toolchainPatchLevel string
+	directOnly          bool
+	includeDevDeps      bool
+	cacheFrom           []string
+	cacheTo             []string
+	smokeTest           string
+	prePatchScript      string
+	postPatchScript     string
+	repoSnapshots       []string
+	repoMirrors         []string
+	aptSecurityOnly     bool
+	labels              []string
+	annotations         []string
+	pkgMgrPaths         []string
 	progress            string
 	ociDir              string
+	ociPartial          string
+	ociOutputFormat     string
+	reportExts          []string
 	eolAPIBaseURL       string
 	exitOnEOL           bool
 	configFile          string
+	imageList           string
+	failFast            bool
+	failOnNoPatch       bool
+	updateAll           bool
+	forcePkgManager     string
+	maxDownloads        int
+	maxParallel         int
+	registryConcurrency int
+	sharePatches        bool
+	scan                bool
+	scannerArgs         string
+	confirmFixed        bool
+	severitySource      string
+	minSeverity         string
+	kevCatalog          string
+	kevOnly             bool
+	policy              string
+	compression         string
+	proxySecret         string
+	includeUnfixed      bool
+	patchedUser         string
+	patchedUserChown    []string
+	noRebase            bool
+	pull                string
+	pinDigest           bool
+	changelogOutput     string
+	changelogInImage    bool
+	singlePatchLayer    bool
+	exportDiff          string
+	dumpLLB             string
 }
 
 func NewPatchCmd() *cobra.Command {
@@ -58,10 +115,10 @@ func NewPatchCmd() *cobra.Command {
 		Use:   "patch",
 		Short: "Patch container image(s) with upgrade packages specified by a vulnerability report or by comprehensive update",
 		Example: `copa patch -i images/python:3.7-alpine -r trivy.json -t 3.7-alpine-patched (Single Image Patching)
-copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)`,
+copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)
+copa patch --image-list images.txt --push (Image List Patching)`,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			// Validate library patch level
-			if err := validateLibraryPatchLevel(ua.libraryPatchLevel, ua.pkgTypes); err != nil {
+			if err := validatePatchArgs(&ua); err != nil {
 				return err
 			}
 
@@ -83,49 +140,119 @@ copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)`,
 			defer signal.Stop(forceQuitCh)
 
 			opts := &types.Options{
-				Image:               ua.appImage,
-				Report:              ua.report,
-				PatchedTag:          ua.patchedTag,
-				Suffix:              ua.suffix,
-				WorkingFolder:       ua.workingFolder,
-				Timeout:             ua.timeout,
-				Scanner:             ua.scanner,
-				IgnoreError:         ua.ignoreError,
-				Format:              ua.format,
-				Output:              ua.output,
-				BkAddr:              ua.bkOpts.Addr,
-				BkCACertPath:        ua.bkOpts.CACertPath,
-				BkCertPath:          ua.bkOpts.CertPath,
-				BkKeyPath:           ua.bkOpts.KeyPath,
-				Push:                ua.push,
-				Platforms:           ua.platform,
-				Loader:              ua.loader,
-				PkgTypes:            ua.pkgTypes,
-				LibraryPatchLevel:   ua.libraryPatchLevel,
-				ToolchainPatchLevel: ua.toolchainPatchLevel,
-				Progress:            progressui.DisplayMode(ua.progress),
-				OCIDir:              ua.ociDir,
-				EOLAPIBaseURL:       ua.eolAPIBaseURL,
-				ExitOnEOL:           ua.exitOnEOL,
-				ConfigFile:          ua.configFile,
+				Image:                  ua.appImage,
+				Report:                 ua.report,
+				PatchedTag:             ua.patchedTag,
+				Suffix:                 ua.suffix,
+				WorkingFolder:          ua.workingFolder,
+				Timeout:                ua.timeout,
+				Scanner:                ua.scanner,
+				IgnoreError:            ua.ignoreError,
+				StrictReportPlatform:   ua.strictReportPlat,
+				ReportPlatformKey:      ua.reportPlatKey,
+				PlatformReportMap:      ua.platformReportMap,
+				ReportExtensions:       ua.reportExts,
+				VerifyEmulation:        ua.verifyEmulation,
+				Format:                 ua.format,
+				Output:                 ua.output,
+				BkAddr:                 ua.bkOpts.Addr,
+				BkCACertPath:           ua.bkOpts.CACertPath,
+				BkCertPath:             ua.bkOpts.CertPath,
+				BkKeyPath:              ua.bkOpts.KeyPath,
+				Push:                   ua.push,
+				Load:                   ua.load,
+				PushTo:                 ua.pushTo,
+				ParallelRegistryPush:   ua.parallelPush,
+				AttachAttestations:     ua.attachAttestations,
+				Platforms:              ua.platform,
+				Loader:                 ua.loader,
+				PkgTypes:               ua.pkgTypes,
+				LibraryPatchLevel:      ua.libraryPatchLevel,
+				ToolchainPatchLevel:    ua.toolchainPatchLevel,
+				NodeDirectOnly:         ua.directOnly,
+				NodeIncludeDev:         ua.includeDevDeps,
+				CacheFrom:              ua.cacheFrom,
+				CacheTo:                ua.cacheTo,
+				SmokeTest:              ua.smokeTest,
+				PrePatchScript:         ua.prePatchScript,
+				PostPatchScript:        ua.postPatchScript,
+				Progress:               progressui.DisplayMode(ua.progress),
+				OCIDir:                 ua.ociDir,
+				OCIPartial:             ua.ociPartial,
+				OCIOutputFormat:        ua.ociOutputFormat,
+				EOLAPIBaseURL:          ua.eolAPIBaseURL,
+				ExitOnEOL:              ua.exitOnEOL,
+				ConfigFile:             ua.configFile,
+				FailFast:               ua.failFast,
+				FailOnNoPatch:          ua.failOnNoPatch,
+				UpdateAll:              ua.updateAll,
+				ForcePkgManager:        ua.forcePkgManager,
+				APTSecurityOnly:        ua.aptSecurityOnly,
+				MaxConcurrentDownloads: ua.maxDownloads,
+				MaxParallelPlatforms:   ua.maxParallel,
+				RegistryConcurrency:    ua.registryConcurrency,
+				SharePlatformPatches:   ua.sharePatches,
+				Scan:                   ua.scan,
+				ScannerArgs:            strings.Fields(ua.scannerArgs),
+				ConfirmFixed:           ua.confirmFixed,
+				SeveritySource:         ua.severitySource,
+				MinSeverity:            ua.minSeverity,
+				KEVCatalog:             ua.kevCatalog,
+				KEVOnly:                ua.kevOnly,
+				Policy:                 ua.policy,
+				Compression:            ua.compression,
+				ProxySecret:            ua.proxySecret,
+				IncludeUnfixed:         ua.includeUnfixed,
+				PatchedUser:            ua.patchedUser,
+				PatchedUserChown:       ua.patchedUserChown,
+				NoRebase:               ua.noRebase,
+				Pull:                   ua.pull,
+				PinDigest:              ua.pinDigest,
+				ChangelogOutput:        ua.changelogOutput,
+				ChangelogInImage:       ua.changelogInImage,
+				SinglePatchLayer:       ua.singlePatchLayer,
+				ExportDiff:             ua.exportDiff,
+				DumpLLB:                ua.dumpLLB,
 			}
 
-			if ua.configFile == "" && ua.appImage == "" {
-				return errors.New("either --config or --image must be provided")
+			repoSnapshots, err := pkgmgr.ParseRepoSnapshots(ua.repoSnapshots)
+			if err != nil {
+				return err
+			}
+			opts.RepoSnapshots = repoSnapshots
+			repoMirrors, err := pkgmgr.ParseRepoMirrors(ua.repoMirrors)
+			if err != nil {
+				return err
+			}
+			opts.RepoMirrors = repoMirrors
+			labels, err := utils.ParseKeyValues("--label", ua.labels)
+			if err != nil {
+				return err
+			}
+			opts.Labels = labels
+			annotations, err := utils.ParseKeyValues("--annotation", ua.annotations)
+			if err != nil {
+				return err
+			}
+			opts.Annotations = annotations
+			pkgMgrPaths, err := pkgmgr.ParsePkgMgrPaths(ua.pkgMgrPaths)
+			if err != nil {
+				return err
+			}
+			opts.PkgMgrPaths = pkgMgrPaths
+
+			// patch the images of a list file
+			if ua.imageList != "" {
+				log.Info("Starting in image list patching mode...")
+
+				return bulk.PatchFromImageList(ctx, ua.imageList, opts)
 			}
 
 			// bulk patch
 			if ua.configFile != "" {
-				if ua.appImage != "" || ua.patchedTag != "" {
-					return errors.New("--config cannot be used with --image or --tag")
-				}
-
 				log.Info("Starting in bulk image patching mode...")
 
-				return bulk.PatchFromConfig(context.Background(), ua.configFile, opts)
-			}
-			if ua.appImage == "" {
-				return errors.New("--image is required when not using --config")
+				return bulk.PatchFromConfig(ctx, ua.configFile, opts)
 			}
 			log.Info("Starting in single image patching mode...")
 			return patch.Patch(ctx, opts)
@@ -133,9 +260,18 @@ copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)`,
 	}
 	flags := patchCmd.Flags()
 	flags.StringVar(&ua.configFile, "config", "", "Path to a bulk patch YAML config file (Comprehensive update only). Cannot be used with --image or --tag.")
+	flags.StringVar(&ua.imageList, "image-list", "",
+		"Path to a file listing images to patch, one per line as image[,report][,tag]. Cannot be used with --config, --image or --tag")
+	flags.BoolVar(&ua.failFast, "fail-fast", false, "With --image-list, stop patching after the first image that fails")
 	flags.StringVarP(&ua.appImage, "image", "i", "", "Application image name and tag to patch")
 	flags.StringVarP(&ua.report, "report", "r", "", "Vulnerability report file or directory of reports")
 	flags.StringVarP(&ua.patchedTag, "tag", "t", "", "Tag for the patched image")
+	flags.BoolVar(&ua.updateAll, "update-all", false,
+		"Upgrade every OS package listed in the report to its latest version, not only the vulnerable ones. "+
+			"Requires a Trivy report generated with --list-all-pkgs")
+	flags.StringVar(&ua.forcePkgManager, "force-pkg-manager", "",
+		"Package manager to patch with instead of the one detected for the image's OS: "+strings.Join(pkgmgr.ForcedPackageManagers(), ", ")+
+			". npm skips OS packages and patches only Node.js packages")
 	flags.StringVarP(&ua.suffix, "tag-suffix", "", "patched",
 		"Suffix for the patched image (if no explicit --tag provided)")
 	flags.StringVarP(&ua.workingFolder, "working-folder", "w", "", "Working folder, defaults to system temp folder")
@@ -145,12 +281,95 @@ copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)`,
 	flags.StringVarP(&ua.bkOpts.CertPath, "cert", "", "", "Absolute path to buildkit client certificate")
 	flags.StringVarP(&ua.bkOpts.KeyPath, "key", "", "", "Absolute path to buildkit client key")
 	flags.DurationVar(&ua.timeout, "timeout", 5*time.Minute, "Timeout for the operation, defaults to '5m'")
-	flags.StringVarP(&ua.scanner, "scanner", "s", "trivy", "Scanner used to generate the report, defaults to 'trivy'")
+	flags.StringVarP(&ua.scanner, "scanner", "s", "trivy", "Scanner used to generate the report, defaults to 'trivy'. "+
+		"Supported: "+strings.Join(report.SupportedScanners(), ", ")+", or a copa-<scanner> plugin on PATH")
+	flags.BoolVar(&ua.scan, "scan", false, "Run the scanner against the image to generate a report when no --report is provided (trivy only)")
+	flags.StringVar(&ua.scannerArgs, "scanner-args", "", "Extra whitespace-separated arguments passed to the scanner when --scan is set (e.g. '--ignore-unfixed --severity HIGH,CRITICAL')")
+	flags.BoolVar(&ua.confirmFixed, "confirm-fixed", false,
+		"Re-scan the patched image with the scanner and fail if any vulnerability targeted by the patch is still reported (trivy only). Each platform of a multi-platform image is re-scanned on its own")
+	flags.StringVar(&ua.severitySource, "report-severity-source", report.SeveritySourceHighest,
+		"Severity used for --min-severity when NVD and the distro or advisory vendor disagree: highest, nvd or vendor")
+	flags.StringVar(&ua.minSeverity, "min-severity", "",
+		"Only patch vulnerabilities rated at or above this severity (low, medium, high, critical); updates without a severity are always patched")
+	flags.StringVar(&ua.kevCatalog, "kev-catalog", "",
+		"Known Exploited Vulnerabilities catalog (local file or URL, in CISA's JSON format) used to report known exploited vulnerabilities. "+
+			"Defaults to the CISA feed when --kev-only is set; downloads are cached for 24h")
+	flags.BoolVar(&ua.kevOnly, "kev-only", false, "Only patch vulnerabilities listed in the KEV catalog")
+	flags.StringVar(&ua.compression, "compression", "",
+		"Layer compression of the pushed image or OCI layout: gzip, zstd, estargz or uncompressed. "+
+			"Recompresses every layer; by default the patch layers of a pushed image match the source image's compression")
+	flags.StringVar(&ua.proxySecret, "proxy-secret", "",
+		"Credentials (user:password) for the HTTP_PROXY and HTTPS_PROXY proxies, as a secret: src=<file> or env=<variable>. "+
+			"Added to proxy URLs without credentials, which reach BuildKit only as session secrets and are not stored in the build definition or the image")
+	flags.StringVar(&ua.policy, "policy", "",
+		"YAML or JSON file with allow and deny rules by package name and version range; upgrades the policy does not allow are held and reported as affected")
+	flags.BoolVar(&ua.includeUnfixed, "include-unfixed", false,
+		"Keep vulnerabilities without a fixed version from the report and list them as affected in the VEX output. "+
+			"They are never installed; by default they are dropped when the report is parsed")
+	flags.StringVar(&ua.patchedUser, "patched-user", "",
+		"Set the user the patched image runs as (e.g. 'nonroot' or '65532:65532'). By default the original user is kept")
+	flags.StringSliceVar(&ua.patchedUserChown, "patched-user-chown", nil,
+		"Absolute paths recursively chowned to --patched-user after patching (requires chown in the image; adds a layer)")
+	flags.BoolVar(&ua.noRebase, "no-rebase", false,
+		"Patch the image as a fresh image even if it has a BaseImage label, instead of rebasing the patch onto that base. "+
+			"Patches stack on top of earlier ones, and the existing BaseImage label is kept")
+	flags.StringVar(&ua.pull, "pull", string(buildkit.PullMissing),
+		"Where to get the image to patch: 'missing' uses a local copy in BuildKit or Docker if there is one, "+
+			"'always' resolves it from the registry so the patched image matches the one that was scanned")
+	flags.BoolVar(&ua.pinDigest, "pin-digest", false,
+		"Resolve the tag of --image to the digest it points to in the registry before patching, "+
+			"and patch that exact image. The tag is still used to name the patched image")
+	flags.StringVar(&ua.changelogOutput, "changelog-output", "",
+		"Write a changelog of the updated packages, their old and new versions and the vulnerabilities they fix to this file "+
+			"(e.g. copa-changelog.txt; markdown if the file ends in .md). "+
+			"For multi-platform images each platform gets its own file, with the platform added to the name (e.g. copa-changelog-linux-arm64.txt)")
+	flags.BoolVar(&ua.changelogInImage, "changelog-in-image", false,
+		"Add the changelog to the patched image at /usr/share/copa/changelog")
+	flags.BoolVar(&ua.singlePatchLayer, "single-patch-layer", false,
+		"Keep the original image layers and add all patched files as a single layer on top, so images sharing a base keep sharing its layers")
+	flags.StringVar(&ua.exportDiff, "export-diff", "",
+		"Instead of a patched image, write only the files changed by the updates to this tar file. "+
+			"It can only be applied on top of the exact image that was patched")
+	flags.StringVar(&ua.dumpLLB, "dump-llb", "",
+		"Write the LLB definition of the patched image to this file before it is built, for 'buildctl debug dump-llb'. "+
+			"For multi-platform images the architecture is added to the file name, e.g. llb-arm64.pb")
+	flags.BoolVar(&ua.failOnNoPatch, "fail-on-no-patch", false,
+		"Exit with an error when no packages were patched, for example because the report has only unfixed vulnerabilities")
 	flags.BoolVar(&ua.ignoreError, "ignore-errors", false, "Ignore errors and continue patching (for single-platform: continue with other packages; for multi-platform: continue with other platforms)")
+	flags.BoolVar(&ua.strictReportPlat, "strict-report-platform", false,
+		"Fail instead of skipping a platform when its report in the --report directory records a different architecture")
+	flags.StringVar(&ua.reportPlatKey, "report-platform-key-format", string(buildkit.ReportPlatformKeyFull),
+		"How reports in the --report directory are matched to image platforms: 'full' matches os/arch, variant and OS version; "+
+			"'os-arch' ignores the variant so a report applies to every variant of its architecture")
+	flags.StringSliceVar(&ua.platformReportMap, "platform-report-map", nil,
+		"Patch the platforms of a multi-platform image with these reports instead of discovering them from a --report directory, "+
+			"as platform=path pairs (e.g. linux/amd64=scan-x64.json,linux/arm64=scan-arm.json). Other platforms are preserved")
+	flags.StringSliceVar(&ua.reportExts, "report-extensions", nil,
+		"Extensions of the files read as reports from the --report directory (default .json). "+
+			"The built-in scanners read .json and .jsonl; other extensions, e.g. .sarif, need a copa-<scanner> plugin")
+	flags.BoolVar(&ua.verifyEmulation, "verify-emulation", false,
+		"Before patching, run a command in each platform that needs QEMU emulation to check the emulator works")
 	flags.StringVarP(&ua.format, "format", "f", "openvex", "Output format, defaults to 'openvex'")
 	flags.StringVarP(&ua.output, "output", "o", "", "Output file path")
 	flags.BoolVarP(&ua.push, "push", "p", false, "Push patched image to destination registry")
+	flags.BoolVar(&ua.load, "load", false,
+		"Also load the patched image into the local Docker or Podman daemon when --push is set (images are always loaded when not pushing). "+
+			"For multi-platform images only the host platform is loaded")
+	flags.StringArrayVar(&ua.pushTo, "push-to", nil,
+		"Also push the patched image to this reference when --push is set, e.g. dr.example.com/app; may be repeated. "+
+			"A reference without a tag gets the patched tag. Multi-platform manifest lists are pushed to every destination")
+	flags.BoolVar(&ua.parallelPush, "parallel-registry-push", false,
+		"Copy the platform images of a multi-platform image to the patched image's repository and each --push-to destination in parallel, "+
+			"up to --registry-concurrency at a time. A manifest list is only pushed once all of its platform images were copied")
+	flags.BoolVar(&ua.attachAttestations, "attach-attestations", false,
+		"Attach the VEX document to the pushed patched image as an OCI referrer, also writing it to --output if set. "+
+			"Registries without the referrers API get a referrers tag instead")
 	flags.StringVar(&ua.ociDir, "oci-dir", "", "Create OCI layout at specified directory for multi-platform images (only used when --push is not specified)")
+	flags.StringVar(&ua.ociOutputFormat, "oci-output-format", string(buildkit.OCILayoutDir),
+		"How --oci-dir writes the OCI layout: dir, tar or tar.gz. For tar and tar.gz, --oci-dir is the archive path and must end in .tar, or .tar.gz or .tgz")
+	flags.StringVar(&ua.ociPartial, "oci-partial", "",
+		"How --oci-dir handles platforms that failed to patch or export: strict (fail), preserve (include the original image) or omit. "+
+			"Defaults to omit with --ignore-errors and strict otherwise")
 	flags.StringSliceVar(&ua.platform, "platform", nil,
 		"Target platform(s) for multi-arch images when no report directory is provided (e.g., linux/amd64,linux/arm64). "+
 			"Valid platforms: linux/amd64, linux/arm64, linux/riscv64, linux/ppc64le, linux/s390x, linux/386, linux/arm/v7, linux/arm/v6. "+
@@ -158,6 +377,42 @@ copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)`,
 	flags.StringVarP(&ua.loader, "loader", "l", "", "Loader to use for loading images. Options: 'docker', 'podman', or empty for auto-detection based on buildkit address")
 	flags.StringVar(&ua.eolAPIBaseURL, "eol-api-url", "", "EOL API base URL, defaults to 'https://endoflife.date/api/v1/products'")
 	flags.BoolVar(&ua.exitOnEOL, "exit-on-eol", false, "Exit with error when EOL (End of Life) operating system is detected")
+	flags.IntVar(&ua.maxDownloads, "max-concurrent-downloads", 0,
+		"Limit concurrent package downloads of apt, dnf, yum and npm (0 = no limit). Useful on slow or constrained networks. "+
+			"apk downloads one package at a time already; microdnf and tdnf are not limited")
+	flags.IntVar(&ua.maxParallel, "max-parallel-platforms", 0,
+		"Maximum number of platforms of a multi-platform image patched at once (0 = one per CPU)")
+	flags.IntVar(&ua.registryConcurrency, "registry-concurrency", utils.DefaultRegistryConcurrency,
+		"Maximum number of registry requests Copa makes at once when looking up manifests and pushing manifest lists, to stay within registry rate limits")
+	flags.StringArrayVar(&ua.cacheFrom, "cache-from", nil,
+		"External cache source for the patch build, repeatable (e.g. 'type=registry,ref=example.com/cache:patch' or 'type=local,src=/tmp/cache')")
+	flags.StringArrayVar(&ua.cacheTo, "cache-to", nil,
+		"Cache export destination for the patch build, repeatable (e.g. 'type=registry,ref=example.com/cache:patch,mode=max' or 'type=local,dest=/tmp/cache')")
+	flags.StringVar(&ua.smokeTest, "smoke-test", "",
+		"Shell command to run inside the patched image (e.g. 'nginx -t'); patching fails if it exits non-zero. "+
+			"Skipped for platforms that cannot run on this host")
+	flags.StringVar(&ua.prePatchScript, "pre-patch-script", "",
+		"Shell snippet to run in the image before packages are updated (e.g. to install a CA certificate or repository key)")
+	flags.StringVar(&ua.postPatchScript, "post-patch-script", "",
+		"Shell snippet to run in the image after packages are updated (e.g. to remove caches)")
+	flags.StringArrayVar(&ua.repoSnapshots, "repo-snapshot", nil,
+		"Pin a package type's repositories to a snapshot for reproducible patching, repeatable, as <type>=<url>. "+
+			"Supported types: "+strings.Join(pkgmgr.SnapshotTypes(), ", ")+
+			" (e.g. 'deb=https://snapshot.debian.org/archive/debian/20240101T000000Z')")
+	flags.StringArrayVar(&ua.labels, "label", nil,
+		"Label to add to the patched image config as key=value (repeatable), replacing a label of the same key")
+	flags.StringArrayVar(&ua.annotations, "annotation", nil,
+		"Annotation to add to the patched image manifest, and to the index of a multi-platform image, as key=value (repeatable)")
+	flags.StringArrayVar(&ua.repoMirrors, "repo-mirror", nil,
+		"Use a mirror for a package type's repositories while patching, repeatable, as <type>=<url>. "+
+			"The mirror replaces the scheme and host of each configured repository and is not kept in the patched image. "+
+			"Supported types: "+strings.Join(pkgmgr.MirrorTypes(), ", ")+" (e.g. 'deb=https://mirror.example.com')")
+	flags.BoolVar(&ua.aptSecurityOnly, "apt-security-only", false,
+		"On Debian and Ubuntu, upgrade the reported packages to the latest versions in the release's security suite instead of those in the report, "+
+			"as unattended-upgrades does. The security suite is preferred through apt pinning while patching; the image's apt sources are kept and not changed")
+	flags.StringArrayVar(&ua.pkgMgrPaths, "pkgmgr-path", nil,
+		"Invoke a package manager by this absolute path inside the image instead of looking it up on PATH, repeatable, as <command>=<path>. "+
+			"Supported commands: "+strings.Join(pkgmgr.PkgMgrPathCommands(), ", ")+" (e.g. 'npm=/opt/node/bin/npm')")
 	flags.StringVar(&ua.progress, "progress", "auto", "Set the buildkit display mode (auto, plain, tty, quiet or rawjson). Set to quiet to discard all output.")
 
 	// Experimental flags - only available when COPA_EXPERIMENTAL=1
@@ -173,6 +428,15 @@ copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)`,
 				"Values: 'patch' (e.g., 1.23.0 -> 1.23.latest), 'minor' (e.g., 1.23 -> 1.25), 'major'. "+
 				"Currently supported for Go only. Requires 'library' in --pkg-types")
 		flags.Lookup("toolchain-patch-level").NoOptDefVal = utils.PatchTypePatch
+		flags.BoolVar(&ua.directOnly, "direct-only", false,
+			"[EXPERIMENTAL] Only update Node.js packages listed as dependencies or devDependencies in each app's package.json, "+
+				"skipping transitive dependencies")
+		flags.BoolVar(&ua.includeDevDeps, "include-dev-dependencies", false,
+			"[EXPERIMENTAL] Also update Node.js packages that are only devDependencies and keep them installed. "+
+				"By default devDependencies are left out of npm updates and pruned, as in a production install")
+		flags.BoolVar(&ua.sharePatches, "share-platform-patches", false,
+			"[EXPERIMENTAL] Patch platforms that have the same base image layers and updates only once, "+
+				"reusing the result for the other platforms")
 	} else {
 		// Set default values when experimental flags are not enabled
 		ua.pkgTypes = utils.PkgTypeOS
@@ -182,6 +446,184 @@ copa patch --config copa-bulk-config.yaml --push (Bulk Image Patching)`,
 	return patchCmd
 }
 
+// validatePatchArgs checks the patch flags and the combinations they may be used in
+// before any options are built from them.
+func validatePatchArgs(ua *patchArgs) error {
+	if err := validateLibraryPatchLevel(ua.libraryPatchLevel, ua.pkgTypes); err != nil {
+		return err
+	}
+	if ua.maxDownloads < 0 {
+		return errors.New("--max-concurrent-downloads must not be negative")
+	}
+	if ua.maxParallel < 0 {
+		return errors.New("--max-parallel-platforms must not be negative")
+	}
+	if ua.registryConcurrency < 1 {
+		return errors.New("--registry-concurrency must be at least 1")
+	}
+
+	if len(ua.pushTo) > 0 && !ua.push {
+		return errors.New("--push-to requires --push")
+	}
+	if ua.parallelPush && !ua.push {
+		return errors.New("--parallel-registry-push requires --push")
+	}
+	if ua.attachAttestations && !ua.push {
+		return errors.New("--attach-attestations requires --push")
+	}
+
+	if ua.scannerArgs != "" && !ua.scan {
+		return errors.New("--scanner-args requires --scan")
+	}
+	if ua.scan && ua.report != "" {
+		return errors.New("--scan cannot be used with --report")
+	}
+	if len(ua.platformReportMap) > 0 {
+		if ua.report != "" || ua.scan {
+			return errors.New("--platform-report-map cannot be used with --report or --scan")
+		}
+		if ua.configFile != "" || ua.imageList != "" {
+			return errors.New("--platform-report-map cannot be used with --config or --image-list")
+		}
+		if _, err := buildkit.ParsePlatformReportMap(ua.platformReportMap); err != nil {
+			return err
+		}
+	}
+	reportGiven := ua.report != "" || len(ua.platformReportMap) > 0
+	if reportGiven || ua.scan {
+		if err := report.ValidateScanner(ua.scanner); err != nil {
+			return err
+		}
+	}
+	if ua.confirmFixed {
+		if !reportGiven && !ua.scan {
+			return errors.New("--confirm-fixed requires --report, --platform-report-map or --scan")
+		}
+		if ua.scanner != "trivy" {
+			return errors.New("--confirm-fixed requires the trivy scanner")
+		}
+	}
+	if _, err := buildkit.ParseReportPlatformKeyFormat(ua.reportPlatKey); err != nil {
+		return err
+	}
+	if err := report.ValidateFileExtensions(ua.scanner, ua.reportExts); err != nil {
+		return fmt.Errorf("invalid --report-extensions: %w", err)
+	}
+	if ua.ociPartial != "" {
+		if _, err := buildkit.ParseOCIPartialMode(ua.ociPartial); err != nil {
+			return err
+		}
+	}
+	if ua.ociDir != "" {
+		if _, err := buildkit.ParseOCILayoutFormat(ua.ociOutputFormat, ua.ociDir); err != nil {
+			return err
+		}
+	}
+	if err := report.ValidateSeverityOptions(ua.severitySource, ua.minSeverity); err != nil {
+		return err
+	}
+	if (ua.kevOnly || ua.kevCatalog != "") && ua.configFile == "" && ua.imageList == "" && !reportGiven && !ua.scan {
+		return errors.New("--kev-only and --kev-catalog require --report or --scan")
+	}
+	if _, err := buildkit.ParsePullPolicy(ua.pull); err != nil {
+		return err
+	}
+	if err := utils.ValidateCompression(ua.compression); err != nil {
+		return fmt.Errorf("invalid --compression: %w", err)
+	}
+	if ua.policy != "" && ua.configFile == "" && ua.imageList == "" && !reportGiven && !ua.scan {
+		return errors.New("--policy requires --report or --scan")
+	}
+	if ua.updateAll && !reportGiven && !ua.scan {
+		return errors.New("--update-all requires a report from trivy --list-all-pkgs; without a report Copa already updates all packages")
+	}
+	if ua.forcePkgManager != "" {
+		if err := pkgmgr.ValidateForcedPackageManager(ua.forcePkgManager); err != nil {
+			return fmt.Errorf("invalid --force-pkg-manager: %w", err)
+		}
+		if ua.forcePkgManager == pkgmgr.ForceNPM && ua.updateAll {
+			return errors.New("--update-all cannot be used with --force-pkg-manager=npm, which skips OS packages")
+		}
+	}
+	if ua.patchedUser != "" {
+		if err := buildkit.ValidateUser(ua.patchedUser); err != nil {
+			return fmt.Errorf("invalid --patched-user: %w", err)
+		}
+	}
+	if len(ua.patchedUserChown) > 0 && ua.patchedUser == "" {
+		return errors.New("--patched-user-chown requires --patched-user")
+	}
+	for _, p := range ua.patchedUserChown {
+		if !path.IsAbs(p) {
+			return fmt.Errorf("invalid --patched-user-chown %q: must be an absolute path", p)
+		}
+	}
+	if ua.exportDiff != "" {
+		if ua.push || ua.ociDir != "" {
+			return errors.New("--export-diff cannot be used with --push or --oci-dir; it writes the diff instead of an image")
+		}
+		if ua.confirmFixed {
+			return errors.New("--export-diff cannot be used with --confirm-fixed, which scans the patched image")
+		}
+		if ua.configFile != "" || ua.imageList != "" {
+			return errors.New("--export-diff cannot be used with --config or --image-list")
+		}
+	}
+	if _, err := buildkit.ParseCacheOptions(ua.cacheFrom, ua.cacheTo); err != nil {
+		return err
+	}
+	repoSnapshots, err := pkgmgr.ParseRepoSnapshots(ua.repoSnapshots)
+	if err != nil {
+		return err
+	}
+	if _, ok := repoSnapshots["deb"]; ok && ua.aptSecurityOnly {
+		return errors.New("--apt-security-only cannot be used with a deb --repo-snapshot")
+	}
+	repoMirrors, err := pkgmgr.ParseRepoMirrors(ua.repoMirrors)
+	if err != nil {
+		return err
+	}
+	for pkgType := range repoMirrors {
+		if _, ok := repoSnapshots[pkgType]; ok {
+			return fmt.Errorf("--repo-mirror and --repo-snapshot cannot both be set for %s", pkgType)
+		}
+	}
+	if _, err := utils.ParseKeyValues("--label", ua.labels); err != nil {
+		return err
+	}
+	if _, err := utils.ParseKeyValues("--annotation", ua.annotations); err != nil {
+		return err
+	}
+	if _, err := pkgmgr.ParsePkgMgrPaths(ua.pkgMgrPaths); err != nil {
+		return err
+	}
+
+	if ua.configFile == "" && ua.imageList == "" && ua.appImage == "" {
+		return errors.New("either --config, --image-list or --image must be provided")
+	}
+	if ua.imageList != "" {
+		if ua.configFile != "" || ua.appImage != "" || ua.patchedTag != "" {
+			return errors.New("--image-list cannot be used with --config, --image or --tag")
+		}
+		if ua.report != "" {
+			return errors.New("--image-list cannot be used with --report; give each image's report in the list")
+		}
+		if len(ua.pushTo) > 0 {
+			return errors.New("--push-to cannot be used with --image-list")
+		}
+		return nil
+	}
+	if ua.configFile != "" {
+		if ua.appImage != "" || ua.patchedTag != "" {
+			return errors.New("--config cannot be used with --image or --tag")
+		}
+		if len(ua.pushTo) > 0 {
+			return errors.New("--push-to cannot be used with --config")
+		}
+	}
+	return nil
+}
+
 // validateLibraryPatchLevel validates the library patch level flag and its usage.
 func validateLibraryPatchLevel(libraryPatchLevel, pkgTypes string) error {
 	// Valid library patch levels
@@ -19,7 +19,7 @@ func TestNewPatchCmdValidation(t *testing.T) {
 			name:                  "FAIL: No flags provided",
 			args:                  []string{},
 			expectValidationError: true,
-			expectedErrorContains: "either --config or --image must be provided",
+			expectedErrorContains: "either --config, --image-list or --image must be provided",
 		},
 		{
 			name:                  "FAIL: Conflicting flags (--config and --image)",
@@ -27,6 +27,53 @@ func TestNewPatchCmdValidation(t *testing.T) {
 			expectValidationError: true,
 			expectedErrorContains: "--config cannot be used with --image or --tag",
 		},
+		{
+			name:                  "FAIL: Conflicting flags (--image-list and --image)",
+			args:                  []string{"--image-list", "images.txt", "--image", "alpine"},
+			expectValidationError: true,
+			expectedErrorContains: "--image-list cannot be used with --config, --image or --tag",
+		},
+		{
+			name:                  "FAIL: Conflicting flags (--image-list and --report)",
+			args:                  []string{"--image-list", "images.txt", "--report", "trivy.json"},
+			expectValidationError: true,
+			expectedErrorContains: "--image-list cannot be used with --report",
+		},
+		{
+			name:                  "FAIL: --apt-security-only with a deb --repo-snapshot",
+			args:                  []string{"--image", "debian:12", "--apt-security-only", "--repo-snapshot", "deb=https://snapshot.debian.org/archive/debian/20240101T000000Z"},
+			expectValidationError: true,
+			expectedErrorContains: "--apt-security-only cannot be used with a deb --repo-snapshot",
+		},
+		{
+			name:                  "FAIL: --confirm-fixed without a report",
+			args:                  []string{"--image", "alpine:3.19", "--confirm-fixed"},
+			expectValidationError: true,
+			expectedErrorContains: "--confirm-fixed requires --report, --platform-report-map or --scan",
+		},
+		{
+			name:                  "PASS: --confirm-fixed with --platform-report-map",
+			args:                  []string{"--image", "alpine:3.19", "--confirm-fixed", "--platform-report-map", "linux/arm64=arm64.json"},
+			expectValidationError: false,
+		},
+		{
+			name:                  "FAIL: --platform-report-map with --report",
+			args:                  []string{"--image", "alpine:3.19", "--report", "trivy.json", "--platform-report-map", "linux/arm64=arm64.json"},
+			expectValidationError: true,
+			expectedErrorContains: "--platform-report-map cannot be used with --report or --scan",
+		},
+		{
+			name:                  "FAIL: --export-diff with --push",
+			args:                  []string{"--image", "alpine:3.19", "--export-diff", "diff.tar", "--push"},
+			expectValidationError: true,
+			expectedErrorContains: "--export-diff cannot be used with --push or --oci-dir",
+		},
+		{
+			name:                  "FAIL: --patched-user-chown without --patched-user",
+			args:                  []string{"--image", "alpine:3.19", "--patched-user-chown", "/app"},
+			expectValidationError: true,
+			expectedErrorContains: "--patched-user-chown requires --patched-user",
+		},
 		{
 			name:                  "PASS: Single image mode validation",
 			args:                  []string{"--image", "alpine:latest"},
@@ -27,7 +27,7 @@ func SetupBuildkitConfigAndManager(
 	osInfo *OSInfo, // If nil, will be detected from image
 ) (*buildkit.Config, pkgmgr.PackageManager, error) {
 	// Initialize buildkit config
-	config, err := buildkit.InitializeBuildkitConfig(ctx, c, image, platform)
+	config, err := buildkit.InitializeBuildkitConfig(ctx, c, image, platform, false, buildkit.PullMissing.ResolveMode())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -2,9 +2,9 @@ package frontend
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/moby/buildkit/client/llb"
@@ -12,25 +12,36 @@ import (
 	"github.com/moby/buildkit/util/bklog"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
+	fstypes "github.com/tonistiigi/fsutil/types"
 
 	"github.com/project-copacetic/copacetic/pkg/common"
 	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
 )
 
 const (
 	jsonExt = ".json"
 )
 
+// patchedImage is the image buildPatchedImage built for a platform.
+type patchedImage struct {
+	state llb.State
+	// noUpdatesApplied is set when state is the original image, because there was
+	// nothing to update or the update was skipped
+	noUpdatesApplied bool
+}
+
 // BuildPatchedImage builds a patched image using the Copa patching logic.
 // This reuses the same components as the CLI to ensure consistency.
-func (f *Frontend) buildPatchedImage(ctx context.Context, opts *types.Options, platform *ocispecs.Platform) (llb.State, error) {
+func (f *Frontend) buildPatchedImage(ctx context.Context, opts *types.Options, platform *ocispecs.Platform) (patchedImage, error) {
 	// Create package manager instance
 	config, pm, err := common.SetupBuildkitConfigAndManager(ctx, f.client, opts.Image, platform, "", nil)
 	if err != nil {
-		return llb.State{}, errors.Wrap(err, "failed to set up buildkit config and package manager")
+		return patchedImage{}, errors.Wrap(err, "failed to set up buildkit config and package manager")
 	}
+	original := patchedImage{state: config.ImageState, noUpdatesApplied: true}
 
 	// Parse the vulnerability report if provided
 	var um *unversioned.UpdateManifest
@@ -40,23 +51,22 @@ func (f *Frontend) buildPatchedImage(ctx context.Context, opts *types.Options, p
 		if platform != nil {
 			// Check if the report path is a directory with platform-specific files
 			if fi, err := os.Stat(reportPath); err == nil && fi.IsDir() {
-				// Build platform-specific filename
-				platformFile := fmt.Sprintf("%s-%s", platform.OS, platform.Architecture)
-				if platform.Variant != "" {
-					platformFile = fmt.Sprintf("%s-%s", platformFile, platform.Variant)
+				// Look for report-<os>-<arch>.json, then the bare <os>-<arch>.json
+				var specificReportPath string
+				for _, platformFile := range utils.PlatformReportNames(*platform) {
+					candidate := filepath.Join(reportPath, platformFile)
+					if _, err := os.Stat(candidate); err == nil {
+						specificReportPath = candidate
+						break
+					}
 				}
-				platformFile += jsonExt
-
-				specificReportPath := filepath.Join(reportPath, platformFile)
-				// Check if platform-specific report exists
-				if _, err := os.Stat(specificReportPath); err == nil {
-					reportPath = specificReportPath
-				} else {
+				if specificReportPath == "" {
 					bklog.G(ctx).WithField("component", "copa-frontend").
-						WithField("platform", platformFile).
+						WithField("platform", utils.PlatformFileSuffix(*platform)).
 						Warn("No report found for platform, skipping patch")
-					return config.ImageState, nil
+					return original, nil
 				}
+				reportPath = specificReportPath
 			}
 		}
 
@@ -67,37 +77,43 @@ func (f *Frontend) buildPatchedImage(ctx context.Context, opts *types.Options, p
 		var err error
 		um, err = report.TryParseScanReport(reportPath, opts.Scanner, opts.PkgTypes, opts.LibraryPatchLevel)
 		if err != nil {
-			return llb.State{}, errors.Wrapf(err, "failed to parse vulnerability report from path: %s", reportPath)
+			return patchedImage{}, errors.Wrapf(err, "failed to parse vulnerability report from path: %s", reportPath)
 		}
 	}
 
 	// Check if there are packages to update
 	if um != nil && len(um.OSUpdates) == 0 && len(um.LangUpdates) == 0 {
 		bklog.G(ctx).WithField("component", "copa-frontend").Info("No packages to update, returning original image")
-		return config.ImageState, nil
+		return original, nil
 	}
 
 	// Apply package updates using the same logic as CLI
 	patchedState, _, err := pm.InstallUpdates(ctx, um, opts.IgnoreError)
+	if errors.Is(err, types.ErrNoUpdatesFound) {
+		bklog.G(ctx).WithField("component", "copa-frontend").Info("Package manager found no updates, returning original image")
+		return original, nil
+	}
 	if err != nil {
 		if opts.IgnoreError {
 			bklog.G(ctx).WithError(err).WithField("component", "copa-frontend").Warn("Failed to install updates (ignored)")
-			return config.ImageState, nil
+			return original, nil
 		}
-		return llb.State{}, errors.Wrap(err, "failed to install package updates")
+		return patchedImage{}, errors.Wrap(err, "failed to install package updates")
 	}
 
-	return *patchedState, nil
+	return patchedImage{state: *patchedState}, nil
 }
 
 // extractReportFromContext extracts a report file or directory from the BuildKit context.
 // It automatically detects whether the report path is a file or directory and extracts accordingly.
-// Returns the path to the extracted temp file/directory.
+// Returns the path to the extracted temp file/directory, and a function that removes it,
+// to be called once the report was used. From a directory, only the files ending in one of
+// exts are extracted. On error nothing is left behind.
 //
 // To avoid gRPC message size limits (16MB), this function reads files in chunks when needed.
-func extractReportFromContext(ctx context.Context, client gwclient.Client, reportPath string) (string, error) {
+func extractReportFromContext(ctx context.Context, client gwclient.Client, reportPath string, exts []string) (string, func(), error) {
 	if reportPath == "" {
-		return "", nil
+		return "", func() {}, nil
 	}
 
 	bklog.G(ctx).WithField("component", "copa-frontend").
@@ -113,38 +129,56 @@ func extractReportFromContext(ctx context.Context, client gwclient.Client, repor
 
 	def, err := localState.Marshal(ctx)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to marshal local state")
+		return "", nil, errors.Wrap(err, "failed to marshal local state")
 	}
 
 	res, err := client.Solve(ctx, gwclient.SolveRequest{Definition: def.ToPB()})
 	if err != nil {
-		return "", errors.Wrap(err, "failed to solve local state")
+		return "", nil, errors.Wrap(err, "failed to solve local state")
 	}
 
 	ref, err := res.SingleRef()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get single ref for local state")
+		return "", nil, errors.Wrap(err, "failed to get single ref for local state")
 	}
 
 	// Check if this is a file or directory
 	stat, statErr := ref.StatFile(ctx, gwclient.StatRequest{Path: reportPath})
 	if statErr != nil {
-		return "", errors.Wrapf(statErr, "failed to stat report path: %s", reportPath)
+		return "", nil, errors.Wrapf(statErr, "failed to stat report path: %s", reportPath)
 	}
 
 	// Handle directory case
 	if stat.IsDir() {
-		return extractReportDirectory(ctx, ref, reportPath)
+		dir, err := extractReportDirectory(ctx, ref, reportPath, exts)
+		if err != nil {
+			return "", nil, err
+		}
+		return dir, removeExtractedReport(ctx, dir), nil
 	}
 
 	// Handle single file case - read in chunks if needed to avoid gRPC limits
-	return extractReportFile(ctx, ref, reportPath, stat.Size)
+	file, err := extractReportFile(ctx, ref, reportPath, stat.Size)
+	if err != nil {
+		return "", nil, err
+	}
+	return file, removeExtractedReport(ctx, filepath.Dir(file)), nil
 }
 
-// extractReportFile extracts a single report file, reading in chunks if it's larger than 8MB.
-func extractReportFile(ctx context.Context, ref gwclient.Reference, reportPath string, fileSize int64) (string, error) {
-	const chunkSize = 8 * 1024 * 1024 // 8MB chunks to stay well under 16MB gRPC limit
+// removeExtractedReport returns a function that removes dir, the temp directory a
+// report was extracted to, so frontends serving many builds don't fill up /tmp.
+func removeExtractedReport(ctx context.Context, dir string) func() {
+	return func() {
+		if err := os.RemoveAll(dir); err != nil {
+			bklog.G(ctx).WithError(err).WithField("component", "copa-frontend").
+				WithField("tempDir", dir).
+				Warn("Failed to remove extracted report")
+		}
+	}
+}
 
+// extractReportFile extracts a single report file to a new temp directory.
+func extractReportFile(ctx context.Context, ref gwclient.Reference, reportPath string, fileSize int64) (string, error) {
 	tmpDir, err := os.MkdirTemp("", "copa-frontend-report-")
 	if err != nil {
 		return "", errors.Wrap(err, "failed to create temp dir for report file")
@@ -155,26 +189,35 @@ func extractReportFile(ctx context.Context, ref gwclient.Reference, reportPath s
 		filename = "report" + jsonExt
 	}
 	tmpFile := filepath.Join(tmpDir, filename)
+	if err := writeReportFile(ctx, ref, reportPath, fileSize, tmpFile); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	return tmpFile, nil
+}
+
+// writeReportFile writes the report file at reportPath to dest, reading it in chunks
+// if it's larger than 8MB.
+func writeReportFile(ctx context.Context, ref gwclient.Reference, reportPath string, fileSize int64, dest string) error {
+	const chunkSize = 8 * 1024 * 1024 // 8MB chunks to stay well under 16MB gRPC limit
 
 	// If file is small enough, read in one go
 	if fileSize < chunkSize {
 		data, err := ref.ReadFile(ctx, gwclient.ReadRequest{Filename: reportPath})
 		if err != nil {
-			os.RemoveAll(tmpDir)
-			return "", errors.Wrapf(err, "failed to read report file: %s", reportPath)
+			return errors.Wrapf(err, "failed to read report file: %s", reportPath)
 		}
 
-		if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
-			os.RemoveAll(tmpDir)
-			return "", errors.Wrap(err, "failed to write report to temp file")
+		if err := os.WriteFile(dest, data, 0o600); err != nil {
+			return errors.Wrap(err, "failed to write report to temp file")
 		}
 
 		bklog.G(ctx).WithField("component", "copa-frontend").
-			WithField("tempFile", tmpFile).
+			WithField("tempFile", dest).
 			WithField("size", fileSize).
 			Debug("Extracted report file from context")
 
-		return tmpFile, nil
+		return nil
 	}
 
 	// File is large - read in chunks
@@ -182,10 +225,9 @@ func extractReportFile(ctx context.Context, ref gwclient.Reference, reportPath s
 		WithField("size", fileSize).
 		Info("Reading large report file in chunks")
 
-	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY, 0o600)
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		os.RemoveAll(tmpDir)
-		return "", errors.Wrap(err, "failed to create temp file")
+		return errors.Wrap(err, "failed to create temp file")
 	}
 	defer f.Close()
 
@@ -204,39 +246,51 @@ func extractReportFile(ctx context.Context, ref gwclient.Reference, reportPath s
 			},
 		})
 		if err != nil {
-			os.RemoveAll(tmpDir)
-			return "", errors.Wrapf(err, "failed to read chunk at offset %d", offset)
+			return errors.Wrapf(err, "failed to read chunk at offset %d", offset)
 		}
 
 		if _, err := f.Write(chunk); err != nil {
-			os.RemoveAll(tmpDir)
-			return "", errors.Wrap(err, "failed to write chunk to temp file")
+			return errors.Wrap(err, "failed to write chunk to temp file")
 		}
 
 		offset += int64(len(chunk))
 	}
 
 	bklog.G(ctx).WithField("component", "copa-frontend").
-		WithField("tempFile", tmpFile).
+		WithField("tempFile", dest).
 		WithField("size", fileSize).
 		WithField("chunks", (fileSize+chunkSize-1)/chunkSize).
 		Debug("Extracted large report file in chunks")
 
-	return tmpFile, nil
+	return nil
+}
+
+// reportIncludePattern returns the ReadDir include pattern that matches the report
+// files with one of exts. BuildKit takes a single pattern, so several extensions list
+// every file, to be filtered by the caller.
+func reportIncludePattern(exts []string) string {
+	if len(exts) == 1 {
+		return "*" + exts[0]
+	}
+	return ""
 }
 
-// extractReportDirectory extracts all JSON files from a report directory.
-func extractReportDirectory(ctx context.Context, ref gwclient.Reference, reportPath string) (string, error) {
+// extractReportDirectory extracts the report files, those ending in one of exts, from
+// a report directory.
+func extractReportDirectory(ctx context.Context, ref gwclient.Reference, reportPath string, exts []string) (string, error) {
 	entries, err := ref.ReadDir(ctx, gwclient.ReadDirRequest{
 		Path:           reportPath,
-		IncludePattern: "*" + jsonExt,
+		IncludePattern: reportIncludePattern(exts),
 	})
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to read report directory: %s", reportPath)
 	}
+	entries = slices.DeleteFunc(entries, func(e *fstypes.Stat) bool {
+		return !report.HasFileExtension(e.GetPath(), exts)
+	})
 
 	if len(entries) == 0 {
-		return "", errors.Errorf("no JSON files found in report directory: %s", reportPath)
+		return "", errors.Errorf("no %s files found in report directory: %s", strings.Join(exts, " or "), reportPath)
 	}
 
 	tmpDir, err := os.MkdirTemp("", "copa-frontend-reports-")
@@ -244,28 +298,21 @@ func extractReportDirectory(ctx context.Context, ref gwclient.Reference, reportP
 		return "", errors.Wrap(err, "failed to create temp dir for report directory")
 	}
 
-	// Extract each JSON file
+	// Extract each report file
 	for _, entry := range entries {
-		if strings.HasSuffix(entry.GetPath(), jsonExt) {
-			entryPath := filepath.Join(reportPath, filepath.Base(entry.GetPath()))
-
-			// Read file (with chunking support for large files)
-			extractedFile, err := extractReportFile(ctx, ref, entryPath, entry.Size)
-			if err != nil {
-				bklog.G(ctx).WithError(err).WithField("file", entryPath).Warn("Failed to extract report file from directory")
-				continue
-			}
+		if err := ctx.Err(); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", errors.Wrap(err, "report extraction canceled")
+		}
 
-			// Move to the reports directory
-			destPath := filepath.Join(tmpDir, filepath.Base(entry.GetPath()))
-			if err := os.Rename(extractedFile, destPath); err != nil {
-				// If rename fails, try copy
-				data, readErr := os.ReadFile(extractedFile)
-				if readErr == nil {
-					_ = os.WriteFile(destPath, data, 0o600)
-				}
-				os.RemoveAll(filepath.Dir(extractedFile))
-			}
+		entryPath := filepath.Join(reportPath, filepath.Base(entry.GetPath()))
+		destPath := filepath.Join(tmpDir, filepath.Base(entry.GetPath()))
+
+		// Read file (with chunking support for large files)
+		if err := writeReportFile(ctx, ref, entryPath, entry.Size, destPath); err != nil {
+			bklog.G(ctx).WithError(err).WithField("file", entryPath).Warn("Failed to extract report file from directory")
+			os.Remove(destPath)
+			continue
 		}
 	}
 
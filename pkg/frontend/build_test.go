@@ -1,12 +1,18 @@
 package frontend
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+
+	"github.com/project-copacetic/copacetic/mocks"
 )
 
 const (
@@ -297,3 +303,110 @@ func TestJSONFileFiltering(t *testing.T) {
 		assert.Contains(t, jsonFiles, "report2.json")
 	})
 }
+
+func TestExtractReportDirectoryExtensions(t *testing.T) {
+	stat := func(name string) *fstypes.Stat { return &fstypes.Stat{Path: name, Size: 2} }
+
+	tests := []struct {
+		name        string
+		exts        []string
+		wantPattern string
+		want        []string
+	}{
+		{name: "default", exts: []string{".json"}, wantPattern: "*.json", want: []string{"linux-amd64.json"}},
+		{
+			name:        "several extensions",
+			exts:        []string{".json", ".sarif"},
+			wantPattern: "",
+			want:        []string{"linux-amd64.json", "linux-arm64.sarif"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := new(mocks.MockReference)
+			var entries []*fstypes.Stat
+			for _, name := range []string{"linux-amd64.json", "linux-arm64.sarif", "linux-s390x.json.gz", "README.md"} {
+				if tt.wantPattern == "" || filepath.Ext(name) == filepath.Ext(tt.wantPattern) {
+					entries = append(entries, stat(name))
+				}
+			}
+			ref.On("ReadDir", mock.Anything, gwclient.ReadDirRequest{Path: "reports", IncludePattern: tt.wantPattern}).Return(entries, nil)
+			ref.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("{}"), nil)
+
+			dir, err := extractReportDirectory(context.Background(), ref, "reports", tt.exts)
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			files, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			var got []string
+			for _, f := range files {
+				got = append(got, f.Name())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExtractReportFromContextCleanup(t *testing.T) {
+	tests := []struct {
+		name string
+		stat *fstypes.Stat
+	}{
+		{name: "file", stat: &fstypes.Stat{Path: "report.json", Size: 2}},
+		{name: "directory", stat: &fstypes.Stat{Path: "reports", Mode: uint32(os.ModeDir)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+
+			client := new(mocks.MockGWClient)
+			ref := new(mocks.MockReference)
+			res := &gwclient.Result{}
+			res.SetRef(ref)
+			client.On("Solve", mock.Anything, mock.Anything).Return(res, nil)
+			ref.On("StatFile", mock.Anything, mock.Anything).Return(tt.stat, nil)
+			ref.On("ReadDir", mock.Anything, mock.Anything).Return([]*fstypes.Stat{
+				{Path: "linux-amd64.json", Size: 2},
+				{Path: "linux-arm64.json", Size: 2},
+			}, nil)
+			ref.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("{}"), nil)
+
+			path, cleanup, err := extractReportFromContext(context.Background(), client, tt.stat.Path, []string{jsonExt})
+			require.NoError(t, err)
+			if tt.stat.IsDir() {
+				assert.FileExists(t, filepath.Join(path, "linux-amd64.json"))
+			} else {
+				assert.FileExists(t, path)
+			}
+
+			left, err := os.ReadDir(tmp)
+			require.NoError(t, err)
+			assert.Len(t, left, 1, "a single temp directory holds the report")
+
+			cleanup()
+			left, err = os.ReadDir(tmp)
+			require.NoError(t, err)
+			assert.Empty(t, left)
+		})
+	}
+
+	t.Run("canceled directory extraction", func(t *testing.T) {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+
+		ref := new(mocks.MockReference)
+		ref.On("ReadDir", mock.Anything, mock.Anything).Return([]*fstypes.Stat{{Path: "linux-amd64.json", Size: 2}}, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := extractReportDirectory(ctx, ref, "reports", []string{jsonExt})
+		require.ErrorIs(t, err, context.Canceled)
+		left, err := os.ReadDir(tmp)
+		require.NoError(t, err)
+		assert.Empty(t, left)
+	})
+}
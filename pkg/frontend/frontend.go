@@ -13,6 +13,7 @@ import (
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/types"
 )
 
@@ -29,6 +30,8 @@ const (
 	keyFormat            = "format"
 	keyPkgTypes          = "pkg-types"
 	keyLibraryPatchLevel = "library-patch-level"
+	keyReportExtensions  = "report-extensions"
+	keyFailOnNoPatch     = "fail-on-no-patch"
 )
 
 // Frontend implements the BuildKit frontend interface for Copa.
@@ -48,10 +51,12 @@ func (f *Frontend) build(ctx context.Context) (*gwclient.Result, error) {
 	bklog.G(ctx).WithField("component", "copa-frontend").Info("Frontend started")
 
 	// Parse frontend configuration
-	opts, err := ParseOptions(ctx, f.client)
+	opts, removeReport, err := ParseOptions(ctx, f.client)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse frontend configuration")
 	}
+	// Runs when the build returns, including when it is canceled
+	defer removeReport()
 
 	bklog.G(ctx).WithField("component", "copa-frontend").Debug("Configuration parsed successfully")
 
@@ -65,11 +70,12 @@ func (f *Frontend) build(ctx context.Context) (*gwclient.Result, error) {
 			// Try to discover platforms from the extracted directory
 			entries, err := os.ReadDir(opts.Report)
 			if err == nil {
+				exts := report.FileExtensionsOrDefault(opts.ReportExtensions)
 				hasPlatformFiles := false
 				for _, entry := range entries {
-					if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+					if !entry.IsDir() && report.HasFileExtension(entry.Name(), exts) {
 						// Check if filename matches platform pattern (e.g., linux-amd64.json)
-						name := strings.TrimSuffix(entry.Name(), ".json")
+						name := report.TrimFileExtension(entry.Name(), exts)
 						if strings.Contains(name, "-") {
 							hasPlatformFiles = true
 							break
@@ -96,13 +102,16 @@ func (f *Frontend) build(ctx context.Context) (*gwclient.Result, error) {
 
 	// No platforms specified - use default build
 	bklog.G(ctx).WithField("component", "copa-frontend").Info("Building for default platform")
-	st, err := f.buildPatchedImage(ctx, opts, nil)
+	img, err := f.buildPatchedImage(ctx, opts, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build patched image")
 	}
+	if err := checkUpdatesApplied(ctx, opts, []patchedImage{img}); err != nil {
+		return nil, err
+	}
 
 	// Solve and return the result
-	def, err := st.Marshal(ctx)
+	def, err := img.state.Marshal(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal LLB")
 	}
@@ -124,7 +133,8 @@ func (f *Frontend) buildMultiarch(ctx context.Context, opts *types.Options) (*gw
 	// If report is a directory, discover platforms from report files
 	if opts.Report != "" {
 		if fi, err := os.Stat(opts.Report); err == nil && fi.IsDir() {
-			patchPlatforms, err := buildkit.DiscoverPlatformsFromReport(opts.Report, opts.Scanner)
+			patchPlatforms, err := buildkit.DiscoverPlatformsFromReport(opts.Report, opts.Scanner,
+				&buildkit.ReportDiscoveryOptions{Extensions: opts.ReportExtensions})
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to discover platforms from report directory")
 			}
@@ -154,14 +164,21 @@ func (f *Frontend) buildMultiarch(ctx context.Context, opts *types.Options) (*gw
 
 	// Build for each platform and add as separate references
 	var expPlatforms exptypes.Platforms
-	for _, platform := range targetPlatforms {
-		st, err := f.buildPatchedImage(ctx, opts, &platform)
+	images := make([]patchedImage, len(targetPlatforms))
+	for i, platform := range targetPlatforms {
+		img, err := f.buildPatchedImage(ctx, opts, &platform)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to build patched image for platform %s", platforms.Format(platform))
 		}
+		images[i] = img
+	}
+	if err := checkUpdatesApplied(ctx, opts, images); err != nil {
+		return nil, err
+	}
 
+	for i, platform := range targetPlatforms {
 		// Solve each platform individually to get a reference
-		def, err := st.Marshal(ctx)
+		def, err := images[i].state.Marshal(ctx)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to marshal LLB")
 		}
@@ -198,3 +215,22 @@ func (f *Frontend) buildMultiarch(ctx context.Context, opts *types.Options) (*gw
 
 	return res, nil
 }
+
+// checkUpdatesApplied logs when none of images was patched, since the build then
+// produces an image identical to opts.Image, and fails with ErrNoPackagesPatched
+// when fail-on-no-patch is set so the unchanged image isn't pushed under a new tag.
+func checkUpdatesApplied(ctx context.Context, opts *types.Options, images []patchedImage) error {
+	if len(images) == 0 {
+		return nil
+	}
+	for _, img := range images {
+		if !img.noUpdatesApplied {
+			return nil
+		}
+	}
+	if opts.FailOnNoPatch {
+		return errors.Wrapf(types.ErrNoPackagesPatched, "%s is already up to date", opts.Image)
+	}
+	bklog.G(ctx).WithField("component", "copa-frontend").Infof("Image %s is already up to date, no patch produced", opts.Image)
+	return nil
+}
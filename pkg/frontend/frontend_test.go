@@ -1,6 +1,7 @@
 package frontend
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,6 +11,8 @@ import (
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
 )
 
 // Note: Build() and buildMultiarch() tests are covered by e2e tests in test/e2e/frontend/
@@ -327,3 +330,34 @@ func TestPlatformNormalization(t *testing.T) {
 		}
 	})
 }
+
+func TestCheckUpdatesApplied(t *testing.T) {
+	ctx := context.Background()
+	upToDate := patchedImage{noUpdatesApplied: true}
+	patched := patchedImage{}
+
+	tests := []struct {
+		name          string
+		images        []patchedImage
+		failOnNoPatch bool
+		wantErr       bool
+	}{
+		{name: "patched", images: []patchedImage{patched}, failOnNoPatch: true},
+		{name: "one platform patched", images: []patchedImage{upToDate, patched}, failOnNoPatch: true},
+		{name: "up to date", images: []patchedImage{upToDate}},
+		{name: "up to date with fail-on-no-patch", images: []patchedImage{upToDate, upToDate}, failOnNoPatch: true, wantErr: true},
+		{name: "no platforms", failOnNoPatch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &types.Options{Image: "example.com/app:1", FailOnNoPatch: tt.failOnNoPatch}
+			err := checkUpdatesApplied(ctx, opts, tt.images)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, types.ErrNoPackagesPatched)
+			assert.ErrorContains(t, err, "example.com/app:1 is already up to date")
+		})
+	}
+}
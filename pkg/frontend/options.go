@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/exp/slices"
 
+	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/utils"
 )
@@ -19,13 +20,22 @@ const (
 	trueStr = "true"
 )
 
-// ParseOptions parses the frontend options from the build context.
-func ParseOptions(ctx context.Context, client gwclient.Client) (*types.Options, error) {
+// ParseOptions parses the frontend options from the build context. The returned
+// function removes the report extracted from the context and must be called once the
+// build is done with it.
+func ParseOptions(ctx context.Context, client gwclient.Client) (_ *types.Options, _ func(), err error) {
+	removeReport := func() {}
+	defer func() {
+		if err != nil {
+			removeReport()
+		}
+	}()
+
 	// Wrap the client with dockerui for better Docker CLI compatibility
 	// This provides automatic dockerignore handling and named context support
 	c, err := dockerui.NewClient(client)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create dockerui client")
+		return nil, nil, errors.Wrap(err, "failed to create dockerui client")
 	}
 
 	opts := c.BuildOpts()
@@ -53,7 +63,7 @@ func ParseOptions(ctx context.Context, client gwclient.Client) (*types.Options,
 	if v, ok := getOpt(keyImage); ok {
 		options.Image = v
 	} else {
-		return nil, errors.New("base image reference required via --opt image=<ref>")
+		return nil, nil, errors.New("base image reference required via --opt image=<ref>")
 	}
 
 	// Parse scanner type
@@ -66,6 +76,11 @@ func ParseOptions(ctx context.Context, client gwclient.Client) (*types.Options,
 		options.IgnoreError = v == trueStr || v == "1"
 	}
 
+	// Parse fail on no patch flag
+	if v, ok := getOpt(keyFailOnNoPatch); ok {
+		options.FailOnNoPatch = v == trueStr || v == "1"
+	}
+
 	// Parse platforms (as string slice for multiarch support)
 	if v, ok := getOpt(keyPlatform); ok {
 		// Split comma-separated platforms
@@ -73,15 +88,28 @@ func ParseOptions(ctx context.Context, client gwclient.Client) (*types.Options,
 		bklog.G(ctx).WithField("component", "copa-frontend").WithField("platforms", options.Platforms).Debug("Parsed platforms")
 	}
 
+	// Parse the extensions of the report files in a report directory
+	if v, ok := getOpt(keyReportExtensions); ok {
+		options.ReportExtensions = strings.Split(v, ",")
+		if err := report.ValidateFileExtensions(options.Scanner, options.ReportExtensions); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid report-extensions")
+		}
+	}
+
 	// Parse vulnerability report
 	if reportPath, ok := getOpt(keyReport); ok {
 		bklog.G(ctx).WithField("component", "copa-frontend").WithField("reportPath", reportPath).Info("Vulnerability report provided, using report mode")
 
 		// Extract the report from the BuildKit context
-		extractedPath, err := extractReportFromContext(ctx, client, reportPath)
+		exts := options.ReportExtensions
+		if len(exts) == 0 {
+			exts = []string{report.DefaultFileExtension}
+		}
+		extractedPath, cleanup, err := extractReportFromContext(ctx, client, reportPath, exts)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to extract report from context")
+			return nil, nil, errors.Wrapf(err, "failed to extract report from context")
 		}
+		removeReport = cleanup
 		options.Report = extractedPath
 	} else {
 		// update all
@@ -122,21 +150,21 @@ func ParseOptions(ctx context.Context, client gwclient.Client) (*types.Options,
 
 	// Validate library patch level
 	if err := validateLibraryPatchLevel(options.LibraryPatchLevel, options.PkgTypes); err != nil {
-		return nil, errors.Wrap(err, "invalid library patch level configuration")
+		return nil, nil, errors.Wrap(err, "invalid library patch level configuration")
 	}
 
 	// Validate that library package types require a report
 	pkgTypesList, err := parsePkgTypes(options.PkgTypes)
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid package types")
+		return nil, nil, errors.Wrap(err, "invalid package types")
 	}
 
 	reportProvided := options.Report != ""
 	if err := validateLibraryPkgTypesRequireReport(pkgTypesList, reportProvided); err != nil {
-		return nil, errors.Wrap(err, "library package types validation failed")
+		return nil, nil, errors.Wrap(err, "library package types validation failed")
 	}
 
-	return options, nil
+	return options, removeReport, nil
 }
 
 // validateLibraryPatchLevel validates the library patch level flag and its usage.
@@ -14,20 +14,18 @@ import (
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
 	gwclient "github.com/moby/buildkit/frontend/gateway/client"
-	"github.com/moby/buildkit/session"
-	"github.com/moby/buildkit/session/auth/authprovider"
 	"github.com/moby/buildkit/util/progress/progressui"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
-	"github.com/docker/cli/cli/config"
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/common"
 	"github.com/project-copacetic/copacetic/pkg/patch"
 	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
 	"github.com/project-copacetic/copacetic/pkg/vex"
 )
 
@@ -174,9 +172,7 @@ func extractPatchLayer(
 	reportFile, format, output string,
 	progress progressui.DisplayMode,
 ) ([]byte, error) {
-	dockerConfig := config.LoadDefaultConfigFile(os.Stderr)
-	cfg := authprovider.DockerAuthProviderConfig{AuthConfigProvider: authprovider.LoadAuthConfig(dockerConfig)}
-	attachable := []session.Attachable{authprovider.NewDockerAuthProvider(cfg)}
+	attachable := append(utils.RegistryAuthSession(), utils.ProxySecrets(ctx))
 
 	// Channel to collect the patch layer data
 	patchChannel := make(chan []byte, 1)
@@ -275,7 +271,7 @@ func extractPatchLayer(
 			}
 
 			// Get the patched image state from result
-			config, err := buildkit.InitializeBuildkitConfig(ctx, c, image, &platform)
+			config, err := buildkit.InitializeBuildkitConfig(ctx, c, image, &platform, false, buildkit.PullMissing.ResolveMode())
 			if err != nil {
 				ch <- err
 				return nil, err
@@ -109,6 +109,37 @@ func TestDockerLoader_Load_EmptyStream(t *testing.T) {
 	}
 }
 
+func TestDockerLoader_Load_StreamsExport(t *testing.T) {
+	// The docker exporter writes the image tarball into a pipe that is handed to the
+	// loader; ImageLoad must receive exactly what was exported.
+	exported := []byte("exported-docker-tarball")
+	pipeR, pipeW := io.Pipe()
+	go func() {
+		_, err := pipeW.Write(exported)
+		pipeW.CloseWithError(err)
+	}()
+
+	var loaded []byte
+	mockCli := &mockDockerAPIClientImpl{
+		imageLoadFunc: func(_ context.Context, input io.Reader, _ ...dockerClient.ImageLoadOption) (dockerClient.ImageLoadResult, error) {
+			var err error
+			loaded, err = io.ReadAll(input)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(strings.NewReader("{\"stream\":\"Loaded image: example.com/app:patched\"}\n")), nil
+		},
+	}
+	ldr := &dockerLoader{cli: mockCli}
+
+	if err := ldr.Load(context.Background(), pipeR, "example.com/app:patched"); err != nil {
+		t.Fatalf("(*dockerLoader).Load failed: %v", err)
+	}
+	if !bytes.Equal(exported, loaded) {
+		t.Fatalf("ImageLoad received %q, want %q", loaded, exported)
+	}
+}
+
 // mockDockerAPIClientImpl implements the dockerAPIClient interface for testing.
 type mockDockerAPIClientImpl struct {
 	pingFunc      func(ctx context.Context, options dockerClient.PingOptions) (dockerClient.PingResult, error)
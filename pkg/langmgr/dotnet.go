@@ -323,7 +323,7 @@ func (dnm *dotnetManager) patchRuntimeImage(
 
 	discoveryState := imageState.Run(
 		llb.Shlex(findDepsCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Extract the detected framework version to use the correct SDK image
@@ -391,14 +391,14 @@ cat /patch/patch.csproj'`, patchCsproj)
 
 	projectCreated := sdkState.Run(
 		llb.Shlex(createProjectCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Restore and publish to extract the fixed DLLs, then install jq for deps.json manipulation
 	restoreAndPublishCmd := `sh -c 'cd /patch && dotnet restore && dotnet publish -c Release -o /output && apt-get update -qq && apt-get install -qq -y jq >/dev/null 2>&1'`
 	publishedDLLs := projectCreated.Run(
 		llb.Shlex(restoreAndPublishCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Copy the patched DLLs and native dependencies back to the runtime image
@@ -453,7 +453,7 @@ echo "DLL patching complete"
 	patchedState := discoveryState.Run(
 		llb.AddMount("/output", publishedDLLs, llb.SourcePath("/output"), llb.Readonly),
 		llb.Shlex(copyDLLsScript),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Now run deps.json update in SDK container with jq, then copy result back to runtime image
@@ -469,7 +469,7 @@ echo "DLL patching complete"
 	sdkWithDepsUpdate := publishedDLLs.Run(
 		llb.AddMount("/runtime-tmp", patchedState, llb.SourcePath("/tmp")),
 		llb.Args([]string{"sh", "-c", updateDepsJsonScript}),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Copy the updated deps.json back to the runtime image
@@ -485,7 +485,7 @@ fi
 	depsUpdatedState := patchedState.Run(
 		llb.AddMount("/updated-deps", sdkWithDepsUpdate, llb.SourcePath("/output"), llb.Readonly),
 		llb.Args([]string{"sh", "-c", copyUpdatedDepsScript}),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Clean up temporary files from /tmp that were created during patching
@@ -500,7 +500,7 @@ rm -f /tmp/framework_version
 '`
 	cleanedState := depsUpdatedState.Run(
 		llb.Shlex(cleanupScript),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	log.Info("Runtime patching completed")
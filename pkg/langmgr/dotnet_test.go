@@ -237,7 +237,7 @@ func TestGetLanguageManagers_DotnetAndPython(t *testing.T) {
 		},
 	}
 
-	managers := GetLanguageManagers(config, testWorkingFolder, manifest, "")
+	managers := GetLanguageManagers(config, testWorkingFolder, manifest, Options{})
 	// Expect two managers (order not strictly guaranteed)
 	assert.Len(t, managers, 2)
 
@@ -257,14 +257,14 @@ func TestGetLanguageManagers_DotnetAndPython(t *testing.T) {
 func TestGetLanguageManagers_None(t *testing.T) {
 	config := &buildkit.Config{}
 	manifest := &unversioned.UpdateManifest{LangUpdates: unversioned.LangUpdatePackages{}}
-	managers := GetLanguageManagers(config, testWorkingFolder, manifest, "")
+	managers := GetLanguageManagers(config, testWorkingFolder, manifest, Options{})
 	assert.Len(t, managers, 0)
 }
 
 func TestGetLanguageManagers_DotnetOnly(t *testing.T) {
 	config := &buildkit.Config{}
 	manifest := &unversioned.UpdateManifest{LangUpdates: unversioned.LangUpdatePackages{{Name: "Newtonsoft.Json", FixedVersion: "13.0.3", Type: utils.DotNetPackages}}}
-	managers := GetLanguageManagers(config, testWorkingFolder, manifest, "")
+	managers := GetLanguageManagers(config, testWorkingFolder, manifest, Options{})
 	assert.Len(t, managers, 1)
 	_, ok := managers[0].(*dotnetManager)
 	assert.True(t, ok, "expected first manager to be dotnetManager")
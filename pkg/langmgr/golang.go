@@ -298,7 +298,7 @@ func (gm *golangManager) detectGo(ctx context.Context, currentState *llb.State)
 	checkCmd := `sh -c 'if command -v go >/dev/null 2>&1; then echo ok > ` + goCheckFile + `; fi'`
 	checked := currentState.Run(
 		llb.Shlex(checkCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	_, err := buildkit.ExtractFileFromState(ctx, gm.config.Client, &checked, goCheckFile)
@@ -328,7 +328,7 @@ func (gm *golangManager) detectGoModules(ctx context.Context, currentState *llb.
 
 	detected := currentState.Run(
 		llb.Shlex(findCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	pathBytes, err := buildkit.ExtractFileFromState(ctx, gm.config.Client, &detected, goModDetectFile)
@@ -355,7 +355,7 @@ func (gm *golangManager) detectGoWorkspace(ctx context.Context, currentState *ll
 
 	detected := currentState.Run(
 		llb.Shlex(findCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	pathBytes, err := buildkit.ExtractFileFromState(ctx, gm.config.Client, &detected, goWorkDetectFile)
@@ -380,7 +380,7 @@ func (gm *golangManager) detectVendor(ctx context.Context, currentState *llb.Sta
 	checkCmd := fmt.Sprintf(`sh -c 'if [ -d "%s/vendor" ]; then echo ok > %s; fi'`, modPath, goVendorDetectFile)
 	checked := currentState.Run(
 		llb.Shlex(checkCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	_, err := buildkit.ExtractFileFromState(ctx, gm.config.Client, &checked, goVendorDetectFile)
@@ -398,7 +398,7 @@ func (gm *golangManager) detectGoVersion(ctx context.Context, currentState *llb.
 	versionCmd := `sh -c 'go version 2>/dev/null | grep -oE "go[0-9]+\.[0-9]+" | sed "s/go//" > ` + goVersionFile + `'`
 	versionState := currentState.Run(
 		llb.Shlex(versionCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	versionBytes, err := buildkit.ExtractFileFromState(ctx, gm.config.Client, &versionState, goVersionFile)
@@ -799,7 +799,7 @@ func (gm *golangManager) updateGoModule(
 
 	state = state.Run(
 		llb.Shlex(updateCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Check for vendor directory and update if present
@@ -809,7 +809,7 @@ func (gm *golangManager) updateGoModule(
 		vendorCmd := fmt.Sprintf(`sh -c 'cd %s && go mod vendor'`, modPath)
 		state = state.Run(
 			llb.Shlex(vendorCmd),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	}
 
@@ -916,7 +916,7 @@ func (gm *golangManager) upgradePackagesWithTooling(
 
 		toolingState = toolingState.Dir("/workspace").Run(
 			llb.Shlex(updateCmd),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 
 		// Check if vendor exists in original and update if so
@@ -926,7 +926,7 @@ func (gm *golangManager) upgradePackagesWithTooling(
 			vendorCmd := `sh -c 'cd /workspace && go mod vendor'`
 			toolingState = toolingState.Dir("/workspace").Run(
 				llb.Shlex(vendorCmd),
-				llb.WithProxy(utils.GetProxy()),
+				utils.WithProxy(),
 			).Root()
 
 			// Copy vendor directory back
@@ -26,9 +26,27 @@ type LangManager interface {
 	InstallUpdates(context.Context, *llb.State, *unversioned.UpdateManifest, bool) (*llb.State, []string, error)
 }
 
+// SkippedPackagesReporter is implemented by language managers that leave some reported
+// packages alone on purpose, such as transitive npm dependencies in direct-only mode.
+// Those packages are not patched, but did not fail to update either.
+type SkippedPackagesReporter interface {
+	SkippedPackages() []string
+}
+
+// Options tunes the behavior of individual language managers.
+type Options struct {
+	// ToolchainPatchLevel enables Go toolchain upgrades ("patch", "minor", "major"; empty = disabled).
+	ToolchainPatchLevel string
+	// NodeDirectOnly limits npm updates to direct dependencies listed in package.json.
+	NodeDirectOnly bool
+	// NodeIncludeDev updates packages that are only devDependencies and keeps them
+	// installed; otherwise npm updates skip them and prune them as --omit=dev does.
+	NodeIncludeDev bool
+}
+
 // GetLanguageManagers returns a list of language managers that have relevant packages to process.
 // Uses a switch-based approach to determine which managers to include based on package types.
-func GetLanguageManagers(config *buildkit.Config, workingFolder string, manifest *unversioned.UpdateManifest, toolchainPatchLevel string) []LangManager {
+func GetLanguageManagers(config *buildkit.Config, workingFolder string, manifest *unversioned.UpdateManifest, opts Options) []LangManager {
 	var managers []LangManager
 
 	if manifest == nil || len(manifest.LangUpdates) == 0 {
@@ -46,10 +64,15 @@ func GetLanguageManagers(config *buildkit.Config, workingFolder string, manifest
 		case utils.PythonPackages:
 			managers = append(managers, &pythonManager{config: config, workingFolder: workingFolder})
 		case utils.NodePackages:
-			managers = append(managers, &nodejsManager{config: config, workingFolder: workingFolder})
+			managers = append(managers, &nodejsManager{
+				config:        config,
+				workingFolder: workingFolder,
+				directOnly:    opts.NodeDirectOnly,
+				includeDev:    opts.NodeIncludeDev,
+			})
 		case utils.GoModules, utils.GoBinary:
 			if !goAdded {
-				managers = append(managers, &golangManager{config: config, workingFolder: workingFolder, toolchainPatchLevel: toolchainPatchLevel})
+				managers = append(managers, &golangManager{config: config, workingFolder: workingFolder, toolchainPatchLevel: opts.ToolchainPatchLevel})
 				goAdded = true
 			}
 		case utils.DotNetPackages:
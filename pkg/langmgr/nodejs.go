@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -26,11 +28,21 @@ const (
 	defaultToolingNodeTag       = "lts-alpine" // Latest Active LTS (automatically tracks current LTS version)
 	toolingNodeTemplate         = "docker.io/library/node:%s"
 	npmVersionLatest            = "latest" // Fallback npm version when Node.js version is unknown
+	npmBinary                   = "npm"
 )
 
 type nodejsManager struct {
 	config        *buildkit.Config
 	workingFolder string
+	// directOnly restricts updates to packages listed in each app's package.json.
+	directOnly bool
+	// skippedPkgs collects transitive packages left untouched in direct-only mode.
+	skippedPkgs []string
+	// includeDev updates packages that are only devDependencies and keeps them
+	// installed. Otherwise they are skipped and pruned, as in a production install.
+	includeDev bool
+	// yarnPnPApps are the Yarn Plug'n'Play projects, which npm must not install into.
+	yarnPnPApps map[string]bool
 }
 
 // validNodePackageNamePattern defines the regex pattern for valid npm package names
@@ -93,6 +105,15 @@ func safeTarExtractScript(tarballFile, targetDir string) string {
 	)
 }
 
+// npmNetworkFlags returns extra npm install flags that cap concurrent registry
+// connections. It returns an empty string when no limit is configured.
+func npmNetworkFlags(maxSockets int) string {
+	if maxSockets <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" --maxsockets=%d", maxSockets)
+}
+
 // validateNodePackageName validates that a package name is safe for use in shell commands.
 func validateNodePackageName(name string) error {
 	if name == "" {
@@ -176,32 +197,43 @@ func isLessThanNodeVersion(v1, v2 string) bool {
 
 // getDirectDependencies reads a package.json from the image state and returns a set of its direct dependencies.
 func getDirectDependencies(ctx context.Context, c gwclient.Client, st *llb.State, workDir string) (map[string]bool, error) {
-	deps := make(map[string]bool)
+	deps, devOnly, err := getPackageDependencies(ctx, c, st, workDir)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(deps, devOnly)
+	return deps, nil
+}
+
+// getPackageDependencies reads a package.json from the image state and returns the
+// set of its dependencies and the set of its devDependencies that are not also
+// dependencies.
+func getPackageDependencies(ctx context.Context, c gwclient.Client, st *llb.State, workDir string) (deps, devOnly map[string]bool, err error) {
 	pkgJSONPath := filepath.Join(workDir, "package.json")
 
 	reader := st.File(llb.Copy(*st, pkgJSONPath, "/tmp/package.json.out", &llb.CopyInfo{AllowWildcard: true}))
 
 	def, err := reader.Marshal(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal state for reading package.json: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal state for reading package.json: %w", err)
 	}
 
 	// Step 1: Solve the state to get a result.
 	result, err := c.Solve(ctx, gwclient.SolveRequest{Definition: def.ToPB()})
 	if err != nil {
-		return nil, fmt.Errorf("could not solve for package.json in %s: %w", workDir, err)
+		return nil, nil, fmt.Errorf("could not solve for package.json in %s: %w", workDir, err)
 	}
 
 	// Step 2: Get the file reference from the result.
 	ref, err := result.SingleRef()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get reference from solved package.json: %w", err)
+		return nil, nil, fmt.Errorf("failed to get reference from solved package.json: %w", err)
 	}
 
 	// Step 3: Read the file from the reference.
 	data, err := ref.ReadFile(ctx, gwclient.ReadRequest{Filename: "/tmp/package.json.out"})
 	if err != nil {
-		return nil, fmt.Errorf("could not read package.json from %s: %w", workDir, err)
+		return nil, nil, fmt.Errorf("could not read package.json from %s: %w", workDir, err)
 	}
 
 	var pkg struct {
@@ -209,17 +241,21 @@ func getDirectDependencies(ctx context.Context, c gwclient.Client, st *llb.State
 		DevDependencies map[string]string `json:"devDependencies"`
 	}
 	if err := json.Unmarshal(data, &pkg); err != nil {
-		return nil, fmt.Errorf("could not parse package.json from %s: %w", workDir, err)
+		return nil, nil, fmt.Errorf("could not parse package.json from %s: %w", workDir, err)
 	}
 
+	deps = make(map[string]bool, len(pkg.Dependencies))
 	for dep := range pkg.Dependencies {
 		deps[dep] = true
 	}
+	devOnly = make(map[string]bool)
 	for dep := range pkg.DevDependencies {
-		deps[dep] = true
+		if !deps[dep] {
+			devOnly[dep] = true
+		}
 	}
 
-	return deps, nil
+	return deps, devOnly, nil
 }
 
 // hasNpmVulnerabilities checks if any updates target npm's dependencies.
@@ -312,15 +348,7 @@ func extractAppPathsFromUpdates(updates unversioned.LangUpdatePackages) []string
 			continue
 		}
 
-		// Ensure leading slash
-		pkgPath := u.PkgPath
-		if !strings.HasPrefix(pkgPath, "/") {
-			pkgPath = "/" + pkgPath
-		}
-
-		// Find node_modules in path and extract everything before it
-		if idx := strings.Index(pkgPath, "/node_modules/"); idx != -1 {
-			appPath := pkgPath[:idx]
+		if appPath := nodeAppRoot(u.PkgPath); appPath != "" {
 			pathMap[appPath] = true
 		}
 	}
@@ -332,6 +360,33 @@ func extractAppPathsFromUpdates(updates unversioned.LangUpdatePackages) []string
 	return paths
 }
 
+// nodeAppRoot returns the application directory a package at pkgPath is installed
+// in: the directory holding its outermost node_modules, or "" if there is none.
+func nodeAppRoot(pkgPath string) string {
+	// Ensure leading slash
+	if !strings.HasPrefix(pkgPath, "/") {
+		pkgPath = "/" + pkgPath
+	}
+	if idx := strings.Index(pkgPath, "/node_modules/"); idx != -1 {
+		return pkgPath[:idx]
+	}
+	return ""
+}
+
+// updatesForAppRoot returns the updates for packages installed in the app at appRoot,
+// so each app is patched to the versions its own report entries ask for even when
+// another app needs a different version of the same package. Updates without a
+// PkgPath can't be attributed to an app and apply to every app.
+func updatesForAppRoot(appRoot string, updates unversioned.LangUpdatePackages) unversioned.LangUpdatePackages {
+	var appUpdates unversioned.LangUpdatePackages
+	for _, u := range updates {
+		if u.PkgPath == "" || nodeAppRoot(u.PkgPath) == appRoot {
+			appUpdates = append(appUpdates, u)
+		}
+	}
+	return appUpdates
+}
+
 // filterNodePackages returns only the packages that are Node.js packages.
 func filterNodePackages(langUpdates unversioned.LangUpdatePackages) unversioned.LangUpdatePackages {
 	var nodePackages unversioned.LangUpdatePackages
@@ -352,6 +407,11 @@ func (nm *nodejsManager) InstallUpdates(
 ) (*llb.State, []string, error) {
 	var errPkgsReported []string
 
+	// Language packages are only updated from a report; there is no upgrade-all mode.
+	if manifest == nil {
+		return currentState, []string{}, nil
+	}
+
 	// Filter for Node.js packages only
 	nodeUpdates := filterNodePackages(manifest.LangUpdates)
 	if len(nodeUpdates) == 0 {
@@ -391,6 +451,9 @@ func (nm *nodejsManager) InstallUpdates(
 		return currentState, errPkgsReported, nil
 	}
 
+	if skipped := nm.SkippedPackages(); len(skipped) > 0 {
+		log.Infof("Node.js packages skipped in direct-only mode: %v", skipped)
+	}
 	if len(errPkgsReported) > 0 {
 		log.Infof("Node.js packages reported as problematic: %v", errPkgsReported)
 	} else {
@@ -428,6 +491,13 @@ func (nm *nodejsManager) upgradePackages(
 		}
 	}
 
+	// Yarn Plug'n'Play projects have no node_modules for npm to patch, so they are
+	// updated with yarn first, whether or not the image has npm
+	currentState, updates = nm.upgradeYarnPnPApps(ctx, currentState, updates)
+	if len(updates) == 0 {
+		return currentState, nil
+	}
+
 	// Detect if npm exists in the target image
 	npmExists, detectErr := nm.detectNpm(ctx, currentState)
 	if detectErr != nil {
@@ -475,15 +545,26 @@ func (nm *nodejsManager) upgradePackages(
 	appPaths := extractAppPathsFromUpdates(userAppUpdates)
 	if len(appPaths) > 0 {
 		log.Infof("Detected Node.js application paths from vulnerability report: %v", appPaths)
+		// Each app has its own package.json, lockfile and node_modules, so the apps are
+		// updated in parallel branches of the image that are merged afterwards.
+		var appInstalls []func(llb.State) llb.State
 		for _, appPath := range appPaths {
-			if _, err := getDirectDependencies(ctx, nm.config.Client, &updatedState, appPath); err != nil {
+			deps, devOnly, err := getPackageDependencies(ctx, nm.config.Client, currentState, appPath)
+			if err != nil {
 				log.Warnf("Path %s does not appear to be a valid Node.js project (missing package.json?), skipping.", appPath)
 				continue
 			}
 			log.Infof("Updating packages in %s", appPath)
-			// Pass ONLY the user app updates to the installer.
-			updatedState = nm.installNodePackages(ctx, &updatedState, appPath, userAppUpdates)
+			// Pass ONLY this app's updates to the installer.
+			appUpdates := nm.filterDevDependencies(appPath, updatesForAppRoot(appPath, userAppUpdates), devOnly)
+			directDeps := maps.Clone(deps)
+			maps.Copy(directDeps, devOnly)
+			appUpdates = nm.filterDirectOnly(appPath, appUpdates, directDeps)
+			appInstalls = append(appInstalls, func(base llb.State) llb.State {
+				return nm.installNodePackages(ctx, &base, appPath, appUpdates)
+			})
 		}
+		updatedState = updateNodeBranches(*currentState, nm.config.MaxConcurrentDownloads, appInstalls)
 	} else {
 		log.Debug("No user application vulnerabilities found to patch.")
 	}
@@ -510,7 +591,7 @@ func (nm *nodejsManager) upgradePackages(
 	log.Info("Aggressively cleaning npm cache and removing all cached package files")
 	cleanupCmd := `sh -c '` +
 		// Run npm cache clean (may fail if npm not available, that's ok)
-		`npm cache clean --force 2>&1 || echo "WARN: npm cache clean command failed"; ` +
+		nm.npm() + ` cache clean --force 2>&1 || echo "WARN: npm cache clean command failed"; ` +
 		// Find and remove ALL .npm cache directories across the entire filesystem
 		`find / -type d -path "*/.npm/_cacache" -prune -exec rm -rf {} \; 2>&1 || echo "WARN: find command for .npm/_cacache failed"; ` +
 		// Also target common known cache locations explicitly
@@ -519,11 +600,78 @@ func (nm *nodejsManager) upgradePackages(
 		`rm -rf /home/*/.npm 2>&1 || echo "WARN: Failed to remove /home/*/.npm"; ` +
 		// Remove npm's global cache if it exists
 		`rm -rf /tmp/npm-* 2>&1 || echo "WARN: Failed to remove /tmp/npm-*"'`
-	updatedState = updatedState.Run(llb.Shlex(cleanupCmd), llb.WithProxy(utils.GetProxy())).Root()
+	updatedState = updatedState.Run(llb.Shlex(cleanupCmd), utils.WithProxy()).Root()
 
 	return &updatedState, nil
 }
 
+// SkippedPackages returns the transitive packages left untouched in direct-only mode.
+func (nm *nodejsManager) SkippedPackages() []string {
+	return utils.DeduplicateStringSlice(nm.skippedPkgs)
+}
+
+// filterDirectOnly drops updates for packages that are not direct dependencies of
+// the app at appPath when direct-only mode is enabled, recording them as skipped.
+func (nm *nodejsManager) filterDirectOnly(
+	appPath string,
+	updates unversioned.LangUpdatePackages,
+	directDeps map[string]bool,
+) unversioned.LangUpdatePackages {
+	if !nm.directOnly {
+		return updates
+	}
+	var direct unversioned.LangUpdatePackages
+	var skipped []string
+	for _, u := range updates {
+		if directDeps[u.Name] {
+			direct = append(direct, u)
+		} else {
+			skipped = append(skipped, u.Name)
+		}
+	}
+	if len(skipped) > 0 {
+		log.Infof("Direct-only mode: skipping %d transitive package(s) in %s: %v", len(skipped), appPath, skipped)
+		nm.skippedPkgs = append(nm.skippedPkgs, skipped...)
+	}
+	return direct
+}
+
+// filterDevDependencies drops updates for packages that are only devDependencies of
+// the app at appPath unless devDependencies are included. Those packages are pruned
+// from the app rather than updated.
+func (nm *nodejsManager) filterDevDependencies(
+	appPath string,
+	updates unversioned.LangUpdatePackages,
+	devOnly map[string]bool,
+) unversioned.LangUpdatePackages {
+	if nm.includeDev {
+		return updates
+	}
+	var kept unversioned.LangUpdatePackages
+	var skipped []string
+	for _, u := range updates {
+		if devOnly[u.Name] {
+			skipped = append(skipped, u.Name)
+		} else {
+			kept = append(kept, u)
+		}
+	}
+	if len(skipped) > 0 {
+		log.Infof("Skipping %d devDependencies in %s, which are pruned instead (use --include-dev-dependencies to update them): %v",
+			len(skipped), appPath, skipped)
+	}
+	return kept
+}
+
+// npmOmitDevFlag returns the npm flag that leaves devDependencies out of an install
+// or prune, or "" when they are included.
+func (nm *nodejsManager) npmOmitDevFlag() string {
+	if nm.includeDev {
+		return ""
+	}
+	return " --omit=dev"
+}
+
 // shellQuote wraps s in single quotes and escapes embedded single quotes so it can be
 // safely passed as a shell argument.
 func shellQuote(s string) string {
@@ -552,21 +700,27 @@ func (nm *nodejsManager) installNodePackages(
 
 	// Remove devDependencies from package.json to prevent them from being installed
 	// This ensures production images don't include development dependencies
-	log.Debugf("Removing devDependencies from package.json in %s", workDir)
-	removeDevDepsCmd := fmt.Sprintf(
-		`sh -c 'cd -- "$1" && `+
-			`node -e "const fs=require('\''fs'\''); const pkg=JSON.parse(fs.readFileSync('\''package.json'\'')); `+
-			`delete pkg.devDependencies; fs.writeFileSync('\''package.json'\'', JSON.stringify(pkg, null, 2));"' -- %s`,
-		shellQuote(workDir),
-	)
-	state = state.Run(llb.Shlex(removeDevDepsCmd), llb.WithProxy(utils.GetProxy())).Root()
+	if !nm.includeDev {
+		log.Debugf("Removing devDependencies from package.json in %s", workDir)
+		removeDevDepsCmd := fmt.Sprintf(
+			`sh -c 'cd -- "$1" && `+
+				`node -e "const fs=require('\''fs'\''); const pkg=JSON.parse(fs.readFileSync('\''package.json'\'')); `+
+				`delete pkg.devDependencies; fs.writeFileSync('\''package.json'\'', JSON.stringify(pkg, null, 2));"' -- %s`,
+			shellQuote(workDir),
+		)
+		state = state.Run(llb.Shlex(removeDevDepsCmd), utils.WithProxy()).Root()
+	}
 
 	var transitiveUpdates unversioned.LangUpdatePackages
 
 	// == Step 1: Replace Direct Dependencies via Tarball Download ==
 	// Use direct tarball replacement instead of npm install to avoid
 	// re-resolving the dependency tree and introducing new vulnerabilities.
+	// Each direct dependency is its own top-level node_modules directory and the
+	// lockfile is only written by the final cleanup, so the replacements are
+	// independent branches of state that BuildKit can run concurrently.
 	log.Infof("Processing direct dependency updates for %s...", workDir)
+	var directReplaces []func(llb.State) llb.State
 	for _, u := range updates {
 		if directDeps[u.Name] {
 			if u.FixedVersion == "" {
@@ -606,19 +760,24 @@ func (nm *nodejsManager) installNodePackages(
 			)
 
 			log.Infof("Replacing direct dependency %s@%s in %s", u.Name, u.FixedVersion, workDir)
-			state = state.Run(
-				llb.Shlex(replaceCmd),
-				llb.WithProxy(utils.GetProxy()),
-			).Root()
+			directReplaces = append(directReplaces, func(base llb.State) llb.State {
+				return base.Run(
+					llb.Shlex(replaceCmd),
+					utils.WithProxy(),
+				).Root()
+			})
 		} else {
 			transitiveUpdates = append(transitiveUpdates, u)
 		}
 	}
+	state = updateNodeBranches(state, nm.config.MaxConcurrentDownloads, directReplaces)
 
 	// == Step 2: Replace Transitive Dependencies via Direct Tarball Download ==
 	// Instead of using npm overrides + npm install (which re-resolves the entire
 	// dependency tree and can introduce new vulnerabilities), we directly download
 	// and extract package tarballs from the npm registry into node_modules.
+	// These run one after another: a package can be nested in another's
+	// node_modules, so their replacements may overlap.
 	if len(transitiveUpdates) > 0 {
 		log.Infof("Processing %d transitive dependency update(s) for %s via direct tarball replacement...", len(transitiveUpdates), workDir)
 
@@ -667,7 +826,7 @@ func (nm *nodejsManager) installNodePackages(
 			log.Infof("Replacing transitive dependency %s@%s in %s", u.Name, u.FixedVersion, workDir)
 			state = state.Run(
 				llb.Shlex(replaceCmd),
-				llb.WithProxy(utils.GetProxy()),
+				utils.WithProxy(),
 			).Root()
 		}
 	}
@@ -676,22 +835,67 @@ func (nm *nodejsManager) installNodePackages(
 	log.Infof("Running final cleanup for %s...", workDir)
 	cleanupCmd := fmt.Sprintf(
 		`sh -c 'cd -- "$1" && `+
-			`npm prune --omit=dev --legacy-peer-deps 2>&1 | grep -v "^npm warn" || true && `+
-			`npm dedupe --omit=dev --legacy-peer-deps 2>&1 | grep -v "^npm warn" || true && `+
-			`(rm -rf /root/.npm ~/.npm /home/*/.npm /tmp/npm-* 2>&1 || echo "WARN: Cache cleanup failed")' -- %s`,
-		shellQuote(workDir),
+			`%[1]s prune%[3]s --legacy-peer-deps 2>&1 | grep -v "^npm warn" || true && `+
+			`%[1]s dedupe%[3]s --legacy-peer-deps 2>&1 | grep -v "^npm warn" || true && `+
+			`(rm -rf /root/.npm ~/.npm /home/*/.npm /tmp/npm-* 2>&1 || echo "WARN: Cache cleanup failed")' -- %[2]s`,
+		nm.npm(), shellQuote(workDir), nm.npmOmitDevFlag(),
 	)
 	state = state.Run(
 		llb.Shlex(cleanupCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	return state
 }
 
+// updateNodeBranches applies updates, each deriving a state from the one it is given,
+// to base. Without a limit they all branch off base and are merged, so BuildKit runs
+// them concurrently. Each update downloads packages, so with --max-concurrent-downloads
+// at most limit of them branch off the same state: every batch is merged before the
+// next one starts from the result, and a limit of 1 is a serial chain.
+func updateNodeBranches(base llb.State, limit int, updates []func(llb.State) llb.State) llb.State {
+	if len(updates) == 0 {
+		return base
+	}
+	if limit <= 0 {
+		limit = len(updates)
+	}
+	state := base
+	for batch := range slices.Chunk(updates, limit) {
+		branches := make([]llb.State, 0, len(batch))
+		for _, update := range batch {
+			branches = append(branches, update(state))
+		}
+		state = mergeNodeBranches(state, branches)
+	}
+	return state
+}
+
+// mergeNodeBranches combines states that were each derived from base by independent
+// updates. Only the changes of each branch are merged onto base, so BuildKit can
+// build the branches concurrently instead of as one serial chain.
+func mergeNodeBranches(base llb.State, branches []llb.State) llb.State {
+	switch len(branches) {
+	case 0:
+		return base
+	case 1:
+		return branches[0]
+	}
+	layers := []llb.State{base}
+	for _, branch := range branches {
+		layers = append(layers, llb.Diff(base, branch))
+	}
+	return llb.Merge(layers, llb.WithCustomName("Merging Node.js package updates"))
+}
+
+// npm returns the command that invokes npm inside the target image.
+func (nm *nodejsManager) npm() string {
+	return nm.config.PkgMgrBinary(npmBinary)
+}
+
 // detectNpm checks if npm exists in the target image.
 func (nm *nodejsManager) detectNpm(ctx context.Context, currentState *llb.State) (bool, error) {
-	checkCmd := `sh -c 'if command -v npm >/dev/null 2>&1; then echo ok > ` + npmCheckFile + `; fi'`
+	checkCmd := `sh -c 'if command -v ` + nm.npm() + ` >/dev/null 2>&1; then echo ok > ` + npmCheckFile + `; fi'`
 	checked := currentState.Run(llb.Shlex(checkCmd)).Root()
 	_, err := buildkit.ExtractFileFromState(ctx, nm.config.Client, &checked, npmCheckFile)
 	if err != nil {
@@ -754,10 +958,10 @@ func (nm *nodejsManager) detectGlobalNodeModules(ctx context.Context, currentSta
 	// Find global node_modules path using npm root -g
 	// Then find all root-level packages (depth 1) with package.json
 	findCmd := fmt.Sprintf(
-		`sh -c 'if command -v npm >/dev/null 2>&1; then globalRoot=$(npm root -g 2>/dev/null); `+
+		`sh -c 'if command -v %[1]s >/dev/null 2>&1; then globalRoot=$(%[1]s root -g 2>/dev/null); `+
 			`if [ -d "$globalRoot" ]; then find "$globalRoot" -mindepth 1 -maxdepth 1 -type d `+
-			`-exec sh -c "[ -f \"{}/package.json\" ] && echo \"{} \"" \; | tr -d \"\\n\" > %s; fi; fi'`,
-		globalNodeModulesDetectFile,
+			`-exec sh -c "[ -f \"{}/package.json\" ] && echo \"{} \"" \; | tr -d \"\\n\" > %[2]s; fi; fi'`,
+		nm.npm(), globalNodeModulesDetectFile,
 	)
 
 	detected := currentState.Run(llb.Shlex(findCmd)).Root()
@@ -814,11 +1018,31 @@ func (nm *nodejsManager) upgradePackagesWithTooling(
 
 	// For each package.json location, use a tooling container to update packages
 	for _, pkgPath := range pkgJSONPaths {
+		if nm.yarnPnPApps[pkgPath] {
+			continue
+		}
 		log.Infof("Attempting to update packages in %s using tooling container", pkgPath)
 
+		appUpdates := updatesForAppRoot(pkgPath, updates)
+		if nm.directOnly || !nm.includeDev {
+			deps, devOnly, err := getPackageDependencies(ctx, nm.config.Client, &state, pkgPath)
+			switch {
+			case err == nil:
+				// npm install --save would move a devDependency to dependencies
+				appUpdates = nm.filterDevDependencies(pkgPath, appUpdates, devOnly)
+				maps.Copy(deps, devOnly)
+				appUpdates = nm.filterDirectOnly(pkgPath, appUpdates, deps)
+			case nm.directOnly:
+				log.Warnf("Could not read direct dependencies for %s, skipping in direct-only mode: %v", pkgPath, err)
+				continue
+			default:
+				log.Warnf("Could not read the devDependencies of %s, updating all of its packages: %v", pkgPath, err)
+			}
+		}
+
 		// Build install command in tooling container
 		var pkgSpecs []string
-		for _, u := range updates {
+		for _, u := range appUpdates {
 			if u.FixedVersion != "" {
 				pkgSpecs = append(pkgSpecs, fmt.Sprintf("%s@%s", u.Name, u.FixedVersion))
 			}
@@ -829,9 +1053,10 @@ func (nm *nodejsManager) upgradePackagesWithTooling(
 		}
 
 		// Copy package.json and package-lock.json to tooling container, install, then copy back
+		npmFlags := npmNetworkFlags(nm.config.MaxConcurrentDownloads)
 		toolingInstallCmd := fmt.Sprintf(
-			`sh -c 'npm install --save --save-exact --no-audit --timeout=%d %s && npm install --package-lock-only --no-audit'`,
-			npmInstallTimeoutSeconds, strings.Join(pkgSpecs, " "))
+			`sh -c 'npm install --save --save-exact --no-audit --timeout=%d%s%s %s && npm install --package-lock-only --no-audit%s'`,
+			npmInstallTimeoutSeconds, npmFlags, nm.npmOmitDevFlag(), strings.Join(pkgSpecs, " "), npmFlags)
 
 		// Create a tooling state that copies the package files, installs, and we copy back
 		toolingState := llb.Image(toolingImage)
@@ -843,7 +1068,7 @@ func (nm *nodejsManager) upgradePackagesWithTooling(
 		)
 		toolingState = toolingState.Dir("/app").Run(
 			llb.Shlex(toolingInstallCmd),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 
 		// Copy the updated node_modules and package files back
@@ -908,7 +1133,7 @@ func (nm *nodejsManager) upgradeGlobalPackages(
 			safeExtractScript := safeTarExtractScript(tarballFile, "\"$dir\"")
 
 			replaceCmd := fmt.Sprintf(
-				`sh -c 'NPM_ROOT=$(npm root -g) && `+
+				`sh -c 'NPM_ROOT=$(%s root -g) && `+
 					`if %s; then `+
 					`  PKG_NAME="%s" && `+
 					`  FOUND=0 && `+
@@ -926,6 +1151,7 @@ func (nm *nodejsManager) upgradeGlobalPackages(
 					`else `+
 					`  echo "WARN: failed to download %s@%s, skipping"; `+
 					`fi'`,
+				nm.npm(),
 				downloadScript,
 				u.Name,
 				u.FixedVersion,
@@ -938,7 +1164,7 @@ func (nm *nodejsManager) upgradeGlobalPackages(
 			log.Infof("Replacing %s@%s within npm's node_modules", u.Name, u.FixedVersion)
 			state = state.Run(
 				llb.Shlex(replaceCmd),
-				llb.WithProxy(utils.GetProxy()),
+				utils.WithProxy(),
 			).Root()
 		}
 
@@ -1009,7 +1235,7 @@ func (nm *nodejsManager) upgradeGlobalPackages(
 				`delete pkg.devDependencies; fs.writeFileSync('\''package.json'\'', JSON.stringify(pkg, null, 2));"' -- %s`,
 			shellQuote(pkgPath),
 		)
-		state = state.Run(llb.Shlex(removeDevDepsCmd), llb.WithProxy(utils.GetProxy())).Root()
+		state = state.Run(llb.Shlex(removeDevDepsCmd), utils.WithProxy()).Root()
 
 		// Separate into direct and transitive updates
 		var directUpdates unversioned.LangUpdatePackages
@@ -1067,7 +1293,7 @@ func (nm *nodejsManager) upgradeGlobalPackages(
 				log.Infof("Replacing direct dependency %s@%s in global package %s", u.Name, u.FixedVersion, pkgName)
 				state = state.Run(
 					llb.Shlex(replaceCmd),
-					llb.WithProxy(utils.GetProxy()),
+					utils.WithProxy(),
 				).Root()
 			}
 		}
@@ -1119,7 +1345,7 @@ func (nm *nodejsManager) upgradeGlobalPackages(
 				log.Infof("Replacing transitive dependency %s@%s in global package %s", u.Name, u.FixedVersion, pkgName)
 				state = state.Run(
 					llb.Shlex(replaceCmd),
-					llb.WithProxy(utils.GetProxy()),
+					utils.WithProxy(),
 				).Root()
 			}
 		}
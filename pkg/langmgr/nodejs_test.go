@@ -2,13 +2,19 @@ package langmgr
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/project-copacetic/copacetic/mocks"
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 	"github.com/project-copacetic/copacetic/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidateNodePackageName(t *testing.T) {
@@ -347,6 +353,248 @@ func TestGetNpmTarballURL(t *testing.T) {
 	}
 }
 
+func TestNpmNetworkFlags(t *testing.T) {
+	assert.Equal(t, "", npmNetworkFlags(0))
+	assert.Equal(t, "", npmNetworkFlags(-3))
+	assert.Equal(t, " --maxsockets=4", npmNetworkFlags(4))
+}
+
+func TestFilterDirectOnly(t *testing.T) {
+	updates := unversioned.LangUpdatePackages{
+		{Name: "express", FixedVersion: "4.19.2"},
+		{Name: "qs", FixedVersion: "6.11.0"},
+		{Name: "jest", FixedVersion: "29.7.0"},
+	}
+	directDeps := map[string]bool{"express": true, "jest": true}
+
+	t.Run("disabled keeps all updates", func(t *testing.T) {
+		nm := &nodejsManager{}
+		assert.Equal(t, updates, nm.filterDirectOnly("/app", updates, directDeps))
+		assert.Empty(t, nm.skippedPkgs)
+	})
+
+	t.Run("enabled skips transitive packages", func(t *testing.T) {
+		nm := &nodejsManager{directOnly: true}
+		got := nm.filterDirectOnly("/app", updates, directDeps)
+		assert.Equal(t, unversioned.LangUpdatePackages{updates[0], updates[2]}, got)
+		assert.Equal(t, []string{"qs"}, nm.skippedPkgs)
+	})
+
+	t.Run("skipped packages are reported once", func(t *testing.T) {
+		nm := &nodejsManager{directOnly: true}
+		nm.filterDirectOnly("/app", updates, directDeps)
+		nm.filterDirectOnly("/other", updates, directDeps)
+		var reporter SkippedPackagesReporter = nm
+		assert.Equal(t, []string{"qs"}, reporter.SkippedPackages())
+	})
+}
+
+func TestFilterDevDependencies(t *testing.T) {
+	updates := unversioned.LangUpdatePackages{
+		{Name: "express", FixedVersion: "4.19.2"},
+		{Name: "jest", FixedVersion: "29.7.0"},
+		{Name: "qs", FixedVersion: "6.11.0"},
+	}
+	devOnly := map[string]bool{"jest": true}
+
+	t.Run("skipped by default", func(t *testing.T) {
+		nm := &nodejsManager{}
+		assert.Equal(t, unversioned.LangUpdatePackages{updates[0], updates[2]}, nm.filterDevDependencies("/app", updates, devOnly))
+		// They are pruned, not left vulnerable
+		assert.Empty(t, nm.skippedPkgs)
+	})
+
+	t.Run("included", func(t *testing.T) {
+		nm := &nodejsManager{includeDev: true}
+		assert.Equal(t, updates, nm.filterDevDependencies("/app", updates, devOnly))
+	})
+}
+
+func TestInstallNodePackagesDevDependencies(t *testing.T) {
+	tests := []struct {
+		name        string
+		includeDev  bool
+		wantPrune   string
+		wantDevDrop bool
+	}{
+		{name: "production install", wantPrune: "npm prune --omit=dev ", wantDevDrop: true},
+		{name: "dev dependencies included", includeDev: true, wantPrune: "npm prune --legacy-peer-deps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mocks.MockGWClient)
+			mockRef := new(mocks.MockReference)
+			mockResult := &gwclient.Result{}
+			mockResult.SetRef(mockRef)
+			mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+			mockRef.On("ReadFile", mock.Anything, mock.Anything).
+				Return([]byte(`{"dependencies":{"express":"^4.18.0"},"devDependencies":{"jest":"^29.0.0"}}`), nil)
+
+			nm := &nodejsManager{config: &buildkit.Config{Client: mockClient}, includeDev: tt.includeDev}
+			st := llb.Image("node:20-alpine")
+			updates := unversioned.LangUpdatePackages{{Name: "express", FixedVersion: "4.19.2"}}
+			got := nm.installNodePackages(context.Background(), &st, "/app", updates)
+
+			def, err := got.Marshal(context.Background())
+			require.NoError(t, err)
+			var scripts []string
+			for _, args := range execArgs(t, def.ToPB()) {
+				scripts = append(scripts, strings.Join(args, " "))
+			}
+			all := strings.Join(scripts, "\n")
+			assert.Contains(t, all, tt.wantPrune)
+			assert.Equal(t, tt.wantDevDrop, strings.Contains(all, "delete pkg.devDependencies"))
+		})
+	}
+}
+
+func TestInstallNodePackagesParallelDirectUpdates(t *testing.T) {
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).
+		Return([]byte(`{"dependencies":{"express":"^4.18.0","lodash":"^4.17.0","qs":"^6.0.0"}}`), nil)
+
+	nm := &nodejsManager{config: &buildkit.Config{Client: mockClient}, includeDev: true}
+	st := llb.Image("node:20-alpine")
+	updates := unversioned.LangUpdatePackages{
+		{Name: "express", FixedVersion: "4.19.2"},
+		{Name: "lodash", FixedVersion: "4.17.21"},
+		{Name: "qs", FixedVersion: "6.11.0"},
+		{Name: "minimist", FixedVersion: "1.2.8"},
+	}
+	got := nm.installNodePackages(context.Background(), &st, "/app", updates)
+
+	def, err := got.Marshal(context.Background())
+	require.NoError(t, err)
+
+	var merges, diffs int
+	replaceInputs := map[string]bool{}
+	for _, dt := range def.ToPB().Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		switch {
+		case op.GetMerge() != nil:
+			merges++
+			assert.Len(t, op.GetMerge().GetInputs(), 4, "base plus one diff per direct dependency")
+		case op.GetDiff() != nil:
+			diffs++
+		case op.GetExec() != nil:
+			script := strings.Join(op.GetExec().GetMeta().GetArgs(), " ")
+			if strings.Contains(script, "Replacing direct dependency") {
+				replaceInputs[op.GetInputs()[0].GetDigest()] = true
+			}
+		}
+	}
+	assert.Equal(t, 1, merges)
+	assert.Equal(t, 3, diffs)
+	// Every direct replacement starts from the same state rather than the previous one
+	assert.Len(t, replaceInputs, 1)
+}
+
+func TestInstallNodePackagesDownloadLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        int
+		wantMerges   int
+		wantReplaces int // distinct states the direct replacements start from
+	}{
+		{name: "serial with a limit of 1", limit: 1, wantMerges: 0, wantReplaces: 3},
+		{name: "batches of 2", limit: 2, wantMerges: 1, wantReplaces: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mocks.MockGWClient)
+			mockRef := new(mocks.MockReference)
+			mockResult := &gwclient.Result{}
+			mockResult.SetRef(mockRef)
+			mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+			mockRef.On("ReadFile", mock.Anything, mock.Anything).
+				Return([]byte(`{"dependencies":{"express":"^4.18.0","lodash":"^4.17.0","qs":"^6.0.0"}}`), nil)
+
+			nm := &nodejsManager{config: &buildkit.Config{Client: mockClient, MaxConcurrentDownloads: tt.limit}, includeDev: true}
+			st := llb.Image("node:20-alpine")
+			updates := unversioned.LangUpdatePackages{
+				{Name: "express", FixedVersion: "4.19.2"},
+				{Name: "lodash", FixedVersion: "4.17.21"},
+				{Name: "qs", FixedVersion: "6.11.0"},
+			}
+			got := nm.installNodePackages(context.Background(), &st, "/app", updates)
+
+			def, err := got.Marshal(context.Background())
+			require.NoError(t, err)
+
+			var merges int
+			replaceInputs := map[string]bool{}
+			for _, dt := range def.ToPB().Def {
+				var op pb.Op
+				require.NoError(t, op.UnmarshalVT(dt))
+				switch {
+				case op.GetMerge() != nil:
+					merges++
+					assert.LessOrEqual(t, len(op.GetMerge().GetInputs()), tt.limit+1, "base plus at most limit diffs")
+				case op.GetExec() != nil:
+					script := strings.Join(op.GetExec().GetMeta().GetArgs(), " ")
+					if strings.Contains(script, "Replacing direct dependency") {
+						replaceInputs[op.GetInputs()[0].GetDigest()] = true
+					}
+				}
+			}
+			assert.Equal(t, tt.wantMerges, merges)
+			assert.Len(t, replaceInputs, tt.wantReplaces)
+		})
+	}
+}
+
+func TestMergeNodeBranches(t *testing.T) {
+	base := llb.Image("node:20-alpine")
+	branch := base.Run(llb.Shlex("true")).Root()
+
+	// Nothing to merge for zero or one branch
+	assert.Equal(t, base.Output(), mergeNodeBranches(base, nil).Output())
+	assert.Equal(t, branch.Output(), mergeNodeBranches(base, []llb.State{branch}).Output())
+}
+
+func TestUpdatesForAppRoot(t *testing.T) {
+	updates := unversioned.LangUpdatePackages{
+		{Name: "lodash", FixedVersion: "4.17.21", PkgPath: "srv/api/node_modules/lodash/package.json"},
+		{Name: "lodash", FixedVersion: "4.17.12", PkgPath: "srv/web/node_modules/lodash/package.json"},
+		{Name: "qs", FixedVersion: "6.11.0", PkgPath: "srv/web/node_modules/express/node_modules/qs/package.json"},
+		{Name: "express", FixedVersion: "4.19.2"},
+	}
+
+	// Each app only gets the versions reported for its own node_modules, plus the
+	// updates that can't be attributed to an app.
+	assert.Equal(t, unversioned.LangUpdatePackages{updates[0], updates[3]}, updatesForAppRoot("/srv/api", updates))
+	assert.Equal(t, unversioned.LangUpdatePackages{updates[1], updates[2], updates[3]}, updatesForAppRoot("/srv/web", updates))
+	assert.Equal(t, unversioned.LangUpdatePackages{updates[3]}, updatesForAppRoot("/app", updates))
+
+	// The dedupe keeps the two lodash entries apart, so neither app's version
+	// overrides the other's.
+	nodeComparer := VersionComparer{isValidNodeVersion, isLessThanNodeVersion}
+	unique, err := GetUniqueLatestUpdates(updates, nodeComparer, false)
+	require.NoError(t, err)
+	lodashVersion := func(appRoot string) string {
+		for _, u := range updatesForAppRoot(appRoot, unique) {
+			if u.Name == "lodash" {
+				return u.FixedVersion
+			}
+		}
+		return ""
+	}
+	assert.Equal(t, "4.17.21", lodashVersion("/srv/api"))
+	assert.Equal(t, "4.17.12", lodashVersion("/srv/web"))
+}
+
+func TestNodeAppRoot(t *testing.T) {
+	assert.Equal(t, "/var/lib/ghost/versions/6.2.0", nodeAppRoot("var/lib/ghost/versions/6.2.0/node_modules/@babel/runtime/package.json"))
+	assert.Equal(t, "/app", nodeAppRoot("/app/node_modules/a/node_modules/b/package.json"))
+	assert.Equal(t, "", nodeAppRoot("usr/local/bin/node"))
+}
+
 func TestShellQuote(t *testing.T) {
 	tests := []struct {
 		name string
@@ -371,3 +619,56 @@ func TestShellQuote(t *testing.T) {
 		})
 	}
 }
+
+// execArgs returns the args of every exec op in the definition.
+func execArgs(t *testing.T, def *pb.Definition) [][]string {
+	t.Helper()
+	var args [][]string
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		if exec := op.GetExec(); exec != nil {
+			args = append(args, exec.GetMeta().GetArgs())
+		}
+	}
+	return args
+}
+
+func TestNodejsManagerNpmPath(t *testing.T) {
+	const npmPath = "/opt/node/bin/npm"
+
+	tests := []struct {
+		name        string
+		pkgMgrPaths map[string]string
+		want        string
+	}{
+		{name: "PATH lookup by default", want: "command -v npm >/dev/null"},
+		{name: "configured path", pkgMgrPaths: map[string]string{"npm": npmPath}, want: "command -v " + npmPath + " >/dev/null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := new(mocks.MockGWClient)
+			mockRef := new(mocks.MockReference)
+			mockResult := &gwclient.Result{}
+			mockResult.SetRef(mockRef)
+			var req gwclient.SolveRequest
+			mockClient.On("Solve", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				req = args.Get(1).(gwclient.SolveRequest)
+			}).Return(mockResult, nil)
+			mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("ok\n"), nil)
+
+			nm := &nodejsManager{config: &buildkit.Config{Client: mockClient, PkgMgrPaths: tt.pkgMgrPaths}}
+			st := llb.Image("node:20-alpine")
+			found, err := nm.detectNpm(context.Background(), &st)
+			require.NoError(t, err)
+			assert.True(t, found)
+
+			require.NotNil(t, req.Definition)
+			args := execArgs(t, req.Definition)
+			require.Len(t, args, 1)
+			assert.Equal(t, []string{"sh", "-c"}, args[0][:2])
+			assert.Contains(t, args[0][2], tt.want)
+		})
+	}
+}
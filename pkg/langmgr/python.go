@@ -340,6 +340,11 @@ func (pm *pythonManager) InstallUpdates(
 ) (*llb.State, []string, error) {
 	var errPkgsReported []string // Packages that will be reported as problematic
 
+	// Language packages are only updated from a report; there is no upgrade-all mode.
+	if manifest == nil {
+		return currentState, []string{}, nil
+	}
+
 	// Filter for Python packages only
 	pythonUpdates := filterPythonPackages(manifest.LangUpdates)
 	if len(pythonUpdates) == 0 {
@@ -781,7 +786,7 @@ func (pm *pythonManager) installPythonPackages(currentState *llb.State, packageS
 		installCmd := fmt.Sprintf(`sh -c '%s'`, strings.Join(installCommands, "; "))
 		return currentState.Run(
 			llb.Shlex(installCmd),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	}
 	// Standard single command install (fail-fast)
@@ -789,7 +794,7 @@ func (pm *pythonManager) installPythonPackages(currentState *llb.State, packageS
 	args = append(args, packageSpecs...)
 	return currentState.Run(
 		llb.Args(args),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 }
 
@@ -835,14 +840,14 @@ func (pm *pythonManager) installPythonPackagesWithPip(currentState *llb.State, p
 		args = append(args, packageSpecs...)
 		return currentState.Run(
 			llb.Args(args),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	}
 	args := []string{pipPath, "install", fmt.Sprintf("--timeout=%d", defaultPipInstallTimeoutSeconds)}
 	args = append(args, packageSpecs...)
 	return currentState.Run(
 		llb.Args(args),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 }
 
@@ -894,7 +899,7 @@ func (pm *pythonManager) upgradePackagesToSitePackagesDir(
 	pipInstallArgs = append(pipInstallArgs, installPkgSpecs...)
 	toolingState := llb.Image(toolingImage).Run(
 		llb.Args(pipInstallArgs),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Build package base names (lowercase, hyphens) for cleanup.
@@ -1042,7 +1047,7 @@ func (pm *pythonManager) upgradeVenvPackagesWithTooling(
 	pipInstallArgs = append(pipInstallArgs, installPkgSpecs...)
 	toolingState := llb.Image(toolingImage).Run(
 		llb.Args(pipInstallArgs),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Clean old package directories then copy the new ones in.
@@ -1098,7 +1103,7 @@ func (pm *pythonManager) upgradeVendorParents(
 		args = append(args, parents...)
 		upgraded := currentState.Run(
 			llb.Args(args),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 		return &upgraded, nil
 	}
@@ -1126,7 +1131,7 @@ func (pm *pythonManager) upgradeVendorParents(
 	args = append(args, parents...)
 	upgraded := currentState.Run(
 		llb.Args(args),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 	return &upgraded, nil
 }
@@ -1216,7 +1221,7 @@ func (pm *pythonManager) upgradePackagesWithTooling(
 	toolingInstallCmd := fmt.Sprintf("sh -c 'pip install --no-cache-dir --disable-pip-version-check --no-deps --target /copa-pkgs %s'", strings.Join(installPkgSpecs, " "))
 	toolingState := llb.Image(toolingImage).Run(
 		llb.Shlex(toolingInstallCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Clean old versions of these packages in the detected site-packages path before copying new ones
@@ -584,7 +584,7 @@ func TestPythonManagerType(t *testing.T) {
 			},
 		},
 	}
-	managers := GetLanguageManagers(config, workingFolder, manifest, "")
+	managers := GetLanguageManagers(config, workingFolder, manifest, Options{})
 	require.Len(t, managers, 1)
 
 	pythonMgr, ok := managers[0].(*pythonManager)
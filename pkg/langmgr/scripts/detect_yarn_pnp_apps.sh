@@ -0,0 +1,20 @@
+# Lists the Yarn Plug'n'Play projects found under the given directories.
+# A PnP project has a package.json and no node_modules, and is marked by a
+# .pnp.cjs/.pnp.js loader, a .yarn directory or a yarnPath in .yarnrc.yml.
+# Usage: detect_yarn_pnp_apps.sh <output file> <dir>...
+out="$1"
+shift
+
+apps=""
+for pkg in $(find "$@" -maxdepth 6 -type f -name package.json 2>/dev/null | grep -v -e "/node_modules/" -e "/.yarn/" | sort -u); do
+    dir="$(dirname "$pkg")"
+    if [ -d "$dir/node_modules" ]; then
+        continue
+    fi
+    if [ -f "$dir/.pnp.cjs" ] || [ -f "$dir/.pnp.js" ] || [ -d "$dir/.yarn" ] ||
+        grep -q "^yarnPath:" "$dir/.yarnrc.yml" 2>/dev/null; then
+        apps="$apps $dir"
+    fi
+done
+
+echo "$apps" > "$out"
@@ -0,0 +1,31 @@
+# Updates a Yarn Plug'n'Play project in place.
+# Direct dependencies are passed as name@version arguments to yarn up. Transitive
+# dependencies are passed as a JSON object in COPA_YARN_RESOLUTIONS and pinned
+# through the resolutions field of package.json.
+# Usage: yarn_pnp_update.sh <project dir> [name@version]...
+set -e
+
+cd -- "$1"
+shift
+
+# Prefer the yarn release checked into the project, then corepack
+yarn_path="$(sed -n 's/^yarnPath:[[:space:]]*//p' .yarnrc.yml 2>/dev/null | tr -d "\"'")"
+if [ -n "$yarn_path" ] && [ -f "$yarn_path" ]; then
+    yarn() { node "$yarn_path" "$@"; }
+elif ! command -v yarn >/dev/null 2>&1 && command -v corepack >/dev/null 2>&1; then
+    yarn() { corepack yarn "$@"; }
+fi
+
+if [ "$#" -gt 0 ]; then
+    yarn up "$@"
+fi
+
+if [ -n "$COPA_YARN_RESOLUTIONS" ]; then
+    node -e '
+const fs = require("fs");
+const pkg = JSON.parse(fs.readFileSync("package.json", "utf8"));
+pkg.resolutions = Object.assign(pkg.resolutions || {}, JSON.parse(process.env.COPA_YARN_RESOLUTIONS));
+fs.writeFileSync("package.json", JSON.stringify(pkg, null, 2) + "\n");
+'
+    yarn install
+fi
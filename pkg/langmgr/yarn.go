@@ -0,0 +1,157 @@
+package langmgr
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+//go:embed scripts/detect_yarn_pnp_apps.sh
+var detectYarnPnPAppsScript string
+
+//go:embed scripts/yarn_pnp_update.sh
+var yarnPnPUpdateScript string
+
+const yarnPnPDetectFile = "/copa-yarn-pnp-apps"
+
+// yarnPnPSearchDirs are the directories searched for Yarn Plug'n'Play projects.
+var yarnPnPSearchDirs = []string{"/app", "/usr/src/app", "/workspace", "/opt", "/home", "/srv", "/var"}
+
+// detectYarnPnPApps returns the directories of the Yarn Plug'n'Play projects in the
+// target image. These have no node_modules, so the npm-based strategies can't patch them.
+func (nm *nodejsManager) detectYarnPnPApps(ctx context.Context, currentState *llb.State) ([]string, error) {
+	args := append([]string{"sh", "-c", detectYarnPnPAppsScript, "detect_yarn_pnp_apps", yarnPnPDetectFile}, yarnPnPSearchDirs...)
+	detected := currentState.Run(llb.Args(args), llb.WithCustomName("Detecting Yarn Plug'n'Play projects")).Root()
+	appBytes, err := buildkit.ExtractFileFromState(ctx, nm.config.Client, &detected, yarnPnPDetectFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect Yarn Plug'n'Play projects: %w", err)
+	}
+	return strings.Fields(string(appBytes)), nil
+}
+
+// yarnPnPAppsForUpdate returns the Plug'n'Play projects an update applies to. Updates
+// without a package path (as reported from yarn.lock) apply to every project.
+func yarnPnPAppsForUpdate(pkgPath string, apps []string) []string {
+	if pkgPath == "" {
+		return apps
+	}
+	if !strings.HasPrefix(pkgPath, "/") {
+		pkgPath = "/" + pkgPath
+	}
+	for _, app := range apps {
+		if strings.HasPrefix(pkgPath, strings.TrimSuffix(app, "/")+"/") {
+			return []string{app}
+		}
+	}
+	return nil
+}
+
+// upgradeYarnPnPApps updates the packages of the Yarn Plug'n'Play projects in the image
+// and returns the updates that belong to no such project, to be patched with npm.
+func (nm *nodejsManager) upgradeYarnPnPApps(
+	ctx context.Context,
+	currentState *llb.State,
+	updates unversioned.LangUpdatePackages,
+) (*llb.State, unversioned.LangUpdatePackages) {
+	apps, err := nm.detectYarnPnPApps(ctx, currentState)
+	if err != nil {
+		log.Debugf("Skipping Yarn Plug'n'Play detection: %v", err)
+		return currentState, updates
+	}
+	if len(apps) == 0 {
+		return currentState, updates
+	}
+	log.Infof("Detected Yarn Plug'n'Play projects: %v", apps)
+
+	nm.yarnPnPApps = make(map[string]bool, len(apps))
+	appUpdates := make(map[string]unversioned.LangUpdatePackages, len(apps))
+	var remaining unversioned.LangUpdatePackages
+	for _, u := range updates {
+		matched := yarnPnPAppsForUpdate(u.PkgPath, apps)
+		if len(matched) == 0 {
+			remaining = append(remaining, u)
+			continue
+		}
+		for _, app := range matched {
+			appUpdates[app] = append(appUpdates[app], u)
+		}
+	}
+
+	state := *currentState
+	for _, app := range apps {
+		nm.yarnPnPApps[app] = true
+		if len(appUpdates[app]) == 0 {
+			continue
+		}
+		state = nm.installYarnPnPPackages(ctx, &state, app, appUpdates[app])
+	}
+	return &state, remaining
+}
+
+// installYarnPnPPackages updates a Plug'n'Play project with yarn: direct dependencies
+// with yarn up and transitive ones through package.json resolutions.
+func (nm *nodejsManager) installYarnPnPPackages(
+	ctx context.Context,
+	currentState *llb.State,
+	appPath string,
+	updates unversioned.LangUpdatePackages,
+) llb.State {
+	directDeps, err := getDirectDependencies(ctx, nm.config.Client, currentState, appPath)
+	if err != nil {
+		log.Warnf("Could not read package.json of Yarn Plug'n'Play project %s, skipping: %v", appPath, err)
+		return *currentState
+	}
+	updates = nm.filterDirectOnly(appPath, updates, directDeps)
+
+	specs, resolutions := yarnPnPUpdateArgs(updates, directDeps)
+	if len(specs) == 0 && resolutions == "" {
+		return *currentState
+	}
+
+	log.Infof("Updating Yarn Plug'n'Play project %s (direct: %v, resolutions: %s)", appPath, specs, resolutions)
+	args := append([]string{"sh", "-c", yarnPnPUpdateScript, "yarn_pnp_update", appPath}, specs...)
+	return currentState.Run(
+		llb.Args(args),
+		llb.AddEnv("COPA_YARN_RESOLUTIONS", resolutions),
+		// yarn turns immutable installs on in CI environments, which would reject the update
+		llb.AddEnv("YARN_ENABLE_IMMUTABLE_INSTALLS", "false"),
+		utils.WithProxy(),
+		llb.WithCustomNamef("Updating Yarn Plug'n'Play project %s", appPath),
+	).Root()
+}
+
+// yarnPnPUpdateArgs splits updates into name@version arguments for yarn up (direct
+// dependencies) and a JSON object of resolutions (transitive dependencies). Updates
+// without a fixed version are skipped.
+func yarnPnPUpdateArgs(updates unversioned.LangUpdatePackages, directDeps map[string]bool) ([]string, string) {
+	var specs []string
+	pinned := make(map[string]string)
+	for _, u := range updates {
+		if u.FixedVersion == "" {
+			continue
+		}
+		if directDeps[u.Name] {
+			specs = append(specs, u.Name+"@"+u.FixedVersion)
+		} else {
+			pinned[u.Name] = u.FixedVersion
+		}
+	}
+	sort.Strings(specs)
+
+	if len(pinned) == 0 {
+		return specs, ""
+	}
+	// json.Marshal sorts map keys, so the resolutions are stable across runs
+	resolutions, _ := json.Marshal(pinned)
+	return specs, string(resolutions)
+}
@@ -0,0 +1,193 @@
+package langmgr
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/project-copacetic/copacetic/mocks"
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFixture creates files (relative path -> content) under root. A path ending in
+// "/" creates a directory.
+func writeFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		if strings.HasSuffix(p, "/") {
+			require.NoError(t, os.MkdirAll(full, 0o755))
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0o600))
+	}
+}
+
+func TestDetectYarnPnPAppsScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"pnp-loader/package.json":                              `{"name":"pnp-loader"}`,
+		"pnp-loader/.pnp.cjs":                                  "",
+		"legacy-loader/package.json":                           `{"name":"legacy-loader"}`,
+		"legacy-loader/.pnp.js":                                "",
+		"yarn-path/package.json":                               `{"name":"yarn-path"}`,
+		"yarn-path/.yarnrc.yml":                                "yarnPath: .yarn/releases/yarn-4.1.0.cjs\n",
+		"yarn-dir/package.json":                                `{"name":"yarn-dir"}`,
+		"yarn-dir/.yarn/":                                      "",
+		"npm-app/package.json":                                 `{"name":"npm-app"}`,
+		"npm-app/node_modules/":                                "",
+		"node-modules-linker/package.json":                     `{"name":"node-modules-linker"}`,
+		"node-modules-linker/.yarn/":                           "",
+		"node-modules-linker/node_modules/lodash/package.json": `{"name":"lodash"}`,
+		"plain/package.json":                                   `{"name":"plain"}`,
+		"pnp-loader/.yarn/unplugged/esbuild-npm-0.19.0/node_modules/esbuild/package.json": `{"name":"esbuild"}`,
+	})
+
+	out := filepath.Join(t.TempDir(), "apps")
+	cmd := exec.Command("sh", "-c", detectYarnPnPAppsScript, "detect_yarn_pnp_apps", out, root, filepath.Join(root, "missing"))
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(root, "pnp-loader"),
+		filepath.Join(root, "legacy-loader"),
+		filepath.Join(root, "yarn-path"),
+		filepath.Join(root, "yarn-dir"),
+	}, strings.Fields(string(data)))
+}
+
+func TestYarnPnPUpdateScript(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node not available")
+	}
+
+	// The project's yarnPath release records how it was invoked.
+	app := t.TempDir()
+	logFile := filepath.Join(t.TempDir(), "yarn.log")
+	writeFixture(t, app, map[string]string{
+		"package.json":                  `{"name":"app","dependencies":{"lodash":"4.17.20"}}`,
+		".pnp.cjs":                      "",
+		".yarnrc.yml":                   "nodeLinker: pnp\nyarnPath: \".yarn/releases/yarn-4.1.0.cjs\"\n",
+		".yarn/releases/yarn-4.1.0.cjs": `require("fs").appendFileSync(process.env.YARN_LOG, process.argv.slice(2).join(" ") + "\n");`,
+	})
+
+	cmd := exec.Command("sh", "-c", yarnPnPUpdateScript, "yarn_pnp_update", app, "lodash@4.17.21")
+	cmd.Env = append(os.Environ(), "YARN_LOG="+logFile, `COPA_YARN_RESOLUTIONS={"minimist":"1.2.8"}`)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	calls, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "up lodash@4.17.21\ninstall\n", string(calls))
+
+	data, err := os.ReadFile(filepath.Join(app, "package.json"))
+	require.NoError(t, err)
+	var pkg struct {
+		Resolutions map[string]string `json:"resolutions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &pkg))
+	assert.Equal(t, map[string]string{"minimist": "1.2.8"}, pkg.Resolutions)
+}
+
+func TestYarnPnPAppsForUpdate(t *testing.T) {
+	apps := []string{"/app", "/srv/web"}
+
+	tests := []struct {
+		name    string
+		pkgPath string
+		want    []string
+	}{
+		{name: "no path applies to every project", pkgPath: "", want: apps},
+		{name: "yarn cache path", pkgPath: "app/.yarn/cache/lodash-npm-4.17.20-abc.zip", want: []string{"/app"}},
+		{name: "absolute path", pkgPath: "/srv/web/.yarn/unplugged/esbuild-npm-0.19.0/node_modules/esbuild/package.json", want: []string{"/srv/web"}},
+		{name: "sibling directory with shared prefix", pkgPath: "application/node_modules/lodash/package.json", want: nil},
+		{name: "npm project", pkgPath: "usr/src/api/node_modules/lodash/package.json", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, yarnPnPAppsForUpdate(tt.pkgPath, apps))
+		})
+	}
+}
+
+func TestYarnPnPUpdateArgs(t *testing.T) {
+	updates := unversioned.LangUpdatePackages{
+		{Name: "lodash", FixedVersion: "4.17.21"},
+		{Name: "@babel/core", FixedVersion: "7.23.2"},
+		{Name: "minimist", FixedVersion: "1.2.8"},
+		{Name: "semver", FixedVersion: "7.5.2"},
+		{Name: "unfixed", FixedVersion: ""},
+	}
+	directDeps := map[string]bool{"lodash": true, "@babel/core": true, "unfixed": true}
+
+	specs, resolutions := yarnPnPUpdateArgs(updates, directDeps)
+	assert.Equal(t, []string{"@babel/core@7.23.2", "lodash@4.17.21"}, specs)
+	assert.JSONEq(t, `{"minimist":"1.2.8","semver":"7.5.2"}`, resolutions)
+
+	specs, resolutions = yarnPnPUpdateArgs(updates[:1], directDeps)
+	assert.Equal(t, []string{"lodash@4.17.21"}, specs)
+	assert.Empty(t, resolutions)
+}
+
+func TestUpgradeYarnPnPApps(t *testing.T) {
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	mockRef.On("ReadFile", mock.Anything, mock.MatchedBy(func(req gwclient.ReadRequest) bool {
+		return req.Filename == yarnPnPDetectFile
+	})).Return([]byte(" /app\n"), nil)
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte(`{"dependencies":{"lodash":"^4.17.20"}}`), nil)
+
+	nm := &nodejsManager{config: &buildkit.Config{Client: mockClient}}
+	st := llb.Image("node:20-alpine")
+	updates := unversioned.LangUpdatePackages{
+		{Name: "lodash", FixedVersion: "4.17.21", PkgPath: ""},
+		{Name: "minimist", FixedVersion: "1.2.8", PkgPath: "app/.yarn/cache/minimist-npm-1.2.5-abc.zip"},
+		{Name: "express", FixedVersion: "4.19.2", PkgPath: "usr/src/api/node_modules/express/package.json"},
+	}
+
+	updated, remaining := nm.upgradeYarnPnPApps(context.Background(), &st, updates)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "express", remaining[0].Name)
+	assert.True(t, nm.yarnPnPApps["/app"])
+
+	def, err := updated.Marshal(context.Background())
+	require.NoError(t, err)
+	var found bool
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		execOp := op.GetExec()
+		if execOp == nil {
+			continue
+		}
+		args := execOp.GetMeta().GetArgs()
+		if len(args) > 3 && args[3] == "yarn_pnp_update" {
+			found = true
+			assert.Equal(t, []string{"/app", "lodash@4.17.21"}, args[4:])
+			assert.Contains(t, execOp.GetMeta().GetEnv(), `COPA_YARN_RESOLUTIONS={"minimist":"1.2.8"}`)
+		}
+	}
+	assert.True(t, found, "yarn update exec not found in LLB")
+}
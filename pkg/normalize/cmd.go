@@ -0,0 +1,50 @@
+package normalize
+
+import (
+	"os"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewNormalizeCmd() *cobra.Command {
+	opts := Options{}
+	normalizeCmd := &cobra.Command{
+		Use:   "normalize",
+		Short: "Convert a vulnerability report into Copa's update manifest",
+		Long: `Normalize parses a vulnerability report with the selected scanner parser and writes the
+resulting update manifest as JSON. It does not pull or patch any image and does not need BuildKit.`,
+		Example: `  # Normalize a Trivy report to stdout
+  copa normalize -r trivy.json
+
+  # Normalize a report from a scanner plugin to a file
+  copa normalize -r grype.json -s grype -o manifest.json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return Normalize(&opts, cmd.OutOrStdout())
+		},
+	}
+
+	flags := normalizeCmd.Flags()
+	flags.StringVarP(&opts.Report, "report", "r", "", "Vulnerability report file path")
+	flags.StringVarP(&opts.Scanner, "scanner", "s", "trivy", "Scanner that generated the report, defaults to 'trivy'")
+	flags.StringVarP(&opts.Output, "output", "o", "", "Output file path for the update manifest, defaults to stdout")
+
+	// Experimental flags - only available when COPA_EXPERIMENTAL=1
+	if os.Getenv("COPA_EXPERIMENTAL") == "1" {
+		flags.StringVar(&opts.PkgTypes, "pkg-types", utils.PkgTypeOS,
+			"[EXPERIMENTAL] Package types to include, comma-separated list of 'os' and 'library'. "+
+				"Defaults to 'os' for OS vulnerabilities only")
+		flags.StringVar(&opts.LibraryPatchLevel, "library-patch-level", utils.PatchTypePatch,
+			"[EXPERIMENTAL] Library patch level preference: 'patch', 'minor', or 'major'. "+
+				"Only applicable when 'library' is included in --pkg-types. Defaults to 'patch'")
+	} else {
+		opts.PkgTypes = utils.PkgTypeOS
+		opts.LibraryPatchLevel = utils.PatchTypePatch
+	}
+
+	if err := normalizeCmd.MarkFlagRequired("report"); err != nil {
+		panic(err)
+	}
+
+	return normalizeCmd
+}
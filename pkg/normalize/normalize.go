@@ -0,0 +1,49 @@
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/project-copacetic/copacetic/pkg/report"
+)
+
+// Options are the inputs to Normalize.
+type Options struct {
+	Report            string
+	Scanner           string
+	PkgTypes          string
+	LibraryPatchLevel string
+	// Output is the file the manifest is written to, or stdout when empty.
+	Output string
+}
+
+// Normalize parses a scanner report with the parser selected by opts.Scanner and writes
+// the resulting update manifest as JSON. No image is inspected and no BuildKit
+// connection is made.
+func Normalize(opts *Options, stdout io.Writer) error {
+	if err := report.ValidateScanner(opts.Scanner); err != nil {
+		return err
+	}
+
+	manifest, err := report.TryParseScanReport(opts.Report, opts.Scanner, opts.PkgTypes, opts.LibraryPatchLevel)
+	if err != nil {
+		return fmt.Errorf("failed to parse report %s: %w", opts.Report, err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal update manifest: %w", err)
+	}
+	data = append(data, '\n')
+
+	if opts.Output == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(opts.Output, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write update manifest to %s: %w", opts.Output, err)
+	}
+	return nil
+}
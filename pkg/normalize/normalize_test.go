@@ -0,0 +1,112 @@
+package normalize
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func TestNormalize(t *testing.T) {
+	const nodeReport = "../report/testdata/trivy_node_valid.json"
+
+	tests := []struct {
+		name            string
+		opts            Options
+		wantOSUpdates   int
+		wantNodeUpdates int
+		wantErr         string
+	}{
+		{
+			name: "OS and library packages",
+			opts: Options{
+				Report:            nodeReport,
+				Scanner:           "trivy",
+				PkgTypes:          utils.PkgTypeOS + "," + utils.PkgTypeLibrary,
+				LibraryPatchLevel: utils.PatchTypePatch,
+			},
+			wantOSUpdates:   1,
+			wantNodeUpdates: 2,
+		},
+		{
+			name: "OS packages only",
+			opts: Options{
+				Report:            nodeReport,
+				Scanner:           "trivy",
+				PkgTypes:          utils.PkgTypeOS,
+				LibraryPatchLevel: utils.PatchTypePatch,
+			},
+			wantOSUpdates:   1,
+			wantNodeUpdates: 0,
+		},
+		{
+			name: "missing report",
+			opts: Options{
+				Report:   "testdata/does-not-exist.json",
+				Scanner:  "trivy",
+				PkgTypes: utils.PkgTypeOS,
+			},
+			wantErr: "failed to parse report",
+		},
+		{
+			name: "unknown scanner",
+			opts: Options{
+				Report:  nodeReport,
+				Scanner: "no-such-scanner",
+			},
+			wantErr: "no-such-scanner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Output = filepath.Join(t.TempDir(), "manifest.json")
+
+			err := Normalize(&tt.opts, nil)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(tt.opts.Output)
+			require.NoError(t, err)
+
+			var manifest unversioned.UpdateManifest
+			require.NoError(t, json.Unmarshal(data, &manifest))
+			assert.Equal(t, "alpine", manifest.Metadata.OS.Type)
+			assert.Len(t, manifest.OSUpdates, tt.wantOSUpdates)
+
+			nodeUpdates := 0
+			for _, u := range manifest.LangUpdates {
+				if u.Type == utils.NodePackages {
+					nodeUpdates++
+				}
+			}
+			assert.Equal(t, tt.wantNodeUpdates, nodeUpdates)
+		})
+	}
+}
+
+func TestNormalizeToStdout(t *testing.T) {
+	var out bytes.Buffer
+	err := Normalize(&Options{
+		Report:            "../report/testdata/trivy_node_valid.json",
+		Scanner:           "trivy",
+		PkgTypes:          utils.PkgTypeOS,
+		LibraryPatchLevel: utils.PatchTypePatch,
+	}, &out)
+	require.NoError(t, err)
+
+	var manifest unversioned.UpdateManifest
+	require.NoError(t, json.Unmarshal(out.Bytes(), &manifest))
+	assert.Equal(t, "protobuf-c", manifest.OSUpdates[0].Name)
+}
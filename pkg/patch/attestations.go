@@ -0,0 +1,124 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// vexArtifactType is the artifact type of the OpenVEX documents attached to patched images.
+const vexArtifactType = "application/vnd.openvex+json"
+
+// attestation is a document Copa generated for a patched image, attached to it as an
+// OCI referrer by --attach-attestations.
+type attestation struct {
+	path         string
+	artifactType string
+}
+
+// vexOutputPath returns the path the VEX document is written to: output if set, or
+// when the document is only attached, a file in workingFolder named for platform so
+// platforms patched in parallel don't share it.
+func vexOutputPath(output string, attach bool, workingFolder string, platform ispec.Platform) string {
+	if output != "" || !attach {
+		return output
+	}
+	return filepath.Join(workingFolder, utils.PlatformArtifactName(utils.VEXArtifactPrefix, platform))
+}
+
+// attachAttestations pushes each of docs as an artifact whose subject is the image
+// with digest dgst in each of the repositories of imageNames, so registries list them
+// with the referrers API. go-containerregistry falls back to the referrers tag schema,
+// a sha256-<digest> tag holding an index of the referrers, when the registry does not
+// support the API.
+func attachAttestations(ctx context.Context, imageNames []string, dgst string, docs []attestation) error {
+	seen := make(map[string]bool)
+	for _, imageName := range imageNames {
+		ref, err := name.ParseReference(imageName)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", imageName, err)
+		}
+		repo := ref.Context()
+		if seen[repo.Name()] {
+			continue
+		}
+		seen[repo.Name()] = true
+
+		subjectRef := repo.Digest(dgst)
+		auth := remote.WithAuthFromKeychain(authn.DefaultKeychain)
+		desc, err := utils.RemoteGet(ctx, subjectRef, auth)
+		if err != nil {
+			return fmt.Errorf("failed to get patched image %s: %w", subjectRef, err)
+		}
+
+		for _, doc := range docs {
+			artifact, err := newReferrerArtifact(doc, desc.Descriptor)
+			if err != nil {
+				return err
+			}
+			artifactDigest, err := artifact.Digest()
+			if err != nil {
+				return fmt.Errorf("failed to compute digest of %s artifact: %w", doc.path, err)
+			}
+			if err := writeArtifact(ctx, repo.Digest(artifactDigest.String()), artifact, auth); err != nil {
+				return fmt.Errorf("failed to attach %s to %s: %w", doc.path, subjectRef, err)
+			}
+			log.Infof("Attached %s to %s as %s", filepath.Base(doc.path), subjectRef, repo.Digest(artifactDigest.String()))
+		}
+	}
+	return nil
+}
+
+// writeArtifact pushes artifact to ref once a registry slot is free.
+func writeArtifact(ctx context.Context, ref name.Digest, artifact v1.Image, options ...remote.Option) error {
+	release, err := utils.AcquireRegistrySlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return remote.Write(ref, artifact, append(options, remote.WithContext(ctx))...)
+}
+
+// newReferrerArtifact returns an OCI artifact manifest with the content of doc as its
+// only layer, subject as its subject and the artifact type of doc as its config media
+// type, which registries report as the artifact type of the referrer.
+func newReferrerArtifact(doc attestation, subject v1.Descriptor) (v1.Image, error) {
+	data, err := os.ReadFile(doc.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", doc.path, err)
+	}
+
+	img := mutate.MediaType(empty.Image, ggcrtypes.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, ggcrtypes.MediaType(doc.artifactType))
+	img, err = mutate.Append(img, mutate.Addendum{
+		Layer:       static.NewLayer(data, ggcrtypes.MediaType(doc.artifactType)),
+		Annotations: map[string]string{ispec.AnnotationTitle: filepath.Base(doc.path)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s artifact: %w", doc.path, err)
+	}
+
+	withSubject, ok := mutate.Subject(img, v1.Descriptor{
+		MediaType: subject.MediaType,
+		Digest:    subject.Digest,
+		Size:      subject.Size,
+	}).(v1.Image)
+	if !ok {
+		return nil, fmt.Errorf("failed to set the subject of %s artifact", doc.path)
+	}
+	return withSubject, nil
+}
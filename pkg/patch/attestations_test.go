@@ -0,0 +1,88 @@
+package patch
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachAttestations(t *testing.T) {
+	tests := []struct {
+		name      string
+		referrers bool
+	}{
+		{name: "referrers API", referrers: true},
+		{name: "referrers tag schema fallback", referrers: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(registry.New(registry.WithReferrersSupport(tt.referrers)))
+			defer srv.Close()
+			host := strings.TrimPrefix(srv.URL, "http://")
+
+			img, err := random.Image(64, 1)
+			require.NoError(t, err)
+			patched, err := name.ParseReference(host + "/app:1.0-patched")
+			require.NoError(t, err)
+			require.NoError(t, remote.Write(patched, img))
+			dgst, err := img.Digest()
+			require.NoError(t, err)
+
+			vexPath := filepath.Join(t.TempDir(), "vex.json")
+			require.NoError(t, os.WriteFile(vexPath, []byte(`{"@context":"https://openvex.dev/ns/v0.2.0"}`), 0o600))
+
+			// Both names are in the same repository, so the VEX is attached once
+			names := []string{patched.String(), host + "/app:latest"}
+			docs := []attestation{{path: vexPath, artifactType: vexArtifactType}}
+			require.NoError(t, attachAttestations(context.Background(), names, dgst.String(), docs))
+
+			idx, err := remote.Referrers(patched.Context().Digest(dgst.String()))
+			require.NoError(t, err)
+			manifest, err := idx.IndexManifest()
+			require.NoError(t, err)
+			require.Len(t, manifest.Manifests, 1)
+			assert.Equal(t, vexArtifactType, manifest.Manifests[0].ArtifactType)
+
+			artifact, err := remote.Image(patched.Context().Digest(manifest.Manifests[0].Digest.String()))
+			require.NoError(t, err)
+			artifactManifest, err := artifact.Manifest()
+			require.NoError(t, err)
+			require.NotNil(t, artifactManifest.Subject)
+			assert.Equal(t, dgst, artifactManifest.Subject.Digest)
+			require.Len(t, artifactManifest.Layers, 1)
+			assert.Equal(t, "vex.json", artifactManifest.Layers[0].Annotations[ispec.AnnotationTitle])
+		})
+	}
+}
+
+func TestAttachAttestationsMissingSubject(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	vexPath := filepath.Join(t.TempDir(), "vex.json")
+	require.NoError(t, os.WriteFile(vexPath, []byte(`{}`), 0o600))
+
+	err := attachAttestations(context.Background(), []string{host + "/app:1.0-patched"},
+		"sha256:"+strings.Repeat("0", 64), []attestation{{path: vexPath, artifactType: vexArtifactType}})
+	assert.ErrorContains(t, err, "failed to get patched image")
+}
+
+func TestVEXOutputPath(t *testing.T) {
+	platform := ispec.Platform{OS: "linux", Architecture: "arm64"}
+	assert.Equal(t, "vex.json", vexOutputPath("vex.json", true, "/tmp/copa", platform))
+	assert.Empty(t, vexOutputPath("", false, "/tmp/copa", platform))
+	assert.Equal(t, filepath.Join("/tmp/copa", "vex-linux-arm64.json"), vexOutputPath("", true, "/tmp/copa", platform))
+}
@@ -5,77 +5,114 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/docker/cli/cli/config"
 	"github.com/moby/buildkit/client"
-	"github.com/moby/buildkit/session"
-	"github.com/moby/buildkit/session/auth/authprovider"
 	sourcepolicy "github.com/moby/buildkit/sourcepolicy/pb"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
 )
 
 const (
 	attrValueTrue = "true"
 )
 
+// layerCompression is how the layers of a pushed image are compressed.
+type layerCompression struct {
+	// Type is gzip, zstd, estargz or uncompressed; empty keeps BuildKit's default
+	Type string
+	// Force recompresses the original image's layers too, not only the patch layers
+	Force bool
+}
+
 // BuildConfig holds configuration for building and exporting images.
 type BuildConfig struct {
 	SolveOpt        client.SolveOpt
 	ShouldExportOCI bool
 	PipeWriter      io.WriteCloser
+	// Image names the solve pushes to, the patched image name first
+	PushedNames []string
+	// OS packages upgraded to their latest versions along with the report's updates
+	InstalledOSPackages unversioned.UpdatePackages
 }
 
-// createBuildConfig creates the build configuration for patching.
+// createBuildConfig creates the build configuration for patching. The image is pushed
+// to the registry when push is set and streamed to pipeW for loading into the local
+// runtime when load is set; both may be set to do both in a single solve. With push,
+// the image is also pushed to each of pushTo from the same solve, with its layers
+// compressed as set by compression. annotations are added to the image manifest.
 func createBuildConfig(
 	patchedImageName string,
 	shouldExportOCI bool,
 	push bool,
+	load bool,
+	pushTo []string,
 	pipeW io.WriteCloser,
+	cache *buildkit.CacheOptions,
+	compression layerCompression,
+	annotations map[string]string,
 ) (*BuildConfig, error) {
-	dockerConfig := config.LoadDefaultConfigFile(os.Stderr)
-	cfg := authprovider.DockerAuthProviderConfig{AuthConfigProvider: authprovider.LoadAuthConfig(dockerConfig)}
-	attachable := []session.Attachable{authprovider.NewDockerAuthProvider(cfg)}
+	attachable := utils.RegistryAuthSession()
 
 	// create solve options based on whether we're pushing to registry or loading to docker
 	solveOpt := client.SolveOpt{
 		Frontend: "",         // i.e. we are passing in the llb.Definition directly
 		Session:  attachable, // used for authprovider, sshagentprovider and secretprovider
 	}
+	cache.Apply(&solveOpt)
 
 	// determine which attributes to set for the export
-	attrs := map[string]string{
-		"name": patchedImageName,
-		"annotation." + copaAnnotationKeyPrefix + ".image.patched": time.Now().UTC().Format(time.RFC3339),
+	attrs := make(map[string]string, len(annotations)+2)
+	for k, v := range annotations {
+		attrs["annotation."+k] = v
 	}
+	attrs["name"] = patchedImageName
+	attrs["annotation."+copaAnnotationKeyPrefix+".image.patched"] = time.Now().UTC().Format(time.RFC3339)
 	if shouldExportOCI {
 		attrs["oci-mediatypes"] = attrValueTrue
 	}
 
+	var pushedNames []string
 	if push {
-		attrs["push"] = attrValueTrue
-		solveOpt.Exports = []client.ExportEntry{
-			{
+		pushedNames = append([]string{patchedImageName}, pushTo...)
+		for _, name := range pushedNames {
+			pushAttrs := maps.Clone(attrs)
+			pushAttrs["name"] = name
+			pushAttrs["push"] = attrValueTrue
+			if compression.Type != "" {
+				pushAttrs["compression"] = compression.Type
+				if utils.CompressionNeedsOCIMediaTypes(compression.Type) {
+					pushAttrs["oci-mediatypes"] = attrValueTrue
+				}
+				if compression.Force {
+					pushAttrs["force-compression"] = attrValueTrue
+				}
+			}
+			solveOpt.Exports = append(solveOpt.Exports, client.ExportEntry{
 				Type:  client.ExporterImage,
-				Attrs: attrs,
-			},
+				Attrs: pushAttrs,
+			})
 		}
-	} else {
+	}
+	if load {
 		// Use uncompressed layers for local export to ensure diff_id == blob digest
 		// This fixes Trivy scanning issues where compressed layers have mismatched hashes
-		attrs["compression"] = "uncompressed"
-		attrs["force-compression"] = attrValueTrue
-
-		solveOpt.Exports = []client.ExportEntry{
-			{
-				Type:  client.ExporterDocker,
-				Attrs: attrs,
-				Output: func(_ map[string]string) (io.WriteCloser, error) {
-					return pipeW, nil
-				},
+		loadAttrs := maps.Clone(attrs)
+		loadAttrs["compression"] = "uncompressed"
+		loadAttrs["force-compression"] = attrValueTrue
+
+		solveOpt.Exports = append(solveOpt.Exports, client.ExportEntry{
+			Type:  client.ExporterDocker,
+			Attrs: loadAttrs,
+			Output: func(_ map[string]string) (io.WriteCloser, error) {
+				return pipeW, nil
 			},
-		}
+		})
 	}
 
 	// Set source policy
@@ -93,9 +130,22 @@ func createBuildConfig(
 		SolveOpt:        solveOpt,
 		ShouldExportOCI: shouldExportOCI,
 		PipeWriter:      pipeW,
+		PushedNames:     pushedNames,
 	}, nil
 }
 
+// diffExportEntry writes the solved patch diff to path as a tar archive of the
+// changed files, laid out relative to the image root.
+func diffExportEntry(path string) client.ExportEntry {
+	return client.ExportEntry{
+		Type:  client.ExporterTar,
+		Attrs: map[string]string{},
+		Output: func(_ map[string]string) (io.WriteCloser, error) {
+			return os.Create(path)
+		},
+	}
+}
+
 // validateSourcePolicy validates that the source policy doesn't contain unsupported distributions.
 func validateSourcePolicy(sourcePolicy *sourcepolicy.Policy) error {
 	if sourcePolicy == nil || len(sourcePolicy.Rules) == 0 {
@@ -1,10 +1,19 @@
 package patch
 
 import (
+	"io"
 	"testing"
 
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/client"
 	sourcepolicy "github.com/moby/buildkit/sourcepolicy/pb"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
 )
 
 // TestValidateSourcePolicy tests the validateSourcePolicy function.
@@ -152,3 +161,161 @@ func TestValidateSourcePolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateBuildConfigCache(t *testing.T) {
+	cache, err := buildkit.ParseCacheOptions(
+		[]string{"type=registry,ref=example.com/cache:patch"},
+		[]string{"type=local,dest=/tmp/cache"},
+	)
+	require.NoError(t, err)
+
+	buildConfig, err := createBuildConfig("example.com/app:patched", false, true, false, nil, nil, cache, layerCompression{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []client.CacheOptionsEntry{
+		{Type: "registry", Attrs: map[string]string{"ref": "example.com/cache:patch"}},
+	}, buildConfig.SolveOpt.CacheImports)
+	assert.Equal(t, []client.CacheOptionsEntry{
+		{Type: "local", Attrs: map[string]string{"dest": "/tmp/cache"}},
+	}, buildConfig.SolveOpt.CacheExports)
+
+	buildConfig, err = createBuildConfig("example.com/app:patched", false, true, false, nil, nil, nil, layerCompression{}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, buildConfig.SolveOpt.CacheImports)
+	assert.Empty(t, buildConfig.SolveOpt.CacheExports)
+}
+
+func TestCreateBuildConfigExports(t *testing.T) {
+	tests := []struct {
+		name       string
+		push, load bool
+		pushTo     []string
+		want       []string
+		wantNames  []string
+	}{
+		{name: "load only", load: true, want: []string{client.ExporterDocker}},
+		{name: "push only", push: true, want: []string{client.ExporterImage}, wantNames: []string{"example.com/app:patched"}},
+		{name: "push and load", push: true, load: true, want: []string{client.ExporterImage, client.ExporterDocker}, wantNames: []string{"example.com/app:patched"}},
+		{
+			name:      "push to additional destinations",
+			push:      true,
+			load:      true,
+			pushTo:    []string{"dr.example.com/app:patched", "mirror.example.com/app:patched"},
+			want:      []string{client.ExporterImage, client.ExporterImage, client.ExporterImage, client.ExporterDocker},
+			wantNames: []string{"example.com/app:patched", "dr.example.com/app:patched", "mirror.example.com/app:patched"},
+		},
+		{name: "push-to ignored without push", load: true, pushTo: []string{"dr.example.com/app:patched"}, want: []string{client.ExporterDocker}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeR, pipeW := io.Pipe()
+			defer pipeR.Close()
+
+			buildConfig, err := createBuildConfig("example.com/app:patched", false, tt.push, tt.load, tt.pushTo, pipeW, nil, layerCompression{}, nil)
+			require.NoError(t, err)
+
+			var got, names []string
+			for _, export := range buildConfig.SolveOpt.Exports {
+				got = append(got, export.Type)
+				switch export.Type {
+				case client.ExporterImage:
+					names = append(names, export.Attrs["name"])
+					assert.Equal(t, "true", export.Attrs["push"])
+					assert.NotContains(t, export.Attrs, "compression")
+				case client.ExporterDocker:
+					assert.NotContains(t, export.Attrs, "push")
+					assert.Equal(t, "uncompressed", export.Attrs["compression"])
+					w, err := export.Output(nil)
+					require.NoError(t, err)
+					assert.Same(t, pipeW, w)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantNames, names)
+			assert.Equal(t, tt.wantNames, buildConfig.PushedNames)
+		})
+	}
+}
+
+func TestShouldLoadImage(t *testing.T) {
+	host := platforms.Normalize(platforms.DefaultSpec())
+	other := ispec.Platform{OS: "linux", Architecture: "s390x"}
+	if host.Architecture == other.Architecture {
+		other.Architecture = "ppc64le"
+	}
+
+	tests := []struct {
+		name          string
+		push, load    bool
+		platform      ispec.Platform
+		multiPlatform bool
+		want          bool
+	}{
+		{name: "not pushing always loads", platform: other, multiPlatform: true, want: true},
+		{name: "push without load", push: true, platform: host, want: false},
+		{name: "push and load single platform", push: true, load: true, platform: other, want: true},
+		{name: "push and load host platform", push: true, load: true, platform: host, multiPlatform: true, want: true},
+		{name: "push and load other platform", push: true, load: true, platform: other, multiPlatform: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &types.Options{Push: tt.push, Load: tt.load}
+			assert.Equal(t, tt.want, shouldLoadImage(opts, &types.PatchPlatform{Platform: tt.platform}, tt.multiPlatform))
+		})
+	}
+}
+
+func TestCreateBuildConfigCompression(t *testing.T) {
+	pipeR, pipeW := io.Pipe()
+	defer pipeR.Close()
+
+	buildConfig, err := createBuildConfig("example.com/app:patched", false, true, true, nil, pipeW, nil,
+		layerCompression{Type: utils.CompressionZstd, Force: true}, nil)
+	require.NoError(t, err)
+	require.Len(t, buildConfig.SolveOpt.Exports, 2)
+
+	push := buildConfig.SolveOpt.Exports[0]
+	assert.Equal(t, client.ExporterImage, push.Type)
+	assert.Equal(t, "zstd", push.Attrs["compression"])
+	assert.Equal(t, "true", push.Attrs["force-compression"])
+	assert.Equal(t, "true", push.Attrs["oci-mediatypes"])
+
+	// Images loaded into the runtime stay uncompressed.
+	load := buildConfig.SolveOpt.Exports[1]
+	assert.Equal(t, client.ExporterDocker, load.Type)
+	assert.Equal(t, "uncompressed", load.Attrs["compression"])
+
+	// Matching the source compresses only the patch layers.
+	buildConfig, err = createBuildConfig("example.com/app:patched", false, true, false, nil, nil, nil,
+		layerCompression{Type: utils.CompressionGzip}, nil)
+	require.NoError(t, err)
+	push = buildConfig.SolveOpt.Exports[0]
+	assert.Equal(t, "gzip", push.Attrs["compression"])
+	assert.NotContains(t, push.Attrs, "force-compression")
+	assert.NotContains(t, push.Attrs, "oci-mediatypes")
+
+	// estargz keeps its annotations only in OCI manifests.
+	buildConfig, err = createBuildConfig("example.com/app:patched", false, true, false, nil, nil, nil,
+		layerCompression{Type: utils.CompressionEstargz, Force: true}, nil)
+	require.NoError(t, err)
+	push = buildConfig.SolveOpt.Exports[0]
+	assert.Equal(t, "estargz", push.Attrs["compression"])
+	assert.Equal(t, "true", push.Attrs["oci-mediatypes"])
+}
+
+func TestCreateBuildConfigAnnotations(t *testing.T) {
+	pipeR, pipeW := io.Pipe()
+	defer pipeR.Close()
+
+	annotations := map[string]string{"com.example.build": "42", copaAnnotationKeyPrefix + ".image.patched": "overridden"}
+	buildConfig, err := createBuildConfig("example.com/app:patched", false, true, true, nil, pipeW, nil, layerCompression{}, annotations)
+	require.NoError(t, err)
+	require.Len(t, buildConfig.SolveOpt.Exports, 2)
+	for _, export := range buildConfig.SolveOpt.Exports {
+		assert.Equal(t, "42", export.Attrs["annotation.com.example.build"], export.Type)
+		// Copa's own annotation is not replaced
+		assert.NotEqual(t, "overridden", export.Attrs["annotation."+copaAnnotationKeyPrefix+".image.patched"], export.Type)
+		assert.Equal(t, "example.com/app:patched", export.Attrs["name"], export.Type)
+	}
+}
@@ -0,0 +1,77 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+// changelogImagePath is where --changelog-in-image puts the changelog in the patched image.
+const changelogImagePath = "/usr/share/copa/changelog"
+
+// renderChangelog returns a human-readable list of the updates applied to image, one
+// line (or markdown table row) per package with its old and new version and the
+// vulnerabilities it fixes. Updates for packages in errPkgs are left out.
+func renderChangelog(image string, updates *unversioned.UpdateManifest, errPkgs []string, markdown bool) []byte {
+	var b bytes.Buffer
+	if markdown {
+		fmt.Fprintf(&b, "# Changes applied by Copa to `%s`\n\n", image)
+	} else {
+		fmt.Fprintf(&b, "Changes applied by Copa to %s\n\n", image)
+	}
+
+	if updates == nil {
+		b.WriteString("All packages were upgraded to their latest versions.\n")
+		return b.Bytes()
+	}
+	changes := appliedChanges(updates, errPkgs)
+	if len(changes) == 0 {
+		b.WriteString("No packages were updated.\n")
+		return b.Bytes()
+	}
+
+	if markdown {
+		b.WriteString("| Package | Old version | New version | Vulnerabilities |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+	}
+	for _, c := range changes {
+		vulns := strings.Join(c.VulnerabilityIDs, ", ")
+		if markdown {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", c.Name, c.InstalledVersion, c.FixedVersion, vulns)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s -> %s", c.Name, c.InstalledVersion, c.FixedVersion)
+		if vulns != "" {
+			fmt.Fprintf(&b, " (%s)", vulns)
+		}
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
+
+// writeChangelog writes the changelog for the applied updates to file, as a
+// markdown table when file has a .md or .markdown extension and as plain text otherwise.
+func writeChangelog(file, image string, updates *unversioned.UpdateManifest) error {
+	ext := strings.ToLower(filepath.Ext(file))
+	markdown := ext == ".md" || ext == ".markdown"
+	if err := os.WriteFile(file, renderChangelog(image, updates, nil, markdown), 0o644); err != nil {
+		return fmt.Errorf("failed to write changelog to %s: %w", file, err)
+	}
+	return nil
+}
+
+// withChangelog adds the plain text changelog to st at changelogImagePath.
+func withChangelog(st llb.State, changelog []byte) llb.State {
+	return st.File(
+		llb.Mkdir(path.Dir(changelogImagePath), 0o755, llb.WithParents(true)).
+			Mkfile(changelogImagePath, 0o644, changelog),
+		llb.WithCustomName("Adding patch changelog"),
+	)
+}
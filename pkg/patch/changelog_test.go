@@ -0,0 +1,88 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+func TestRenderChangelog(t *testing.T) {
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{
+			{Name: "openssl", InstalledVersion: "1.1.1n-r0", FixedVersion: "1.1.1q-r0", VulnerabilityID: "CVE-2022-2097"},
+			{Name: "openssl", InstalledVersion: "1.1.1n-r0", FixedVersion: "1.1.1q-r0", VulnerabilityID: "CVE-2022-2068"},
+			{Name: "zlib", InstalledVersion: "1.2.12-r0", FixedVersion: "1.2.12-r2", VulnerabilityID: "CVE-2022-37434"},
+		},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		got := renderChangelog("docker.io/library/alpine:3.16-patched", updates, nil, false)
+		assert.Equal(t, "Changes applied by Copa to docker.io/library/alpine:3.16-patched\n\n"+
+			"openssl 1.1.1n-r0 -> 1.1.1q-r0 (CVE-2022-2068, CVE-2022-2097)\n"+
+			"zlib 1.2.12-r0 -> 1.2.12-r2 (CVE-2022-37434)\n", string(got))
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		got := renderChangelog("docker.io/library/alpine:3.16-patched", updates, nil, true)
+		assert.Equal(t, "# Changes applied by Copa to `docker.io/library/alpine:3.16-patched`\n\n"+
+			"| Package | Old version | New version | Vulnerabilities |\n"+
+			"| --- | --- | --- | --- |\n"+
+			"| openssl | 1.1.1n-r0 | 1.1.1q-r0 | CVE-2022-2068, CVE-2022-2097 |\n"+
+			"| zlib | 1.2.12-r0 | 1.2.12-r2 | CVE-2022-37434 |\n", string(got))
+	})
+
+	t.Run("errored packages are left out", func(t *testing.T) {
+		got := renderChangelog("alpine", updates, []string{"openssl", "zlib"}, false)
+		assert.Equal(t, "Changes applied by Copa to alpine\n\nNo packages were updated.\n", string(got))
+	})
+
+	t.Run("no report", func(t *testing.T) {
+		got := renderChangelog("alpine", nil, nil, false)
+		assert.Equal(t, "Changes applied by Copa to alpine\n\nAll packages were upgraded to their latest versions.\n", string(got))
+	})
+}
+
+func TestWriteChangelog(t *testing.T) {
+	dir := t.TempDir()
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{
+			{Name: "zlib", InstalledVersion: "1.2.12-r0", FixedVersion: "1.2.12-r2", VulnerabilityID: "CVE-2022-37434"},
+		},
+	}
+
+	txt := filepath.Join(dir, "copa-changelog.txt")
+	require.NoError(t, writeChangelog(txt, "alpine", updates))
+	b, err := os.ReadFile(txt)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "zlib 1.2.12-r0 -> 1.2.12-r2 (CVE-2022-37434)")
+
+	md := filepath.Join(dir, "copa-changelog.md")
+	require.NoError(t, writeChangelog(md, "alpine", updates))
+	b, err = os.ReadFile(md)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "| zlib | 1.2.12-r0 | 1.2.12-r2 | CVE-2022-37434 |")
+
+	assert.Error(t, writeChangelog(filepath.Join(dir, "missing", "changelog.txt"), "alpine", updates))
+}
+
+func TestWithChangelog(t *testing.T) {
+	st := withChangelog(llb.Scratch(), []byte("Changes applied by Copa to alpine\n"))
+	def, err := st.Marshal(context.Background())
+	require.NoError(t, err)
+
+	var found bool
+	for _, op := range def.Def {
+		if bytes.Contains(op, []byte(changelogImagePath)) && bytes.Contains(op, []byte("Changes applied by Copa to alpine")) {
+			found = true
+		}
+	}
+	assert.True(t, found, "changelog file op not found in LLB")
+}
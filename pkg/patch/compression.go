@@ -0,0 +1,119 @@
+package patch
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// for testing.
+var sourceLayerCompression = registryLayerCompression
+
+// resolveLayerCompression returns how the patched image's layers are compressed when
+// pushed. An explicit --compression recompresses every layer; otherwise the patch
+// layers match the compression of the source image so it isn't mixed.
+func resolveLayerCompression(ctx context.Context, requested, imageRef string, platform *ispec.Platform) layerCompression {
+	if requested != "" {
+		return layerCompression{Type: requested, Force: true}
+	}
+	compression, err := sourceLayerCompression(ctx, imageRef, platform)
+	if err != nil {
+		log.Debugf("Could not determine layer compression of %s, using the default: %v", imageRef, err)
+		return layerCompression{}
+	}
+	if compression != "" {
+		log.Debugf("Compressing patch layers with %s to match %s", compression, imageRef)
+	}
+	return layerCompression{Type: compression}
+}
+
+// ociExportOptions returns how the patched platforms are exported to an OCI layout,
+// with annotations and each platform's compression resolved like
+// resolveLayerCompression does for a push.
+func ociExportOptions(ctx context.Context, requested, imageRef string, platforms []types.PatchPlatform, annotations map[string]string) *buildkit.OCIExportOptions {
+	export := &buildkit.OCIExportOptions{Compression: make(map[string]string), Annotations: annotations}
+	for i := range platforms {
+		if platforms[i].ShouldPreserve {
+			continue
+		}
+		compression := resolveLayerCompression(ctx, requested, imageRef, &platforms[i].Platform)
+		if compression.Type != "" {
+			export.Compression[buildkit.PlatformKey(platforms[i].Platform)] = compression.Type
+		}
+		export.ForceCompression = compression.Force
+	}
+	return export
+}
+
+// compressionSupportWarning returns a warning about the registries and runtimes that
+// can't use images with the explicitly requested compression, or "" if it is widely
+// supported.
+func compressionSupportWarning(compression string) string {
+	switch compression {
+	case utils.CompressionZstd:
+		return "--compression=zstd: the registry must accept OCI image manifests, and pulling requires containerd 1.5+, " +
+			"Docker 23+ or Podman 3.3+; older clients fail to pull the patched image. Use gzip for the widest support"
+	case utils.CompressionEstargz:
+		return "--compression=estargz: the registry must accept OCI image manifests. The layers stay gzip-compatible, " +
+			"but lazy pulling requires the stargz snapshotter on the pulling host"
+	case utils.CompressionUncompressed:
+		return "--compression=uncompressed: some registries reject uncompressed layers, and pulls transfer the full layer size"
+	}
+	return ""
+}
+
+// registryLayerCompression returns the compression shared by all layers of the
+// registry image imageRef for platform, or "" when its layers are compressed
+// differently from each other.
+func registryLayerCompression(ctx context.Context, imageRef string, platform *ispec.Platform) (string, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return "", err
+	}
+	desc, err := utils.RemoteGet(ctx, ref,
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithPlatform(v1.Platform{OS: platform.OS, Architecture: platform.Architecture, Variant: platform.Variant}))
+	if err != nil {
+		return "", err
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return "", err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", err
+	}
+
+	mediaTypes := make([]string, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		mediaTypes = append(mediaTypes, string(layer.MediaType))
+	}
+	return commonLayerCompression(mediaTypes), nil
+}
+
+// commonLayerCompression returns the compression of the layer media types, or "" if
+// they differ or none is a known layer media type.
+func commonLayerCompression(mediaTypes []string) string {
+	var common string
+	for _, mt := range mediaTypes {
+		compression, ok := utils.LayerCompression(mt)
+		if !ok {
+			continue
+		}
+		if common != "" && common != compression {
+			return ""
+		}
+		common = compression
+	}
+	return common
+}
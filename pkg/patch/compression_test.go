@@ -0,0 +1,84 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func TestResolveLayerCompression(t *testing.T) {
+	orig := sourceLayerCompression
+	defer func() { sourceLayerCompression = orig }()
+
+	platform := &ispec.Platform{OS: "linux", Architecture: "amd64"}
+	var source string
+	var sourceErr error
+	sourceLayerCompression = func(_ context.Context, _ string, _ *ispec.Platform) (string, error) {
+		return source, sourceErr
+	}
+
+	// The default matches a zstd source without recompressing its layers.
+	source = utils.CompressionZstd
+	assert.Equal(t, layerCompression{Type: utils.CompressionZstd},
+		resolveLayerCompression(context.Background(), "", "example.com/app:1.0", platform))
+
+	// An explicit compression wins and recompresses every layer.
+	assert.Equal(t, layerCompression{Type: utils.CompressionGzip, Force: true},
+		resolveLayerCompression(context.Background(), utils.CompressionGzip, "example.com/app:1.0", platform))
+
+	// A source that can't be inspected keeps BuildKit's default.
+	source, sourceErr = "", errors.New("not found")
+	assert.Equal(t, layerCompression{},
+		resolveLayerCompression(context.Background(), "", "example.com/app:1.0", platform))
+}
+
+func TestOCIExportOptions(t *testing.T) {
+	orig := sourceLayerCompression
+	defer func() { sourceLayerCompression = orig }()
+
+	amd64 := ispec.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := ispec.Platform{OS: "linux", Architecture: "arm64"}
+	s390x := ispec.Platform{OS: "linux", Architecture: "s390x"}
+	sourceLayerCompression = func(_ context.Context, _ string, p *ispec.Platform) (string, error) {
+		if p.Architecture == "arm64" {
+			return utils.CompressionZstd, nil
+		}
+		return utils.CompressionGzip, nil
+	}
+	platforms := []types.PatchPlatform{{Platform: amd64}, {Platform: arm64}, {Platform: s390x, ShouldPreserve: true}}
+
+	// Each patched platform matches the compression of its source image
+	export := ociExportOptions(context.Background(), "", "example.com/app:1.0", platforms, nil)
+	assert.Equal(t, map[string]string{"linux/amd64": utils.CompressionGzip, "linux/arm64": utils.CompressionZstd}, export.Compression)
+	assert.False(t, export.ForceCompression)
+
+	export = ociExportOptions(context.Background(), utils.CompressionZstd, "example.com/app:1.0", platforms, map[string]string{"a": "b"})
+	assert.Equal(t, map[string]string{"linux/amd64": utils.CompressionZstd, "linux/arm64": utils.CompressionZstd}, export.Compression)
+	assert.True(t, export.ForceCompression)
+	assert.Equal(t, map[string]string{"a": "b"}, export.Annotations)
+}
+
+func TestCommonLayerCompression(t *testing.T) {
+	const (
+		zstdLayer = "application/vnd.oci.image.layer.v1.tar+zstd"
+		gzipLayer = "application/vnd.oci.image.layer.v1.tar+gzip"
+	)
+	assert.Equal(t, utils.CompressionZstd, commonLayerCompression([]string{zstdLayer, zstdLayer}))
+	assert.Equal(t, utils.CompressionGzip, commonLayerCompression([]string{gzipLayer, "application/vnd.in-toto+json"}))
+	assert.Empty(t, commonLayerCompression([]string{gzipLayer, zstdLayer}))
+	assert.Empty(t, commonLayerCompression(nil))
+}
+
+func TestCompressionSupportWarning(t *testing.T) {
+	assert.Empty(t, compressionSupportWarning(""))
+	assert.Empty(t, compressionSupportWarning(utils.CompressionGzip))
+	assert.Contains(t, compressionSupportWarning(utils.CompressionZstd), "Docker 23+")
+	assert.Contains(t, compressionSupportWarning(utils.CompressionEstargz), "stargz snapshotter")
+	assert.Contains(t, compressionSupportWarning(utils.CompressionUncompressed), "reject uncompressed layers")
+}
@@ -0,0 +1,90 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/containerd/platforms"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/report"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+// ErrVulnerabilitiesRemain is returned by --confirm-fixed when a re-scan of the patched
+// image still reports vulnerabilities the patch was meant to fix.
+var ErrVulnerabilitiesRemain = errors.New("targeted vulnerabilities remain after patching")
+
+// for testing.
+var scanPatchedImage = report.ScanImage
+
+// confirmFixed re-scans the patched image in result and fails if any of the
+// vulnerabilities it was patched for are still reported.
+func confirmFixed(ctx context.Context, opts *types.Options, reportOpts *report.ParseOptions, result *types.PatchResult) error {
+	if result == nil || result.PatchedRef == nil {
+		return nil
+	}
+	if len(result.FixedCVEs) == 0 {
+		log.Infof("No targeted vulnerabilities to confirm for %s", result.PatchedRef)
+		return nil
+	}
+
+	dir, err := os.MkdirTemp(opts.WorkingFolder, "copa-confirm-")
+	if err != nil {
+		return fmt.Errorf("failed to create confirmation scan directory: %w", err)
+	}
+	defer removeIfNotDebug(dir)
+
+	scanOpts := &report.ScanOptions{
+		Scanner:   opts.Scanner,
+		Image:     result.PatchedRef.String(),
+		Output:    filepath.Join(dir, "confirm.json"),
+		ExtraArgs: opts.ScannerArgs,
+	}
+	// A platform of a multi-platform image has to be picked out of the index, or the
+	// scanner would look at the platform of the host instead.
+	if result.Platform.OS != "" {
+		scanOpts.Platform = platforms.Format(result.Platform)
+	}
+	log.Infof("Re-scanning %s to confirm %d vulnerabilities were fixed", scanOpts.Image, len(result.FixedCVEs))
+	if err := scanPatchedImage(ctx, scanOpts); err != nil {
+		return fmt.Errorf("failed to re-scan patched image %s: %w", scanOpts.Image, err)
+	}
+
+	manifest, err := report.TryParseScanReportWithOptions(scanOpts.Output, opts.Scanner, opts.PkgTypes, opts.LibraryPatchLevel, reportOpts)
+	if err != nil {
+		return fmt.Errorf("failed to parse re-scan report for %s: %w", scanOpts.Image, err)
+	}
+
+	remaining := remainingVulnerabilities(result.FixedCVEs, manifest)
+	if len(remaining) > 0 {
+		return fmt.Errorf("%w in %s: %s", ErrVulnerabilitiesRemain, scanOpts.Image, strings.Join(remaining, ", "))
+	}
+	log.Infof("Confirmed %d targeted vulnerabilities are no longer reported for %s", len(result.FixedCVEs), scanOpts.Image)
+	return nil
+}
+
+// remainingVulnerabilities returns the sorted IDs from targeted that the re-scan
+// manifest still reports as fixable.
+func remainingVulnerabilities(targeted []string, manifest *unversioned.UpdateManifest) []string {
+	if manifest == nil {
+		return nil
+	}
+	var remaining []string
+	for _, list := range [][]unversioned.UpdatePackage{manifest.OSUpdates, manifest.LangUpdates} {
+		for _, u := range list {
+			if slices.Contains(targeted, u.VulnerabilityID) && !slices.Contains(remaining, u.VulnerabilityID) {
+				log.Warnf("%s is still reported for %s %s (fixed in %s)", u.VulnerabilityID, u.Name, u.InstalledVersion, u.FixedVersion)
+				remaining = append(remaining, u.VulnerabilityID)
+			}
+		}
+	}
+	slices.Sort(remaining)
+	return remaining
+}
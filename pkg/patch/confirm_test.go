@@ -0,0 +1,83 @@
+package patch
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/distribution/reference"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/report"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+const confirmScanReport = `{"apiVersion":"v1alpha1","metadata":{"os":{"type":"alpine","version":"3.19.0"},"config":{"arch":"amd64"}},"updates":[
+{"name":"libcrypto3","installedVersion":"3.1.4-r1","fixedVersion":"3.1.4-r5","vulnerabilityID":"CVE-2024-0727"},
+{"name":"busybox","installedVersion":"1.36.1-r15","fixedVersion":"1.36.1-r19","vulnerabilityID":"CVE-2023-42363"}]}`
+
+func TestRemainingVulnerabilities(t *testing.T) {
+	manifest := &unversioned.UpdateManifest{
+		OSUpdates: []unversioned.UpdatePackage{
+			{Name: "libssl3", VulnerabilityID: "CVE-2024-0727"},
+			{Name: "libcrypto3", VulnerabilityID: "CVE-2024-0727"},
+			{Name: "busybox", VulnerabilityID: "CVE-2023-42363"},
+		},
+		LangUpdates: []unversioned.UpdatePackage{
+			{Name: "requests", VulnerabilityID: "CVE-2024-35195"},
+		},
+	}
+
+	assert.Equal(t, []string{"CVE-2024-0727", "CVE-2024-35195"}, remainingVulnerabilities([]string{"CVE-2024-35195", "CVE-2024-0727", "CVE-2024-9999"}, manifest))
+	assert.Empty(t, remainingVulnerabilities([]string{"CVE-2024-9999"}, manifest))
+	assert.Empty(t, remainingVulnerabilities([]string{"CVE-2024-0727"}, nil))
+}
+
+func TestConfirmFixed(t *testing.T) {
+	ref, err := reference.ParseNormalizedNamed("docker.io/library/alpine:3.19-patched")
+	require.NoError(t, err)
+
+	origScan := scanPatchedImage
+	defer func() { scanPatchedImage = origScan }()
+	var scanned, scannedPlatform string
+	scanPatchedImage = func(_ context.Context, opts *report.ScanOptions) error {
+		scanned = opts.Image
+		scannedPlatform = opts.Platform
+		return os.WriteFile(opts.Output, []byte(confirmScanReport), 0o600)
+	}
+
+	opts := &types.Options{Scanner: "native", WorkingFolder: t.TempDir()}
+
+	t.Run("fails when a targeted vulnerability remains", func(t *testing.T) {
+		result := &types.PatchResult{PatchedRef: ref, FixedCVEs: []string{"CVE-2024-0727", "CVE-2024-1234"}}
+		err := confirmFixed(context.Background(), opts, nil, result)
+		require.ErrorIs(t, err, ErrVulnerabilitiesRemain)
+		assert.Contains(t, err.Error(), "CVE-2024-0727")
+		assert.NotContains(t, err.Error(), "CVE-2024-1234")
+		assert.Equal(t, ref.String(), scanned)
+	})
+
+	t.Run("passes when untargeted vulnerabilities remain", func(t *testing.T) {
+		result := &types.PatchResult{PatchedRef: ref, FixedCVEs: []string{"CVE-2024-1234"}}
+		assert.NoError(t, confirmFixed(context.Background(), opts, nil, result))
+	})
+
+	t.Run("scans the patched platform", func(t *testing.T) {
+		result := &types.PatchResult{
+			PatchedRef: ref,
+			Platform:   ispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			FixedCVEs:  []string{"CVE-2024-1234"},
+		}
+		require.NoError(t, confirmFixed(context.Background(), opts, nil, result))
+		assert.Equal(t, "linux/arm64/v8", scannedPlatform)
+	})
+
+	t.Run("skips the scan with nothing to confirm", func(t *testing.T) {
+		scanned = ""
+		assert.NoError(t, confirmFixed(context.Background(), opts, nil, &types.PatchResult{PatchedRef: ref}))
+		assert.Empty(t, scanned)
+	})
+}
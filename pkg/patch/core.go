@@ -3,6 +3,8 @@ package patch
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/exporter/containerimage/exptypes"
@@ -42,8 +44,75 @@ type Options struct {
 	// Toolchain patch level (e.g., "patch", "minor", "major"; empty = disabled)
 	ToolchainPatchLevel string
 
+	// Only update direct Node.js dependencies (skip transitive packages)
+	NodeDirectOnly bool
+
+	// Also update Node.js devDependencies instead of pruning them
+	NodeIncludeDev bool
+
 	// EOL configuration
 	ExitOnEOL bool
+
+	// Maximum number of concurrent package downloads (0 = package manager default)
+	MaxConcurrentDownloads int
+
+	// Shell command run inside the patched image; a non-zero exit fails the patch
+	SmokeTest string
+
+	// Pinned repository URLs keyed by package type (deb, apk)
+	RepoSnapshots map[string]string
+
+	// Repository mirror URLs keyed by package type (deb, apk, rpm)
+	RepoMirrors map[string]string
+
+	// Upgrade Debian and Ubuntu packages from the security suite only
+	APTSecurityOnly bool
+
+	// Package manager commands (apk, apt-get, npm) mapped to their path in the image
+	PkgMgrPaths map[string]string
+
+	// Called with Updates before anything is installed; may modify them in place.
+	// An error aborts the patch. See types.Options.ManifestTransform.
+	ManifestTransform func(*unversioned.UpdateManifest) error
+
+	// User the patched image runs as, and paths chowned to that user (empty = keep the original user)
+	PatchedUser      string
+	PatchedUserChown []string
+
+	// Labels added to the patched image config
+	Labels map[string]string
+
+	// Patch the image as a fresh image even if it has a BaseImage label
+	NoRebase bool
+
+	// Where the image and its base images are resolved from (empty = missing)
+	Pull buildkit.PullPolicy
+
+	// Add the changelog of applied updates to the patched image
+	ChangelogInImage bool
+
+	// Export the patch as one layer on top of the original image layers
+	SinglePatchLayer bool
+
+	// Solve only the files the patch changed instead of the patched image
+	ExportDiff bool
+
+	// File the LLB definition of the patched image is written to before it is solved
+	// (empty = no dump)
+	DumpLLB string
+
+	// Installed OS packages to upgrade to their latest versions along with the
+	// vulnerable ones in Updates (nil = only Updates)
+	InstalledOSPackages unversioned.UpdatePackages
+
+	// Shell snippets run on the image before any update is installed and after all
+	// updates are installed (empty = no hook)
+	PrePatchScript  string
+	PostPatchScript string
+
+	// Package manager to use instead of the one for the image's OS type: apt, apk, rpm,
+	// or npm to patch only Node.js packages (empty = detect)
+	ForcePkgManager string
 }
 
 // Result contains the result of the core patching operation.
@@ -53,12 +122,21 @@ type Result struct {
 
 	// Package manager information
 	PackageType      string
+	PackageTool      string
 	ErroredPackages  []string
 	ValidatedUpdates []unversioned.UpdatePackage
+	FixedCVEs        []string
+
+	// Packages left alone on purpose, such as transitive npm dependencies in
+	// direct-only mode. Like errored packages, they are not counted as fixed.
+	SkippedPackages []string
 
 	// BuildKit state and config (only set if ReturnState is true)
 	PatchedState *llb.State
 	ConfigData   []byte
+
+	// The patched image and the images its BaseImage labels lead to
+	BaseChain []types.BaseImageLink
 }
 
 // Context wraps the context and gateway client for core operations.
@@ -94,21 +172,44 @@ func ExecutePatchCore(patchCtx *Context, opts *Options) (*Result, error) {
 	ignoreError := opts.IgnoreError
 	updates := opts.Updates
 
+	if opts.ManifestTransform != nil && updates != nil {
+		if err := opts.ManifestTransform(updates); err != nil {
+			err = fmt.Errorf("update manifest transform failed: %w", err)
+			trySendError(opts.ErrorChannel, err)
+			return nil, err
+		}
+	}
+
 	// Configure buildctl/client for use by package manager
-	config, err := buildkit.InitializeBuildkitConfig(ctx, c, opts.ImageName, &opts.TargetPlatform.Platform)
+	config, err := buildkit.InitializeBuildkitConfig(ctx, c, opts.ImageName, &opts.TargetPlatform.Platform, opts.NoRebase, opts.Pull.ResolveMode())
 	if err != nil {
 		trySendError(opts.ErrorChannel, err)
 		return nil, err
 	}
+	config.MaxConcurrentDownloads = opts.MaxConcurrentDownloads
+	config.RepoSnapshots = opts.RepoSnapshots
+	config.RepoMirrors = opts.RepoMirrors
+	config.APTSecurityOnly = opts.APTSecurityOnly
+	config.PkgMgrPaths = opts.PkgMgrPaths
+
+	// The package managers build on config.ImageState, so the pre-patch hook runs
+	// before any of them. The original image stays the base of a single patch layer.
+	originalState := config.ImageState
+	if opts.PrePatchScript != "" {
+		config.ImageState = runPatchHook(config.ImageState, prePatchHook, opts.PrePatchScript)
+	}
 
 	// Determine if we need OS-level patching or language-only patching.
 	// Language-only mode applies when the report has lang updates but no OS updates
 	// (common for scratch/distroless/busybox Go binary images).
-	langOnlyMode := updates != nil && len(updates.OSUpdates) == 0 && len(updates.LangUpdates) > 0
+	langOnlyMode := len(opts.InstalledOSPackages) == 0 && updates != nil && len(updates.OSUpdates) == 0 && len(updates.LangUpdates) > 0
+	if opts.ForcePkgManager == pkgmgr.ForceNPM {
+		langOnlyMode = true
+	}
 
 	var manager pkgmgr.PackageManager
 	var patchedImageState *llb.State
-	var errPkgs []string
+	var errPkgs, skippedPkgs []string
 
 	if langOnlyMode {
 		log.Debug("No OS package updates found; skipping OS package manager setup and proceeding with language updates only.")
@@ -118,22 +219,43 @@ func ExecutePatchCore(patchCtx *Context, opts *Options) (*Result, error) {
 		// Create package manager helper (requires OS metadata in the report)
 		manager, err = setupPackageManager(ctx, c, config, opts)
 		if err != nil {
+			// Without a report we probe /etc/os-release; on a scratch image there is
+			// nothing to probe, so report that a rebuild is needed instead.
+			if updates == nil && isEmptyRootfs(ctx, c, &originalState) {
+				err = &types.RebuildRequiredError{
+					Image:  opts.ImageName,
+					Reason: emptyRootfsReason,
+					Err:    err,
+				}
+			}
+			trySendError(opts.ErrorChannel, err)
+			return nil, err
+		}
+
+		osUpdates := withInstalledOSPackages(opts.Updates, opts.InstalledOSPackages)
+
+		if err := manager.Preflight(ctx); err != nil {
 			trySendError(opts.ErrorChannel, err)
 			return nil, err
 		}
 
 		var installErr error
-		patchedImageState, errPkgs, installErr = manager.InstallUpdates(ctx, opts.Updates, opts.IgnoreError)
+		patchedImageState, errPkgs, installErr = manager.InstallUpdates(ctx, osUpdates, opts.IgnoreError)
 		if installErr != nil {
 			trySendError(opts.ErrorChannel, installErr)
 			return nil, installErr
 		}
+		patchedImageState = pkgmgr.PreserveFileCapabilities(ctx, c, &config.ImageState, patchedImageState)
 	}
 
 	// For normal Docker export, continue with solving but preserve states
 	// Handle Language Specific Updates
 	if updates != nil && len(updates.LangUpdates) > 0 {
-		languageManagers := langmgr.GetLanguageManagers(config, workingFolder, updates, opts.ToolchainPatchLevel)
+		languageManagers := langmgr.GetLanguageManagers(config, workingFolder, updates, langmgr.Options{
+			ToolchainPatchLevel: opts.ToolchainPatchLevel,
+			NodeDirectOnly:      opts.NodeDirectOnly,
+			NodeIncludeDev:      opts.NodeIncludeDev,
+		})
 		var langErrPkgsFromAllManagers []string
 		var combinedLangError error
 
@@ -158,13 +280,34 @@ func ExecutePatchCore(patchCtx *Context, opts *Options) (*Result, error) {
 					combinedLangError = fmt.Errorf("%w; %v", combinedLangError, tempErr)
 				}
 				if !ignoreError {
-					trySendError(opts.ErrorChannel, combinedLangError)
-					return nil, combinedLangError
+					langErrPkgsFromAllManagers = append(langErrPkgsFromAllManagers, tempErrPkgs...)
+					break
 				}
 			}
 			if len(tempErrPkgs) > 0 {
 				langErrPkgsFromAllManagers = append(langErrPkgsFromAllManagers, tempErrPkgs...)
 			}
+			if reporter, ok := individualLangManager.(langmgr.SkippedPackagesReporter); ok {
+				skippedPkgs = append(skippedPkgs, reporter.SkippedPackages()...)
+			}
+		}
+
+		// Scratch images have no shell or package manager, so anything the
+		// language managers could not fix (e.g. Go binaries without source
+		// provenance) can only be remediated by rebuilding the image.
+		if langOnlyMode && (combinedLangError != nil || len(langErrPkgsFromAllManagers) > 0) &&
+			isEmptyRootfs(ctx, c, &originalState) {
+			rebuildErr := &types.RebuildRequiredError{
+				Image:      opts.ImageName,
+				Reason:     emptyRootfsReason,
+				Components: rebuildComponents(updates, langErrPkgsFromAllManagers),
+				Err:        combinedLangError,
+			}
+			if combinedLangError != nil && !ignoreError {
+				trySendError(opts.ErrorChannel, rebuildErr)
+				return nil, rebuildErr
+			}
+			log.Warn(rebuildErr.Error())
 		}
 
 		// Update the main patchedImageState with the result of all language managers
@@ -186,6 +329,64 @@ func ExecutePatchCore(patchCtx *Context, opts *Options) (*Result, error) {
 		log.Debug("No language-specific updates found in the manifest.")
 	}
 
+	// Neither errored nor skipped packages were updated
+	notUpdated := append(slices.Clone(errPkgs), skippedPkgs...)
+
+	if opts.PostPatchScript != "" {
+		withHook := runPatchHook(*patchedImageState, postPatchHook, opts.PostPatchScript)
+		patchedImageState = &withHook
+	}
+
+	if opts.PatchedUser != "" {
+		patchedImageState, err = setPatchedUser(config, patchedImageState, opts.PatchedUser, opts.PatchedUserChown)
+		if err != nil {
+			trySendError(opts.ErrorChannel, err)
+			return nil, err
+		}
+	}
+
+	if len(opts.Labels) > 0 {
+		config.ConfigData, err = buildkit.SetConfigLabels(config.ConfigData, opts.Labels)
+		if err != nil {
+			trySendError(opts.ErrorChannel, err)
+			return nil, err
+		}
+	}
+
+	if opts.ChangelogInImage {
+		withLog := withChangelog(*patchedImageState, renderChangelog(opts.ImageName, updates, notUpdated, false))
+		patchedImageState = &withLog
+	}
+
+	if opts.SinglePatchLayer {
+		squashed := withSinglePatchLayer(originalState, *patchedImageState)
+		patchedImageState = &squashed
+	}
+
+	if opts.SmokeTest != "" {
+		if canRunPlatform(opts.TargetPlatform) {
+			if err := runSmokeTest(ctx, c, patchedImageState, opts.SmokeTest); err != nil {
+				trySendError(opts.ErrorChannel, err)
+				return nil, err
+			}
+		} else {
+			log.Warnf("Skipping smoke test: %s", emulationUnavailableReason(opts.TargetPlatform))
+		}
+	}
+
+	if opts.ExportDiff {
+		diff := patchDiffLayer(originalState, *patchedImageState)
+		patchedImageState = &diff
+	}
+
+	if opts.DumpLLB != "" {
+		if err := dumpLLB(ctx, *patchedImageState, opts.TargetPlatform.Platform, opts.DumpLLB); err != nil {
+			trySendError(opts.ErrorChannel, err)
+			return nil, err
+		}
+		log.Infof("Wrote the LLB of the patched image to %s", opts.DumpLLB)
+	}
+
 	// Preserve the state and config for potential OCI export use
 	// This allows both Docker export AND OCI layout creation from the same patching operation
 	preservedState := patchedImageState
@@ -196,10 +397,14 @@ func ExecutePatchCore(patchCtx *Context, opts *Options) (*Result, error) {
 		return &Result{
 			Result:           nil, // No result when returning state
 			PackageType:      packageType(manager),
+			PackageTool:      packageTool(manager),
 			ErroredPackages:  errPkgs,
-			ValidatedUpdates: getValidatedUpdates(opts.Updates, errPkgs),
+			SkippedPackages:  skippedPkgs,
+			ValidatedUpdates: getValidatedUpdates(opts.Updates, notUpdated),
+			FixedCVEs:        fixedVulnerabilities(opts.Updates, notUpdated),
 			PatchedState:     preservedState,
 			ConfigData:       preservedConfig,
+			BaseChain:        config.BaseChain,
 		}, nil
 	}
 
@@ -227,6 +432,23 @@ func ExecutePatchCore(patchCtx *Context, opts *Options) (*Result, error) {
 		trySendError(opts.ErrorChannel, err)
 		return nil, err
 	}
+
+	// Record the applied updates in the image history so `docker history` shows them
+	fixed, err = appendPatchHistory(fixed, patchHistoryEntries(updates, notUpdated), time.Now())
+	if err != nil {
+		trySendError(opts.ErrorChannel, err)
+		return nil, err
+	}
+	fixed, err = redactHistoryCredentials(fixed, utils.ProxyPasswords(ctx))
+	if err != nil {
+		trySendError(opts.ErrorChannel, err)
+		return nil, err
+	}
+
+	if err := buildkit.VerifyRuntimeConfig(config.ConfigData, fixed); err != nil {
+		trySendError(opts.ErrorChannel, err)
+		return nil, err
+	}
 	res.AddMeta(exptypes.ExporterImageConfigKey, fixed)
 
 	// Return result with BOTH the solved result AND preserved states
@@ -234,13 +456,78 @@ func ExecutePatchCore(patchCtx *Context, opts *Options) (*Result, error) {
 	return &Result{
 		Result:           res,
 		PackageType:      packageType(manager),
+		PackageTool:      packageTool(manager),
 		ErroredPackages:  errPkgs,
-		ValidatedUpdates: getValidatedUpdates(opts.Updates, errPkgs),
+		SkippedPackages:  skippedPkgs,
+		ValidatedUpdates: getValidatedUpdates(opts.Updates, notUpdated),
+		FixedCVEs:        fixedVulnerabilities(opts.Updates, notUpdated),
 		PatchedState:     preservedState,  // Always preserve for OCI export
 		ConfigData:       preservedConfig, // Always preserve for OCI export
+		BaseChain:        config.BaseChain,
 	}, nil
 }
 
+// setPatchedUser sets the user the patched image runs as and chowns paths to it, so
+// files the package managers left owned by root stay writable by that user. The
+// config change is made to config.ConfigData so every export path picks it up.
+func setPatchedUser(config *buildkit.Config, st *llb.State, user string, paths []string) (*llb.State, error) {
+	configData, err := buildkit.SetConfigUser(config.ConfigData, user)
+	if err != nil {
+		return nil, err
+	}
+	config.ConfigData = configData
+	log.Infof("Setting patched image user to %s", user)
+
+	if len(paths) == 0 {
+		return st, nil
+	}
+	chowned := st.Run(
+		llb.Args(append([]string{"chown", "-R", user}, paths...)),
+		llb.WithCustomNamef("Changing ownership of %s to %s", strings.Join(paths, ", "), user),
+	).Root()
+	return &chowned, nil
+}
+
+// emptyRootfsReason explains why an image needs to be rebuilt rather than patched.
+const emptyRootfsReason = "image has an empty root filesystem (no shell or OS package manager)"
+
+// isEmptyRootfs reports whether the image looks like a FROM scratch image, i.e.
+// it has neither /etc/os-release nor /bin/sh. Solve failures are not treated as
+// an empty rootfs since the files were never actually looked up.
+func isEmptyRootfs(ctx context.Context, c gwclient.Client, st *llb.State) bool {
+	for _, path := range []string{"/etc/os-release", "/bin/sh"} {
+		_, err := buildkit.TryExtractFileFromState(ctx, c, st, path)
+		if err == nil || !err.ReadFailed {
+			return false
+		}
+	}
+	log.Debug("Image has no /etc/os-release or /bin/sh; treating it as a scratch image")
+	return true
+}
+
+// rebuildComponents lists the language updates that could not be applied in
+// place. If errPkgs is empty, every language update is listed.
+func rebuildComponents(updates *unversioned.UpdateManifest, errPkgs []string) []types.RebuildComponent {
+	if updates == nil {
+		return nil
+	}
+	var components []types.RebuildComponent
+	for _, u := range updates.LangUpdates {
+		if len(errPkgs) > 0 && !slices.Contains(errPkgs, u.Name) {
+			continue
+		}
+		components = append(components, types.RebuildComponent{
+			Name:             u.Name,
+			Type:             u.Type,
+			InstalledVersion: u.InstalledVersion,
+			FixedVersion:     u.FixedVersion,
+			PkgPath:          u.PkgPath,
+			VulnerabilityID:  u.VulnerabilityID,
+		})
+	}
+	return components
+}
+
 // getValidatedUpdates extracts validated updates (excluding errored packages).
 func getValidatedUpdates(updates *unversioned.UpdateManifest, errPkgs []string) []unversioned.UpdatePackage {
 	var validatedUpdates []unversioned.UpdatePackage
@@ -254,6 +541,25 @@ func getValidatedUpdates(updates *unversioned.UpdateManifest, errPkgs []string)
 	return validatedUpdates
 }
 
+// fixedVulnerabilities returns the sorted, de-duplicated vulnerability IDs addressed by
+// the OS and language updates that were applied (excluding errored packages).
+func fixedVulnerabilities(updates *unversioned.UpdateManifest, errPkgs []string) []string {
+	if updates == nil {
+		return nil
+	}
+	var ids []string
+	for _, list := range [][]unversioned.UpdatePackage{updates.OSUpdates, updates.LangUpdates} {
+		for _, update := range list {
+			if update.VulnerabilityID == "" || slices.Contains(errPkgs, update.Name) {
+				continue
+			}
+			ids = append(ids, update.VulnerabilityID)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
 // packageType returns the package type string from the manager, or
 // "library" when no OS package manager is available (language-only mode).
 func packageType(manager pkgmgr.PackageManager) string {
@@ -263,9 +569,22 @@ func packageType(manager pkgmgr.PackageManager) string {
 	return utils.PkgTypeLibrary
 }
 
+// packageTool returns the tool manager installs updates with in the image, if it
+// chooses among several.
+func packageTool(manager pkgmgr.PackageManager) string {
+	if reporter, ok := manager.(pkgmgr.PackageToolReporter); ok {
+		return reporter.PackageTool()
+	}
+	return ""
+}
+
 // setupPackageManager creates and configures the appropriate package manager
 // based on the image's operating system.
 func setupPackageManager(ctx context.Context, c gwclient.Client, config *buildkit.Config, opts *Options) (pkgmgr.PackageManager, error) {
+	if opts.ForcePkgManager != "" {
+		return setupForcedPackageManager(ctx, c, config, opts)
+	}
+
 	if opts.Updates == nil {
 		// No vulnerability report provided - detect OS from image
 		fileBytes, err := buildkit.ExtractFileFromState(ctx, c, &config.ImageState, "/etc/os-release")
@@ -303,9 +622,52 @@ func setupPackageManager(ctx context.Context, c gwclient.Client, config *buildki
 		return pkgmgr.GetPackageManager(osType, osVersion, config, opts.WorkingFolder)
 	}
 
-	// Use OS information from the vulnerability report
-	if opts.Updates.Metadata.OS.Type == "" || opts.Updates.Metadata.OS.Version == "" {
-		return nil, fmt.Errorf("vulnerability report metadata is incomplete: OS type=%q, version=%q", opts.Updates.Metadata.OS.Type, opts.Updates.Metadata.OS.Version)
+	// Use OS information from the vulnerability report. Rolling distros like Wolfi
+	// may have no version to report.
+	osMetadata := opts.Updates.Metadata.OS
+	if osMetadata.Type == "" || (osMetadata.Version == "" && !utils.IsRollingRelease(osMetadata.Type)) {
+		return nil, fmt.Errorf("vulnerability report metadata is incomplete: OS type=%q, version=%q", osMetadata.Type, osMetadata.Version)
+	}
+	return pkgmgr.GetPackageManager(osMetadata.Type, osMetadata.Version, config, opts.WorkingFolder)
+}
+
+// setupForcedPackageManager creates the package manager named by opts.ForcePkgManager.
+// The OS type and version are still read from the report or /etc/os-release when
+// available, but an unknown or unsupported OS type doesn't stop the patch.
+func setupForcedPackageManager(ctx context.Context, c gwclient.Client, config *buildkit.Config, opts *Options) (pkgmgr.PackageManager, error) {
+	var osType, osVersion string
+	if opts.Updates != nil {
+		osType, osVersion = opts.Updates.Metadata.OS.Type, opts.Updates.Metadata.OS.Version
+	} else if fileBytes, err := buildkit.ExtractFileFromState(ctx, c, &config.ImageState, "/etc/os-release"); err == nil {
+		if osInfo, err := common.GetOSInfo(ctx, fileBytes); err == nil {
+			osType, osVersion = osInfo.Type, osInfo.Version
+		}
+	}
+	log.Infof("Using the %s package manager as requested by --force-pkg-manager", opts.ForcePkgManager)
+	return pkgmgr.GetForcedPackageManager(ctx, opts.ForcePkgManager, osType, osVersion, config, opts.WorkingFolder)
+}
+
+// withInstalledOSPackages returns a copy of updates that also asks for each package of
+// installed, so the package manager upgrades them to the latest versions it has. A
+// package without a fix in updates is asked for at least its installed version, which
+// keeps it from being reported as failed when it has no newer version, and is marked
+// InstalledOnly so it is installed by name rather than at that version.
+func withInstalledOSPackages(updates *unversioned.UpdateManifest, installed unversioned.UpdatePackages) *unversioned.UpdateManifest {
+	if updates == nil || len(installed) == 0 {
+		return updates
+	}
+	withInstalled := *updates
+	withInstalled.OSUpdates = slices.Clone(updates.OSUpdates)
+	for _, pkg := range installed {
+		if pkg.InstalledVersion == "" {
+			continue
+		}
+		withInstalled.OSUpdates = append(withInstalled.OSUpdates, unversioned.UpdatePackage{
+			Name:             pkg.Name,
+			InstalledVersion: pkg.InstalledVersion,
+			FixedVersion:     pkg.InstalledVersion,
+			Status:           unversioned.InstalledOnly,
+		})
 	}
-	return pkgmgr.GetPackageManager(opts.Updates.Metadata.OS.Type, opts.Updates.Metadata.OS.Version, config, opts.WorkingFolder)
+	return &withInstalled
 }
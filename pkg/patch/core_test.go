@@ -1,12 +1,17 @@
 package patch
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/moby/buildkit/client/llb"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/project-copacetic/copacetic/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 )
@@ -258,3 +263,139 @@ func TestOptions_ValidationScenarios(t *testing.T) {
 		})
 	}
 }
+
+func TestRebuildComponents(t *testing.T) {
+	updates := &unversioned.UpdateManifest{
+		LangUpdates: unversioned.LangUpdatePackages{
+			{Name: "golang.org/x/net", Type: utils.GoBinary, InstalledVersion: "v0.17.0", FixedVersion: "v0.23.0", PkgPath: "app", VulnerabilityID: "CVE-2023-45288"},
+			{Name: "stdlib", Type: utils.GoBinary, InstalledVersion: "v1.21.0", FixedVersion: "1.21.9", PkgPath: "app"},
+		},
+	}
+
+	t.Run("nil manifest", func(t *testing.T) {
+		assert.Nil(t, rebuildComponents(nil, nil))
+	})
+
+	t.Run("all lang updates when no errored packages", func(t *testing.T) {
+		components := rebuildComponents(updates, nil)
+		assert.Len(t, components, 2)
+	})
+
+	t.Run("only errored packages", func(t *testing.T) {
+		components := rebuildComponents(updates, []string{"golang.org/x/net"})
+		assert.Equal(t, []types.RebuildComponent{{
+			Name:             "golang.org/x/net",
+			Type:             utils.GoBinary,
+			InstalledVersion: "v0.17.0",
+			FixedVersion:     "v0.23.0",
+			PkgPath:          "app",
+			VulnerabilityID:  "CVE-2023-45288",
+		}}, components)
+	})
+}
+
+func TestFixedVulnerabilities(t *testing.T) {
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: []unversioned.UpdatePackage{
+			{Name: "openssl", VulnerabilityID: "CVE-2024-0002"},
+			{Name: "libssl3", VulnerabilityID: "CVE-2024-0002"},
+			{Name: "curl", VulnerabilityID: "CVE-2024-0001"},
+			{Name: "zlib", VulnerabilityID: "CVE-2024-0003"},
+		},
+		LangUpdates: []unversioned.UpdatePackage{
+			{Name: "requests", VulnerabilityID: "GHSA-xxxx-yyyy-zzzz"},
+			{Name: "urllib3"},
+		},
+	}
+
+	assert.Equal(t, []string{"CVE-2024-0001", "CVE-2024-0002", "GHSA-xxxx-yyyy-zzzz"}, fixedVulnerabilities(updates, []string{"zlib"}))
+	assert.Nil(t, fixedVulnerabilities(nil, nil))
+}
+
+func TestSetPatchedUser(t *testing.T) {
+	config := &buildkit.Config{ConfigData: []byte(`{"config": {"User": "root", "Cmd": ["app"]}}`)}
+	st := llb.Image("docker.io/library/alpine:3.19")
+
+	got, err := setPatchedUser(config, &st, "nonroot", nil)
+	require.NoError(t, err)
+	assert.Same(t, &st, got)
+	assert.JSONEq(t, `{"config": {"User": "nonroot", "Cmd": ["app"]}}`, string(config.ConfigData))
+
+	got, err = setPatchedUser(config, &st, "1000:1000", []string{"/app", "/var/lib/app"})
+	require.NoError(t, err)
+	assert.NotSame(t, &st, got)
+	assert.JSONEq(t, `{"config": {"User": "1000:1000", "Cmd": ["app"]}}`, string(config.ConfigData))
+
+	_, err = setPatchedUser(config, &st, "bad user", nil)
+	assert.Error(t, err)
+}
+
+func TestExecutePatchCoreManifestTransformError(t *testing.T) {
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{{Name: "openssl", FixedVersion: "3.0.13-1~deb12u1"}},
+	}
+	errPolicy := errors.New("openssl is maintained in-house")
+	errCh := make(chan error, 1)
+
+	var seen *unversioned.UpdateManifest
+	_, err := ExecutePatchCore(&Context{Context: context.Background()}, &Options{
+		Updates:      updates,
+		ErrorChannel: errCh,
+		ManifestTransform: func(m *unversioned.UpdateManifest) error {
+			seen = m
+			return errPolicy
+		},
+	})
+
+	// The patch is aborted before BuildKit is touched.
+	require.ErrorIs(t, err, errPolicy)
+	assert.Same(t, updates, seen)
+	assert.ErrorIs(t, <-errCh, errPolicy)
+}
+
+func TestSetupPackageManagerForced(t *testing.T) {
+	opts := &Options{
+		Updates: &unversioned.UpdateManifest{
+			Metadata: unversioned.Metadata{OS: unversioned.OS{Type: utils.OSTypeDebian, Version: "12"}},
+		},
+		ForcePkgManager: "apk",
+	}
+
+	// The report says debian, but the forced manager wins.
+	manager, err := setupPackageManager(context.Background(), nil, &buildkit.Config{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "apk", manager.GetPackageType())
+
+	// Without --force-pkg-manager, an OS type missing from the report is an error.
+	opts.Updates.Metadata.OS = unversioned.OS{}
+	_, err = setupPackageManager(context.Background(), nil, &buildkit.Config{}, &Options{Updates: opts.Updates})
+	require.Error(t, err)
+
+	manager, err = setupPackageManager(context.Background(), nil, &buildkit.Config{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "apk", manager.GetPackageType())
+}
+
+func TestWithInstalledOSPackages(t *testing.T) {
+	updates := &unversioned.UpdateManifest{
+		Metadata:  unversioned.Metadata{OS: unversioned.OS{Type: "alpine", Version: "3.14.0"}},
+		OSUpdates: unversioned.UpdatePackages{{Name: "apk-tools", InstalledVersion: "2.12.5-r1", FixedVersion: "2.12.6-r0", VulnerabilityID: "CVE-2021-36159"}},
+	}
+	installed := unversioned.UpdatePackages{
+		{Name: "apk-tools", InstalledVersion: "2.12.5-r1"},
+		{Name: "busybox", InstalledVersion: "1.33.1-r2"},
+	}
+
+	got := withInstalledOSPackages(updates, installed)
+	assert.Equal(t, updates.Metadata, got.Metadata)
+	assert.Equal(t, unversioned.UpdatePackages{
+		{Name: "apk-tools", InstalledVersion: "2.12.5-r1", FixedVersion: "2.12.6-r0", VulnerabilityID: "CVE-2021-36159"},
+		{Name: "apk-tools", InstalledVersion: "2.12.5-r1", FixedVersion: "2.12.5-r1", Status: unversioned.InstalledOnly},
+		{Name: "busybox", InstalledVersion: "1.33.1-r2", FixedVersion: "1.33.1-r2", Status: unversioned.InstalledOnly},
+	}, got.OSUpdates)
+	// The report's updates, used for VEX output, are left alone.
+	assert.Len(t, updates.OSUpdates, 1)
+
+	assert.Same(t, updates, withInstalledOSPackages(updates, nil))
+	assert.Nil(t, withInstalledOSPackages(nil, installed))
+}
@@ -0,0 +1,127 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/util/imagetools"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// resolvePushDestinations parses the --push-to references the patched image is pushed
+// to in addition to its own name. A destination without a tag gets the patched tag, so
+// "--push-to dr.example.com/app" mirrors the primary push.
+func resolvePushDestinations(dests []string, patchedTag string) ([]reference.NamedTagged, error) {
+	resolved := make([]reference.NamedTagged, 0, len(dests))
+	for _, dest := range dests {
+		named, err := reference.ParseNormalizedNamed(dest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --push-to %q: %w", dest, err)
+		}
+		if _, ok := named.(reference.Digested); ok {
+			return nil, fmt.Errorf("invalid --push-to %q: must not include a digest", dest)
+		}
+		tagged, ok := named.(reference.NamedTagged)
+		if !ok {
+			tagged, err = reference.WithTag(named, patchedTag)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --push-to %q: %w", dest, err)
+			}
+		}
+		resolved = append(resolved, tagged)
+	}
+	return resolved, nil
+}
+
+// destinationNames returns the string form of dests for the image exporter.
+func destinationNames(dests []reference.NamedTagged) []string {
+	names := make([]string, 0, len(dests))
+	for _, dest := range dests {
+		names = append(names, dest.String())
+	}
+	return names
+}
+
+// indexPusher is the part of imagetools.Resolver that pushes a manifest list and copies
+// its platform images.
+type indexPusher interface {
+	Copy(ctx context.Context, src *imagetools.Source, dest reference.Named) error
+	Push(ctx context.Context, ref reference.Named, desc ispec.Descriptor, dt []byte) error
+}
+
+// pushIndexToDestinations pushes the index to each destination with pushIndex, so
+// every destination resolves to the same digest. A destination that fails doesn't keep
+// the index from being pushed to the others.
+func pushIndexToDestinations(
+	ctx context.Context,
+	pusher indexPusher,
+	srcRefs []*imagetools.Source,
+	desc ispec.Descriptor,
+	idxBytes []byte,
+	dests []reference.NamedTagged,
+	workers int,
+) error {
+	var errs []error
+	for _, dest := range dests {
+		if err := pushIndex(ctx, pusher, srcRefs, desc, idxBytes, dest, workers); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pushIndex copies each platform image into the repository of dest, up to workers at
+// once, and pushes the index to dest once all of them were copied. The images that
+// failed are reported and nothing is rolled back.
+func pushIndex(
+	ctx context.Context,
+	pusher indexPusher,
+	srcRefs []*imagetools.Source,
+	desc ispec.Descriptor,
+	idxBytes []byte,
+	dest reference.Named,
+	workers int,
+) error {
+	if err := copyPlatformImages(ctx, pusher, srcRefs, dest, workers); err != nil {
+		return fmt.Errorf("not pushing multi-platform manifest list to %s: %w", dest.String(), err)
+	}
+	if err := withRegistrySlot(ctx, func() error { return pusher.Push(ctx, dest, desc, idxBytes) }); err != nil {
+		return fmt.Errorf("failed to push multi-platform manifest list to %s: %w", dest.String(), err)
+	}
+	log.Infof("Pushed multi-platform manifest list %s@%s", dest.String(), desc.Digest)
+	return nil
+}
+
+// copyPlatformImages copies every image of srcRefs to dest, up to workers at a time. It
+// returns an error naming each image that could not be copied.
+func copyPlatformImages(ctx context.Context, pusher indexPusher, srcRefs []*imagetools.Source, dest reference.Named, workers int) error {
+	errs := make([]error, len(srcRefs))
+	var g errgroup.Group
+	g.SetLimit(max(workers, 1))
+	for i, src := range srcRefs {
+		g.Go(func() error {
+			if err := withRegistrySlot(ctx, func() error { return pusher.Copy(ctx, src, dest) }); err != nil {
+				errs[i] = fmt.Errorf("failed to copy %s: %w", src.Ref.String(), err)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return errors.Join(errs...)
+}
+
+// withRegistrySlot runs fn, a registry operation, once a registry slot is free.
+func withRegistrySlot(ctx context.Context, fn func() error) error {
+	release, err := utils.AcquireRegistrySlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
@@ -0,0 +1,177 @@
+package patch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/distribution/reference"
+	"github.com/docker/buildx/util/imagetools"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func TestResolvePushDestinations(t *testing.T) {
+	tests := []struct {
+		name    string
+		dests   []string
+		want    []string
+		wantErr string
+	}{
+		{name: "none", want: []string{}},
+		{
+			name:  "tag kept",
+			dests: []string{"dr.example.com/app:1.0-prod"},
+			want:  []string{"dr.example.com/app:1.0-prod"},
+		},
+		{
+			name:  "patched tag added",
+			dests: []string{"dr.example.com/team/app", "nginx"},
+			want:  []string{"dr.example.com/team/app:1.0-patched", "docker.io/library/nginx:1.0-patched"},
+		},
+		{
+			name:    "digest rejected",
+			dests:   []string{"dr.example.com/app@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+			wantErr: "must not include a digest",
+		},
+		{name: "invalid reference", dests: []string{"Not A Reference"}, wantErr: "invalid --push-to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePushDestinations(tt.dests, "1.0-patched")
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, destinationNames(got))
+		})
+	}
+}
+
+// recordingRegistry serves a registry that records the manifests written to it in
+// order and rejects the manifests in reject.
+type recordingRegistry struct {
+	handler http.Handler
+	reject  map[string]bool
+
+	mu        sync.Mutex
+	manifests []string
+}
+
+func (r *recordingRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPut && strings.Contains(req.URL.Path, "/manifests/") {
+		if r.reject[path.Base(req.URL.Path)] {
+			http.Error(w, "manifest invalid", http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		r.manifests = append(r.manifests, strings.TrimPrefix(req.URL.Path, "/v2/"))
+		r.mu.Unlock()
+	}
+	r.handler.ServeHTTP(w, req)
+}
+
+func TestPushIndex(t *testing.T) {
+	reg := &recordingRegistry{handler: registry.New(registry.WithReferrersSupport(true))}
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	// Platform images in the source repository, as the patched and kept platforms are
+	var srcRefs []*imagetools.Source
+	var children []string
+	for _, arch := range []string{"amd64", "arm64", "s390x", "ppc64le"} {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		ref, err := name.ParseReference(host + "/src/app:1.0-patched-" + arch)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+		dgst, err := img.Digest()
+		require.NoError(t, err)
+		size, err := img.Size()
+		require.NoError(t, err)
+		mediaType, err := img.MediaType()
+		require.NoError(t, err)
+
+		named, err := reference.ParseNormalizedNamed(ref.String())
+		require.NoError(t, err)
+		srcRefs = append(srcRefs, &imagetools.Source{Ref: named, Desc: ispec.Descriptor{
+			MediaType: string(mediaType),
+			Digest:    digest.Digest(dgst.String()),
+			Size:      size,
+			Platform:  &ispec.Platform{OS: "linux", Architecture: arch},
+		}})
+		children = append(children, dgst.String())
+	}
+
+	ctx := context.Background()
+	resolver := imagetools.New(imagetools.Opt{Auth: utils.RegistryAuth()})
+	idxBytes, desc, _, err := resolver.Combine(ctx, srcRefs, nil, false, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		repo    string
+		workers int
+		reject  map[string]bool
+		wantErr string
+	}{
+		{name: "sequential", repo: "sequential/app", workers: 1},
+		{name: "parallel", repo: "parallel/app", workers: 4},
+		{
+			name:    "failed copy skips the manifest list",
+			repo:    "failed/app",
+			workers: 4,
+			reject:  map[string]bool{children[2]: true},
+			wantErr: "failed to copy " + srcRefs[2].Ref.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg.mu.Lock()
+			reg.reject, reg.manifests = tt.reject, nil
+			reg.mu.Unlock()
+
+			dest, err := reference.ParseNormalizedNamed(host + "/" + tt.repo + ":1.0-patched")
+			require.NoError(t, err)
+			err = pushIndex(ctx, resolver, srcRefs, desc, idxBytes, dest, tt.workers)
+
+			indexPut := tt.repo + "/manifests/1.0-patched"
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				assert.ErrorContains(t, err, "not pushing multi-platform manifest list to "+dest.String())
+				assert.NotContains(t, reg.manifests, indexPut)
+				return
+			}
+			require.NoError(t, err)
+
+			// Every platform image was written before the index, which came last
+			require.NotEmpty(t, reg.manifests)
+			assert.Equal(t, indexPut, reg.manifests[len(reg.manifests)-1])
+			for _, child := range children {
+				assert.Contains(t, reg.manifests[:len(reg.manifests)-1], tt.repo+"/manifests/"+child)
+			}
+
+			pushed, err := name.ParseReference(dest.String())
+			require.NoError(t, err)
+			got, err := remote.Get(pushed)
+			require.NoError(t, err)
+			assert.Equal(t, desc.Digest.String(), got.Digest.String())
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// dumpLLB marshals st for platform and writes its definition to path, in the
+// protobuf format read by buildctl debug dump-llb.
+func dumpLLB(ctx context.Context, st llb.State, platform ispec.Platform, path string) error {
+	def, err := st.Marshal(ctx, llb.Platform(platform))
+	if err != nil {
+		return fmt.Errorf("failed to marshal LLB for %s: %w", path, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create LLB dump: %w", err)
+	}
+	if err := llb.WriteTo(def, f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write LLB dump %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// platformLLBDumpPath inserts the platform before the extension of path, e.g.
+// llb-arm64-v8.pb for llb.pb, so the platforms of a multi-platform image don't
+// overwrite each other's dump.
+func platformLLBDumpPath(path string, platform ispec.Platform) string {
+	ext := filepath.Ext(path)
+	return archTag(strings.TrimSuffix(path, ext), platform.Architecture, platform.Variant) + ext
+}
@@ -0,0 +1,52 @@
+package patch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpLLB(t *testing.T) {
+	st := llb.Image("docker.io/library/alpine:3.20").Run(llb.Shlex("apk upgrade --no-cache")).Root()
+	platform := ispec.Platform{OS: "linux", Architecture: "arm64"}
+	path := filepath.Join(t.TempDir(), "llb.pb")
+	require.NoError(t, dumpLLB(context.Background(), st, platform, path))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	def, err := llb.ReadFrom(f)
+	require.NoError(t, err)
+	require.NotEmpty(t, def.Def)
+
+	var sources, execs int
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		switch o := op.Op.(type) {
+		case *pb.Op_Source:
+			sources++
+			assert.Equal(t, "docker-image://docker.io/library/alpine:3.20", o.Source.Identifier)
+			assert.Equal(t, "arm64", op.Platform.Architecture)
+		case *pb.Op_Exec:
+			execs++
+			assert.Equal(t, []string{"apk", "upgrade", "--no-cache"}, o.Exec.Meta.Args)
+		}
+	}
+	assert.Equal(t, 1, sources)
+	assert.Equal(t, 1, execs)
+}
+
+func TestPlatformLLBDumpPath(t *testing.T) {
+	arm := ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	assert.Equal(t, "out/llb-arm-v7.pb", platformLLBDumpPath("out/llb.pb", arm))
+	assert.Equal(t, "out.d/llb-arm-v7", platformLLBDumpPath("out.d/llb", arm))
+	assert.Equal(t, "llb-amd64.json", platformLLBDumpPath("llb.json", ispec.Platform{OS: "linux", Architecture: "amd64"}))
+}
@@ -0,0 +1,201 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// for testing.
+var (
+	qemuAvailable       = buildkit.QemuAvailable
+	defaultHostPlatform = func() ispec.Platform { return platforms.Normalize(platforms.DefaultSpec()) }
+)
+
+const emulationDocsURL = "https://docs.docker.com/build/building/multi-platform/#qemu"
+
+// needsEmulation reports whether the target platform cannot run natively on the host.
+func needsEmulation(targetPlatform *types.PatchPlatform) bool {
+	hostPlatform := defaultHostPlatform()
+	if hostPlatform.OS != LINUX {
+		hostPlatform.OS = LINUX
+	}
+
+	if hostPlatform.OS == targetPlatform.OS && hostPlatform.Architecture == targetPlatform.Architecture {
+		log.Debugf("Host platform %+v matches target platform %+v", hostPlatform, targetPlatform)
+		return false
+	}
+
+	log.Debugf("Host platform %+v does not match target platform %+v", hostPlatform, targetPlatform)
+	return true
+}
+
+// emulationUnavailableReason describes why a platform cannot be patched on this host.
+func emulationUnavailableReason(targetPlatform *types.PatchPlatform) string {
+	return fmt.Sprintf("QEMU emulation is not available for %s", targetPlatform.String())
+}
+
+// validatePlatformEmulation checks if emulation is available for cross-platform builds.
+func validatePlatformEmulation(targetPlatform types.PatchPlatform) error { //nolint:gocritic
+	if !needsEmulation(&targetPlatform) {
+		return nil
+	}
+
+	if !qemuAvailable(&targetPlatform) {
+		return fmt.Errorf("%s: install qemu-user-static or register binfmt handlers "+
+			"(e.g. docker run --privileged --rm tonistiigi/binfmt --install all), see %s",
+			emulationUnavailableReason(&targetPlatform), emulationDocsURL)
+	}
+
+	log.Debugf("Emulation is enabled for platform %+v", targetPlatform)
+	return nil
+}
+
+// checkPlatformEmulation verifies up front that every platform to be patched can run
+// on this host. With ignoreError, platforms lacking emulation are preserved as-is and
+// their PreserveReason is set; otherwise the first such platform fails the run.
+func checkPlatformEmulation(patchPlatforms []types.PatchPlatform, ignoreError bool) error {
+	var missing []string
+	for i := range patchPlatforms {
+		p := &patchPlatforms[i]
+		if p.ShouldPreserve {
+			continue
+		}
+		err := validatePlatformEmulation(*p)
+		if err == nil {
+			continue
+		}
+		if !ignoreError {
+			return err
+		}
+		p.ShouldPreserve = true
+		p.PreserveReason = emulationUnavailableReason(p)
+		missing = append(missing, p.String())
+	}
+
+	if len(missing) > 0 {
+		log.Warnf("Preserving %s without patching because QEMU emulation is not available. "+
+			"Install qemu-user-static or enable binfmt to patch them, see %s",
+			strings.Join(missing, ", "), emulationDocsURL)
+	}
+	return nil
+}
+
+// emulationProbeImage is a small image published for every platform Copa patches, used
+// to check that emulation works.
+const emulationProbeImage = "docker.io/library/busybox:stable"
+
+// emulationProbes caches the outcome of probeEmulation per platform for the process,
+// so bulk and multi-platform runs probe each architecture once.
+var emulationProbes sync.Map // platform string -> error (nil when emulation works)
+
+// unameMachines are the uname -m outputs expected for a GOARCH; others must match it.
+var unameMachines = map[string][]string{
+	"amd64": {"x86_64"},
+	"arm64": {"aarch64", "arm64"},
+	"arm":   {"armv"},
+	"386":   {"i386", "i486", "i586", "i686"},
+}
+
+// probeEmulation runs uname -m in emulationProbeImage for targetPlatform through
+// BuildKit. A registered binfmt handler does not guarantee a working interpreter, so
+// this catches broken emulation before package installs fail on it.
+func probeEmulation(ctx context.Context, c gwclient.Client, targetPlatform *types.PatchPlatform) error {
+	key := targetPlatform.String()
+	if cached, ok := emulationProbes.Load(key); ok {
+		err, _ := cached.(error)
+		return err
+	}
+
+	err := runEmulationProbe(ctx, c, targetPlatform)
+	if ctx.Err() == nil {
+		emulationProbes.Store(key, err)
+	}
+	return err
+}
+
+func runEmulationProbe(ctx context.Context, c gwclient.Client, targetPlatform *types.PatchPlatform) error {
+	p := targetPlatform.Platform
+	out := llb.Image(emulationProbeImage, llb.Platform(p)).Platform(p).Run(
+		llb.Args([]string{"/bin/sh", "-c", "uname -m > /probe/machine"}),
+		llb.WithCustomNamef("Checking emulation for %s", targetPlatform.String()),
+		llb.IgnoreCache,
+	).AddMount("/probe", llb.Scratch())
+
+	machine, err := buildkit.ExtractFileFromState(ctx, c, &out, "/machine")
+	if err != nil {
+		return fmt.Errorf("emulation for %s is registered but running a command failed: %w; "+
+			"reinstall the QEMU handlers (e.g. docker run --privileged --rm tonistiigi/binfmt --install all), see %s",
+			targetPlatform.String(), err, emulationDocsURL)
+	}
+
+	got := strings.TrimSpace(string(machine))
+	want, ok := unameMachines[p.Architecture]
+	if !ok {
+		want = []string{p.Architecture}
+	}
+	for _, prefix := range want {
+		if strings.HasPrefix(got, prefix) {
+			log.Debugf("Emulation for %s works (uname -m: %s)", targetPlatform.String(), got)
+			return nil
+		}
+	}
+	return fmt.Errorf("emulation for %s ran the probe as %q instead of %s; the binfmt handler points to the wrong interpreter, see %s",
+		targetPlatform.String(), got, strings.Join(want, " or "), emulationDocsURL)
+}
+
+// verifyPlatformEmulation probes every platform to be patched that needs emulation, so
+// a broken QEMU setup fails the run before any platform is patched. With ignoreError,
+// such platforms are preserved as-is with their PreserveReason set instead.
+func verifyPlatformEmulation(ctx context.Context, bkOpts buildkit.Opts, patchPlatforms []types.PatchPlatform, ignoreError bool) error {
+	var pending []*types.PatchPlatform
+	for i := range patchPlatforms {
+		p := &patchPlatforms[i]
+		if !p.ShouldPreserve && needsEmulation(p) {
+			pending = append(pending, p)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	bkClient, closeClient, err := newClient(ctx, bkOpts)
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	var failed []error
+	_, err = bkClient.Build(ctx, client.SolveOpt{}, copaProduct, func(ctx context.Context, c gwclient.Client) (*gwclient.Result, error) {
+		for _, p := range pending {
+			probeErr := probeEmulation(ctx, c, p)
+			if probeErr == nil {
+				continue
+			}
+			if !ignoreError {
+				return nil, probeErr
+			}
+			p.ShouldPreserve = true
+			p.PreserveReason = fmt.Sprintf("QEMU emulation is not working for %s", p.String())
+			failed = append(failed, probeErr)
+		}
+		return gwclient.NewResult(), nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+	for _, probeErr := range failed {
+		log.Warnf("Preserving platform without patching: %v", probeErr)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/mocks"
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+func TestCheckPlatformEmulation(t *testing.T) {
+	origQemu, origHost := qemuAvailable, defaultHostPlatform
+	defer func() { qemuAvailable, defaultHostPlatform = origQemu, origHost }()
+
+	defaultHostPlatform = func() ispec.Platform { return ispec.Platform{OS: "linux", Architecture: "amd64"} }
+	// Emulation is registered for arm64 but not for s390x.
+	qemuAvailable = func(p *types.PatchPlatform) bool { return p.Architecture == "arm64" }
+
+	newPlatforms := func() []types.PatchPlatform {
+		return []types.PatchPlatform{
+			{Platform: ispec.Platform{OS: "linux", Architecture: "amd64"}},
+			{Platform: ispec.Platform{OS: "linux", Architecture: "arm64"}},
+			{Platform: ispec.Platform{OS: "linux", Architecture: "s390x"}},
+			{Platform: ispec.Platform{OS: "linux", Architecture: "ppc64le"}, ShouldPreserve: true},
+		}
+	}
+
+	t.Run("best-effort preserves platforms without emulation", func(t *testing.T) {
+		ps := newPlatforms()
+		require.NoError(t, checkPlatformEmulation(ps, true))
+
+		assert.False(t, ps[0].ShouldPreserve)
+		assert.False(t, ps[1].ShouldPreserve)
+		assert.True(t, ps[2].ShouldPreserve)
+		assert.Equal(t, "QEMU emulation is not available for linux/s390x", ps[2].PreserveReason)
+		// Platforms already preserved by the user are left alone.
+		assert.Empty(t, ps[3].PreserveReason)
+	})
+
+	t.Run("strict fails early with an actionable message", func(t *testing.T) {
+		ps := newPlatforms()
+		err := checkPlatformEmulation(ps, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "linux/s390x")
+		assert.Contains(t, err.Error(), "qemu-user-static")
+		assert.False(t, ps[2].ShouldPreserve)
+	})
+}
+
+func TestProbeEmulation(t *testing.T) {
+	tests := []struct {
+		name     string
+		arch     string
+		variant  string
+		machine  string
+		solveErr error
+		wantErr  string
+	}{
+		{name: "arm64", arch: "arm64", machine: "aarch64\n"},
+		{name: "arm variant", arch: "arm", variant: "v7", machine: "armv7l\n"},
+		{name: "other architecture", arch: "s390x", machine: "s390x\n"},
+		{name: "wrong interpreter", arch: "arm64", machine: "x86_64\n", wantErr: `ran the probe as "x86_64" instead of aarch64 or arm64`},
+		{name: "command fails", arch: "riscv64", solveErr: errors.New("exec format error"), wantErr: "is registered but running a command failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emulationProbes = sync.Map{}
+			defer func() { emulationProbes = sync.Map{} }()
+
+			mockClient := new(mocks.MockGWClient)
+			mockRef := new(mocks.MockReference)
+			result := &gwclient.Result{}
+			result.SetRef(mockRef)
+			if tt.solveErr != nil {
+				mockClient.On("Solve", mock.Anything, mock.Anything).Return((*gwclient.Result)(nil), tt.solveErr)
+			} else {
+				mockClient.On("Solve", mock.Anything, mock.Anything).Return(result, nil)
+				mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte(tt.machine), nil)
+			}
+
+			p := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: tt.arch, Variant: tt.variant}}
+			err := probeEmulation(context.Background(), mockClient, p)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// The outcome is cached, so a second probe doesn't solve again.
+			err2 := probeEmulation(context.Background(), mockClient, p)
+			assert.Equal(t, err, err2)
+			mockClient.AssertNumberOfCalls(t, "Solve", 1)
+		})
+	}
+}
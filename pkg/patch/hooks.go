@@ -0,0 +1,25 @@
+package patch
+
+import (
+	"github.com/moby/buildkit/client/llb"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+const (
+	prePatchHook  = "pre-patch"
+	postPatchHook = "post-patch"
+)
+
+// runPatchHook runs a user-provided shell snippet on st, such as installing a CA
+// certificate before packages are updated or removing caches afterwards. The snippet
+// becomes a layer of the patched image and sees the same proxy settings as the package
+// managers.
+func runPatchHook(st llb.State, hook, script string) llb.State {
+	return st.Run(
+		buildkit.Sh(script),
+		utils.WithProxy(),
+		llb.WithCustomNamef("Running %s script", hook),
+	).Root()
+}
@@ -0,0 +1,60 @@
+package patch
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPatchHookOrder(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+
+	base := llb.Image("docker.io/library/alpine:3.18")
+	pre := runPatchHook(base, prePatchHook, "cp /run/ca.pem /usr/local/share/ca-certificates/ && update-ca-certificates")
+	updated := pre.Run(llb.Shlex("apk upgrade --no-cache openssl")).Root()
+	post := runPatchHook(updated, postPatchHook, "rm -rf /var/cache/apk/*")
+
+	def, err := post.Marshal(context.Background())
+	require.NoError(t, err)
+
+	ops := make(map[digest.Digest]*pb.Op, len(def.Def))
+	var last digest.Digest
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		ops[digest.FromBytes(dt)] = &op
+		last = digest.FromBytes(dt)
+	}
+
+	// Walk from the output back to the image source, recording each command.
+	var execs []*pb.ExecOp
+	cur := ops[last]
+	for cur != nil && len(cur.GetInputs()) > 0 {
+		cur = ops[digest.Digest(cur.GetInputs()[0].GetDigest())]
+		if exec := cur.GetExec(); exec != nil {
+			execs = append([]*pb.ExecOp{exec}, execs...)
+		}
+	}
+
+	require.Len(t, execs, 3)
+	args := func(i int) string { return strings.Join(execs[i].GetMeta().GetArgs(), " ") }
+	assert.Contains(t, args(0), "update-ca-certificates", "the pre-patch script should run before the updates")
+	assert.Equal(t, "apk upgrade --no-cache openssl", args(1))
+	assert.Contains(t, args(2), "rm -rf /var/cache/apk/*", "the post-patch script should run after the updates")
+	for _, i := range []int{0, 2} {
+		var names []string
+		for _, s := range execs[i].GetSecretenv() {
+			names = append(names, s.GetName())
+		}
+		assert.Contains(t, names, "HTTPS_PROXY", "the hooks should get the proxy as a secret")
+	}
+	for _, dt := range def.Def {
+		assert.NotContains(t, string(dt), "proxy.example.com", "the proxy URL should not be part of the LLB")
+	}
+}
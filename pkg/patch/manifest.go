@@ -3,15 +3,12 @@ package patch
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/distribution/reference"
 	"github.com/docker/buildx/util/imagetools"
-	"github.com/docker/cli/cli/config"
 	"github.com/moby/buildkit/exporter/containerimage/exptypes"
-	"github.com/moby/buildkit/session/auth/authprovider"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/project-copacetic/copacetic/pkg/types"
@@ -25,14 +22,19 @@ const (
 // createMultiPlatformManifest assembles a multi-platform manifest list and pushes it
 // via Buildx's imagetools helper (equivalent to
 // `docker buildx imagetools create --tag … img@sha256:d1 img@sha256:d2 …`).
+// The same manifest list is then pushed to each of destinations, copying up to
+// pushWorkers platform images at once. indexAnnotations are added to the index.
 func createMultiPlatformManifest(
 	ctx context.Context,
 	imageName reference.NamedTagged,
 	items []types.PatchResult,
 	originalImage string,
+	destinations []reference.NamedTagged,
+	pushWorkers int,
+	indexAnnotations map[string]string,
 ) error {
 	resolver := imagetools.New(imagetools.Opt{
-		Auth: authprovider.LoadAuthConfig(config.LoadDefaultConfigFile(os.Stderr)),
+		Auth: utils.RegistryAuth(),
 	})
 
 	// fetch annotations from the original image
@@ -115,6 +117,11 @@ func createMultiPlatformManifest(
 	}
 	annotations[copaKey] = time.Now().UTC().Format(time.RFC3339)
 
+	// add the user's --annotation values, replacing original annotations of the same key
+	for k, v := range indexAnnotations {
+		annotations[exptypes.AnnotationKey{Type: exptypes.AnnotationIndex, Key: k}] = v
+	}
+
 	// add manifest descriptor level annotations for each platform
 	for _, it := range items {
 		if it.PatchedDesc != nil && it.PatchedDesc.Platform != nil {
@@ -169,12 +176,11 @@ func createMultiPlatformManifest(
 		return fmt.Errorf("failed to combine sources into manifest list: %w", err)
 	}
 
+	// Platform images that were kept as they are may live in another repository, so
+	// every platform image is copied next to the index before it is pushed
 	log.Infof("Successfully created manifest list, pushing to %s", imageName.String())
-	err = resolver.Push(ctx, imageName, desc, idxBytes)
-	if err != nil {
-		return fmt.Errorf("failed to push multi-platform manifest list: %w", err)
+	if err := pushIndex(ctx, resolver, srcRefs, desc, idxBytes, imageName, pushWorkers); err != nil {
+		return err
 	}
-
-	log.Infof("Successfully pushed multi-platform manifest list to %s", imageName.String())
-	return nil
+	return pushIndexToDestinations(ctx, resolver, srcRefs, desc, idxBytes, destinations, pushWorkers)
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -14,28 +15,60 @@ import (
 	"github.com/moby/buildkit/client"
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/common"
+	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/tui"
 	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
+// reportParseErrorReason is the summary reason of a platform preserved because its
+// scan report could not be parsed.
+const reportParseErrorReason = "report-parse-error"
+
 // patchMultiPlatformImage patches a multi-platform image across all discovered platforms.
 func patchMultiPlatformImage(
 	ctx context.Context,
 	opts *types.Options,
+	reportOpts *report.ParseOptions,
 	discoveredPlatforms []types.PatchPlatform,
 ) error {
 	image := opts.Image
 	reportDir := opts.Report
+	hasReports := reportDir != "" || len(opts.PlatformReportMap) > 0
 	ignoreError := opts.IgnoreError
 	log.Debugf("Handling platform specific errors with ignore-errors=%t", ignoreError)
 
+	if opts.ExportDiff != "" {
+		return errors.New("--export-diff only supports single-platform images")
+	}
+
+	cacheOpts, err := buildkit.ParseCacheOptions(opts.CacheFrom, opts.CacheTo)
+	if err != nil {
+		return err
+	}
+
 	var platforms []types.PatchPlatform
-	if reportDir != "" {
-		// Using report directory - discover platforms from reports
-		var err error
-		platforms, err = buildkit.DiscoverPlatforms(image, reportDir, opts.Scanner)
+	if hasReports {
+		if len(opts.PlatformReportMap) > 0 {
+			// Using reports mapped to platforms explicitly
+			var reports []types.PatchPlatform
+			reports, err = buildkit.ParsePlatformReportMap(opts.PlatformReportMap)
+			if err != nil {
+				return err
+			}
+			platforms, err = buildkit.DiscoverPlatformsFromReportMap(ctx, image, reports)
+		} else {
+			// Using report directory - discover platforms from reports
+			platforms, err = buildkit.DiscoverPlatforms(ctx, image, reportDir, opts.Scanner,
+				&buildkit.ReportDiscoveryOptions{
+					Extensions:      opts.ReportExtensions,
+					AcceptAnyOSType: opts.ForcePkgManager != "",
+					KeyFormat:       buildkit.ReportPlatformKeyFormat(opts.ReportPlatformKey),
+					SkipMalformed:   ignoreError,
+				})
+		}
 		if err != nil {
 			return err
 		}
@@ -88,6 +121,28 @@ func patchMultiPlatformImage(
 		}
 	}
 
+	// Detect platforms that can't run on this host before any solve is started
+	if err := checkPlatformEmulation(platforms, ignoreError); err != nil {
+		return err
+	}
+	if opts.VerifyEmulation {
+		bkOpts := buildkit.Opts{
+			Addr:       opts.BkAddr,
+			CACertPath: opts.BkCACertPath,
+			CertPath:   opts.BkCertPath,
+			KeyPath:    opts.BkKeyPath,
+		}
+		if err := verifyPlatformEmulation(ctx, bkOpts, platforms, ignoreError); err != nil {
+			return err
+		}
+	}
+
+	// Platforms sharing a base image and update set with another platform reuse its patch
+	var sharedPatches map[string]string
+	if opts.SharePlatformPatches {
+		sharedPatches = findSharedPatches(ctx, opts, reportOpts, platforms)
+	}
+
 	// Display styled patching plan before starting
 	plan := buildPatchingPlan(opts, platforms)
 	fmt.Fprintln(os.Stderr, tui.RenderPatchingPlan(plan))
@@ -99,6 +154,9 @@ func patchMultiPlatformImage(
 	// Count how many platforms will be patched (not preserved) to know when to close the channel
 	var patchingPlatformCount int32
 	for _, p := range platforms {
+		if _, shared := sharedPatches[buildkit.PlatformKey(p.Platform)]; shared {
+			continue
+		}
 		if !p.ShouldPreserve {
 			patchingPlatformCount++
 		}
@@ -106,7 +164,7 @@ func patchMultiPlatformImage(
 	var completedCount atomic.Int32
 	var closeProgressOnce sync.Once
 
-	sem := make(chan struct{}, runtime.NumCPU())
+	sem := make(chan struct{}, platformConcurrency(opts.MaxParallelPlatforms))
 	g, gctx := errgroup.WithContext(ctx)
 
 	// Start the unified progress display
@@ -118,6 +176,8 @@ func patchMultiPlatformImage(
 
 	var mu sync.Mutex
 	patchResults := []types.PatchResult{}
+	// results of patched platforms, used to fill in platforms that share a patch
+	platformResults := make(map[string]types.PatchResult)
 
 	summaryMap := make(map[string]*types.MultiPlatformSummary)
 
@@ -130,6 +190,9 @@ func patchMultiPlatformImage(
 		// rebind
 		p := p //nolint
 		platformKey := buildkit.PlatformKey(p.Platform)
+		if _, shared := sharedPatches[platformKey]; shared {
+			continue
+		}
 		g.Go(func() error {
 			select {
 			case sem <- struct{}{}:
@@ -173,7 +236,7 @@ func patchMultiPlatformImage(
 				}
 
 				// Get the original platform descriptor from the manifest
-				originalDesc, err := getPlatformDescriptorFromManifest(image, &p)
+				originalDesc, err := getPlatformDescriptorFromManifest(gctx, image, &p)
 				if err != nil {
 					mu.Lock()
 					summaryMap[platformKey] = &types.MultiPlatformSummary{
@@ -193,12 +256,16 @@ func patchMultiPlatformImage(
 					OriginalRef: originalRef,
 					PatchedRef:  originalRef,
 					PatchedDesc: originalDesc,
+					Platform:    p.Platform,
+					Preserved:   true,
 				}
 
 				mu.Lock()
 				patchResults = append(patchResults, result)
 				var preserveReason string
-				if reportDir != "" && p.ReportFile == "" {
+				if p.PreserveReason != "" {
+					preserveReason = p.PreserveReason
+				} else if hasReports && p.ReportFile == "" {
 					preserveReason = "No scan report for platform"
 				} else {
 					preserveReason = "Not in --platform list"
@@ -216,19 +283,29 @@ func patchMultiPlatformImage(
 
 			// When no report directory is provided, patch with empty report file
 			reportFile := p.ReportFile
-			if reportDir == "" {
+			if !hasReports {
 				reportFile = ""
 			}
 
 			patchOpts := *opts
 			patchOpts.Report = reportFile
+			// Platform images are copied to --push-to destinations with the manifest list
+			patchOpts.PushTo = nil
+			if opts.Output != "" {
+				// Each platform gets its own VEX document instead of overwriting one file
+				patchOpts.Output = utils.PlatformOutputPath(opts.Output, utils.VEXArtifactPrefix, p.Platform)
+			}
+			if opts.ChangelogOutput != "" {
+				// Likewise for the changelog, as platforms are patched in parallel
+				patchOpts.ChangelogOutput = utils.PlatformOutputPath(opts.ChangelogOutput, utils.ChangelogArtifactPrefix, p.Platform)
+			}
 
 			// Count a real patch attempt (not preserved)
 			mu.Lock()
 			patchedAttempts++
 			mu.Unlock()
 
-			res, err := patchSingleArchImage(gctx, &patchOpts, p, true, sharedProgressCh)
+			res, err := patchSingleArchImage(gctx, &patchOpts, reportOpts, p, true, sharedProgressCh)
 
 			// Track completion to know when to close shared channel
 			if completedCount.Add(1) == patchingPlatformCount {
@@ -240,6 +317,7 @@ func patchMultiPlatformImage(
 			if err != nil {
 				if errors.Is(err, types.ErrNoUpdatesFound) {
 					patchResults = append(patchResults, *res)
+					platformResults[platformKey] = *res
 					summaryMap[platformKey] = &types.MultiPlatformSummary{
 						Platform: platformKey,
 						Status:   "Up-to-date",
@@ -250,8 +328,41 @@ func patchMultiPlatformImage(
 					return nil
 				}
 
+				if errors.Is(err, types.ErrReportPlatformMismatch) && res != nil {
+					// The platform is kept as-is rather than patched with another platform's fixes
+					patchResults = append(patchResults, *res)
+					platformResults[platformKey] = *res
+					summaryMap[platformKey] = &types.MultiPlatformSummary{
+						Platform: platformKey,
+						Status:   "Not Patched",
+						Ref:      res.OriginalRef.String() + " (original reference)",
+						Message:  err.Error(),
+					}
+					return nil
+				}
+
+				if errors.Is(err, types.ErrReportParse) {
+					if ignoreError && res != nil {
+						// Only this platform's report is unusable, keep it as-is and patch the others
+						patchResults = append(patchResults, *res)
+						platformResults[platformKey] = *res
+						summaryMap[platformKey] = &types.MultiPlatformSummary{
+							Platform: platformKey,
+							Status:   "Not Patched",
+							Ref:      res.OriginalRef.String() + " (original reference)",
+							Message:  reportParseErrorReason + ": " + err.Error(),
+						}
+						return nil
+					}
+					// Without --ignore-errors a bad report stops the other platforms too
+					hasErrors = true
+					return err
+				}
+
 				status := "Error"
-				if ignoreError {
+				if errors.Is(err, types.ErrRebuildRequired) {
+					status = "Rebuild"
+				} else if ignoreError {
 					status = "Ignored"
 				}
 				summaryMap[platformKey] = &types.MultiPlatformSummary{
@@ -276,6 +387,7 @@ func patchMultiPlatformImage(
 			}
 
 			patchResults = append(patchResults, *res)
+			platformResults[platformKey] = *res
 			summaryMap[platformKey] = &types.MultiPlatformSummary{
 				Platform: platformKey,
 				Status:   "Patched",
@@ -301,6 +413,68 @@ func patchMultiPlatformImage(
 	// Wait for the progress display to finish
 	_ = displayEg.Wait()
 
+	// Fill in platforms that reuse another platform's patch
+	for i := range platforms {
+		platformKey := buildkit.PlatformKey(platforms[i].Platform)
+		leader, shared := sharedPatches[platformKey]
+		if !shared {
+			continue
+		}
+		patchedAttempts++
+
+		leaderRes, ok := platformResults[leader]
+		if !ok {
+			status := "Error"
+			if ignoreError {
+				status = "Ignored"
+			}
+			summaryMap[platformKey] = &types.MultiPlatformSummary{
+				Platform: platformKey,
+				Status:   status,
+				Message:  fmt.Sprintf("shared patch from %s is not available", leader),
+			}
+			hasErrors = true
+			continue
+		}
+
+		// A leader that was up to date or kept as it is has no patch to share, so
+		// the follower keeps its own original image the same way.
+		if leaderSummary := summaryMap[leader]; leaderSummary.Status != "Patched" {
+			res, _ := createOriginalImageResult(ctx, leaderRes.OriginalRef, &platforms[i], image)
+			res.NoUpdatesApplied = leaderRes.NoUpdatesApplied
+			patchResults = append(patchResults, *res)
+			summaryMap[platformKey] = &types.MultiPlatformSummary{
+				Platform: platformKey,
+				Status:   leaderSummary.Status,
+				Ref:      res.OriginalRef.String() + " (original)",
+				Message:  fmt.Sprintf("%s, like %s", leaderSummary.Message, leader),
+			}
+			if leaderRes.NoUpdatesApplied {
+				patchedSuccesses++
+			}
+			continue
+		}
+
+		res, err := reuseSharedPatch(ctx, &leaderRes, &platforms[i], opts.Push)
+		if err != nil {
+			summaryMap[platformKey] = &types.MultiPlatformSummary{
+				Platform: platformKey,
+				Status:   "Error",
+				Message:  err.Error(),
+			}
+			hasErrors = true
+			continue
+		}
+		patchResults = append(patchResults, res)
+		summaryMap[platformKey] = &types.MultiPlatformSummary{
+			Platform: platformKey,
+			Status:   summaryMap[leader].Status,
+			Ref:      res.PatchedRef.String(),
+			Message:  fmt.Sprintf("Reused patch from %s", leader),
+		}
+		patchedSuccesses++
+	}
+
 	// Render the per-platform summary before any early error returns so users
 	// can see which platforms failed and why.
 	var summaries []tui.PlatformSummary
@@ -354,8 +528,24 @@ func patchMultiPlatformImage(
 		return fmt.Errorf("failed to parse patched image name: %w", err)
 	}
 
-	if opts.Push {
-		err = createMultiPlatformManifest(ctx, patchedImageName, patchResults, image)
+	anyPatched := false
+	for _, summary := range summaryMap {
+		if summary.Status == "Patched" {
+			anyPatched = true
+			break
+		}
+	}
+	if !anyPatched && !hasErrors {
+		log.Infof("Image %s is already up to date, no patch produced", image)
+	}
+
+	// Without a patched platform the index would be the original image under a new tag
+	if opts.Push && anyPatched {
+		destinations, err := resolvePushDestinations(opts.PushTo, resolvedPatchedTag)
+		if err != nil {
+			return err
+		}
+		err = createMultiPlatformManifest(ctx, patchedImageName, patchResults, image, destinations, registryPushWorkers(opts), opts.Annotations)
 		if err != nil {
 			return fmt.Errorf("manifest list creation failed: %w", err)
 		}
@@ -417,19 +607,106 @@ func patchMultiPlatformImage(
 		}
 	}
 	if !anySuccesses && len(summaryMap) > 0 {
-		return types.ErrNoUpdatesFound
+		return checkPatchedPackages(opts, types.ErrNoUpdatesFound)
 	}
 	// Create OCI layout if requested and not pushing to registry
 	if opts.OCIDir != "" && !opts.Push {
-		if err := buildkit.CreateOCILayoutFromResults(opts.OCIDir, patchResults, platforms); err != nil {
+		if err := writeOCILayout(ctx, opts, image, patchResults, platforms, cacheOpts); err != nil {
 			log.Warnf("Failed to create OCI layout: %v", err)
 			return fmt.Errorf("failed to create OCI layout: %w", err)
 		}
 	}
 
+	if opts.FailOnNoPatch && !anyPatched {
+		// Every platform was up-to-date or kept as it was
+		return checkPatchedPackages(opts, types.ErrNoUpdatesFound)
+	}
+	results := make([]*types.PatchResult, len(patchResults))
+	for i := range patchResults {
+		results[i] = &patchResults[i]
+	}
+	return checkPatchedPackages(opts, nil, results...)
+}
+
+// registryPushWorkers returns how many platform images are copied to a --push-to
+// destination at once.
+func registryPushWorkers(opts *types.Options) int {
+	if !opts.ParallelRegistryPush {
+		return 1
+	}
+	if opts.RegistryConcurrency > 0 {
+		return opts.RegistryConcurrency
+	}
+	return utils.DefaultRegistryConcurrency
+}
+
+// writeOCILayout writes the OCI layout of the patch results to opts.OCIDir, as a
+// directory or, with --oci-output-format, as an archive. An archive's layout is
+// assembled in a temporary directory next to it first, and its files are moved into
+// the archive as it is written.
+func writeOCILayout(
+	ctx context.Context,
+	opts *types.Options,
+	image string,
+	patchResults []types.PatchResult,
+	platforms []types.PatchPlatform,
+	cacheOpts *buildkit.CacheOptions,
+) error {
+	format := buildkit.OCILayoutDir
+	if opts.OCIOutputFormat != "" {
+		var err error
+		if format, err = buildkit.ParseOCILayoutFormat(opts.OCIOutputFormat, opts.OCIDir); err != nil {
+			return err
+		}
+	}
+
+	layoutDir := opts.OCIDir
+	if format != buildkit.OCILayoutDir {
+		tmp, err := os.MkdirTemp(filepath.Dir(opts.OCIDir), ".copa-oci-layout-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary OCI layout directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		layoutDir = tmp
+	}
+
+	if err := buildkit.CreateOCILayoutFromResults(ctx, layoutDir, patchResults, platforms, cacheOpts,
+		ociExportOptions(ctx, opts.Compression, image, platforms, opts.Annotations), ociPartialMode(opts)); err != nil {
+		return err
+	}
+	buildkit.SetLayerDeltas(ctx, layoutDir, image, patchResults)
+
+	if format == buildkit.OCILayoutDir {
+		return nil
+	}
+	if err := buildkit.MoveOCILayoutToArchive(layoutDir, opts.OCIDir, format); err != nil {
+		return err
+	}
+	log.Infof("Wrote OCI layout archive %s", opts.OCIDir)
 	return nil
 }
 
+// ociPartialMode returns how the OCI layout export treats failed platforms. Unless set
+// explicitly, --ignore-errors keeps the platforms that succeeded.
+func ociPartialMode(opts *types.Options) buildkit.OCIPartialMode {
+	if opts.OCIPartial != "" {
+		return buildkit.OCIPartialMode(opts.OCIPartial)
+	}
+	if opts.IgnoreError {
+		return buildkit.OCIPartialOmit
+	}
+	return buildkit.OCIPartialStrict
+}
+
+// platformConcurrency returns how many platforms are patched in parallel: one per
+// CPU, or maxParallel when it is set.
+func platformConcurrency(maxParallel int) int {
+	if maxParallel > 0 {
+		return maxParallel
+	}
+	return runtime.NumCPU()
+}
+
 // buildPatchingPlan creates a PatchingPlan from the options and platforms.
 func buildPatchingPlan(opts *types.Options, platforms []types.PatchPlatform) tui.PatchingPlan {
 	var targetPlatforms []string
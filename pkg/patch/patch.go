@@ -2,17 +2,22 @@ package patch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/containerd/platforms"
 	"github.com/distribution/reference"
+	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/util/progress/progressui"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/common"
+	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/tui"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/utils"
@@ -23,6 +28,28 @@ var (
 	bkNewClient = buildkit.NewClient
 )
 
+type sharedClientKey struct{}
+
+// WithSharedClient returns a copy of ctx whose patch runs use c instead of opening
+// their own BuildKit connection, so a batch of images shares one. The caller keeps
+// ownership of c and closes it once the runs are done.
+func WithSharedClient(ctx context.Context, c *client.Client) context.Context {
+	return context.WithValue(ctx, sharedClientKey{}, c)
+}
+
+// newClient returns the shared BuildKit client of ctx, or a new one for bkOpts when
+// there is none. The returned func closes the client only if it was opened here.
+func newClient(ctx context.Context, bkOpts buildkit.Opts) (*client.Client, func(), error) {
+	if c, ok := ctx.Value(sharedClientKey{}).(*client.Client); ok && c != nil {
+		return c, func() {}, nil
+	}
+	c, err := bkNewClient(ctx, bkOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, func() { c.Close() }, nil
+}
+
 // Patch command applies package updates to an OCI image given a vulnerability report for a given set of options.
 func Patch(ctx context.Context, opts *types.Options) error {
 	allowedProgressModes := map[string]struct{}{
@@ -78,6 +105,24 @@ func Patch(ctx context.Context, opts *types.Options) error {
 	}
 }
 
+// resolveImage rewrites opts.Image to the reference the rest of the patch uses: a short
+// digest is expanded and, with --pin-digest, the tag is pinned to the digest it
+// points to now.
+func resolveImage(ctx context.Context, opts *types.Options) error {
+	// Short digests copied from docker images output can't be pulled as-is
+	resolvedImage, err := buildkit.ResolveShortDigest(ctx, opts.Image)
+	if err != nil {
+		return err
+	}
+	opts.Image = resolvedImage
+	if opts.PinDigest {
+		if opts.Image, err = buildkit.PinDigest(ctx, opts.Image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // patchWithContext orchestrates the main patching workflow.
 func patchWithContext(ctx context.Context, opts *types.Options) error {
 	// Configure EOL API if provided
@@ -86,11 +131,56 @@ func patchWithContext(ctx context.Context, opts *types.Options) error {
 		log.Debugf("Configured EOL API base URL: %s", opts.EOLAPIBaseURL)
 	}
 
+	reportOpts, err := report.NewSeverityOptions(opts.SeveritySource, opts.MinSeverity)
+	if err != nil {
+		return err
+	}
+	if opts.KEVOnly || opts.KEVCatalog != "" {
+		if reportOpts.KEVCatalog, err = report.LoadKEVCatalog(ctx, opts.KEVCatalog); err != nil {
+			return err
+		}
+		reportOpts.KEVOnly = opts.KEVOnly
+		log.Infof("Loaded KEV catalog %s with %d vulnerabilities", reportOpts.KEVCatalog.Version, reportOpts.KEVCatalog.Len())
+	}
+	if opts.Policy != "" {
+		if reportOpts.Policy, err = report.LoadPolicy(opts.Policy); err != nil {
+			return err
+		}
+	}
+	reportOpts.IncludeUnfixed = opts.IncludeUnfixed
+	ctx = utils.WithRegistryConcurrency(ctx, opts.RegistryConcurrency)
+	ctx = buildkit.WithPullPolicy(ctx, buildkit.PullPolicy(opts.Pull))
+	if warning := compressionSupportWarning(opts.Compression); warning != "" {
+		log.Warn(warning)
+	}
+	if opts.ProxySecret != "" {
+		user, password, err := utils.LoadProxySecret(opts.ProxySecret)
+		if err != nil {
+			return err
+		}
+		ctx = utils.WithProxyCredentials(ctx, user, password)
+	}
+
+	if err := resolveImage(ctx, opts); err != nil {
+		return err
+	}
+
 	image := opts.Image
 	reportPath := opts.Report
 	targetPlatforms := opts.Platforms
 	pkgTypes := opts.PkgTypes
 
+	// Generate the report in-process when asked to scan and no report was given
+	if reportPath == "" && opts.Scan {
+		scanReport, cleanup, err := scanImage(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		reportPath = scanReport
+		opts.Report = scanReport
+	}
+
 	// Parse and validate package types early
 	pkgTypesList, err := parsePkgTypes(pkgTypes)
 	if err != nil {
@@ -98,18 +188,30 @@ func patchWithContext(ctx context.Context, opts *types.Options) error {
 	}
 
 	// Validate that library package types require a scanner report
-	reportProvided := reportPath != ""
+	reportProvided := reportPath != "" || len(opts.PlatformReportMap) > 0
 	if err := validateLibraryPkgTypesRequireReport(pkgTypesList, reportProvided); err != nil {
 		return err
 	}
 
+	// Reports mapped to platforms explicitly - the function will discover the platforms internally
+	if len(opts.PlatformReportMap) > 0 {
+		if len(targetPlatforms) > 0 {
+			log.Info("Platform flag ignored when --platform-report-map is provided")
+		}
+		return patchMultiPlatformImage(ctx, opts, reportOpts, nil)
+	}
+
 	// Handle empty report path - check if image is manifest list or single platform
 	if reportPath == "" {
 		// Discover platforms from the image reference to determine if it's multi-platform
-		discoveredPlatforms, err := buildkit.DiscoverPlatformsFromReference(image)
+		discoveredPlatforms, err := buildkit.DiscoverPlatformsFromReference(ctx, image)
 		if err != nil {
 			// Failed to discover platforms - treat as single-platform image
-			log.Warnf("Failed to discover platforms for image %s (treating as single-platform): %v", image, err)
+			if errors.Is(err, buildkit.ErrUnknownImagePlatform) {
+				log.Warnf("%v; defaulting to the host platform", err)
+			} else {
+				log.Warnf("Failed to discover platforms for image %s (treating as single-platform): %v", image, err)
+			}
 			if len(targetPlatforms) > 0 {
 				log.Info("Platform flag ignored when platform discovery fails")
 			}
@@ -123,11 +225,8 @@ func patchWithContext(ctx context.Context, opts *types.Options) error {
 			}
 
 			displaySingleArchPlan(opts, &patchPlatform)
-			result, err := patchSingleArchImage(ctx, opts, patchPlatform, false, nil)
-			if err == nil && result != nil && result.PatchedRef != nil {
-				log.Infof("Patched image (%s): %s\n", patchPlatform.OS+"/"+patchPlatform.Architecture, result.PatchedRef)
-			}
-			return err
+			result, err := patchSingleArchImage(ctx, opts, reportOpts, patchPlatform, false, nil)
+			return singleArchResult(opts, &patchPlatform, result, err)
 		}
 
 		if len(discoveredPlatforms) <= 1 {
@@ -153,15 +252,12 @@ func patchWithContext(ctx context.Context, opts *types.Options) error {
 			}
 
 			displaySingleArchPlan(opts, &patchPlatform)
-			result, err := patchSingleArchImage(ctx, opts, patchPlatform, false, nil)
-			if err == nil && result != nil && result.PatchedRef != nil {
-				log.Infof("Patched image (%s): %s\n", patchPlatform.OS+"/"+patchPlatform.Architecture, result.PatchedRef)
-			}
-			return err
+			result, err := patchSingleArchImage(ctx, opts, reportOpts, patchPlatform, false, nil)
+			return singleArchResult(opts, &patchPlatform, result, err)
 		}
 
 		log.Debugf("Detected multi-platform image with %d platforms", len(discoveredPlatforms))
-		return patchMultiPlatformImage(ctx, opts, discoveredPlatforms)
+		return patchMultiPlatformImage(ctx, opts, reportOpts, discoveredPlatforms)
 	}
 
 	// Check if reportPath exists
@@ -182,7 +278,7 @@ func patchWithContext(ctx context.Context, opts *types.Options) error {
 			log.Info("Platform flag ignored when report directory is provided")
 		}
 		// For report directory, we pass nil as discoveredPlatforms - the function will discover them internally
-		return patchMultiPlatformImage(ctx, opts, nil)
+		return patchMultiPlatformImage(ctx, opts, reportOpts, nil)
 	}
 	// Handle file - single-platform patching
 	log.Debugf("Using report file: %s", reportPath)
@@ -194,11 +290,95 @@ func patchWithContext(ctx context.Context, opts *types.Options) error {
 		patchPlatform.OS = LINUX
 	}
 	displaySingleArchPlan(opts, &patchPlatform)
-	result, err := patchSingleArchImage(ctx, opts, patchPlatform, false, nil)
-	if err == nil && result != nil {
-		log.Infof("Patched image (%s): %s\n", patchPlatform.OS+"/"+patchPlatform.Architecture, result.PatchedRef.String())
+	result, err := patchSingleArchImage(ctx, opts, reportOpts, patchPlatform, false, nil)
+	return singleArchResult(opts, &patchPlatform, result, err)
+}
+
+// singleArchResult logs the outcome of patching a single-platform image and returns
+// the error copa exits with. An image that was already up to date produced no patch,
+// so nothing was tagged or pushed for it.
+func singleArchResult(opts *types.Options, platform *types.PatchPlatform, result *types.PatchResult, err error) error {
+	if result != nil && result.NoUpdatesApplied {
+		log.Infof("Image %s is already up to date, no patch produced", opts.Image)
+		if !opts.FailOnNoPatch {
+			return nil
+		}
+		return checkPatchedPackages(opts, err, result)
 	}
-	return err
+	if err == nil && result != nil && result.PatchedRef != nil {
+		log.Infof("Patched image (%s): %s\n", platform.OS+"/"+platform.Architecture, result.PatchedRef)
+	}
+	return checkPatchedPackages(opts, err, result)
+}
+
+// checkPatchedPackages returns ErrNoPackagesPatched in place of err when --fail-on-no-patch
+// is set and none of results applied an update from the report. Without a report, or with
+// --update-all, packages are upgraded that the report does not list, so then only an
+// ErrNoUpdatesFound from the package manager counts as nothing patched.
+func checkPatchedPackages(opts *types.Options, err error, results ...*types.PatchResult) error {
+	if !opts.FailOnNoPatch {
+		return err
+	}
+	if errors.Is(err, types.ErrNoUpdatesFound) {
+		// %v rather than %w: main exits successfully on ErrNoUpdatesFound
+		return fmt.Errorf("%w: %v; %s was left unchanged", types.ErrNoPackagesPatched, err, opts.Image)
+	}
+	reportGiven := opts.Report != "" || len(opts.PlatformReportMap) > 0
+	if err != nil || !reportGiven || opts.UpdateAll {
+		return err
+	}
+
+	var errored, skipped int
+	for _, r := range results {
+		if r == nil || r.Preserved {
+			continue
+		}
+		if r.PatchedPackages > 0 || len(r.FixedCVEs) > 0 {
+			return nil
+		}
+		errored += len(r.ErroredPackages)
+		skipped += len(r.SkippedPackages)
+	}
+	if errored > 0 {
+		return fmt.Errorf("%w: all %d packages to update failed and were skipped; the output image has the same packages as %s",
+			types.ErrNoPackagesPatched, errored, opts.Image)
+	}
+	if skipped > 0 {
+		return fmt.Errorf("%w: all %d packages to update are transitive dependencies skipped by --direct-only; the output image has the same packages as %s",
+			types.ErrNoPackagesPatched, skipped, opts.Image)
+	}
+	return fmt.Errorf("%w: the report has no fixable vulnerabilities for packages in %s", types.ErrNoPackagesPatched, opts.Image)
+}
+
+// scanImage runs the configured scanner against the image and returns the path to
+// the generated report along with a function that removes it.
+func scanImage(ctx context.Context, opts *types.Options) (string, func(), error) {
+	dir, err := os.MkdirTemp(opts.WorkingFolder, "copa-scan-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create scan directory: %w", err)
+	}
+	cleanup := func() { removeIfNotDebug(dir) }
+
+	scanOpts := &report.ScanOptions{
+		Scanner:     opts.Scanner,
+		Image:       opts.Image,
+		Output:      filepath.Join(dir, utils.ReportArtifactPrefix+".json"),
+		ExtraArgs:   opts.ScannerArgs,
+		ListAllPkgs: opts.UpdateAll,
+	}
+	if len(opts.Platforms) == 1 {
+		scanOpts.Platform = opts.Platforms[0]
+		if p, err := platforms.Parse(scanOpts.Platform); err == nil {
+			scanOpts.Output = filepath.Join(dir, utils.PlatformArtifactName(utils.ReportArtifactPrefix, p))
+		}
+	}
+
+	log.Infof("Scanning %s with %s", opts.Image, opts.Scanner)
+	if err := report.ScanImage(ctx, scanOpts); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return scanOpts.Output, cleanup, nil
 }
 
 // displaySingleArchPlan shows a patching plan for single-arch images.
@@ -225,6 +405,18 @@ func getErrorInfo(err error) tui.ErrorInfo {
 
 	// Check for common error patterns and provide helpful hints
 	switch {
+	case errors.Is(err, types.ErrRebuildRequired):
+		return tui.ErrorInfo{
+			Title:   "Rebuild Required",
+			Message: errStr,
+			Hint:    "The image has no shell or package manager to patch in place; rebuild it from source with the fixed versions listed above",
+		}
+	case errors.Is(err, types.ErrNoPackagesPatched):
+		return tui.ErrorInfo{
+			Title:   "No Packages Patched",
+			Message: errStr,
+			Hint:    "Remove --fail-on-no-patch to treat a run that patches nothing as success",
+		}
 	case containsIgnoreCase(errStr, "no updates found"):
 		return tui.ErrorInfo{
 			Title:   "No Updates Available",
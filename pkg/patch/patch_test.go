@@ -3,18 +3,30 @@ package patch
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"text/tabwriter"
 	"time"
 
 	"github.com/distribution/reference"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/common"
 	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	buildkitclient "github.com/moby/buildkit/client"
 	ispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -192,6 +204,71 @@ func init() {
 	}
 }
 
+func TestPinnedDigestIsOriginalRef(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	image := u.Host + "/test/app:1.0"
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	// A malformed report under --ignore-errors returns the original image without
+	// needing BuildKit, after the reference has gone through the whole setup
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, os.WriteFile(reportFile, []byte(`{"SchemaVersion": 2, "Results": [`), 0o600))
+	opts := &types.Options{Image: image, PinDigest: true, Report: reportFile, Scanner: "trivy", IgnoreError: true}
+	require.NoError(t, resolveImage(context.Background(), opts))
+
+	// The patched image is still named after the tag
+	imageName, err := reference.ParseNormalizedNamed(opts.Image)
+	require.NoError(t, err)
+	_, patchedTag, err := common.ResolvePatchedImageName(imageName, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0-patched", patchedTag)
+
+	platform := types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: runtime.GOARCH}, ReportFile: reportFile}
+	result, err := patchSingleArchImage(context.Background(), opts, nil, platform, true, nil)
+	require.ErrorIs(t, err, types.ErrReportParse)
+	require.NotNil(t, result)
+	digested, ok := result.OriginalRef.(reference.Digested)
+	require.True(t, ok, "OriginalRef %s has no digest", result.OriginalRef)
+	assert.Equal(t, digest.String(), digested.Digest().String())
+}
+
+func TestReportParseErrorPreservesPlatform(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	image := u.Host + "/test/app:1.0"
+
+	reportFile := filepath.Join(t.TempDir(), "linux-"+runtime.GOARCH+".json")
+	require.NoError(t, os.WriteFile(reportFile, []byte(`{"SchemaVersion": 2, "Results": [`), 0o600))
+	platform := types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: runtime.GOARCH}, ReportFile: reportFile}
+
+	t.Run("best-effort preserves the platform", func(t *testing.T) {
+		opts := &types.Options{Image: image, Report: reportFile, Scanner: "trivy", IgnoreError: true}
+		res, err := patchSingleArchImage(context.Background(), opts, nil, platform, true, nil)
+		require.ErrorIs(t, err, types.ErrReportParse)
+		require.NotNil(t, res)
+		assert.Equal(t, res.OriginalRef.String(), res.PatchedRef.String())
+	})
+
+	t.Run("strict fails", func(t *testing.T) {
+		opts := &types.Options{Image: image, Report: reportFile, Scanner: "trivy"}
+		res, err := patchSingleArchImage(context.Background(), opts, nil, platform, true, nil)
+		require.ErrorIs(t, err, types.ErrReportParse)
+		assert.Nil(t, res)
+	})
+}
+
 func TestPatch_BuildReturnsNilResponse(t *testing.T) {
 	// This test verifies that Patch() handles errors gracefully and doesn't panic
 	// when the BuildKit connection fails.
@@ -236,6 +313,19 @@ func TestArchTag(t *testing.T) {
 	}
 }
 
+func TestPlatformConcurrency(t *testing.T) {
+	cpus := runtime.NumCPU()
+	assert.Equal(t, cpus, platformConcurrency(0))
+	assert.Equal(t, 1, platformConcurrency(1))
+	assert.Equal(t, cpus+10, platformConcurrency(cpus+10))
+}
+
+func TestOCIPartialMode(t *testing.T) {
+	assert.Equal(t, buildkit.OCIPartialStrict, ociPartialMode(&types.Options{}))
+	assert.Equal(t, buildkit.OCIPartialOmit, ociPartialMode(&types.Options{IgnoreError: true}))
+	assert.Equal(t, buildkit.OCIPartialPreserve, ociPartialMode(&types.Options{IgnoreError: true, OCIPartial: "preserve"}))
+}
+
 func TestNormalizeConfigForPlatform(t *testing.T) {
 	// minimal starting config (missing fields on purpose)
 	orig := []byte(`{"architecture":"amd64"}`)
@@ -351,3 +441,160 @@ windows/amd64  Not Patched  docker.io/library/nginx (original reference)  Window
 		}
 	}
 }
+
+func TestUpdatesAllOSPackages(t *testing.T) {
+	updates := &unversioned.UpdateManifest{}
+	tests := []struct {
+		name    string
+		opts    types.Options
+		updates *unversioned.UpdateManifest
+		want    bool
+	}{
+		{name: "not requested", opts: types.Options{}, updates: updates, want: false},
+		{name: "default package types", opts: types.Options{UpdateAll: true}, updates: updates, want: true},
+		{name: "os and library", opts: types.Options{UpdateAll: true, PkgTypes: "os,library"}, updates: updates, want: true},
+		{name: "library only", opts: types.Options{UpdateAll: true, PkgTypes: "library"}, updates: updates, want: false},
+		{name: "no report", opts: types.Options{UpdateAll: true}, updates: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, updatesAllOSPackages(&tt.opts, tt.updates))
+		})
+	}
+}
+
+func TestForceNodeOnlyUpdates(t *testing.T) {
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{{Name: "openssl", FixedVersion: "3.0.13-1~deb12u1"}},
+		LangUpdates: unversioned.LangUpdatePackages{
+			{Name: "lodash", FixedVersion: "4.17.21", Type: utils.NodePackages},
+			{Name: "requests", FixedVersion: "2.32.0", Type: utils.PythonPackages},
+		},
+	}
+
+	forceNodeOnlyUpdates(updates)
+	assert.Empty(t, updates.OSUpdates)
+	require.Len(t, updates.LangUpdates, 1)
+	assert.Equal(t, "lodash", updates.LangUpdates[0].Name)
+}
+
+func TestCheckPatchedPackages(t *testing.T) {
+	withReport := types.Options{Image: "alpine:3.18", Report: "report.json", FailOnNoPatch: true}
+	tests := []struct {
+		name    string
+		opts    types.Options
+		err     error
+		results []*types.PatchResult
+		wantErr error
+	}{
+		{
+			name:    "flag not set",
+			opts:    types.Options{Report: "report.json"},
+			err:     types.ErrNoUpdatesFound,
+			wantErr: types.ErrNoUpdatesFound,
+		},
+		{
+			name:    "empty update manifest",
+			opts:    withReport,
+			err:     types.ErrNoUpdatesFound,
+			wantErr: types.ErrNoPackagesPatched,
+		},
+		{
+			name: "all packages unpatchable",
+			opts: withReport,
+			results: []*types.PatchResult{
+				{ErroredPackages: []string{"openssl", "libcrypto3"}},
+			},
+			wantErr: types.ErrNoPackagesPatched,
+		},
+		{
+			name: "all packages skipped by direct-only",
+			opts: withReport,
+			results: []*types.PatchResult{
+				{SkippedPackages: []string{"qs"}},
+			},
+			wantErr: types.ErrNoPackagesPatched,
+		},
+		{
+			name:    "os packages patched",
+			opts:    withReport,
+			results: []*types.PatchResult{{PatchedPackages: 2}},
+		},
+		{
+			name:    "only library packages patched",
+			opts:    withReport,
+			results: []*types.PatchResult{{FixedCVEs: []string{"CVE-2021-23337"}}},
+		},
+		{
+			name: "preserved platforms are not counted",
+			opts: withReport,
+			results: []*types.PatchResult{
+				{Preserved: true},
+				{ErroredPackages: []string{"openssl"}},
+			},
+			wantErr: types.ErrNoPackagesPatched,
+		},
+		{
+			name: "all packages unpatchable with a platform report map",
+			opts: types.Options{Image: "alpine:3.18", PlatformReportMap: []string{"linux/arm64=arm64.json"}, FailOnNoPatch: true},
+			results: []*types.PatchResult{
+				{ErroredPackages: []string{"openssl"}},
+				{SkippedPackages: []string{"qs"}},
+			},
+			wantErr: types.ErrNoPackagesPatched,
+		},
+		{
+			name:    "no report patches packages the report does not list",
+			opts:    types.Options{FailOnNoPatch: true},
+			results: []*types.PatchResult{{}},
+		},
+		{
+			name:    "other errors are returned unchanged",
+			opts:    withReport,
+			err:     types.ErrPackageNotFound,
+			wantErr: types.ErrPackageNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPatchedPackages(&tt.opts, tt.err, tt.results...)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			if errors.Is(tt.wantErr, types.ErrNoPackagesPatched) {
+				// main treats ErrNoUpdatesFound as success, so it must not be wrapped
+				assert.NotErrorIs(t, err, types.ErrNoUpdatesFound)
+			}
+		})
+	}
+}
+
+func TestSingleArchResult(t *testing.T) {
+	platform := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: "amd64"}}
+	upToDate := &types.PatchResult{NoUpdatesApplied: true}
+
+	assert.NoError(t, singleArchResult(&types.Options{Image: "alpine:3.18"}, platform, upToDate, types.ErrNoUpdatesFound))
+	err := singleArchResult(&types.Options{Image: "alpine:3.18", FailOnNoPatch: true}, platform, upToDate, types.ErrNoUpdatesFound)
+	assert.ErrorIs(t, err, types.ErrNoPackagesPatched)
+	assert.NoError(t, singleArchResult(&types.Options{Image: "alpine:3.18"}, platform, &types.PatchResult{PatchedPackages: 1}, nil))
+	assert.ErrorIs(t, singleArchResult(&types.Options{Image: "alpine:3.18"}, platform, nil, types.ErrPackageNotFound), types.ErrPackageNotFound)
+}
+
+func TestNewClientUsesSharedClient(t *testing.T) {
+	ctx := context.Background()
+	shared, err := buildkitclient.New(ctx, "unix:///tmp/nowhere.sock")
+	require.NoError(t, err)
+	defer shared.Close()
+
+	got, closeClient, err := newClient(WithSharedClient(ctx, shared), buildkit.Opts{})
+	require.NoError(t, err)
+	assert.Same(t, shared, got)
+	closeClient()
+
+	got, closeClient, err = newClient(ctx, buildkit.Opts{})
+	require.NoError(t, err)
+	assert.NotSame(t, shared, got)
+	closeClient()
+}
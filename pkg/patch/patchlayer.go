@@ -0,0 +1,20 @@
+package patch
+
+import (
+	"github.com/moby/buildkit/client/llb"
+)
+
+// withSinglePatchLayer rebuilds patched as the untouched layers of base plus one layer
+// holding every file the patch changed. The package manager steps otherwise add a
+// layer each, and language updates run on top of the OS patch layer, so squashing
+// them keeps the base layers shared with other images built from the same base.
+func withSinglePatchLayer(base, patched llb.State) llb.State {
+	return llb.Merge([]llb.State{base, patchDiffLayer(base, patched)})
+}
+
+// patchDiffLayer returns the files patched adds or changes on top of base, copied
+// onto scratch so the result holds those paths only and none of the base rootfs.
+func patchDiffLayer(base, patched llb.State) llb.State {
+	patchDiff := llb.Diff(base, patched, llb.WithCustomName("Computing patch layer"))
+	return llb.Scratch().File(llb.Copy(patchDiff, "/", "/"), llb.WithCustomName("Squashing patch layer"))
+}
@@ -0,0 +1,109 @@
+package patch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSinglePatchLayer(t *testing.T) {
+	base := llb.Image("docker.io/library/alpine:3.18")
+	patched := base.Run(llb.Shlex("apk upgrade --no-cache openssl")).Root().
+		Run(llb.Shlex("npm update --prefix /app")).Root()
+
+	def, err := withSinglePatchLayer(base, patched).Marshal(context.Background())
+	require.NoError(t, err)
+
+	ops := make(map[digest.Digest]*pb.Op, len(def.Def))
+	var merges, diffs, copies []*pb.Op
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		ops[digest.FromBytes(dt)] = &op
+		switch {
+		case op.GetMerge() != nil:
+			merges = append(merges, &op)
+		case op.GetDiff() != nil:
+			diffs = append(diffs, &op)
+		case op.GetFile() != nil:
+			copies = append(copies, &op)
+		}
+	}
+
+	require.Len(t, merges, 1)
+	assert.Len(t, diffs, 1)
+	assert.Len(t, copies, 1, "the patch diff should be squashed into one layer")
+
+	// The merge keeps the original image as its lower layers and adds the squashed
+	// patch on top.
+	inputs := merges[0].GetInputs()
+	require.Len(t, inputs, 2)
+	lower := ops[digest.Digest(inputs[0].GetDigest())]
+	require.NotNil(t, lower)
+	assert.Equal(t, "docker-image://docker.io/library/alpine:3.18", lower.GetSource().GetIdentifier())
+	upper := ops[digest.Digest(inputs[1].GetDigest())]
+	require.NotNil(t, upper)
+	assert.NotNil(t, upper.GetFile())
+}
+
+func TestPatchDiffLayer(t *testing.T) {
+	base := llb.Image("docker.io/library/alpine:3.18")
+	patched := base.Run(llb.Shlex("apk upgrade --no-cache openssl")).Root()
+
+	def, err := patchDiffLayer(base, patched).Marshal(context.Background())
+	require.NoError(t, err)
+
+	ops := make(map[digest.Digest]*pb.Op, len(def.Def))
+	var merges, diffs, copies []*pb.Op
+	var diffDigest digest.Digest
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		ops[digest.FromBytes(dt)] = &op
+		switch {
+		case op.GetMerge() != nil:
+			merges = append(merges, &op)
+		case op.GetDiff() != nil:
+			diffs = append(diffs, &op)
+			diffDigest = digest.FromBytes(dt)
+		case op.GetFile() != nil:
+			copies = append(copies, &op)
+		}
+	}
+
+	// The base rootfs is only the lower side of the diff; nothing merges it back in.
+	assert.Empty(t, merges)
+	require.Len(t, diffs, 1)
+	lower := ops[digest.Digest(diffs[0].GetInputs()[diffs[0].GetDiff().GetLower().GetInput()].GetDigest())]
+	require.NotNil(t, lower)
+	assert.Equal(t, "docker-image://docker.io/library/alpine:3.18", lower.GetSource().GetIdentifier())
+
+	// The changed paths are copied onto scratch, so the copy has no base input.
+	require.Len(t, copies, 1)
+	require.Len(t, copies[0].GetInputs(), 1, "only the diff should feed the copy")
+	assert.Equal(t, diffDigest, digest.Digest(copies[0].GetInputs()[0].GetDigest()))
+}
+
+func TestDiffExportEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patch.tar")
+	entry := diffExportEntry(path)
+	assert.Equal(t, client.ExporterTar, entry.Type)
+
+	w, err := entry.Output(nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("layer"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	dt, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "layer", string(dt))
+}
@@ -1,6 +1,7 @@
 package patch
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
 )
 
 const (
@@ -119,6 +121,7 @@ func filterPlatforms(discoveredPlatforms []types.PatchPlatform, targetPlatforms
 
 // getPlatformDescriptorFromManifest gets the descriptor for a specific platform from a multi-arch manifest.
 func getPlatformDescriptorFromManifest(
+	ctx context.Context,
 	imageRef string,
 	targetPlatform *types.PatchPlatform,
 ) (*ispec.Descriptor, error) {
@@ -128,10 +131,10 @@ func getPlatformDescriptorFromManifest(
 	}
 
 	// Try local daemon first, then fall back to remote
-	desc, err := buildkit.TryGetManifestFromLocal(ref)
+	desc, err := buildkit.TryGetManifestFromLocal(ctx, ref)
 	if err != nil {
 		log.Debugf("Failed to get descriptor from local daemon: %v, trying remote registry", err)
-		desc, err = remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		desc, err = utils.RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 		if err != nil {
 			return nil, fmt.Errorf("error fetching descriptor for %q from both local daemon and remote registry: %w", imageRef, err)
 		}
@@ -0,0 +1,41 @@
+package patch
+
+import (
+	"fmt"
+
+	"github.com/containerd/platforms"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+// reportPlatformMismatch compares the architecture recorded in a per-platform report
+// with the platform it is about to be applied to. It returns a description of the
+// difference, or "" when they agree or the report records no architecture. This catches
+// a report saved under the wrong platform's name, which would otherwise apply one
+// architecture's fixes to another. With ignoreVariant, as when reports are matched to
+// platforms on os/arch only, a report applies to every variant of its architecture.
+func reportPlatformMismatch(manifest *unversioned.UpdateManifest, target *ispec.Platform, ignoreVariant bool) string {
+	if manifest == nil || manifest.Metadata.Config.Arch == "" {
+		return ""
+	}
+
+	// Normalize both sides so aarch64 matches arm64 and arm64/v8 matches arm64
+	reported := platforms.Normalize(ispec.Platform{
+		OS:           target.OS,
+		Architecture: manifest.Metadata.Config.Arch,
+		Variant:      manifest.Metadata.Config.Variant,
+	})
+	want := platforms.Normalize(ispec.Platform{
+		OS:           target.OS,
+		Architecture: target.Architecture,
+		Variant:      target.Variant,
+	})
+
+	// Scanners often leave the variant out, so it is only compared when recorded
+	if reported.Architecture == want.Architecture &&
+		(ignoreVariant || manifest.Metadata.Config.Variant == "" || reported.Variant == want.Variant) {
+		return ""
+	}
+	return fmt.Sprintf("report is for %s but is being applied to %s", platforms.Format(reported), platforms.Format(want))
+}
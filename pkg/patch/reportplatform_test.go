@@ -0,0 +1,50 @@
+package patch
+
+import (
+	"testing"
+
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+func TestReportPlatformMismatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		arch, variant string
+		target        ispec.Platform
+		ignoreVariant bool
+		wantMismatch  bool
+	}{
+		{name: "same architecture", arch: "amd64", target: ispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{name: "no architecture recorded", target: ispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{name: "aarch64 alias", arch: "aarch64", target: ispec.Platform{OS: "linux", Architecture: "arm64"}},
+		{name: "arm64 v8 variant", arch: "arm64", variant: "v8", target: ispec.Platform{OS: "linux", Architecture: "arm64"}},
+		{name: "variant not recorded", arch: "arm", target: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{name: "arm64 report for amd64", arch: "arm64", target: ispec.Platform{OS: "linux", Architecture: "amd64"}, wantMismatch: true},
+		{name: "different arm variant", arch: "arm", variant: "v7", target: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, wantMismatch: true},
+		{name: "different arm variant ignored", arch: "arm", variant: "v7", target: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, ignoreVariant: true},
+		{name: "different architecture with variant ignored", arch: "arm64", target: ispec.Platform{OS: "linux", Architecture: "amd64"}, ignoreVariant: true, wantMismatch: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &unversioned.UpdateManifest{
+				Metadata: unversioned.Metadata{Config: unversioned.Config{Arch: tt.arch, Variant: tt.variant}},
+			}
+			mismatch := reportPlatformMismatch(manifest, &tt.target, tt.ignoreVariant)
+			if tt.wantMismatch {
+				assert.NotEmpty(t, mismatch)
+			} else {
+				assert.Empty(t, mismatch)
+			}
+		})
+	}
+
+	assert.Equal(t, "report is for linux/arm64 but is being applied to linux/amd64",
+		reportPlatformMismatch(&unversioned.UpdateManifest{
+			Metadata: unversioned.Metadata{Config: unversioned.Config{Arch: "arm64"}},
+		}, &ispec.Platform{OS: "linux", Architecture: "amd64"}, false))
+	assert.Empty(t, reportPlatformMismatch(nil, &ispec.Platform{OS: "linux", Architecture: "amd64"}, false))
+}
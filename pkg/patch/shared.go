@@ -0,0 +1,259 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/containerd/platforms"
+	"github.com/distribution/reference"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/report"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// sharedPatchCandidate describes a platform that is about to be patched,
+// keyed by what determines the patch output: the layers of its base image and
+// the set of updates to apply.
+type sharedPatchCandidate struct {
+	platformKey string
+	baseLayers  string
+	updateSet   string
+}
+
+// groupSharedPatches maps each platform that can reuse another platform's patch
+// to the platform that will actually be patched. Platforms are only grouped when
+// their base images have the same layers and they share the same update set;
+// candidates with unknown layers are always patched on their own.
+func groupSharedPatches(candidates []sharedPatchCandidate) map[string]string {
+	followers := make(map[string]string)
+	leaders := make(map[string]string)
+	for _, c := range candidates {
+		if c.baseLayers == "" {
+			continue
+		}
+		key := c.baseLayers + "\x00" + c.updateSet
+		if leader, ok := leaders[key]; ok {
+			followers[c.platformKey] = leader
+			continue
+		}
+		leaders[key] = c.platformKey
+	}
+	return followers
+}
+
+// updateSetKey returns a stable key for the updates in a manifest so that two
+// reports requesting the same package versions produce the same key.
+func updateSetKey(manifest *unversioned.UpdateManifest) string {
+	if manifest == nil {
+		return ""
+	}
+	var entries []string
+	for _, u := range manifest.OSUpdates {
+		entries = append(entries, "os:"+u.Name+"="+u.FixedVersion)
+	}
+	for _, u := range manifest.LangUpdates {
+		entries = append(entries, "lang:"+u.Name+"@"+u.PkgPath+"="+u.FixedVersion)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
+
+// findSharedPatches inspects the platforms to be patched and returns the platforms
+// whose patch can be reused from another platform (follower -> leader). Platforms
+// that cannot be inspected are patched normally.
+func findSharedPatches(ctx context.Context, opts *types.Options, reportOpts *report.ParseOptions, platforms []types.PatchPlatform) map[string]string {
+	var candidates []sharedPatchCandidate
+	for i := range platforms {
+		p := &platforms[i]
+		if p.ShouldPreserve {
+			continue
+		}
+		platformKey := buildkit.PlatformKey(p.Platform)
+
+		// The per-platform manifests of an index always differ, if only in the config
+		// that names the platform, so platforms are compared by their layers.
+		desc, err := getPlatformDescriptorFromManifest(ctx, opts.Image, p)
+		if err != nil {
+			log.Debugf("Not sharing patch for platform %s: %v", platformKey, err)
+			continue
+		}
+		layers, err := platformLayers(ctx, opts.Image, desc)
+		if err != nil {
+			log.Debugf("Not sharing patch for platform %s: %v", platformKey, err)
+			continue
+		}
+
+		// Without a report every platform runs the same "upgrade all" commands.
+		var updateSet string
+		if p.ReportFile != "" {
+			updates, err := report.TryParseScanReportWithOptions(p.ReportFile, opts.Scanner, opts.PkgTypes, opts.LibraryPatchLevel, reportOpts)
+			if err != nil {
+				log.Debugf("Not sharing patch for platform %s: %v", platformKey, err)
+				continue
+			}
+			updateSet = updateSetKey(updates)
+		}
+
+		candidates = append(candidates, sharedPatchCandidate{
+			platformKey: platformKey,
+			baseLayers:  strings.Join(layers, ","),
+			updateSet:   updateSet,
+		})
+	}
+
+	followers := groupSharedPatches(candidates)
+	for follower, leader := range followers {
+		log.Infof("Platform %s has the same base image and updates as %s; reusing its patch", follower, leader)
+	}
+	return followers
+}
+
+// platformLayers fetches the image manifest desc points to from the repository
+// of imageRef and returns its layer digests in order.
+func platformLayers(ctx context.Context, imageRef string, desc *ispec.Descriptor) ([]string, error) {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing reference %q: %w", imageRef, err)
+	}
+	platformDesc, err := utils.RemoteGet(ctx, ref.Context().Digest(desc.Digest.String()), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching manifest %s: %w", desc.Digest, err)
+	}
+	img, err := platformDesc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("error reading image %s: %w", desc.Digest, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest %s: %w", desc.Digest, err)
+	}
+	layers := make([]string, 0, len(manifest.Layers))
+	for _, l := range manifest.Layers {
+		layers = append(layers, l.Digest.String())
+	}
+	return layers, nil
+}
+
+// reuseSharedPatch returns the leader's patch result for a follower platform. The
+// follower gets the leader's patched layers under a config that declares its own
+// platform, so its index entry never points at an image of another architecture or
+// variant. When the leader was pushed, that image is written next to it in the
+// registry under the follower's platform tag.
+func reuseSharedPatch(ctx context.Context, leader *types.PatchResult, p *types.PatchPlatform, push bool) (types.PatchResult, error) {
+	if leader == nil || leader.PatchedDesc == nil {
+		return types.PatchResult{}, fmt.Errorf("no patch result to share with platform %s", buildkit.PlatformKey(p.Platform))
+	}
+	res := *leader
+	desc := *leader.PatchedDesc
+	platform := p.Platform
+	desc.Platform = &ispec.Platform{
+		OS:           platform.OS,
+		Architecture: platform.Architecture,
+		Variant:      platform.Variant,
+		OSVersion:    platform.OSVersion,
+		OSFeatures:   platform.OSFeatures,
+	}
+	res.PatchedDesc = &desc
+	res.Platform = platform
+
+	// The OCI layout is exported from the leader's state with this config
+	if leader.ConfigData != nil {
+		configData, err := normalizeConfigForPlatform(leader.ConfigData, p)
+		if err != nil {
+			return types.PatchResult{}, fmt.Errorf("failed to set the platform of the shared config for %s: %w", buildkit.PlatformKey(platform), err)
+		}
+		res.ConfigData = configData
+	}
+
+	if push {
+		ref, manifestDesc, err := pushSharedPlatformImage(ctx, leader, &platform)
+		if err != nil {
+			return types.PatchResult{}, fmt.Errorf("failed to push the shared patch for %s: %w", buildkit.PlatformKey(platform), err)
+		}
+		desc.MediaType = manifestDesc.MediaType
+		desc.Digest = manifestDesc.Digest
+		desc.Size = manifestDesc.Size
+		res.PatchedRef = ref
+	}
+	return res, nil
+}
+
+// pushSharedPlatformImage writes an image with the layers of the leader's pushed patch
+// and its config set to platform to the leader's repository, tagged for platform, and
+// returns the reference and the descriptor of its manifest.
+func pushSharedPlatformImage(ctx context.Context, leader *types.PatchResult, platform *ispec.Platform) (reference.Named, *ispec.Descriptor, error) {
+	tagged, ok := leader.PatchedRef.(reference.NamedTagged)
+	if !ok {
+		return nil, nil, fmt.Errorf("patched image %s has no tag", leader.PatchedRef)
+	}
+	repo, err := name.NewRepository(leader.PatchedRef.Name())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing repository %q: %w", leader.PatchedRef.Name(), err)
+	}
+	auth := remote.WithAuthFromKeychain(authn.DefaultKeychain)
+
+	remoteDesc, err := utils.RemoteGet(ctx, repo.Digest(leader.PatchedDesc.Digest.String()), auth)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching patched image %s: %w", leader.PatchedRef, err)
+	}
+	img, err := remoteDesc.Image()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading patched image %s: %w", leader.PatchedRef, err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading config of %s: %w", leader.PatchedRef, err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS, cfg.Architecture, cfg.Variant = platform.OS, platform.Architecture, platform.Variant
+	cfg.OSVersion, cfg.OSFeatures = platform.OSVersion, platform.OSFeatures
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error setting the platform of %s: %w", leader.PatchedRef, err)
+	}
+
+	// The leader is tagged <tag>-<arch>[-<variant>]; the follower gets its own suffix
+	leaderPlatform := leader.Platform
+	baseTag := strings.TrimSuffix(tagged.Tag(), archTag("", leaderPlatform.Architecture, leaderPlatform.Variant))
+	tag := repo.Tag(archTag(baseTag, platform.Architecture, platform.Variant))
+	release, err := utils.AcquireRegistrySlot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = remote.Write(tag, img, auth, remote.WithContext(ctx))
+	release()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error pushing %s: %w", tag, err)
+	}
+
+	manifestDigest, err := img.Digest()
+	if err != nil {
+		return nil, nil, err
+	}
+	size, err := img.Size()
+	if err != nil {
+		return nil, nil, err
+	}
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, err := reference.WithTag(reference.TrimNamed(leader.PatchedRef), tag.TagStr())
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Infof("Pushed shared patch for %s as %s@%s", platforms.Format(*platform), tag, manifestDigest)
+	return ref, &ispec.Descriptor{MediaType: string(mediaType), Digest: digest.Digest(manifestDigest.String()), Size: size}, nil
+}
@@ -0,0 +1,227 @@
+package patch
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/distribution/reference"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	ispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+func TestGroupSharedPatches(t *testing.T) {
+	updates := updateSetKey(&unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{
+			{Name: "openssl", FixedVersion: "3.0.13-r0"},
+			{Name: "busybox", FixedVersion: "1.36.1-r2"},
+		},
+	})
+
+	tests := []struct {
+		name       string
+		candidates []sharedPatchCandidate
+		want       map[string]string
+	}{
+		{
+			name: "same base and updates are patched once",
+			candidates: []sharedPatchCandidate{
+				{platformKey: "linux/arm/v6", baseLayers: "sha256:aaa", updateSet: updates},
+				{platformKey: "linux/arm/v7", baseLayers: "sha256:aaa", updateSet: updates},
+			},
+			want: map[string]string{"linux/arm/v7": "linux/arm/v6"},
+		},
+		{
+			name: "different base layers are patched separately",
+			candidates: []sharedPatchCandidate{
+				{platformKey: "linux/amd64", baseLayers: "sha256:aaa", updateSet: updates},
+				{platformKey: "linux/arm64", baseLayers: "sha256:bbb", updateSet: updates},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "different updates are patched separately",
+			candidates: []sharedPatchCandidate{
+				{platformKey: "linux/arm/v6", baseLayers: "sha256:aaa", updateSet: updates},
+				{platformKey: "linux/arm/v7", baseLayers: "sha256:aaa", updateSet: "os:openssl=3.0.13-r0"},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "unknown base layers are never shared",
+			candidates: []sharedPatchCandidate{
+				{platformKey: "linux/arm/v6", updateSet: updates},
+				{platformKey: "linux/arm/v7", updateSet: updates},
+			},
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, groupSharedPatches(tt.candidates))
+		})
+	}
+}
+
+func TestFindSharedPatches(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	image := strings.TrimPrefix(srv.URL, "http://") + "/app:1.0"
+
+	// arm/v6 and arm/v7 are built from the same layers, amd64 is not. Each platform
+	// has its own config and so its own manifest digest.
+	shared, err := random.Layer(64, "")
+	require.NoError(t, err)
+	other, err := random.Layer(64, "")
+	require.NoError(t, err)
+	platformImage := func(layer v1.Layer, p v1.Platform) mutate.IndexAddendum {
+		img, err := mutate.AppendLayers(empty.Image, layer)
+		require.NoError(t, err)
+		cfg, err := img.ConfigFile()
+		require.NoError(t, err)
+		cfg.OS, cfg.Architecture, cfg.Variant = p.OS, p.Architecture, p.Variant
+		img, err = mutate.ConfigFile(img, cfg)
+		require.NoError(t, err)
+		return mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &p}}
+	}
+	idx := mutate.AppendManifests(empty.Index,
+		platformImage(shared, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}),
+		platformImage(shared, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}),
+		platformImage(other, v1.Platform{OS: "linux", Architecture: "amd64"}),
+	)
+	ref, err := name.ParseReference(image)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, idx))
+
+	platforms := []types.PatchPlatform{
+		{Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{Platform: ispec.Platform{OS: "linux", Architecture: "amd64"}},
+	}
+	ctx := buildkit.WithPullPolicy(context.Background(), buildkit.PullAlways)
+	followers := findSharedPatches(ctx, &types.Options{Image: image}, nil, platforms)
+	assert.Equal(t, map[string]string{"linux/arm/v7": "linux/arm/v6"}, followers)
+}
+
+func TestUpdateSetKeyIsOrderIndependent(t *testing.T) {
+	a := &unversioned.UpdateManifest{OSUpdates: unversioned.UpdatePackages{
+		{Name: "openssl", FixedVersion: "3.0.13-r0"},
+		{Name: "busybox", FixedVersion: "1.36.1-r2"},
+	}}
+	b := &unversioned.UpdateManifest{OSUpdates: unversioned.UpdatePackages{
+		{Name: "busybox", FixedVersion: "1.36.1-r2"},
+		{Name: "openssl", FixedVersion: "3.0.13-r0"},
+	}}
+	assert.Equal(t, updateSetKey(a), updateSetKey(b))
+	assert.Equal(t, "", updateSetKey(nil))
+}
+
+func TestReuseSharedPatch(t *testing.T) {
+	ref, err := reference.ParseNormalizedNamed("docker.io/library/alpine:3.19")
+	require.NoError(t, err)
+
+	leader := &types.PatchResult{
+		OriginalRef: ref,
+		PatchedRef:  ref,
+		PatchedDesc: &ispec.Descriptor{
+			MediaType: ispec.MediaTypeImageManifest,
+			Digest:    "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+			Platform:  &ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		},
+		Platform:   ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		ConfigData: []byte(`{"architecture":"arm","variant":"v6","os":"linux"}`),
+	}
+	follower := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}}
+
+	res, err := reuseSharedPatch(context.Background(), leader, follower, false)
+	require.NoError(t, err)
+
+	// The shared patch layer (same manifest digest) is reused for the second arch.
+	assert.Equal(t, leader.PatchedDesc.Digest, res.PatchedDesc.Digest)
+	assert.Equal(t, leader.PatchedRef, res.PatchedRef)
+	assert.Equal(t, "v7", res.PatchedDesc.Platform.Variant)
+	assert.Equal(t, follower.Platform, res.Platform)
+	// The OCI layout config declares the follower's platform.
+	assert.JSONEq(t, `{"architecture":"arm","variant":"v7","os":"linux"}`, string(res.ConfigData))
+	// The leader's descriptor is left untouched.
+	assert.Equal(t, "v6", leader.PatchedDesc.Platform.Variant)
+
+	_, err = reuseSharedPatch(context.Background(), nil, follower, false)
+	assert.Error(t, err)
+}
+
+func TestReuseSharedPatchPush(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	repo := strings.TrimPrefix(srv.URL, "http://") + "/app"
+
+	// The leader's patched image as pushed for arm/v6
+	layer, err := random.Layer(64, "")
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	cfg.OS, cfg.Architecture, cfg.Variant = "linux", "arm", "v6"
+	img, err = mutate.ConfigFile(img, cfg)
+	require.NoError(t, err)
+	leaderTag, err := name.NewTag(repo + ":1.0-patched-arm-v6")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(leaderTag, img))
+	leaderDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	patchedRef, err := reference.ParseNormalizedNamed(leaderTag.String())
+	require.NoError(t, err)
+	leader := &types.PatchResult{
+		PatchedRef: patchedRef,
+		PatchedDesc: &ispec.Descriptor{
+			MediaType: ispec.MediaTypeImageManifest,
+			Digest:    digest.Digest(leaderDigest.String()),
+			Platform:  &ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		},
+		Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+	}
+	follower := &types.PatchPlatform{Platform: ispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}}
+
+	res, err := reuseSharedPatch(context.Background(), leader, follower, true)
+	require.NoError(t, err)
+	assert.Equal(t, repo+":1.0-patched-arm-v7", res.PatchedRef.String())
+	assert.NotEqual(t, leader.PatchedDesc.Digest, res.PatchedDesc.Digest)
+
+	// The follower's manifest has the leader's layers and a config declaring its platform
+	followerTag, err := name.NewTag(res.PatchedRef.String())
+	require.NoError(t, err)
+	pushed, err := remote.Image(followerTag)
+	require.NoError(t, err)
+	pushedDigest, err := pushed.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, res.PatchedDesc.Digest.String(), pushedDigest.String())
+	pushedCfg, err := pushed.ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, "arm", pushedCfg.Architecture)
+	assert.Equal(t, "v7", pushedCfg.Variant)
+	pushedLayers, err := pushed.Layers()
+	require.NoError(t, err)
+	require.Len(t, pushedLayers, 1)
+	wantLayer, err := layer.Digest()
+	require.NoError(t, err)
+	gotLayer, err := pushedLayers[0].Digest()
+	require.NoError(t, err)
+	assert.Equal(t, wantLayer, gotLayer)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/common"
 	"github.com/project-copacetic/copacetic/pkg/imageloader"
+	"github.com/project-copacetic/copacetic/pkg/pkgmgr"
 	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/tui"
 	"github.com/project-copacetic/copacetic/pkg/types"
@@ -52,6 +53,7 @@ func removeIfNotDebug(workingFolder string) {
 func patchSingleArchImage(
 	ctx context.Context,
 	opts *types.Options,
+	reportOpts *report.ParseOptions,
 	//nolint:gocritic
 	targetPlatform types.PatchPlatform,
 	multiPlatform bool,
@@ -77,9 +79,8 @@ func patchSingleArchImage(
 	}
 	pkgTypes := opts.PkgTypes
 	libraryPatchLevel := opts.LibraryPatchLevel
-	toolchainPatchLevel := opts.ToolchainPatchLevel
 
-	if reportFile == "" && output != "" {
+	if reportFile == "" && (output != "" || opts.AttachAttestations) {
 		log.Warn("No vulnerability report was provided, so no VEX output will be generated.")
 	}
 
@@ -107,6 +108,11 @@ func patchSingleArchImage(
 	}
 	patchedImageName := fmt.Sprintf("%s:%s", patchImage, patchedTag)
 
+	llbDumpPath := opts.DumpLLB
+	if multiPlatform && llbDumpPath != "" {
+		llbDumpPath = platformLLBDumpPath(llbDumpPath, targetPlatform.Platform)
+	}
+
 	// Setup working folder
 	workingFolder, cleanup, err := setupWorkingFolder(workingFolder)
 	if err != nil {
@@ -116,10 +122,33 @@ func patchSingleArchImage(
 
 	// Parse report for update packages
 	var updates *unversioned.UpdateManifest
+	// OS packages --update-all upgrades along with the report's updates
+	var installedOS unversioned.UpdatePackages
 	if reportFile != "" {
-		updates, err = report.TryParseScanReport(reportFile, scanner, pkgTypes, libraryPatchLevel)
+		updates, err = report.TryParseScanReportWithOptions(reportFile, scanner, pkgTypes, libraryPatchLevel, reportOpts)
 		if err != nil {
-			return nil, err
+			if !multiPlatform {
+				return nil, err
+			}
+			err = fmt.Errorf("%w: %s: %w", types.ErrReportParse, reportFile, err)
+			if !ignoreError {
+				return nil, err
+			}
+			log.Warnf("Skipping platform %s: %v", targetPlatform.String(), err)
+			res, _ := createOriginalImageResult(ctx, imageName, &targetPlatform, image)
+			return res, err
+		}
+
+		if multiPlatform {
+			if mismatch := reportPlatformMismatch(updates, &targetPlatform.Platform, opts.ReportPlatformKey == string(buildkit.ReportPlatformKeyOSArch)); mismatch != "" {
+				err := fmt.Errorf("%w: %s: %s", types.ErrReportPlatformMismatch, reportFile, mismatch)
+				if opts.StrictReportPlatform {
+					return nil, err
+				}
+				log.Warnf("Skipping platform %s: %v", targetPlatform.String(), err)
+				res, _ := createOriginalImageResult(ctx, imageName, &targetPlatform, image)
+				return res, err
+			}
 		}
 
 		// Filter updates based on package types
@@ -141,13 +170,25 @@ func patchSingleArchImage(
 				updates.LangUpdates = []unversioned.UpdatePackage{}
 			}
 
+			if opts.ForcePkgManager == pkgmgr.ForceNPM {
+				forceNodeOnlyUpdates(updates)
+			}
+
 			log.Debugf("Filtered updates to apply: OS=%d, Lang=%d", len(updates.OSUpdates), len(updates.LangUpdates))
 
+			updateAllOS := updatesAllOSPackages(opts, updates)
+			if updateAllOS {
+				installedOS, err = report.ListInstalledOSPackages(reportFile, scanner)
+				if err != nil {
+					return nil, fmt.Errorf("--update-all: %w", err)
+				}
+				log.Infof("Updating all %d installed OS packages listed in %s to their latest versions", len(installedOS), reportFile)
+			}
+
 			// If after filtering there are zero OS and zero library updates, return an error
 			// only when user explicitly requested some package types (default is OS) but none are patchable.
-			if len(updates.OSUpdates) == 0 && len(updates.LangUpdates) == 0 {
-				res, _ := createOriginalImageResult(imageName, &targetPlatform, image)
-				return res, types.ErrNoUpdatesFound
+			if !updateAllOS && len(updates.OSUpdates) == 0 && len(updates.LangUpdates) == 0 {
+				return upToDateResult(ctx, imageName, &targetPlatform, image)
 			}
 		}
 
@@ -155,11 +196,11 @@ func patchSingleArchImage(
 	}
 
 	// Create buildkit client
-	bkClient, err := bkNewClient(ctx, bkOpts)
+	bkClient, closeClient, err := newClient(ctx, bkOpts)
 	if err != nil {
 		return nil, err
 	}
-	defer bkClient.Close()
+	defer closeClient()
 
 	// Resolve image reference
 	ref := resolveImageReference(imageName)
@@ -173,11 +214,34 @@ func patchSingleArchImage(
 	// Create pipes for Docker export
 	pipeR, pipeW := io.Pipe()
 
+	cacheOpts, err := buildkit.ParseCacheOptions(opts.CacheFrom, opts.CacheTo)
+	if err != nil {
+		return nil, err
+	}
+
+	pushDestinations, err := resolvePushDestinations(opts.PushTo, patchedTag)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create build configuration
-	buildConfig, err := createBuildConfig(patchedImageName, shouldExportOCI, push, pipeW)
+	// --export-diff replaces the image with a tar of the patched files
+	exportDiff := opts.ExportDiff != ""
+	load := !exportDiff && shouldLoadImage(opts, &targetPlatform, multiPlatform)
+	push = push && !exportDiff
+	var compression layerCompression
+	if push {
+		compression = resolveLayerCompression(ctx, opts.Compression, ref, &targetPlatform.Platform)
+	}
+	buildConfig, err := createBuildConfig(patchedImageName, shouldExportOCI, push, load, destinationNames(pushDestinations), pipeW, cacheOpts, compression, opts.Annotations)
 	if err != nil {
 		return nil, err
 	}
+	buildConfig.SolveOpt.Session = append(buildConfig.SolveOpt.Session, utils.ProxySecrets(ctx))
+	buildConfig.InstalledOSPackages = installedOS
+	if exportDiff {
+		buildConfig.SolveOpt.Exports = append(buildConfig.SolveOpt.Exports, diffExportEntry(opts.ExportDiff))
+	}
 
 	// Create channels for build coordination.
 	// Buffer the channel to prevent backpressure from the progress display
@@ -185,15 +249,15 @@ func patchSingleArchImage(
 	// PlainMode due to rendering overhead; without a buffer, builds that
 	// generate heavy output (e.g. .NET patching) can stall indefinitely.
 	buildChannel := make(chan *client.SolveStatus, 128)
-	eg, ctx := errgroup.WithContext(ctx)
+	eg, egCtx := errgroup.WithContext(ctx)
 
 	// Resolve image reference for BuildKit operations
-	// For multi-platform images with local manifests, use platform-specific reference
+	// For multi-platform images, use the platform-specific digest from the local or remote index
 	buildkitImageRef := imageName
 	if multiPlatform {
-		platformImageRef, err := buildkit.GetPlatformImageReference(image, &targetPlatform.Platform)
+		platformImageRef, err := buildkit.GetPlatformImageReference(ctx, image, &targetPlatform.Platform)
 		if err == nil {
-			// Successfully resolved platform-specific reference for local manifest
+			// Successfully resolved platform-specific reference
 			log.Debugf("Using platform-specific image reference for BuildKit: %s", platformImageRef)
 			buildkitImageRefNamed, err := reference.ParseNormalizedNamed(platformImageRef)
 			if err == nil {
@@ -208,8 +272,8 @@ func patchSingleArchImage(
 	var patchResult *Result
 	eg.Go(func() error {
 		defer pipeW.Close()
-		result, err := executePatchBuild(ctx, bkClient, buildConfig, buildkitImageRef, &targetPlatform,
-			workingFolder, updates, ignoreError, reportFile, format, output, patchedImageName, buildChannel, opts.ExitOnEOL, toolchainPatchLevel)
+		result, err := executePatchBuild(egCtx, bkClient, buildConfig, buildkitImageRef, &targetPlatform,
+			workingFolder, updates, ignoreError, reportFile, format, output, patchedImageName, llbDumpPath, buildChannel, opts)
 		if err != nil {
 			return err
 		}
@@ -225,18 +289,18 @@ func patchSingleArchImage(
 		hostPlatform := platforms.Normalize(platforms.DefaultSpec())
 		platformPrefix := tui.FormatEmulationPrefix(hostPlatform.Architecture, targetPlatform.Architecture, targetPlatform.Variant)
 		eg.Go(func() error {
-			common.ForwardProgressWithPrefix(ctx, buildChannel, sharedProgressCh, platformPrefix)
+			common.ForwardProgressWithPrefix(egCtx, buildChannel, sharedProgressCh, platformPrefix)
 			return nil
 		})
 	} else {
 		// Display progress locally (single-arch mode)
-		common.DisplayProgress(ctx, eg, buildChannel, opts.Progress)
+		common.DisplayProgress(egCtx, eg, buildChannel, opts.Progress)
 	}
 
-	// Handle image loading if not pushing
-	if !push {
+	// Load the image into the local runtime unless it is only being pushed
+	if load {
 		eg.Go(func() error {
-			return loadImageToRuntime(ctx, pipeR, patchedImageName, finalLoaderType)
+			return loadImageToRuntime(egCtx, pipeR, patchedImageName, finalLoaderType)
 		})
 	} else {
 		go func() {
@@ -247,46 +311,75 @@ func patchSingleArchImage(
 	// Wait for completion
 	if err := eg.Wait(); err != nil {
 		if errors.Is(err, types.ErrNoUpdatesFound) {
-			res, _ := createOriginalImageResult(imageName, &targetPlatform, image)
-			return res, types.ErrNoUpdatesFound
+			return upToDateResult(ctx, imageName, &targetPlatform, image)
 		}
 		return nil, err
 	}
 
-	// Get patched descriptor and add annotations, including preserved states
-	return createPatchResultWithStates(imageName, patchedImageName, &targetPlatform, image, finalLoaderType, patchResult)
-}
+	logBaseChain(&targetPlatform, patchResult)
 
-// validatePlatformEmulation checks if emulation is available for cross-platform builds.
-func validatePlatformEmulation(targetPlatform types.PatchPlatform) error { //nolint:gocritic
-	hostPlatform := platforms.Normalize(platforms.DefaultSpec())
-	if hostPlatform.OS != LINUX {
-		hostPlatform.OS = LINUX
+	if exportDiff {
+		log.Infof("Exported patch diff for %s (%s) to %s", image, platforms.Format(targetPlatform.Platform), opts.ExportDiff)
+		return &types.PatchResult{
+			OriginalRef:     imageName,
+			Platform:        targetPlatform.Platform,
+			FixedCVEs:       patchResult.FixedCVEs,
+			PatchedPackages: len(patchResult.ValidatedUpdates),
+			PackageTool:     patchResult.PackageTool,
+			ErroredPackages: patchResult.ErroredPackages,
+			SkippedPackages: patchResult.SkippedPackages,
+			BaseChain:       patchResult.BaseChain,
+		}, nil
 	}
 
-	platformsEqual := hostPlatform.OS == targetPlatform.OS &&
-		hostPlatform.Architecture == targetPlatform.Architecture
-
-	if platformsEqual {
-		log.Debugf("Host platform %+v matches target platform %+v", hostPlatform, targetPlatform)
-		return nil
+	// Get patched descriptor and add annotations, including preserved states
+	result, err := createPatchResultWithStates(imageName, patchedImageName, &targetPlatform, image, finalLoaderType, patchResult)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Debugf("Host platform %+v does not match target platform %+v", hostPlatform, targetPlatform)
-
-	if emulationEnabled := buildkit.QemuAvailable(&targetPlatform); !emulationEnabled {
-		platform := targetPlatform.OS + "/" + targetPlatform.Architecture
-
-		log.Warnf("Emulation is not enabled for platform %s.\n"+
-			"To enable emulation, see docs: \n"+
-			"https://docs.docker.com/build/building/multi-platform/#qemu",
-			platform)
+	if opts.ConfirmFixed {
+		if err := confirmFixed(ctx, opts, reportOpts, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
 
-		return fmt.Errorf("emulation is not enabled for platform %s", platform)
+// logBaseChain reports the images the patch was built on. A chain longer than the
+// input image itself means the input was patched before and was rebased.
+func logBaseChain(targetPlatform *types.PatchPlatform, patchResult *Result) {
+	if patchResult == nil || len(patchResult.BaseChain) == 0 {
+		return
 	}
+	chain := buildkit.FormatBaseImageChain(patchResult.BaseChain)
+	if len(patchResult.BaseChain) == 1 {
+		log.Debugf("Base image chain (%s): %s", platforms.Format(targetPlatform.Platform), chain)
+		return
+	}
+	log.Infof("Base image chain (%s): %s", platforms.Format(targetPlatform.Platform), chain)
+}
 
-	log.Debugf("Emulation is enabled for platform %+v", targetPlatform)
-	return nil
+// shouldLoadImage reports whether the patched image is loaded into the local runtime.
+// Images are always loaded when not pushing. With --push, --load also loads them, but
+// only the host platform of a multi-platform image, since the others could not run here.
+func shouldLoadImage(opts *types.Options, targetPlatform *types.PatchPlatform, multiPlatform bool) bool {
+	if !opts.Push {
+		return true
+	}
+	if !opts.Load {
+		return false
+	}
+	if !multiPlatform {
+		return true
+	}
+	host := platforms.Normalize(platforms.DefaultSpec())
+	if platforms.OnlyStrict(host).Match(targetPlatform.Platform) {
+		return true
+	}
+	log.Warnf("--load only loads the host platform (%s) of multi-platform images; %s is pushed but not loaded",
+		platforms.Format(host), platforms.Format(targetPlatform.Platform))
+	return false
 }
 
 // setupWorkingFolder creates and configures the working directory.
@@ -446,12 +539,19 @@ func createPatchResultWithStates(imageName reference.Named, patchedImageName str
 		OriginalRef: imageName,
 		PatchedRef:  patchedRef,
 		PatchedDesc: patchedDesc,
+		Platform:    targetPlatform.Platform,
 	}
 
-	// Include preserved BuildKit states if available
+	// Include preserved BuildKit states and the patch outcome if available
 	if patchResult != nil {
 		result.PatchedState = patchResult.PatchedState
 		result.ConfigData = patchResult.ConfigData
+		result.FixedCVEs = patchResult.FixedCVEs
+		result.PatchedPackages = len(patchResult.ValidatedUpdates)
+		result.PackageTool = patchResult.PackageTool
+		result.ErroredPackages = patchResult.ErroredPackages
+		result.SkippedPackages = patchResult.SkippedPackages
+		result.BaseChain = patchResult.BaseChain
 	}
 
 	return result, nil
@@ -467,10 +567,9 @@ func executePatchBuild(
 	workingFolder string,
 	updates *unversioned.UpdateManifest,
 	ignoreError bool,
-	reportFile, format, output, patchedImageName string,
+	reportFile, format, output, patchedImageName, llbDumpPath string,
 	buildChannel chan *client.SolveStatus,
-	exitOnEOL bool,
-	toolchainPatchLevel string,
+	opts *types.Options,
 ) (*Result, error) {
 	var pkgType string
 	var validatedManifest *unversioned.UpdateManifest
@@ -490,6 +589,7 @@ func executePatchBuild(
 			},
 			OSUpdates:   []unversioned.UpdatePackage{},
 			LangUpdates: []unversioned.UpdatePackage{},
+			Unfixed:     updates.Unfixed,
 		}
 	}
 
@@ -501,15 +601,37 @@ func executePatchBuild(
 		}
 
 		patchOpts := &Options{
-			ImageName:           imageName.String(),
-			TargetPlatform:      targetPlatform,
-			Updates:             updates,
-			ValidatedUpdates:    validatedManifest,
-			WorkingFolder:       workingFolder,
-			IgnoreError:         ignoreError,
-			ReturnState:         false, // Always solve for Docker export
-			ExitOnEOL:           exitOnEOL,
-			ToolchainPatchLevel: toolchainPatchLevel,
+			ImageName:              imageName.String(),
+			TargetPlatform:         targetPlatform,
+			Updates:                updates,
+			ValidatedUpdates:       validatedManifest,
+			WorkingFolder:          workingFolder,
+			IgnoreError:            ignoreError,
+			ReturnState:            false, // Always solve for Docker export
+			ExitOnEOL:              opts.ExitOnEOL,
+			ToolchainPatchLevel:    opts.ToolchainPatchLevel,
+			NodeDirectOnly:         opts.NodeDirectOnly,
+			NodeIncludeDev:         opts.NodeIncludeDev,
+			MaxConcurrentDownloads: opts.MaxConcurrentDownloads,
+			SmokeTest:              opts.SmokeTest,
+			PrePatchScript:         opts.PrePatchScript,
+			PostPatchScript:        opts.PostPatchScript,
+			ForcePkgManager:        opts.ForcePkgManager,
+			RepoSnapshots:          opts.RepoSnapshots,
+			RepoMirrors:            opts.RepoMirrors,
+			APTSecurityOnly:        opts.APTSecurityOnly,
+			PkgMgrPaths:            opts.PkgMgrPaths,
+			PatchedUser:            opts.PatchedUser,
+			PatchedUserChown:       opts.PatchedUserChown,
+			Labels:                 opts.Labels,
+			ManifestTransform:      opts.ManifestTransform,
+			NoRebase:               opts.NoRebase,
+			Pull:                   buildkit.PullPolicy(opts.Pull),
+			ChangelogInImage:       opts.ChangelogInImage,
+			SinglePatchLayer:       opts.SinglePatchLayer,
+			ExportDiff:             opts.ExportDiff != "",
+			DumpLLB:                llbDumpPath,
+			InstalledOSPackages:    buildConfig.InstalledOSPackages,
 		}
 
 		// Execute the core patching logic
@@ -524,10 +646,10 @@ func executePatchBuild(
 		// Update validation data for VEX document generation
 		pkgType = result.PackageType
 
-		// Build validated manifest (exclude errored packages) using original updates + result.ErroredPackages
+		// Build validated manifest (exclude errored and skipped packages) using original updates
 		if validatedManifest != nil && updates != nil {
 			errored := map[string]struct{}{}
-			for _, e := range result.ErroredPackages {
+			for _, e := range append(slices.Clone(result.ErroredPackages), result.SkippedPackages...) {
 				errored[e] = struct{}{}
 			}
 			for _, u := range updates.OSUpdates {
@@ -551,13 +673,35 @@ func executePatchBuild(
 		digest := solveResponse.ExporterResponse[exptypes.ExporterImageDigestKey]
 		patchedImageDigest = digest
 	}
+	if patchedImageDigest != "" {
+		// Every push export is solved from the same result, so they share one digest
+		for _, name := range buildConfig.PushedNames {
+			log.Infof("Pushed %s@%s", name, patchedImageDigest)
+		}
+	}
 	if patchedImageDigest != "" && reportFile != "" && validatedManifest != nil {
 		nameDigestOrTag := common.GetRepoNameWithDigest(patchedImageName, patchedImageDigest)
+		vexOutput := vexOutputPath(output, opts.AttachAttestations, workingFolder, targetPlatform.Platform)
 		// vex document must contain at least one statement
-		if output != "" && (len(validatedManifest.OSUpdates) > 0 || len(validatedManifest.LangUpdates) > 0) {
-			if err := vex.TryOutputVexDocument(validatedManifest, pkgType, nameDigestOrTag, format, output); err != nil {
+		if vexOutput != "" && (len(validatedManifest.OSUpdates) > 0 || len(validatedManifest.LangUpdates) > 0 || len(validatedManifest.Unfixed) > 0) {
+			if err := vex.TryOutputVexDocument(validatedManifest, pkgType, nameDigestOrTag, format, vexOutput); err != nil {
 				return nil, err
 			}
+			if opts.AttachAttestations && len(buildConfig.PushedNames) > 0 {
+				docs := []attestation{{path: vexOutput, artifactType: vexArtifactType}}
+				if err := attachAttestations(ctx, buildConfig.PushedNames, patchedImageDigest, docs); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err == nil && opts.ChangelogOutput != "" {
+		changelogImage := patchedImageName
+		if patchedImageDigest != "" {
+			changelogImage = common.GetRepoNameWithDigest(patchedImageName, patchedImageDigest)
+		}
+		if err := writeChangelog(opts.ChangelogOutput, changelogImage, validatedManifest); err != nil {
+			return nil, err
 		}
 	}
 
@@ -608,8 +752,8 @@ func parsePkgTypes(pkgTypesStr string) ([]string, error) {
 	return validTypes, nil
 }
 
-func createOriginalImageResult(imageName reference.Named, targetPlatform *types.PatchPlatform, originalImageRef string) (*types.PatchResult, error) {
-	originalDesc, err := getPlatformDescriptorFromManifest(originalImageRef, targetPlatform)
+func createOriginalImageResult(ctx context.Context, imageName reference.Named, targetPlatform *types.PatchPlatform, originalImageRef string) (*types.PatchResult, error) {
+	originalDesc, err := getPlatformDescriptorFromManifest(ctx, originalImageRef, targetPlatform)
 	if err != nil {
 		log.Warnf("Could not get original descriptor for up-to-date platform %s/%s: %v", targetPlatform.OS, targetPlatform.Architecture, err)
 	}
@@ -618,5 +762,48 @@ func createOriginalImageResult(imageName reference.Named, targetPlatform *types.
 		OriginalRef: imageName,
 		PatchedRef:  imageName,
 		PatchedDesc: originalDesc,
+		Platform:    targetPlatform.Platform,
 	}, nil
 }
+
+// upToDateResult returns the original image as the result for a platform that had
+// nothing to update, along with ErrNoUpdatesFound.
+func upToDateResult(ctx context.Context, imageName reference.Named, targetPlatform *types.PatchPlatform, originalImageRef string) (*types.PatchResult, error) {
+	res, _ := createOriginalImageResult(ctx, imageName, targetPlatform, originalImageRef)
+	if res == nil {
+		res = &types.PatchResult{OriginalRef: imageName, PatchedRef: imageName, Platform: targetPlatform.Platform}
+	}
+	res.NoUpdatesApplied = true
+	return res, types.ErrNoUpdatesFound
+}
+
+// updatesAllOSPackages reports whether --update-all applies to an image patched from
+// the report updates were parsed from: every OS package is then upgraded, not only
+// the vulnerable ones.
+func updatesAllOSPackages(opts *types.Options, updates *unversioned.UpdateManifest) bool {
+	if !opts.UpdateAll || updates == nil {
+		return false
+	}
+	pkgTypesList, err := parsePkgTypes(opts.PkgTypes)
+	return err == nil && shouldIncludeOSUpdates(pkgTypesList)
+}
+
+// forceNodeOnlyUpdates drops every update but the Node.js packages from updates, as
+// only those are patched with --force-pkg-manager=npm.
+func forceNodeOnlyUpdates(updates *unversioned.UpdateManifest) {
+	var skipped int
+	nodeUpdates := updates.LangUpdates[:0]
+	for _, u := range updates.LangUpdates {
+		if u.Type == utils.NodePackages {
+			nodeUpdates = append(nodeUpdates, u)
+		} else {
+			skipped++
+		}
+	}
+	skipped += len(updates.OSUpdates)
+	if skipped > 0 {
+		log.Warnf("--force-pkg-manager=npm: skipping %d updates that are not Node.js packages", skipped)
+	}
+	updates.OSUpdates = []unversioned.UpdatePackage{}
+	updates.LangUpdates = nodeUpdates
+}
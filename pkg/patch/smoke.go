@@ -0,0 +1,55 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+const smokeTestDir = "/copa-smoke-test"
+
+// canRunPlatform reports whether commands for the target platform can execute on this
+// host, either natively or through QEMU emulation.
+func canRunPlatform(targetPlatform *types.PatchPlatform) bool {
+	return !needsEmulation(targetPlatform) || qemuAvailable(targetPlatform)
+}
+
+// runSmokeTest runs cmd with sh inside the patched state and returns an error carrying
+// the command's output if it exits non-zero. The exit status and output are written to
+// a scratch mount so the solve itself succeeds and the output can be surfaced.
+func runSmokeTest(ctx context.Context, c gwclient.Client, st *llb.State, cmd string) error {
+	script := fmt.Sprintf("(%s) > %s/output 2>&1; echo $? > %s/status", cmd, smokeTestDir, smokeTestDir)
+	run := st.Run(
+		llb.Args([]string{"sh", "-c", script}),
+		llb.WithCustomNamef("Running smoke test: %s", cmd),
+		llb.IgnoreCache,
+	)
+	results := run.AddMount(smokeTestDir, llb.Scratch())
+
+	status, err := buildkit.ExtractFileFromState(ctx, c, &results, "/status")
+	if err != nil {
+		return fmt.Errorf("failed to run smoke test %q: %w", cmd, err)
+	}
+	exitCode, err := strconv.Atoi(strings.TrimSpace(string(status)))
+	if err != nil {
+		return fmt.Errorf("failed to read smoke test exit status %q: %w", status, err)
+	}
+	if exitCode == 0 {
+		log.Infof("Smoke test %q passed", cmd)
+		return nil
+	}
+
+	output, err := buildkit.ExtractFileFromState(ctx, c, &results, "/output")
+	if err != nil {
+		log.Debugf("Unable to read smoke test output: %v", err)
+	}
+	return fmt.Errorf("smoke test %q failed with exit code %d:\n%s", cmd, exitCode, strings.TrimSpace(string(output)))
+}
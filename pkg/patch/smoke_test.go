@@ -0,0 +1,44 @@
+package patch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/mocks"
+)
+
+func newSmokeTestClient(status, output string) *mocks.MockGWClient {
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+
+	res := &gwclient.Result{}
+	res.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(res, nil)
+
+	mockRef.On("ReadFile", mock.Anything, gwclient.ReadRequest{Filename: "/status"}).Return([]byte(status), nil)
+	mockRef.On("ReadFile", mock.Anything, gwclient.ReadRequest{Filename: "/output"}).Return([]byte(output), nil)
+	return mockClient
+}
+
+func TestRunSmokeTest(t *testing.T) {
+	st := llb.Image("docker.io/library/node:20-alpine")
+
+	t.Run("passing command succeeds", func(t *testing.T) {
+		c := newSmokeTestClient("0\n", "")
+		require.NoError(t, runSmokeTest(context.Background(), c, &st, `node -e "require('express')"`))
+	})
+
+	t.Run("failing command fails with its output", func(t *testing.T) {
+		c := newSmokeTestClient("1\n", "Error: Cannot find module 'express'\n")
+		err := runSmokeTest(context.Background(), c, &st, `node -e "require('express')"`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exit code 1")
+		assert.Contains(t, err.Error(), "Cannot find module 'express'")
+	})
+}
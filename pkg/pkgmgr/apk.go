@@ -18,6 +18,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// apkInstalledDB is the database of packages installed with apk.
+const apkInstalledDB = "/lib/apk/db/installed"
+
 type apkManager struct {
 	config        *buildkit.Config
 	workingFolder string
@@ -68,7 +71,7 @@ func validateAPKPackageVersions(updates unversioned.UpdatePackages, cmp VersionC
 	sort.SliceStable(updates, func(i, j int) bool {
 		return updates[i].Name < updates[j].Name
 	})
-	log.Debugf("Required updates: %s", updates)
+	log.Debugf("Required updates: %v", updates)
 
 	sort.SliceStable(lines, func(i, j int) bool {
 		return lines[i] < lines[j]
@@ -118,6 +121,22 @@ func validateAPKPackageVersions(updates unversioned.UpdatePackages, cmp VersionC
 	return errorPkgs, allErrors.ErrorOrNil()
 }
 
+func (am *apkManager) Preflight(ctx context.Context) error {
+	st := preflightState(am.config)
+	apk := am.config.PkgMgrBinary(binaryAPK)
+	existing, err := buildkit.ExistingFilesInState(ctx, am.config.Client, &st, append(toolPaths(apk), apkInstalledDB))
+	if err != nil {
+		return err
+	}
+	if !existing[apkInstalledDB] {
+		return noPackageDatabaseError(apkInstalledDB)
+	}
+	if !hasTool(existing, apk) {
+		return missingToolError(binaryAPK, apk)
+	}
+	return nil
+}
+
 func (am *apkManager) InstallUpdates(ctx context.Context, manifest *unversioned.UpdateManifest, ignoreErrors bool) (*llb.State, []string, error) {
 	// If manifest is nil, update all packages
 	if manifest == nil {
@@ -169,17 +188,32 @@ func (am *apkManager) upgradePackages(ctx context.Context, updates unversioned.U
 	if am.config.PatchedConfigData != nil {
 		imageStateCurrent = am.config.PatchedImageState
 	}
+	if repos := am.config.RepoSnapshots[repoTypeAPK]; repos != "" {
+		imageStateCurrent = withAPKSnapshot(imageStateCurrent, repos)
+	}
+	if mirror := am.config.RepoMirrors[repoTypeAPK]; mirror != "" {
+		imageStateCurrent = withAPKMirror(imageStateCurrent, mirror)
+	}
+
+	// Images built without apk (e.g. by Bazel or ko) may still identify as Alpine
+	if _, err := buildkit.TryExtractFileFromState(ctx, am.config.Client, &imageStateCurrent, apkInstalledDB); err != nil {
+		if isMarkerMissingErr(err, apkInstalledDB) {
+			return nil, nil, noPackageDatabaseError(apkInstalledDB)
+		}
+		return nil, nil, fmt.Errorf("failed to read the apk database: %w", err)
+	}
 
+	apk := am.config.PkgMgrBinary(binaryAPK)
 	apkUpdated := imageStateCurrent.Run(
-		llb.Shlex("apk update"),
-		llb.WithProxy(utils.GetProxy()),
+		llb.Shlex(apk+" update"),
+		utils.WithProxy(),
 		llb.IgnoreCache,
 		llb.WithCustomName("Updating package database")).Root()
 
 	// If updating all packages, check for upgrades before proceeding with patch
 	if updates == nil {
 		const updatesAvailableMarker = "/updates.txt"
-		checkUpgradable := fmt.Sprintf(`sh -c 'if apk list 2>/dev/null | grep -q "upgradable"; then touch %s; fi'`, updatesAvailableMarker)
+		checkUpgradable := fmt.Sprintf(`sh -c 'if %s list 2>/dev/null | grep -q "upgradable"; then touch %s; fi'`, apk, updatesAvailableMarker)
 		stateWithCheck := apkUpdated.Run(
 			llb.Shlex(checkUpgradable),
 			llb.WithCustomName("Checking for available updates"),
@@ -201,45 +235,42 @@ func (am *apkManager) upgradePackages(ctx context.Context, updates unversioned.U
 	if updates != nil {
 		// Add all requested update packages
 		// This works around cases where some packages (for example, tiff) require other packages in it's dependency tree to be updated
-		const apkAddTemplate = `apk add --no-cache %s`
-		pkgStrings := []string{}
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
-		}
-		addCmd := fmt.Sprintf(apkAddTemplate, strings.Join(pkgStrings, " "))
+		const apkAddTemplate = `%s add --no-cache %s`
+		pkgStrings := installPackageNames(updates)
+		addCmd := fmt.Sprintf(apkAddTemplate, apk, strings.Join(pkgStrings, " "))
 		apkAdded := apkUpdated.Run(
-			llb.Shlex(addCmd),
-			llb.WithProxy(utils.GetProxy()),
+			guardedInstall(addCmd, apkNotFoundPattern),
+			utils.WithProxy(),
 			llb.WithCustomName(fmt.Sprintf("Installing %d security updates", len(pkgStrings)))).Root()
 
 		// Install all requested update packages without specifying the version. This works around:
 		//  - Reports being slightly out of date, where a newer security revision has displaced the one specified leading to not found errors.
 		//  - Reports not specifying version epochs correct (e.g. bsdutils=2.36.1-8+deb11u1 instead of with epoch as 1:2.36.1-8+dev11u1)
 		// Note that this keeps the log files from the operation, which we can consider removing as a size optimization in the future.
-		const apkInstallTemplate = `apk upgrade --no-cache %s`
-		installCmd := fmt.Sprintf(apkInstallTemplate, strings.Join(pkgStrings, " "))
+		const apkInstallTemplate = `%s upgrade --no-cache %s`
+		installCmd := fmt.Sprintf(apkInstallTemplate, apk, strings.Join(pkgStrings, " "))
 		apkInstalled = apkAdded.Run(
-			llb.Shlex(installCmd),
-			llb.WithProxy(utils.GetProxy()),
+			guardedInstall(installCmd, apkNotFoundPattern),
+			utils.WithProxy(),
 			llb.WithCustomName(fmt.Sprintf("Upgrading %d security updates", len(pkgStrings)))).Root()
 
 		// Write updates-manifest to host for post-patch validation
-		const outputResultsTemplate = `sh -c 'apk info --installed -v %s > %s; if [[ $? -ne 0 ]]; then echo "WARN: apk info --installed returned $?"; fi'`
+		const outputResultsTemplate = `sh -c '%s info --installed -v %s > %s; if [[ $? -ne 0 ]]; then echo "WARN: apk info --installed returned $?"; fi'`
 		pkgs := strings.Trim(fmt.Sprintf("%s", pkgStrings), "[]")
-		outputResultsCmd := fmt.Sprintf(outputResultsTemplate, pkgs, resultManifest)
+		outputResultsCmd := fmt.Sprintf(outputResultsTemplate, apk, pkgs, resultManifest)
 		mkFolders := apkInstalled.File(llb.Mkdir(resultsPath, 0o744, llb.WithParents(true)))
 		resultsDiff := mkFolders.Dir(resultsPath).Run(llb.Shlex(outputResultsCmd)).AddMount(resultsPath, llb.Scratch())
 
 		resultManifestBytes, err = buildkit.ExtractFileFromState(ctx, am.config.Client, &resultsDiff, resultManifest)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, classifyInstallError(err)
 		}
 	} else {
 		// if updates is not specified, update all packages
-		installCmd := `output=$(apk upgrade --no-cache 2>&1); if [ $? -ne 0 ]; then echo "$output" >>error_log.txt; fi`
+		installCmd := fmt.Sprintf(`output=$(%s upgrade --no-cache 2>&1); if [ $? -ne 0 ]; then echo "$output" >>error_log.txt; fi`, apk)
 		apkInstalled = apkUpdated.Run(
 			buildkit.Sh(installCmd),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 			llb.WithCustomName("Upgrading all packages")).Root()
 
 		// Validate no errors were encountered if updating all
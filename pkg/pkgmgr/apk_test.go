@@ -15,8 +15,10 @@ import (
 	gwclient "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 	"github.com/project-copacetic/copacetic/pkg/utils"
@@ -233,6 +235,7 @@ func Test_InstallUpdates_APK(t *testing.T) {
 		expectedState         bool
 		expectedPkgs          []string
 		expectNoUpdates       bool
+		noAPKDatabase         bool
 		expectedErrorContains string
 	}{
 		{
@@ -283,6 +286,17 @@ func Test_InstallUpdates_APK(t *testing.T) {
 			expectedPkgs:          nil,
 			expectedErrorContains: "failed while checking for available apk updates: repository not found",
 		},
+		{
+			name: "No apk database",
+			manifest: &unversioned.UpdateManifest{
+				OSUpdates: unversioned.UpdatePackages{
+					{Name: "package1", FixedVersion: "2.0.0"},
+				},
+			},
+			noAPKDatabase:         true,
+			expectedState:         false,
+			expectedErrorContains: types.ErrNoPackageDatabase.Error(),
+		},
 		{
 			name: "Ignore errors",
 			manifest: &unversioned.UpdateManifest{
@@ -309,6 +323,15 @@ func Test_InstallUpdates_APK(t *testing.T) {
 
 			mockGWClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
 
+			dbRead := mockRef.On("ReadFile", mock.Anything, mock.MatchedBy(func(req gwclient.ReadRequest) bool {
+				return req.Filename == apkInstalledDB
+			}))
+			if tt.noAPKDatabase {
+				dbRead.Return([]byte(nil), fmt.Errorf("failed to stat %s: no such file or directory", apkInstalledDB))
+			} else {
+				dbRead.Return([]byte("P:musl\nV:1.2.4-r2\n"), nil)
+			}
+
 			if tt.mockSetup != nil {
 				tt.mockSetup(mockRef)
 			}
@@ -346,3 +369,29 @@ func Test_InstallUpdates_APK(t *testing.T) {
 		})
 	}
 }
+
+// TestWolfiReportUsesAPK parses a Wolfi Trivy report, which has a build date instead
+// of a release version, and checks that it is patched with apk to the latest fixed
+// -r<N> revision of each package.
+func TestWolfiReportUsesAPK(t *testing.T) {
+	manifest, err := report.NewTrivyParser().Parse("testdata/trivy_wolfi.json")
+	require.NoError(t, err)
+	require.Equal(t, utils.OSTypeWolfi, utils.CanonicalOSType(manifest.Metadata.OS.Type))
+
+	pm, err := GetPackageManager(manifest.Metadata.OS.Type, manifest.Metadata.OS.Version, &buildkit.Config{}, "")
+	require.NoError(t, err)
+	assert.IsType(t, &apkManager{}, pm)
+
+	apkComparer := VersionComparer{isValidAPKVersion, isLessThanAPKVersion}
+	updates, err := GetUniqueLatestUpdates(manifest.OSUpdates, apkComparer, false)
+	require.NoError(t, err)
+	fixed := make(map[string]string, len(updates))
+	for _, u := range updates {
+		fixed[u.Name] = u.FixedVersion
+	}
+	assert.Equal(t, map[string]string{"libcrypto3": "3.3.2-r0", "glibc": "2.39-r5"}, fixed)
+
+	errPkgs, err := validateAPKPackageVersions(updates, apkComparer, []byte("libcrypto3-3.3.2-r0\nglibc-2.39-r4\n"), true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"glibc"}, errPkgs, "an older -r revision does not satisfy the fix")
+}
@@ -0,0 +1,50 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Package manager commands that accept a --pkgmgr-path override.
+const (
+	binaryAPK    = "apk"
+	binaryAptGet = "apt-get"
+	binaryNpm    = "npm"
+)
+
+// validBinaryPathPattern restricts binary paths to absolute paths that are safe to
+// interpolate into the shell commands run inside the image.
+var validBinaryPathPattern = regexp.MustCompile(`^/[A-Za-z0-9._+/-]+$`)
+
+// ParsePkgMgrPaths parses --pkgmgr-path values of the form <command>=<path>, e.g.
+// "apt-get=/opt/debian/bin/apt-get" or "npm=/usr/local/node/bin/npm".
+func ParsePkgMgrPaths(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	paths := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, path, ok := strings.Cut(spec, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid --pkgmgr-path %q: expected <command>=<path>", spec)
+		}
+		if name != binaryAPK && name != binaryAptGet && name != binaryNpm {
+			return nil, fmt.Errorf("invalid --pkgmgr-path %q: unsupported command %q, supported: %s", spec, name, strings.Join(PkgMgrPathCommands(), ", "))
+		}
+		if _, dup := paths[name]; dup {
+			return nil, fmt.Errorf("invalid --pkgmgr-path %q: %s path specified more than once", spec, name)
+		}
+		if !validBinaryPathPattern.MatchString(path) {
+			return nil, fmt.Errorf("invalid --pkgmgr-path %q: %q is not an absolute path", spec, path)
+		}
+		paths[name] = path
+	}
+	return paths, nil
+}
+
+// PkgMgrPathCommands returns the sorted package manager commands that accept a path override.
+func PkgMgrPathCommands() []string {
+	return []string{binaryAPK, binaryAptGet, binaryNpm}
+}
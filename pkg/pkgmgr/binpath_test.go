@@ -0,0 +1,73 @@
+package pkgmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/mocks"
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+func TestParsePkgMgrPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "none"},
+		{
+			name:  "apt-get and npm",
+			specs: []string{"apt-get=/opt/debian/bin/apt-get", "npm=/usr/local/node/bin/npm"},
+			want:  map[string]string{"apt-get": "/opt/debian/bin/apt-get", "npm": "/usr/local/node/bin/npm"},
+		},
+		{name: "missing path", specs: []string{"apk="}, wantErr: "expected <command>=<path>"},
+		{name: "unsupported command", specs: []string{"yum=/usr/bin/yum"}, wantErr: `unsupported command "yum"`},
+		{name: "relative path", specs: []string{"apk=sbin/apk"}, wantErr: "not an absolute path"},
+		{name: "shell metacharacters", specs: []string{"apk=/sbin/apk;reboot"}, wantErr: "not an absolute path"},
+		{name: "duplicate command", specs: []string{"npm=/a/npm", "npm=/b/npm"}, wantErr: "more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePkgMgrPaths(tt.specs)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPkgMgrPathInLLB(t *testing.T) {
+	const aptGet = "/opt/debian/bin/apt-get"
+
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("openssl-3.0.13\n"), nil)
+
+	dm := &dpkgManager{
+		config: &buildkit.Config{
+			Client:      mockClient,
+			ImageState:  llb.Image("debian:12"),
+			PkgMgrPaths: map[string]string{binaryAptGet: aptGet},
+		},
+	}
+	st, _, err := dm.installUpdates(context.TODO(), unversioned.UpdatePackages{{Name: "openssl", FixedVersion: "3.0.13"}}, false)
+	require.NoError(t, err)
+
+	assert.True(t, definitionContains(t, *st, aptGet+" "))
+	assert.True(t, definitionContains(t, *st, "install --no-install-recommends -y openssl && "+aptGet+" clean -y"))
+}
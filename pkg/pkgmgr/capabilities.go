@@ -0,0 +1,110 @@
+package pkgmgr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+)
+
+const fileCapsProbeFile = "/copa-file-caps"
+
+// fileCapsDirs are searched for binaries with file capabilities, such as nginx with
+// cap_net_bind_service so it can bind port 80 without running as root.
+var fileCapsDirs = []string{"/bin", "/sbin", "/usr", "/opt"}
+
+// probeFileCapabilities returns the file capabilities set in st, keyed by path. Images
+// without a shell or getcap report none.
+func probeFileCapabilities(ctx context.Context, c gwclient.Client, st *llb.State) (map[string]string, error) {
+	script := fmt.Sprintf(`: > %[1]s; if command -v getcap >/dev/null 2>&1; then getcap -r %[2]s > %[1]s 2>/dev/null || true; fi`,
+		fileCapsProbeFile, strings.Join(fileCapsDirs, " "))
+	probed := st.Run(buildkit.Sh(script), llb.WithCustomName("Probing file capabilities")).Root()
+	out, err := buildkit.ExtractFileFromState(ctx, c, &probed, fileCapsProbeFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseGetcapOutput(string(out)), nil
+}
+
+// parseGetcapOutput parses getcap -r output in both the current format
+// ("/usr/sbin/nginx cap_net_bind_service=ep") and the one of libcap before 2.41
+// ("/usr/sbin/nginx = cap_net_bind_service+ep").
+func parseGetcapOutput(out string) map[string]string {
+	caps := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		path, capText, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		capText = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(capText), "="))
+		if capText == "" {
+			continue
+		}
+		caps[path] = capText
+	}
+	return caps
+}
+
+// restoreFileCapabilitiesScript re-adds each recorded capability whose file lost it in
+// the update, since package managers replace binaries without their extended
+// attributes. Files that kept their capabilities are left alone so the layer does not
+// grow, and files that cannot be restored only produce a warning.
+func restoreFileCapabilitiesScript(caps map[string]string) string {
+	paths := make([]string, 0, len(caps))
+	for path := range caps {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	b.WriteString(`if ! command -v setcap >/dev/null 2>&1; then echo "setcap not found, file capabilities not restored" >&2; exit 0; fi; `)
+	for _, path := range paths {
+		fmt.Fprintf(&b, `if [ -e %[1]s ] && [ -z "$(getcap %[1]s 2>/dev/null)" ]; then `+
+			`if setcap %[2]s %[1]s; then echo %[3]s; else echo %[4]s >&2; fi; fi; `,
+			shellQuote(path), shellQuote(caps[path]),
+			shellQuote(fmt.Sprintf("restored %s on %s", caps[path], path)),
+			shellQuote(fmt.Sprintf("could not restore %s on %s", caps[path], path)))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// PreserveFileCapabilities records the file capabilities of binaries in original and
+// adds a step to updated that restores those the package updates dropped. If the
+// capabilities cannot be probed, updated is returned unchanged.
+func PreserveFileCapabilities(ctx context.Context, c gwclient.Client, original, updated *llb.State) *llb.State {
+	if updated == nil {
+		return nil
+	}
+	caps, err := probeFileCapabilities(ctx, c, original)
+	if err != nil {
+		log.Debugf("Skipping file capability preservation: %v", err)
+		return updated
+	}
+	if len(caps) == 0 {
+		return updated
+	}
+
+	paths := make([]string, 0, len(caps))
+	for path, capText := range caps {
+		paths = append(paths, path+" ("+capText+")")
+	}
+	sort.Strings(paths)
+	log.Infof("Preserving file capabilities of %s", strings.Join(paths, ", "))
+
+	restored := updated.Run(
+		buildkit.Sh(restoreFileCapabilitiesScript(caps)),
+		llb.WithCustomName("Restoring file capabilities"),
+	).Root()
+	return &restored
+}
@@ -0,0 +1,124 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/mocks"
+)
+
+func TestParseGetcapOutput(t *testing.T) {
+	out := "/usr/sbin/nginx cap_net_bind_service=ep\n" +
+		"/usr/bin/ping = cap_net_raw+ep\n" +
+		"/usr/bin/mtr-packet cap_net_admin,cap_net_raw=ep\n" +
+		"\n" +
+		"/usr/bin/nothing\n"
+	assert.Equal(t, map[string]string{
+		"/usr/sbin/nginx":     "cap_net_bind_service=ep",
+		"/usr/bin/ping":       "cap_net_raw+ep",
+		"/usr/bin/mtr-packet": "cap_net_admin,cap_net_raw=ep",
+	}, parseGetcapOutput(out))
+}
+
+// execArgs returns the arguments of every exec op in st.
+func execArgs(t *testing.T, st *llb.State) [][]string {
+	t.Helper()
+	def, err := st.Marshal(context.Background())
+	require.NoError(t, err)
+	var args [][]string
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		if exec := op.GetExec(); exec != nil {
+			args = append(args, exec.GetMeta().GetArgs())
+		}
+	}
+	return args
+}
+
+func TestPreserveFileCapabilities(t *testing.T) {
+	original := llb.Image("docker.io/library/nginx:1.25")
+	updated := original.Run(llb.Shlex("apt-get install -y nginx")).Root()
+
+	newClient := func(getcap []byte, readErr error) *mocks.MockGWClient {
+		mockClient := new(mocks.MockGWClient)
+		mockRef := new(mocks.MockReference)
+		mockResult := &gwclient.Result{}
+		mockResult.SetRef(mockRef)
+		mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+		mockRef.On("ReadFile", mock.Anything, mock.MatchedBy(func(req gwclient.ReadRequest) bool {
+			return req.Filename == fileCapsProbeFile
+		})).Return(getcap, readErr)
+		return mockClient
+	}
+
+	t.Run("capabilities found", func(t *testing.T) {
+		c := newClient([]byte("/usr/sbin/nginx cap_net_bind_service=ep\n"), nil)
+		got := PreserveFileCapabilities(context.Background(), c, &original, &updated)
+		require.NotSame(t, &updated, got)
+
+		args := execArgs(t, got)
+		require.Len(t, args, 2)
+		assert.Equal(t, []string{"apt-get", "install", "-y", "nginx"}, args[0], "the restore step should follow the update")
+		script := args[1][len(args[1])-1]
+		assert.Contains(t, script, "setcap 'cap_net_bind_service=ep' '/usr/sbin/nginx'")
+	})
+
+	t.Run("no capabilities", func(t *testing.T) {
+		c := newClient([]byte(""), nil)
+		got := PreserveFileCapabilities(context.Background(), c, &original, &updated)
+		assert.Same(t, &updated, got)
+	})
+
+	t.Run("probe failure", func(t *testing.T) {
+		c := newClient(nil, errors.New("no shell"))
+		got := PreserveFileCapabilities(context.Background(), c, &original, &updated)
+		assert.Same(t, &updated, got)
+	})
+}
+
+func TestRestoreFileCapabilitiesScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// Fake getcap and setcap record what the script asks for.
+	bin := t.TempDir()
+	logFile := filepath.Join(t.TempDir(), "setcap.log")
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept")
+	lost := filepath.Join(dir, "it's lost")
+	for _, f := range []string{kept, lost} {
+		require.NoError(t, os.WriteFile(f, nil, 0o600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "getcap"),
+		[]byte("#!/bin/sh\n[ \"$1\" = '"+kept+"' ] && echo \"$1 cap_net_raw=ep\"\nexit 0\n"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "setcap"),
+		[]byte("#!/bin/sh\necho \"$1 $2\" >> "+logFile+"\n"), 0o700))
+
+	script := restoreFileCapabilitiesScript(map[string]string{
+		kept:                       "cap_net_raw=ep",
+		lost:                       "cap_net_bind_service=ep",
+		filepath.Join(dir, "gone"): "cap_sys_time=ep",
+	})
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	calls, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "cap_net_bind_service=ep "+lost, strings.TrimSpace(string(calls)))
+}
@@ -0,0 +1,301 @@
+package pkgmgr
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/mocks"
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/langmgr"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// conformanceCase describes how to drive one PackageManager implementation
+// through the shared contract checked by runPackageManagerConformance.
+type conformanceCase struct {
+	osType    string
+	osVersion string
+	// files maps paths read back from BuildKit to their contents; any other
+	// read returns results, which doubles as the post-install package manifest.
+	files   map[string]string
+	results string
+	// update is satisfied by results, unmet is newer than what results report.
+	update unversioned.UpdatePackage
+	unmet  unversioned.UpdatePackage
+	// upgradeAll is part of the command that upgrades every package when no
+	// manifest is given.
+	upgradeAll string
+}
+
+// newConformanceConfig returns a buildkit config backed by a mock gateway whose
+// solves always succeed and whose file reads are answered from tc.
+func newConformanceConfig(tc *conformanceCase) *buildkit.Config {
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+
+	for name, content := range tc.files {
+		mockRef.On("ReadFile", mock.Anything, mock.MatchedBy(func(req gwclient.ReadRequest) bool {
+			return req.Filename == name
+		})).Return([]byte(content), nil)
+	}
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte(tc.results), nil)
+
+	return &buildkit.Config{
+		Client:     mockClient,
+		ImageState: llb.Scratch().Platform(ocispecs.Platform{OS: "linux", Architecture: "amd64"}),
+	}
+}
+
+// execCommands returns the commands of the exec ops in the LLB of state.
+func execCommands(t *testing.T, state *llb.State) []string {
+	t.Helper()
+	def, err := state.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	var cmds []string
+	for _, dt := range def.Def {
+		var op pb.Op
+		require.NoError(t, op.UnmarshalVT(dt))
+		if exec := op.GetExec(); exec != nil {
+			cmds = append(cmds, strings.Join(exec.GetMeta().GetArgs(), " "))
+		}
+	}
+	return cmds
+}
+
+// runPackageManagerConformance checks the behavior every PackageManager must share:
+//   - a nil manifest upgrades every installed package without naming any
+//   - an empty update list returns the original image state untouched
+//   - a basic update produces a new state
+//   - GetPackageType is non-empty
+//   - ignoreErrors=true never surfaces an error for unmet updates
+func runPackageManagerConformance(t *testing.T, tc *conformanceCase) {
+	t.Helper()
+
+	newManager := func(t *testing.T) (PackageManager, *buildkit.Config) {
+		config := newConformanceConfig(tc)
+		pm, err := GetPackageManager(tc.osType, tc.osVersion, config, utils.DefaultTempWorkingFolder)
+		require.NoError(t, err)
+		return pm, config
+	}
+	// Reports always carry the scanned OS, which some managers use to pick tooling images.
+	newManifest := func(updates ...unversioned.UpdatePackage) *unversioned.UpdateManifest {
+		return &unversioned.UpdateManifest{
+			Metadata:  unversioned.Metadata{OS: unversioned.OS{Type: tc.osType, Version: tc.osVersion}},
+			OSUpdates: updates,
+		}
+	}
+
+	t.Run("package type", func(t *testing.T) {
+		pm, _ := newManager(t)
+		assert.NotEmpty(t, pm.GetPackageType())
+	})
+
+	t.Run("nil manifest", func(t *testing.T) {
+		pm, config := newManager(t)
+		state, errPkgs, err := pm.InstallUpdates(context.TODO(), nil, false)
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		assert.NotSame(t, &config.ImageState, state)
+		assert.Empty(t, errPkgs)
+
+		// Every installed package is upgraded, none is named.
+		cmds := execCommands(t, state)
+		assert.True(t, slices.ContainsFunc(cmds, func(c string) bool { return strings.Contains(c, tc.upgradeAll) }),
+			"no command upgrades all packages with %q: %v", tc.upgradeAll, cmds)
+		for _, c := range cmds {
+			assert.NotContains(t, c, tc.update.Name)
+		}
+	})
+
+	t.Run("empty updates", func(t *testing.T) {
+		pm, config := newManager(t)
+		state, errPkgs, err := pm.InstallUpdates(context.TODO(), newManifest(), false)
+		require.NoError(t, err)
+		assert.Same(t, &config.ImageState, state)
+		assert.Empty(t, errPkgs)
+	})
+
+	t.Run("basic update", func(t *testing.T) {
+		pm, config := newManager(t)
+		manifest := newManifest(tc.update)
+		state, errPkgs, err := pm.InstallUpdates(context.TODO(), manifest, false)
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		assert.NotSame(t, &config.ImageState, state)
+		assert.Empty(t, errPkgs)
+	})
+
+	t.Run("ignore errors", func(t *testing.T) {
+		pm, _ := newManager(t)
+		manifest := newManifest(tc.unmet)
+		state, errPkgs, err := pm.InstallUpdates(context.TODO(), manifest, true)
+		require.NoError(t, err)
+		assert.NotNil(t, state)
+		assert.Equal(t, []string{tc.unmet.Name}, errPkgs)
+	})
+}
+
+// TestPackageManagerConformance runs every package manager returned by
+// GetPackageManager through the shared contract. The language managers of
+// pkg/langmgr are covered by TestLangManagerConformance.
+func TestPackageManagerConformance(t *testing.T) {
+	tests := map[string]*conformanceCase{
+		"apk": {
+			osType:     utils.OSTypeAlpine,
+			osVersion:  "3.19",
+			results:    "package1-1.0.1\n",
+			update:     unversioned.UpdatePackage{Name: "package1", FixedVersion: "1.0.1"},
+			unmet:      unversioned.UpdatePackage{Name: "package1", FixedVersion: "2.0.0"},
+			upgradeAll: "apk upgrade --no-cache",
+		},
+		"dpkg": {
+			osType:    utils.OSTypeDebian,
+			osVersion: "12",
+			files: map[string]string{
+				statusdOutputFilename: "1", // DPKGStatusFile
+				"/held.txt":           "",
+			},
+			results:    "Package: package1\nVersion: 1.0.1\n",
+			update:     unversioned.UpdatePackage{Name: "package1", FixedVersion: "1.0.1"},
+			unmet:      unversioned.UpdatePackage{Name: "package1", FixedVersion: "2.0.0"},
+			upgradeAll: "apt-get -o Acquire::Retries=3 upgrade -y",
+		},
+		"rpm": {
+			osType:    utils.OSTypeRocky,
+			osVersion: "9.3",
+			files: map[string]string{
+				"/applications.txt": "dnf\nbusybox\ndnf-utils\ncpio\n",
+				rpmDBFile:           rpmLibPath + "/" + rpmSQLLiteDB + "\n",
+				rpmToolsFile:        "dnf:/usr/bin/dnf\nrpm:/usr/bin/rpm\n",
+			},
+			results:    "package1\t1.0.1-1\tx86_64\n",
+			update:     unversioned.UpdatePackage{Name: "package1", FixedVersion: "1.0.1-1"},
+			unmet:      unversioned.UpdatePackage{Name: "package1", FixedVersion: "2.0.0-1"},
+			upgradeAll: "dnf upgrade --refresh",
+		},
+		"pacman": {
+			osType:     utils.OSTypeArchLinux,
+			osVersion:  "latest",
+			results:    "package1 1.0.1-1\n",
+			update:     unversioned.UpdatePackage{Name: "package1", FixedVersion: "1.0.1-1"},
+			unmet:      unversioned.UpdatePackage{Name: "package1", FixedVersion: "2.0.0-1"},
+			upgradeAll: "pacman -Su --noconfirm",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			runPackageManagerConformance(t, tc)
+		})
+	}
+}
+
+// langConformanceCase describes how to drive one language manager through the
+// shared contract checked by runLangManagerConformance.
+type langConformanceCase struct {
+	files   map[string]string
+	results string
+	update  unversioned.UpdatePackage
+	unmet   unversioned.UpdatePackage
+	// verifies is set for managers that read the installed versions back, which
+	// must report unmet as an error package.
+	verifies bool
+}
+
+// runLangManagerConformance checks the same contract for the language managers of
+// pkg/langmgr, which patch on top of the OS updates and have no upgrade-all mode:
+//   - a nil manifest returns the current state untouched
+//   - an empty update list returns the current state untouched
+//   - a basic update produces a new state
+//   - ignoreErrors=true never surfaces an error for unmet updates, which are
+//     reported when the manager verifies the installed versions
+func runLangManagerConformance(t *testing.T, tc *langConformanceCase) {
+	t.Helper()
+
+	newManager := func(t *testing.T) (langmgr.LangManager, *buildkit.Config) {
+		config := newConformanceConfig(&conformanceCase{files: tc.files, results: tc.results})
+		managers := langmgr.GetLanguageManagers(config, utils.DefaultTempWorkingFolder,
+			&unversioned.UpdateManifest{LangUpdates: unversioned.LangUpdatePackages{tc.update}}, langmgr.Options{})
+		require.Len(t, managers, 1)
+		return managers[0], config
+	}
+	newManifest := func(updates ...unversioned.UpdatePackage) *unversioned.UpdateManifest {
+		return &unversioned.UpdateManifest{LangUpdates: updates}
+	}
+
+	t.Run("nil manifest", func(t *testing.T) {
+		lm, config := newManager(t)
+		state, errPkgs, err := lm.InstallUpdates(context.TODO(), &config.ImageState, nil, false)
+		require.NoError(t, err)
+		assert.Same(t, &config.ImageState, state)
+		assert.Empty(t, errPkgs)
+	})
+
+	t.Run("empty updates", func(t *testing.T) {
+		lm, config := newManager(t)
+		state, errPkgs, err := lm.InstallUpdates(context.TODO(), &config.ImageState, newManifest(), false)
+		require.NoError(t, err)
+		assert.Same(t, &config.ImageState, state)
+		assert.Empty(t, errPkgs)
+	})
+
+	t.Run("basic update", func(t *testing.T) {
+		lm, config := newManager(t)
+		state, errPkgs, err := lm.InstallUpdates(context.TODO(), &config.ImageState, newManifest(tc.update), false)
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		assert.NotSame(t, &config.ImageState, state)
+		assert.Empty(t, errPkgs)
+	})
+
+	t.Run("ignore errors", func(t *testing.T) {
+		lm, config := newManager(t)
+		state, errPkgs, err := lm.InstallUpdates(context.TODO(), &config.ImageState, newManifest(tc.unmet), true)
+		require.NoError(t, err)
+		assert.NotNil(t, state)
+		if tc.verifies {
+			assert.Equal(t, []string{tc.unmet.Name}, errPkgs)
+		}
+	})
+}
+
+// TestLangManagerConformance runs the npm and pip managers of pkg/langmgr through the
+// shared contract. There is no RubyGems manager to cover yet.
+func TestLangManagerConformance(t *testing.T) {
+	tests := map[string]*langConformanceCase{
+		"npm": {
+			// npm trusts the exit status of the install and reads nothing back.
+			results: "lodash@4.17.21\n",
+			update:  unversioned.UpdatePackage{Name: "lodash", InstalledVersion: "4.17.20", FixedVersion: "4.17.21", Type: utils.NodePackages},
+			unmet:   unversioned.UpdatePackage{Name: "lodash", InstalledVersion: "4.17.20", FixedVersion: "5.0.0", Type: utils.NodePackages},
+		},
+		"pip": {
+			results:  "requests==2.31.0\n",
+			update:   unversioned.UpdatePackage{Name: "requests", InstalledVersion: "2.30.0", FixedVersion: "2.31.0", Type: utils.PythonPackages},
+			unmet:    unversioned.UpdatePackage{Name: "requests", InstalledVersion: "2.30.0", FixedVersion: "3.0.0", Type: utils.PythonPackages},
+			verifies: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			runLangManagerConformance(t, tc)
+		})
+	}
+}
@@ -126,6 +126,31 @@ func getDPKGStatusType(b []byte) dpkgStatusType {
 	return statusType
 }
 
+func (dm *dpkgManager) Preflight(ctx context.Context) error {
+	st := preflightState(dm.config)
+	aptGet := dm.config.PkgMgrBinary(binaryAptGet)
+	paths := append(toolPaths(aptGet), dpkgStatusPath, dpkgStatusFolder)
+	existing, err := buildkit.ExistingFilesInState(ctx, dm.config.Client, &st, paths)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case existing[dpkgStatusPath]:
+		if !hasTool(existing, aptGet) {
+			return missingToolError(binaryAptGet, aptGet)
+		}
+		return nil
+	case existing[dpkgStatusFolder]:
+		// Distroless images are patched with apt-get from a tooling image
+		manifest := &unversioned.UpdateManifest{Metadata: unversioned.Metadata{OS: unversioned.OS{Type: dm.osType}}}
+		return preflightToolImage(ctx, dm.config, binaryAptGet,
+			getAPTImageName(manifest, dm.osVersion, true), getAPTImageName(manifest, dm.osVersion, false))
+	default:
+		return noPackageDatabaseError(dpkgStatusPath, dpkgStatusFolder)
+	}
+}
+
 func (dm *dpkgManager) InstallUpdates(ctx context.Context, manifest *unversioned.UpdateManifest, ignoreErrors bool) (*llb.State, []string, error) {
 	imagePlatform, err := dm.config.ImageState.GetPlatform(ctx)
 	if err != nil {
@@ -165,9 +190,10 @@ func (dm *dpkgManager) InstallUpdates(ctx context.Context, manifest *unversioned
 	if err != nil {
 		return nil, nil, err
 	}
+	updates, skipped := skipReleaseMismatches(dm.osType, dm.osVersion, updates)
 	if len(updates) == 0 {
 		log.Warn("No update packages were specified to apply")
-		return &dm.config.ImageState, nil, nil
+		return &dm.config.ImageState, skipped, nil
 	}
 
 	var updatedImageState *llb.State
@@ -184,13 +210,23 @@ func (dm *dpkgManager) InstallUpdates(ctx context.Context, manifest *unversioned
 		}
 	}
 
+	if dm.config.APTSecurityOnly {
+		results, err := securityUpgradeResults(updates, resultManifestBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range results {
+			log.Infof("Security-only upgrade: %s", r)
+		}
+	}
+
 	// Validate that the deployed packages are of the requested version or better
 	errPkgs, err := validateDebianPackageVersions(updates, debComparer, resultManifestBytes, ignoreErrors)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return updatedImageState, errPkgs, nil
+	return updatedImageState, append(errPkgs, skipped...), nil
 }
 
 // Probe the target image for:
@@ -207,9 +243,10 @@ func (dm *dpkgManager) probeDPKGStatus(ctx context.Context, toolImage string, pl
 		llb.Platform(*platform),
 		llb.ResolveModeDefault,
 	)
+	aptOpts := aptGetOptions(dm.config.MaxConcurrentDownloads)
 	updated := toolingBase.Run(
-		llb.Shlex("apt-get -o Acquire::Retries=3 update"),
-		llb.WithProxy(utils.GetProxy()),
+		llb.Shlex("apt-get "+aptOpts+" update"),
+		utils.WithProxy(),
 		llb.IgnoreCache,
 		llb.WithCustomName("Updating package database"),
 	).Root()
@@ -217,7 +254,7 @@ func (dm *dpkgManager) probeDPKGStatus(ctx context.Context, toolImage string, pl
 	const installBusyBoxCmd = "apt-get -o Acquire::Retries=3 install busybox-static"
 	busyBoxInstalled := updated.Run(
 		llb.Shlex(installBusyBoxCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 		llb.WithCustomName("Installing busybox")).Root()
 	busyBoxApplied := imageStateCurrent.File(llb.Copy(busyBoxInstalled, "/bin/busybox", "/bin/busybox"))
 	mkFolders := busyBoxApplied.File(llb.Mkdir(resultsPath, 0o744, llb.WithParents(true)))
@@ -302,6 +339,8 @@ func (dm *dpkgManager) probeDPKGStatus(ctx context.Context, toolImage string, pl
 		log.Infof("Processed status.d: %s", dm.statusdNames)
 		dm.isDistroless = true
 		return nil
+	case DPKGStatusNone:
+		return noPackageDatabaseError(dpkgStatusPath, dpkgStatusFolder)
 	default:
 		err := fmt.Errorf("could not infer DPKG status of target image: %v", dpkgStatus)
 		log.Error(err)
@@ -332,6 +371,17 @@ func GetPackageInfo(file string) (string, string, error) {
 	return packageName, packageVersion, nil
 }
 
+// aptGetOptions returns the -o options passed to apt-get for network operations.
+// When maxDownloads is set, apt uses a single queue per access method and keeps at
+// most maxDownloads requests in flight, so slow links are not saturated.
+func aptGetOptions(maxDownloads int) string {
+	opts := "-o Acquire::Retries=3"
+	if maxDownloads > 0 {
+		opts += fmt.Sprintf(" -o Acquire::Queue-Mode=access -o Acquire::http::Pipeline-Depth=%d", maxDownloads)
+	}
+	return opts
+}
+
 // Patch a regular debian image with:
 //   - sh and apt-get installed on the image
 //   - valid dpkg status on the image
@@ -342,10 +392,21 @@ func (dm *dpkgManager) installUpdates(ctx context.Context, updates unversioned.U
 	if dm.config.PatchedConfigData != nil {
 		imageStateCurrent = dm.config.PatchedImageState
 	}
+	if archive := dm.config.RepoSnapshots[repoTypeDeb]; archive != "" {
+		imageStateCurrent = withAPTSnapshot(imageStateCurrent, archive)
+	}
+	if dm.config.APTSecurityOnly {
+		imageStateCurrent = withAPTSecuritySources(imageStateCurrent)
+	}
+	if mirror := dm.config.RepoMirrors[repoTypeDeb]; mirror != "" {
+		imageStateCurrent = withAPTMirror(imageStateCurrent, mirror)
+	}
 
+	aptGet := dm.config.PkgMgrBinary(binaryAptGet)
+	aptOpts := aptGetOptions(dm.config.MaxConcurrentDownloads)
 	aptGetUpdated := imageStateCurrent.Run(
-		llb.Shlex("apt-get -o Acquire::Retries=3 update"),
-		llb.WithProxy(utils.GetProxy()),
+		llb.Shlex(aptGet+" "+aptOpts+" update"),
+		utils.WithProxy(),
 		llb.IgnoreCache,
 		llb.WithCustomName("Updating package database"),
 	).Root()
@@ -353,7 +414,7 @@ func (dm *dpkgManager) installUpdates(ctx context.Context, updates unversioned.U
 	// Only check for upgradable packages when updating all (no specific updates list).
 	if updates == nil {
 		const updatesAvailableMarker = "/updates.txt"
-		checkUpgradable := fmt.Sprintf(`sh -c 'if apt-get -s upgrade 2>/dev/null | grep -q "^Inst"; then touch %s; fi'`, updatesAvailableMarker)
+		checkUpgradable := fmt.Sprintf(`sh -c 'if %s -s upgrade 2>/dev/null | grep -q "^Inst"; then touch %s; fi'`, aptGet, updatesAvailableMarker)
 		aptGetUpdated = aptGetUpdated.Run(
 			llb.Shlex(checkUpgradable),
 			llb.WithCustomName("Checking for upgradable packages"),
@@ -390,20 +451,23 @@ func (dm *dpkgManager) installUpdates(ctx context.Context, updates unversioned.U
 	//  - Reports not specifying version epochs correct (e.g. bsdutils=2.36.1-8+deb11u1 instead of with epoch as 1:2.36.1-8+dev11u1)
 	// Note that this keeps the log files from the operation, which we can consider removing as a size optimization in the future.
 
-	var installCmd string
+	var installRun llb.RunOption
 	if updates != nil {
 		if err := ValidateOSPackageNames(updates); err != nil {
 			return nil, nil, fmt.Errorf("package name validation failed: %w", err)
 		}
-		aptGetInstallTemplate := `sh -c "apt-get -o Acquire::Retries=3 install --no-install-recommends -y %s && apt-get clean -y"`
-		pkgStrings := []string{}
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
+		const aptGetInstallTemplate = `%[1]s %[2]s install %[3]s--no-install-recommends -y %[4]s && %[1]s clean -y`
+		// With the security suite pinned, upgrade the reported packages to whatever it
+		// carries rather than pulling in packages that are not installed.
+		var onlyUpgrade string
+		if dm.config.APTSecurityOnly {
+			onlyUpgrade = "--only-upgrade "
 		}
-		installCmd = fmt.Sprintf(aptGetInstallTemplate, strings.Join(pkgStrings, " "))
+		pkgStrings := installPackageNames(updates)
+		installRun = guardedInstall(fmt.Sprintf(aptGetInstallTemplate, aptGet, aptOpts, onlyUpgrade, strings.Join(pkgStrings, " ")), aptNotFoundPattern)
 	} else {
 		// if updates is not specified, update all packages
-		installCmd = `sh -c "output=$(apt-get -o Acquire::Retries=3 upgrade -y && apt-get clean -y && apt-get autoremove -y 2>&1); if [ $? -ne 0 ]; then echo "$output" >>error_log.txt; fi"`
+		installRun = llb.Shlex(fmt.Sprintf(`sh -c "output=$(%[1]s %[2]s upgrade -y && %[1]s clean -y && %[1]s autoremove -y 2>&1); if [ $? -ne 0 ]; then echo "$output" >>error_log.txt; fi"`, aptGet, aptOpts))
 	}
 
 	var customName string
@@ -413,8 +477,8 @@ func (dm *dpkgManager) installUpdates(ctx context.Context, updates unversioned.U
 		customName = "Upgrading all packages"
 	}
 	aptGetInstalled := aptGetUpdated.Run(
-		llb.Shlex(installCmd),
-		llb.WithProxy(utils.GetProxy()),
+		installRun,
+		utils.WithProxy(),
 		llb.WithCustomName(customName),
 	).Root()
 
@@ -437,7 +501,7 @@ func (dm *dpkgManager) installUpdates(ctx context.Context, updates unversioned.U
 
 	resultsBytes, err := buildkit.ExtractFileFromState(ctx, dm.config.Client, &resultsDiff, filepath.Join(resultsPath, resultManifest))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, classifyInstallError(err)
 	}
 
 	// If the image has been patched before, diff the base image and patched image to retain previous patches
@@ -493,10 +557,21 @@ func (dm *dpkgManager) unpackAndMergeUpdates(ctx context.Context, updates unvers
 		log.Debugf("Successfully resolved tooling image %s using host platform", toolImage)
 	}
 
+	if archive := dm.config.RepoSnapshots[repoTypeDeb]; archive != "" {
+		toolingBase = withAPTSnapshot(toolingBase, archive)
+	}
+	if dm.config.APTSecurityOnly {
+		toolingBase = withAPTSecuritySources(toolingBase)
+	}
+	if mirror := dm.config.RepoMirrors[repoTypeDeb]; mirror != "" {
+		toolingBase = withAPTMirror(toolingBase, mirror)
+	}
+
 	// Run apt-get update && apt-get download list of updates to target folder
+	aptOpts := aptGetOptions(dm.config.MaxConcurrentDownloads)
 	updated := toolingBase.Run(
-		llb.Shlex("apt-get -o Acquire::Retries=3 update"),
-		llb.WithProxy(utils.GetProxy()),
+		llb.Shlex("apt-get "+aptOpts+" update"),
+		utils.WithProxy(),
 		llb.IgnoreCache,
 		llb.WithCustomName("Updating package database in tooling container"),
 	).Root()
@@ -581,9 +656,7 @@ func (dm *dpkgManager) unpackAndMergeUpdates(ctx context.Context, updates unvers
 	pkgStrings := []string{}
 	var updateAll string
 	if updates != nil {
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
-		}
+		pkgStrings = installPackageNames(updates)
 		downloadCmd = fmt.Sprintf(aptGetDownloadScript, strings.Join(pkgStrings, " "))
 		updateAll = "false"
 	} else {
@@ -621,8 +694,9 @@ func (dm *dpkgManager) unpackAndMergeUpdates(ctx context.Context, updates unvers
 		llb.AddEnv("IGNORE_ERRORS", errorValidation),
 		llb.AddEnv("UPDATE_ALL", updateAll),
 		llb.AddEnv("STATUSD_FILE_MAP", string(jsonStatusdFileMap)),
+		llb.AddEnv("APT_OPTS", aptOpts),
 		buildkit.Sh(`./download.sh`),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 		llb.WithCustomName(downloadCustomName),
 	).AddMount("/tmp/debian-rootfs", withDPkgStatus)
 
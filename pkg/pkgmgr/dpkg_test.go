@@ -14,6 +14,7 @@ import (
 	"github.com/project-copacetic/copacetic/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
 	"github.com/project-copacetic/copacetic/pkg/types"
@@ -142,6 +143,27 @@ func TestGetAPTImageName(t *testing.T) {
 	}
 }
 
+func TestAptGetOptions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		maxDownloads int
+		want         string
+	}{
+		{"no limit", 0, "-o Acquire::Retries=3"},
+		{"negative is no limit", -1, "-o Acquire::Retries=3"},
+		{"limited", 2, "-o Acquire::Retries=3 -o Acquire::Queue-Mode=access -o Acquire::http::Pipeline-Depth=2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := aptGetOptions(tc.maxDownloads)
+			if got != tc.want {
+				t.Errorf("aptGetOptions() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestGetDPKGStatusType(t *testing.T) {
 	// Create some temporary directories with different files
 	tests := []struct {
@@ -546,3 +568,21 @@ func Test_installUpdates_DPKG(t *testing.T) {
 		})
 	}
 }
+
+func TestGetUniqueLatestUpdatesDebianEpochs(t *testing.T) {
+	dpkgComparer := VersionComparer{isValidDebianVersion, isLessThanDebianVersion}
+
+	// A plain string compare would keep 2.4.0-1, which dpkg orders below any epoch 1 version
+	updates := unversioned.UpdatePackages{
+		{Name: "libfoo", FixedVersion: "1:2.3.4-1"},
+		{Name: "libfoo", FixedVersion: "2.4.0-1"},
+		{Name: "libbar", FixedVersion: "3.0.0-1"},
+		{Name: "libbar", FixedVersion: "3.0.0~rc1-1"},
+	}
+	got, err := GetUniqueLatestUpdates(updates, dpkgComparer, false)
+	require.NoError(t, err)
+	assert.Equal(t, unversioned.UpdatePackages{
+		{Name: "libbar", FixedVersion: "3.0.0-1"},
+		{Name: "libfoo", FixedVersion: "1:2.3.4-1"},
+	}, got)
+}
@@ -0,0 +1,138 @@
+package pkgmgr
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// suseProductsDir holds the product files of a SUSE installation.
+const suseProductsDir = "/etc/products.d"
+
+// ForceNPM is the --force-pkg-manager value that skips OS package updates and patches
+// only the image's Node.js packages.
+const ForceNPM = "npm"
+
+// forcedManager describes an OS package manager that can be selected with
+// --force-pkg-manager instead of being derived from the image's OS type.
+type forcedManager struct {
+	// packageType is the GetPackageType of the manager
+	packageType string
+	// osType is the OS type the manager is built for when the image's OS type
+	// belongs to a different package manager or is unknown
+	osType string
+	// rpmTool is the RPM front end the manager is limited to: dnf and yum must be
+	// in the image, while zypper runs from a SUSE tooling image (empty = any)
+	rpmTool string
+}
+
+var forcedManagers = map[string]forcedManager{
+	"apt":    {packageType: "deb", osType: utils.OSTypeDebian},
+	"apk":    {packageType: "apk", osType: utils.OSTypeAlpine},
+	"rpm":    {packageType: "rpm", osType: utils.OSTypeRedHat},
+	"dnf":    {packageType: "rpm", osType: utils.OSTypeRedHat, rpmTool: "dnf"},
+	"yum":    {packageType: "rpm", osType: utils.OSTypeRedHat, rpmTool: "yum"},
+	"zypper": {packageType: "rpm", osType: utils.OSTypeSLES, rpmTool: "zypper"},
+}
+
+// accepts reports whether manager, built for the image's own OS type, already is
+// the forced manager. SUSE images are patched with zypper and other RPM distros
+// with dnf or yum, so forcing one of those also needs the matching distro family.
+func (f forcedManager) accepts(manager PackageManager, osType string) bool {
+	if manager.GetPackageType() != f.packageType {
+		return false
+	}
+	return f.rpmTool == "" || utils.IsSUSEImage(osType) == (f.rpmTool == "zypper")
+}
+
+// ForcedPackageManagers returns the sorted values accepted by --force-pkg-manager.
+func ForcedPackageManagers() []string {
+	names := []string{ForceNPM}
+	for name := range forcedManagers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateForcedPackageManager returns an error if name is not a value accepted by
+// --force-pkg-manager.
+func ValidateForcedPackageManager(name string) error {
+	if _, ok := forcedManagers[name]; ok || name == ForceNPM {
+		return nil
+	}
+	return fmt.Errorf("unsupported package manager %q, supported: %s", name, strings.Join(ForcedPackageManagers(), ", "))
+}
+
+// GetForcedPackageManager returns the OS package manager name, regardless of whether
+// osType is supported or maps to another manager. When osType belongs to the forced
+// manager (e.g. rpm on Rocky Linux), the manager keeps its distro-specific behavior;
+// otherwise it is built as if the image ran the manager's default distro. Forcing
+// dnf or yum fails at install time if the image doesn't have that tool, and forcing
+// zypper fails here unless the image looks like a SUSE installation.
+func GetForcedPackageManager(ctx context.Context, name, osType, osVersion string, config *buildkit.Config, workingFolder string) (PackageManager, error) {
+	forced, ok := forcedManagers[name]
+	if !ok {
+		if err := ValidateForcedPackageManager(name); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s does not patch OS packages", name)
+	}
+
+	canonicalOSType := utils.CanonicalOSType(osType)
+	if newManager, ok := packageManagers[canonicalOSType]; ok {
+		manager := newManager(canonicalOSType, osVersion, config, workingFolder)
+		if forced.accepts(manager, canonicalOSType) {
+			return withForcedRPMTool(manager, forced.rpmTool), nil
+		}
+	}
+
+	if forced.rpmTool == "zypper" {
+		if err := probeSUSEImage(ctx, config); err != nil {
+			return nil, fmt.Errorf("--force-pkg-manager=zypper on an image reported as %q: %w", osType, err)
+		}
+	}
+
+	log.Warnf("Forcing the %s package manager on an image reported as %q; updates will fail if %s does not manage the image's packages",
+		name, osType, name)
+	manager := packageManagers[forced.osType](forced.osType, osVersion, config, workingFolder)
+	return withForcedRPMTool(manager, forced.rpmTool), nil
+}
+
+// probeSUSEImage checks that the image has an rpm database and either zypper or the
+// SUSE product files, as zypper patches an image from a SUSE tooling image that can
+// only update the packages of a SUSE installation.
+func probeSUSEImage(ctx context.Context, config *buildkit.Config) error {
+	st := preflightState(config)
+	libDBPaths := rpmLibDBPaths()
+	paths := append(append(slices.Clone(libDBPaths), toolPaths("zypper")...), suseProductsDir)
+	existing, err := buildkit.ExistingFilesInState(ctx, config.Client, &st, paths)
+	if err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(libDBPaths, func(p string) bool { return existing[p] }) {
+		return noPackageDatabaseError(libDBPaths...)
+	}
+	if !hasTool(existing, "zypper") && !existing[suseProductsDir] {
+		return fmt.Errorf("%w: the image has neither zypper nor %s", types.ErrPackageManagerNotFound, suseProductsDir)
+	}
+	return nil
+}
+
+// withForcedRPMTool limits an RPM manager to tool when it is one the image has to
+// provide itself.
+func withForcedRPMTool(manager PackageManager, tool string) PackageManager {
+	if rm, ok := manager.(*rpmManager); ok && (tool == "dnf" || tool == "yum") {
+		rm.forcedTool = tool
+	}
+	return manager
+}
@@ -0,0 +1,84 @@
+package pkgmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func TestGetForcedPackageManager(t *testing.T) {
+	tests := []struct {
+		name       string
+		forced     string
+		osType     string
+		osVersion  string
+		files      []string
+		wantType   string
+		wantOSType string
+		wantTool   string
+		wantErr    string
+	}{
+		{name: "apk on a debian report", forced: "apk", osType: utils.OSTypeDebian, osVersion: "12", wantType: "apk"},
+		{name: "apt on an unknown distro", forced: "apt", osType: "", wantType: "deb", wantOSType: utils.OSTypeDebian},
+		{name: "apt keeps ubuntu", forced: "apt", osType: "Ubuntu", osVersion: "22.04", wantType: "deb", wantOSType: utils.OSTypeUbuntu},
+		{name: "rpm keeps the rpm distro", forced: "rpm", osType: utils.OSTypeRocky, osVersion: "9.3", wantType: "rpm", wantOSType: utils.OSTypeRocky},
+		{name: "rpm on an alpine report", forced: "rpm", osType: utils.OSTypeAlpine, osVersion: "3.19", wantType: "rpm", wantOSType: utils.OSTypeRedHat},
+		{name: "npm has no OS package manager", forced: ForceNPM, osType: utils.OSTypeDebian, wantErr: "does not patch OS packages"},
+		{name: "dnf keeps the rpm distro", forced: "dnf", osType: utils.OSTypeRocky, osVersion: "9.3", wantType: "rpm", wantOSType: utils.OSTypeRocky, wantTool: "dnf"},
+		{name: "yum on a suse report", forced: "yum", osType: utils.OSTypeSLES, osVersion: "15.5", wantType: "rpm", wantOSType: utils.OSTypeRedHat, wantTool: "yum"},
+		{name: "zypper keeps suse", forced: "zypper", osType: utils.OSTypeOpenSUSELeap, osVersion: "15.5", wantType: "rpm", wantOSType: utils.OSTypeOpenSUSELeap},
+		{name: "zypper on a rocky report", forced: "zypper", osType: utils.OSTypeRocky, osVersion: "9.3", files: []string{"/var/lib/rpm/rpmdb.sqlite", "/usr/bin/zypper"}, wantType: "rpm", wantOSType: utils.OSTypeSLES},
+		{name: "zypper on an unknown distro with suse products", forced: "zypper", osType: "", osVersion: "15.5", files: []string{"/var/lib/rpm/Packages.db", suseProductsDir}, wantType: "rpm", wantOSType: utils.OSTypeSLES},
+		{name: "zypper on an image without suse setup", forced: "zypper", osType: utils.OSTypeRocky, osVersion: "9.3", files: []string{"/var/lib/rpm/rpmdb.sqlite", "/usr/bin/dnf"}, wantErr: "the image has neither zypper nor /etc/products.d"},
+		{name: "zypper on an image without an rpm database", forced: "zypper", osType: utils.OSTypeAlpine, osVersion: "3.19", files: []string{"/lib/apk/db/installed"}, wantErr: "no package database"},
+		{name: "unknown manager", forced: "pacman", osType: utils.OSTypeArchLinux, wantErr: `unsupported package manager "pacman", supported: apk, apt, dnf, npm, rpm, yum, zypper`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, err := GetForcedPackageManager(context.TODO(), tt.forced, tt.osType, tt.osVersion, preflightConfig(tt.files, nil, nil), utils.DefaultTempWorkingFolder)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, manager.GetPackageType())
+
+			switch m := manager.(type) {
+			case *dpkgManager:
+				assert.Equal(t, tt.wantOSType, m.osType)
+			case *rpmManager:
+				assert.Equal(t, tt.wantOSType, m.osType)
+				assert.Equal(t, tt.osVersion, m.osVersion)
+				assert.Equal(t, tt.wantTool, m.forcedTool)
+			}
+		})
+	}
+}
+
+func TestValidateForcedPackageManager(t *testing.T) {
+	for _, name := range ForcedPackageManagers() {
+		assert.NoError(t, ValidateForcedPackageManager(name))
+	}
+	assert.Error(t, ValidateForcedPackageManager("pacman"))
+	assert.Error(t, ValidateForcedPackageManager(""))
+}
+
+func TestSelectForcedRPMTool(t *testing.T) {
+	tools := rpmToolPaths{"dnf": "/usr/bin/dnf", "microdnf": "/usr/bin/microdnf", "rpm": "/usr/bin/rpm"}
+
+	selected, err := selectForcedRPMTool(tools, "dnf")
+	require.NoError(t, err)
+	assert.Equal(t, rpmToolPaths{"dnf": "/usr/bin/dnf", "rpm": "/usr/bin/rpm"}, selected)
+
+	_, err = selectForcedRPMTool(tools, "yum")
+	assert.EqualError(t, err, "--force-pkg-manager=yum: yum was not found in the image, which has dnf, microdnf")
+
+	_, err = selectForcedRPMTool(rpmToolPaths{"rpm": "/usr/bin/rpm"}, "dnf")
+	assert.EqualError(t, err, "--force-pkg-manager=dnf: dnf was not found in the image")
+}
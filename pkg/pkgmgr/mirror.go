@@ -0,0 +1,85 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// validMirrorURLPattern restricts mirror URLs to characters that are safe to
+// interpolate into the sed expressions below.
+var validMirrorURLPattern = regexp.MustCompile(`^https?://[A-Za-z0-9._~:/%+-]+$`)
+
+// repoHostPattern matches the scheme and host of a repository URL. Rewriting only this
+// part keeps each repository's path, so an internal mirror that proxies the upstream
+// layout under a prefix (e.g. https://mirror.example.com/debian-remote) works as is.
+const repoHostPattern = `https?://[^/ ]+`
+
+// ParseRepoMirrors parses --repo-mirror values of the form <type>=<url>, e.g.
+// "deb=https://mirror.example.com" or "apk=https://mirror.example.com/alpine-proxy".
+func ParseRepoMirrors(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	mirrors := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		pkgType, value, ok := strings.Cut(spec, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid --repo-mirror %q: expected <type>=<url>", spec)
+		}
+		if pkgType != repoTypeDeb && pkgType != repoTypeAPK && pkgType != repoTypeRPM {
+			return nil, fmt.Errorf("invalid --repo-mirror %q: unsupported type %q, supported: %s", spec, pkgType, strings.Join(MirrorTypes(), ", "))
+		}
+		if _, dup := mirrors[pkgType]; dup {
+			return nil, fmt.Errorf("invalid --repo-mirror %q: %s mirror specified more than once", spec, pkgType)
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Host == "" || !validMirrorURLPattern.MatchString(value) {
+			return nil, fmt.Errorf("invalid --repo-mirror %q: %q is not an absolute http(s) URL", spec, value)
+		}
+		mirrors[pkgType] = strings.TrimSuffix(value, "/")
+	}
+	return mirrors, nil
+}
+
+// MirrorTypes returns the sorted package types that accept a repository mirror.
+func MirrorTypes() []string {
+	return []string{repoTypeAPK, repoTypeDeb, repoTypeRPM}
+}
+
+// withAPTMirror points every apt source in st at mirror. Like withAPTSnapshot, it is
+// applied below the state the install runs on so the image keeps its original sources.
+func withAPTMirror(st llb.State, mirror string) llb.State {
+	script := fmt.Sprintf(`for f in /etc/apt/sources.list /etc/apt/sources.list.d/*.list /etc/apt/sources.list.d/*.sources; do `+
+		`if [ -f "$f" ]; then sed -i -E 's#%s#%s#g' "$f"; fi; done`, repoHostPattern, mirror)
+	return st.Run(
+		llb.Args([]string{"sh", "-c", script}),
+		llb.WithCustomNamef("Using apt mirror %s", mirror),
+	).Root()
+}
+
+// withAPKMirror points the repositories in /etc/apk/repositories at mirror.
+func withAPKMirror(st llb.State, mirror string) llb.State {
+	script := fmt.Sprintf(`sed -i -E 's#%s#%s#g' %s`, repoHostPattern, mirror, apkRepositoriesFile)
+	return st.Run(
+		llb.Args([]string{"sh", "-c", script}),
+		llb.WithCustomNamef("Using apk mirror %s", mirror),
+	).Root()
+}
+
+// withRPMMirror points the yum/dnf/tdnf repositories in st at mirror. Mirror lists and
+// metalinks would bypass the mirror, so they are disabled and any commented-out
+// baseurl is restored in their place.
+func withRPMMirror(st llb.State, mirror string) llb.State {
+	script := fmt.Sprintf(`for f in /etc/yum.repos.d/*.repo; do `+
+		`if [ -f "$f" ]; then sed -i -E -e 's@^#?baseurl=%s@baseurl=%s@' -e 's@^(mirrorlist|metalink)=@#\1=@' "$f"; fi; done`,
+		repoHostPattern, mirror)
+	return st.Run(
+		llb.Args([]string{"sh", "-c", script}),
+		llb.WithCustomNamef("Using rpm mirror %s", mirror),
+	).Root()
+}
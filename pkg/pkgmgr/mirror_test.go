@@ -0,0 +1,74 @@
+package pkgmgr
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRepoMirrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "none"},
+		{
+			name:  "all types",
+			specs: []string{"deb=https://mirror.example.com/debian-proxy/", "apk=http://10.0.0.5:8080", "rpm=https://mirror.example.com"},
+			want: map[string]string{
+				"deb": "https://mirror.example.com/debian-proxy",
+				"apk": "http://10.0.0.5:8080",
+				"rpm": "https://mirror.example.com",
+			},
+		},
+		{name: "missing url", specs: []string{"rpm="}, wantErr: "expected <type>=<url>"},
+		{name: "unsupported type", specs: []string{"pacman=https://mirror.example.com"}, wantErr: `unsupported type "pacman"`},
+		{name: "relative url", specs: []string{"deb=mirror.example.com"}, wantErr: "not an absolute http(s) URL"},
+		{name: "unsupported scheme", specs: []string{"deb=ftp://mirror.example.com"}, wantErr: "not an absolute http(s) URL"},
+		{name: "shell metacharacters", specs: []string{"apk=https://mirror.example.com/';reboot;'"}, wantErr: "not an absolute http(s) URL"},
+		{name: "sed delimiter", specs: []string{"deb=https://mirror.example.com/#x"}, wantErr: "not an absolute http(s) URL"},
+		{
+			name:    "duplicate type",
+			specs:   []string{"rpm=https://a.example.com", "rpm=https://b.example.com"},
+			wantErr: "more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRepoMirrors(tt.specs)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepoMirrorInLLB(t *testing.T) {
+	const mirror = "https://mirror.example.com/proxy"
+
+	t.Run("apt sources", func(t *testing.T) {
+		st := withAPTMirror(llb.Image("debian:12"), mirror)
+		assert.True(t, definitionContains(t, st, "s#"+repoHostPattern+"#"+mirror+"#g"))
+		assert.True(t, definitionContains(t, st, "/etc/apt/sources.list.d/*.sources"))
+	})
+
+	t.Run("apk repositories", func(t *testing.T) {
+		st := withAPKMirror(llb.Image("alpine:3.19"), mirror)
+		assert.True(t, definitionContains(t, st, "s#"+repoHostPattern+"#"+mirror+"#g' "+apkRepositoriesFile))
+	})
+
+	t.Run("rpm repo files", func(t *testing.T) {
+		st := withRPMMirror(llb.Image("quay.io/centos/centos:stream9"), mirror)
+		assert.True(t, definitionContains(t, st, "s@^#?baseurl="+repoHostPattern+"@baseurl="+mirror+"@"))
+		assert.True(t, definitionContains(t, st, "#\\1="))
+		assert.True(t, definitionContains(t, st, "/etc/yum.repos.d/*.repo"))
+	})
+}
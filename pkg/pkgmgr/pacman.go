@@ -59,7 +59,7 @@ func validatePacmanPackageVersions(updates unversioned.UpdatePackages, cmp Versi
 	sort.SliceStable(updates, func(i, j int) bool {
 		return updates[i].Name < updates[j].Name
 	})
-	log.Debugf("Required updates: %s", updates)
+	log.Debugf("Required updates: %v", updates)
 
 	sort.SliceStable(lines, func(i, j int) bool {
 		return lines[i] < lines[j]
@@ -106,6 +106,22 @@ func validatePacmanPackageVersions(updates unversioned.UpdatePackages, cmp Versi
 	return errorPkgs, errors.Join(allErrors...)
 }
 
+func (pm *pacmanManager) Preflight(ctx context.Context) error {
+	const pacman, sh = "/usr/bin/pacman", "/bin/sh"
+	st := preflightState(pm.config)
+	existing, err := buildkit.ExistingFilesInState(ctx, pm.config.Client, &st, []string{pacman, sh})
+	if err != nil {
+		return err
+	}
+	if !existing[pacman] {
+		return missingToolError("pacman", pacman)
+	}
+	if !existing[sh] {
+		return missingToolError("sh", sh)
+	}
+	return nil
+}
+
 func (pm *pacmanManager) InstallUpdates(ctx context.Context, manifest *unversioned.UpdateManifest, ignoreErrors bool) (*llb.State, []string, error) {
 	if manifest == nil {
 		updatedImageState, _, err := pm.upgradePackages(ctx, nil, ignoreErrors)
@@ -154,7 +170,7 @@ func (pm *pacmanManager) upgradePackages(ctx context.Context, updates unversione
 
 	pacmanUpdated := imageStateCurrent.Run(
 		llb.Shlex("/usr/bin/pacman -Sy"),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 		llb.IgnoreCache,
 		llb.WithCustomName("Updating package database"),
 	).Root()
@@ -183,10 +199,7 @@ func (pm *pacmanManager) upgradePackages(ctx context.Context, updates unversione
 	var resultManifestBytes []byte
 	var err error
 	if updates != nil {
-		pkgStrings := []string{}
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
-		}
+		pkgStrings := installPackageNames(updates)
 
 		// 1. Join strings properly
 		// 2. Use /bin/sh explicitly for safety
@@ -195,7 +208,7 @@ func (pm *pacmanManager) upgradePackages(ctx context.Context, updates unversione
 
 		pacmanInstalled = pacmanUpdated.Run(
 			llb.Shlex(installCmd),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 			llb.WithCustomName(fmt.Sprintf("Upgrading %d security updates", len(pkgStrings))),
 		).Root()
 
@@ -217,7 +230,7 @@ func (pm *pacmanManager) upgradePackages(ctx context.Context, updates unversione
 		installCmd := `output=$(/usr/bin/pacman -Su --noconfirm 2>&1); if [ $? -ne 0 ]; then echo "$output" >> error_log.txt; fi`
 		pacmanInstalled = pacmanUpdated.Run(
 			buildkit.Sh(installCmd),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 			llb.WithCustomName("Upgrading all packages"),
 		).Root()
 
@@ -14,6 +14,7 @@ import (
 	"github.com/moby/buildkit/frontend/gateway/client"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 	"github.com/project-copacetic/copacetic/pkg/utils"
 	log "github.com/sirupsen/logrus"
@@ -30,47 +31,80 @@ const (
 )
 
 type PackageManager interface {
+	// Preflight checks that the image has the tools InstallUpdates runs, or that the
+	// tooling image used in their place can be resolved, so that a missing package
+	// manager fails early with ErrPackageManagerNotFound instead of mid-solve.
+	Preflight(context.Context) error
 	InstallUpdates(context.Context, *unversioned.UpdateManifest, bool) (*llb.State, []string, error)
 	GetPackageType() string
 }
 
+// PackageToolReporter is implemented by package managers that choose among several
+// tools found in the image, such as dnf and yum on RPM images.
+type PackageToolReporter interface {
+	// PackageTool returns the tool updates are installed with, or "" when the image
+	// is patched with the tools of a tooling image.
+	PackageTool() string
+}
+
+// packageManagerFactory constructs the PackageManager for a canonical OS type.
+type packageManagerFactory func(osType, osVersion string, config *buildkit.Config, workingFolder string) PackageManager
+
+func newAPKManager(_, _ string, config *buildkit.Config, workingFolder string) PackageManager {
+	return &apkManager{config: config, workingFolder: workingFolder}
+}
+
+func newDPKGManager(osType, osVersion string, config *buildkit.Config, workingFolder string) PackageManager {
+	return &dpkgManager{config: config, workingFolder: workingFolder, osVersion: osVersion, osType: osType}
+}
+
+func newRPMManager(osType, osVersion string, config *buildkit.Config, workingFolder string) PackageManager {
+	return &rpmManager{config: config, workingFolder: workingFolder, osType: osType, osVersion: osVersion}
+}
+
+func newPacmanManager(_, _ string, config *buildkit.Config, workingFolder string) PackageManager {
+	return &pacmanManager{config: config, workingFolder: workingFolder}
+}
+
+// packageManagers maps each supported canonical OS type to its package manager.
+var packageManagers = map[string]packageManagerFactory{
+	utils.OSTypeAlpine:       newAPKManager,
+	utils.OSTypeDebian:       newDPKGManager,
+	utils.OSTypeUbuntu:       newDPKGManager,
+	utils.OSTypeCBLMariner:   newRPMManager,
+	utils.OSTypeAzureLinux:   newRPMManager,
+	utils.OSTypeCentOS:       newRPMManager,
+	utils.OSTypeOracle:       newRPMManager,
+	utils.OSTypeRedHat:       newRPMManager,
+	utils.OSTypeRocky:        newRPMManager,
+	utils.OSTypeAmazon:       newRPMManager,
+	utils.OSTypeAlma:         newRPMManager,
+	utils.OSTypeAlmaLinux:    newRPMManager,
+	utils.OSTypeSLES:         newRPMManager,
+	utils.OSTypeOpenSUSELeap: newRPMManager,
+	utils.OSTypeOpenSUSETW:   newRPMManager,
+	utils.OSTypeArchLinux:    newPacmanManager,
+	utils.OSTypeWolfi:        newAPKManager,
+	utils.OSTypeChainguard:   newAPKManager,
+}
+
+// SupportedEcosystems returns the sorted OS types that have a registered package manager.
+func SupportedEcosystems() []string {
+	osTypes := make([]string, 0, len(packageManagers))
+	for osType := range packageManagers {
+		osTypes = append(osTypes, osType)
+	}
+	sort.Strings(osTypes)
+	return osTypes
+}
+
 func GetPackageManager(osType string, osVersion string, config *buildkit.Config, workingFolder string) (PackageManager, error) {
 	canonicalOSType := utils.CanonicalOSType(osType)
-	switch canonicalOSType {
-	case utils.OSTypeAlpine:
-		return &apkManager{
-			config:        config,
-			workingFolder: workingFolder,
-		}, nil
-	case utils.OSTypeDebian, utils.OSTypeUbuntu:
-		return &dpkgManager{
-			config:        config,
-			workingFolder: workingFolder,
-			osVersion:     osVersion,
-			osType:        canonicalOSType,
-		}, nil
-	case utils.OSTypeCBLMariner, utils.OSTypeAzureLinux, utils.OSTypeCentOS, utils.OSTypeOracle, utils.OSTypeRedHat, utils.OSTypeRocky, utils.OSTypeAmazon, utils.OSTypeAlma, utils.OSTypeAlmaLinux:
-		return &rpmManager{
-			config:        config,
-			workingFolder: workingFolder,
-			osType:        canonicalOSType,
-			osVersion:     osVersion,
-		}, nil
-	case utils.OSTypeSLES, utils.OSTypeOpenSUSELeap, utils.OSTypeOpenSUSETW:
-		return &rpmManager{
-			config:        config,
-			workingFolder: workingFolder,
-			osType:        canonicalOSType,
-			osVersion:     osVersion,
-		}, nil
-	case utils.OSTypeArchLinux:
-		return &pacmanManager{
-			config:        config,
-			workingFolder: workingFolder,
-		}, nil
-	default:
-		return nil, fmt.Errorf("unsupported osType %s specified", osType)
+	newManager, ok := packageManagers[canonicalOSType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported osType %s specified, supported: %s", osType, strings.Join(SupportedEcosystems(), ", "))
 	}
+	return newManager(canonicalOSType, osVersion, config, workingFolder), nil
 }
 
 // Utility functions for package manager implementations to share
@@ -99,6 +133,19 @@ func ValidateOSPackageNames(updates unversioned.UpdatePackages) error {
 	return nil
 }
 
+// installPackageNames returns the names to pass to the package manager for updates.
+// These are the names exactly as they appear in the image, never the canonical
+// upstream names from utils.CanonicalPackageName: the two differ across musl and
+// glibc distros (e.g. Alpine's libcrypto3 is OpenSSL), and installing by the
+// upstream name would add a new package or fail to resolve.
+func installPackageNames(updates unversioned.UpdatePackages) []string {
+	names := make([]string, 0, len(updates))
+	for _, u := range updates {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
 type VersionComparer struct {
 	IsValid  func(string) bool
 	LessThan func(string, string) bool
@@ -111,15 +158,14 @@ func GetUniqueLatestUpdates(updates unversioned.UpdatePackages, cmp VersionCompa
 		return unversioned.UpdatePackages{}, nil
 	}
 
-	dict := make(map[string]string)
+	dict := make(map[string]unversioned.UpdatePackage)
 	var allErrors *multierror.Error
 	for _, u := range updates {
 		if cmp.IsValid(u.FixedVersion) {
-			ver, ok := dict[u.Name]
-			if !ok {
-				dict[u.Name] = u.FixedVersion
-			} else if cmp.LessThan(ver, u.FixedVersion) {
-				dict[u.Name] = u.FixedVersion
+			latest, ok := dict[u.Name]
+			if !ok || cmp.LessThan(latest.FixedVersion, u.FixedVersion) {
+				// The status is kept so InstalledOnly entries can still be told apart
+				dict[u.Name] = unversioned.UpdatePackage{Name: u.Name, FixedVersion: u.FixedVersion, Status: u.Status}
 			}
 		} else {
 			err := fmt.Errorf("invalid version %s found for package %s", u.FixedVersion, u.Name)
@@ -133,8 +179,8 @@ func GetUniqueLatestUpdates(updates unversioned.UpdatePackages, cmp VersionCompa
 	}
 
 	out := unversioned.UpdatePackages{}
-	for k, v := range dict {
-		out = append(out, unversioned.UpdatePackage{Name: k, FixedVersion: v})
+	for _, u := range dict {
+		out = append(out, u)
 	}
 	sort.Slice(out, func(i, j int) bool {
 		return out[i].Name < out[j].Name
@@ -240,6 +286,12 @@ func tryImage(ctx context.Context, imageRef string, c client.Client, platform *o
 	return st, nil
 }
 
+// noPackageDatabaseError reports that none of paths, the locations of a package
+// manager's database, exist in the image.
+func noPackageDatabaseError(paths ...string) error {
+	return fmt.Errorf("%w (looked for %s)", types.ErrNoPackageDatabase, strings.Join(paths, ", "))
+}
+
 // isMarkerMissingErr returns true only when a marker-file extraction failed
 // during ReadFile (not during c.Solve). This guarantees the error text we
 // inspect came from the file-read phase only and cannot contain shell command
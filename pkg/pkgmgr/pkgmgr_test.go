@@ -129,6 +129,24 @@ func TestGetPackageManager(t *testing.T) {
 	})
 }
 
+func TestSupportedEcosystems(t *testing.T) {
+	ecosystems := SupportedEcosystems()
+	assert.IsIncreasing(t, ecosystems)
+	assert.Contains(t, ecosystems, utils.OSTypeAlpine)
+	assert.Contains(t, ecosystems, utils.OSTypeAmazon)
+	assert.Contains(t, ecosystems, utils.OSTypeArchLinux)
+
+	// Every listed OS type resolves to a package manager
+	for _, osType := range ecosystems {
+		manager, err := GetPackageManager(osType, "1.0", &buildkit.Config{}, utils.DefaultTempWorkingFolder)
+		assert.NoError(t, err, osType)
+		assert.NotNil(t, manager, osType)
+	}
+
+	_, err := GetPackageManager("unsupported", "", &buildkit.Config{}, utils.DefaultTempWorkingFolder)
+	assert.ErrorContains(t, err, "supported: "+strings.Join(ecosystems, ", "))
+}
+
 func IsValid(version string) bool {
 	return version != "invalid"
 }
@@ -427,3 +445,27 @@ func TestIsMarkerMissingErr(t *testing.T) {
 		})
 	}
 }
+
+func TestInstallPackageNames(t *testing.T) {
+	// Packages whose in-image names differ between musl and glibc distros must be
+	// installed by those names, not by the upstream project they map to.
+	tests := []struct {
+		pkgType string
+		names   []string
+	}{
+		{pkgType: "apk", names: []string{"libcrypto3", "libssl3", "musl-utils", "libcurl"}},
+		{pkgType: "deb", names: []string{"libc6", "libssl3", "zlib1g", "libcurl4"}},
+		{pkgType: "rpm", names: []string{"glibc-common", "openssl-libs", "libcurl-minimal"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkgType, func(t *testing.T) {
+			var updates unversioned.UpdatePackages
+			for _, name := range tt.names {
+				updates = append(updates, unversioned.UpdatePackage{Name: name})
+				assert.NotEqual(t, name, utils.CanonicalPackageName(tt.pkgType, name), "%s should have a canonical name", name)
+			}
+			assert.Equal(t, tt.names, installPackageNames(updates))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package pkgmgr
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+// toolDirs are searched for package manager commands that are not given by path, as
+// they would be on the default PATH of a shell in the image.
+var toolDirs = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// toolPaths returns the paths command may be found at in an image: only itself when
+// it is an absolute path, as set with --pkgmgr-path, otherwise each of toolDirs.
+func toolPaths(command string) []string {
+	if path.IsAbs(command) {
+		return []string{command}
+	}
+	paths := make([]string, 0, len(toolDirs))
+	for _, dir := range toolDirs {
+		paths = append(paths, path.Join(dir, command))
+	}
+	return paths
+}
+
+// hasTool reports whether command is at any of its toolPaths in existing.
+func hasTool(existing map[string]bool, command string) bool {
+	return slices.ContainsFunc(toolPaths(command), func(p string) bool { return existing[p] })
+}
+
+// preflightState returns the state the package manager runs its commands in.
+func preflightState(config *buildkit.Config) llb.State {
+	if config.PatchedConfigData != nil {
+		return config.PatchedImageState
+	}
+	return config.ImageState
+}
+
+// missingToolError reports that command, which the package manager runs in the
+// image, is not in it.
+func missingToolError(name, command string) error {
+	where := "in " + strings.Join(toolDirs, ", ")
+	if path.IsAbs(command) {
+		where = "at " + command
+	}
+	err := fmt.Errorf("%w: %s was not found %s", types.ErrPackageManagerNotFound, name, where)
+	if slices.Contains(PkgMgrPathCommands(), name) && !path.IsAbs(command) {
+		err = fmt.Errorf("%w; pass --pkgmgr-path %s=<path> if it is installed elsewhere", err, name)
+	}
+	return err
+}
+
+// preflightToolImage checks that one of images, the tooling images tried in turn
+// for an image without its own package manager, can be resolved for its platform.
+func preflightToolImage(ctx context.Context, config *buildkit.Config, missing string, images ...string) error {
+	platform, err := config.ImageState.GetPlatform(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get image platform %w", err)
+	}
+	for _, image := range images {
+		if _, err = tryImage(ctx, image, config.Client, platform); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the image has no %s and the tooling image used in its place could not be resolved: %w",
+		types.ErrPackageManagerNotFound, missing, err)
+}
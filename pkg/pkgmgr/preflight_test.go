@@ -0,0 +1,145 @@
+package pkgmgr
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+
+	"github.com/project-copacetic/copacetic/mocks"
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+// preflightConfig returns a config whose image has exactly the files in existing.
+// Solves after the first, such as those resolving a tooling image, fail when
+// toolImageErr is set.
+func preflightConfig(existing []string, toolImageErr error, pkgMgrPaths map[string]string) *buildkit.Config {
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	if toolImageErr != nil {
+		mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil).Once()
+		mockClient.On("Solve", mock.Anything, mock.Anything).Return((*gwclient.Result)(nil), toolImageErr)
+	} else {
+		mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	}
+	for _, path := range existing {
+		mockRef.On("StatFile", mock.Anything, gwclient.StatRequest{Path: path}).Return(&fstypes.Stat{Path: path}, nil)
+	}
+	mockRef.On("StatFile", mock.Anything, mock.Anything).Return((*fstypes.Stat)(nil), errors.New("stat: no such file or directory"))
+
+	return &buildkit.Config{
+		Client:      mockClient,
+		ImageState:  llb.Image("example.com/app:1.0", llb.LinuxAmd64),
+		PkgMgrPaths: pkgMgrPaths,
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	unresolvable := errors.New("failed to resolve tooling image")
+
+	tests := []struct {
+		name    string
+		manager func(*buildkit.Config) PackageManager
+		files   []string
+		paths   map[string]string
+		toolErr error
+		wantErr string
+	}{
+		{
+			name:    "apk present",
+			manager: func(c *buildkit.Config) PackageManager { return &apkManager{config: c} },
+			files:   []string{apkInstalledDB, "/sbin/apk"},
+		},
+		{
+			name:    "apk missing",
+			manager: func(c *buildkit.Config) PackageManager { return &apkManager{config: c} },
+			files:   []string{apkInstalledDB},
+			wantErr: "apk was not found in /usr/local/sbin, /usr/local/bin, /usr/sbin, /usr/bin, /sbin, /bin; pass --pkgmgr-path apk=<path>",
+		},
+		{
+			name:    "apk at configured path",
+			manager: func(c *buildkit.Config) PackageManager { return &apkManager{config: c} },
+			files:   []string{apkInstalledDB, "/opt/apk"},
+			paths:   map[string]string{binaryAPK: "/opt/apk"},
+		},
+		{
+			name:    "apk missing at configured path",
+			manager: func(c *buildkit.Config) PackageManager { return &apkManager{config: c} },
+			files:   []string{apkInstalledDB, "/sbin/apk"},
+			paths:   map[string]string{binaryAPK: "/opt/apk"},
+			wantErr: "apk was not found at /opt/apk",
+		},
+		{
+			name: "apt-get missing",
+			manager: func(c *buildkit.Config) PackageManager {
+				return &dpkgManager{config: c, osType: "debian", osVersion: "12"}
+			},
+			files:   []string{dpkgStatusPath, "/usr/bin/dpkg"},
+			wantErr: "apt-get was not found",
+		},
+		{
+			name: "distroless with tooling image",
+			manager: func(c *buildkit.Config) PackageManager {
+				return &dpkgManager{config: c, osType: "debian", osVersion: "12"}
+			},
+			files: []string{dpkgStatusFolder},
+		},
+		{
+			name: "distroless without tooling image",
+			manager: func(c *buildkit.Config) PackageManager {
+				return &dpkgManager{config: c, osType: "debian", osVersion: "12"}
+			},
+			files:   []string{dpkgStatusFolder},
+			toolErr: unresolvable,
+			wantErr: "the image has no apt-get and the tooling image used in its place could not be resolved",
+		},
+		{
+			name:    "pacman missing",
+			manager: func(c *buildkit.Config) PackageManager { return &pacmanManager{config: c} },
+			files:   []string{"/bin/sh"},
+			wantErr: "pacman was not found at /usr/bin/pacman",
+		},
+		{
+			name: "rpm tools present",
+			manager: func(c *buildkit.Config) PackageManager {
+				return &rpmManager{config: c, osType: "rhel", osVersion: "9.4"}
+			},
+			files: []string{"/var/lib/rpm/rpmdb.sqlite", "/usr/bin/dnf", "/usr/bin/rpm"},
+		},
+		{
+			name: "rpm tools missing without tooling image",
+			manager: func(c *buildkit.Config) PackageManager {
+				return &rpmManager{config: c, osType: "rhel", osVersion: "9.4"}
+			},
+			files:   []string{"/var/lib/rpm/rpmdb.sqlite", "/usr/bin/rpm"},
+			toolErr: unresolvable,
+			wantErr: "the image has no RPM package manager",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manager(preflightConfig(tt.files, tt.toolErr, tt.paths)).Preflight(context.TODO())
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, types.ErrPackageManagerNotFound)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("no package database", func(t *testing.T) {
+		err := (&apkManager{config: preflightConfig([]string{"/sbin/apk"}, nil, nil)}).Preflight(context.TODO())
+		require.ErrorIs(t, err, types.ErrNoPackageDatabase)
+	})
+}
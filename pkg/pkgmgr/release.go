@@ -0,0 +1,84 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	debReleasePattern    = regexp.MustCompile(`[~+]deb(\d+)u\d+`)
+	ubuntuReleasePattern = regexp.MustCompile(`(?:ubuntu\d+\.|~)(\d{2}\.\d{2})(?:\.\d+)*$`)
+	elReleasePattern     = regexp.MustCompile(`\.el(\d+)`)
+)
+
+// releaseMarkers maps an OS type to the pattern that extracts the distro release a
+// package build targets from its version, e.g. 3.0.11-1~deb12u2 is built for Debian 12
+// and 1.2.3-1ubuntu0.22.04.1 for Ubuntu 22.04. Alpine's -rN suffix is a package
+// revision rather than a release marker, so apk versions are not checked.
+var releaseMarkers = map[string]*regexp.Regexp{
+	utils.OSTypeDebian:     debReleasePattern,
+	utils.OSTypeUbuntu:     ubuntuReleasePattern,
+	utils.OSTypeRedHat:     elReleasePattern,
+	utils.OSTypeCentOS:     elReleasePattern,
+	utils.OSTypeRocky:      elReleasePattern,
+	utils.OSTypeAlma:       elReleasePattern,
+	utils.OSTypeAlmaLinux:  elReleasePattern,
+	utils.OSTypeOracle:     elReleasePattern,
+	utils.OSTypeAmazon:     regexp.MustCompile(`\.amzn(\d+)`),
+	utils.OSTypeCBLMariner: regexp.MustCompile(`\.cm(\d+)`),
+	utils.OSTypeAzureLinux: regexp.MustCompile(`\.azl(\d+)`),
+}
+
+var (
+	majorReleasePattern  = regexp.MustCompile(`^\d+`)
+	ubuntuVersionPattern = regexp.MustCompile(`^\d{2}\.\d{2}`)
+)
+
+// imageRelease returns the part of the image's OS version that release markers are
+// compared with: YY.MM for Ubuntu, the major version otherwise.
+func imageRelease(osType, osVersion string) string {
+	if osType == utils.OSTypeUbuntu {
+		return ubuntuVersionPattern.FindString(osVersion)
+	}
+	return majorReleasePattern.FindString(osVersion)
+}
+
+// releaseMismatch returns why fixedVersion cannot be installed on an osType image at
+// osVersion, or "" if the version is built for the image's release or carries no
+// release marker.
+func releaseMismatch(osType, osVersion, fixedVersion string) string {
+	pattern, ok := releaseMarkers[osType]
+	if !ok {
+		return ""
+	}
+	m := pattern.FindStringSubmatch(fixedVersion)
+	if m == nil {
+		return ""
+	}
+	release := imageRelease(osType, osVersion)
+	if release == "" || m[1] == release {
+		return ""
+	}
+	return fmt.Sprintf("fixed version %s is built for %s %s, image is %s %s", fixedVersion, osType, m[1], osType, release)
+}
+
+// skipReleaseMismatches removes updates whose fixed version targets a different
+// distro release than the image, such as a Debian 12 security update reported for a
+// Debian 11 image. It returns the updates to install and the names of those skipped.
+func skipReleaseMismatches(osType, osVersion string, updates unversioned.UpdatePackages) (unversioned.UpdatePackages, []string) {
+	var skipped []string
+	kept := make(unversioned.UpdatePackages, 0, len(updates))
+	for _, u := range updates {
+		if reason := releaseMismatch(osType, osVersion, u.FixedVersion); reason != "" {
+			log.Warnf("Skipping %s: %s", u.Name, reason)
+			skipped = append(skipped, u.Name)
+			continue
+		}
+		kept = append(kept, u)
+	}
+	return kept, skipped
+}
@@ -0,0 +1,60 @@
+package pkgmgr
+
+import (
+	"testing"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseMismatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		osType       string
+		osVersion    string
+		fixedVersion string
+		wantMismatch bool
+	}{
+		{"debian 12 fix on debian 11", utils.OSTypeDebian, "11.8", "3.0.13-1~deb12u1", true},
+		{"debian 11 fix on debian 11", utils.OSTypeDebian, "11.8", "1.1.1w-0+deb11u1", false},
+		{"debian fix without release marker", utils.OSTypeDebian, "11.8", "2.36-9", false},
+		{"ubuntu 22.04 fix on 20.04", utils.OSTypeUbuntu, "20.04", "3.0.2-0ubuntu1.15~22.04.1", true},
+		{"ubuntu security update for image release", utils.OSTypeUbuntu, "22.04", "1.2.11.dfsg-2ubuntu0.22.04.1", false},
+		{"ubuntu fix without release marker", utils.OSTypeUbuntu, "22.04", "2.35-0ubuntu3.6", false},
+		{"el8 fix on el9", utils.OSTypeRedHat, "9.3", "3.0.7-25.el8", true},
+		{"el9 fix on el9 with minor release", utils.OSTypeRocky, "9.3", "3.0.7-25.el9_3", false},
+		{"amazon 2 fix on 2023", utils.OSTypeAmazon, "2023.3.20240108", "1.0.2k-24.amzn2.0.9", true},
+		{"azure linux 3 fix on azure linux 3", utils.OSTypeAzureLinux, "3.0.20240727", "3.3.0-3.azl3", false},
+		{"mariner 1 fix on mariner 2", utils.OSTypeCBLMariner, "2.0.20240123", "1.1.1k-2.cm1", true},
+		{"alpine revision is not a release marker", utils.OSTypeAlpine, "3.19.1", "3.1.4-r5", false},
+		{"unknown image version", utils.OSTypeDebian, "", "3.0.13-1~deb12u1", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reason := releaseMismatch(tc.osType, tc.osVersion, tc.fixedVersion)
+			if tc.wantMismatch {
+				assert.NotEmpty(t, reason)
+				assert.Contains(t, reason, tc.fixedVersion)
+			} else {
+				assert.Empty(t, reason)
+			}
+		})
+	}
+}
+
+func TestSkipReleaseMismatches(t *testing.T) {
+	updates := unversioned.UpdatePackages{
+		{Name: "libssl1.1", FixedVersion: "1.1.1w-0+deb11u1"},
+		{Name: "libssl3", FixedVersion: "3.0.13-1~deb12u1"},
+		{Name: "tzdata", FixedVersion: "2024a-0+deb11u1"},
+	}
+
+	kept, skipped := skipReleaseMismatches(utils.OSTypeDebian, "11.8", updates)
+	assert.Equal(t, unversioned.UpdatePackages{
+		{Name: "libssl1.1", FixedVersion: "1.1.1w-0+deb11u1"},
+		{Name: "tzdata", FixedVersion: "2024a-0+deb11u1"},
+	}, kept)
+	assert.Equal(t, []string{"libssl3"}, skipped)
+}
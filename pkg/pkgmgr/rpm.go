@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"unicode"
@@ -35,6 +36,8 @@ const (
 	rpmManifest1        = "container-manifest-1"
 	rpmManifest2        = "container-manifest-2"
 	rpmManifestWildcard = "container-manifest-*"
+	amazonLinux2        = "2"
+	amazonLinux2023     = "2023"
 	falseConst          = "false"
 	trueConst           = "true"
 
@@ -48,7 +51,8 @@ type rpmManager struct {
 	workingFolder  string
 	rpmTools       rpmToolPaths
 	isDistroless   bool
-	isMissingTools bool // image has valid RPM DB but no package manager tools
+	isMissingTools bool   // image has valid RPM DB but no package manager tools
+	forcedTool     string // dnf or yum, as chosen with --force-pkg-manager (empty = detect)
 	packageInfo    map[string]string
 	osType         string
 	osVersion      string
@@ -179,6 +183,9 @@ func getChrootToolImage(osType string, osVersion string) string {
 	case utils.OSTypeAlma, utils.OSTypeAlmaLinux:
 		return fmt.Sprintf("almalinux:%s", majorVersion)
 	case utils.OSTypeAmazon:
+		if gen := amazonLinuxGeneration(osVersion); gen != "" {
+			return fmt.Sprintf("amazonlinux:%s", gen)
+		}
 		return fmt.Sprintf("amazonlinux:%s", osVersion)
 	case utils.OSTypeOracle:
 		return fmt.Sprintf("oraclelinux:%s", majorVersion)
@@ -190,27 +197,158 @@ func getChrootToolImage(osType string, osVersion string) string {
 	}
 }
 
+// amazonLinuxGeneration returns "2" or "2023" for the Amazon Linux version reported by
+// scanners (e.g. "2", "2 (Karoo)", "2023", "2023.3.20240108"), or "" if unrecognized.
+func amazonLinuxGeneration(osVersion string) string {
+	major := strings.SplitN(strings.TrimSpace(osVersion), " ", 2)[0]
+	major = strings.SplitN(major, ".", 2)[0]
+	switch major {
+	case amazonLinux2, amazonLinux2023:
+		return major
+	default:
+		return ""
+	}
+}
+
+// amazonLinuxPackageTool returns the package manager shipped with the given
+// Amazon Linux version: yum on AL2 and dnf on AL2023.
+func amazonLinuxPackageTool(osVersion string) string {
+	if amazonLinuxGeneration(osVersion) == amazonLinux2 {
+		return "yum"
+	}
+	return "dnf"
+}
+
+// selectAmazonLinuxTools narrows the probed tools to the package manager matching
+// the Amazon Linux version, so AL2 is never patched with dnf and AL2023 never with yum.
+func selectAmazonLinuxTools(tools rpmToolPaths, osVersion string) rpmToolPaths {
+	selected := rpmToolPaths{}
+	for k, v := range tools {
+		selected[k] = v
+	}
+
+	switch amazonLinuxGeneration(osVersion) {
+	case amazonLinux2:
+		if selected["yum"] != "" {
+			delete(selected, "tdnf")
+			delete(selected, "dnf")
+			delete(selected, "microdnf")
+		}
+	case amazonLinux2023:
+		if selected["dnf"] != "" {
+			delete(selected, "yum")
+		}
+	}
+	return selected
+}
+
+// rpmReleaseVer returns the value passed to --releasever for chroot-based patching.
+func rpmReleaseVer(osType, osVersion string) string {
+	if osType == utils.OSTypeAmazon {
+		switch amazonLinuxGeneration(osVersion) {
+		case amazonLinux2:
+			return amazonLinux2
+		case amazonLinux2023:
+			return "latest"
+		}
+	}
+
+	// Derive the release version (major.minor) for dnf --releasever
+	parts := strings.Split(osVersion, ".")
+	if len(parts) >= 2 {
+		return parts[0] + "." + parts[1]
+	}
+	return osVersion
+}
+
+// canonicalRPMTool maps a package manager binary name to the tool whose code path
+// should drive it, e.g. "dnf-3" and "dnf5" to "dnf". It returns "" for unknown binaries.
+func canonicalRPMTool(binary string) string {
+	base := filepath.Base(binary)
+	for _, tool := range []string{"microdnf", "tdnf", "dnf", "yum"} {
+		if strings.HasPrefix(base, tool) {
+			return tool
+		}
+	}
+	if base == "rpm" {
+		return "rpm"
+	}
+	return ""
+}
+
 func parseRPMTools(b []byte) (rpmToolPaths, error) {
 	buf := bytes.NewBuffer(b)
 	// rpmTools file is expected contain a string map in the format of:
-	// <tool name>:<tool path | `notfound`>
+	// <tool name>:<tool path | `notfound`>[:<resolved symlink target>]
 	// ...
 	rpmTools := rpmToolPaths{}
+	resolved := map[string]string{}
 	fs := bufio.NewScanner(buf)
 	for fs.Scan() {
 		kv := strings.Split(fs.Text(), `:`)
-		if len(kv) != 2 {
+		if len(kv) != 2 && len(kv) != 3 {
 			err := fmt.Errorf("unexpected %s file entry: %s", rpmToolsFile, fs.Text())
 			log.Error(err)
 			return nil, err
 		}
 		if kv[1] != "notfound" && kv[1] != "" {
 			rpmTools[kv[0]] = kv[1]
+			if len(kv) == 3 {
+				resolved[kv[0]] = kv[2]
+			}
+		}
+	}
+
+	// A tool that is a symlink to another package manager (e.g. yum -> dnf-3 on
+	// RHEL 8 based images) must take that package manager's code path.
+	for tool, target := range resolved {
+		canonical := canonicalRPMTool(target)
+		if canonical == "" || canonical == tool {
+			continue
 		}
+		log.Debugf("%s at %s is a symlink to %s; treating it as %s", tool, rpmTools[tool], target, canonical)
+		if _, ok := rpmTools[canonical]; !ok {
+			rpmTools[canonical] = rpmTools[tool]
+		}
+		delete(rpmTools, tool)
 	}
 	return rpmTools, nil
 }
 
+// selectForcedRPMTool narrows tools to the package manager forced with
+// --force-pkg-manager, failing if the image doesn't have it rather than falling
+// back to another tool or to chroot-based patching.
+func selectForcedRPMTool(tools rpmToolPaths, tool string) (rpmToolPaths, error) {
+	if tools[tool] == "" {
+		var found []string
+		for _, t := range []string{"tdnf", "dnf", "yum", "microdnf"} {
+			if tools[t] != "" {
+				found = append(found, t)
+			}
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("--force-pkg-manager=%s: %s was not found in the image", tool, tool)
+		}
+		return nil, fmt.Errorf("--force-pkg-manager=%s: %s was not found in the image, which has %s", tool, tool, strings.Join(found, ", "))
+	}
+	selected := rpmToolPaths{tool: tools[tool]}
+	if tools["rpm"] != "" {
+		selected["rpm"] = tools["rpm"]
+	}
+	return selected, nil
+}
+
+// selectRPMTool returns the package manager used for patching and its path, in order
+// of preference: tdnf, dnf, yum, then microdnf. It returns "" if none are available.
+func selectRPMTool(tools rpmToolPaths) (string, string) {
+	for _, tool := range []string{"tdnf", "dnf", "yum", "microdnf"} {
+		if path := tools[tool]; path != "" {
+			return tool, path
+		}
+	}
+	return "", ""
+}
+
 // Check the RPM DB type given image probe results.
 func getRPMDBType(b []byte) rpmDBType {
 	buf := bytes.NewBuffer(b)
@@ -251,9 +389,76 @@ func getRPMDBType(b []byte) rpmDBType {
 	}
 }
 
+// rpmPackageManagers are the RPM tools probed for in the image.
+var rpmPackageManagers = []string{"tdnf", "dnf", "microdnf", "yum", "rpm"}
+
+// rpmLibDBPaths returns the locations of the databases rpm itself may keep in an image.
+func rpmLibDBPaths() []string {
+	return []string{
+		filepath.Join(rpmLibPath, rpmBDB),
+		filepath.Join(rpmLibPath, rpmNDB),
+		filepath.Join(rpmLibPath, rpmSQLLiteDB),
+	}
+}
+
+// rpmManifestPaths returns the locations of the container manifests that list the
+// packages of distroless images without an rpm database.
+func rpmManifestPaths() []string {
+	return []string{
+		filepath.Join(rpmManifestPath, rpmManifest1),
+		filepath.Join(rpmManifestPath, rpmManifest2),
+	}
+}
+
+// rpmDBPaths returns the locations of the RPM databases an image may have, the
+// container manifests last.
+func rpmDBPaths() []string {
+	return append(rpmLibDBPaths(), rpmManifestPaths()...)
+}
+
+func (rm *rpmManager) Preflight(ctx context.Context) error {
+	manifest := &unversioned.UpdateManifest{Metadata: unversioned.Metadata{OS: unversioned.OS{Type: rm.osType, Version: rm.osVersion}}}
+	toolImages := []string{getRPMImageName(manifest, rm.osType, rm.osVersion, true), getRPMImageName(manifest, rm.osType, rm.osVersion, false)}
+	// SUSE images are always patched with zypper from a tooling image
+	if utils.IsSUSEImage(rm.osType) {
+		return preflightToolImage(ctx, rm.config, "zypper", toolImages...)
+	}
+
+	st := preflightState(rm.config)
+	libDBPaths, manifestPaths := rpmLibDBPaths(), rpmManifestPaths()
+	paths := rpmDBPaths()
+	for _, tool := range rpmPackageManagers {
+		paths = append(paths, toolPaths(tool)...)
+	}
+	existing, err := buildkit.ExistingFilesInState(ctx, rm.config.Client, &st, paths)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case slices.ContainsFunc(libDBPaths, func(p string) bool { return existing[p] }):
+		tools := rpmToolPaths{}
+		for _, tool := range rpmPackageManagers {
+			if hasTool(existing, tool) {
+				tools[tool] = tool
+			}
+		}
+		if tool, _ := selectRPMTool(tools); tool != "" && tools["rpm"] != "" {
+			return nil
+		}
+		return preflightToolImage(ctx, rm.config, "RPM package manager", getChrootToolImage(rm.osType, rm.osVersion))
+	case slices.ContainsFunc(manifestPaths, func(p string) bool { return existing[p] }):
+		// Distroless images are patched with the tools of a tooling image
+		return preflightToolImage(ctx, rm.config, "RPM package manager", toolImages...)
+	default:
+		return noPackageDatabaseError(rpmDBPaths()...)
+	}
+}
+
 func (rm *rpmManager) InstallUpdates(ctx context.Context, manifest *unversioned.UpdateManifest, ignoreErrors bool) (*llb.State, []string, error) {
 	// Resolve set of unique packages to update if UpdateManifest provided, else update all
 	var updates unversioned.UpdatePackages
+	var skipped []string
 	var rpmComparer VersionComparer
 	var err error
 
@@ -269,9 +474,10 @@ func (rm *rpmManager) InstallUpdates(ctx context.Context, manifest *unversioned.
 		if err != nil {
 			return nil, nil, err
 		}
+		updates, skipped = skipReleaseMismatches(rm.osType, rm.osVersion, updates)
 		if len(updates) == 0 {
 			log.Warn("No update packages were specified to apply")
-			return &rm.config.ImageState, nil, nil
+			return &rm.config.ImageState, skipped, nil
 		}
 		log.Debugf("latest unique RPMs: %v", updates)
 	}
@@ -328,7 +534,7 @@ func (rm *rpmManager) InstallUpdates(ctx context.Context, manifest *unversioned.
 		}
 	}
 
-	return updatedImageState, errPkgs, nil
+	return updatedImageState, append(errPkgs, skipped...), nil
 }
 
 func (rm *rpmManager) probeRPMStatus(ctx context.Context, toolImage string, platform *ocispecs.Platform) error {
@@ -357,11 +563,9 @@ func (rm *rpmManager) probeRPMStatus(ctx context.Context, toolImage string, plat
 		return err
 	}
 
-	packageManagers := []string{"tdnf", "dnf", "microdnf", "yum", "rpm"}
-
 	toolsInstalled := toolingBase.Run(
 		llb.Shlex(installToolsCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 		llb.WithCustomName("Installing package management tools"),
 	).Root()
 	toolsApplied := imageStateCurrent.File(llb.Copy(toolsInstalled, "/usr/sbin/busybox", "/usr/sbin/busybox"))
@@ -369,18 +573,12 @@ func (rm *rpmManager) probeRPMStatus(ctx context.Context, toolImage string, plat
 		File(llb.Mkdir(resultsPath, 0o744, llb.WithParents(true))).
 		File(llb.Mkdir(inputPath, 0o744, llb.WithParents(true)))
 
-	rpmDBList := []string{
-		filepath.Join(rpmLibPath, rpmBDB),
-		filepath.Join(rpmLibPath, rpmNDB),
-		filepath.Join(rpmLibPath, rpmSQLLiteDB),
-		filepath.Join(rpmManifestPath, rpmManifest1),
-		filepath.Join(rpmManifestPath, rpmManifest2),
-	}
+	rpmDBList := rpmDBPaths()
 
 	toolListPath := filepath.Join(inputPath, "tool_list")
 	dbListPath := filepath.Join(inputPath, "rpm_db_list")
 
-	probed := buildkit.WithArrayFile(&mkFolders, toolListPath, packageManagers)
+	probed := buildkit.WithArrayFile(&mkFolders, toolListPath, rpmPackageManagers)
 	probed = buildkit.WithArrayFile(&probed, dbListPath, rpmDBList)
 	outState := probed.Run(
 		llb.AddEnv("TOOL_LIST_PATH", toolListPath),
@@ -393,7 +591,11 @@ func (rm *rpmManager) probeRPMStatus(ctx context.Context, toolImage string, plat
 			`/usr/sbin/busybox`, `sh`, `-c`, `
                 while IFS= read -r tool; do
                     tool_path="$($BUSYBOX which "$tool")"
-                    echo "${tool}:${tool_path:-notfound}" >> "${RESULTS_PATH}/${RPM_TOOLS_OUTPUT_FILENAME}"
+                    if [ -n "$tool_path" ]; then
+                        echo "${tool}:${tool_path}:$($BUSYBOX readlink -f "$tool_path")" >> "${RESULTS_PATH}/${RPM_TOOLS_OUTPUT_FILENAME}"
+                    else
+                        echo "${tool}:notfound" >> "${RESULTS_PATH}/${RPM_TOOLS_OUTPUT_FILENAME}"
+                    fi
                 done < "$TOOL_LIST_PATH"
 
                 while IFS= read -r db; do
@@ -427,12 +629,18 @@ func (rm *rpmManager) probeRPMStatus(ctx context.Context, toolImage string, plat
 			return err
 		}
 		rm.packageInfo = pkgInfo
-	case RPMDBNone, RPMDBMixed:
+	case RPMDBNone:
+		return noPackageDatabaseError(rpmDBList...)
+	case RPMDBMixed:
 		err := fmt.Errorf("could not find determine RPM DB type of target image: %v", rpmDB)
 		log.Error(err)
 		return err
 	}
 
+	if rm.isDistroless && rm.forcedTool != "" {
+		return fmt.Errorf("--force-pkg-manager=%s: the image is distroless and has no %s", rm.forcedTool, rm.forcedTool)
+	}
+
 	// Parse rpmTools File if not distroless
 	if !rm.isDistroless {
 		log.Info("Checking for available RPM tools in non-distroless image ...")
@@ -446,10 +654,15 @@ func (rm *rpmManager) probeRPMStatus(ctx context.Context, toolImage string, plat
 		if err != nil {
 			return err
 		}
+		if rm.forcedTool != "" {
+			if rpmTools, err = selectForcedRPMTool(rpmTools, rm.forcedTool); err != nil {
+				return err
+			}
+		}
 
 		// If the image has no package managers or no rpm tool, fall back to
 		// chroot-based patching via the tooling image instead of failing.
-		if rpmTools["tdnf"] == "" && rpmTools["dnf"] == "" && rpmTools["yum"] == "" && rpmTools["microdnf"] == "" {
+		if tool, _ := selectRPMTool(rpmTools); tool == "" {
 			log.Warn("image contains no RPM package managers; will use chroot-based patching via tooling image")
 			rm.isMissingTools = true
 			return nil
@@ -460,7 +673,12 @@ func (rm *rpmManager) probeRPMStatus(ctx context.Context, toolImage string, plat
 			return nil
 		}
 
+		if rm.osType == utils.OSTypeAmazon {
+			rpmTools = selectAmazonLinuxTools(rpmTools, rm.osVersion)
+		}
 		rm.rpmTools = rpmTools
+		tool, toolPath := selectRPMTool(rpmTools)
+		log.Debugf("Detected RPM tools %v; %s (%s) will be used for patching", rpmTools, tool, toolPath)
 	}
 	return nil
 }
@@ -528,6 +746,38 @@ func parseManifestFile(file string) (map[string]string, error) {
 	return resultMap, nil
 }
 
+// rpmInstallTargets returns the install targets for updates. On CBL-Mariner and Azure
+// Linux an update whose fixed version has the release's dist tag (e.g. 1.1.1k-28.cm2)
+// is installed as that exact name-version-release, so tdnf installs the build the
+// report names. Other distros, untagged versions and the InstalledOnly packages of
+// --update-all install by name.
+func rpmInstallTargets(osType string, updates unversioned.UpdatePackages) []string {
+	distTag, ok := releaseMarkers[osType]
+	if !ok || (osType != utils.OSTypeCBLMariner && osType != utils.OSTypeAzureLinux) {
+		return installPackageNames(updates)
+	}
+	targets := make([]string, 0, len(updates))
+	for _, u := range updates {
+		if u.Status != unversioned.InstalledOnly && distTag.MatchString(u.FixedVersion) && validOSPackageNamePattern.MatchString(u.FixedVersion) {
+			targets = append(targets, u.Name+"-"+u.FixedVersion)
+			continue
+		}
+		targets = append(targets, u.Name)
+	}
+	return targets
+}
+
+// dnfDownloadOptions returns the dnf and yum options that keep at most maxDownloads
+// package downloads in flight, or nothing when maxDownloads is not set. dnf rejects
+// values above 20, and yum before version 4 warns about the option and ignores it.
+func dnfDownloadOptions(maxDownloads int) string {
+	if maxDownloads <= 0 {
+		return ""
+	}
+	maxDownloads = min(maxDownloads, 20)
+	return fmt.Sprintf(" --setopt=max_parallel_downloads=%d", maxDownloads)
+}
+
 // Patch a regular RPM-based image with:
 //   - sh and an appropriate tool installed on the image (yum, dnf, microdnf)
 //   - valid rpm database on the image
@@ -540,28 +790,32 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 
 	pkgs := ""
 
-	imageStateCurrent := rm.config.ImageState
-	if rm.config.PatchedConfigData != nil {
-		imageStateCurrent = rm.config.PatchedImageState
+	imageStateCurrent := rm.installBaseState()
+	// Diff against the state the install runs on so a mirror override stays out of the patch
+	diffBase := rm.config.ImageState
+	if rm.config.RepoMirrors[repoTypeRPM] != "" {
+		diffBase = imageStateCurrent
 	}
 
 	// If specific updates provided, parse into pkg names, else will update all
 	if updates != nil {
 		// Format the requested updates into a space-separated string
-		pkgStrings := []string{}
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
-		}
+		pkgStrings := rpmInstallTargets(rm.osType, updates)
 		pkgs = strings.Join(pkgStrings, " ")
 	}
 
 	// Install patches using available rpm managers in order of preference
+	tool, toolPath := selectRPMTool(rm.rpmTools)
+	log.Debugf("Selected %s (%s) to install RPM updates", tool, toolPath)
+
 	var installCmd string
-	switch {
-	case rm.rpmTools["tdnf"] != "" || rm.rpmTools["dnf"] != "":
-		dnfTooling := rm.rpmTools["tdnf"]
-		if dnfTooling == "" {
-			dnfTooling = rm.rpmTools["dnf"]
+	switch tool {
+	case "tdnf", "dnf":
+		dnfTooling := toolPath
+		// AL2023 locks dnf to the release the image was built from; security
+		// updates are only published to the latest release repos.
+		if rm.osType == utils.OSTypeAmazon && amazonLinuxGeneration(rm.osVersion) == amazonLinux2023 {
+			dnfTooling += " --releasever=latest"
 		}
 		if updates == nil {
 			checkUpdateTemplate := `sh -c '%[1]s clean all && %[1]s makecache --refresh -y; if [ "$(%[1]s -q check-update | wc -l)" -ne 0 ]; then echo >> /updates.txt; fi'`
@@ -573,12 +827,12 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 			}
 		}
 
-		const dnfInstallTemplate = `sh -c '%[1]s upgrade --refresh %[2]s -y && %[1]s clean all'`
-		installCmd = fmt.Sprintf(dnfInstallTemplate, dnfTooling, pkgs)
-	case rm.rpmTools["yum"] != "":
+		const dnfInstallTemplate = `%[1]s upgrade --refresh%[3]s %[2]s -y && %[1]s clean all`
+		installCmd = fmt.Sprintf(dnfInstallTemplate, dnfTooling, pkgs, dnfDownloadOptions(rm.config.MaxConcurrentDownloads))
+	case "yum":
 		if updates == nil {
 			checkUpdateTemplate := `sh -c '%[1]s clean all && %[1]s makecache fast; if [ "$(%[1]s -q check-update | wc -l)" -ne 0 ]; then echo >> /updates.txt; fi'`
-			if err := rm.checkForUpgrades(ctx, rm.rpmTools["yum"], checkUpdateTemplate); err != nil {
+			if err := rm.checkForUpgrades(ctx, toolPath, checkUpdateTemplate); err != nil {
 				if !errors.Is(err, types.ErrNoUpdatesFound) {
 					return nil, nil, fmt.Errorf("failed while checking for available rpm updates: %w", err)
 				}
@@ -586,12 +840,12 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 			}
 		}
 
-		const yumInstallTemplate = `sh -c '%[1]s upgrade %[2]s -y && %[1]s clean all'`
-		installCmd = fmt.Sprintf(yumInstallTemplate, rm.rpmTools["yum"], pkgs)
-	case rm.rpmTools["microdnf"] != "":
+		const yumInstallTemplate = `%[1]s upgrade%[3]s %[2]s -y && %[1]s clean all`
+		installCmd = fmt.Sprintf(yumInstallTemplate, toolPath, pkgs, dnfDownloadOptions(rm.config.MaxConcurrentDownloads))
+	case "microdnf":
 		if updates == nil {
 			checkUpdateTemplate := `sh -c "%[1]s install dnf -y; dnf clean all && dnf makecache --refresh -y;  dnf check-update -y; if [ $? -ne 0 ]; then echo >> /updates.txt; fi;"`
-			if err := rm.checkForUpgrades(ctx, rm.rpmTools["microdnf"], checkUpdateTemplate); err != nil {
+			if err := rm.checkForUpgrades(ctx, toolPath, checkUpdateTemplate); err != nil {
 				if !errors.Is(err, types.ErrNoUpdatesFound) {
 					return nil, nil, fmt.Errorf("failed while checking for available rpm updates: %w", err)
 				}
@@ -599,8 +853,8 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 			}
 		}
 
-		const microdnfInstallTemplate = `sh -c '%[1]s update %[2]s -y && %[1]s clean all'`
-		installCmd = fmt.Sprintf(microdnfInstallTemplate, rm.rpmTools["microdnf"], pkgs)
+		const microdnfInstallTemplate = `%[1]s update %[2]s -y && %[1]s clean all`
+		installCmd = fmt.Sprintf(microdnfInstallTemplate, toolPath, pkgs)
 	default:
 		err := errors.New("unexpected: no package manager tools were found for patching")
 		return nil, nil, err
@@ -611,9 +865,13 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 		customName = fmt.Sprintf("Installing security updates for %d packages", len(updates))
 	}
 
+	installRun := buildkit.Sh(installCmd)
+	if updates != nil {
+		installRun = guardedInstall(installCmd, rpmNotFoundPattern)
+	}
 	installed := imageStateCurrent.Run(
-		llb.Shlex(installCmd),
-		llb.WithProxy(utils.GetProxy()),
+		installRun,
+		utils.WithProxy(),
 		llb.WithCustomName(customName),
 	).Root()
 
@@ -634,7 +892,7 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 		var err error
 		resultBytes, err = buildkit.ExtractFileFromState(ctx, rm.config.Client, &resultsWritten, resultManifest)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, classifyInstallError(err)
 		}
 	}
 
@@ -644,7 +902,7 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 		prevPatchDiff := llb.Diff(rm.config.ImageState, rm.config.PatchedImageState)
 
 		// Diff the base image and new patches
-		newPatchDiff := llb.Diff(rm.config.ImageState, installed)
+		newPatchDiff := llb.Diff(diffBase, installed)
 
 		// Merging these two diffs will discard everything in the filesystem that hasn't changed
 		// Doing llb.Scratch ensures we can keep everything in the filesystem that has not changed
@@ -658,17 +916,27 @@ func (rm *rpmManager) installUpdates(ctx context.Context, updates unversioned.Up
 	}
 
 	// Diff the installed updates and merge that into the target image
-	patchDiff := llb.Diff(rm.config.ImageState, installed)
+	patchDiff := llb.Diff(diffBase, installed)
 	patchMerge := llb.Merge([]llb.State{rm.config.ImageState, patchDiff})
 
 	return &patchMerge, resultBytes, nil
 }
 
-func (rm *rpmManager) checkForUpgrades(ctx context.Context, toolPath, checkUpdateTemplate string) error {
-	imageStateCurrent := rm.config.ImageState
+// installBaseState returns the state package manager commands run on: the image (or
+// its previously patched state) with any configured repository mirror applied.
+func (rm *rpmManager) installBaseState() llb.State {
+	st := rm.config.ImageState
 	if rm.config.PatchedConfigData != nil {
-		imageStateCurrent = rm.config.PatchedImageState
+		st = rm.config.PatchedImageState
 	}
+	if mirror := rm.config.RepoMirrors[repoTypeRPM]; mirror != "" {
+		st = withRPMMirror(st, mirror)
+	}
+	return st
+}
+
+func (rm *rpmManager) checkForUpgrades(ctx context.Context, toolPath, checkUpdateTemplate string) error {
+	imageStateCurrent := rm.installBaseState()
 
 	checkUpdate := fmt.Sprintf(checkUpdateTemplate, toolPath)
 	stateWithCheck := imageStateCurrent.Run(
@@ -708,6 +976,10 @@ func (rm *rpmManager) unpackAndMergeUpdates(ctx context.Context, updates unversi
 		}
 		log.Debugf("Successfully resolved tooling image %s using host platform", toolImage)
 	}
+	// tdnf downloads the updates with the tooling image's repositories
+	if mirror := rm.config.RepoMirrors[repoTypeRPM]; mirror != "" {
+		toolingBase = withRPMMirror(toolingBase, mirror)
+	}
 
 	// List all packages installed in the tooling image
 	toolsListed := toolingBase.Run(llb.Shlex(`sh -c 'ls /usr/bin > applications.txt'`)).Root()
@@ -717,7 +989,7 @@ func (rm *rpmManager) unpackAndMergeUpdates(ctx context.Context, updates unversi
 	}
 
 	// Install busybox. This should reuse the layer cached from probeRPMStatus.
-	toolsInstalled := toolingBase.Run(llb.Shlex(installToolsCmd), llb.WithProxy(utils.GetProxy())).Root()
+	toolsInstalled := toolingBase.Run(llb.Shlex(installToolsCmd), utils.WithProxy()).Root()
 	busyboxCopied := toolsInstalled.Dir(downloadPath).Run(llb.Shlex("cp /usr/sbin/busybox .")).Root()
 
 	// Retrieve all package info from image to be patched.
@@ -804,7 +1076,11 @@ func (rm *rpmManager) unpackAndMergeUpdates(ctx context.Context, updates unversi
 		rpm --dbpath=/tmp/rootfs/var/lib/rpm -qa
 
 		for package in $packages; do
-			package="${package%%.*}" # trim anything after the first "."
+			# trim anything after the first ".", except in a name-version-release target
+			case "$package" in
+				*-[0-9]*.*) ;;
+				*) package="${package%%.*}" ;;
+			esac
 			# Convert OS_VERSION from X.Y.Z to X.Y format
 			OS_VERSION_XY=$(echo "$OS_VERSION" | cut -d'.' -f1-2)
 
@@ -828,12 +1104,10 @@ func (rm *rpmManager) unpackAndMergeUpdates(ctx context.Context, updates unversi
 
 		rpm --dbpath /tmp/rpmdb -qa --qf="%%{NAME}\t%%{VERSION}-%%{RELEASE}\t%%{ARCH}\n" %s > /tmp/rootfs/manifest`
 
-		pkgStrings := []string{}
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
-		}
+		targets := rpmInstallTargets(rm.osType, updates)
+		pkgStrings := installPackageNames(updates)
 
-		downloadCmd = fmt.Sprintf(rpmDownloadTemplate, strings.Join(pkgStrings, " "), strings.Join(pkgStrings, " "))
+		downloadCmd = fmt.Sprintf(rpmDownloadTemplate, strings.Join(targets, " "), strings.Join(pkgStrings, " "))
 	} else {
 		// only updated the outdated packages from packages.txt
 		downloadCmd = `
@@ -878,7 +1152,7 @@ func (rm *rpmManager) unpackAndMergeUpdates(ctx context.Context, updates unversi
 		llb.AddEnv("OS_VERSION", rm.osVersion),
 		llb.AddEnv("IGNORE_ERRORS", errorValidation),
 		buildkit.Sh(downloadCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 		llb.AddMount("/tmp/rpmdb", rpmdb),
 	).AddMount("/tmp/rootfs", rm.config.ImageState)
 
@@ -950,10 +1224,7 @@ func (rm *rpmManager) zypperChrootInstallUpdates(ctx context.Context, updates un
 	// If specific updates provided, parse into pkg names, else will update all
 	if updates != nil {
 		// Format the requested updates into a space-separated string
-		pkgStrings := []string{}
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
-		}
+		pkgStrings := rpmInstallTargets(rm.osType, updates)
 		pkgs = strings.Join(pkgStrings, " ")
 	}
 
@@ -1001,7 +1272,7 @@ func (rm *rpmManager) zypperChrootInstallUpdates(ctx context.Context, updates un
 		llb.AddEnv("COPA_MANIFEST_FILE", filepath.Join(chrootDir, manifestFile)),
 		llb.AddEnv("COPA_UPDATES_MARKER", updatesMarkerFile),
 		buildkit.Sh(zypperCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	)
 
 	downloaded := run.AddMount(chrootDir, imageStateCurrent)
@@ -1086,10 +1357,7 @@ func (rm *rpmManager) dnfChrootInstallUpdates(ctx context.Context, updates unver
 
 	// If specific updates provided, parse into pkg names, else will update all
 	if updates != nil {
-		pkgStrings := []string{}
-		for _, u := range updates {
-			pkgStrings = append(pkgStrings, u.Name)
-		}
+		pkgStrings := rpmInstallTargets(rm.osType, updates)
 		pkgs = strings.Join(pkgStrings, " ")
 	}
 
@@ -1106,25 +1374,25 @@ func (rm *rpmManager) dnfChrootInstallUpdates(ctx context.Context, updates unver
 	if ignoreErrors {
 		dnfCmd = `
                 if ! [[ -d "${COPA_CHROOT_DIR}/var/lib/rpm" ]]; then echo "RPM DB not found"; exit 1; fi
-                output=$(dnf --installroot="${COPA_CHROOT_DIR}" \
+                output=$("${COPA_PKG_TOOL}" --installroot="${COPA_CHROOT_DIR}" \
                     --setopt=reposdir="${COPA_CHROOT_DIR}/etc/yum.repos.d" \
                     --releasever="${COPA_RELEASE_VER}" \
                     --nogpgcheck \
                     upgrade -y %s 2>&1) || true
                 echo "$output"
-                if ! echo "$output" | grep -q "Nothing to do"; then
+                if ! echo "$output" | grep -qE "Nothing to do|No packages marked for update"; then
                     echo "updates_applied" > "${COPA_UPDATES_MARKER}"
                 fi
-                dnf --installroot="${COPA_CHROOT_DIR}" \
+                "${COPA_PKG_TOOL}" --installroot="${COPA_CHROOT_DIR}" \
                     --setopt=reposdir="${COPA_CHROOT_DIR}/etc/yum.repos.d" \
                     clean all 2>/dev/null || true
-                rm -rf "${COPA_CHROOT_DIR}"/var/cache/dnf/* "${COPA_CHROOT_DIR}"/var/log/dnf.*
+                rm -rf "${COPA_CHROOT_DIR}"/var/cache/"${COPA_PKG_TOOL}"/* "${COPA_CHROOT_DIR}"/var/log/dnf.*
                 rpm --dbpath "${COPA_CHROOT_DIR}"/var/lib/rpm -qa --qf="%%{NAME}\t%%{VERSION}-%%{RELEASE}\t%%{ARCH}\n" %s > "${COPA_MANIFEST_FILE}"
 	`
 	} else {
 		dnfCmd = `
                 if ! [[ -d "${COPA_CHROOT_DIR}/var/lib/rpm" ]]; then echo "RPM DB not found"; exit 1; fi
-                output=$(dnf --installroot="${COPA_CHROOT_DIR}" \
+                output=$("${COPA_PKG_TOOL}" --installroot="${COPA_CHROOT_DIR}" \
                     --setopt=reposdir="${COPA_CHROOT_DIR}/etc/yum.repos.d" \
                     --releasever="${COPA_RELEASE_VER}" \
                     --nogpgcheck \
@@ -1132,32 +1400,32 @@ func (rm *rpmManager) dnfChrootInstallUpdates(ctx context.Context, updates unver
                 dnf_exit=$?
                 echo "$output"
                 if [ $dnf_exit -ne 0 ]; then exit $dnf_exit; fi
-                if ! echo "$output" | grep -q "Nothing to do"; then
+                if ! echo "$output" | grep -qE "Nothing to do|No packages marked for update"; then
                     echo "updates_applied" > "${COPA_UPDATES_MARKER}"
                 fi
-                dnf --installroot="${COPA_CHROOT_DIR}" \
+                "${COPA_PKG_TOOL}" --installroot="${COPA_CHROOT_DIR}" \
                     --setopt=reposdir="${COPA_CHROOT_DIR}/etc/yum.repos.d" \
                     clean all 2>/dev/null || true
-                rm -rf "${COPA_CHROOT_DIR}"/var/cache/dnf/* "${COPA_CHROOT_DIR}"/var/log/dnf.*
+                rm -rf "${COPA_CHROOT_DIR}"/var/cache/"${COPA_PKG_TOOL}"/* "${COPA_CHROOT_DIR}"/var/log/dnf.*
                 rpm --dbpath "${COPA_CHROOT_DIR}"/var/lib/rpm -qa --qf="%%{NAME}\t%%{VERSION}-%%{RELEASE}\t%%{ARCH}\n" %s > "${COPA_MANIFEST_FILE}"
 	`
 	}
 	dnfCmd = fmt.Sprintf(dnfCmd, pkgs, pkgs)
 
-	// Derive the release version (major.minor) for dnf --releasever
-	releaseVer := rm.osVersion
-	parts := strings.Split(rm.osVersion, ".")
-	if len(parts) >= 2 {
-		releaseVer = parts[0] + "." + parts[1]
+	// AL2 tooling images only ship yum, which accepts the same flags used here
+	pkgTool := "dnf"
+	if rm.osType == utils.OSTypeAmazon {
+		pkgTool = amazonLinuxPackageTool(rm.osVersion)
 	}
 
 	run := toolingBase.Run(
 		llb.AddEnv("COPA_CHROOT_DIR", chrootDir),
-		llb.AddEnv("COPA_RELEASE_VER", releaseVer),
+		llb.AddEnv("COPA_PKG_TOOL", pkgTool),
+		llb.AddEnv("COPA_RELEASE_VER", rpmReleaseVer(rm.osType, rm.osVersion)),
 		llb.AddEnv("COPA_MANIFEST_FILE", filepath.Join(chrootDir, manifestFile)),
 		llb.AddEnv("COPA_UPDATES_MARKER", updatesMarkerFile),
 		buildkit.Sh(dnfCmd),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	)
 
 	downloaded := run.AddMount(chrootDir, imageStateCurrent)
@@ -1202,6 +1470,13 @@ func (rm *rpmManager) GetPackageType() string {
 	return "rpm"
 }
 
+// PackageTool returns the canonical name of the image's RPM tool used for patching,
+// e.g. dnf when yum is a symlink to it.
+func (rm *rpmManager) PackageTool() string {
+	tool, _ := selectRPMTool(rm.rpmTools)
+	return tool
+}
+
 func rpmReadResultsManifest(b []byte) ([]string, error) {
 	if b == nil {
 		return nil, fmt.Errorf("nil result manifest buffer")
@@ -1238,7 +1513,7 @@ func validateRPMPackageVersions(updates unversioned.UpdatePackages, cmp VersionC
 	sort.SliceStable(updates, func(i, j int) bool {
 		return updates[i].Name < updates[j].Name
 	})
-	log.Debugf("Required updates: %s", updates)
+	log.Debugf("Required updates: %v", updates)
 
 	sort.SliceStable(lines, func(i, j int) bool {
 		return lines[i] < lines[j]
@@ -17,10 +17,12 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/report"
 	"github.com/project-copacetic/copacetic/pkg/types"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 	"github.com/project-copacetic/copacetic/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestRpmDBTypeString tests the String method of rpmDBType.
@@ -250,6 +252,51 @@ func TestParseRPMTools(t *testing.T) {
 	}
 }
 
+func TestParseRPMToolsSymlinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		probe    string
+		want     rpmToolPaths
+		wantTool string
+	}{
+		{
+			name:     "yum symlinked to dnf takes the dnf path",
+			probe:    "tdnf:notfound\ndnf:notfound\nmicrodnf:notfound\nyum:/usr/bin/yum:/usr/bin/dnf-3\nrpm:/usr/bin/rpm:/usr/bin/rpm\n",
+			want:     rpmToolPaths{"dnf": "/usr/bin/yum", "rpm": "/usr/bin/rpm"},
+			wantTool: "dnf",
+		},
+		{
+			name:     "yum and dnf both symlinked to dnf-3",
+			probe:    "dnf:/usr/bin/dnf:/usr/bin/dnf-3\nyum:/usr/bin/yum:/usr/bin/dnf-3\nrpm:/usr/bin/rpm:/usr/bin/rpm\n",
+			want:     rpmToolPaths{"dnf": "/usr/bin/dnf", "rpm": "/usr/bin/rpm"},
+			wantTool: "dnf",
+		},
+		{
+			name:     "real yum is kept",
+			probe:    "dnf:notfound\nyum:/usr/bin/yum:/usr/bin/yum\nrpm:/usr/bin/rpm:/usr/bin/rpm\n",
+			want:     rpmToolPaths{"yum": "/usr/bin/yum", "rpm": "/usr/bin/rpm"},
+			wantTool: "yum",
+		},
+		{
+			name:     "dnf5 is treated as dnf",
+			probe:    "dnf:/usr/bin/dnf:/usr/bin/dnf5\nrpm:/usr/bin/rpm:/usr/bin/rpm\n",
+			want:     rpmToolPaths{"dnf": "/usr/bin/dnf", "rpm": "/usr/bin/rpm"},
+			wantTool: "dnf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpmTools, err := parseRPMTools([]byte(tt.probe))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rpmTools)
+
+			rm := &rpmManager{rpmTools: rpmTools}
+			assert.Equal(t, tt.wantTool, rm.PackageTool())
+		})
+	}
+}
+
 // TestGetRPMDBType tests the getRPMDBType function with different input directories.
 func TestGetRPMDBType(t *testing.T) {
 	// Define some test cases with expected output
@@ -971,6 +1018,12 @@ func TestGetChrootToolImage(t *testing.T) {
 			osVersion: "2023",
 			expected:  "amazonlinux:2023",
 		},
+		{
+			name:      "Amazon Linux 2023 full release version",
+			osType:    utils.OSTypeAmazon,
+			osVersion: "2023.3.20240108",
+			expected:  "amazonlinux:2023",
+		},
 		{
 			name:      "Oracle Linux 8",
 			osType:    utils.OSTypeOracle,
@@ -993,6 +1046,66 @@ func TestGetChrootToolImage(t *testing.T) {
 	}
 }
 
+func TestAmazonLinuxVersionHandling(t *testing.T) {
+	tools := rpmToolPaths{"dnf": "/usr/bin/dnf", "yum": "/usr/bin/yum", "rpm": "/usr/bin/rpm"}
+
+	testCases := []struct {
+		osVersion  string
+		generation string
+		tool       string
+		releaseVer string
+		wantTools  rpmToolPaths
+	}{
+		{
+			osVersion:  "2",
+			generation: "2",
+			tool:       "yum",
+			releaseVer: "2",
+			wantTools:  rpmToolPaths{"yum": "/usr/bin/yum", "rpm": "/usr/bin/rpm"},
+		},
+		{
+			osVersion:  "2 (Karoo)",
+			generation: "2",
+			tool:       "yum",
+			releaseVer: "2",
+			wantTools:  rpmToolPaths{"yum": "/usr/bin/yum", "rpm": "/usr/bin/rpm"},
+		},
+		{
+			osVersion:  "2023",
+			generation: "2023",
+			tool:       "dnf",
+			releaseVer: "latest",
+			wantTools:  rpmToolPaths{"dnf": "/usr/bin/dnf", "rpm": "/usr/bin/rpm"},
+		},
+		{
+			osVersion:  "2023.3.20240108",
+			generation: "2023",
+			tool:       "dnf",
+			releaseVer: "latest",
+			wantTools:  rpmToolPaths{"dnf": "/usr/bin/dnf", "rpm": "/usr/bin/rpm"},
+		},
+		{
+			osVersion:  "2018.03",
+			generation: "",
+			tool:       "dnf",
+			releaseVer: "2018.03",
+			wantTools:  tools,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.osVersion, func(t *testing.T) {
+			assert.Equal(t, tc.generation, amazonLinuxGeneration(tc.osVersion))
+			assert.Equal(t, tc.tool, amazonLinuxPackageTool(tc.osVersion))
+			assert.Equal(t, tc.releaseVer, rpmReleaseVer(utils.OSTypeAmazon, tc.osVersion))
+			assert.Equal(t, tc.wantTools, selectAmazonLinuxTools(tools, tc.osVersion))
+		})
+	}
+
+	// Other RPM distros keep using major.minor for --releasever
+	assert.Equal(t, "9.3", rpmReleaseVer(utils.OSTypeRocky, "9.3.1"))
+}
+
 func Test_dnfChrootInstallUpdates(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -1137,3 +1250,152 @@ func Test_dnfChrootInstallUpdates(t *testing.T) {
 		})
 	}
 }
+
+// TestMarinerReportInstallsFullNVR parses a CBL-Mariner Trivy report and checks that
+// tdnf is asked for the exact name-version-release of each update with the .cm2 tag.
+func TestMarinerReportInstallsFullNVR(t *testing.T) {
+	manifest, err := report.NewTrivyParser().Parse("testdata/trivy_mariner.json")
+	require.NoError(t, err)
+	require.Equal(t, utils.OSTypeCBLMariner, manifest.Metadata.OS.Type)
+
+	config := &buildkit.Config{ImageState: llb.Scratch()}
+	pm, err := GetPackageManager(manifest.Metadata.OS.Type, manifest.Metadata.OS.Version, config, "")
+	require.NoError(t, err)
+	rm, ok := pm.(*rpmManager)
+	require.True(t, ok)
+
+	updates, err := GetUniqueLatestUpdates(manifest.OSUpdates, VersionComparer{isValidRPMVersion, isLessThanRPMVersion}, false)
+	require.NoError(t, err)
+	updates, skipped := skipReleaseMismatches(rm.osType, rm.osVersion, updates)
+	assert.Equal(t, []string{"zlib"}, skipped, "the .cm1 build is for CBL-Mariner 1")
+
+	targets := rpmInstallTargets(rm.osType, updates)
+	assert.Equal(t, []string{"openssl-1.1.1k-28.cm2", "openssl-libs-1.1.1k-28.cm2", "sqlite-libs"}, targets)
+
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("openssl\t1.1.1k-28.cm2\tx86_64\n"), nil)
+	config.Client = mockClient
+	rm.rpmTools = rpmToolPaths{"tdnf": "/usr/bin/tdnf"}
+
+	st, _, err := rm.installUpdates(context.TODO(), updates, false)
+	require.NoError(t, err)
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	want := []byte("/usr/bin/tdnf upgrade --refresh openssl-1.1.1k-28.cm2 openssl-libs-1.1.1k-28.cm2 sqlite-libs -y")
+	found := false
+	for _, op := range def.Def {
+		if bytes.Contains(op, want) {
+			found = true
+			break
+		}
+	}
+	assert.True(t, found, "install command with full NVRs not found in LLB")
+}
+
+// With --update-all, the packages a report lists as installed but not vulnerable are
+// installed by name, so tdnf upgrades them instead of reinstalling the installed build.
+func TestAzureLinuxUpdateAllInstallsByName(t *testing.T) {
+	const file = "testdata/trivy_azurelinux_list_all_pkgs.json"
+	manifest, err := report.NewTrivyParser().Parse(file)
+	require.NoError(t, err)
+	require.Equal(t, utils.OSTypeAzureLinux, manifest.Metadata.OS.Type)
+	installed, err := report.ListInstalledOSPackages(file, "trivy")
+	require.NoError(t, err)
+
+	// As added by --update-all
+	osUpdates := manifest.OSUpdates
+	for _, pkg := range installed {
+		osUpdates = append(osUpdates, unversioned.UpdatePackage{
+			Name:             pkg.Name,
+			InstalledVersion: pkg.InstalledVersion,
+			FixedVersion:     pkg.InstalledVersion,
+			Status:           unversioned.InstalledOnly,
+		})
+	}
+	updates, err := GetUniqueLatestUpdates(osUpdates, VersionComparer{isValidRPMVersion, isLessThanRPMVersion}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "curl", "openssl-3.3.0-3.azl3"}, rpmInstallTargets(utils.OSTypeAzureLinux, updates))
+
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("openssl\t3.3.0-3.azl3\tx86_64\n"), nil)
+	rm := &rpmManager{
+		config:    &buildkit.Config{Client: mockClient, ImageState: llb.Scratch()},
+		osType:    utils.OSTypeAzureLinux,
+		osVersion: manifest.Metadata.OS.Version,
+		rpmTools:  rpmToolPaths{"tdnf": "/usr/bin/tdnf"},
+	}
+
+	st, _, err := rm.installUpdates(context.TODO(), updates, false)
+	require.NoError(t, err)
+	assert.True(t, definitionContains(t, *st, "/usr/bin/tdnf upgrade --refresh bash curl openssl-3.3.0-3.azl3 -y"))
+}
+
+// The distroless path installs the same full NVRs with tdnf in the tooling image.
+func TestMarinerDistrolessInstallsFullNVR(t *testing.T) {
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("openssl\t1.1.1k-28.cm2\tx86_64\ntdnf"), nil)
+
+	rm := &rpmManager{
+		config: &buildkit.Config{Client: mockClient, ImageState: llb.Scratch()},
+		osType: utils.OSTypeCBLMariner,
+	}
+	updates := unversioned.UpdatePackages{
+		{Name: "openssl", FixedVersion: "1.1.1k-28.cm2"},
+		{Name: "curl", FixedVersion: "7.86.0"},
+	}
+	st, _, err := rm.unpackAndMergeUpdates(context.TODO(), updates, "test-tool-image:latest",
+		&ocispecs.Platform{OS: "linux", Architecture: "amd64"}, false)
+	require.NoError(t, err)
+	assert.True(t, definitionContains(t, *st, `packages="openssl-1.1.1k-28.cm2 curl"`))
+}
+
+func TestUnpackAndMergeUpdatesUsesRPMMirror(t *testing.T) {
+	mockClient := new(mocks.MockGWClient)
+	mockRef := new(mocks.MockReference)
+	mockResult := &gwclient.Result{}
+	mockResult.SetRef(mockRef)
+	mockClient.On("Solve", mock.Anything, mock.Anything).Return(mockResult, nil)
+	mockRef.On("ReadFile", mock.Anything, mock.Anything).Return([]byte("curl\t7.86.0\tx86_64\ntdnf"), nil)
+
+	rm := &rpmManager{
+		config: &buildkit.Config{
+			Client:      mockClient,
+			ImageState:  llb.Scratch(),
+			RepoMirrors: map[string]string{repoTypeRPM: "https://mirror.example.com"},
+		},
+		osType: utils.OSTypeCBLMariner,
+	}
+	st, _, err := rm.unpackAndMergeUpdates(context.TODO(), unversioned.UpdatePackages{{Name: "curl", FixedVersion: "7.86.0"}},
+		"test-tool-image:latest", &ocispecs.Platform{OS: "linux", Architecture: "amd64"}, false)
+	require.NoError(t, err)
+	assert.True(t, definitionContains(t, *st, "baseurl=https://mirror.example.com"))
+}
+
+func TestDNFDownloadOptions(t *testing.T) {
+	assert.Equal(t, "", dnfDownloadOptions(0))
+	assert.Equal(t, "", dnfDownloadOptions(-1))
+	assert.Equal(t, " --setopt=max_parallel_downloads=3", dnfDownloadOptions(3))
+	assert.Equal(t, " --setopt=max_parallel_downloads=20", dnfDownloadOptions(50))
+}
+
+func TestRPMInstallTargets(t *testing.T) {
+	updates := unversioned.UpdatePackages{
+		{Name: "openssl", FixedVersion: "3.3.0-3.azl3"},
+		{Name: "curl", FixedVersion: "8.8.0-1"},
+	}
+	assert.Equal(t, []string{"openssl-3.3.0-3.azl3", "curl"}, rpmInstallTargets(utils.OSTypeAzureLinux, updates))
+	assert.Equal(t, []string{"openssl", "curl"}, rpmInstallTargets(utils.OSTypeRedHat, updates))
+}
@@ -10,9 +10,9 @@ else
     packages="%s"
 fi
 
-apt-get -o Acquire::Retries=3 update
+apt-get $APT_OPTS update
 
-apt-get -o Acquire::Retries=3 download --no-install-recommends $packages
+apt-get $APT_OPTS download --no-install-recommends $packages
 dpkg --root=/tmp/debian-rootfs --admindir=/tmp/debian-rootfs/var/lib/dpkg --force-all --force-confold --install *.deb
 dpkg --root=/tmp/debian-rootfs --configure -a
 
@@ -0,0 +1,76 @@
+package pkgmgr
+
+import (
+	"fmt"
+
+	"github.com/moby/buildkit/client/llb"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+const (
+	aptSecuritySourcesList = "/etc/apt/sources.list.d/copa-security.list"
+	aptSecurityPreferences = "/etc/apt/preferences.d/copa-security"
+)
+
+// aptSecuritySourcesScript adds the security suite of the release named in
+// /etc/os-release to the image's apt sources and pins it at priority 990, matching the
+// suite as unattended-upgrades' default origins do. Its versions become the candidates
+// over those of the other suites, which keep the default priority of 500. Ubuntu serves architectures other
+// than amd64 and i386 from the ports archive, and Debian releases before bullseye
+// name the suite <codename>/updates.
+const aptSecuritySourcesScript = `. /etc/os-release && ` +
+	`if [ -z "${VERSION_CODENAME}" ]; then echo "no VERSION_CODENAME in /etc/os-release to find the security suite by" >&2; exit 1; fi && ` +
+	`case "${ID}" in ` +
+	`ubuntu) case "$(dpkg --print-architecture)" in ` +
+	`amd64|i386) uri=http://security.ubuntu.com/ubuntu ;; ` +
+	`*) uri=http://ports.ubuntu.com/ubuntu-ports ;; esac; ` +
+	`source="deb ${uri} ${VERSION_CODENAME}-security main restricted universe multiverse"; ` +
+	`pin="a=${VERSION_CODENAME}-security" ;; ` +
+	`*) case "${VERSION_CODENAME}" in ` +
+	`stretch|buster) suite="${VERSION_CODENAME}/updates" ;; ` +
+	`*) suite="${VERSION_CODENAME}-security" ;; esac; ` +
+	`source="deb http://security.debian.org/debian-security ${suite} main"; ` +
+	`pin="l=Debian-Security" ;; ` +
+	`esac && ` +
+	`echo "${source}" > ` + aptSecuritySourcesList + ` && ` +
+	`printf 'Package: *\nPin: release %s\nPin-Priority: 990\n' "${pin}" > ` + aptSecurityPreferences
+
+// withAPTSecuritySources makes apt prefer the security suite of the image's release,
+// so reported packages are upgraded to the versions published there, as
+// unattended-upgrades does. The image's own sources stay configured, so dependencies
+// that are only in the main suite or a point release still resolve. Like
+// withAPTSnapshot, the change is not part of the patch layer.
+func withAPTSecuritySources(st llb.State) llb.State {
+	return st.Run(
+		llb.Args([]string{"sh", "-c", aptSecuritySourcesScript}),
+		llb.WithCustomName("Pinning apt to the security suite"),
+	).Root()
+}
+
+// securityUpgradeResults describes the version each of updates landed on after a
+// security-only upgrade, read from the results manifest. The version may be newer than
+// the one the report asked for, or still the installed one when the security suite
+// does not carry a fix yet.
+func securityUpgradeResults(updates unversioned.UpdatePackages, results []byte) ([]string, error) {
+	landed, err := dpkgParseResultsManifest(results)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(updates))
+	for _, u := range updates {
+		version, ok := landed[u.Name]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s is no longer installed", u.Name))
+			continue
+		}
+		if version == u.InstalledVersion {
+			lines = append(lines, fmt.Sprintf("%s stayed at %s, the security suite has no newer version (report fixed version %s)",
+				u.Name, version, u.FixedVersion))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s -> %s (report fixed version %s)", u.Name, u.InstalledVersion, version, u.FixedVersion))
+	}
+	return lines, nil
+}
@@ -0,0 +1,44 @@
+package pkgmgr
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+func TestAPTSecuritySourcesInLLB(t *testing.T) {
+	st := withAPTSecuritySources(llb.Image("debian:12"))
+	assert.True(t, definitionContains(t, st, "deb http://security.debian.org/debian-security ${suite} main"))
+	assert.True(t, definitionContains(t, st, "${VERSION_CODENAME}-security main restricted universe multiverse"))
+	assert.True(t, definitionContains(t, st, aptSecuritySourcesList))
+	assert.True(t, definitionContains(t, st, aptSecurityPreferences))
+	assert.True(t, definitionContains(t, st, "Pin-Priority: 990"))
+	assert.True(t, definitionContains(t, st, `pin="l=Debian-Security"`))
+	assert.True(t, definitionContains(t, st, `pin="a=${VERSION_CODENAME}-security"`))
+	// The image's own sources are kept.
+	assert.False(t, definitionContains(t, st, "rm -f /etc/apt/sources.list"))
+}
+
+func TestSecurityUpgradeResults(t *testing.T) {
+	updates := unversioned.UpdatePackages{
+		{Name: "libssl3", InstalledVersion: "3.0.11-1~deb12u1", FixedVersion: "3.0.13-1~deb12u1"},
+		{Name: "tzdata", InstalledVersion: "2024a-0+deb12u1", FixedVersion: "2025b-0+deb12u1"},
+		{Name: "libgnutls30", InstalledVersion: "3.7.9-2", FixedVersion: "3.7.9-2+deb12u3"},
+	}
+	results := []byte("Package: libssl3\nVersion: 3.0.15-1~deb12u1\nPackage: tzdata\nVersion: 2024a-0+deb12u1\n")
+
+	got, err := securityUpgradeResults(updates, results)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"libssl3 3.0.11-1~deb12u1 -> 3.0.15-1~deb12u1 (report fixed version 3.0.13-1~deb12u1)",
+		"tzdata stayed at 2024a-0+deb12u1, the security suite has no newer version (report fixed version 2025b-0+deb12u1)",
+		"libgnutls30 is no longer installed",
+	}, got)
+
+	_, err = securityUpgradeResults(updates, []byte("Status: install ok installed\n"))
+	assert.Error(t, err)
+}
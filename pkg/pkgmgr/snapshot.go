@@ -0,0 +1,86 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// Package types that accept --repo-snapshot and --repo-mirror overrides.
+const (
+	repoTypeDeb = "deb"
+	repoTypeAPK = "apk"
+	repoTypeRPM = "rpm"
+)
+
+const (
+	aptSnapshotSourcesList = "/etc/apt/sources.list"
+	apkRepositoriesFile    = "/etc/apk/repositories"
+)
+
+// ParseRepoSnapshots parses --repo-snapshot values of the form <type>=<url>[,<url>...],
+// e.g. "deb=https://snapshot.debian.org/archive/debian/20240101T000000Z" or
+// "apk=https://dl-cdn.alpinelinux.org/alpine/v3.19/main,https://dl-cdn.alpinelinux.org/alpine/v3.19/community".
+// apt takes a single archive URL; apk takes one or more repository URLs.
+func ParseRepoSnapshots(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	snapshots := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		pkgType, value, ok := strings.Cut(spec, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid --repo-snapshot %q: expected <type>=<url>", spec)
+		}
+		if pkgType != repoTypeDeb && pkgType != repoTypeAPK {
+			return nil, fmt.Errorf("invalid --repo-snapshot %q: unsupported type %q, supported: %s, %s", spec, pkgType, repoTypeAPK, repoTypeDeb)
+		}
+		if _, dup := snapshots[pkgType]; dup {
+			return nil, fmt.Errorf("invalid --repo-snapshot %q: %s snapshot specified more than once", spec, pkgType)
+		}
+
+		urls := strings.Split(value, ",")
+		if pkgType == repoTypeDeb && len(urls) > 1 {
+			return nil, fmt.Errorf("invalid --repo-snapshot %q: %s takes a single archive URL", spec, pkgType)
+		}
+		for _, u := range urls {
+			parsed, err := url.Parse(u)
+			if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return nil, fmt.Errorf("invalid --repo-snapshot %q: %q is not an absolute URL", spec, u)
+			}
+		}
+		snapshots[pkgType] = value
+	}
+	return snapshots, nil
+}
+
+// SnapshotTypes returns the sorted package types that accept a repository snapshot.
+func SnapshotTypes() []string {
+	return []string{repoTypeAPK, repoTypeDeb}
+}
+
+// withAPTSnapshot replaces the apt sources of st with a single snapshot archive for the
+// image's release. The change is made below the state apt-get update runs on, so it is
+// not part of the patch layer and the image keeps its original sources.
+func withAPTSnapshot(st llb.State, archiveURL string) llb.State {
+	script := fmt.Sprintf(`rm -f /etc/apt/sources.list.d/*.list /etc/apt/sources.list.d/*.sources && `+
+		`. /etc/os-release && `+
+		`echo "deb [check-valid-until=no] %s ${VERSION_CODENAME} main" > %s`, archiveURL, aptSnapshotSourcesList)
+	return st.Run(
+		llb.Args([]string{"sh", "-c", script}),
+		llb.WithCustomNamef("Pinning apt sources to snapshot %s", archiveURL),
+	).Root()
+}
+
+// withAPKSnapshot overrides /etc/apk/repositories in st with the given comma-separated
+// repository URLs. Like withAPTSnapshot, the override is not part of the patch layer.
+func withAPKSnapshot(st llb.State, repositories string) llb.State {
+	content := strings.Join(strings.Split(repositories, ","), "\n") + "\n"
+	return st.File(
+		llb.Mkfile(apkRepositoriesFile, 0o644, []byte(content)),
+		llb.WithCustomNamef("Pinning apk repositories to %s", repositories),
+	)
+}
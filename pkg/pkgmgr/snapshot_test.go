@@ -0,0 +1,84 @@
+package pkgmgr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRepoSnapshots(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "none"},
+		{
+			name: "deb and apk",
+			specs: []string{
+				"deb=https://snapshot.debian.org/archive/debian/20240101T000000Z",
+				"apk=https://dl-cdn.alpinelinux.org/alpine/v3.19/main,https://dl-cdn.alpinelinux.org/alpine/v3.19/community",
+			},
+			want: map[string]string{
+				"deb": "https://snapshot.debian.org/archive/debian/20240101T000000Z",
+				"apk": "https://dl-cdn.alpinelinux.org/alpine/v3.19/main,https://dl-cdn.alpinelinux.org/alpine/v3.19/community",
+			},
+		},
+		{name: "missing url", specs: []string{"deb="}, wantErr: "expected <type>=<url>"},
+		{name: "unsupported type", specs: []string{"rpm=https://example.com/repo"}, wantErr: `unsupported type "rpm"`},
+		{name: "relative url", specs: []string{"apk=alpine/v3.19/main"}, wantErr: "not an absolute URL"},
+		{name: "multiple deb urls", specs: []string{"deb=https://a.example.com,https://b.example.com"}, wantErr: "single archive URL"},
+		{
+			name:    "duplicate type",
+			specs:   []string{"apk=https://a.example.com/main", "apk=https://b.example.com/main"},
+			wantErr: "more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRepoSnapshots(tt.specs)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// definitionContains reports whether any op in the marshaled LLB graph of st contains s.
+func definitionContains(t *testing.T, st llb.State, s string) bool {
+	t.Helper()
+	def, err := st.Marshal(context.Background())
+	require.NoError(t, err)
+	for _, op := range def.Def {
+		if bytes.Contains(op, []byte(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRepoSnapshotInLLB(t *testing.T) {
+	t.Run("apt sources list", func(t *testing.T) {
+		const archive = "https://snapshot.debian.org/archive/debian/20240101T000000Z"
+		st := withAPTSnapshot(llb.Image("debian:12"), archive)
+		assert.True(t, definitionContains(t, st, "deb [check-valid-until=no] "+archive+" ${VERSION_CODENAME} main"))
+		assert.True(t, definitionContains(t, st, aptSnapshotSourcesList))
+	})
+
+	t.Run("apk repositories file", func(t *testing.T) {
+		st := withAPKSnapshot(llb.Image("alpine:3.19"),
+			"https://dl-cdn.alpinelinux.org/alpine/v3.19/main,https://dl-cdn.alpinelinux.org/alpine/v3.19/community")
+		assert.True(t, definitionContains(t, st,
+			"https://dl-cdn.alpinelinux.org/alpine/v3.19/main\nhttps://dl-cdn.alpinelinux.org/alpine/v3.19/community\n"))
+		assert.True(t, definitionContains(t, st, apkRepositoriesFile))
+	})
+}
@@ -0,0 +1,74 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+
+	"github.com/project-copacetic/copacetic/pkg/buildkit"
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+// Exit codes a guarded install step uses to report why the package manager failed,
+// taken from sysexits.h so they do not collide with the package managers' own codes.
+const (
+	exitPackageNotFound = 69 // EX_UNAVAILABLE
+	exitNotWritable     = 74 // EX_IOERR
+)
+
+// installTargetDirs are the directories OS package managers write to during an install.
+var installTargetDirs = []string{"/usr", "/etc", "/var/lib"}
+
+// Output of each package manager when a requested package is not in its repositories.
+const (
+	aptNotFoundPattern = `Unable to locate package|has no installation candidate`
+	apkNotFoundPattern = `unable to select packages|no such package`
+	rpmNotFoundPattern = `No match for argument|No package .* available|No matching packages|No package matches`
+)
+
+var exitCodePattern = regexp.MustCompile(`did not complete successfully: exit code: (\d+)`)
+
+// guardedInstall runs the install command cmd so that its failures can be told apart.
+// Target directories that are read-only by their mode bits, as in some hardened
+// images, are made writable for the install and restored afterwards, so the patch
+// layer does not change their permissions. If cmd fails, the step exits with
+// exitNotWritable when a target directory still cannot be written to and with
+// exitPackageNotFound when the output matches notFoundPattern; otherwise it keeps
+// the exit code of cmd.
+func guardedInstall(cmd, notFoundPattern string) llb.RunOption {
+	dirs := strings.Join(installTargetDirs, " ")
+	script := fmt.Sprintf(`ro=""; `+
+		`for d in %[1]s; do if [ -d "$d" ] && [ -n "$(find "$d" -maxdepth 0 ! -perm -200 2>/dev/null)" ]; then ro="$ro $d"; chmod u+w "$d"; fi; done; `+
+		`output=$(%[2]s 2>&1); rc=$?; echo "$output"; `+
+		`for d in $ro; do chmod u-w "$d"; done; `+
+		`if [ $rc -eq 0 ]; then exit 0; fi; `+
+		`for d in %[1]s; do if [ -d "$d" ]; then if ! touch "$d/.copa-write-test" 2>/dev/null; then echo "$d is not writable" >&2; exit %[3]d; fi; rm -f "$d/.copa-write-test"; fi; done; `+
+		`if echo "$output" | grep -qE '%[4]s'; then exit %[5]d; fi; `+
+		`exit $rc`,
+		dirs, cmd, exitNotWritable, notFoundPattern, exitPackageNotFound)
+	return buildkit.Sh(script)
+}
+
+// classifyInstallError marks err with types.ErrFilesystemNotWritable or
+// types.ErrPackageNotFound when it comes from a guarded install step that failed
+// for one of those reasons. Other errors are returned unchanged.
+func classifyInstallError(err error) error {
+	if err == nil {
+		return nil
+	}
+	m := exitCodePattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	switch code, _ := strconv.Atoi(m[1]); code {
+	case exitNotWritable:
+		return fmt.Errorf("%w: %w", types.ErrFilesystemNotWritable, err)
+	case exitPackageNotFound:
+		return fmt.Errorf("%w: %w", types.ErrPackageNotFound, err)
+	default:
+		return err
+	}
+}
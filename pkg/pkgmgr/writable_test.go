@@ -0,0 +1,52 @@
+package pkgmgr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/project-copacetic/copacetic/pkg/types"
+)
+
+func TestGuardedInstall(t *testing.T) {
+	st := llb.Image("debian:12").Run(guardedInstall("apt-get install -y openssl", aptNotFoundPattern)).Root()
+	assert.True(t, definitionContains(t, st, `output=$(apt-get install -y openssl 2>&1)`))
+	assert.True(t, definitionContains(t, st, `grep -qE '`+aptNotFoundPattern+`'; then exit 69`))
+	assert.True(t, definitionContains(t, st, `echo "$d is not writable" >&2; exit 74`))
+	assert.True(t, definitionContains(t, st, `for d in /usr /etc /var/lib;`))
+}
+
+func TestClassifyInstallError(t *testing.T) {
+	exitErr := func(code int) error {
+		return fmt.Errorf(`failed to solve: process "/bin/sh -c ... exit 74 ..." did not complete successfully: exit code: %d`, code)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "nil"},
+		{name: "not writable", err: exitErr(exitNotWritable), want: types.ErrFilesystemNotWritable},
+		{name: "package not found", err: exitErr(exitPackageNotFound), want: types.ErrPackageNotFound},
+		{name: "other exit code", err: exitErr(100)},
+		{name: "not an exit error", err: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyInstallError(tt.err)
+			if tt.err == nil {
+				assert.NoError(t, got)
+				return
+			}
+			assert.ErrorIs(t, got, tt.err)
+			for _, sentinel := range []error{types.ErrFilesystemNotWritable, types.ErrPackageNotFound} {
+				assert.Equal(t, sentinel == tt.want, errors.Is(got, sentinel), "errors.Is(%v)", sentinel)
+			}
+		})
+	}
+}
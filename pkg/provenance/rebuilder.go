@@ -723,7 +723,7 @@ func (r *Rebuilder) buildBinaryWithUpdates(
 	log.Debug("Running go mod download with retry...")
 	state = state.Run(
 		llb.Shlex(fmt.Sprintf("sh -c '%s'", strings.ReplaceAll(downloadScript, "'", "'\"'\"'"))),
-		llb.WithProxy(utils.GetProxy()),
+		utils.WithProxy(),
 	).Root()
 
 	// Apply updates with retry for each module.
@@ -742,7 +742,7 @@ func (r *Rebuilder) buildBinaryWithUpdates(
 		getScript := retryScript(getCmd, 3)
 		state = state.Run(
 			llb.Shlex(fmt.Sprintf("sh -c '%s'", strings.ReplaceAll(getScript, "'", "'\"'\"'"))),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	}
 
@@ -753,7 +753,7 @@ func (r *Rebuilder) buildBinaryWithUpdates(
 		log.Debug("Running go mod tidy...")
 		state = state.Run(
 			llb.Shlexf("%s mod tidy", goBin),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	}
 
@@ -776,7 +776,7 @@ fi
 `, goBin)
 		state = state.Run(
 			llb.Shlex(fmt.Sprintf("sh -c '%s'", strings.ReplaceAll(vendorSyncScript, "'", "'\"'\"'"))),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	}
 
@@ -815,7 +815,7 @@ fi
 		)
 		state = state.Run(
 			llb.Shlex(fmt.Sprintf("sh /tmp/copa_discover_build.sh %s %s %s", binaryName, outputPath, mainPkg)),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	} else {
 		// No source cloned (generated go.mod). Let the build fail if it can't find the package.
@@ -823,7 +823,7 @@ fi
 		log.Debugf("Build command (no source): %s", buildCmd)
 		state = state.Run(
 			llb.Shlex(fmt.Sprintf("sh -c '%s'", strings.ReplaceAll(buildCmd, "'", "'\"'\"'"))),
-			llb.WithProxy(utils.GetProxy()),
+			utils.WithProxy(),
 		).Root()
 	}
 
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultFileExtension is the extension of the report files picked up from a report
+// directory unless other extensions are given.
+const DefaultFileExtension = ".json"
+
+// builtinFileExtensions are the report file extensions the built-in scanners read:
+// JSON documents and JSON Lines.
+var builtinFileExtensions = []string{".json", ".jsonl"}
+
+// FileExtensionsOrDefault returns exts, the extensions of the files read from a report
+// directory, or DefaultFileExtension alone when exts is empty.
+func FileExtensionsOrDefault(exts []string) []string {
+	if len(exts) == 0 {
+		return []string{DefaultFileExtension}
+	}
+	return exts
+}
+
+// HasFileExtension reports whether the file name ends in one of exts, ignoring case,
+// so "linux-amd64.json.gz" has the extension ".json.gz" but not ".json".
+func HasFileExtension(name string, exts []string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range exts {
+		if strings.HasSuffix(name, strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrimFileExtension returns name without the first of exts it ends in.
+func TrimFileExtension(name string, exts []string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range exts {
+		if strings.HasSuffix(lower, strings.ToLower(ext)) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// ValidateFileExtensions checks that each of exts is a file extension the reports of
+// scanner can have. The built-in scanners only read JSON; other formats, such as
+// .sarif or .json.gz, need a copa-<scanner> plugin that reads them.
+func ValidateFileExtensions(scanner string, exts []string) error {
+	_, builtin := scanReportParsers[scanner]
+	for _, ext := range exts {
+		if len(ext) < 2 || !strings.HasPrefix(ext, ".") || strings.ContainsAny(ext, `/\*?[`) {
+			return fmt.Errorf("invalid report file extension %q: must start with a dot, e.g. .json", ext)
+		}
+		if builtin && !slices.Contains(builtinFileExtensions, strings.ToLower(ext)) {
+			return fmt.Errorf("the %s scanner reads %s reports; %s reports need a copa-<scanner> plugin",
+				scanner, strings.Join(builtinFileExtensions, " and "), ext)
+		}
+	}
+	return nil
+}
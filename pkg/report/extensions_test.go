@@ -0,0 +1,51 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasFileExtension(t *testing.T) {
+	exts := []string{".json", ".json.gz"}
+	assert.True(t, HasFileExtension("linux-amd64.json", exts))
+	assert.True(t, HasFileExtension("linux-amd64.JSON", exts))
+	assert.True(t, HasFileExtension("linux-arm64.json.gz", exts))
+	assert.False(t, HasFileExtension("linux-arm64.sarif", exts))
+	assert.False(t, HasFileExtension("linux-arm64.json.gz", []string{".json"}))
+
+	assert.Equal(t, "linux-arm64", TrimFileExtension("linux-arm64.json.gz", []string{".json.gz"}))
+	assert.Equal(t, "notes.txt", TrimFileExtension("notes.txt", exts))
+}
+
+func TestFileExtensionsOrDefault(t *testing.T) {
+	assert.Equal(t, []string{".json", ".sarif"}, FileExtensionsOrDefault([]string{".json", ".sarif"}))
+	assert.Equal(t, []string{DefaultFileExtension}, FileExtensionsOrDefault(nil))
+}
+
+func TestValidateFileExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		scanner string
+		exts    []string
+		wantErr string
+	}{
+		{name: "default", scanner: "trivy"},
+		{name: "JSON Lines", scanner: "native", exts: []string{".json", ".jsonl"}},
+		{name: "SARIF needs a plugin", scanner: "trivy", exts: []string{".sarif"}, wantErr: ".sarif reports need a copa-<scanner> plugin"},
+		{name: "plugin reads any extension", scanner: "grype", exts: []string{".sarif", ".json.gz"}},
+		{name: "missing dot", scanner: "grype", exts: []string{"sarif"}, wantErr: "must start with a dot"},
+		{name: "pattern", scanner: "grype", exts: []string{".js*"}, wantErr: "must start with a dot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFileExtensions(tt.scanner, tt.exts)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
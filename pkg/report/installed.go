@@ -0,0 +1,55 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	scanutils "github.com/aquasecurity/trivy/pkg/scan/utils"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+// ErrNoInstalledPackages is returned when a report does not list the installed OS
+// packages, as Trivy reports only do when generated with --list-all-pkgs.
+var ErrNoInstalledPackages = errors.New("report does not list installed OS packages; generate it with trivy --list-all-pkgs")
+
+// ListInstalledOSPackages returns the OS packages a Trivy report lists as installed,
+// vulnerable or not, with their installed versions and sorted by name. Only Trivy
+// reports record them.
+func ListInstalledOSPackages(file, scanner string) (unversioned.UpdatePackages, error) {
+	if scanner != "trivy" {
+		return nil, fmt.Errorf("listing installed packages requires a trivy report, got scanner %q", scanner)
+	}
+	reports, err := parseTrivyReports(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed unversioned.UpdatePackages
+	seen := make(map[string]bool)
+	for _, report := range reports {
+		for i := range report.Results {
+			r := &report.Results[i]
+			if r.Class != "os-pkgs" {
+				continue
+			}
+			for _, pkg := range r.Packages {
+				if seen[pkg.Name] {
+					continue
+				}
+				seen[pkg.Name] = true
+				installed = append(installed, unversioned.UpdatePackage{
+					Name:             pkg.Name,
+					InstalledVersion: scanutils.FormatVersion(pkg),
+				})
+			}
+		}
+	}
+	if len(installed) == 0 {
+		return nil, fmt.Errorf("%s: %w", file, ErrNoInstalledPackages)
+	}
+
+	sort.Slice(installed, func(i, j int) bool { return installed[i].Name < installed[j].Name })
+	return installed, nil
+}
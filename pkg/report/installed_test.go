@@ -0,0 +1,31 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+func TestListInstalledOSPackages(t *testing.T) {
+	installed, err := ListInstalledOSPackages("testdata/trivy_list_all_pkgs.json", "trivy")
+	require.NoError(t, err)
+	assert.Equal(t, unversioned.UpdatePackages{
+		{Name: "apk-tools", InstalledVersion: "2.12.5-r1"},
+		{Name: "busybox", InstalledVersion: "1.33.1-r2"},
+		{Name: "musl", InstalledVersion: "1.2.2-r3"},
+		{Name: "musl-utils", InstalledVersion: "1.2.2-r3"},
+	}, installed)
+
+	// Without --list-all-pkgs only vulnerabilities are recorded.
+	_, err = ListInstalledOSPackages("testdata/trivy_valid.json", "trivy")
+	assert.ErrorIs(t, err, ErrNoInstalledPackages)
+
+	_, err = ListInstalledOSPackages("testdata/trivy_list_all_pkgs.json", "grype")
+	assert.ErrorContains(t, err, "requires a trivy report")
+
+	_, err = ListInstalledOSPackages("testdata/missing.json", "trivy")
+	assert.Error(t, err)
+}
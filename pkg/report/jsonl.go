@@ -0,0 +1,74 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+// utf8BOM is the byte order mark some Windows tools write at the start of a file.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// trimBOM strips a leading UTF-8 byte order mark, which encoding/json rejects.
+func trimBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
+}
+
+// firstJSONValue returns the first JSON value in data and whatever non-whitespace
+// content follows it.
+func firstJSONValue(data []byte) (json.RawMessage, []byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var v json.RawMessage
+	if err := dec.Decode(&v); err != nil {
+		return nil, nil, err
+	}
+	return v, bytes.TrimSpace(data[dec.InputOffset():]), nil
+}
+
+// splitJSONLines splits newline-delimited JSON into its documents, skipping blank
+// lines. It reports false unless every non-blank line is a valid JSON document.
+func splitJSONLines(data []byte) ([][]byte, bool) {
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, false
+		}
+		lines = append(lines, line)
+	}
+	return lines, len(lines) > 0
+}
+
+// mergeUpdateManifests combines manifests parsed from several reports of the same
+// image into one. Metadata is taken from the first manifest that has OS information,
+// and manifests describing a different OS are rejected.
+func mergeUpdateManifests(manifests []*unversioned.UpdateManifest) (*unversioned.UpdateManifest, error) {
+	switch len(manifests) {
+	case 0:
+		return nil, fmt.Errorf("no reports to merge")
+	case 1:
+		return manifests[0], nil
+	}
+
+	merged := &unversioned.UpdateManifest{}
+	for _, m := range manifests {
+		if m == nil {
+			continue
+		}
+		if merged.Metadata.OS.Type == "" {
+			merged.Metadata = m.Metadata
+		} else if m.Metadata.OS.Type != "" && m.Metadata.OS != merged.Metadata.OS {
+			return nil, fmt.Errorf("cannot merge reports for different operating systems: %s %s and %s %s",
+				merged.Metadata.OS.Type, merged.Metadata.OS.Version, m.Metadata.OS.Type, m.Metadata.OS.Version)
+		}
+		merged.OSUpdates = append(merged.OSUpdates, m.OSUpdates...)
+		merged.LangUpdates = append(merged.LangUpdates, m.LangUpdates...)
+		merged.Unfixed = append(merged.Unfixed, m.Unfixed...)
+	}
+	return merged, nil
+}
@@ -0,0 +1,56 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func TestSplitJSONLines(t *testing.T) {
+	lines, ok := splitJSONLines([]byte("{\"a\":1}\r\n\n  \n{\"b\":2}\n"))
+	require.True(t, ok)
+	assert.Equal(t, [][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)}, lines)
+
+	_, ok = splitJSONLines([]byte("{\"a\":1}\nnot json\n"))
+	assert.False(t, ok)
+
+	_, ok = splitJSONLines([]byte("\n\n"))
+	assert.False(t, ok)
+}
+
+func TestParseJSONLinesReport(t *testing.T) {
+	manifest, err := TryParseScanReport("testdata/native_multi.jsonl", "native", utils.PkgTypeOS, utils.PatchTypePatch)
+	require.NoError(t, err)
+
+	assert.Equal(t, utils.OSTypeAlpine, manifest.Metadata.OS.Type)
+	assert.Equal(t, "3.14.0", manifest.Metadata.OS.Version)
+	require.Len(t, manifest.OSUpdates, 2)
+	assert.Equal(t, "openssl", manifest.OSUpdates[0].Name)
+	assert.Equal(t, "busybox", manifest.OSUpdates[1].Name)
+}
+
+func TestMergeUpdateManifests(t *testing.T) {
+	alpine := unversioned.Metadata{OS: unversioned.OS{Type: "alpine", Version: "3.19"}}
+
+	_, err := mergeUpdateManifests(nil)
+	assert.Error(t, err)
+
+	_, err = mergeUpdateManifests([]*unversioned.UpdateManifest{
+		{Metadata: alpine},
+		{Metadata: unversioned.Metadata{OS: unversioned.OS{Type: "debian", Version: "12"}}},
+	})
+	assert.ErrorContains(t, err, "different operating systems")
+
+	merged, err := mergeUpdateManifests([]*unversioned.UpdateManifest{
+		{LangUpdates: unversioned.LangUpdatePackages{{Name: "requests"}}},
+		{Metadata: alpine, OSUpdates: unversioned.UpdatePackages{{Name: "zlib"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, alpine, merged.Metadata)
+	assert.Len(t, merged.OSUpdates, 1)
+	assert.Len(t, merged.LangUpdates, 1)
+}
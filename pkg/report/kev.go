@@ -0,0 +1,211 @@
+package report
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultKEVCatalogURL is CISA's Known Exploited Vulnerabilities catalog feed.
+const DefaultKEVCatalogURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+// kevCacheTTL is how long a downloaded catalog is reused before it is fetched again.
+// CISA updates the catalog at most a few times a day.
+const kevCacheTTL = 24 * time.Hour
+
+// for testing.
+var (
+	kevHTTPClient = &http.Client{Timeout: 30 * time.Second}
+	kevCacheDir   = os.UserCacheDir
+)
+
+// KEVCatalog is a set of CVE IDs known to be exploited in the wild.
+type KEVCatalog struct {
+	Version string
+	cves    map[string]struct{}
+}
+
+// Contains reports whether id is listed in the catalog.
+func (c *KEVCatalog) Contains(id string) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.cves[strings.ToUpper(id)]
+	return ok
+}
+
+// Len returns the number of CVEs in the catalog.
+func (c *KEVCatalog) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.cves)
+}
+
+type kevFeed struct {
+	CatalogVersion  string `json:"catalogVersion"`
+	Vulnerabilities []struct {
+		CVEID string `json:"cveID"`
+	} `json:"vulnerabilities"`
+}
+
+// ParseKEVCatalog parses a catalog in CISA's KEV JSON format.
+func ParseKEVCatalog(data []byte) (*KEVCatalog, error) {
+	var feed kevFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse KEV catalog: %w", err)
+	}
+	if len(feed.Vulnerabilities) == 0 {
+		return nil, fmt.Errorf("KEV catalog lists no vulnerabilities")
+	}
+
+	catalog := &KEVCatalog{Version: feed.CatalogVersion, cves: make(map[string]struct{}, len(feed.Vulnerabilities))}
+	for _, v := range feed.Vulnerabilities {
+		if v.CVEID != "" {
+			catalog.cves[strings.ToUpper(v.CVEID)] = struct{}{}
+		}
+	}
+	return catalog, nil
+}
+
+// LoadKEVCatalog loads a KEV catalog from source, which is either a local file, for
+// air-gapped use, or an http(s) URL. An empty source loads DefaultKEVCatalogURL.
+// Downloaded catalogs are cached in the user cache directory, and a stale cached copy
+// is used if the feed cannot be reached.
+func LoadKEVCatalog(ctx context.Context, source string) (*KEVCatalog, error) {
+	if source == "" {
+		source = DefaultKEVCatalogURL
+	}
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read KEV catalog %s: %w", source, err)
+		}
+		return ParseKEVCatalog(data)
+	}
+
+	cachePath := kevCachePath(source)
+	if cachePath != "" {
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < kevCacheTTL {
+			if data, err := os.ReadFile(cachePath); err == nil {
+				if catalog, err := ParseKEVCatalog(data); err == nil {
+					log.Debugf("Using cached KEV catalog %s", cachePath)
+					return catalog, nil
+				}
+			}
+		}
+	}
+
+	data, err := fetchKEVCatalog(ctx, source)
+	if err == nil {
+		var catalog *KEVCatalog
+		if catalog, err = ParseKEVCatalog(data); err == nil {
+			if cachePath != "" {
+				if err := writeKEVCache(cachePath, data); err != nil {
+					log.Debugf("Failed to cache KEV catalog: %v", err)
+				}
+			}
+			return catalog, nil
+		}
+	}
+
+	if cachePath != "" {
+		if cached, readErr := os.ReadFile(cachePath); readErr == nil {
+			if catalog, parseErr := ParseKEVCatalog(cached); parseErr == nil {
+				log.Warnf("Failed to update KEV catalog from %s, using cached copy: %v", source, err)
+				return catalog, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+func fetchKEVCatalog(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KEV catalog request: %w", err)
+	}
+	resp, err := kevHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch KEV catalog from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch KEV catalog from %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KEV catalog from %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// kevCachePath returns where the catalog downloaded from url is cached, or "" if the
+// user has no cache directory.
+func kevCachePath(url string) string {
+	dir, err := kevCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "copa", "kev-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+func writeKEVCache(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// applyKEVCatalog marks the updates in manifest whose vulnerability is in catalog and,
+// when only is set, removes every other update.
+func applyKEVCatalog(manifest *unversioned.UpdateManifest, catalog *KEVCatalog, only bool) {
+	if manifest == nil || catalog == nil {
+		return
+	}
+	listed := make(map[string]struct{})
+	mark := func(updates []unversioned.UpdatePackage) []unversioned.UpdatePackage {
+		filtered := updates[:0]
+		for _, u := range updates {
+			u.KnownExploited = catalog.Contains(u.VulnerabilityID)
+			if u.KnownExploited {
+				listed[u.VulnerabilityID] = struct{}{}
+			} else if only {
+				continue
+			}
+			filtered = append(filtered, u)
+		}
+		return filtered
+	}
+	manifest.OSUpdates = mark(manifest.OSUpdates)
+	manifest.LangUpdates = mark(manifest.LangUpdates)
+	manifest.Unfixed = mark(manifest.Unfixed)
+
+	if len(listed) == 0 {
+		log.Infof("No vulnerabilities in the report are in the KEV catalog %s", catalog.Version)
+		return
+	}
+	ids := make([]string, 0, len(listed))
+	for id := range listed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	log.Infof("%d known exploited vulnerabilities (KEV catalog %s): %s", len(ids), catalog.Version, strings.Join(ids, ", "))
+}
@@ -0,0 +1,125 @@
+package report
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKEVCatalog = `{
+	"title": "CISA Catalog of Known Exploited Vulnerabilities",
+	"catalogVersion": "2024.06.03",
+	"count": 2,
+	"vulnerabilities": [
+		{"cveID": "CVE-2023-4911", "vendorProject": "GNU", "product": "GNU C Library"},
+		{"cveID": "CVE-2024-3094", "vendorProject": "XZ Utils", "product": "XZ Utils"}
+	]
+}`
+
+func TestParseKEVCatalog(t *testing.T) {
+	catalog, err := ParseKEVCatalog([]byte(testKEVCatalog))
+	require.NoError(t, err)
+	assert.Equal(t, "2024.06.03", catalog.Version)
+	assert.Equal(t, 2, catalog.Len())
+	assert.True(t, catalog.Contains("CVE-2023-4911"))
+	assert.True(t, catalog.Contains("cve-2024-3094"))
+	assert.False(t, catalog.Contains("CVE-2023-0001"))
+
+	_, err = ParseKEVCatalog([]byte(`{"vulnerabilities": []}`))
+	assert.Error(t, err)
+	_, err = ParseKEVCatalog([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestLoadKEVCatalogFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kev.json")
+	require.NoError(t, os.WriteFile(path, []byte(testKEVCatalog), 0o600))
+
+	catalog, err := LoadKEVCatalog(context.Background(), path)
+	require.NoError(t, err)
+	assert.True(t, catalog.Contains("CVE-2024-3094"))
+
+	_, err = LoadKEVCatalog(context.Background(), filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestLoadKEVCatalogFromURLIsCached(t *testing.T) {
+	cacheDir := t.TempDir()
+	origCacheDir := kevCacheDir
+	kevCacheDir = func() (string, error) { return cacheDir, nil }
+	defer func() { kevCacheDir = origCacheDir }()
+
+	requests := 0
+	available := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(testKEVCatalog))
+	}))
+	defer srv.Close()
+
+	catalog, err := LoadKEVCatalog(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.True(t, catalog.Contains("CVE-2023-4911"))
+	assert.Equal(t, 1, requests)
+
+	// A fresh cached copy is used without contacting the feed.
+	_, err = LoadKEVCatalog(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	// A stale cached copy is used when the feed is unavailable.
+	stale := time.Now().Add(-2 * kevCacheTTL)
+	require.NoError(t, os.Chtimes(kevCachePath(srv.URL), stale, stale))
+	available = false
+	catalog, err = LoadKEVCatalog(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.True(t, catalog.Contains("CVE-2024-3094"))
+	assert.Equal(t, 2, requests)
+}
+
+func TestApplyKEVCatalog(t *testing.T) {
+	catalog, err := ParseKEVCatalog([]byte(testKEVCatalog))
+	require.NoError(t, err)
+
+	newManifest := func() *unversioned.UpdateManifest {
+		return &unversioned.UpdateManifest{
+			OSUpdates: unversioned.UpdatePackages{
+				{Name: "libc6", VulnerabilityID: "CVE-2023-4911"},
+				{Name: "libssl3", VulnerabilityID: "CVE-2024-0727"},
+			},
+			LangUpdates: unversioned.LangUpdatePackages{
+				{Name: "requests", VulnerabilityID: "CVE-2024-35195"},
+			},
+		}
+	}
+
+	t.Run("mark", func(t *testing.T) {
+		manifest := newManifest()
+		applyKEVCatalog(manifest, catalog, false)
+		require.Len(t, manifest.OSUpdates, 2)
+		require.Len(t, manifest.LangUpdates, 1)
+		assert.True(t, manifest.OSUpdates[0].KnownExploited)
+		assert.False(t, manifest.OSUpdates[1].KnownExploited)
+		assert.False(t, manifest.LangUpdates[0].KnownExploited)
+	})
+
+	t.Run("only", func(t *testing.T) {
+		manifest := newManifest()
+		applyKEVCatalog(manifest, catalog, true)
+		require.Len(t, manifest.OSUpdates, 1)
+		assert.Equal(t, "libc6", manifest.OSUpdates[0].Name)
+		assert.Empty(t, manifest.LangUpdates)
+	})
+}
@@ -0,0 +1,113 @@
+package report
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// leadingNumberPattern matches the first numeric component of a version.
+var leadingNumberPattern = regexp.MustCompile(`^\d+`)
+
+// versionLine identifies the compatibility line of a version in an ecosystem: two
+// versions on different lines are a major upgrade apart.
+type versionLine struct {
+	epoch string
+	major int
+	// minor is set only where the ecosystem treats minor bumps below 1.0 as breaking
+	minor int
+}
+
+// parseVersionLine returns the compatibility line of version for pkgType, or false when
+// the version has no leading number to compare.
+func parseVersionLine(pkgType, version string) (versionLine, bool) {
+	var line versionLine
+	version = strings.TrimSpace(version)
+
+	switch pkgType {
+	case utils.PythonPackages:
+		// PEP 440 epochs are written as N!
+		if epoch, rest, ok := strings.Cut(version, "!"); ok {
+			line.epoch, version = epoch, rest
+		}
+	case utils.NodePackages, utils.GoModules, utils.GoBinary, utils.DotNetPackages:
+		version = strings.TrimPrefix(version, "v")
+	default:
+		// OS package versions are [epoch:]upstream[-revision]
+		if epoch, rest, ok := strings.Cut(version, ":"); ok && leadingNumberPattern.MatchString(epoch) {
+			line.epoch, version = epoch, rest
+		}
+	}
+
+	majorStr := leadingNumberPattern.FindString(version)
+	if majorStr == "" {
+		return line, false
+	}
+	line.major, _ = strconv.Atoi(majorStr)
+
+	// npm's caret ranges treat 0.x minor releases as breaking
+	if pkgType == utils.NodePackages && line.major == 0 {
+		rest := strings.TrimPrefix(version, majorStr+".")
+		if minorStr := leadingNumberPattern.FindString(rest); minorStr != "" {
+			line.minor, _ = strconv.Atoi(minorStr)
+		}
+	}
+	return line, true
+}
+
+// requiresMajorUpgrade reports whether upgrading from installed to fixed crosses a
+// major version boundary for the ecosystem of pkgType (the report's package type, such
+// as node-pkg or debian). Versions that cannot be compared are not flagged.
+func requiresMajorUpgrade(pkgType, installed, fixed string) bool {
+	if installed == "" || fixed == "" {
+		return false
+	}
+	from, ok := parseVersionLine(pkgType, installed)
+	if !ok {
+		return false
+	}
+	to, ok := parseVersionLine(pkgType, fixed)
+	if !ok {
+		return false
+	}
+	return from != to
+}
+
+// markMajorUpgrades sets FixRequiresMajorUpgrade on updates whose fixed version crosses a
+// major version boundary and warns once per package, so the upgrade can be reviewed
+// before the patched image is trusted.
+func markMajorUpgrades(manifest *unversioned.UpdateManifest) {
+	warned := make(map[string]bool)
+	mark := func(updates unversioned.UpdatePackages) {
+		for i := range updates {
+			u := &updates[i]
+			if _, special := getSpecialPackagePatchLevels()[u.Name]; special {
+				// e.g. certifi is calendar versioned and always upgraded to the latest
+				continue
+			}
+			if u.Status == "" && requiresMajorUpgrade(u.Type, u.InstalledVersion, u.FixedVersion) {
+				u.Status = unversioned.FixRequiresMajorUpgrade
+			}
+			if u.Status != unversioned.FixRequiresMajorUpgrade {
+				continue
+			}
+			key := u.Name + "\x00" + u.PkgPath
+			if warned[key] {
+				continue
+			}
+			warned[key] = true
+			if u.FixedVersion == "" {
+				log.Warnf("%s %s: every available fix requires a major version upgrade, not updating at the current patch level", u.Name, u.InstalledVersion)
+			} else {
+				log.Warnf("%s: fix requires a major version upgrade from %s to %s, review before trusting the patched image", u.Name, u.InstalledVersion, u.FixedVersion)
+			}
+		}
+	}
+	mark(manifest.OSUpdates)
+	mark(unversioned.UpdatePackages(manifest.LangUpdates))
+}
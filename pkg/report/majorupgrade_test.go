@@ -0,0 +1,89 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func TestRequiresMajorUpgrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		pkgType   string
+		installed string
+		fixed     string
+		want      bool
+	}{
+		{name: "npm major", pkgType: utils.NodePackages, installed: "3.10.1", fixed: "4.17.21", want: true},
+		{name: "npm minor", pkgType: utils.NodePackages, installed: "6.7.0", fixed: "6.10.3"},
+		{name: "npm zero minor is breaking", pkgType: utils.NodePackages, installed: "0.2.1", fixed: "0.3.0", want: true},
+		{name: "npm zero patch", pkgType: utils.NodePackages, installed: "0.2.1", fixed: "0.2.4"},
+		{name: "python zero minor is not breaking", pkgType: utils.PythonPackages, installed: "0.2.1", fixed: "0.3.0"},
+		{name: "python major", pkgType: utils.PythonPackages, installed: "1.26.18", fixed: "2.0.7", want: true},
+		{name: "python epoch", pkgType: utils.PythonPackages, installed: "2.0", fixed: "1!2.1", want: true},
+		{name: "go module prefix", pkgType: utils.GoModules, installed: "v0.17.0", fixed: "v0.23.0"},
+		{name: "dotnet major", pkgType: utils.DotNetPackages, installed: "12.0.3", fixed: "13.0.1", want: true},
+		{name: "deb revision", pkgType: "debian", installed: "3.0.11-1~deb12u2", fixed: "3.0.13-1~deb12u1"},
+		{name: "deb upstream major", pkgType: "debian", installed: "1.1.1n-0+deb11u4", fixed: "3.0.11-1~deb12u2", want: true},
+		{name: "rpm epoch", pkgType: "redhat", installed: "1:1.1.1k-9.el8", fixed: "2:1.1.1k-12.el8", want: true},
+		{name: "apk revision", pkgType: "alpine", installed: "3.1.4-r5", fixed: "3.1.6-r0"},
+		{name: "unparseable", pkgType: "debian", installed: "git20230101", fixed: "2.0"},
+		{name: "no fix", pkgType: utils.NodePackages, installed: "3.10.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, requiresMajorUpgrade(tt.pkgType, tt.installed, tt.fixed))
+		})
+	}
+}
+
+func TestMarkMajorUpgrades(t *testing.T) {
+	manifest := &unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{
+			{Name: "libssl3", Type: "debian", InstalledVersion: "3.0.11-1~deb12u2", FixedVersion: "3.0.13-1~deb12u1"},
+			{Name: "libssl1.1", Type: "debian", InstalledVersion: "1.1.1n-0+deb11u4", FixedVersion: "3.0.11-1~deb12u2"},
+		},
+		LangUpdates: unversioned.LangUpdatePackages{
+			{Name: "lodash", Type: utils.NodePackages, InstalledVersion: "3.10.1", FixedVersion: "4.17.21"},
+			{Name: "certifi", Type: utils.PythonPackages, InstalledVersion: "2021.10.8", FixedVersion: "2024.2.2"},
+		},
+	}
+
+	markMajorUpgrades(manifest)
+
+	assert.Empty(t, manifest.OSUpdates[0].Status)
+	assert.Equal(t, unversioned.FixRequiresMajorUpgrade, manifest.OSUpdates[1].Status)
+	assert.Equal(t, unversioned.FixRequiresMajorUpgrade, manifest.LangUpdates[0].Status)
+	assert.Empty(t, manifest.LangUpdates[1].Status)
+}
+
+func TestTrivyParserMajorUpgradeStatus(t *testing.T) {
+	statuses := func(m *unversioned.UpdateManifest) map[string][2]string {
+		got := make(map[string][2]string)
+		for _, u := range m.LangUpdates {
+			got[u.Name] = [2]string{u.FixedVersion, u.Status}
+		}
+		return got
+	}
+
+	// At the patch level the lodash fix is ruled out, and the status says why
+	manifest, err := (&TrivyParser{}).ParseWithLibraryPatchLevel("testdata/trivy_major_upgrade.json", utils.PatchTypePatch)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][2]string{
+		"lodash": {"", unversioned.FixRequiresMajorUpgrade},
+		"qs":     {"6.7.3", ""},
+	}, statuses(manifest))
+
+	// At the major level the fix is selected and flagged for review
+	manifest, err = TryParseScanReport("testdata/trivy_major_upgrade.json", "trivy", utils.PkgTypeLibrary, utils.PatchTypeMajor)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][2]string{
+		"lodash": {"4.17.21", unversioned.FixRequiresMajorUpgrade},
+		"qs":     {"6.10.3", ""},
+	}, statuses(manifest))
+}
@@ -0,0 +1,235 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	pep440 "github.com/aquasecurity/go-pep440-version"
+	apkVer "github.com/knqyf263/go-apk-version"
+	debVer "github.com/knqyf263/go-deb-version"
+	rpmVer "github.com/knqyf263/go-rpm-version"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// Policy governs which package upgrades Copa may apply. A package matching a deny rule
+// is held; when allow rules are given, so is every package matching none of them.
+type Policy struct {
+	Allow []PolicyRule `yaml:"allow"`
+	Deny  []PolicyRule `yaml:"deny"`
+}
+
+// PolicyRule matches packages by name and, optionally, by the version they would be
+// upgraded to.
+type PolicyRule struct {
+	// Name is a package name or a path.Match pattern such as "openssl*"
+	Name string `yaml:"name"`
+	// Versions is a comma-separated list of constraints on the fixed version, such as
+	// ">=3.0.0, <3.2.0". Empty matches any version.
+	Versions string `yaml:"versions"`
+
+	bounds []versionBound
+}
+
+type versionBound struct {
+	op      string
+	version string
+}
+
+// versionOperators is ordered so that two-character operators are matched first.
+var versionOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// LoadPolicy reads a policy file in YAML or JSON.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %w", file, err)
+	}
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %s: %w", file, err)
+	}
+	for _, rules := range [][]PolicyRule{policy.Allow, policy.Deny} {
+		for i := range rules {
+			if err := rules[i].compile(); err != nil {
+				return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+func (r *PolicyRule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule without a package name")
+	}
+	if _, err := path.Match(r.Name, ""); err != nil {
+		return fmt.Errorf("invalid package name pattern %q: %w", r.Name, err)
+	}
+	r.bounds = nil
+	for _, c := range strings.Split(r.Versions, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		op := "="
+		for _, candidate := range versionOperators {
+			if strings.HasPrefix(c, candidate) {
+				op = candidate
+				break
+			}
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(c, op))
+		if version == "" {
+			return fmt.Errorf("rule %s: constraint %q has no version", r.Name, c)
+		}
+		r.bounds = append(r.bounds, versionBound{op: op, version: version})
+	}
+	return nil
+}
+
+// matches reports whether u is covered by the rule. A rule with version constraints
+// never matches an update without a fixed version.
+func (r *PolicyRule) matches(u *unversioned.UpdatePackage) bool {
+	if ok, _ := path.Match(r.Name, u.Name); !ok {
+		return false
+	}
+	if len(r.bounds) > 0 && u.FixedVersion == "" {
+		return false
+	}
+	for _, b := range r.bounds {
+		cmp := comparePolicyVersions(u.Type, u.FixedVersion, b.version)
+		var ok bool
+		switch b.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// holds returns the rule that holds u back, or "" when the policy allows the upgrade.
+func (p *Policy) holds(u *unversioned.UpdatePackage) string {
+	for i := range p.Deny {
+		if p.Deny[i].matches(u) {
+			return "denied by rule " + p.Deny[i].describe()
+		}
+	}
+	if len(p.Allow) == 0 {
+		return ""
+	}
+	for i := range p.Allow {
+		if p.Allow[i].matches(u) {
+			return ""
+		}
+	}
+	return "not matched by any allow rule"
+}
+
+func (r *PolicyRule) describe() string {
+	if r.Versions == "" {
+		return r.Name
+	}
+	return r.Name + " " + r.Versions
+}
+
+// comparePolicyVersions compares two versions of a package of pkgType (the report's
+// package type) with the ordering of its ecosystem, returning -1, 0 or 1. Versions that
+// can't be parsed for the ecosystem fall back to a numeric comparison.
+func comparePolicyVersions(pkgType, v1, v2 string) int {
+	return sign(compareEcosystemVersions(pkgType, v1, v2))
+}
+
+func compareEcosystemVersions(pkgType, v1, v2 string) int {
+	switch osType := utils.CanonicalOSType(pkgType); {
+	case utils.CanonicalPkgManagerType(osType) == "apk":
+		a, err1 := apkVer.NewVersion(v1)
+		b, err2 := apkVer.NewVersion(v2)
+		if err1 == nil && err2 == nil {
+			return a.Compare(b)
+		}
+	case osType == utils.OSTypeDebian || osType == utils.OSTypeUbuntu:
+		a, err1 := debVer.NewVersion(v1)
+		b, err2 := debVer.NewVersion(v2)
+		if err1 == nil && err2 == nil {
+			return a.Compare(b)
+		}
+	case slices.Contains(utils.RPMDistros, osType):
+		return rpmVer.NewVersion(v1).Compare(rpmVer.NewVersion(v2))
+	case pkgType == utils.PythonPackages:
+		a, err1 := pep440.Parse(v1)
+		b, err2 := pep440.Parse(v2)
+		if err1 == nil && err2 == nil {
+			return a.Compare(b)
+		}
+	default:
+		a, err1 := semver.NewVersion(v1)
+		b, err2 := semver.NewVersion(v2)
+		if err1 == nil && err2 == nil {
+			return a.Compare(b)
+		}
+	}
+	return compareVersions(v1, v2)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// applyPolicy moves the updates that p holds back from the OS and language updates of
+// manifest to its unfixed list, with their status set to unversioned.HeldByPolicy, so
+// they are not installed but are still reported. It returns the number of updates held.
+func applyPolicy(manifest *unversioned.UpdateManifest, p *Policy) int {
+	if manifest == nil || p == nil {
+		return 0
+	}
+	held := 0
+	warned := make(map[string]bool)
+	filter := func(updates []unversioned.UpdatePackage) []unversioned.UpdatePackage {
+		kept := updates[:0]
+		for _, u := range updates {
+			reason := p.holds(&u)
+			if reason == "" {
+				kept = append(kept, u)
+				continue
+			}
+			if key := u.Name + "\x00" + u.PkgPath; !warned[key] {
+				warned[key] = true
+				log.Infof("%s %s -> %s held by policy: %s", u.Name, u.InstalledVersion, u.FixedVersion, reason)
+			}
+			u.Status = unversioned.HeldByPolicy
+			manifest.Unfixed = append(manifest.Unfixed, u)
+			held++
+		}
+		return kept
+	}
+	manifest.OSUpdates = filter(manifest.OSUpdates)
+	manifest.LangUpdates = filter(manifest.LangUpdates)
+	return held
+}
@@ -0,0 +1,157 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func writePolicy(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestLoadPolicy(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		p, err := LoadPolicy(writePolicy(t, "policy.yaml", `
+allow:
+  - name: "lib*"
+deny:
+  - name: openssl
+    versions: ">= 3.2, <4"
+`))
+		require.NoError(t, err)
+		require.Len(t, p.Allow, 1)
+		require.Len(t, p.Deny, 1)
+		assert.Equal(t, []versionBound{{op: ">=", version: "3.2"}, {op: "<", version: "4"}}, p.Deny[0].bounds)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		p, err := LoadPolicy(writePolicy(t, "policy.json", `{"deny":[{"name":"glibc"}]}`))
+		require.NoError(t, err)
+		assert.Equal(t, "glibc", p.Deny[0].Name)
+		assert.Empty(t, p.Allow)
+	})
+
+	errTests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "rule without name", content: "deny:\n  - versions: '>1'\n", wantErr: "rule without a package name"},
+		{name: "bad pattern", content: "deny:\n  - name: 'lib['\n", wantErr: "invalid package name pattern"},
+		{name: "constraint without version", content: "allow:\n  - name: curl\n    versions: '>=, <8'\n", wantErr: `constraint ">=" has no version`},
+		{name: "not a policy", content: "deny: glibc\n", wantErr: "failed to parse policy"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPolicy(writePolicy(t, "policy.yaml", tt.content))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read policy")
+}
+
+func TestApplyPolicy(t *testing.T) {
+	p, err := LoadPolicy(writePolicy(t, "policy.yaml", `
+allow:
+  - name: "lib*"
+  - name: openssl
+  - name: requests
+    versions: "<3"
+deny:
+  - name: libc6
+  - name: openssl
+    versions: ">=3.1"
+`))
+	require.NoError(t, err)
+
+	manifest := &unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{
+			{Name: "libssl3", InstalledVersion: "3.0.11-1~deb12u2", FixedVersion: "3.0.13-1~deb12u1", VulnerabilityID: "CVE-2024-0727", Type: utils.OSTypeDebian},
+			{Name: "libc6", InstalledVersion: "2.36-9+deb12u4", FixedVersion: "2.36-9+deb12u7", VulnerabilityID: "CVE-2024-2961", Type: utils.OSTypeDebian},
+			{Name: "openssl", InstalledVersion: "3.0.11-1~deb12u2", FixedVersion: "3.0.13-1~deb12u1", VulnerabilityID: "CVE-2024-0727", Type: utils.OSTypeDebian},
+			{Name: "curl", InstalledVersion: "7.88.1-10+deb12u5", FixedVersion: "7.88.1-10+deb12u6", VulnerabilityID: "CVE-2024-2398", Type: utils.OSTypeDebian},
+		},
+		LangUpdates: unversioned.LangUpdatePackages{
+			{Name: "requests", InstalledVersion: "2.31.0", FixedVersion: "2.32.0", VulnerabilityID: "CVE-2024-35195", Type: utils.PythonPackages},
+			{Name: "requests", InstalledVersion: "2.31.0", FixedVersion: "3.1.0", VulnerabilityID: "CVE-2099-0001", Type: utils.PythonPackages},
+		},
+		Unfixed: unversioned.UpdatePackages{{Name: "zlib1g", VulnerabilityID: "CVE-2023-45853", Type: utils.OSTypeDebian}},
+	}
+
+	assert.Equal(t, 3, applyPolicy(manifest, p))
+
+	names := func(updates []unversioned.UpdatePackage) []string {
+		var out []string
+		for _, u := range updates {
+			out = append(out, u.Name+"@"+u.FixedVersion)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"libssl3@3.0.13-1~deb12u1", "openssl@3.0.13-1~deb12u1"}, names(manifest.OSUpdates))
+	assert.Equal(t, []string{"requests@2.32.0"}, names(manifest.LangUpdates))
+	// libc6 is denied, curl is not allowed and requests 3.1.0 is outside its range.
+	assert.Equal(t, []string{"zlib1g@", "libc6@2.36-9+deb12u7", "curl@7.88.1-10+deb12u6", "requests@3.1.0"}, names(manifest.Unfixed))
+	assert.Empty(t, manifest.Unfixed[0].Status)
+	for _, u := range manifest.Unfixed[1:] {
+		assert.Equal(t, unversioned.HeldByPolicy, u.Status)
+	}
+
+	// A denied version range holds only the matching fixed versions.
+	manifest = &unversioned.UpdateManifest{OSUpdates: unversioned.UpdatePackages{
+		{Name: "openssl", FixedVersion: "3.1.4-r5", Type: utils.OSTypeAlpine},
+	}}
+	assert.Equal(t, 1, applyPolicy(manifest, p))
+	assert.Empty(t, manifest.OSUpdates)
+
+	assert.Zero(t, applyPolicy(manifest, nil))
+}
+
+func TestComparePolicyVersions(t *testing.T) {
+	tests := []struct {
+		pkgType string
+		v1, v2  string
+		want    int
+	}{
+		{utils.OSTypeDebian, "1:2.0-1", "3.0-1", 1},
+		{utils.OSTypeUbuntu, "3.0.2-0ubuntu1.15", "3.0.2-0ubuntu1.9", 1},
+		{utils.OSTypeAlpine, "3.1.4-r5", "3.1.4-r10", -1},
+		{utils.OSTypeRocky, "3.0.7-27.el9", "3.0.7-27.el9", 0},
+		{utils.PythonPackages, "3.0.0rc1", "3.0.0", -1},
+		{utils.NodePackages, "4.17.21", "4.17.3", 1},
+		{utils.NodePackages, "not-a-version", "1.0.0", 1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, comparePolicyVersions(tt.pkgType, tt.v1, tt.v2), "%s %s vs %s", tt.pkgType, tt.v1, tt.v2)
+	}
+}
+
+func TestTryParseScanReportWithPolicy(t *testing.T) {
+	p, err := LoadPolicy(writePolicy(t, "policy.yaml", `
+deny:
+  - name: apk-tools
+`))
+	require.NoError(t, err)
+
+	manifest, err := TryParseScanReportWithOptions("testdata/trivy_valid.json", "trivy", utils.PkgTypeOS, utils.PatchTypePatch, &ParseOptions{Policy: p})
+	require.NoError(t, err)
+	assert.Empty(t, manifest.OSUpdates)
+	require.Len(t, manifest.Unfixed, 1)
+	assert.Equal(t, unversioned.HeldByPolicy, manifest.Unfixed[0].Status)
+
+	// The policy of one parse does not leak into the next.
+	manifest, err = TryParseScanReport("testdata/trivy_valid.json", "trivy", utils.PkgTypeOS, utils.PatchTypePatch)
+	require.NoError(t, err)
+	assert.Len(t, manifest.OSUpdates, 1)
+}
@@ -6,12 +6,15 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 	"github.com/project-copacetic/copacetic/pkg/types/v1alpha1"
 	"github.com/project-copacetic/copacetic/pkg/types/v1alpha2"
 	"github.com/project-copacetic/copacetic/pkg/utils"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -19,22 +22,116 @@ const (
 	v1alpha2APIVersion = "v1alpha2"
 )
 
+// ErrorUnsupported means a report is not in the format a parser reads, e.g. a Grype
+// report given to the Trivy parser, so another parser may still accept it.
 type ErrorUnsupported struct {
 	err error
 }
 
 func (e *ErrorUnsupported) Error() string { return e.err.Error() }
 
+func (e *ErrorUnsupported) Unwrap() error { return e.err }
+
+// ErrorMalformed means a report could not be read as JSON at all, e.g. because it was
+// truncated. No parser accepts it.
+type ErrorMalformed struct {
+	err error
+}
+
+func (e *ErrorMalformed) Error() string { return e.err.Error() }
+
+func (e *ErrorMalformed) Unwrap() error { return e.err }
+
 type ScanReportParser interface {
 	Parse(string) (*unversioned.UpdateManifest, error)
 	ParseWithLibraryPatchLevel(string, string) (*unversioned.UpdateManifest, error)
 }
 
+// scanReportParsers holds the scanners whose reports copa parses itself. Any other
+// scanner name is handled by a "copa-<scanner>" plugin binary found on PATH.
+var scanReportParsers = map[string]func(file, pkgTypes, libraryPatchLevel string, opts *ParseOptions) (*unversioned.UpdateManifest, error){
+	"trivy": defaultParseScanReport,
+	"native": func(file, _, _ string, _ *ParseOptions) (*unversioned.UpdateManifest, error) {
+		return customParseScanReport(file, "native")
+	},
+}
+
+// for testing.
+var lookPath = exec.LookPath
+
+// SupportedScanners returns the sorted names of the built-in scanners.
+func SupportedScanners() []string {
+	names := make([]string, 0, len(scanReportParsers))
+	for name := range scanReportParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateScanner checks that scanner is either built in or provided by a
+// "copa-<scanner>" plugin on PATH.
+func ValidateScanner(scanner string) error {
+	if _, ok := scanReportParsers[scanner]; ok {
+		return nil
+	}
+	if validScannerNamePattern.MatchString(scanner) {
+		if _, err := lookPath("copa-" + scanner); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported scanner %q, supported: %s, or a copa-<scanner> plugin on PATH",
+		scanner, strings.Join(SupportedScanners(), ", "))
+}
+
+// ParseOptions select which of the vulnerabilities in a report become updates. A nil
+// or zero ParseOptions keeps every vulnerability with a fixed version.
+type ParseOptions struct {
+	// SeveritySource picks the severity recorded on each update (empty = highest);
+	// updates rated below MinSeverity are dropped. See NewSeverityOptions.
+	SeveritySource string
+	MinSeverity    string
+
+	// IncludeUnfixed keeps vulnerabilities without a fixed version in the manifest's
+	// Unfixed list, for VEX and other reporting. They are never installed.
+	IncludeUnfixed bool
+
+	// KEVCatalog marks the updates for the vulnerabilities it lists as known
+	// exploited; with KEVOnly, all other updates are dropped. Nil disables both.
+	KEVCatalog *KEVCatalog
+	KEVOnly    bool
+
+	// Policy holds back the upgrades it does not allow; nil allows every upgrade.
+	Policy *Policy
+}
+
 func TryParseScanReport(file, scanner, pkgTypes, libraryPatchLevel string) (*unversioned.UpdateManifest, error) {
-	if scanner == "trivy" {
-		return defaultParseScanReport(file, pkgTypes, libraryPatchLevel)
+	return TryParseScanReportWithOptions(file, scanner, pkgTypes, libraryPatchLevel, nil)
+}
+
+// TryParseScanReportWithOptions is TryParseScanReport with the updates filtered by opts.
+func TryParseScanReportWithOptions(file, scanner, pkgTypes, libraryPatchLevel string, opts *ParseOptions) (*unversioned.UpdateManifest, error) {
+	if opts == nil {
+		opts = &ParseOptions{}
 	}
-	return customParseScanReport(file, scanner)
+	var manifest *unversioned.UpdateManifest
+	var err error
+	if parse, ok := scanReportParsers[scanner]; ok {
+		manifest, err = parse(file, pkgTypes, libraryPatchLevel, opts)
+	} else {
+		manifest, err = customParseScanReport(file, scanner)
+	}
+	if err != nil {
+		return nil, err
+	}
+	filterBySeverity(manifest, opts.MinSeverity)
+	applyKEVCatalog(manifest, opts.KEVCatalog, opts.KEVOnly)
+	held := applyPolicy(manifest, opts.Policy)
+	markMajorUpgrades(manifest)
+	if n := len(manifest.Unfixed) - held; n > 0 {
+		log.Infof("%d reported vulnerabilities have no fixed version and will not be patched", n)
+	}
+	return manifest, nil
 }
 
 // validScannerNamePattern ensures the scanner name is safe for use in binary lookups.
@@ -64,9 +161,14 @@ func customParseScanReport(file, scanner string) (*unversioned.UpdateManifest, e
 		}
 	}
 
+	scannerOutput = trimBOM(scannerOutput)
 	var m map[string]interface{}
 	if err := json.Unmarshal(scannerOutput, &m); err != nil {
-		return nil, fmt.Errorf("error parsing scanner output: %w", err)
+		lines, ok := splitJSONLines(scannerOutput)
+		if !ok {
+			return nil, fmt.Errorf("error parsing scanner output: %w", err)
+		}
+		return convertJSONLinesToUnversionedAPI(lines)
 	}
 
 	// Convert the output to an unversioned UpdateManifest struct
@@ -78,9 +180,27 @@ func customParseScanReport(file, scanner string) (*unversioned.UpdateManifest, e
 	return updateManifest, nil
 }
 
-func defaultParseScanReport(file, pkgTypes, libraryPatchLevel string) (*unversioned.UpdateManifest, error) {
+// convertJSONLinesToUnversionedAPI converts each line of a JSON Lines scanner output
+// and merges the results into a single UpdateManifest.
+func convertJSONLinesToUnversionedAPI(lines [][]byte) (*unversioned.UpdateManifest, error) {
+	manifests := make([]*unversioned.UpdateManifest, 0, len(lines))
+	for i, line := range lines {
+		var m map[string]interface{}
+		if err := json.Unmarshal(line, &m); err != nil {
+			return nil, fmt.Errorf("error parsing scanner output line %d: %w", i+1, err)
+		}
+		um, err := convertToUnversionedAPI(line, m)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, um)
+	}
+	return mergeUpdateManifests(manifests)
+}
+
+func defaultParseScanReport(file, pkgTypes, libraryPatchLevel string, opts *ParseOptions) (*unversioned.UpdateManifest, error) {
 	allParsers := []ScanReportParser{
-		&TrivyParser{},
+		&TrivyParser{SeveritySource: opts.SeveritySource, IncludeUnfixed: opts.IncludeUnfixed},
 	}
 	for _, parser := range allParsers {
 		manifest, err := parser.ParseWithLibraryPatchLevel(file, libraryPatchLevel)
@@ -95,6 +215,12 @@ func defaultParseScanReport(file, pkgTypes, libraryPatchLevel string) (*unversio
 				if !strings.Contains(pkgTypes, utils.PkgTypeOS) {
 					manifest.OSUpdates = []unversioned.UpdatePackage{}
 				}
+				manifest.Unfixed = slices.DeleteFunc(manifest.Unfixed, func(u unversioned.UpdatePackage) bool {
+					if u.Class == utils.LangPackages {
+						return !strings.Contains(pkgTypes, utils.PkgTypeLibrary)
+					}
+					return !strings.Contains(pkgTypes, utils.PkgTypeOS)
+				})
 			}
 			return manifest, nil
 		} else if _, ok := err.(*ErrorUnsupported); ok {
@@ -45,9 +45,9 @@ func TestTryParseScanReport(t *testing.T) {
 			err: nil,
 		},
 		{
-			file:     "testdata/invalid.json",
+			file:     "testdata/grype.json",
 			manifest: nil,
-			err:      fmt.Errorf("testdata/invalid.json is not a supported scan report format"),
+			err:      fmt.Errorf("testdata/grype.json is not a supported scan report format"),
 		},
 	}
 
@@ -63,6 +63,18 @@ func TestTryParseScanReport(t *testing.T) {
 	}
 }
 
+func TestTryParseScanReportMalformed(t *testing.T) {
+	for _, file := range []string{"testdata/invalid.json", "testdata/trivy_truncated.json"} {
+		t.Run(file, func(t *testing.T) {
+			manifest, err := TryParseScanReport(file, "trivy", utils.PkgTypeOS, utils.PatchTypePatch)
+			assert.Nil(t, manifest)
+			var malformed *ErrorMalformed
+			assert.ErrorAs(t, err, &malformed)
+			assert.ErrorContains(t, err, file+" is not a valid scan report")
+		})
+	}
+}
+
 // TestErrorUnsupported tests the ErrorUnsupported error type.
 func TestErrorUnsupported(t *testing.T) {
 	originalErr := fmt.Errorf("original error message")
@@ -257,3 +269,26 @@ func TestValidScannerNamePattern(t *testing.T) {
 		})
 	}
 }
+
+func TestSupportedScanners(t *testing.T) {
+	assert.Equal(t, []string{"native", "trivy"}, SupportedScanners())
+}
+
+func TestValidateScanner(t *testing.T) {
+	origLookPath := lookPath
+	defer func() { lookPath = origLookPath }()
+	lookPath = func(file string) (string, error) {
+		if file == "copa-grype" {
+			return "/usr/local/bin/copa-grype", nil
+		}
+		return "", fmt.Errorf("%s: executable file not found in $PATH", file)
+	}
+
+	assert.NoError(t, ValidateScanner("trivy"))
+	assert.NoError(t, ValidateScanner("native"))
+	assert.NoError(t, ValidateScanner("grype"))
+
+	err := ValidateScanner("snyk")
+	assert.ErrorContains(t, err, `unsupported scanner "snyk", supported: native, trivy`)
+	assert.Error(t, ValidateScanner("../bin/sh"))
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ScanOptions configures an in-process scan of an image.
+type ScanOptions struct {
+	// Scanner is the scanner to run. Only "trivy" is supported.
+	Scanner string
+	// Image is the image reference to scan.
+	Image string
+	// Platform optionally restricts the scan to a single platform (e.g. linux/arm64).
+	Platform string
+	// Output is the path the JSON report is written to.
+	Output string
+	// ExtraArgs are passed through to the scanner binary before the image argument.
+	ExtraArgs []string
+	// ListAllPkgs records every installed package in the report, not only vulnerable ones.
+	ListAllPkgs bool
+}
+
+// ScanImage runs the configured scanner against an image and writes a JSON report
+// to opts.Output. Scanner stderr is included in the returned error on failure.
+func ScanImage(ctx context.Context, opts *ScanOptions) error {
+	if opts.Scanner != "trivy" {
+		return fmt.Errorf("scanning with %q is not supported, only trivy can be run by copa", opts.Scanner)
+	}
+
+	args := []string{"image", "--format", "json", "--output", opts.Output}
+	if opts.Platform != "" {
+		args = append(args, "--platform", opts.Platform)
+	}
+	if opts.ListAllPkgs {
+		args = append(args, "--list-all-pkgs")
+	}
+	args = append(args, opts.ExtraArgs...)
+	args = append(args, opts.Image)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, opts.Scanner, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return fmt.Errorf("error running %s: %w", opts.Scanner, err)
+		}
+		return fmt.Errorf("error running %s: %w: %s", opts.Scanner, err, msg)
+	}
+	return nil
+}
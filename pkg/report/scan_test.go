@@ -0,0 +1,90 @@
+package report
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeTrivy puts a fake trivy script on PATH that records its arguments.
+func writeFakeTrivy(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake scanner script requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args.txt")
+	content := "#!/bin/sh\nfor a in \"$@\"; do echo \"$a\" >> " + argsFile + "; done\n" + script
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "trivy"), []byte(content), 0o755))
+	t.Setenv("PATH", dir)
+	return argsFile
+}
+
+func TestScanImage(t *testing.T) {
+	t.Run("passes scanner args through", func(t *testing.T) {
+		argsFile := writeFakeTrivy(t, "exit 0\n")
+		output := filepath.Join(t.TempDir(), "report.json")
+
+		err := ScanImage(context.Background(), &ScanOptions{
+			Scanner:   "trivy",
+			Image:     "docker.io/library/alpine:3.19",
+			Platform:  "linux/arm64",
+			Output:    output,
+			ExtraArgs: []string{"--ignore-unfixed", "--severity", "HIGH,CRITICAL"},
+		})
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"image", "--format", "json", "--output", output,
+			"--platform", "linux/arm64",
+			"--ignore-unfixed", "--severity", "HIGH,CRITICAL",
+			"docker.io/library/alpine:3.19",
+		}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+	})
+
+	t.Run("lists all packages", func(t *testing.T) {
+		argsFile := writeFakeTrivy(t, "exit 0\n")
+		output := filepath.Join(t.TempDir(), "report.json")
+
+		err := ScanImage(context.Background(), &ScanOptions{
+			Scanner:     "trivy",
+			Image:       "docker.io/library/alpine:3.19",
+			Output:      output,
+			ListAllPkgs: true,
+		})
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(argsFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"image", "--format", "json", "--output", output,
+			"--list-all-pkgs",
+			"docker.io/library/alpine:3.19",
+		}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+	})
+
+	t.Run("surfaces scanner stderr on failure", func(t *testing.T) {
+		writeFakeTrivy(t, "echo 'FATAL unable to find image' >&2\nexit 1\n")
+
+		err := ScanImage(context.Background(), &ScanOptions{
+			Scanner: "trivy",
+			Image:   "example.com/missing:latest",
+			Output:  filepath.Join(t.TempDir(), "report.json"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "FATAL unable to find image")
+	})
+
+	t.Run("only trivy is supported", func(t *testing.T) {
+		err := ScanImage(context.Background(), &ScanOptions{Scanner: "grype", Image: "alpine"})
+		assert.ErrorContains(t, err, "only trivy")
+	})
+}
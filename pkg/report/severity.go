@@ -0,0 +1,105 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+)
+
+// Severity sources accepted by --report-severity-source. Trivy reports carry a
+// top-level severity plus the severity each vendor assigned; these pick which one
+// is recorded on an update and used for --min-severity filtering.
+const (
+	SeveritySourceNVD     = "nvd"
+	SeveritySourceVendor  = "vendor"
+	SeveritySourceHighest = "highest"
+)
+
+// severityRanks orders the severity names Trivy reports, from least to most severe.
+var severityRanks = map[string]int{
+	"UNKNOWN":  0,
+	"LOW":      1,
+	"MEDIUM":   2,
+	"HIGH":     3,
+	"CRITICAL": 4,
+}
+
+// ValidateSeverityOptions checks the values of --report-severity-source and --min-severity.
+// Either may be empty to keep the default.
+func ValidateSeverityOptions(source, minimum string) error {
+	switch source {
+	case "", SeveritySourceNVD, SeveritySourceVendor, SeveritySourceHighest:
+	default:
+		return fmt.Errorf("unsupported severity source %q, supported: %s, %s, %s",
+			source, SeveritySourceHighest, SeveritySourceNVD, SeveritySourceVendor)
+	}
+	if minimum != "" {
+		if _, ok := severityRanks[strings.ToUpper(minimum)]; !ok {
+			return fmt.Errorf("unsupported minimum severity %q, supported: low, medium, high, critical", minimum)
+		}
+	}
+	return nil
+}
+
+// NewSeverityOptions returns the ParseOptions for the severity source and minimum
+// severity given to --report-severity-source and --min-severity.
+func NewSeverityOptions(source, minimum string) (*ParseOptions, error) {
+	if err := ValidateSeverityOptions(source, minimum); err != nil {
+		return nil, err
+	}
+	if source == "" {
+		source = SeveritySourceHighest
+	}
+	return &ParseOptions{SeveritySource: source, MinSeverity: strings.ToUpper(minimum)}, nil
+}
+
+// selectSeverity returns the severity of vuln according to source. It falls back to
+// the top-level severity Trivy reports when the chosen vendor did not rate it.
+func selectSeverity(vuln *trivyTypes.DetectedVulnerability, source string) string {
+	switch source {
+	case SeveritySourceNVD:
+		if s, ok := vuln.VendorSeverity["nvd"]; ok {
+			return s.String()
+		}
+	case SeveritySourceVendor:
+		if vuln.DataSource != nil {
+			if s, ok := vuln.VendorSeverity[vuln.DataSource.ID]; ok {
+				return s.String()
+			}
+		}
+	default:
+		highest := vuln.Severity
+		for _, s := range vuln.VendorSeverity {
+			if severityRanks[s.String()] > severityRanks[highest] {
+				highest = s.String()
+			}
+		}
+		return highest
+	}
+	return vuln.Severity
+}
+
+// filterBySeverity drops updates rated below minimum. Updates without a severity,
+// e.g. from scanners that do not report one or rated UNKNOWN by Trivy, are kept
+// rather than silently skipped.
+func filterBySeverity(manifest *unversioned.UpdateManifest, minimum string) {
+	if manifest == nil || minimum == "" {
+		return
+	}
+	minRank := severityRanks[strings.ToUpper(minimum)]
+	keep := func(updates []unversioned.UpdatePackage) []unversioned.UpdatePackage {
+		filtered := updates[:0]
+		for _, u := range updates {
+			rank, known := severityRanks[strings.ToUpper(u.Severity)]
+			if !known || rank == severityRanks["UNKNOWN"] || rank >= minRank {
+				filtered = append(filtered, u)
+			}
+		}
+		return filtered
+	}
+	manifest.OSUpdates = keep(manifest.OSUpdates)
+	manifest.LangUpdates = keep(manifest.LangUpdates)
+	manifest.Unfixed = keep(manifest.Unfixed)
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSeverityOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		minimum string
+		wantErr bool
+	}{
+		{name: "defaults", source: "", minimum: ""},
+		{name: "nvd with minimum", source: SeveritySourceNVD, minimum: "high"},
+		{name: "vendor", source: SeveritySourceVendor, minimum: "CRITICAL"},
+		{name: "unknown source", source: "redhat", wantErr: true},
+		{name: "unknown minimum", source: SeveritySourceHighest, minimum: "severe", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSeverityOptions(tc.source, tc.minimum)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewSeverityOptions(t *testing.T) {
+	opts, err := NewSeverityOptions("", "high")
+	assert.NoError(t, err)
+	assert.Equal(t, &ParseOptions{SeveritySource: SeveritySourceHighest, MinSeverity: "HIGH"}, opts)
+
+	_, err = NewSeverityOptions("redhat", "")
+	assert.Error(t, err)
+}
+
+func TestFilterBySeverity(t *testing.T) {
+	manifest := &unversioned.UpdateManifest{
+		OSUpdates: unversioned.UpdatePackages{
+			{Name: "libssl3", VulnerabilityID: "CVE-1", Severity: "CRITICAL"},
+			{Name: "zlib1g", VulnerabilityID: "CVE-2", Severity: "MEDIUM"},
+			{Name: "tar", VulnerabilityID: "CVE-3", Severity: "UNKNOWN"},
+			{Name: "bash", VulnerabilityID: "CVE-4"},
+		},
+		LangUpdates: unversioned.LangUpdatePackages{
+			{Name: "minimist", VulnerabilityID: "CVE-5", Severity: "HIGH"},
+			{Name: "lodash", VulnerabilityID: "CVE-6", Severity: "LOW"},
+		},
+	}
+
+	// library callers may pass the minimum in any case
+	filterBySeverity(manifest, "high")
+
+	var osIDs, langIDs []string
+	for _, u := range manifest.OSUpdates {
+		osIDs = append(osIDs, u.VulnerabilityID)
+	}
+	for _, u := range manifest.LangUpdates {
+		langIDs = append(langIDs, u.VulnerabilityID)
+	}
+	// updates without a severity or rated UNKNOWN are kept
+	assert.Equal(t, []string{"CVE-1", "CVE-3", "CVE-4"}, osIDs)
+	assert.Equal(t, []string{"CVE-5"}, langIDs)
+}
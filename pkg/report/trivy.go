@@ -4,8 +4,10 @@ package report
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,9 +16,17 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 	"github.com/project-copacetic/copacetic/pkg/utils"
+	log "github.com/sirupsen/logrus"
 )
 
-type TrivyParser struct{}
+type TrivyParser struct {
+	// SeveritySource selects which severity is recorded on each update; see
+	// SeveritySourceHighest, SeveritySourceNVD and SeveritySourceVendor. Empty means highest.
+	SeveritySource string
+	// IncludeUnfixed keeps vulnerabilities without a fixed version in the manifest's
+	// Unfixed list instead of dropping them.
+	IncludeUnfixed bool
+}
 
 // isUnpatchableDotnetRuntimePackage returns true for .NET runtime/platform packages
 // that have the DotnetPlatform NuGet package type and cannot be installed via
@@ -272,6 +282,46 @@ func getHighestVersion(versions []string) string {
 	return highest
 }
 
+// lowestVersionAbove returns the lowest of versions that is newer than installed.
+func lowestVersionAbove(installed string, versions []string) string {
+	var lowest string
+	for _, v := range versions {
+		if compareVersions(v, installed) > 0 && (lowest == "" || compareVersions(v, lowest) < 0) {
+			lowest = v
+		}
+	}
+	return lowest
+}
+
+// osFixedVersion returns the version to update an OS package of pkgType to. Trivy
+// lists the fixes of several releases as one comma-separated FixedVersion, e.g.
+// "1:2.3.4-1+deb11u1, 1:2.4.0-1"; the lowest of them newer than installed is the fix
+// on the installed release. Versions are compared with the ordering of the package's
+// ecosystem, so Debian epochs and "~" pre-releases sort as dpkg does.
+func osFixedVersion(pkgType, installed, fixedVersion string) string {
+	if !strings.Contains(fixedVersion, ",") {
+		return fixedVersion
+	}
+	var lowest, highest string
+	for _, v := range strings.Split(fixedVersion, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if highest == "" || compareEcosystemVersions(pkgType, v, highest) > 0 {
+			highest = v
+		}
+		if compareEcosystemVersions(pkgType, v, installed) > 0 &&
+			(lowest == "" || compareEcosystemVersions(pkgType, v, lowest) < 0) {
+			lowest = v
+		}
+	}
+	if lowest == "" {
+		return highest
+	}
+	return lowest
+}
+
 // parseVersionParts parses a version string into integer parts.
 func parseVersionParts(version string) []int {
 	// Remove common prefixes like 'v'
@@ -307,18 +357,86 @@ func parseVersionParts(version string) []int {
 	return intParts
 }
 
-func parseTrivyReport(file string) (*trivyTypes.Report, error) {
-	data, err := os.ReadFile(file)
+// decodeLeadingTrivyReport decodes the Trivy report at the start of data, ignoring
+// anything written after it, such as a log line appended by the tool that saved the
+// report. It reports false if data doesn't start with a Trivy report.
+func decodeLeadingTrivyReport(file string, data []byte) (*trivyTypes.Report, bool) {
+	first, trailing, err := firstJSONValue(data)
 	if err != nil {
-		return nil, err
+		return nil, false
+	}
+	report, err := decodeTrivyReport(first)
+	if err != nil {
+		return nil, false
+	}
+	if len(trailing) > 0 {
+		log.Warnf("Ignoring %d bytes after the Trivy report in %s", len(trailing), file)
 	}
+	return report, true
+}
+
+// trivyReportKeys are top-level fields of a Trivy JSON report, one of which must be
+// present for a JSON document to be read as one.
+var trivyReportKeys = []string{"SchemaVersion", "ArtifactName", "ArtifactType", "Metadata", "Results"}
+
+// decodeTrivyReport decodes a Trivy JSON report. It returns an ErrorMalformed if data
+// is not valid JSON and an ErrorUnsupported if it is JSON but not a Trivy report.
+func decodeTrivyReport(data []byte) (*trivyTypes.Report, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, &ErrorMalformed{fmt.Errorf("malformed JSON: %w", err)}
+		}
+		return nil, &ErrorUnsupported{fmt.Errorf("not a Trivy report: %w", err)}
+	}
+	if !slices.ContainsFunc(trivyReportKeys, func(k string) bool { _, ok := fields[k]; return ok }) {
+		return nil, &ErrorUnsupported{errors.New("not a Trivy report: none of the SchemaVersion, Results or Metadata fields found")}
+	}
+
 	var msr trivyTypes.Report
-	if err = json.Unmarshal(data, &msr); err != nil {
-		return nil, &ErrorUnsupported{err}
+	if err := json.Unmarshal(data, &msr); err != nil {
+		return nil, &ErrorUnsupported{fmt.Errorf("not a Trivy report: %w", err)}
 	}
 	return &msr, nil
 }
 
+// parseTrivyReports parses a Trivy report file, falling back to JSON Lines with
+// one report per line when the file is not a single JSON document, and then to the
+// report at the start of the file.
+func parseTrivyReports(file string) ([]*trivyTypes.Report, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	data = trimBOM(data)
+	report, err := decodeTrivyReport(data)
+	if err == nil {
+		return []*trivyTypes.Report{report}, nil
+	}
+	var malformed *ErrorMalformed
+	if !errors.As(err, &malformed) {
+		return nil, err
+	}
+	lines, ok := splitJSONLines(data)
+	if !ok {
+		if r, ok := decodeLeadingTrivyReport(file, data); ok {
+			return []*trivyTypes.Report{r}, nil
+		}
+		return nil, &ErrorMalformed{fmt.Errorf("%s is not a valid scan report: %w", file, malformed.err)}
+	}
+
+	reports := make([]*trivyTypes.Report, 0, len(lines))
+	for _, line := range lines {
+		r, err := decodeTrivyReport(line)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
 // extractVersionsFromImageHistory extracts Node.js and Yarn versions from Docker image history.
 // It looks for ENV commands like "ENV NODE_VERSION=18.20.3" and "ENV YARN_VERSION=1.22.19".
 func extractVersionsFromImageHistory(history []v1.History) (nodeVersion, yarnVersion string) {
@@ -364,11 +482,37 @@ func (t *TrivyParser) Parse(file string) (*unversioned.UpdateManifest, error) {
 }
 
 func (t *TrivyParser) ParseWithLibraryPatchLevel(file, libraryPatchLevel string) (*unversioned.UpdateManifest, error) {
-	report, err := parseTrivyReport(file)
+	reports, err := parseTrivyReports(file)
 	if err != nil {
 		return nil, err
 	}
 
+	manifests := make([]*unversioned.UpdateManifest, 0, len(reports))
+	for _, report := range reports {
+		manifest, err := t.parseReport(report, libraryPatchLevel)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	return mergeUpdateManifests(manifests)
+}
+
+// unfixedUpdate records a vulnerability of result r that has no fixed version.
+func (t *TrivyParser) unfixedUpdate(r *trivyTypes.Result, vuln *trivyTypes.DetectedVulnerability) unversioned.UpdatePackage {
+	return unversioned.UpdatePackage{
+		Name:             vuln.PkgName,
+		Type:             string(r.Type),
+		Class:            string(r.Class),
+		InstalledVersion: vuln.InstalledVersion,
+		PkgPath:          vuln.PkgPath,
+		VulnerabilityID:  vuln.VulnerabilityID,
+		Severity:         selectSeverity(vuln, t.SeveritySource),
+	}
+}
+
+// parseReport converts a single Trivy report into an UpdateManifest.
+func (t *TrivyParser) parseReport(report *trivyTypes.Report, libraryPatchLevel string) (*unversioned.UpdateManifest, error) {
 	// Extract Node.js and Yarn versions from image history
 	var nodeVersion, yarnVersion string
 	if report.Metadata.ImageConfig.History != nil {
@@ -403,9 +547,11 @@ func (t *TrivyParser) ParseWithLibraryPatchLevel(file, libraryPatchLevel string)
 	// Python vs a venv) is treated as a separate upgrade target.
 	langPackageVulns := make(map[string][]trivyTypes.DetectedVulnerability)
 	langPackageInfo := make(map[string]unversioned.UpdatePackage)
-	// track all vulnerability IDs per lang package for VEX emission
-	langPackageVulnIDs := make(map[string]map[string]struct{})
+	// track all vulnerability IDs per lang package for VEX emission, with their severity
+	langPackageVulnIDs := make(map[string]map[string]string)
 
+	// Results of other classes, such as secret, config and license findings from
+	// trivy's other scanners, have no vulnerabilities to patch and are skipped.
 	for i := range report.Results {
 		r := &report.Results[i]
 
@@ -418,10 +564,13 @@ func (t *TrivyParser) ParseWithLibraryPatchLevel(file, libraryPatchLevel string)
 						Name:             vuln.PkgName,
 						Type:             string(r.Type),
 						Class:            string(r.Class),
-						FixedVersion:     vuln.FixedVersion,
+						FixedVersion:     osFixedVersion(string(r.Type), vuln.InstalledVersion, vuln.FixedVersion),
 						InstalledVersion: vuln.InstalledVersion,
 						VulnerabilityID:  vuln.VulnerabilityID,
+						Severity:         selectSeverity(vuln, t.SeveritySource),
 					})
+				} else if t.IncludeUnfixed {
+					updates.Unfixed = append(updates.Unfixed, t.unfixedUpdate(r, vuln))
 				}
 			}
 		}
@@ -444,12 +593,14 @@ func (t *TrivyParser) ParseWithLibraryPatchLevel(file, libraryPatchLevel string)
 								InstalledVersion: vuln.InstalledVersion,
 								PkgPath:          vuln.PkgPath,
 							}
-							langPackageVulnIDs[key] = make(map[string]struct{})
+							langPackageVulnIDs[key] = make(map[string]string)
 						}
 						langPackageVulns[key] = append(langPackageVulns[key], *vuln)
 						if vuln.VulnerabilityID != "" {
-							langPackageVulnIDs[key][vuln.VulnerabilityID] = struct{}{}
+							langPackageVulnIDs[key][vuln.VulnerabilityID] = selectSeverity(vuln, t.SeveritySource)
 						}
+					} else if t.IncludeUnfixed {
+						updates.Unfixed = append(updates.Unfixed, t.unfixedUpdate(r, vuln))
 					}
 				}
 			}
@@ -476,12 +627,14 @@ func (t *TrivyParser) ParseWithLibraryPatchLevel(file, libraryPatchLevel string)
 								InstalledVersion: vuln.InstalledVersion,
 								PkgPath:          vuln.PkgPath,
 							}
-							langPackageVulnIDs[key] = make(map[string]struct{})
+							langPackageVulnIDs[key] = make(map[string]string)
 						}
 						langPackageVulns[key] = append(langPackageVulns[key], *vuln)
 						if vuln.VulnerabilityID != "" {
-							langPackageVulnIDs[key][vuln.VulnerabilityID] = struct{}{}
+							langPackageVulnIDs[key][vuln.VulnerabilityID] = selectSeverity(vuln, t.SeveritySource)
 						}
+					} else if t.IncludeUnfixed {
+						updates.Unfixed = append(updates.Unfixed, t.unfixedUpdate(r, vuln))
 					}
 				}
 			}
@@ -525,6 +678,12 @@ func (t *TrivyParser) ParseWithLibraryPatchLevel(file, libraryPatchLevel string)
 			}
 
 			optimalVersion := FindOptimalFixedVersionWithPatchLevel(info.InstalledVersion, fixedVersions, patchLevelToUse)
+			if optimalVersion == "" {
+				// The patch level ruled out every fix; flag it when even the nearest one is a major upgrade
+				if requiresMajorUpgrade(info.Type, info.InstalledVersion, lowestVersionAbove(info.InstalledVersion, fixedVersions)) {
+					info.Status = unversioned.FixRequiresMajorUpgrade
+				}
+			}
 			if idsMap, ok2 := langPackageVulnIDs[key]; ok2 {
 				var ids []string
 				for id := range idsMap {
@@ -535,6 +694,7 @@ func (t *TrivyParser) ParseWithLibraryPatchLevel(file, libraryPatchLevel string)
 					clone := info
 					clone.FixedVersion = optimalVersion
 					clone.VulnerabilityID = vid
+					clone.Severity = idsMap[vid]
 					updates.LangUpdates = append(updates.LangUpdates, clone)
 				}
 			} else {
@@ -8,8 +8,10 @@ import (
 	ftypes "github.com/aquasecurity/trivy/pkg/fanal/types"
 	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 	"github.com/project-copacetic/copacetic/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -18,7 +20,7 @@ const (
 	patchPatchLevel = "patch"
 )
 
-// TestParseTrivyReport tests the parseTrivyReport function.
+// TestParseTrivyReport tests the parseTrivyReports function on single reports.
 func TestParseTrivyReport(t *testing.T) {
 	// Define a table of test cases with inputs and expected outputs
 	tests := []struct {
@@ -74,9 +76,13 @@ func TestParseTrivyReport(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Call the function under test with the input from the test case
-			msr, err := parseTrivyReport(tc.file)
+			reports, err := parseTrivyReports(tc.file)
 
 			// Check if the output matches the expected output from the test case
+			var msr *trivyTypes.Report
+			if len(reports) == 1 {
+				msr = reports[0]
+			}
 			if !reflect.DeepEqual(msr, tc.msr) {
 				t.Errorf("got %v, want %v", msr, tc.msr)
 			}
@@ -958,3 +964,183 @@ func TestIsUnpatchableDotnetRuntimePackage(t *testing.T) {
 		})
 	}
 }
+
+// TestTrivyParserSeveritySource tests that the severity recorded on each update follows
+// the selected source when NVD and the vendor rate a vulnerability differently.
+func TestTrivyParserSeveritySource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   map[string]string
+	}{
+		{
+			name:   "highest by default",
+			source: "",
+			want: map[string]string{
+				"CVE-2024-0727":  "CRITICAL",
+				"CVE-2023-45853": "MEDIUM",
+				"CVE-2021-44906": "HIGH",
+			},
+		},
+		{
+			name:   "nvd",
+			source: SeveritySourceNVD,
+			want: map[string]string{
+				"CVE-2024-0727":  "CRITICAL",
+				"CVE-2023-45853": "MEDIUM",
+				"CVE-2021-44906": "MEDIUM",
+			},
+		},
+		{
+			name:   "vendor falls back to the reported severity",
+			source: SeveritySourceVendor,
+			want: map[string]string{
+				"CVE-2024-0727":  "LOW",
+				"CVE-2023-45853": "MEDIUM",
+				"CVE-2021-44906": "HIGH",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := &TrivyParser{SeveritySource: tc.source}
+			manifest, err := parser.Parse("testdata/trivy_severity.json")
+			require.NoError(t, err)
+			require.Len(t, manifest.OSUpdates, 2)
+			require.Len(t, manifest.LangUpdates, 1)
+
+			got := make(map[string]string)
+			for _, u := range append(manifest.OSUpdates, manifest.LangUpdates...) {
+				got[u.VulnerabilityID] = u.Severity
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTrivyParserIncludeUnfixed(t *testing.T) {
+	t.Run("dropped by default", func(t *testing.T) {
+		manifest, err := (&TrivyParser{}).Parse("testdata/trivy_unfixed.json")
+		require.NoError(t, err)
+		require.Len(t, manifest.OSUpdates, 1)
+		assert.Empty(t, manifest.LangUpdates)
+		assert.Empty(t, manifest.Unfixed)
+	})
+
+	t.Run("kept apart from installable updates", func(t *testing.T) {
+		manifest, err := (&TrivyParser{IncludeUnfixed: true}).Parse("testdata/trivy_unfixed.json")
+		require.NoError(t, err)
+		require.Len(t, manifest.OSUpdates, 1)
+		assert.Equal(t, "libssl3", manifest.OSUpdates[0].Name)
+		assert.Empty(t, manifest.LangUpdates)
+
+		assert.Equal(t, unversioned.UpdatePackages{
+			{Name: "libc6", Type: "debian", Class: "os-pkgs", InstalledVersion: "2.36-9+deb12u4", VulnerabilityID: "CVE-2010-4756", Severity: "LOW"},
+			{
+				Name: "left-pad", Type: "node-pkg", Class: "lang-pkgs", InstalledVersion: "1.3.0",
+				PkgPath: "app/node_modules/left-pad/package.json", VulnerabilityID: "CVE-2024-0001", Severity: "HIGH",
+			},
+		}, manifest.Unfixed)
+	})
+}
+
+func TestOSFixedVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		pkgType   string
+		installed string
+		fixed     string
+		want      string
+	}{
+		{name: "single version", pkgType: "debian", installed: "1:2.3.0-1", fixed: "1:2.3.4-1", want: "1:2.3.4-1"},
+		{
+			name:      "epoch outranks a higher upstream version",
+			pkgType:   "debian",
+			installed: "1:2.3.0-1",
+			fixed:     "2.4.0-1, 1:2.3.4-1+deb11u1",
+			want:      "1:2.3.4-1+deb11u1",
+		},
+		{
+			name:      "fix on the installed release",
+			pkgType:   "debian",
+			installed: "1:2.3.0-1",
+			fixed:     "1:2.4.0-1, 1:2.3.4-1+deb11u1",
+			want:      "1:2.3.4-1+deb11u1",
+		},
+		{
+			name:      "tilde sorts before the release",
+			pkgType:   "ubuntu",
+			installed: "2.3.4~ubuntu1",
+			fixed:     "2.3.4, 2.3.4~ubuntu2",
+			want:      "2.3.4~ubuntu2",
+		},
+		{
+			name:      "none newer than installed",
+			pkgType:   "debian",
+			installed: "2:1.0-1",
+			fixed:     "1:3.0-1, 1:2.0-1",
+			want:      "1:3.0-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, osFixedVersion(tt.pkgType, tt.installed, tt.fixed))
+		})
+	}
+}
+
+func TestParseTrivyReportBOMAndTrailingContent(t *testing.T) {
+	want, err := parseTrivyReports("testdata/trivy_valid.json")
+	require.NoError(t, err)
+	require.Len(t, want, 1)
+
+	for _, file := range []string{"testdata/trivy_bom.json", "testdata/trivy_trailing.json"} {
+		t.Run(file, func(t *testing.T) {
+			reports, err := parseTrivyReports(file)
+			require.NoError(t, err)
+			assert.Equal(t, want, reports)
+		})
+	}
+}
+
+func TestTrivyParserReportFormatErrors(t *testing.T) {
+	t.Run("other scanner", func(t *testing.T) {
+		_, err := NewTrivyParser().Parse("testdata/grype.json")
+		var unsupported *ErrorUnsupported
+		require.ErrorAs(t, err, &unsupported)
+		var malformed *ErrorMalformed
+		assert.NotErrorAs(t, err, &malformed)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := NewTrivyParser().Parse("testdata/trivy_truncated.json")
+		var malformed *ErrorMalformed
+		require.ErrorAs(t, err, &malformed)
+		var unsupported *ErrorUnsupported
+		assert.NotErrorAs(t, err, &unsupported)
+		assert.ErrorContains(t, err, "unexpected end of JSON input")
+	})
+
+	t.Run("JSON that isn't a report", func(t *testing.T) {
+		_, err := decodeTrivyReport([]byte(`["not", "a", "report"]`))
+		var unsupported *ErrorUnsupported
+		assert.ErrorAs(t, err, &unsupported)
+	})
+}
+
+// TestTrivyParserParseNonVulnerabilityClasses tests that results from trivy's secret,
+// misconfiguration and license scanners are skipped rather than rejected.
+func TestTrivyParserParseNonVulnerabilityClasses(t *testing.T) {
+	manifest, err := NewTrivyParser().Parse("testdata/trivy_secret_config.json")
+	require.NoError(t, err)
+	require.NotNil(t, manifest)
+
+	assert.Equal(t, "alpine", manifest.Metadata.OS.Type)
+	assert.Equal(t, "3.20.3", manifest.Metadata.OS.Version)
+	assert.Equal(t, "amd64", manifest.Metadata.Config.Arch)
+	assert.Empty(t, manifest.OSUpdates)
+	assert.Empty(t, manifest.LangUpdates)
+	assert.Empty(t, manifest.Unfixed)
+}
@@ -164,6 +164,8 @@ func formatStatusStyled(status string) string {
 		return errorStyle.Render("✗ Error      ")
 	case "Ignored":
 		return warningStyle.Render("⊘ Ignored    ")
+	case "Rebuild":
+		return errorStyle.Render("↻ Rebuild    ")
 	default:
 		return fmt.Sprintf("  %-12s", status)
 	}
@@ -190,6 +192,8 @@ func getStatusIcon(status string) string {
 		return "✗"
 	case "Ignored":
 		return "⊘"
+	case "Rebuild":
+		return "↻"
 	default:
 		return " "
 	}
@@ -308,6 +308,7 @@ func TestGetStatusIcon(t *testing.T) {
 		{"Up-to-date", "✓"},
 		{"Error", "✗"},
 		{"Ignored", "⊘"},
+		{"Rebuild", "↻"},
 		{"Unknown", " "},
 		{"", " "},
 	}
@@ -1,6 +1,102 @@
 package types
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // ErrNoUpdatesFound indicates that no package updates are available for the image.
 var ErrNoUpdatesFound = errors.New("no package updates found for image")
+
+// ErrNoPackagesPatched indicates that a patch run with --fail-on-no-patch applied no
+// package updates. Unlike ErrNoUpdatesFound it makes copa exit non-zero.
+var ErrNoPackagesPatched = errors.New("no packages were patched")
+
+// ErrReportPlatformMismatch indicates that a per-platform scan report was generated for
+// a different architecture than the platform it was applied to.
+var ErrReportPlatformMismatch = errors.New("scan report was generated for a different platform")
+
+// ErrReportParse indicates that the scan report of one platform of a multi-platform
+// image could not be parsed.
+var ErrReportParse = errors.New("scan report could not be parsed")
+
+// ErrPackageNotFound indicates that the package manager could not find a requested
+// package in the image's configured repositories.
+var ErrPackageNotFound = errors.New("requested package not found in the configured repositories")
+
+// ErrFilesystemNotWritable indicates that the package manager failed because a
+// directory it installs into is not writable in the image.
+var ErrFilesystemNotWritable = errors.New("image filesystem is not writable")
+
+// ErrNoPackageDatabase indicates that the image has no database of installed OS
+// packages, as with images that Bazel, ko or jib build without a package manager,
+// so an OS package manager has nothing to update.
+var ErrNoPackageDatabase = errors.New("no package database found; image may be built without a package manager")
+
+// ErrPackageManagerNotFound indicates that the image lacks the package manager tools
+// Copa runs to patch it, and that no tooling image can stand in for them.
+var ErrPackageManagerNotFound = errors.New("package manager not found in the image")
+
+// ErrRebuildRequired indicates that the image cannot be remediated in place
+// (e.g. a FROM scratch image with no shell or package manager) and has to be
+// rebuilt from source. Use errors.As with *RebuildRequiredError to get the
+// list of affected components.
+var ErrRebuildRequired = errors.New("no in-place remediation possible; rebuild required")
+
+// RebuildComponent is a vulnerable component that could not be patched in place.
+type RebuildComponent struct {
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	InstalledVersion string `json:"installedVersion"`
+	FixedVersion     string `json:"fixedVersion"`
+	PkgPath          string `json:"pkgPath,omitempty"`
+	VulnerabilityID  string `json:"vulnerabilityID,omitempty"`
+}
+
+// RebuildRequiredError is returned when an image has no usable root filesystem
+// to patch in place. It lists the components that need a rebuild to be fixed.
+type RebuildRequiredError struct {
+	Image      string
+	Reason     string
+	Components []RebuildComponent
+	// Err is the underlying patching error, if any.
+	Err error
+}
+
+func (e *RebuildRequiredError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(ErrRebuildRequired.Error())
+	if e.Image != "" {
+		fmt.Fprintf(&sb, " for %s", e.Image)
+	}
+	if e.Reason != "" {
+		fmt.Fprintf(&sb, ": %s", e.Reason)
+	}
+	if len(e.Components) > 0 {
+		fmt.Fprintf(&sb, "; affected components (%d):", len(e.Components))
+		for _, c := range e.Components {
+			fmt.Fprintf(&sb, " %s", c.Name)
+			if c.PkgPath != "" {
+				fmt.Fprintf(&sb, " [%s]", c.PkgPath)
+			}
+			if c.InstalledVersion != "" || c.FixedVersion != "" {
+				fmt.Fprintf(&sb, " (%s -> %s)", c.InstalledVersion, c.FixedVersion)
+			}
+			sb.WriteString(";")
+		}
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&sb, " underlying error: %v", e.Err)
+	}
+	return sb.String()
+}
+
+// Is reports whether target is ErrRebuildRequired.
+func (e *RebuildRequiredError) Is(target error) bool {
+	return target == ErrRebuildRequired
+}
+
+func (e *RebuildRequiredError) Unwrap() error {
+	return e.Err
+}
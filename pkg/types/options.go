@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/moby/buildkit/util/progress/progressui"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
 )
 
 // Options contains common copacetic options.
@@ -17,6 +19,17 @@ type Options struct {
 	// Bulk image patch configuration
 	ConfigFile string
 
+	// Upgrade every OS package listed in the report (trivy --list-all-pkgs) to its
+	// latest version, not only the vulnerable ones
+	UpdateAll bool
+
+	// With an image list, stop patching after the first image that fails
+	FailFast bool
+
+	// Fail when the run applied no package updates, e.g. because every vulnerability
+	// in the report is unfixed or names a package missing from the image
+	FailOnNoPatch bool
+
 	// Working environment
 	WorkingFolder string
 	Timeout       time.Duration
@@ -25,6 +38,88 @@ type Options struct {
 	Scanner     string
 	IgnoreError bool
 
+	// Fail instead of skipping a platform whose per-platform report records a
+	// different architecture
+	StrictReportPlatform bool
+
+	// How reports in a report directory are matched to image platforms: "full"
+	// (os/arch/variant@osversion) or "os-arch"
+	ReportPlatformKey string
+
+	// platform=path pairs giving the report of each platform to patch, instead of
+	// matching the reports in a report directory to platforms
+	PlatformReportMap []string
+
+	// Extensions of the files read as reports from a report directory (empty = .json)
+	ReportExtensions []string
+
+	// Run a command for every emulated platform before patching to check QEMU works
+	VerifyEmulation bool
+
+	// Run the scanner when no report is given, passing ScannerArgs through to it
+	Scan        bool
+	ScannerArgs []string
+
+	// Re-scan the patched image and fail if any targeted vulnerability remains
+	ConfirmFixed bool
+
+	// Severity vocabulary (highest, nvd or vendor) and the minimum severity to patch
+	SeveritySource string
+	MinSeverity    string
+
+	// KEV catalog file or URL used to mark known exploited vulnerabilities, and
+	// whether to patch only those
+	KEVCatalog string
+	KEVOnly    bool
+
+	// Policy file with the package upgrades that may be applied
+	Policy string
+
+	// Layer compression of the exported image: gzip, zstd, estargz or uncompressed
+	// (empty = match the source image)
+	Compression string
+
+	// Secret with user:password credentials for the HTTP(S) proxy, as src=<file>
+	// or env=<variable>
+	ProxySecret string
+
+	// Keep vulnerabilities without a fixed version in the parsed report for VEX
+	// output; they are never installed
+	IncludeUnfixed bool
+
+	// User the patched image runs as, and paths chowned to that user; empty keeps
+	// the original user
+	PatchedUser      string
+	PatchedUserChown []string
+
+	// Ignore the BaseImage label of the input image and patch it as a fresh image
+	// instead of rebasing onto the labeled base
+	NoRebase bool
+
+	// Where the image to patch is resolved from: "missing" prefers BuildKit's local
+	// store, "always" pulls it from the registry
+	Pull string
+
+	// Resolve the tag of Image to its current digest before patching, so a floating
+	// tag can't move to another image during the run
+	PinDigest bool
+
+	// File the LLB definition of the patched image is written to before it is solved,
+	// with the platform added to the name for multi-platform images
+	DumpLLB string
+
+	// File the changelog of applied updates is written to (markdown for .md), and
+	// whether to add it to the patched image
+	ChangelogOutput  string
+	ChangelogInImage bool
+
+	// Add all patched files as one layer on top of the original image layers
+	SinglePatchLayer bool
+
+	// Write only the files changed by the patch to this tar file instead of
+	// producing a patched image
+	ExportDiff string
+
 	// Output configuration
 	Format   string
 	Output   string
@@ -38,9 +133,16 @@ type Options struct {
 
 	// Platform and push
 	Push      bool
+	Load      bool     // also load into the local runtime when pushing
+	PushTo    []string // additional references to push the patched image to
 	Platforms []string
 	Loader    string
 	OCIDir    string
+	// How --oci-dir handles failed platforms: strict, preserve or omit. Empty means
+	// omit with IgnoreError and strict otherwise.
+	OCIPartial string
+	// How OCIDir is written: dir, tar or tar.gz. Empty means dir.
+	OCIOutputFormat string
 
 	// Package types and library patch level
 	PkgTypes          string
@@ -49,10 +151,68 @@ type Options struct {
 	// Toolchain patch level (e.g., Go stdlib upgrade)
 	ToolchainPatchLevel string
 
+	// Only update direct Node.js dependencies listed in package.json
+	NodeDirectOnly bool
+
+	// Also update and keep Node.js devDependencies (default: production install)
+	NodeIncludeDev bool
+
+	// Shell command run inside the patched image to verify it still works
+	SmokeTest string
+
+	// Shell snippets run on the image before and after the package updates
+	PrePatchScript  string
+	PostPatchScript string
+
+	// Package manager to use instead of detecting it from the image's OS type
+	ForcePkgManager string
+
+	// Pinned repository URLs keyed by package type (deb, apk)
+	RepoSnapshots map[string]string
+
+	// Repository mirror URLs keyed by package type (deb, apk, rpm)
+	RepoMirrors map[string]string
+
+	// Upgrade Debian and Ubuntu packages from the security suite only
+	APTSecurityOnly bool
+
+	// Labels added to the patched image config, and annotations added to its
+	// manifest and, for multi-platform images, its index
+	Labels      map[string]string
+	Annotations map[string]string
+
+	// Package manager commands (apk, apt-get, npm) mapped to their path in the image
+	PkgMgrPaths map[string]string
+
+	// BuildKit cache import/export specs (e.g., type=registry,ref=...)
+	CacheFrom []string
+	CacheTo   []string
+
 	// Generate specific
 	OutputContext string
 
 	// EOL configuration
 	EOLAPIBaseURL string
 	ExitOnEOL     bool
+	// Download throttling (0 = unlimited)
+	MaxConcurrentDownloads int
+	// Platforms of a multi-platform image patched at once (0 = one per CPU)
+	MaxParallelPlatforms int
+	// Registry requests made at once (0 = utils.DefaultRegistryConcurrency)
+	RegistryConcurrency int
+	// Copy a multi-platform image's platform images to the patched repository and
+	// --push-to destinations in parallel, up to RegistryConcurrency at a time
+	ParallelRegistryPush bool
+	// Attach the VEX document to the pushed patched image as an OCI referrer
+	AttachAttestations bool
+	// Reuse one platform's patch for other platforms with the same base image layers and updates
+	SharePlatformPatches bool
+
+	// ManifestTransform, if set, is called with the parsed update manifest before any
+	// packages are installed and may modify it in place, e.g. to drop a package or
+	// rewrite a fixed version. Returning an error aborts the patch. In multi-platform
+	// mode it runs once for each platform that is patched, possibly concurrently;
+	// platforms reusing a shared patch get the transformed result of the platform
+	// they share it with. It is not called when no report is given.
+	ManifestTransform func(*unversioned.UpdateManifest) error `json:"-"`
 }
@@ -32,6 +32,9 @@ type PatchPlatform struct {
 	ispec.Platform
 	ReportFile     string `json:"reportFile"`
 	ShouldPreserve bool   `json:"shouldPreserve"`
+	// PreserveReason explains why a platform is preserved instead of patched, when
+	// it is not simply excluded by the user (e.g. missing emulation).
+	PreserveReason string `json:"preserveReason,omitempty"`
 }
 
 // String returns a string representation of the PatchPlatform.
@@ -49,6 +52,39 @@ type PatchResult struct {
 	PatchedRef   reference.Named
 	PatchedState *llb.State // BuildKit state for OCI export
 	ConfigData   []byte     // Image config data
+
+	// Platform is the platform this result was produced for. It is the key used to
+	// assemble multi-platform outputs, independent of how PatchedRef is tagged.
+	Platform        ispec.Platform
+	FixedCVEs       []string // vulnerability IDs addressed by the applied updates
+	PatchedPackages int      // number of OS package updates from the report that were applied
+	PackageTool     string   // tool the OS packages were updated with, e.g. dnf when yum links to it (empty = not applicable)
+	ErroredPackages []string // packages that failed to update and were skipped
+	SkippedPackages []string // packages left alone on purpose, e.g. transitive npm dependencies with --direct-only
+	Preserved       bool     // the original image was kept for this platform unpatched
+
+	// NoUpdatesApplied is set when the image was already up to date, so the result
+	// is the original image rather than a patched one
+	NoUpdatesApplied bool
+
+	// LayerDelta is how patching changed the image's layers, when it could be measured
+	LayerDelta *LayerDelta
+
+	// BaseChain is the patched input image followed by the images its BaseImage
+	// labels lead to, as resolved for this patch
+	BaseChain []BaseImageLink
+}
+
+// BaseImageLink is one image in a chain of BaseImage labels.
+type BaseImageLink struct {
+	Ref    string
+	Digest string // empty if the image could not be resolved
+}
+
+// LayerDelta is the difference between the layers of an original and a patched image.
+type LayerDelta struct {
+	LayersAdded int   // layers in the patched image that are not in the original
+	SizeDelta   int64 // change in the total compressed layer size, in bytes
 }
 
 type MultiPlatformSummary struct {
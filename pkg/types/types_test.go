@@ -2,6 +2,8 @@ package types
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/distribution/reference"
@@ -294,3 +296,30 @@ func TestMultiPlatformSummary(t *testing.T) {
 		assert.Contains(t, summary.Message, "Build failed")
 	})
 }
+
+func TestRebuildRequiredError(t *testing.T) {
+	cause := errors.New("no binaries were successfully rebuilt")
+	err := &RebuildRequiredError{
+		Image:  "example.com/app:1.0",
+		Reason: "image has an empty root filesystem",
+		Components: []RebuildComponent{
+			{Name: "golang.org/x/net", PkgPath: "app", InstalledVersion: "v0.17.0", FixedVersion: "v0.23.0"},
+		},
+		Err: cause,
+	}
+
+	assert.ErrorIs(t, err, ErrRebuildRequired)
+	assert.ErrorIs(t, err, cause)
+
+	wrapped := fmt.Errorf("patch failed: %w", err)
+	var rebuildErr *RebuildRequiredError
+	require.ErrorAs(t, wrapped, &rebuildErr)
+	assert.Len(t, rebuildErr.Components, 1)
+
+	msg := err.Error()
+	assert.Contains(t, msg, "rebuild required for example.com/app:1.0")
+	assert.Contains(t, msg, "golang.org/x/net [app] (v0.17.0 -> v0.23.0)")
+	assert.Contains(t, msg, cause.Error())
+
+	assert.Equal(t, ErrRebuildRequired.Error(), (&RebuildRequiredError{}).Error())
+}
@@ -4,10 +4,26 @@ type UpdateManifest struct {
 	Metadata    Metadata           `json:"metadata"`
 	OSUpdates   UpdatePackages     `json:"osupdates"`
 	LangUpdates LangUpdatePackages `json:"langupdates"`
+	// Unfixed lists reported vulnerabilities that have no fixed version. They are
+	// kept for reporting only and never installed.
+	Unfixed UpdatePackages `json:"unfixed,omitempty"`
 }
 
 type UpdatePackages []UpdatePackage
 
+// FixRequiresMajorUpgrade is the UpdatePackage status of a package whose only available
+// fix crosses a major version boundary from the installed version.
+const FixRequiresMajorUpgrade = "fix-requires-major-upgrade"
+
+// HeldByPolicy is the UpdatePackage status of a package whose upgrade the --policy file
+// does not allow. Such packages are listed in Unfixed and not installed.
+const HeldByPolicy = "held-by-policy"
+
+// InstalledOnly is the UpdatePackage status of a package added by --update-all that the
+// report lists as installed but not vulnerable. Its FixedVersion is the installed version,
+// and it is installed by name so the package manager picks the latest version.
+const InstalledOnly = "installed-only"
+
 type LangUpdatePackages []UpdatePackage
 
 type Metadata struct {
@@ -34,5 +50,8 @@ type UpdatePackage struct {
 	VulnerabilityID  string `json:"vulnerabilityID"`
 	Type             string `json:"type"`
 	Class            string `json:"class"`
-	PkgPath          string `json:"pkgPath,omitempty"` // Path to package from Trivy report (e.g., "var/lib/ghost/versions/6.2.0/node_modules/@babel/runtime/package.json")
+	Severity         string `json:"severity,omitempty"`
+	KnownExploited   bool   `json:"knownExploited,omitempty"` // Listed in a KEV catalog
+	Status           string `json:"status,omitempty"`         // Set when the fix needs review, e.g. FixRequiresMajorUpgrade
+	PkgPath          string `json:"pkgPath,omitempty"`        // Path to package from Trivy report (e.g., "var/lib/ghost/versions/6.2.0/node_modules/@babel/runtime/package.json")
 }
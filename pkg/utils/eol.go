@@ -131,6 +131,10 @@ func normalizeOSIdentifier(osType, osVersion string) (apiProduct string, apiVers
 }
 
 func CheckEOSL(osType, osVersion string) (bool, string, error) {
+	if IsRollingRelease(osType) {
+		// There is no release to reach its end of life
+		return false, "", nil
+	}
 	if osType == "" || osVersion == "" {
 		return false, "", fmt.Errorf("internal error: OS type and version must be provided for EOL check")
 	}
@@ -68,6 +68,15 @@ func TestCheckEOSL(t *testing.T) {
 			wantEOLDate:       "Not in EOL DB",
 			expectError:       false,
 		},
+		{
+			name:              "Rolling release (Wolfi)",
+			osType:            OSTypeWolfi,
+			osVersion:         "",
+			mockAPIStatusCode: http.StatusInternalServerError,
+			wantIsEOL:         false,
+			wantEOLDate:       "",
+			expectError:       false,
+		},
 		{
 			name:              "API Rate Limited - No Retry (Short Timeout)",
 			osType:            OSTypeUbuntu,
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os/exec"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -111,7 +113,7 @@ func remoteMediaType(imageRef string) (string, error) {
 		log.Debugf("failed to parse reference %s: %v", imageRef, err)
 		return "", err
 	}
-	desc, err := remoteGet(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	desc, err := RemoteGet(context.Background(), ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	if err != nil {
 		log.Debugf("failed to get remote media type for %s: %v", imageRef, err)
 		return "", err
@@ -119,3 +121,43 @@ func remoteMediaType(imageRef string) (string, error) {
 	log.Debugf("remote media type found for %s: %s", imageRef, desc.MediaType)
 	return string(desc.MediaType), nil
 }
+
+// Layer compressions accepted by --compression and the BuildKit image exporters.
+const (
+	CompressionGzip         = "gzip"
+	CompressionZstd         = "zstd"
+	CompressionEstargz      = "estargz"
+	CompressionUncompressed = "uncompressed"
+)
+
+// ValidateCompression returns an error if compression is not a supported layer
+// compression. An empty compression is valid and means to match the source image.
+func ValidateCompression(compression string) error {
+	switch compression {
+	case "", CompressionGzip, CompressionZstd, CompressionEstargz, CompressionUncompressed:
+		return nil
+	}
+	return fmt.Errorf("unsupported compression %q, supported: %s, %s, %s, %s",
+		compression, CompressionGzip, CompressionZstd, CompressionEstargz, CompressionUncompressed)
+}
+
+// CompressionNeedsOCIMediaTypes reports whether layers with the given compression
+// can only be described by an OCI manifest: Docker manifests have no zstd layer media
+// type and drop the annotations estargz layers are found by.
+func CompressionNeedsOCIMediaTypes(compression string) bool {
+	return compression == CompressionZstd || compression == CompressionEstargz
+}
+
+// LayerCompression returns the compression of a layer with the given OCI or Docker
+// media type, or false if mediaType is not a layer media type.
+func LayerCompression(mediaType string) (string, bool) {
+	switch {
+	case strings.HasSuffix(mediaType, ".tar+zstd"):
+		return CompressionZstd, true
+	case strings.HasSuffix(mediaType, ".tar+gzip"), strings.HasSuffix(mediaType, ".tar.gzip"):
+		return CompressionGzip, true
+	case strings.HasSuffix(mediaType, ".tar"):
+		return CompressionUncompressed, true
+	}
+	return "", false
+}
@@ -13,6 +13,7 @@ import (
 	dockerClient "github.com/moby/moby/client"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/project-copacetic/copacetic/pkg/imageloader"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -221,3 +222,30 @@ func TestRemoteMediaType_InvalidReference(t *testing.T) {
 	_, err := remoteMediaType("invalid::reference")
 	require.Error(t, err)
 }
+
+func TestLayerCompression(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		want      string
+		ok        bool
+	}{
+		{"application/vnd.oci.image.layer.v1.tar+zstd", CompressionZstd, true},
+		{"application/vnd.oci.image.layer.v1.tar+gzip", CompressionGzip, true},
+		{"application/vnd.oci.image.layer.nondistributable.v1.tar+gzip", CompressionGzip, true},
+		{"application/vnd.docker.image.rootfs.diff.tar.gzip", CompressionGzip, true},
+		{"application/vnd.oci.image.layer.v1.tar", CompressionUncompressed, true},
+		{"application/vnd.oci.image.manifest.v1+json", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := LayerCompression(tt.mediaType)
+		assert.Equal(t, tt.want, got, tt.mediaType)
+		assert.Equal(t, tt.ok, ok, tt.mediaType)
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	for _, c := range []string{"", CompressionGzip, CompressionZstd, CompressionEstargz, CompressionUncompressed} {
+		assert.NoError(t, ValidateCompression(c))
+	}
+	assert.ErrorContains(t, ValidateCompression("lz4"), `unsupported compression "lz4"`)
+}
@@ -25,6 +25,8 @@ const (
 	OSTypeOpenSUSELeap = "opensuse-leap"
 	OSTypeOpenSUSETW   = "opensuse-tumbleweed"
 	OSTypeArchLinux    = "archlinux"
+	OSTypeWolfi        = "wolfi"
+	OSTypeChainguard   = "chainguard"
 )
 
 // RPMDistros is a helper slice listing rpm-family OS identifiers.
@@ -98,7 +100,22 @@ func CanonicalOSType(osType string) string {
 		return OSTypeOracle
 	case strings.Contains(os, OSTypeArchLinux):
 		return OSTypeArchLinux
+	case strings.Contains(os, OSTypeWolfi):
+		return OSTypeWolfi
+	case strings.Contains(os, OSTypeChainguard):
+		return OSTypeChainguard
 	default:
 		return ""
 	}
 }
+
+// IsRollingRelease reports whether osType is an apk-based rolling distro such as Wolfi,
+// which has no releases: its version, if reported at all, is only a build date.
+func IsRollingRelease(osType string) bool {
+	switch CanonicalOSType(osType) {
+	case OSTypeWolfi, OSTypeChainguard:
+		return true
+	default:
+		return false
+	}
+}
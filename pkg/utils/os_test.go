@@ -15,6 +15,11 @@ func TestCanonicalOSType(t *testing.T) {
 		{name: "alpine linux", input: "alpine linux", expected: OSTypeAlpine},
 		{name: "Alpine Linux uppercase", input: "Alpine Linux", expected: OSTypeAlpine},
 
+		// Wolfi and Chainguard, also apk-based
+		{name: "wolfi exact", input: "wolfi", expected: OSTypeWolfi},
+		{name: "Wolfi os-release name", input: "Wolfi", expected: OSTypeWolfi},
+		{name: "chainguard exact", input: "chainguard", expected: OSTypeChainguard},
+
 		// Debian variations
 		{name: "debian exact", input: "debian", expected: OSTypeDebian},
 		{name: "Debian GNU/Linux", input: "Debian GNU/Linux", expected: OSTypeDebian},
@@ -0,0 +1,59 @@
+package utils
+
+import "strings"
+
+// canonicalPackageNames maps distro-specific binary package names to the upstream
+// project they are built from, keyed by package manager type (apk, deb, rpm).
+// Only names that differ from their upstream project are listed; musl-based Alpine
+// and glibc-based distros split and name the same libraries differently, e.g.
+// OpenSSL ships as libcrypto3/libssl3 on Alpine, libssl3 on Debian and openssl-libs
+// on RHEL-family distros.
+var canonicalPackageNames = map[string]map[string]string{
+	"apk": {
+		"libcrypto1.1":  "openssl",
+		"libcrypto3":    "openssl",
+		"libssl1.1":     "openssl",
+		"libssl3":       "openssl",
+		"musl-utils":    "musl",
+		"libcurl":       "curl",
+		"libexpat":      "expat",
+		"libxml2-utils": "libxml2",
+		"openssl-dev":   "openssl",
+		"sqlite-libs":   "sqlite",
+	},
+	"deb": {
+		"libc6":           "glibc",
+		"libc-bin":        "glibc",
+		"libc-l10n":       "glibc",
+		"locales":         "glibc",
+		"libssl1.1":       "openssl",
+		"libssl3":         "openssl",
+		"libssl3t64":      "openssl",
+		"zlib1g":          "zlib",
+		"libcurl4":        "curl",
+		"libcurl3-gnutls": "curl",
+		"libexpat1":       "expat",
+		"libsqlite3-0":    "sqlite",
+		"libgnutls30":     "gnutls",
+	},
+	"rpm": {
+		"glibc-common":           "glibc",
+		"glibc-minimal-langpack": "glibc",
+		"openssl-libs":           "openssl",
+		"libcurl":                "curl",
+		"libcurl-minimal":        "curl",
+		"sqlite-libs":            "sqlite",
+	},
+}
+
+// CanonicalPackageName returns the upstream project name for an OS package as it is
+// named inside an image, for cross-referencing packages across distros (e.g. in VEX
+// documents). pkgType may be an OS type or a package manager type. Names without a
+// known mapping are returned unchanged.
+// The canonical name is for reporting only; installs always use the in-image name.
+func CanonicalPackageName(pkgType, name string) string {
+	if canonical, ok := canonicalPackageNames[CanonicalPkgManagerType(strings.ToLower(pkgType))][name]; ok {
+		return canonical
+	}
+	return name
+}
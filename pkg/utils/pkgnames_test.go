@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalPackageName(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkgType string
+		pkg     string
+		want    string
+	}{
+		{"alpine libcrypto3", "apk", "libcrypto3", "openssl"},
+		{"alpine libssl3", "apk", "libssl3", "openssl"},
+		{"debian libssl3", "deb", "libssl3", "openssl"},
+		{"rhel openssl-libs", "rpm", "openssl-libs", "openssl"},
+		{"debian libc6", "deb", "libc6", "glibc"},
+		{"alpine musl-utils", "apk", "musl-utils", "musl"},
+		{"alpine musl is already canonical", "apk", "musl", "musl"},
+		{"debian zlib1g", "deb", "zlib1g", "zlib"},
+		{"alpine zlib is already canonical", "apk", "zlib", "zlib"},
+		{"os type is accepted", OSTypeUbuntu, "libcurl4", "curl"},
+		{"mapping is per package manager", "deb", "libcurl", "libcurl"},
+		{"unknown package", "apk", "busybox", "busybox"},
+		{"unknown package manager", "pacman", "libssl3", "libssl3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CanonicalPackageName(tt.pkgType, tt.pkg))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Prefixes for per-platform artifacts, e.g. report-linux-amd64.json and vex-linux-arm64.json.
+const (
+	ReportArtifactPrefix    = "report"
+	VEXArtifactPrefix       = "vex"
+	ChangelogArtifactPrefix = "changelog"
+
+	artifactExt = ".json"
+)
+
+// PlatformFileSuffix returns the normalized platform used in per-platform file names,
+// e.g. "linux-amd64" or "linux-arm-v7". The default arm64 variant v8 is omitted.
+func PlatformFileSuffix(p ocispec.Platform) string {
+	parts := []string{p.OS, p.Architecture}
+	if p.Variant != "" && (p.Architecture != "arm64" || p.Variant != "v8") {
+		parts = append(parts, p.Variant)
+	}
+	return strings.Join(parts, "-")
+}
+
+// PlatformArtifactName returns the file name for a per-platform artifact, e.g.
+// PlatformArtifactName(VEXArtifactPrefix, linux/arm64) is "vex-linux-arm64.json".
+// An empty prefix yields the bare platform name, e.g. "linux-arm64.json".
+func PlatformArtifactName(prefix string, p ocispec.Platform) string {
+	name := PlatformFileSuffix(p) + artifactExt
+	if prefix == "" {
+		return name
+	}
+	return prefix + "-" + name
+}
+
+// PlatformReportNames lists the file names a per-platform report may use inside a
+// report directory, preferred name first. Names carrying an explicit arm64 v8 variant
+// are still accepted for directories written before the names were normalized.
+func PlatformReportNames(p ocispec.Platform) []string {
+	names := []string{
+		PlatformArtifactName(ReportArtifactPrefix, p),
+		PlatformArtifactName("", p),
+	}
+	if p.Variant != "" && PlatformFileSuffix(p) != p.OS+"-"+p.Architecture+"-"+p.Variant {
+		names = append(names, p.OS+"-"+p.Architecture+"-"+p.Variant+artifactExt)
+	}
+	return names
+}
+
+// PlatformFromReportName returns the platform of a report named as PlatformReportNames
+// lists them, given the name without its extension, e.g. "report-linux-arm-v7" or
+// "linux-amd64". ok is false for names that don't carry a platform.
+func PlatformFromReportName(name string) (p ocispec.Platform, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(name, ReportArtifactPrefix+"-"), "-")
+	if len(parts) < 2 || len(parts) > 3 || (parts[0] != "linux" && parts[0] != "windows") || parts[1] == "" {
+		return ocispec.Platform{}, false
+	}
+	p = ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return ocispec.Platform{}, false
+		}
+		p.Variant = parts[2]
+	}
+	return p, true
+}
+
+// IsPlatformArtifact reports whether name is a per-platform artifact with the given prefix.
+func IsPlatformArtifact(prefix, name string) bool {
+	return strings.HasPrefix(name, prefix+"-") && strings.HasSuffix(name, artifactExt)
+}
+
+// PlatformOutputPath derives a per-platform output path from a user-supplied one.
+// An existing directory receives "<prefix>-<platform>.json"; for a file path the
+// platform is inserted before the extension, so "vex.json" becomes "vex-linux-arm64.json".
+func PlatformOutputPath(output, prefix string, p ocispec.Platform) string {
+	if fi, err := os.Stat(output); err == nil && fi.IsDir() {
+		return filepath.Join(output, PlatformArtifactName(prefix, p))
+	}
+	ext := filepath.Ext(output)
+	if ext == "" {
+		ext = artifactExt
+	}
+	return strings.TrimSuffix(output, filepath.Ext(output)) + "-" + PlatformFileSuffix(p) + ext
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformArtifactName(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		platform ocispec.Platform
+		want     string
+	}{
+		{"vex amd64", VEXArtifactPrefix, ocispec.Platform{OS: "linux", Architecture: "amd64"}, "vex-linux-amd64.json"},
+		{"report arm v7", ReportArtifactPrefix, ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, "report-linux-arm-v7.json"},
+		{"arm64 v8 is normalized", VEXArtifactPrefix, ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, "vex-linux-arm64.json"},
+		{"no prefix", "", ocispec.Platform{OS: "linux", Architecture: "s390x"}, "linux-s390x.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PlatformArtifactName(tt.prefix, tt.platform))
+		})
+	}
+}
+
+func TestPlatformArtifactRoundTrip(t *testing.T) {
+	p := ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+
+	// A report written by Copa is the first name looked up on input.
+	assert.Equal(t, PlatformArtifactName(ReportArtifactPrefix, p), PlatformReportNames(p)[0])
+	assert.Equal(t, []string{"report-linux-arm64.json", "linux-arm64.json", "linux-arm64-v8.json"}, PlatformReportNames(p))
+
+	// VEX output is recognized so report discovery can skip it.
+	assert.True(t, IsPlatformArtifact(VEXArtifactPrefix, PlatformArtifactName(VEXArtifactPrefix, p)))
+	assert.False(t, IsPlatformArtifact(VEXArtifactPrefix, PlatformArtifactName(ReportArtifactPrefix, p)))
+}
+
+func TestPlatformFromReportName(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   ocispec.Platform
+		wantOK bool
+	}{
+		{"report-linux-amd64", ocispec.Platform{OS: "linux", Architecture: "amd64"}, true},
+		{"linux-arm-v7", ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+		{"linux-arm64-v8", ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, true},
+		{"trivy-results", ocispec.Platform{}, false},
+		{"linux", ocispec.Platform{}, false},
+		{"linux-arm-v7-extra", ocispec.Platform{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PlatformFromReportName(tt.name)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPlatformOutputPath(t *testing.T) {
+	p := ocispec.Platform{OS: "linux", Architecture: "arm64"}
+	dir := t.TempDir()
+
+	assert.Equal(t, filepath.Join(dir, "vex-linux-arm64.json"), PlatformOutputPath(dir, VEXArtifactPrefix, p))
+	assert.Equal(t, filepath.Join(dir, "vex-linux-arm64.json"), PlatformOutputPath(filepath.Join(dir, "vex.json"), VEXArtifactPrefix, p))
+	assert.Equal(t, filepath.Join(dir, "out-linux-arm64.openvex"), PlatformOutputPath(filepath.Join(dir, "out.openvex"), VEXArtifactPrefix, p))
+	assert.Equal(t, filepath.Join(dir, "out-linux-arm64.json"), PlatformOutputPath(filepath.Join(dir, "out"), VEXArtifactPrefix, p))
+	assert.Equal(t, filepath.Join(dir, "changes-linux-arm64.md"), PlatformOutputPath(filepath.Join(dir, "changes.md"), ChangelogArtifactPrefix, p))
+}
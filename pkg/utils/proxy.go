@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+)
+
+// BuildKit secret IDs the proxy URLs are passed to package manager steps with.
+const (
+	httpProxySecretID  = "copa-http-proxy"
+	httpsProxySecretID = "copa-https-proxy"
+)
+
+type proxyCredentialsKey struct{}
+
+// WithProxyCredentials returns a copy of ctx whose ProxySecrets add user and password
+// to proxy URLs without credentials of their own.
+func WithProxyCredentials(ctx context.Context, user, password string) context.Context {
+	return context.WithValue(ctx, proxyCredentialsKey{}, url.UserPassword(user, password))
+}
+
+// LoadProxySecret reads proxy credentials in user:password form from a secret given
+// in BuildKit secret syntax: src=<file> or env=<variable>, or just the file path. The
+// credentials only reach BuildKit through the session secrets of ProxySecrets, so
+// they are not part of the build definition or any image layer or config.
+func LoadProxySecret(spec string) (user, password string, err error) {
+	var data []byte
+	switch key, value, _ := strings.Cut(spec, "="); key {
+	case "env":
+		data = []byte(os.Getenv(value))
+		if len(data) == 0 {
+			return "", "", fmt.Errorf("proxy secret: environment variable %s is empty", value)
+		}
+	case "src", "source":
+		spec = value
+		fallthrough
+	default:
+		if data, err = os.ReadFile(spec); err != nil {
+			return "", "", fmt.Errorf("proxy secret: %w", err)
+		}
+	}
+
+	user, password, ok := strings.Cut(strings.TrimSpace(string(data)), ":")
+	if !ok || user == "" {
+		return "", "", errors.New("proxy secret must contain user:password")
+	}
+	return user, password, nil
+}
+
+// WithProxy returns the run option that gives a package manager step the proxy
+// settings of the environment. NO_PROXY is set as a BuildKit proxy setting, while the
+// HTTP_PROXY and HTTPS_PROXY URLs are read from the secrets of ProxySecrets, so they
+// and their credentials stay out of the LLB sent to BuildKit and of --dump-llb.
+func WithProxy() llb.RunOption {
+	opts := runOptions{llb.WithProxy(llb.ProxyEnv{NoProxy: getEnvAny("NO_PROXY")})}
+	if getEnvAny("HTTP_PROXY") != "" {
+		for _, name := range []string{"HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"} {
+			opts = append(opts, llb.AddSecretWithDest(httpProxySecretID, nil, llb.SecretAsEnvName(name)))
+		}
+	}
+	if getEnvAny("HTTPS_PROXY") != "" {
+		for _, name := range []string{"HTTPS_PROXY", "https_proxy"} {
+			opts = append(opts, llb.AddSecretWithDest(httpsProxySecretID, nil, llb.SecretAsEnvName(name)))
+		}
+	}
+	return opts
+}
+
+type runOptions []llb.RunOption
+
+func (o runOptions) SetRunOption(ei *llb.ExecInfo) {
+	for _, opt := range o {
+		opt.SetRunOption(ei)
+	}
+}
+
+// ProxySecrets returns the session attachable that serves the proxy URLs WithProxy
+// reads, with the credentials of ctx added to URLs without any. Every solve of
+// package manager steps must attach it.
+func ProxySecrets(ctx context.Context) session.Attachable {
+	secrets := make(map[string][]byte)
+	for id, proxy := range proxyURLs(ctx) {
+		if proxy != "" {
+			secrets[id] = []byte(proxy)
+		}
+	}
+	return secretsprovider.FromMap(secrets)
+}
+
+// proxyURLs returns the HTTP_PROXY and HTTPS_PROXY URLs of the environment by secret
+// ID, with the credentials of ctx added to URLs without any.
+func proxyURLs(ctx context.Context) map[string]string {
+	creds, _ := ctx.Value(proxyCredentialsKey{}).(*url.Userinfo)
+	return map[string]string{
+		httpProxySecretID:  withProxyCredentials(getEnvAny("HTTP_PROXY"), creds),
+		httpsProxySecretID: withProxyCredentials(getEnvAny("HTTPS_PROXY"), creds),
+	}
+}
+
+// withProxyCredentials adds creds to proxy, unless it has credentials already or is
+// not a URL.
+func withProxyCredentials(proxy string, creds *url.Userinfo) string {
+	if proxy == "" || creds == nil {
+		return proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" || u.User != nil {
+		return proxy
+	}
+	u.User = creds
+	return u.String()
+}
+
+// ProxyPasswords returns the passwords of the proxy URLs of ProxySecrets, both as
+// they are and URL-escaped, so they can be redacted wherever they might be recorded.
+func ProxyPasswords(ctx context.Context) []string {
+	var passwords []string
+	urls := proxyURLs(ctx)
+	for _, id := range []string{httpProxySecretID, httpsProxySecretID} {
+		u, err := url.Parse(urls[id])
+		if err != nil || u.User == nil {
+			continue
+		}
+		password, ok := u.User.Password()
+		if !ok || password == "" {
+			continue
+		}
+		_, escaped, _ := strings.Cut(u.User.String(), ":")
+		for _, s := range []string{password, escaped} {
+			if !slices.Contains(passwords, s) {
+				passwords = append(passwords, s)
+			}
+		}
+	}
+	return passwords
+}
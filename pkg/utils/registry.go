@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// DefaultRegistryConcurrency is the number of registry requests Copa makes at once
+// unless --registry-concurrency says otherwise. It is kept low so multi-platform
+// patching does not trip registry rate limits.
+const DefaultRegistryConcurrency = 4
+
+// defaultRegistrySlots is the semaphore of registry requests made with a context
+// that has no limit of its own.
+var defaultRegistrySlots = make(chan struct{}, DefaultRegistryConcurrency)
+
+type registrySlotsKey struct{}
+
+// WithRegistryConcurrency returns a copy of ctx whose registry requests may have n in
+// flight at once, independently of other contexts. Values below 1 use the default.
+func WithRegistryConcurrency(ctx context.Context, n int) context.Context {
+	if n < 1 {
+		n = DefaultRegistryConcurrency
+	}
+	return context.WithValue(ctx, registrySlotsKey{}, make(chan struct{}, n))
+}
+
+// registrySlots returns the semaphore of the registry requests made with ctx.
+func registrySlots(ctx context.Context) chan struct{} {
+	if slots, ok := ctx.Value(registrySlotsKey{}).(chan struct{}); ok {
+		return slots
+	}
+	return defaultRegistrySlots
+}
+
+// AcquireRegistrySlot blocks until a registry request may start, or ctx is done. The
+// returned release function must be called once the request has finished.
+func AcquireRegistrySlot(ctx context.Context) (release func(), err error) {
+	slots := registrySlots(ctx)
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// RemoteGet fetches the descriptor of ref from its registry once a registry slot is
+// free, so concurrent lookups stay within the --registry-concurrency limit.
+func RemoteGet(ctx context.Context, ref name.Reference, options ...remote.Option) (*remote.Descriptor, error) {
+	release, err := AcquireRegistrySlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return remoteGet(ref, append(options, remote.WithContext(ctx))...)
+}
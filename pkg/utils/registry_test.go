@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteGetRespectsRegistryConcurrency(t *testing.T) {
+	origRemoteGet := remoteGet
+	defer func() { remoteGet = origRemoteGet }()
+
+	var inFlight, maxInFlight, calls atomic.Int32
+	remoteGet = func(_ name.Reference, _ ...remote.Option) (*remote.Descriptor, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return &remote.Descriptor{}, nil
+	}
+
+	const limit = 2
+	ctx := WithRegistryConcurrency(context.Background(), limit)
+
+	ref, err := name.ParseReference("docker.io/library/alpine:3.20")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := RemoteGet(ctx, ref)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(10), calls.Load())
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(limit))
+}
+
+func TestAcquireRegistrySlotCanceled(t *testing.T) {
+	runCtx := WithRegistryConcurrency(context.Background(), 1)
+
+	release, err := AcquireRegistrySlot(runCtx)
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithCancel(runCtx)
+	cancel()
+	_, err = AcquireRegistrySlot(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Another run has slots of its own
+	release2, err := AcquireRegistrySlot(WithRegistryConcurrency(context.Background(), 1))
+	require.NoError(t, err)
+	release2()
+}
+
+func TestWithRegistryConcurrencyDefault(t *testing.T) {
+	assert.Equal(t, DefaultRegistryConcurrency, cap(registrySlots(WithRegistryConcurrency(context.Background(), 0))))
+	assert.Equal(t, DefaultRegistryConcurrency, cap(registrySlots(context.Background())))
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth/authprovider"
+)
+
+// RegistryAuth returns the registry credentials BuildKit and the manifest list push
+// use. They are looked up with the same keychain as Copa's own registry requests: the
+// Docker config, including its credsStore and credHelpers, then the Podman auth file,
+// so an image can be pushed wherever `docker login` or a credential helper has
+// stored credentials for its registry.
+func RegistryAuth() authprovider.AuthConfigProvider {
+	return keychainAuthConfig(authn.DefaultKeychain)
+}
+
+// RegistryAuthSession returns the session attachables that give BuildKit the
+// credentials of RegistryAuth for pulls and pushes.
+func RegistryAuthSession() []session.Attachable {
+	return []session.Attachable{authprovider.NewDockerAuthProvider(authprovider.DockerAuthProviderConfig{
+		AuthConfigProvider: RegistryAuth(),
+	})}
+}
+
+type authCacheEntry struct {
+	created time.Time
+	auth    types.AuthConfig
+}
+
+// keychainAuthConfig adapts keychain to BuildKit's auth config lookup. Credentials are
+// cached by host so a credential helper is not run for every blob of a push.
+func keychainAuthConfig(keychain authn.Keychain) authprovider.AuthConfigProvider {
+	var mu sync.Mutex
+	cache := map[string]authCacheEntry{}
+
+	return func(_ context.Context, host string, _ []string, expired authprovider.ExpireCachedAuthCheck) (types.AuthConfig, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if entry, ok := cache[host]; ok && (expired == nil || !expired(entry.created, host)) {
+			return entry.auth, nil
+		}
+
+		registry := host
+		if host == authprovider.DockerHubRegistryHost {
+			// The keychain files Docker Hub credentials under index.docker.io
+			registry = name.DefaultRegistry
+		}
+		reg, err := name.NewRegistry(registry)
+		if err != nil {
+			return types.AuthConfig{}, fmt.Errorf("invalid registry host %q: %w", host, err)
+		}
+		authenticator, err := keychain.Resolve(reg)
+		if err != nil {
+			return types.AuthConfig{}, fmt.Errorf("failed to look up credentials for %s: %w", host, err)
+		}
+		cfg, err := authenticator.Authorization()
+		if err != nil {
+			return types.AuthConfig{}, fmt.Errorf("failed to get credentials for %s: %w", host, err)
+		}
+
+		ac := types.AuthConfig{
+			Username:      cfg.Username,
+			Password:      cfg.Password,
+			Auth:          cfg.Auth,
+			IdentityToken: cfg.IdentityToken,
+			RegistryToken: cfg.RegistryToken,
+			ServerAddress: host,
+		}
+		cache[host] = authCacheEntry{created: time.Now(), auth: ac}
+		return ac, nil
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeychain stands in for the Docker config and its credential helpers.
+type fakeKeychain struct {
+	creds    map[string]authn.AuthConfig
+	err      error
+	resolved []string
+}
+
+func (k *fakeKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	k.resolved = append(k.resolved, target.RegistryStr())
+	if k.err != nil {
+		return nil, k.err
+	}
+	cfg, ok := k.creds[target.RegistryStr()]
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(cfg), nil
+}
+
+func TestKeychainAuthConfig(t *testing.T) {
+	ctx := context.Background()
+	keychain := &fakeKeychain{creds: map[string]authn.AuthConfig{
+		"index.docker.io":       {Username: "hubuser", Password: "hubpass"},
+		"myregistry.azurecr.io": {IdentityToken: "refresh-token"},
+	}}
+	auth := keychainAuthConfig(keychain)
+
+	ac, err := auth(ctx, authprovider.DockerHubRegistryHost, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hubuser", ac.Username)
+	assert.Equal(t, "hubpass", ac.Password)
+
+	ac, err = auth(ctx, "myregistry.azurecr.io", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "refresh-token", ac.IdentityToken)
+
+	ac, err = auth(ctx, "ghcr.io", nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, ac.Username)
+	assert.Empty(t, ac.Password)
+
+	// Cached until BuildKit says the entry expired
+	_, err = auth(ctx, "myregistry.azurecr.io", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"index.docker.io", "myregistry.azurecr.io", "ghcr.io"}, keychain.resolved)
+
+	expired := func(time.Time, string) bool { return true }
+	_, err = auth(ctx, "myregistry.azurecr.io", nil, expired)
+	require.NoError(t, err)
+	assert.Len(t, keychain.resolved, 4)
+}
+
+func TestKeychainAuthConfigError(t *testing.T) {
+	auth := keychainAuthConfig(&fakeKeychain{err: errors.New("credential helper not found")})
+
+	_, err := auth(context.Background(), "ghcr.io", nil, nil)
+	assert.ErrorContains(t, err, "credential helper not found")
+	assert.ErrorContains(t, err, "ghcr.io")
+}
@@ -16,7 +16,6 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
-	"github.com/moby/buildkit/client/llb"
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/project-copacetic/copacetic/pkg/imageloader"
@@ -51,6 +50,7 @@ const (
 // Examples:
 //
 //	alpine              -> apk
+//	wolfi               -> apk
 //	debian              -> deb
 //	ubuntu              -> deb
 //	centos              -> rpm
@@ -67,7 +67,7 @@ func CanonicalPkgManagerType(raw string) string {
 	// Normalize once for matching; we still return the original raw when already canonical
 	lowered := strings.ToLower(raw)
 	switch lowered { // normalize case defensively
-	case OSTypeAlpine:
+	case OSTypeAlpine, OSTypeWolfi, OSTypeChainguard:
 		return "apk"
 	case OSTypeDebian, OSTypeUbuntu:
 		return "deb"
@@ -93,6 +93,28 @@ func DeduplicateStringSlice(input []string) []string {
 	return result
 }
 
+// ParseKeyValues parses the key=value values of a repeatable flag, such as --label,
+// into a map. Values may be empty and may contain '='; a key given twice is an error.
+func ParseKeyValues(flag string, specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid %s %q: expected <key>=<value>", flag, spec)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("invalid %s %q: %s specified more than once", flag, spec, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
 func EnsurePath(path string, perm fs.FileMode) (bool, error) {
 	createdPath := false
 	st, err := os.Stat(path)
@@ -136,16 +158,6 @@ func getEnvAny(names ...string) string {
 	return ""
 }
 
-func GetProxy() llb.ProxyEnv {
-	proxy := llb.ProxyEnv{
-		HTTPProxy:  getEnvAny("HTTP_PROXY"),
-		HTTPSProxy: getEnvAny("HTTPS_PROXY"),
-		NoProxy:    getEnvAny("NO_PROXY"),
-		AllProxy:   getEnvAny("HTTP_PROXY"),
-	}
-	return proxy
-}
-
 // localImageDescriptor tries to get the OCI image descriptor using the local Docker client.
 func localImageDescriptor(ctx context.Context, imageRef string) (*ocispec.Descriptor, error) {
 	cli, err := newClient()
@@ -234,13 +246,13 @@ func podmanImageDescriptor(ctx context.Context, imageRef string) (*ocispec.Descr
 }
 
 // remoteImageDescriptor tries to get the OCI image descriptor from a remote registry.
-func remoteImageDescriptor(imageRef string) (*ocispec.Descriptor, error) {
+func remoteImageDescriptor(ctx context.Context, imageRef string) (*ocispec.Descriptor, error) {
 	ref, err := name.ParseReference(imageRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse image reference '%s': %w", imageRef, err)
 	}
 
-	ggcrDesc, err := remoteGet(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	ggcrDesc, err := RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	if err != nil {
 		log.Debugf("failed to get remote descriptor for %s: %v", imageRef, err)
 		return nil, fmt.Errorf("failed to get remote descriptor for '%s': %w", imageRef, err)
@@ -299,7 +311,7 @@ func GetImageDescriptor(ctx context.Context, imageRef, runtime string) (*ocispec
 	}
 
 	log.Debugf("attempting to get remote image descriptor for %s", imageRef)
-	remoteDesc, remoteErr := remoteImageDescriptor(imageRef)
+	remoteDesc, remoteErr := remoteImageDescriptor(ctx, imageRef)
 	if remoteErr != nil {
 		log.Errorf("failed to get remote image descriptor for %s: %v", imageRef, remoteErr)
 		if isNotFoundError {
@@ -314,14 +326,14 @@ func GetImageDescriptor(ctx context.Context, imageRef, runtime string) (*ocispec
 
 // GetIndexManifestAnnotations retrieves annotations from an image index manifest.
 // This is specifically for multi-platform images to get the index-level annotations.
-func GetIndexManifestAnnotations(_ context.Context, imageRef string) (map[string]string, error) {
+func GetIndexManifestAnnotations(ctx context.Context, imageRef string) (map[string]string, error) {
 	ref, err := name.ParseReference(imageRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse image reference '%s': %w", imageRef, err)
 	}
 
 	// First check if this is an index
-	desc, err := remoteGet(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	desc, err := RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get descriptor for '%s': %w", imageRef, err)
 	}
@@ -350,14 +362,14 @@ func GetIndexManifestAnnotations(_ context.Context, imageRef string) (map[string
 
 // GetPlatformManifestAnnotations retrieves manifest-level annotations for a specific platform
 // from an image index manifest.
-func GetPlatformManifestAnnotations(_ context.Context, imageRef string, targetPlatform *ocispec.Platform) (map[string]string, error) {
+func GetPlatformManifestAnnotations(ctx context.Context, imageRef string, targetPlatform *ocispec.Platform) (map[string]string, error) {
 	ref, err := name.ParseReference(imageRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse image reference '%s': %w", imageRef, err)
 	}
 
 	// First check if this is an index
-	desc, err := remoteGet(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	desc, err := RemoteGet(ctx, ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get descriptor for '%s': %w", imageRef, err)
 	}
@@ -412,14 +424,19 @@ func GetPlatformManifestAnnotations(_ context.Context, imageRef string, targetPl
 
 // GetSinglePlatformManifestAnnotations retrieves annotations from a single-platform manifest.
 // This is used when we need to get annotations from a pushed single-platform image.
-func GetSinglePlatformManifestAnnotations(_ context.Context, imageRef string) (map[string]string, error) {
+func GetSinglePlatformManifestAnnotations(ctx context.Context, imageRef string) (map[string]string, error) {
 	ref, err := name.ParseReference(imageRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse image reference '%s': %w", imageRef, err)
 	}
 
 	// Get the image
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	release, err := AcquireRegistrySlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	img, err := remote.Image(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get image '%s': %w", imageRef, err)
 	}
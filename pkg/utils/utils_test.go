@@ -8,7 +8,6 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/moby/buildkit/client/llb"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/project-copacetic/copacetic/pkg/imageloader"
 	"github.com/stretchr/testify/assert"
@@ -167,41 +166,6 @@ func TestIsSUSEImage(t *testing.T) {
 	}
 }
 
-func TestGetProxy(t *testing.T) {
-	var got llb.ProxyEnv
-	var want llb.ProxyEnv
-
-	// Test with configured proxy
-	os.Setenv("HTTP_PROXY", "httpproxy")
-	os.Setenv("HTTPS_PROXY", "httpsproxy")
-	os.Setenv("NO_PROXY", "noproxy")
-	got = GetProxy()
-	want = llb.ProxyEnv{
-		HTTPProxy:  "httpproxy",
-		HTTPSProxy: "httpsproxy",
-		NoProxy:    "noproxy",
-		AllProxy:   "httpproxy",
-	}
-	if got != want {
-		t.Errorf("unexpected proxy config, got %#v want %#v", got, want)
-	}
-
-	// Test with unconfigured proxy
-	os.Unsetenv("HTTP_PROXY")
-	os.Unsetenv("HTTPS_PROXY")
-	os.Unsetenv("NO_PROXY")
-	got = GetProxy()
-	want = llb.ProxyEnv{
-		HTTPProxy:  "",
-		HTTPSProxy: "",
-		NoProxy:    "",
-		AllProxy:   "",
-	}
-	if got != want {
-		t.Errorf("unexpected proxy config, got %#v want %#v", got, want)
-	}
-}
-
 func TestDeduplicateStringSlice(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -256,6 +220,8 @@ func TestCanonicalPkgManagerType(t *testing.T) {
 	tests := map[string]string{
 		"alpine":              "apk",
 		"AlPiNe":              "apk",
+		"wolfi":               "apk",
+		"chainguard":          "apk",
 		"debian":              "deb",
 		"ubuntu":              "deb",
 		"rpm":                 "rpm",
@@ -343,7 +309,7 @@ func TestPodmanImageDescriptor(t *testing.T) {
 func TestRemoteImageDescriptor(t *testing.T) {
 	// Test with truly invalid image reference format that will fail parsing
 	t.Run("truly_invalid_image_reference", func(t *testing.T) {
-		desc, err := remoteImageDescriptor("")
+		desc, err := remoteImageDescriptor(context.Background(), "")
 		assert.Error(t, err)
 		assert.Nil(t, desc)
 		assert.Contains(t, err.Error(), "failed to parse image reference")
@@ -351,7 +317,7 @@ func TestRemoteImageDescriptor(t *testing.T) {
 
 	// Test with non-existent image reference (will hit registry but get auth/not found error)
 	t.Run("nonexistent_image", func(t *testing.T) {
-		desc, err := remoteImageDescriptor("definitely/does/not/exist:anywhere")
+		desc, err := remoteImageDescriptor(context.Background(), "definitely/does/not/exist:anywhere")
 		assert.Error(t, err)
 		assert.Nil(t, desc)
 		assert.Contains(t, err.Error(), "failed to get remote descriptor")
@@ -476,3 +442,20 @@ func TestGetPlatformManifestAnnotations(t *testing.T) {
 		// Should still fail at the descriptor fetch level before platform processing
 	})
 }
+
+func TestParseKeyValues(t *testing.T) {
+	values, err := ParseKeyValues("--label", []string{"com.example.build=42", "url=https://x.example/?a=b", "empty="})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"com.example.build": "42", "url": "https://x.example/?a=b", "empty": ""}, values)
+
+	values, err = ParseKeyValues("--label", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, values)
+
+	for _, spec := range []string{"novalue", "=value", " =value"} {
+		_, err := ParseKeyValues("--label", []string{spec})
+		assert.ErrorContains(t, err, "invalid --label", spec)
+	}
+	_, err = ParseKeyValues("--annotation", []string{"a=1", "a=2"})
+	assert.ErrorContains(t, err, "a specified more than once")
+}
@@ -0,0 +1,51 @@
+package validatereport
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func NewValidateReportCmd() *cobra.Command {
+	opts := Options{}
+	validateCmd := &cobra.Command{
+		Use:   "validate-report",
+		Short: "Show what Copa understands from a vulnerability report",
+		Long: `Validate-report parses a vulnerability report the way copa patch does and prints the image's
+OS, the updates Copa would apply per ecosystem, and the vulnerabilities it would skip and why.
+It does not pull or patch any image and does not need BuildKit.`,
+		Example: `  # Check a Trivy report
+  copa validate-report -r trivy.json
+
+  # Check a report from a scanner plugin
+  copa validate-report -r grype.json -s grype`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return ValidateReport(&opts, cmd.OutOrStdout())
+		},
+	}
+
+	flags := validateCmd.Flags()
+	flags.StringVarP(&opts.Report, "report", "r", "", "Vulnerability report file path")
+	flags.StringVarP(&opts.Scanner, "scanner", "s", "trivy", "Scanner that generated the report, defaults to 'trivy'")
+
+	// Experimental flags - only available when COPA_EXPERIMENTAL=1
+	if os.Getenv("COPA_EXPERIMENTAL") == "1" {
+		flags.StringVar(&opts.PkgTypes, "pkg-types", utils.PkgTypeOS,
+			"[EXPERIMENTAL] Package types to include, comma-separated list of 'os' and 'library'. "+
+				"Defaults to 'os' for OS vulnerabilities only")
+		flags.StringVar(&opts.LibraryPatchLevel, "library-patch-level", utils.PatchTypePatch,
+			"[EXPERIMENTAL] Library patch level preference: 'patch', 'minor', or 'major'. "+
+				"Only applicable when 'library' is included in --pkg-types. Defaults to 'patch'")
+	} else {
+		opts.PkgTypes = utils.PkgTypeOS
+		opts.LibraryPatchLevel = utils.PatchTypePatch
+	}
+
+	if err := validateCmd.MarkFlagRequired("report"); err != nil {
+		panic(err)
+	}
+
+	return validateCmd
+}
@@ -0,0 +1,205 @@
+package validatereport
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/project-copacetic/copacetic/pkg/pkgmgr"
+	"github.com/project-copacetic/copacetic/pkg/report"
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+// Options are the inputs to ValidateReport.
+type Options struct {
+	Report            string
+	Scanner           string
+	PkgTypes          string
+	LibraryPatchLevel string
+}
+
+// ValidateReport parses a scanner report the way copa patch does and writes what Copa
+// understood from it to w: the image's OS, the updates per ecosystem, and the
+// vulnerabilities that would not be patched and why. No image is inspected and no
+// BuildKit connection is made.
+func ValidateReport(opts *Options, w io.Writer) error {
+	if err := report.ValidateScanner(opts.Scanner); err != nil {
+		return err
+	}
+
+	// Keep vulnerabilities without a fix so they are listed as skipped
+	manifest, err := report.TryParseScanReportWithOptions(opts.Report, opts.Scanner, opts.PkgTypes, opts.LibraryPatchLevel,
+		&report.ParseOptions{IncludeUnfixed: true})
+	if err != nil {
+		return fmt.Errorf("failed to parse report %s: %w", opts.Report, err)
+	}
+	return writeSummary(w, summarize(manifest))
+}
+
+// ecosystem counts the updates Copa would apply for one package ecosystem.
+type ecosystem struct {
+	name            string
+	packages        int
+	vulnerabilities int
+}
+
+// finding is a package Copa would skip, or apply but flag for review.
+type finding struct {
+	pkg    string
+	vulns  []string
+	reason string
+}
+
+type summary struct {
+	os         string
+	ecosystems []ecosystem
+	skipped    []finding
+	review     []finding
+}
+
+// summarize sorts the updates in manifest into those Copa would apply, per ecosystem,
+// and those it would skip or apply but flag.
+func summarize(manifest *unversioned.UpdateManifest) summary {
+	s := summary{os: describeOS(manifest.Metadata)}
+	applied := make(map[string]unversioned.UpdatePackages)
+	skipped := newFindings()
+	review := newFindings()
+
+	osType := manifest.Metadata.OS.Type
+	var osSkipReason string
+	switch {
+	case osType == "":
+		osSkipReason = "the report has no OS type"
+	case !slices.Contains(pkgmgr.SupportedEcosystems(), utils.CanonicalOSType(osType)):
+		osSkipReason = fmt.Sprintf("OS type %q is not supported", osType)
+	}
+	osEcosystem := utils.CanonicalOSType(osType) + " (os)"
+	for _, u := range manifest.OSUpdates {
+		if osSkipReason != "" {
+			skipped.add(u, osSkipReason)
+			continue
+		}
+		if u.Status == unversioned.FixRequiresMajorUpgrade {
+			review.add(u, fmt.Sprintf("fix requires a major version upgrade to %s", u.FixedVersion))
+		}
+		applied[osEcosystem] = append(applied[osEcosystem], u)
+	}
+
+	for _, u := range manifest.LangUpdates {
+		switch {
+		case u.FixedVersion == "" && u.Status == unversioned.FixRequiresMajorUpgrade:
+			skipped.add(u, "every fix requires a major version upgrade")
+		case u.FixedVersion == "":
+			skipped.add(u, "no fix within the library patch level")
+		default:
+			if u.Status == unversioned.FixRequiresMajorUpgrade {
+				review.add(u, fmt.Sprintf("fix requires a major version upgrade to %s", u.FixedVersion))
+			}
+			applied[u.Type] = append(applied[u.Type], u)
+		}
+	}
+	for _, u := range manifest.Unfixed {
+		if u.Status == unversioned.HeldByPolicy {
+			skipped.add(u, "held by policy")
+		} else {
+			skipped.add(u, "no fixed version")
+		}
+	}
+
+	for name, updates := range applied {
+		pkgs := make(map[string]bool)
+		vulns := make(map[string]bool)
+		for _, u := range updates {
+			pkgs[u.Name+"\x00"+u.PkgPath] = true
+			if u.VulnerabilityID != "" {
+				vulns[u.VulnerabilityID] = true
+			}
+		}
+		s.ecosystems = append(s.ecosystems, ecosystem{name: name, packages: len(pkgs), vulnerabilities: len(vulns)})
+	}
+	sort.Slice(s.ecosystems, func(i, j int) bool { return s.ecosystems[i].name < s.ecosystems[j].name })
+	s.skipped = skipped.list()
+	s.review = review.list()
+	return s
+}
+
+func describeOS(m unversioned.Metadata) string {
+	if m.OS.Type == "" {
+		return "unknown"
+	}
+	desc := strings.TrimSpace(m.OS.Type + " " + m.OS.Version)
+	if arch := m.Config.Arch; arch != "" {
+		if m.Config.Variant != "" {
+			arch += "/" + m.Config.Variant
+		}
+		desc += " (" + arch + ")"
+	}
+	return desc
+}
+
+// findings groups the vulnerabilities of a package that share a reason.
+type findings struct {
+	order []string
+	byKey map[string]*finding
+}
+
+func newFindings() *findings {
+	return &findings{byKey: make(map[string]*finding)}
+}
+
+func (f *findings) add(u unversioned.UpdatePackage, reason string) {
+	pkg := strings.TrimSpace(u.Name + " " + u.InstalledVersion)
+	key := pkg + "\x00" + u.PkgPath + "\x00" + reason
+	existing, ok := f.byKey[key]
+	if !ok {
+		existing = &finding{pkg: pkg, reason: reason}
+		f.byKey[key] = existing
+		f.order = append(f.order, key)
+	}
+	if u.VulnerabilityID != "" && !slices.Contains(existing.vulns, u.VulnerabilityID) {
+		existing.vulns = append(existing.vulns, u.VulnerabilityID)
+	}
+}
+
+func (f *findings) list() []finding {
+	list := make([]finding, 0, len(f.order))
+	for _, key := range f.order {
+		list = append(list, *f.byKey[key])
+	}
+	return list
+}
+
+func writeSummary(w io.Writer, s summary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "OS:\t%s\n\n", s.os)
+
+	if len(s.ecosystems) == 0 {
+		fmt.Fprintln(tw, "No updates to apply")
+	} else {
+		fmt.Fprintln(tw, "ECOSYSTEM\tPACKAGES\tVULNERABILITIES")
+		for _, e := range s.ecosystems {
+			fmt.Fprintf(tw, "%s\t%d\t%d\n", e.name, e.packages, e.vulnerabilities)
+		}
+	}
+	writeFindings(tw, "Skipped", s.skipped)
+	writeFindings(tw, "Review before trusting the patched image", s.review)
+	return tw.Flush()
+}
+
+func writeFindings(w io.Writer, title string, list []finding) {
+	if len(list) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, f := range list {
+		vulns := ""
+		if len(f.vulns) > 0 {
+			vulns = " (" + strings.Join(f.vulns, ", ") + ")"
+		}
+		fmt.Fprintf(w, "  %s%s\t%s\n", f.pkg, vulns, f.reason)
+	}
+}
@@ -0,0 +1,104 @@
+package validatereport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/project-copacetic/copacetic/pkg/types/unversioned"
+	"github.com/project-copacetic/copacetic/pkg/utils"
+)
+
+func TestValidateReport(t *testing.T) {
+	var out bytes.Buffer
+	err := ValidateReport(&Options{
+		Report:            "../report/testdata/trivy_unfixed.json",
+		Scanner:           "trivy",
+		PkgTypes:          utils.PkgTypeOS + "," + utils.PkgTypeLibrary,
+		LibraryPatchLevel: utils.PatchTypePatch,
+	}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, `OS:  debian 12.5 (amd64)
+
+ECOSYSTEM    PACKAGES  VULNERABILITIES
+debian (os)  1         1
+
+Skipped:
+  libc6 2.36-9+deb12u4 (CVE-2010-4756)  no fixed version
+  left-pad 1.3.0 (CVE-2024-0001)        no fixed version
+`, out.String())
+}
+
+func TestValidateReportErrors(t *testing.T) {
+	err := ValidateReport(&Options{Report: "../report/testdata/invalid.json", Scanner: "trivy", PkgTypes: utils.PkgTypeOS}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "failed to parse report ../report/testdata/invalid.json")
+
+	err = ValidateReport(&Options{Report: "../report/testdata/trivy_valid.json", Scanner: "no-such-scanner"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "no-such-scanner")
+}
+
+func TestSummarize(t *testing.T) {
+	t.Run("unsupported OS", func(t *testing.T) {
+		s := summarize(&unversioned.UpdateManifest{
+			Metadata: unversioned.Metadata{OS: unversioned.OS{Type: "haiku", Version: "r1"}},
+			OSUpdates: unversioned.UpdatePackages{
+				{Name: "openssl", InstalledVersion: "3.0.1", FixedVersion: "3.0.2", VulnerabilityID: "CVE-2024-1"},
+				{Name: "openssl", InstalledVersion: "3.0.1", FixedVersion: "3.0.2", VulnerabilityID: "CVE-2024-2"},
+			},
+			LangUpdates: unversioned.LangUpdatePackages{
+				{Name: "requests", InstalledVersion: "2.0.0", FixedVersion: "2.0.1", VulnerabilityID: "CVE-2024-3", Type: utils.PythonPackages},
+			},
+		})
+		assert.Equal(t, "haiku r1", s.os)
+		assert.Equal(t, []ecosystem{{name: utils.PythonPackages, packages: 1, vulnerabilities: 1}}, s.ecosystems)
+		assert.Equal(t, []finding{
+			{pkg: "openssl 3.0.1", vulns: []string{"CVE-2024-1", "CVE-2024-2"}, reason: `OS type "haiku" is not supported`},
+		}, s.skipped)
+	})
+
+	t.Run("major upgrades and held packages", func(t *testing.T) {
+		s := summarize(&unversioned.UpdateManifest{
+			Metadata: unversioned.Metadata{
+				OS:     unversioned.OS{Type: "ubuntu", Version: "22.04"},
+				Config: unversioned.Config{Arch: "arm", Variant: "v7"},
+			},
+			OSUpdates: unversioned.UpdatePackages{
+				{Name: "curl", InstalledVersion: "7.81.0", FixedVersion: "7.81.1", VulnerabilityID: "CVE-2024-1"},
+				{Name: "libcurl4", InstalledVersion: "7.81.0", FixedVersion: "7.81.1", VulnerabilityID: "CVE-2024-1"},
+			},
+			LangUpdates: unversioned.LangUpdatePackages{
+				{
+					Name: "express", InstalledVersion: "4.0.0", FixedVersion: "5.0.0", VulnerabilityID: "CVE-2024-2",
+					Type: utils.NodePackages, Status: unversioned.FixRequiresMajorUpgrade,
+				},
+				{Name: "qs", InstalledVersion: "6.0.0", VulnerabilityID: "CVE-2024-3", Type: utils.NodePackages},
+			},
+			Unfixed: unversioned.UpdatePackages{
+				{Name: "bash", InstalledVersion: "5.1", FixedVersion: "6.0", VulnerabilityID: "CVE-2024-4", Status: unversioned.HeldByPolicy},
+			},
+		})
+		assert.Equal(t, "ubuntu 22.04 (arm/v7)", s.os)
+		assert.Equal(t, []ecosystem{
+			{name: utils.NodePackages, packages: 1, vulnerabilities: 1},
+			{name: "ubuntu (os)", packages: 2, vulnerabilities: 1},
+		}, s.ecosystems)
+		assert.Equal(t, []finding{
+			{pkg: "qs 6.0.0", vulns: []string{"CVE-2024-3"}, reason: "no fix within the library patch level"},
+			{pkg: "bash 5.1", vulns: []string{"CVE-2024-4"}, reason: "held by policy"},
+		}, s.skipped)
+		assert.Equal(t, []finding{
+			{pkg: "express 4.0.0", vulns: []string{"CVE-2024-2"}, reason: "fix requires a major version upgrade to 5.0.0"},
+		}, s.review)
+	})
+
+	t.Run("no OS type", func(t *testing.T) {
+		s := summarize(&unversioned.UpdateManifest{
+			OSUpdates: unversioned.UpdatePackages{{Name: "zlib", InstalledVersion: "1.2", FixedVersion: "1.3"}},
+		})
+		assert.Equal(t, "unknown", s.os)
+		assert.Empty(t, s.ecosystems)
+		assert.Equal(t, []finding{{pkg: "zlib 1.2", reason: "the report has no OS type"}}, s.skipped)
+	})
+}
@@ -3,8 +3,11 @@ package vex
 
 import (
 	"bytes"
+	"fmt"
 	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/openvex/go-vex/pkg/vex"
@@ -19,6 +22,18 @@ var (
 	generateID = func(doc *vex.VEX) (string, error) { return doc.GenerateCanonicalID() }
 )
 
+// unfixedActionStatement is the action statement of "affected" statements emitted for
+// vulnerabilities that had no fixed version in the scan report.
+const unfixedActionStatement = "No fixed version was available when the image was patched; update the image once a fix is released"
+
+// heldActionStatement is the action statement of "affected" statements emitted for
+// vulnerabilities whose fix the patch policy did not allow.
+const heldActionStatement = "The fix was held by the patch policy; review and apply the update manually"
+
+// knownExploitedNote is the status note of statements for vulnerabilities listed in
+// the KEV catalog given to --kev-catalog.
+const knownExploitedNote = "Listed as known exploited in the KEV catalog"
+
 type OpenVex struct{}
 
 func (o *OpenVex) CreateVEXDocument(
@@ -54,22 +69,25 @@ func (o *OpenVex) CreateVEXDocument(
 		},
 	}
 
-	// helper closure to add a single update (OS or language) to the VEX doc
-	addUpdate := func(u unversioned.UpdatePackage) {
-		// skip entries without vulnerability IDs; VEX statements represent fixed vulns
+	// helper closure to add a single update (OS or language) to the VEX doc, either as
+	// fixed or, for vulnerabilities without a fix, as affected
+	addUpdate := func(u unversioned.UpdatePackage, status vex.Status) {
+		// skip entries without vulnerability IDs; VEX statements need one
 		if u.VulnerabilityID == "" {
 			log.Debugf("skipping update %s: empty vulnerability id for VEX", u.Name)
 			return
 		}
-		// skip if no fixed version resolved (not actually patched)
-		if u.FixedVersion == "" {
-			log.Debugf("skipping update %s: empty fixed version (no patch applied)", u.Name)
-			return
-		}
-		// skip if installed version equals fixed version (no change performed)
-		if u.InstalledVersion != "" && u.FixedVersion == u.InstalledVersion {
-			log.Debugf("skipping update %s: fixed version equals installed version (%s)", u.Name, u.FixedVersion)
-			return
+		if status == vex.StatusFixed {
+			// skip if no fixed version resolved (not actually patched)
+			if u.FixedVersion == "" {
+				log.Debugf("skipping update %s: empty fixed version (no patch applied)", u.Name)
+				return
+			}
+			// skip if installed version equals fixed version (no change performed)
+			if u.InstalledVersion != "" && u.FixedVersion == u.InstalledVersion {
+				log.Debugf("skipping update %s: fixed version equals installed version (%s)", u.Name, u.FixedVersion)
+				return
+			}
 		}
 		// Derive canonical package manager type (apk, deb, rpm) from the OS-level pkgType.
 		// For language packages (e.g. python-pkg), u.Type triggers a separate PURL scheme below.
@@ -98,9 +116,21 @@ func (o *OpenVex) CreateVEXDocument(
 			componentID = "pkg:" + pt + "/" + updates.Metadata.OS.Type + "/" + u.Name + "@" + purlVersion + "?" + qualifiers.Encode()
 		}
 		subComponent := vex.Subcomponent{Component: vex.Component{ID: componentID}}
-		// if vulnerability id already exists, append subcomponent
+		// The purl keeps the in-image name for BOM correlation; the upstream project, which
+		// lets the same fix be matched across distros (e.g. libcrypto3 and libssl3), goes
+		// in the status notes since scanners reject unknown purl qualifiers.
+		var notes []string
+		if u.KnownExploited {
+			notes = append(notes, knownExploitedNote)
+		}
+		if langType != utils.PythonPackages {
+			if upstream := utils.CanonicalPackageName(pt, u.Name); upstream != u.Name {
+				notes = append(notes, fmt.Sprintf("%s is built from upstream %s", u.Name, upstream))
+			}
+		}
+		// if a statement for the vulnerability id and status already exists, append subcomponent
 		for i := range doc.Statements {
-			if doc.Statements[i].Vulnerability.ID == u.VulnerabilityID {
+			if doc.Statements[i].Vulnerability.ID == u.VulnerabilityID && doc.Statements[i].Status == status {
 				// deduplicate identical subcomponent IDs
 				for _, existing := range doc.Statements[i].Products[0].Subcomponents {
 					if existing.ID == subComponent.ID {
@@ -109,23 +139,35 @@ func (o *OpenVex) CreateVEXDocument(
 					}
 				}
 				doc.Statements[i].Products[0].Subcomponents = append(doc.Statements[i].Products[0].Subcomponents, subComponent)
+				doc.Statements[i].StatusNotes = appendStatusNotes(doc.Statements[i].StatusNotes, notes)
 				return
 			}
 		}
 		// otherwise create new statement
 		imageProduct.Subcomponents = []vex.Subcomponent{subComponent}
-		doc.Statements = append(doc.Statements, vex.Statement{
+		statement := vex.Statement{
 			Vulnerability: vex.Vulnerability{ID: u.VulnerabilityID},
 			Products:      []vex.Product{imageProduct},
-			Status:        "fixed",
-		})
+			Status:        status,
+			StatusNotes:   appendStatusNotes("", notes),
+		}
+		if status == vex.StatusAffected {
+			statement.ActionStatement = unfixedActionStatement
+			if u.Status == unversioned.HeldByPolicy {
+				statement.ActionStatement = heldActionStatement
+			}
+		}
+		doc.Statements = append(doc.Statements, statement)
 	}
 
 	for _, u := range updates.OSUpdates {
-		addUpdate(u)
+		addUpdate(u, vex.StatusFixed)
 	}
 	for _, u := range updates.LangUpdates {
-		addUpdate(u)
+		addUpdate(u, vex.StatusFixed)
+	}
+	for _, u := range updates.Unfixed {
+		addUpdate(u, vex.StatusAffected)
 	}
 
 	var buf bytes.Buffer
@@ -136,3 +178,17 @@ func (o *OpenVex) CreateVEXDocument(
 
 	return buf.String(), nil
 }
+
+// appendStatusNotes adds the notes not already in existing to it, separated by "; ".
+func appendStatusNotes(existing string, notes []string) string {
+	var all []string
+	if existing != "" {
+		all = strings.Split(existing, "; ")
+	}
+	for _, n := range notes {
+		if !slices.Contains(all, n) {
+			all = append(all, n)
+		}
+	}
+	return strings.Join(all, "; ")
+}
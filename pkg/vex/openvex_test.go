@@ -387,6 +387,50 @@ func TestOpenVex_DistroQualifier(t *testing.T) {
 	}
 }
 
+// TestOpenVex_UpstreamNote verifies that packages named differently across distros
+// keep their in-image name in the purl and carry the upstream project in the status notes.
+func TestOpenVex_UpstreamNote(t *testing.T) {
+	t.Setenv("COPA_VEX_AUTHOR", "upstream test")
+	backupID := generateID
+	generateID = func(_ *vex.VEX) (string, error) { return "https://openvex.dev/upstream", nil }
+	defer func() { generateID = backupID }()
+
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: []unversioned.UpdatePackage{
+			{Name: "libcrypto3", InstalledVersion: "3.1.4-r1", FixedVersion: "3.1.4-r5", VulnerabilityID: "CVE-2024-0727"},
+			{Name: "libssl3", InstalledVersion: "3.1.4-r1", FixedVersion: "3.1.4-r5", VulnerabilityID: "CVE-2024-0727"},
+			{Name: "busybox", InstalledVersion: "1.36.1-r15", FixedVersion: "1.36.1-r19", VulnerabilityID: "CVE-2023-42363"},
+		},
+		Metadata: unversioned.Metadata{
+			OS:     unversioned.OS{Type: utils.OSTypeAlpine},
+			Config: unversioned.Config{Arch: "x86_64"},
+		},
+	}
+	got, err := (&OpenVex{}).CreateVEXDocument(updates, "example.io/img:patched", "apk")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(got, "upstream=") {
+		t.Errorf("purls should not carry an upstream qualifier, got: %s", got)
+	}
+
+	var doc vex.VEX
+	if err := json.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("invalid VEX JSON: %v", err)
+	}
+	notes := map[string]string{}
+	for _, s := range doc.Statements {
+		notes[string(s.Vulnerability.ID)] = s.StatusNotes
+	}
+	want := "libcrypto3 is built from upstream openssl; libssl3 is built from upstream openssl"
+	if notes["CVE-2024-0727"] != want {
+		t.Errorf("status notes = %q, want %q", notes["CVE-2024-0727"], want)
+	}
+	if notes["CVE-2023-42363"] != "" {
+		t.Errorf("unexpected status notes %q for a package with its upstream name", notes["CVE-2023-42363"])
+	}
+}
+
 // containsAll returns true if all substrings are present in s.
 func containsAll(s string, subs []string) bool {
 	for _, sub := range subs {
@@ -439,3 +483,100 @@ func deepEqualJSON(a, b any) bool {
 		return a == b
 	}
 }
+
+// TestOpenVex_UnfixedAffected verifies that vulnerabilities without a fix are listed as
+// affected, separately from a fixed statement for the same vulnerability.
+func TestOpenVex_UnfixedAffected(t *testing.T) {
+	t.Setenv("COPA_VEX_AUTHOR", "unfixed test")
+	backupID := generateID
+	generateID = func(_ *vex.VEX) (string, error) { return "https://openvex.dev/unfixed", nil }
+	defer func() { generateID = backupID }()
+
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: []unversioned.UpdatePackage{
+			{Name: "libssl3", InstalledVersion: "3.0.11-1~deb12u2", FixedVersion: "3.0.13-1~deb12u1", VulnerabilityID: "CVE-2024-0727"},
+		},
+		Unfixed: []unversioned.UpdatePackage{
+			{Name: "libc6", InstalledVersion: "2.36-9+deb12u4", VulnerabilityID: "CVE-2010-4756"},
+			{Name: "openssl", InstalledVersion: "3.0.11-1~deb12u2", VulnerabilityID: "CVE-2024-0727"},
+			{Name: "curl", InstalledVersion: "7.88.1-10+deb12u5", FixedVersion: "7.88.1-10+deb12u6", VulnerabilityID: "CVE-2024-2398", Status: unversioned.HeldByPolicy},
+		},
+		Metadata: unversioned.Metadata{
+			OS:     unversioned.OS{Type: utils.OSTypeDebian, Version: "12.5"},
+			Config: unversioned.Config{Arch: "amd64"},
+		},
+	}
+	got, err := (&OpenVex{}).CreateVEXDocument(updates, "example.io/img:patched", "deb")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc vex.VEX
+	if err := json.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("invalid VEX JSON: %v", err)
+	}
+	if len(doc.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d: %s", len(doc.Statements), got)
+	}
+	statuses := map[string]vex.Status{}
+	for _, s := range doc.Statements {
+		key := string(s.Vulnerability.ID) + " " + s.Products[0].Subcomponents[0].ID
+		statuses[key] = s.Status
+		if s.Status == vex.StatusAffected && s.ActionStatement == "" {
+			t.Errorf("affected statement for %s has no action statement", s.Vulnerability.ID)
+		}
+		if s.Vulnerability.ID == "CVE-2024-2398" && s.ActionStatement != heldActionStatement {
+			t.Errorf("held update has action statement %q, want %q", s.ActionStatement, heldActionStatement)
+		}
+	}
+	want := map[string]vex.Status{
+		"CVE-2024-0727 pkg:deb/debian/libssl3@3.0.11-1~deb12u2?arch=amd64&distro=debian-12.5": vex.StatusFixed,
+		"CVE-2010-4756 pkg:deb/debian/libc6@2.36-9+deb12u4?arch=amd64&distro=debian-12.5":     vex.StatusAffected,
+		"CVE-2024-0727 pkg:deb/debian/openssl@3.0.11-1~deb12u2?arch=amd64&distro=debian-12.5": vex.StatusAffected,
+		"CVE-2024-2398 pkg:deb/debian/curl@7.88.1-10+deb12u5?arch=amd64&distro=debian-12.5":   vex.StatusAffected,
+	}
+	for k, v := range want {
+		if statuses[k] != v {
+			t.Errorf("status of %s = %q, want %q (got %v)", k, statuses[k], v, statuses)
+		}
+	}
+}
+
+// TestOpenVex_KnownExploited verifies that statements for vulnerabilities in the KEV
+// catalog carry a status note saying so.
+func TestOpenVex_KnownExploited(t *testing.T) {
+	t.Setenv("COPA_VEX_AUTHOR", "kev test")
+	backupID := generateID
+	generateID = func(_ *vex.VEX) (string, error) { return "https://openvex.dev/kev", nil }
+	defer func() { generateID = backupID }()
+
+	updates := &unversioned.UpdateManifest{
+		OSUpdates: []unversioned.UpdatePackage{
+			{Name: "libssl3", InstalledVersion: "3.0.11-1~deb12u2", FixedVersion: "3.0.13-1~deb12u1", VulnerabilityID: "CVE-2024-0727"},
+			{Name: "libc6", InstalledVersion: "2.36-9+deb12u4", FixedVersion: "2.36-9+deb12u7", VulnerabilityID: "CVE-2024-2961", KnownExploited: true},
+		},
+		Metadata: unversioned.Metadata{
+			OS:     unversioned.OS{Type: utils.OSTypeDebian, Version: "12.5"},
+			Config: unversioned.Config{Arch: "amd64"},
+		},
+	}
+	got, err := (&OpenVex{}).CreateVEXDocument(updates, "example.io/img:patched", "deb")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc vex.VEX
+	if err := json.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("invalid VEX JSON: %v", err)
+	}
+	notes := map[string]string{}
+	for _, s := range doc.Statements {
+		notes[string(s.Vulnerability.ID)] = s.StatusNotes
+	}
+	if !strings.Contains(notes["CVE-2024-2961"], knownExploitedNote) {
+		t.Errorf("status notes of the known exploited vulnerability = %q, want %q in them", notes["CVE-2024-2961"], knownExploitedNote)
+	}
+	if strings.Contains(notes["CVE-2024-0727"], knownExploitedNote) {
+		t.Errorf("unexpected status notes %q for a vulnerability not in the catalog", notes["CVE-2024-0727"])
+	}
+}
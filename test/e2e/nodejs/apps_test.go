@@ -0,0 +1,77 @@
+package nodejs
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPatchYarnBerryPnP patches a Yarn Plug'n'Play project, which has no node_modules
+// for the npm-based node patching to find.
+func TestPatchYarnBerryPnP(t *testing.T) {
+	patchedRef, out := patchFixtureApp(t, "yarn-berry-pnp")
+	require.Contains(t, out, "Detected Yarn Plug'n'Play projects")
+
+	// yarn regenerated the lockfile and the PnP data without falling back to node_modules.
+	runInImage(t, patchedRef,
+		`test -f /app/.pnp.cjs && test ! -d /app/node_modules && grep -q '"lodash@npm:4.17.21"' /app/yarn.lock`)
+}
+
+// TestPatchNpmMultipleAppRoots patches two Node.js apps in one image that have their
+// own lockfiles and different vulnerable versions of lodash. Each app must be patched
+// from its own report entries and keep a lockfile consistent with its node_modules.
+func TestPatchNpmMultipleAppRoots(t *testing.T) {
+	patchedRef, out := patchFixtureApp(t, "npm-multi-root")
+	require.Contains(t, out, "Updating packages in /srv/api")
+	require.Contains(t, out, "Updating packages in /srv/web")
+
+	// Both apps have the fixed lodash and a dependency tree npm considers valid, and
+	// the web app's other dependency was left alone.
+	runInImage(t, patchedRef, `set -e
+		for app in /srv/api /srv/web; do
+			cd "$app"
+			test "$(node -p 'require("lodash/package.json").version')" = 4.17.21
+			npm ls --omit=dev >/dev/null
+		done
+		test "$(node -p 'require("/srv/web/node_modules/ms/package.json").version')" = 2.1.3`)
+}
+
+// patchFixtureApp builds the image in fixtures/<name>, patches its library
+// vulnerabilities and checks that none are left. It returns the patched image
+// reference and the output of copa.
+func patchFixtureApp(t *testing.T, name string) (string, string) {
+	t.Helper()
+
+	imageTag := "copa-e2e-" + name + ":latest"
+	patchedTag := "copa-e2e-" + name + ":patched"
+	t.Cleanup(func() {
+		_ = exec.Command("docker", "rmi", "-f", imageTag, patchedTag).Run()
+	})
+
+	buildOutput, err := exec.Command("docker", "build", "-t", imageTag, filepath.Join("fixtures", name)).CombinedOutput() //#nosec G204
+	require.NoError(t, err, "Failed to build docker image from fixtures/%s:\n%s", name, string(buildOutput))
+
+	cacheDir := filepath.Join(t.TempDir(), "trivy-cache")
+	downloadTrivyDB(t, cacheDir)
+
+	scanResultsFile := filepath.Join(t.TempDir(), "scan.json")
+	vulnsBefore := scanAndParse(t, imageTag, scanResultsFile, cacheDir)
+	require.NotEmpty(t, vulnsBefore, "expected to find vulnerabilities in fixtures/%s", name)
+
+	copaOutput := patchImage(t, imageTag, patchedTag, scanResultsFile)
+
+	vulnsAfter := scanAndParse(t, patchedTag, "", cacheDir)
+	assert.Empty(t, vulnsAfter, "no fixable vulnerabilities should be left after patching. Copa output:\n%s", copaOutput)
+
+	return patchedTag, copaOutput
+}
+
+// runInImage runs script with sh in image and fails the test if it exits non-zero.
+func runInImage(t *testing.T, image, script string) {
+	t.Helper()
+	out, err := exec.Command("docker", "run", "--rm", "--entrypoint=sh", image, "-c", script).CombinedOutput() //#nosec G204
+	require.NoError(t, err, string(out))
+}